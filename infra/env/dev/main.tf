@@ -36,23 +36,23 @@ locals {
 }
 
 module "network" {
-  source                = "../../modules/network"
-  name                  = local.name_prefix
-  cidr_block            = var.vpc_cidr
-  azs                   = var.azs
-  public_subnet_cidrs   = var.public_subnet_cidrs
-  private_subnet_cidrs  = var.private_subnet_cidrs
-  enable_nat_gateway    = true
-  tags                  = local.tags
+  source               = "../../modules/network"
+  name                 = local.name_prefix
+  cidr_block           = var.vpc_cidr
+  azs                  = var.azs
+  public_subnet_cidrs  = var.public_subnet_cidrs
+  private_subnet_cidrs = var.private_subnet_cidrs
+  enable_nat_gateway   = true
+  tags                 = local.tags
 }
 
 module "kms" {
-  source          = "../../modules/kms"
-  key_admin_arns  = var.key_admin_arns
+  source         = "../../modules/kms"
+  key_admin_arns = var.key_admin_arns
   # Don't pass service_roles here - they don't exist yet
   # We'll grant permissions after IAM roles are created
-  service_roles   = {}
-  tags            = local.tags
+  service_roles = {}
+  tags          = local.tags
 }
 
 module "glue_catalog" {
@@ -65,15 +65,15 @@ module "glue_catalog" {
 # Create SQS queue and DLQ for S3 event notifications
 # Note: S3 bucket ARN is constructed from bucket name, so we can use it directly
 module "sqs" {
-  source              = "../../modules/sqs"
-  queue_name          = "${local.name_prefix}-s3-events"
-  dlq_name            = "${local.name_prefix}-s3-events-dlq"
-  kms_key_id          = module.kms.key_arns.raw
-  s3_bucket_arn       = "arn:aws:s3:::${local.name_prefix}-raw"
-  account_id          = data.aws_caller_identity.current.account_id
-  message_retention_seconds = 345600  # 4 days
-  max_receive_count   = 3
-  tags                = local.tags
+  source                    = "../../modules/sqs"
+  queue_name                = "${local.name_prefix}-s3-events"
+  dlq_name                  = "${local.name_prefix}-s3-events-dlq"
+  kms_key_id                = module.kms.key_arns.raw
+  s3_bucket_arn             = "arn:aws:s3:::${local.name_prefix}-raw"
+  account_id                = data.aws_caller_identity.current.account_id
+  message_retention_seconds = 345600 # 4 days
+  max_receive_count         = 3
+  tags                      = local.tags
 }
 
 # Create DynamoDB table for file metadata
@@ -96,52 +96,57 @@ resource "null_resource" "wait_for_sqs_policy" {
   }
 
   provisioner "local-exec" {
-    command = "sleep 60"  # Wait 60 seconds for policy to propagate
+    command = "sleep 60" # Wait 60 seconds for policy to propagate
   }
 }
 
 module "s3" {
-  source                = "../../modules/s3"
-  raw_bucket_name       = "${local.name_prefix}-raw"
-  lake_bucket_name      = "${local.name_prefix}-lake"
-  audit_bucket_name     = "${local.name_prefix}-audit"
-  raw_kms_key_arn       = module.kms.key_arns.raw
-  lake_kms_key_arn      = module.kms.key_arns.lake
-  audit_kms_key_arn     = module.kms.key_arns.audit
+  source                       = "../../modules/s3"
+  raw_bucket_name              = "${local.name_prefix}-raw"
+  lake_bucket_name             = "${local.name_prefix}-lake"
+  audit_bucket_name            = "${local.name_prefix}-audit"
+  archive_bucket_name          = "${local.name_prefix}-edi-archive"
+  raw_kms_key_arn              = module.kms.key_arns.raw
+  lake_kms_key_arn             = module.kms.key_arns.lake
+  audit_kms_key_arn            = module.kms.key_arns.audit
+  archive_kms_key_arn          = module.kms.key_arns.raw
+  archive_retention_days       = var.archive_retention_days
+  archive_retention_admin_arns = var.archive_retention_admin_arns
   allowed_vpc_endpoint_ids = [
     module.network.vpc_endpoint_ids.s3
   ]
-  account_id            = data.aws_caller_identity.current.account_id
-  raw_bucket_sqs_queue_arn = module.sqs.queue_arn
+  account_id                     = data.aws_caller_identity.current.account_id
+  raw_bucket_sqs_queue_arn       = module.sqs.queue_arn
   raw_bucket_sqs_queue_policy_id = module.sqs.queue_policy_id
-  force_destroy         = true  # Allow cleanup in dev/test environments
-  tags                  = local.tags
-  
+  force_destroy                  = true # Allow cleanup in dev/test environments
+  tags                           = local.tags
+
   # Ensure SQS queue and its policy are created and propagated before configuring notifications
   # Also ensure KMS key policy is updated to allow SQS service
   # The queue policy must be fully propagated in AWS before S3 can validate the destination
   depends_on = [
     module.sqs,
-    module.sqs.queue_policy_id,  # Explicitly wait for queue policy to be created
-    null_resource.wait_for_sqs_policy,  # Wait for policy to propagate
-    aws_kms_key_policy.raw_with_sqs  # Ensure KMS key policy allows SQS service
+    module.sqs.queue_policy_id,        # Explicitly wait for queue policy to be created
+    null_resource.wait_for_sqs_policy, # Wait for policy to propagate
+    aws_kms_key_policy.raw_with_sqs    # Ensure KMS key policy allows SQS service
   ]
 }
 
 module "cloudtrail" {
-  source                     = "../../modules/cloudtrail"
-  trail_name                 = "${local.name_prefix}-org-trail"
-  s3_bucket_name             = module.s3.audit_bucket_name
-  cloudwatch_log_group_name  = "/aws/claim/${local.name_prefix}/cloudtrail"
-  sns_topic_name             = "${local.name_prefix}-alerts"
-  tags                       = local.tags
+  source                    = "../../modules/cloudtrail"
+  trail_name                = "${local.name_prefix}-org-trail"
+  s3_bucket_name            = module.s3.audit_bucket_name
+  cloudwatch_log_group_name = "/aws/claim/${local.name_prefix}/cloudtrail"
+  sns_topic_name            = "${local.name_prefix}-alerts"
+  tags                      = local.tags
 }
 
 module "iam" {
-  source                     = "../../modules/iam"
-  raw_bucket_arn             = module.s3.raw_bucket_arn
-  lake_bucket_arn            = module.s3.lake_bucket_arn
-  kms_key_arns               = module.kms.key_arns
+  source             = "../../modules/iam"
+  raw_bucket_arn     = module.s3.raw_bucket_arn
+  lake_bucket_arn    = module.s3.lake_bucket_arn
+  archive_bucket_arn = module.s3.archive_bucket_arn
+  kms_key_arns       = module.kms.key_arns
   glue_catalog_arns = {
     raw_db    = module.glue_catalog.database_arns.raw
     silver_db = module.glue_catalog.database_arns.silver
@@ -150,8 +155,8 @@ module "iam" {
   ingestion_trusted_principals = var.ingestion_trusted_principals
   etl_trusted_principals       = var.etl_trusted_principals
   analyst_trusted_principals   = var.analyst_trusted_principals
-  redshift_cluster_identifier   = var.redshift_cluster_identifier
-  redshift_namespace_arn        = var.redshift_namespace_arn
+  redshift_cluster_identifier  = var.redshift_cluster_identifier
+  redshift_namespace_arn       = var.redshift_namespace_arn
   tags                         = local.tags
 }
 
@@ -166,7 +171,7 @@ resource "aws_kms_grant" "etl_keys" {
   for_each          = module.kms.key_arns
   key_id            = each.value
   grantee_principal = module.iam.role_arns.etl
-  operations         = ["Decrypt", "Encrypt", "ReEncryptFrom", "ReEncryptTo", "GenerateDataKey", "GenerateDataKeyWithoutPlaintext"]
+  operations        = ["Decrypt", "Encrypt", "ReEncryptFrom", "ReEncryptTo", "GenerateDataKey", "GenerateDataKeyWithoutPlaintext"]
 }
 
 resource "aws_kms_grant" "analyst_lake" {
@@ -202,7 +207,7 @@ data "aws_iam_policy_document" "raw_key_with_sqs" {
       "kms:GenerateDataKey*"
     ]
     resources = ["*"]
-    
+
     condition {
       test     = "StringEquals"
       variable = "kms:EncryptionContext:aws:sqs:arn"
@@ -228,7 +233,7 @@ data "aws_iam_policy_document" "raw_key_with_sqs" {
       "kms:GenerateDataKey*"
     ]
     resources = ["*"]
-    
+
     condition {
       test     = "StringEquals"
       variable = "kms:EncryptionContext:aws:sqs:arn"
@@ -26,21 +26,28 @@ output "vpc_endpoint_ids" {
 output "s3_bucket_names" {
   description = "S3 bucket names"
   value = {
-    raw    = module.s3.raw_bucket_name
-    lake   = module.s3.lake_bucket_name
-    audit  = module.s3.audit_bucket_name
+    raw     = module.s3.raw_bucket_name
+    lake    = module.s3.lake_bucket_name
+    audit   = module.s3.audit_bucket_name
+    archive = module.s3.archive_bucket_name
   }
 }
 
 output "s3_bucket_arns" {
   description = "S3 bucket ARNs"
   value = {
-    raw   = module.s3.raw_bucket_arn
-    lake  = module.s3.lake_bucket_arn
-    audit = module.s3.audit_bucket_arn
+    raw     = module.s3.raw_bucket_arn
+    lake    = module.s3.lake_bucket_arn
+    audit   = module.s3.audit_bucket_arn
+    archive = module.s3.archive_bucket_arn
   }
 }
 
+output "archive_retention_days" {
+  description = "Object Lock retention, in days, of archived X12 originals"
+  value       = var.archive_retention_days
+}
+
 output "kms_key_arns" {
   description = "KMS key ARNs"
   value       = module.kms.key_arns
@@ -96,3 +103,7 @@ output "dynamodb_table_name" {
   value       = module.dynamodb.table_name
 }
 
+output "dynamodb_index_names" {
+  description = "Global secondary indexes on the DynamoDB file metadata table"
+  value       = module.dynamodb.global_secondary_index_names
+}
@@ -80,3 +80,14 @@ variable "additional_tags" {
   default     = {}
 }
 
+variable "archive_retention_days" {
+  description = "Object Lock retention, in days, for archived X12 originals. Kept short in dev because COMPLIANCE locks cannot be lifted early."
+  type        = number
+  default     = 1
+}
+
+variable "archive_retention_admin_arns" {
+  description = "Principals besides role/Admin* allowed to change the archive bucket's Object Lock configuration; include the role CI deploys with."
+  type        = list(string)
+  default     = []
+}
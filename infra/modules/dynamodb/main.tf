@@ -1,3 +1,9 @@
+locals {
+  index_key_attributes = distinct(flatten([
+    for gsi in values(var.global_secondary_indexes) : compact([gsi.hash_key, gsi.range_key])
+  ]))
+}
+
 resource "aws_dynamodb_table" "file_metadata" {
   name           = var.table_name
   billing_mode   = var.billing_mode
@@ -8,6 +14,28 @@ resource "aws_dynamodb_table" "file_metadata" {
     type = "S"
   }
 
+  # Key attributes of the secondary indexes (all stored as strings)
+  dynamic "attribute" {
+    for_each = local.index_key_attributes
+    content {
+      name = attribute.value
+      type = "S"
+    }
+  }
+
+  # Secondary access patterns: duplicate lookup by checksum, listing by
+  # file_type/source_system over ingest_time, and by status over updated_at
+  dynamic "global_secondary_index" {
+    for_each = var.global_secondary_indexes
+    content {
+      name               = global_secondary_index.key
+      hash_key           = global_secondary_index.value.hash_key
+      range_key          = global_secondary_index.value.range_key
+      projection_type    = global_secondary_index.value.projection_type
+      non_key_attributes = global_secondary_index.value.projection_type == "INCLUDE" ? global_secondary_index.value.non_key_attributes : null
+    }
+  }
+
   # Point-in-time recovery for data protection
   point_in_time_recovery {
     enabled = var.enable_point_in_time_recovery
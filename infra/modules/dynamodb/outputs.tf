@@ -13,3 +13,8 @@ output "table_id" {
   value       = aws_dynamodb_table.file_metadata.id
 }
 
+
+output "global_secondary_index_names" {
+  description = "Names of the table's global secondary indexes"
+  value       = keys(var.global_secondary_indexes)
+}
@@ -32,6 +32,42 @@ variable "ttl_attribute_name" {
   default     = "ttl"
 }
 
+variable "global_secondary_indexes" {
+  description = "Global secondary indexes keyed by index name. Key attributes are declared as strings."
+  type = map(object({
+    hash_key           = string
+    range_key          = optional(string)
+    projection_type    = string
+    non_key_attributes = optional(list(string), [])
+  }))
+  default = {
+    "checksum-index" = {
+      hash_key           = "checksum"
+      range_key          = "ingest_time"
+      projection_type    = "INCLUDE"
+      non_key_attributes = ["file_name", "source_system", "status", "duplicate_of", "checksum_mismatch"]
+    }
+    "file-type-index" = {
+      hash_key           = "file_type"
+      range_key          = "ingest_time"
+      projection_type    = "INCLUDE"
+      non_key_attributes = ["file_name", "source_system", "status", "updated_at", "bucket", "object_key", "version_id", "checksum", "record_count", "size_bytes"]
+    }
+    "source-system-index" = {
+      hash_key           = "source_system"
+      range_key          = "ingest_time"
+      projection_type    = "INCLUDE"
+      non_key_attributes = ["file_name", "file_type", "status", "updated_at", "bucket", "object_key", "version_id", "checksum", "record_count", "size_bytes"]
+    }
+    "status-index" = {
+      hash_key           = "status"
+      range_key          = "updated_at"
+      projection_type    = "INCLUDE"
+      non_key_attributes = ["file_name", "file_type", "source_system", "ingest_time", "bucket", "object_key", "version_id", "checksum", "record_count", "size_bytes", "duplicate_of"]
+    }
+  }
+}
+
 variable "tags" {
   description = "Common tags"
   type        = map(string)
@@ -15,6 +15,20 @@ locals {
         "${var.lake_bucket_arn}/*"
       ]
     },
+    {
+      # Archived X12 originals are write-once; no delete is granted.
+      actions = [
+        "s3:GetObject",
+        "s3:GetObjectVersion",
+        "s3:GetObjectRetention",
+        "s3:PutObject",
+        "s3:ListBucket"
+      ]
+      resources = [
+        var.archive_bucket_arn,
+        "${var.archive_bucket_arn}/*"
+      ]
+    },
     {
       actions = [
         "kms:Decrypt",
@@ -8,6 +8,11 @@ variable "lake_bucket_arn" {
   description = "ARN of the lake bucket."
 }
 
+variable "archive_bucket_arn" {
+  type        = string
+  description = "ARN of the WORM archive bucket for original X12 files."
+}
+
 variable "kms_key_arns" {
   type        = map(string)
   description = "Map of layer name to KMS key ARN."
@@ -35,6 +35,20 @@ resource "aws_s3_bucket" "audit" {
   })
 }
 
+# X12 originals are copied here before parsing. Object Lock can only be
+# enabled when the bucket is created.
+resource "aws_s3_bucket" "archive" {
+  bucket = var.archive_bucket_name
+
+  object_lock_enabled = true
+  force_destroy       = var.force_destroy
+
+  tags = merge(var.tags, {
+    Name = var.archive_bucket_name
+    Layer = "archive"
+  })
+}
+
 resource "aws_s3_bucket_versioning" "raw" {
   bucket = aws_s3_bucket.raw.id
   versioning_configuration {
@@ -59,6 +73,29 @@ resource "aws_s3_bucket_versioning" "audit" {
   }
 }
 
+resource "aws_s3_bucket_versioning" "archive" {
+  bucket = aws_s3_bucket.archive.id
+  versioning_configuration {
+    status     = "Enabled"
+    mfa_delete = "Disabled"
+  }
+}
+
+# Every archived version is write-once: in COMPLIANCE mode no principal,
+# including root, can delete or overwrite it before the retention expires.
+resource "aws_s3_bucket_object_lock_configuration" "archive" {
+  bucket = aws_s3_bucket.archive.id
+
+  rule {
+    default_retention {
+      mode = var.archive_retention_mode
+      days = var.archive_retention_days
+    }
+  }
+
+  depends_on = [aws_s3_bucket_versioning.archive]
+}
+
 resource "aws_s3_bucket_server_side_encryption_configuration" "raw" {
   bucket = aws_s3_bucket.raw.id
   rule {
@@ -89,6 +126,16 @@ resource "aws_s3_bucket_server_side_encryption_configuration" "audit" {
   }
 }
 
+resource "aws_s3_bucket_server_side_encryption_configuration" "archive" {
+  bucket = aws_s3_bucket.archive.id
+  rule {
+    apply_server_side_encryption_by_default {
+      kms_master_key_id = var.archive_kms_key_arn
+      sse_algorithm     = "aws:kms"
+    }
+  }
+}
+
 resource "aws_s3_bucket_logging" "raw" {
   bucket        = aws_s3_bucket.raw.id
   target_bucket = aws_s3_bucket.audit.id
@@ -107,6 +154,12 @@ resource "aws_s3_bucket_logging" "audit" {
   target_prefix = "${local.log_prefix}audit/"
 }
 
+resource "aws_s3_bucket_logging" "archive" {
+  bucket        = aws_s3_bucket.archive.id
+  target_bucket = aws_s3_bucket.audit.id
+  target_prefix = "${local.log_prefix}archive/"
+}
+
 # Create separate policy documents for each bucket
 # Each bucket policy can only reference its own ARN
 
@@ -220,6 +273,92 @@ data "aws_iam_policy_document" "lake" {
   }
 }
 
+data "aws_iam_policy_document" "archive" {
+  statement {
+    sid    = "AllowClaimRoles"
+    effect = "Allow"
+
+    principals {
+      type        = "AWS"
+      identifiers = ["arn:aws:iam::${var.account_id}:root"]
+    }
+
+    actions = ["s3:*"]
+    resources = [
+      "${aws_s3_bucket.archive.arn}",
+      "${aws_s3_bucket.archive.arn}/*"
+    ]
+
+    condition {
+      test     = "Bool"
+      variable = "aws:SecureTransport"
+      values   = ["true"]
+    }
+
+    condition {
+      test     = "StringLike"
+      variable = "aws:PrincipalArn"
+      values = [
+        "arn:aws:iam::${var.account_id}:role/role-claim-*",
+        "arn:aws:iam::${var.account_id}:role/Admin*"
+      ]
+    }
+  }
+
+  statement {
+    sid    = "RestrictToVpcEndpoints"
+    effect = "Allow"
+
+    principals {
+      type        = "AWS"
+      identifiers = ["*"]
+    }
+
+    actions = ["s3:*"]
+    resources = [
+      "${aws_s3_bucket.archive.arn}",
+      "${aws_s3_bucket.archive.arn}/*"
+    ]
+
+    condition {
+      test     = "StringEquals"
+      variable = "aws:sourceVpce"
+      values   = var.allowed_vpc_endpoint_ids
+    }
+  }
+
+  # Retention is part of the WORM guarantee; only administrators and the
+  # principals that deploy this module may change it.
+  statement {
+    sid    = "DenyRetentionChanges"
+    effect = "Deny"
+
+    principals {
+      type        = "AWS"
+      identifiers = ["*"]
+    }
+
+    actions = [
+      "s3:BypassGovernanceRetention",
+      "s3:PutBucketObjectLockConfiguration",
+      "s3:PutObjectRetention",
+    ]
+    resources = [
+      "${aws_s3_bucket.archive.arn}",
+      "${aws_s3_bucket.archive.arn}/*"
+    ]
+
+    condition {
+      test     = "StringNotLike"
+      variable = "aws:PrincipalArn"
+      values = concat(
+        ["arn:aws:iam::${var.account_id}:role/Admin*"],
+        var.archive_retention_admin_arns
+      )
+    }
+  }
+}
+
 data "aws_iam_policy_document" "audit" {
   # Allow CloudTrail to write logs
   statement {
@@ -291,6 +430,15 @@ resource "aws_s3_bucket_policy" "lake" {
   policy = data.aws_iam_policy_document.lake.json
 }
 
+resource "aws_s3_bucket_policy" "archive" {
+  bucket = aws_s3_bucket.archive.id
+  policy = data.aws_iam_policy_document.archive.json
+
+  # The policy denies PutBucketObjectLockConfiguration, so the lock must be
+  # configured first.
+  depends_on = [aws_s3_bucket_object_lock_configuration.archive]
+}
+
 resource "aws_s3_bucket_policy" "audit" {
   bucket = aws_s3_bucket.audit.id
   policy = data.aws_iam_policy_document.audit.json
@@ -13,6 +13,11 @@ output "audit_bucket_arn" {
   description = "ARN of audit bucket."
 }
 
+output "archive_bucket_arn" {
+  value       = aws_s3_bucket.archive.arn
+  description = "ARN of archive bucket."
+}
+
 output "raw_bucket_name" {
   value       = aws_s3_bucket.raw.bucket
   description = "Name of raw bucket."
@@ -28,6 +33,11 @@ output "audit_bucket_name" {
   description = "Name of audit bucket."
 }
 
+output "archive_bucket_name" {
+  value       = aws_s3_bucket.archive.bucket
+  description = "Name of archive bucket."
+}
+
 output "raw_bucket_id" {
   value       = aws_s3_bucket.raw.id
   description = "ID of raw bucket (for use in event notifications)."
@@ -13,6 +13,11 @@ variable "audit_bucket_name" {
   description = "Name for the audit/log bucket."
 }
 
+variable "archive_bucket_name" {
+  type        = string
+  description = "Name for the WORM archive of original X12 files."
+}
+
 variable "raw_kms_key_arn" {
   type        = string
   description = "KMS key ARN for the raw bucket."
@@ -28,6 +33,39 @@ variable "audit_kms_key_arn" {
   description = "KMS key ARN for the audit bucket."
 }
 
+variable "archive_kms_key_arn" {
+  type        = string
+  description = "KMS key ARN for the archive bucket."
+}
+
+variable "archive_retention_mode" {
+  type        = string
+  description = "Object Lock mode for archived originals. COMPLIANCE locks cannot be removed by any principal."
+  default     = "COMPLIANCE"
+
+  validation {
+    condition     = contains(["COMPLIANCE", "GOVERNANCE"], var.archive_retention_mode)
+    error_message = "archive_retention_mode must be COMPLIANCE or GOVERNANCE."
+  }
+}
+
+variable "archive_retention_days" {
+  type        = number
+  description = "Days each archived original is locked against deletion and overwrite."
+  default     = 2555
+
+  validation {
+    condition     = var.archive_retention_days >= 1
+    error_message = "archive_retention_days must be at least 1."
+  }
+}
+
+variable "archive_retention_admin_arns" {
+  type        = list(string)
+  description = "IAM principal ARNs (wildcards allowed) besides role/Admin* that may change the archive's Object Lock configuration, e.g. the Terraform deploy role."
+  default     = []
+}
+
 variable "allowed_vpc_endpoint_ids" {
   description = "List of VPC endpoint IDs permitted to access the buckets."
   type        = list(string)
@@ -0,0 +1,117 @@
+package ack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/x12"
+)
+
+// DefaultPrefix is where acknowledgments are written, keyed by file_id. It
+// must not be in the raw bucket, whose every new object is ingested.
+const DefaultPrefix = "outbound/ack/"
+
+// MetaFileID is the user metadata key naming the acknowledged file.
+const MetaFileID = "file-id"
+
+// Acknowledger writes the TA1/999 for an ingested X12 file.
+type Acknowledger struct {
+	Objects objectstore.Store
+	// Bucket and Prefix locate the outbound acknowledgments; an empty Prefix
+	// means DefaultPrefix.
+	Bucket string
+	Prefix string
+	// Counter draws the outbound control numbers from ControlSequence, and
+	// Metadata stores a file's first number before its acknowledgment is
+	// written, so a redelivered file reuses it rather than drawing again.
+	// Both are usually the file-metadata DynamoStore.
+	Counter  metadata.Counter
+	Metadata metadata.Store
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Key returns the object key of the acknowledgment for fileID.
+func (a *Acknowledger) Key(fileID string) string {
+	prefix := a.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return prefix + fileID + ".x12"
+}
+
+// Acknowledge reads the X12 object of rec, writes its acknowledgment and sets
+// the ack attributes on rec; the caller stores rec. diags, the parser
+// diagnostics of the file, become IK3/IK4 notes and reject the transaction
+// sets they are in. A file without an interchange header, or whose
+// interchanges need no answer, gets a status but no object and draws no
+// control number.
+func (a *Acknowledger) Acknowledge(ctx context.Context, rec *metadata.FileRecord, diags []*x12.Diagnostic) (*Report, error) {
+	obj, err := a.Objects.Get(ctx, rec.Bucket, rec.Key, rec.VersionID)
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s for acknowledgment: %w", rec.Bucket, rec.Key, err)
+	}
+	defer obj.Body.Close()
+
+	rep, err := Analyze(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s for acknowledgment: %w", rec.Bucket, rec.Key, err)
+	}
+	rep.AddDiagnostics(diags)
+
+	n := Outbound(rep)
+	if n > 0 && rec.AckControlNumber == 0 {
+		last, err := a.Counter.Next(ctx, ControlSequence, n)
+		if err != nil {
+			return nil, fmt.Errorf("draw control number for %s: %w", rec.FileID, err)
+		}
+		rec.AckControlNumber = FirstControl(last, n)
+		if err := a.Metadata.Put(ctx, rec); err != nil {
+			return nil, fmt.Errorf("record control number of %s: %w", rec.FileID, err)
+		}
+	}
+
+	now := a.now()
+	rec.AckStatus = AckStatus(rep.Status())
+	rec.AckTime = metadata.FormatTime(now)
+	rec.AckLocation = ""
+	if n == 0 {
+		return rep, nil
+	}
+
+	body := Generate(rep, rec.AckControlNumber, now.UTC())
+	key := a.Key(rec.FileID)
+	_, err = a.Objects.Put(ctx, a.Bucket, key, bytes.NewReader(body), objectstore.PutOptions{
+		ContentType: "application/edi-x12",
+		Metadata:    map[string]string{MetaFileID: rec.FileID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("write acknowledgment s3://%s/%s: %w", a.Bucket, key, err)
+	}
+	rec.AckLocation = fmt.Sprintf("s3://%s/%s", a.Bucket, key)
+	return rep, nil
+}
+
+// AckStatus maps an acknowledgment code to the file-metadata ack_status.
+func AckStatus(s Status) metadata.AckStatus {
+	switch s {
+	case Accepted:
+		return metadata.AckAccepted
+	case AcceptedWithErrors:
+		return metadata.AckAcceptedWithErrors
+	case PartiallyAccepted:
+		return metadata.AckPartiallyAccepted
+	}
+	return metadata.AckRejected
+}
+
+func (a *Acknowledger) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
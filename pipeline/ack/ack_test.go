@@ -0,0 +1,106 @@
+package ack
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+)
+
+const (
+	rawBucket  = "claim-dev-raw"
+	lakeBucket = "claim-dev-lake"
+)
+
+func putRaw(t *testing.T, objects objectstore.Store, key, body string) *metadata.FileRecord {
+	t.Helper()
+	version, err := objects.Put(context.Background(), rawBucket, key, strings.NewReader(body), objectstore.PutOptions{})
+	require.NoError(t, err)
+	return &metadata.FileRecord{
+		FileID:    metadata.NewFileID(rawBucket, key, version),
+		Bucket:    rawBucket,
+		Key:       key,
+		VersionID: version,
+	}
+}
+
+func TestAcknowledgeWritesAckAndRecordsStatus(t *testing.T) {
+	objects := objectstore.NewDir(t.TempDir())
+	store := metadata.NewMemoryStore()
+	a := &Acknowledger{Objects: objects, Bucket: lakeBucket, Counter: store, Metadata: store, Now: func() time.Time { return ackTime }}
+
+	data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", "837p.x12"))
+	require.NoError(t, err)
+	rec := putRaw(t, objects, "raw/837/source=clearinghouse/claims.x12", string(data))
+
+	rep, err := a.Acknowledge(context.Background(), rec, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Accepted, rep.Status())
+	assert.Equal(t, metadata.AckAccepted, rec.AckStatus)
+	assert.Equal(t, "2025-11-22T08:30:00.000Z", rec.AckTime)
+	key := "outbound/ack/" + rec.FileID + ".x12"
+	assert.Equal(t, "s3://"+lakeBucket+"/"+key, rec.AckLocation)
+
+	obj, err := objects.Get(context.Background(), lakeBucket, key, "")
+	require.NoError(t, err)
+	defer obj.Body.Close()
+	assert.Equal(t, rec.FileID, obj.Metadata[MetaFileID])
+	body, err := io.ReadAll(obj.Body)
+	require.NoError(t, err)
+	assert.Equal(t, string(Generate(rep, 1, ackTime)), string(body))
+	requireValid(t, body)
+
+	// The number is stored before the acknowledgment is written, and an
+	// acknowledgment written again reuses it.
+	assert.Equal(t, 1, rec.AckControlNumber)
+	stored, err := store.Get(context.Background(), rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.AckControlNumber)
+	_, err = a.Acknowledge(context.Background(), rec, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rec.AckControlNumber)
+
+	other := putRaw(t, objects, "raw/837/source=clearinghouse/more.x12", string(data))
+	_, err = a.Acknowledge(context.Background(), other, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, other.AckControlNumber, "Files never share a control number")
+}
+
+func TestAcknowledgeRejectedFile(t *testing.T) {
+	objects := objectstore.NewDir(t.TempDir())
+	store := metadata.NewMemoryStore()
+	a := &Acknowledger{Objects: objects, Bucket: lakeBucket, Prefix: "acks/", Counter: store, Metadata: store, Now: func() time.Time { return ackTime }}
+
+	input := interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"})
+	rec := putRaw(t, objects, "raw/837/bad.x12", strings.Replace(input, "SE*3*", "SE*4*", 1))
+	_, err := a.Acknowledge(context.Background(), rec, nil)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.AckRejected, rec.AckStatus)
+	assert.Equal(t, "s3://"+lakeBucket+"/acks/"+rec.FileID+".x12", rec.AckLocation)
+
+	// Nothing to address an acknowledgment to.
+	rec = putRaw(t, objects, "raw/837/garbage.x12", "not x12")
+	rep, err := a.Acknowledge(context.Background(), rec, nil)
+	require.NoError(t, err)
+	assert.Error(t, rep.Err)
+	assert.Equal(t, metadata.AckRejected, rec.AckStatus)
+	assert.Empty(t, rec.AckLocation)
+	assert.Zero(t, rec.AckControlNumber, "No number is drawn for nothing to send")
+	_, err = objects.Head(context.Background(), lakeBucket, "acks/"+rec.FileID+".x12", "")
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+}
+
+func TestAcknowledgeMissingObject(t *testing.T) {
+	a := &Acknowledger{Objects: objectstore.NewDir(t.TempDir()), Bucket: lakeBucket}
+	_, err := a.Acknowledge(context.Background(), &metadata.FileRecord{FileID: "f", Bucket: rawBucket, Key: "raw/837/gone.x12"}, nil)
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+}
@@ -0,0 +1,202 @@
+package ack
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Version999 is the implementation convention of the 999 (GS08, ST03).
+const Version999 = "005010X231A1"
+
+// maxControl is the largest 9-digit interchange control number.
+const maxControl = 999999999
+
+// ControlSequence is the metadata.Counter sequence that interchange control
+// numbers are drawn from.
+const ControlSequence = "ack-interchange"
+
+// FirstControl returns the first of the n control numbers ending at last, a
+// number drawn from ControlSequence, wrapped into 1..999999999.
+func FirstControl(last int64, n int) int {
+	return int((last-int64(n))%maxControl) + 1
+}
+
+// Outbound returns how many outbound interchanges Generate writes for rep,
+// and so how many control numbers it uses.
+func Outbound(rep *Report) int {
+	n := 0
+	for _, ic := range rep.Interchanges {
+		if ta1, groups := answers(ic); ta1 || groups {
+			n++
+		}
+	}
+	return n
+}
+
+// answers reports whether ic is answered by a TA1 and by a 999 group.
+func answers(ic *Interchange) (ta1, groups bool) {
+	status := ic.Status()
+	return ic.Header.AckRequested || status != Accepted, status != Rejected && len(ic.Groups) > 0
+}
+
+// Generate renders the acknowledgments for rep. Each inbound interchange is
+// answered by one outbound interchange, from its receiver back to its sender,
+// using its delimiters. The outbound interchange holds:
+//
+//   - a TA1, when the sender asked for one (ISA14 = 1) or the interchange
+//     has errors
+//   - unless the TA1 rejects the interchange, a functional group with one
+//     999 per inbound group
+//
+// Interchanges that need neither are omitted, so the result may be empty.
+// control is the ISA13 and GS06 of the first outbound interchange; later ones
+// count up from it.
+func Generate(rep *Report, control int, now time.Time) []byte {
+	var b bytes.Buffer
+	n := 0
+	for _, ic := range rep.Interchanges {
+		ta1, groups := answers(ic)
+		if !ta1 && !groups {
+			continue
+		}
+		ctrl := (control-1+n)%maxControl + 1
+		n++
+
+		w := &segWriter{b: &b, d: outboundDelimiters(ic.Header.Delimiters)}
+		w.isa(ic.Header, ctrl, now)
+		if ta1 {
+			w.seg("TA1", ic.Header.ControlNumber, ic.Header.Date, ic.Header.Time, string(ic.Status()), ic.Note())
+		}
+		ngroups := 0
+		if groups {
+			ngroups = 1
+			sender, receiver := ic.Groups[0].Header.ReceiverCode, ic.Groups[0].Header.SenderCode
+			w.seg("GS", "FA", sender, receiver, now.Format("20060102"), now.Format("1504"), strconv.Itoa(ctrl), "X", Version999)
+			for i, g := range ic.Groups {
+				w.implementationAck(g, fmt.Sprintf("%04d", i+1))
+			}
+			w.seg("GE", strconv.Itoa(len(ic.Groups)), strconv.Itoa(ctrl))
+		}
+		w.seg("IEA", strconv.Itoa(ngroups), fmt.Sprintf("%09d", ctrl))
+	}
+	return b.Bytes()
+}
+
+// segWriter writes segments, one per line, and counts them for SE01.
+// Terminators that are themselves line breaks get no extra one, which strict
+// translators would read as an empty segment.
+type segWriter struct {
+	b     *bytes.Buffer
+	d     x12.Delimiters
+	count int
+}
+
+func (w *segWriter) seg(id string, elems ...string) {
+	// Trailing empty elements are omitted.
+	for len(elems) > 0 && elems[len(elems)-1] == "" {
+		elems = elems[:len(elems)-1]
+	}
+	w.b.WriteString(id)
+	for _, e := range elems {
+		w.b.WriteByte(w.d.Element)
+		w.b.WriteString(e)
+	}
+	w.end()
+	w.count++
+}
+
+// end terminates the segment.
+func (w *segWriter) end() {
+	w.b.WriteByte(w.d.Segment)
+	if w.d.Segment != '\n' && w.d.Segment != '\r' {
+		w.b.WriteByte('\n')
+	}
+}
+
+// isa writes the fixed-width ISA header, swapping sender and receiver.
+func (w *segWriter) isa(in x12.Interchange, ctrl int, now time.Time) {
+	usage := in.Usage
+	if usage == "" {
+		usage = "P"
+	}
+	e := string([]byte{w.d.Element})
+	fields := []string{
+		"ISA", "00", strings.Repeat(" ", 10), "00", strings.Repeat(" ", 10),
+		in.ReceiverQualifier, pad(in.ReceiverID, 15), in.SenderQualifier, pad(in.SenderID, 15),
+		now.Format("060102"), now.Format("1504"), string([]byte{w.d.Repetition}), "00501",
+		fmt.Sprintf("%09d", ctrl), "0", usage, string([]byte{w.d.SubElement}),
+	}
+	w.b.WriteString(strings.Join(fields, e))
+	w.end()
+}
+
+// implementationAck writes one 999 for group g.
+func (w *segWriter) implementationAck(g *Group, control string) {
+	w.count = 0
+	w.seg("ST", "999", control, Version999)
+	w.seg("AK1", g.Header.FunctionalID, g.Header.ControlNumber, g.Header.Version)
+	for _, t := range g.Transactions {
+		w.seg("AK2", t.Header.SetID, t.Header.ControlNumber, t.Header.ImplementationRef)
+		for _, s := range t.Segments {
+			w.seg("IK3", w.clean(s.SegmentID), strconv.Itoa(s.Position), s.Loop, s.Code)
+			for _, e := range s.Elements {
+				w.seg("IK4", w.elementPosition(e), e.Reference, e.Code, w.clean(e.Value))
+			}
+		}
+		w.seg("IK5", append([]string{string(t.Status())}, t.Codes()...)...)
+	}
+	n := strconv.Itoa(len(g.Transactions))
+	w.seg("AK9", append([]string{string(g.Status()), n, n, strconv.Itoa(g.Accepted())}, g.Codes()...)...)
+	w.seg("SE", strconv.Itoa(w.count+1), control)
+}
+
+// elementPosition renders IK401: position[:component[:repeat]].
+func (w *segWriter) elementPosition(e ElementError) string {
+	parts := []string{strconv.Itoa(e.Position)}
+	if e.Component > 0 || e.Repeat > 0 {
+		parts = append(parts, strconv.Itoa(e.Component))
+	}
+	if e.Repeat > 0 {
+		parts = append(parts, strconv.Itoa(e.Repeat))
+	}
+	return strings.Join(parts, string([]byte{w.d.SubElement}))
+}
+
+// clean drops delimiter characters from a value copied from the inbound file
+// (IK404) so it cannot break the acknowledgment's syntax.
+func (w *segWriter) clean(v string) string {
+	b := make([]byte, 0, len(v))
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case w.d.Element, w.d.SubElement, w.d.Repetition, w.d.Segment:
+			continue
+		}
+		b = append(b, v[i])
+	}
+	return string(b)
+}
+
+// outboundDelimiters returns the inbound delimiters, adding a repetition
+// separator for interchanges before 00501.
+func outboundDelimiters(d x12.Delimiters) x12.Delimiters {
+	if d.Repetition != 0 {
+		return d
+	}
+	d.Repetition = x12.DefaultDelimiters.Repetition
+	if d.Repetition == d.Element || d.Repetition == d.SubElement || d.Repetition == d.Segment {
+		return x12.DefaultDelimiters
+	}
+	return d
+}
+
+func pad(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
@@ -0,0 +1,177 @@
+package ack
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/x12"
+)
+
+var ackTime = time.Date(2025, 11, 22, 8, 30, 0, 0, time.UTC)
+
+// segments returns the generated segments without terminators.
+func segments(out []byte) []string {
+	var segs []string
+	for _, s := range strings.Split(strings.TrimSpace(string(out)), "~\n") {
+		segs = append(segs, strings.TrimSuffix(s, "~"))
+	}
+	return segs
+}
+
+// requireValid checks the generated acknowledgment's own envelopes.
+func requireValid(t *testing.T, out []byte) {
+	t.Helper()
+	errs, err := x12.Validate(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.Empty(t, errs, "acknowledgment envelopes:\n%s", out)
+}
+
+func TestGeneratePartialAcceptance(t *testing.T) {
+	input := interchange("000000001",
+		[]string{"BHT*0019*00*1*20251121*1000*CH"},
+		[]string{"BHT*0019*00*2*20251121*1000*CH", "clm*bad"},
+	)
+	out := Generate(analyze(t, input), 42, ackTime)
+	requireValid(t, out)
+
+	assert.Equal(t, []string{
+		"ISA*00*          *00*          *ZZ*CLAIMSYS       *ZZ*SUBMITTER      *251122*0830*^*00501*000000042*0*T*:",
+		"TA1*000000001*251121*1000*A*000",
+		"GS*FA*CLAIMSYS*SUBMITTER*20251122*0830*42*X*005010X231A1",
+		"ST*999*0001*005010X231A1",
+		"AK1*HC*7*005010X222A1",
+		"AK2*837*0001*005010X222A1",
+		"IK5*A",
+		"AK2*837*0002*005010X222A1",
+		"IK3*clm*3**1",
+		"IK5*R*5",
+		"AK9*P*2*2*1",
+		"SE*9*0001",
+		"GE*1*42",
+		"IEA*1*000000042",
+	}, segments(out), "TA1 is included because the sender asked for one (ISA14 = 1)")
+}
+
+func TestGenerateRejectedInterchangeHasOnlyTA1(t *testing.T) {
+	input := interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"})
+	input = input[:strings.Index(input, "GE*")]
+	out := Generate(analyze(t, input), 7, ackTime)
+	requireValid(t, out)
+
+	segs := segments(out)
+	require.Len(t, segs, 3)
+	assert.Equal(t, "TA1*000000001*251121*1000*R*023", segs[1], "IEA missing is a premature end")
+	assert.Equal(t, "IEA*0*000000007", segs[2])
+}
+
+func TestGenerateGroupErrors(t *testing.T) {
+	input := interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"})
+	input = strings.Replace(input, "GE*1*7", "GE*2*8", 1)
+	out := Generate(analyze(t, input), 7, ackTime)
+	requireValid(t, out)
+	assert.Contains(t, segments(out), "AK9*R*1*1*1*5*4", "AK905-AK906 list both group errors")
+}
+
+func TestGenerateFixtures(t *testing.T) {
+	for _, name := range []string{"834.x12", "835.x12", "835_delims.x12", "837p.x12", "837i.x12"} {
+		data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", name))
+		require.NoError(t, err)
+		rep := analyze(t, string(data))
+		out := Generate(rep, 100, ackTime)
+		requireValid(t, out)
+
+		// The fixtures do not request a TA1, so only the 999 is sent.
+		xr := x12.NewReader(bytes.NewReader(out))
+		var ids []string
+		for {
+			seg, err := xr.Next()
+			if err != nil {
+				break
+			}
+			ids = append(ids, seg.ID)
+			if seg.ID == "AK1" {
+				assert.Equal(t, rep.Interchanges[0].Groups[0].Header.ControlNumber, seg.Element(2), name)
+			}
+		}
+		assert.Equal(t, []string{"ISA", "GS", "ST", "AK1", "AK2", "IK5", "AK9", "SE", "GE", "IEA"}, ids, name)
+		assert.Equal(t, rep.Interchanges[0].Header.Delimiters, xr.Delimiters(), "%s: acknowledgment uses the sender's delimiters", name)
+	}
+}
+
+func TestGenerateMultipleInterchanges(t *testing.T) {
+	first := interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"})
+	second := interchange("000000002", []string{"BHT*0019*00*2*20251121*1000*CH"})
+	out := Generate(analyze(t, first+second), maxControl, ackTime)
+	requireValid(t, out)
+
+	var controls []string
+	for _, s := range segments(out) {
+		if strings.HasPrefix(s, "IEA*") {
+			controls = append(controls, s)
+		}
+	}
+	assert.Equal(t, []string{"IEA*1*999999999", "IEA*1*000000001"}, controls, "Control numbers count up and wrap")
+}
+
+func TestGenerateNewlineTerminator(t *testing.T) {
+	input := strings.ReplaceAll(interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"}), "~\n", "\n")
+	out := Generate(analyze(t, input), 1, ackTime)
+	requireValid(t, out)
+
+	assert.NotContains(t, string(out), "\n\n", "A newline terminator gets no second line break")
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	assert.Equal(t, "TA1*000000001*251121*1000*A*000", lines[1])
+	assert.Equal(t, "IEA*1*000000001", lines[len(lines)-1])
+}
+
+func TestGenerateElementErrors(t *testing.T) {
+	rep := analyze(t, interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"}))
+	tx := rep.Interchanges[0].Groups[0].Transactions[0]
+	tx.Segments = append(tx.Segments, SegmentError{
+		SegmentID: "BHT", Position: 2, Loop: "",
+		Elements: []ElementError{
+			{Position: 4, Reference: "373", Code: "8", Value: "2025*11~21"},
+			{Position: 1, Component: 2, Repeat: 1, Code: "1"},
+		},
+	})
+	out := Generate(rep, 1, ackTime)
+	requireValid(t, out)
+
+	segs := segments(out)
+	assert.Contains(t, segs, "IK3*BHT*2")
+	assert.Contains(t, segs, "IK4*4*373*8*20251121", "Delimiters are removed from copied values")
+	assert.Contains(t, segs, "IK4*1:2:1**1")
+	assert.Contains(t, segs, "IK5*R*5")
+}
+
+func TestGenerateNothingToSend(t *testing.T) {
+	isa := strings.Replace(strings.Replace(isaTemplate, "%s", "000000001", 1), "%s", "0", 1)
+	rep := analyze(t, isa+"~IEA*0*000000001~")
+	require.Len(t, rep.Interchanges, 1)
+	assert.Empty(t, Generate(rep, 1, ackTime), "No TA1 requested and no groups to answer")
+	assert.Empty(t, Generate(&Report{}, 1, ackTime))
+}
+
+func TestFirstControl(t *testing.T) {
+	assert.Equal(t, 1, FirstControl(1, 1))
+	assert.Equal(t, 5, FirstControl(6, 2), "Reserving 5 and 6")
+	assert.Equal(t, maxControl, FirstControl(maxControl+1, 2), "Reserving 999999999 and, wrapped, 1")
+	assert.Equal(t, 1, FirstControl(maxControl+1, 1))
+}
+
+func TestOutbound(t *testing.T) {
+	first := interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"})
+	second := interchange("000000002", []string{"BHT*0019*00*2*20251121*1000*CH"})
+	assert.Equal(t, 2, Outbound(analyze(t, first+second)))
+
+	isa := strings.Replace(strings.Replace(isaTemplate, "%s", "000000001", 1), "%s", "0", 1)
+	assert.Equal(t, 1, Outbound(analyze(t, first+isa+"~IEA*0*000000001~")), "The empty interchange needs no answer")
+	assert.Zero(t, Outbound(&Report{}))
+}
@@ -0,0 +1,381 @@
+// Package ack answers inbound X12 files with TA1 interchange and 999
+// implementation acknowledgments, built from the envelope validation of the
+// x12 reader, and writes them to the outbound prefix.
+package ack
+
+import (
+	"errors"
+	"io"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Status is an acknowledgment code: TA104, IK501 and AK901 share these
+// values (P is AK901 only).
+type Status string
+
+const (
+	Accepted           Status = "A"
+	AcceptedWithErrors Status = "E"
+	PartiallyAccepted  Status = "P"
+	Rejected           Status = "R"
+)
+
+// IK304 segment syntax error codes.
+const (
+	IK3UnrecognizedSegment = x12.IK3UnrecognizedSegment
+	IK3UnexpectedSegment   = x12.IK3UnexpectedSegment
+	IK3MissingSegment      = x12.IK3MissingSegment
+)
+
+// ElementError is an IK4 data element note.
+type ElementError struct {
+	// Position is the element position in the segment (IK401-1); Component
+	// and Repeat are set for composite and repeating elements.
+	Position  int
+	Component int
+	Repeat    int
+	// Reference is the data element reference number (IK402), if known.
+	Reference string
+	// Code is IK403, e.g. 1 mandatory element missing, 7 invalid code value.
+	Code string
+	// Value is the offending data (IK404).
+	Value string
+}
+
+// SegmentError is an IK3 segment note with its element notes.
+type SegmentError struct {
+	SegmentID string
+	// Position is the segment's position in the transaction set, counting
+	// ST as 1 (x12.Position.TxIndex).
+	Position int
+	// Loop is the loop identifier (IK303), e.g. 2300.
+	Loop string
+	// Code is IK304; it may be empty when only Elements are reported.
+	Code     string
+	Elements []ElementError
+}
+
+// Transaction is the acknowledgment of one transaction set (AK2 loop).
+type Transaction struct {
+	Header x12.Transaction
+	// Errors are the envelope errors of the set (IK502 codes).
+	Errors []*x12.EnvelopeError
+	// Segments are reported as IK3/IK4. Parsers may append to it.
+	Segments []SegmentError
+}
+
+// Status is IK501: rejected when the set has envelope or segment errors.
+func (t *Transaction) Status() Status {
+	if len(t.Errors) > 0 || len(t.Segments) > 0 {
+		return Rejected
+	}
+	return Accepted
+}
+
+// Codes returns the IK502-IK506 codes, at most five.
+func (t *Transaction) Codes() []string {
+	var codes []string
+	for _, e := range t.Errors {
+		codes = appendCode(codes, e.Code)
+	}
+	if len(t.Segments) > 0 {
+		codes = appendCode(codes, x12.IK5SegmentsInError)
+	}
+	return codes
+}
+
+// Group is the acknowledgment of one functional group: one 999.
+type Group struct {
+	Header       x12.Group
+	Errors       []*x12.EnvelopeError
+	Transactions []*Transaction
+}
+
+// Accepted returns the number of accepted transaction sets (AK904).
+func (g *Group) Accepted() int {
+	n := 0
+	for _, t := range g.Transactions {
+		if s := t.Status(); s == Accepted || s == AcceptedWithErrors {
+			n++
+		}
+	}
+	return n
+}
+
+// Status is AK901. A group with envelope errors is rejected as a whole.
+func (g *Group) Status() Status {
+	accepted := g.Accepted()
+	switch {
+	case len(g.Errors) > 0, accepted == 0 && len(g.Transactions) > 0:
+		return Rejected
+	case accepted < len(g.Transactions):
+		return PartiallyAccepted
+	}
+	return Accepted
+}
+
+// Codes returns the AK905-AK909 codes, at most five.
+func (g *Group) Codes() []string {
+	var codes []string
+	for _, e := range g.Errors {
+		codes = appendCode(codes, e.Code)
+	}
+	return codes
+}
+
+// Interchange is the acknowledgment of one ISA/IEA: a TA1 plus a 999 per
+// group.
+type Interchange struct {
+	Header x12.Interchange
+	Errors []*x12.EnvelopeError
+	Groups []*Group
+}
+
+// Status is TA104. Count and trailer control number mismatches leave the
+// content usable (E); any other interchange error rejects it.
+func (i *Interchange) Status() Status {
+	s := Accepted
+	for _, e := range i.Errors {
+		switch e.Code {
+		case x12.TA1ControlNumberMismatch, x12.TA1GroupCountMismatch:
+			s = AcceptedWithErrors
+		default:
+			return Rejected
+		}
+	}
+	return s
+}
+
+// Note is TA105: the code of the first interchange error, or 000.
+func (i *Interchange) Note() string {
+	if len(i.Errors) == 0 {
+		return "000"
+	}
+	return i.Errors[0].Code
+}
+
+// Report is the acknowledgment of a whole file.
+type Report struct {
+	Interchanges []*Interchange
+	// Err is the tokenizer error that stopped reading, if any. When it
+	// occurred before the first ISA there is nothing to acknowledge.
+	Err error
+}
+
+// Status summarizes the file over all transaction sets: accepted when all
+// are accepted, rejected when none is, partially accepted otherwise.
+// Interchange or group errors reject every set they enclose.
+func (r *Report) Status() Status {
+	var accepted, rejected int
+	withErrors := false
+	for _, ic := range r.Interchanges {
+		is := ic.Status()
+		if is == Rejected {
+			rejected++
+			continue
+		}
+		withErrors = withErrors || is == AcceptedWithErrors
+		for _, g := range ic.Groups {
+			if len(g.Errors) > 0 {
+				rejected += max(1, len(g.Transactions))
+				continue
+			}
+			for _, t := range g.Transactions {
+				if t.Status() == Rejected {
+					rejected++
+				} else {
+					accepted++
+				}
+			}
+		}
+	}
+	switch {
+	case accepted == 0 && rejected == 0:
+		if len(r.Interchanges) == 0 {
+			return Rejected
+		}
+		if withErrors {
+			return AcceptedWithErrors
+		}
+		return Accepted
+	case accepted == 0:
+		return Rejected
+	case rejected > 0:
+		return PartiallyAccepted
+	case withErrors:
+		return AcceptedWithErrors
+	}
+	return Accepted
+}
+
+// Analyze reads an X12 stream to the end and sorts its envelope errors into
+// the interchange, group and transaction set they belong to. Only I/O errors
+// are returned; input that cannot be tokenized is recorded in Report.Err and,
+// after an ISA, rejects the interchange with TA105 024.
+func Analyze(r io.Reader) (*Report, error) {
+	xr := x12.NewReader(r)
+	rep := &Report{}
+
+	var (
+		ic   *Interchange
+		g    *Group
+		tx   *Transaction
+		seen int
+	)
+	attach := func(e *x12.EnvelopeError) {
+		switch {
+		case e.Level == x12.LevelTransaction && tx != nil && e.Code == x12.IK5SegmentsInError:
+			// The reader reports invalid segment ids; they become IK3 notes,
+			// and Transaction.Codes adds the IK502 code they imply.
+			tx.Segments = append(tx.Segments, SegmentError{SegmentID: e.SegmentID, Position: e.Pos.TxIndex, Code: IK3UnrecognizedSegment})
+		case e.Level == x12.LevelTransaction && tx != nil:
+			tx.Errors = append(tx.Errors, e)
+		case e.Level != x12.LevelInterchange && g != nil:
+			g.Errors = append(g.Errors, e)
+		case ic != nil:
+			ic.Errors = append(ic.Errors, e)
+		}
+	}
+
+	for {
+		seg, err := xr.Next()
+		errs := xr.Errors()[seen:]
+		seen = len(xr.Errors())
+		if err != nil {
+			for _, e := range errs {
+				attach(e)
+			}
+			var syntax *x12.SyntaxError
+			switch {
+			case err == io.EOF:
+				return rep, nil
+			case errors.As(err, &syntax), errors.Is(err, x12.ErrNoInterchange):
+				rep.Err = err
+				if ic != nil {
+					ic.Errors = append(ic.Errors, &x12.EnvelopeError{
+						Level:         x12.LevelInterchange,
+						Code:          x12.TA1InvalidContent,
+						ControlNumber: ic.Header.ControlNumber,
+						Msg:           err.Error(),
+					})
+				}
+				return rep, nil
+			}
+			return nil, err
+		}
+
+		// A new header first closes the envelopes still open before it; those
+		// errors belong to the previous interchange, group or set.
+		var later []*x12.EnvelopeError
+		for _, e := range errs {
+			if closesPrevious(seg.ID, e) {
+				attach(e)
+			} else {
+				later = append(later, e)
+			}
+		}
+
+		switch seg.ID {
+		case "ISA":
+			ic = &Interchange{Header: *xr.Interchange()}
+			rep.Interchanges = append(rep.Interchanges, ic)
+			g, tx = nil, nil
+		case "GS":
+			g = &Group{Header: *xr.Group()}
+			if ic != nil {
+				ic.Groups = append(ic.Groups, g)
+			}
+			tx = nil
+		case "ST":
+			tx = &Transaction{Header: *xr.Transaction()}
+			if g != nil {
+				g.Transactions = append(g.Transactions, tx)
+			}
+		}
+		for _, e := range later {
+			attach(e)
+		}
+	}
+}
+
+// AddDiagnostics reports parser diagnostics as IK3/IK4 notes of the
+// transaction sets they were found in. Element notes on the same segment
+// share one IK3. Diagnostics outside any transaction set are dropped; the
+// envelope errors already cover them.
+func (r *Report) AddDiagnostics(diags []*x12.Diagnostic) {
+	for _, d := range diags {
+		tx := r.transactionAt(d.Index)
+		if tx == nil || d.Position == 0 {
+			continue
+		}
+		var seg *SegmentError
+		if d.Element > 0 {
+			for i := range tx.Segments {
+				s := &tx.Segments[i]
+				if s.Position == d.Position && s.SegmentID == d.SegmentID && s.Code == x12.IK3DataElementErrors {
+					seg = s
+					break
+				}
+			}
+		}
+		if seg == nil {
+			tx.Segments = append(tx.Segments, SegmentError{SegmentID: d.SegmentID, Position: d.Position, Loop: d.LoopID(), Code: d.SegmentCode})
+			seg = &tx.Segments[len(tx.Segments)-1]
+		}
+		if d.Element > 0 {
+			seg.Elements = append(seg.Elements, ElementError{
+				Position:  d.Element,
+				Component: d.Component,
+				Repeat:    d.Repeat,
+				Code:      d.ElementCode,
+				Value:     d.Value,
+			})
+		}
+	}
+}
+
+// transactionAt returns the transaction set enclosing the segment at stream
+// index, or nil.
+func (r *Report) transactionAt(index int) *Transaction {
+	var found *Transaction
+	for _, ic := range r.Interchanges {
+		for _, g := range ic.Groups {
+			for _, t := range g.Transactions {
+				if t.Header.Pos.Index > index {
+					return found
+				}
+				found = t
+			}
+		}
+	}
+	return found
+}
+
+func closesPrevious(segID string, e *x12.EnvelopeError) bool {
+	switch segID {
+	case "ISA", "GS", "ST":
+	default:
+		return false
+	}
+	switch {
+	case e.Level == x12.LevelTransaction && e.Code == x12.IK5TrailerMissing,
+		e.Level == x12.LevelGroup && e.Code == x12.AK9TrailerMissing,
+		e.Level == x12.LevelInterchange && e.Code == x12.TA1PrematureEnd:
+		return true
+	}
+	return false
+}
+
+// appendCode adds code unless present; acknowledgments carry at most five.
+func appendCode(codes []string, code string) []string {
+	for _, c := range codes {
+		if c == code {
+			return codes
+		}
+	}
+	if len(codes) == 5 {
+		return codes
+	}
+	return append(codes, code)
+}
@@ -0,0 +1,196 @@
+package ack
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/x12"
+	"claim-management-system/pipeline/x12/x837"
+)
+
+const isaTemplate = "ISA*00*          *00*          *ZZ*SUBMITTER      *ZZ*CLAIMSYS       *251121*1000*^*00501*%s*%s*T*:"
+
+// interchange wraps transaction bodies (segments between ST and SE, without
+// them) in ISA/GS/ST/SE/GE/IEA with correct counts and control numbers.
+func interchange(control string, sets ...[]string) string {
+	segs := []string{
+		strings.Replace(strings.Replace(isaTemplate, "%s", control, 1), "%s", "1", 1),
+		"GS*HC*SUBMITTER*CLAIMSYS*20251121*1000*7*X*005010X222A1",
+	}
+	for i, body := range sets {
+		ctrl := "000" + strconv.Itoa(i+1)
+		segs = append(segs, "ST*837*"+ctrl+"*005010X222A1")
+		segs = append(segs, body...)
+		segs = append(segs, "SE*"+strconv.Itoa(len(body)+2)+"*"+ctrl)
+	}
+	segs = append(segs, "GE*"+strconv.Itoa(len(sets))+"*7", "IEA*1*"+control)
+	return strings.Join(segs, "~\n") + "~\n"
+}
+
+func analyze(t *testing.T, input string) *Report {
+	t.Helper()
+	rep, err := Analyze(strings.NewReader(input))
+	require.NoError(t, err)
+	return rep
+}
+
+func TestAnalyzeFixturesAccepted(t *testing.T) {
+	for _, name := range []string{"834.x12", "835.x12", "835_delims.x12", "837p.x12", "837i.x12"} {
+		f, err := os.Open(filepath.Join("..", "x12", "testdata", name))
+		require.NoError(t, err)
+		rep, err := Analyze(f)
+		f.Close()
+		require.NoError(t, err, name)
+
+		require.Len(t, rep.Interchanges, 1, name)
+		ic := rep.Interchanges[0]
+		require.Len(t, ic.Groups, 1, name)
+		require.Len(t, ic.Groups[0].Transactions, 1, name)
+		assert.Equal(t, Accepted, ic.Status(), name)
+		assert.Equal(t, "000", ic.Note(), name)
+		assert.Equal(t, Accepted, ic.Groups[0].Status(), name)
+		assert.Equal(t, Accepted, rep.Status(), name)
+	}
+}
+
+func TestAnalyzeTransactionErrors(t *testing.T) {
+	input := interchange("000000001",
+		[]string{"BHT*0019*00*1*20251121*1000*CH"},
+		[]string{"BHT*0019*00*2*20251121*1000*CH", "clm*bad"},
+	)
+	// Break the first set's count.
+	input = strings.Replace(input, "SE*3*0001", "SE*9*0001", 1)
+	rep := analyze(t, input)
+
+	g := rep.Interchanges[0].Groups[0]
+	require.Len(t, g.Transactions, 2)
+
+	first := g.Transactions[0]
+	assert.Equal(t, Rejected, first.Status())
+	assert.Equal(t, []string{x12.IK5SegmentCountMismatch}, first.Codes())
+
+	second := g.Transactions[1]
+	assert.Equal(t, Rejected, second.Status())
+	assert.Empty(t, second.Errors, "Invalid segment ids become IK3 notes")
+	assert.Equal(t, []SegmentError{{SegmentID: "clm", Position: 3, Code: IK3UnrecognizedSegment}}, second.Segments)
+	assert.Equal(t, []string{x12.IK5SegmentsInError}, second.Codes())
+
+	assert.Equal(t, Rejected, g.Status())
+	assert.Equal(t, Rejected, rep.Status())
+}
+
+func TestAnalyzePartialAcceptance(t *testing.T) {
+	input := interchange("000000001",
+		[]string{"BHT*0019*00*1*20251121*1000*CH"},
+		[]string{"BHT*0019*00*2*20251121*1000*CH"},
+	)
+	input = strings.Replace(input, "SE*3*0002", "SE*3*0009", 1)
+	rep := analyze(t, input)
+
+	g := rep.Interchanges[0].Groups[0]
+	assert.Equal(t, Accepted, g.Transactions[0].Status())
+	assert.Equal(t, []string{x12.IK5ControlNumberMismatch}, g.Transactions[1].Codes())
+	assert.Equal(t, 1, g.Accepted())
+	assert.Equal(t, PartiallyAccepted, g.Status())
+	assert.Equal(t, PartiallyAccepted, rep.Status())
+}
+
+func TestAnalyzeMissingTrailersBelongToTheirEnvelope(t *testing.T) {
+	// The first set has no SE; the error is reported at the next ST but
+	// belongs to set 0001, not 0002.
+	input := interchange("000000001",
+		[]string{"BHT*0019*00*1*20251121*1000*CH"},
+		[]string{"BHT*0019*00*2*20251121*1000*CH"},
+	)
+	input = strings.Replace(input, "SE*3*0001~\n", "", 1)
+	rep := analyze(t, input)
+
+	txs := rep.Interchanges[0].Groups[0].Transactions
+	require.Len(t, txs, 2)
+	assert.Equal(t, []string{x12.IK5TrailerMissing}, txs[0].Codes())
+	assert.Equal(t, Accepted, txs[1].Status())
+}
+
+func TestAnalyzeGroupAndInterchangeErrors(t *testing.T) {
+	input := interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"})
+	input = strings.Replace(input, "GE*1*7", "GE*1*8", 1)
+	input = strings.Replace(input, "IEA*1*", "IEA*2*", 1)
+	rep := analyze(t, input)
+
+	ic := rep.Interchanges[0]
+	assert.Equal(t, AcceptedWithErrors, ic.Status(), "Count mismatches leave the content usable")
+	assert.Equal(t, x12.TA1GroupCountMismatch, ic.Note())
+
+	g := ic.Groups[0]
+	assert.Equal(t, Rejected, g.Status())
+	assert.Equal(t, []string{x12.AK9ControlNumberMismatch}, g.Codes())
+	assert.Equal(t, Accepted, g.Transactions[0].Status())
+	assert.Equal(t, Rejected, rep.Status(), "Group errors reject the sets inside")
+}
+
+func TestAnalyzeUnreadableInput(t *testing.T) {
+	rep := analyze(t, "this is not x12")
+	assert.Empty(t, rep.Interchanges)
+	assert.ErrorIs(t, rep.Err, x12.ErrNoInterchange)
+	assert.Equal(t, Rejected, rep.Status())
+
+	// A truncated second ISA stops the tokenizer inside the first
+	// interchange, which is rejected as invalid content.
+	input := interchange("000000001", []string{"BHT*0019*00*1*20251121*1000*CH"})
+	input = input[:strings.Index(input, "GE*")] + "ISA*00*trunc"
+	rep = analyze(t, input)
+	require.Len(t, rep.Interchanges, 1)
+	var syntax *x12.SyntaxError
+	require.ErrorAs(t, rep.Err, &syntax)
+	assert.Equal(t, Rejected, rep.Interchanges[0].Status())
+	assert.Equal(t, x12.TA1InvalidContent, rep.Interchanges[0].Note())
+	assert.Equal(t, Rejected, rep.Status())
+}
+
+func TestAddDiagnostics(t *testing.T) {
+	input := interchange("000000001",
+		[]string{"BHT*0019*00*1*20251121*1000*CH"},
+		[]string{
+			"BHT*0019*00*2*20251121*1000*CH",
+			"HL*1**20*1", "NM1*85*2*CLINIC", "HL*2*1*22*0",
+			"CLM**1O.00***11:B:1", "DTP*431*D8*20251340",
+			"LX*1", "SV1*HC:99213*10.00*UN*1",
+		},
+	)
+	p := x837.NewParser(strings.NewReader(input))
+	for {
+		if _, err := p.Next(); err != nil {
+			require.Equal(t, io.EOF, err)
+			break
+		}
+	}
+	rep := analyze(t, input)
+	rep.AddDiagnostics(p.Diagnostics())
+
+	g := rep.Interchanges[0].Groups[0]
+	assert.Empty(t, g.Transactions[0].Segments)
+	tx := g.Transactions[1]
+	require.Len(t, tx.Segments, 2, "Element notes on one segment share an IK3")
+	assert.Equal(t, SegmentError{SegmentID: "CLM", Position: 6, Loop: "2300", Code: x12.IK3DataElementErrors, Elements: []ElementError{
+		{Position: 1, Code: x12.IK4MissingElement},
+		{Position: 2, Code: x12.IK4InvalidCharacter, Value: "1O.00"},
+	}}, tx.Segments[0])
+	assert.Equal(t, "DTP", tx.Segments[1].SegmentID)
+	assert.Equal(t, 7, tx.Segments[1].Position)
+	assert.Equal(t, PartiallyAccepted, rep.Status())
+
+	out := Generate(rep, 1, ackTime)
+	requireValid(t, out)
+	segs := segments(out)
+	assert.Contains(t, segs, "IK3*CLM*6*2300*8")
+	assert.Contains(t, segs, "IK4*2**6*1O.00")
+	assert.Contains(t, segs, "IK4*3**8*20251340")
+	assert.Contains(t, segs, "AK9*P*2*2*1")
+}
@@ -0,0 +1,307 @@
+// Package catalog keeps the Glue Data Catalog in step with the schema
+// registry. Each table of the registry is created in the database of its
+// layer (claim_raw_db, claim_silver_db, claim_gold_db) from
+// schema.Schema.GlueTable, updated when the registry has a newer version, and
+// left alone, with a ConflictError, when the catalog holds something the
+// registry did not write. Partitions are added as transforms write them.
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/glue/glueiface"
+
+	"claim-management-system/pipeline/schema"
+)
+
+// DefaultDatabases are the databases infra/modules/glue_catalog creates.
+var DefaultDatabases = map[schema.Layer]string{
+	schema.Raw:    "claim_raw_db",
+	schema.Silver: "claim_silver_db",
+	schema.Gold:   "claim_gold_db",
+}
+
+// maxBatchPartitions is the most partitions one BatchCreatePartition call
+// takes.
+const maxBatchPartitions = 100
+
+// Action is what SyncTable did to a table.
+type Action string
+
+const (
+	Created   Action = "created"
+	Updated   Action = "updated"
+	Unchanged Action = "unchanged"
+)
+
+// ConflictError reports a catalog table the registry cannot take over: one
+// it did not write, one of a version it lacks or newer than its latest, or
+// one edited since.
+type ConflictError struct {
+	Database string
+	Table    string
+	Diffs    []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("catalog: %s.%s: %s", e.Database, e.Table, strings.Join(e.Diffs, "; "))
+}
+
+// Catalog creates and updates the Glue tables of registry schemas.
+type Catalog struct {
+	client glueiface.GlueAPI
+	// Schemas resolves the version a catalog table was written from.
+	Schemas *schema.Registry
+	// Databases names the database of each layer.
+	Databases map[schema.Layer]string
+	// Buckets names the bucket holding each layer's data.
+	Buckets map[schema.Layer]string
+	// DryRun makes SyncTable and AddPartitions report what they would do
+	// without writing.
+	DryRun bool
+}
+
+// New returns a Catalog of the DefaultDatabases with raw tables in rawBucket
+// and silver and gold tables in lakeBucket.
+func New(client glueiface.GlueAPI, schemas *schema.Registry, rawBucket, lakeBucket string) *Catalog {
+	databases := make(map[schema.Layer]string, len(DefaultDatabases))
+	for layer, db := range DefaultDatabases {
+		databases[layer] = db
+	}
+	return &Catalog{
+		client:    client,
+		Schemas:   schemas,
+		Databases: databases,
+		Buckets:   map[schema.Layer]string{schema.Raw: rawBucket, schema.Silver: lakeBucket, schema.Gold: lakeBucket},
+	}
+}
+
+// Database returns the database of s's layer.
+func (c *Catalog) Database(s *schema.Schema) string {
+	return c.Databases[s.Layer]
+}
+
+// SyncTable brings the catalog table of s to s's version. A missing table is
+// created. An existing one is updated if it was written from an earlier
+// version of s and still matches that version; otherwise it is reported as a
+// ConflictError and not touched.
+func (c *Catalog) SyncTable(ctx context.Context, s *schema.Schema) (Action, error) {
+	db := c.Database(s)
+	want := s.GlueTable(c.Buckets[s.Layer])
+	out, err := c.client.GetTableWithContext(ctx, &glue.GetTableInput{DatabaseName: aws.String(db), Name: want.Name})
+	if isCode(err, glue.ErrCodeEntityNotFoundException) {
+		if !c.DryRun {
+			_, err = c.client.CreateTableWithContext(ctx, &glue.CreateTableInput{DatabaseName: aws.String(db), TableInput: want})
+			if err != nil {
+				return "", fmt.Errorf("create %s.%s: %w", db, s.Name, err)
+			}
+		}
+		return Created, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get %s.%s: %w", db, s.Name, err)
+	}
+
+	conflict := &ConflictError{Database: db, Table: s.Name}
+	table := out.Table
+	version, err := strconv.Atoi(aws.StringValue(table.Parameters[schema.ParamSchemaVersion]))
+	if err != nil {
+		conflict.Diffs = append(conflict.Diffs, "not written from the schema registry (no "+schema.ParamSchemaVersion+" parameter)")
+		return "", conflict
+	}
+	if version > s.Version {
+		conflict.Diffs = append(conflict.Diffs, fmt.Sprintf("catalog has v%d, newer than %s", version, s))
+		return "", conflict
+	}
+	// Get takes version 0 for the latest; the catalog must name one.
+	written, err := c.Schemas.Get(s.Name, version)
+	if err != nil || version < 1 {
+		conflict.Diffs = append(conflict.Diffs, fmt.Sprintf("catalog has v%d, which the registry lacks", version))
+		return "", conflict
+	}
+	if conflict.Diffs = Diff(table, written.GlueTable(c.Buckets[s.Layer])); len(conflict.Diffs) > 0 {
+		for i, d := range conflict.Diffs {
+			conflict.Diffs[i] = fmt.Sprintf("%s (v%d)", d, version)
+		}
+		return "", conflict
+	}
+	if version == s.Version {
+		return Unchanged, nil
+	}
+
+	if !c.DryRun {
+		_, err = c.client.UpdateTableWithContext(ctx, &glue.UpdateTableInput{
+			DatabaseName: aws.String(db),
+			TableInput:   want,
+			// Glue refuses the update if the table changed since GetTable.
+			VersionId: table.VersionId,
+		})
+		if err != nil {
+			return "", fmt.Errorf("update %s.%s to v%d: %w", db, s.Name, s.Version, err)
+		}
+	}
+	return Updated, nil
+}
+
+// SyncResult is the outcome of SyncTable for one schema.
+type SyncResult struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Version  int    `json:"version"`
+	Action   Action `json:"action,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// SyncAll syncs the latest version of every registry table. Every table is
+// tried; the errors of those that failed are joined.
+func (c *Catalog) SyncAll(ctx context.Context) ([]*SyncResult, error) {
+	var results []*SyncResult
+	var errs []error
+	for _, layer := range []schema.Layer{schema.Raw, schema.Silver, schema.Gold} {
+		for _, s := range c.Schemas.Layer(layer) {
+			res := &SyncResult{Database: c.Database(s), Table: s.Name, Version: s.Version}
+			action, err := c.SyncTable(ctx, s)
+			if err != nil {
+				res.Error = err.Error()
+				errs = append(errs, err)
+			}
+			res.Action = action
+			results = append(results, res)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// AddPartitions registers the partitions of s at paths, as returned by
+// schema.Schema.PartitionPath, and returns how many were new. Partitions
+// already in the catalog are left as they are, so adding is idempotent.
+func (c *Catalog) AddPartitions(ctx context.Context, s *schema.Schema, paths []string) (int, error) {
+	db := c.Database(s)
+	table := s.GlueTable(c.Buckets[s.Layer])
+	inputs := make([]*glue.PartitionInput, 0, len(paths))
+	for _, path := range paths {
+		values, err := partitionValues(s, path)
+		if err != nil {
+			return 0, err
+		}
+		sd := *table.StorageDescriptor
+		sd.Location = aws.String(aws.StringValue(table.StorageDescriptor.Location) + path + "/")
+		inputs = append(inputs, &glue.PartitionInput{Values: aws.StringSlice(values), StorageDescriptor: &sd})
+	}
+	if c.DryRun {
+		return len(inputs), nil
+	}
+
+	added := 0
+	for len(inputs) > 0 {
+		batch := inputs
+		if len(batch) > maxBatchPartitions {
+			batch = batch[:maxBatchPartitions]
+		}
+		inputs = inputs[len(batch):]
+		out, err := c.client.BatchCreatePartitionWithContext(ctx, &glue.BatchCreatePartitionInput{
+			DatabaseName:       aws.String(db),
+			TableName:          aws.String(s.Name),
+			PartitionInputList: batch,
+		})
+		if err != nil {
+			return added, fmt.Errorf("add partitions to %s.%s: %w", db, s.Name, err)
+		}
+		added += len(batch)
+		var errs []error
+		for _, pe := range out.Errors {
+			added--
+			code := aws.StringValue(pe.ErrorDetail.ErrorCode)
+			if code == glue.ErrCodeAlreadyExistsException {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("add partition %s to %s.%s: %s: %s",
+				s.PartitionPath(aws.StringValueSlice(pe.PartitionValues)), db, s.Name, code, aws.StringValue(pe.ErrorDetail.ErrorMessage)))
+		}
+		if err := errors.Join(errs...); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// partitionValues returns the values of a partition path of s.
+func partitionValues(s *schema.Schema, path string) ([]string, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != len(s.Partitions) {
+		return nil, fmt.Errorf("catalog: %s: partition path %q does not match partitions %s", s, path, partitionNames(s))
+	}
+	values := make([]string, len(parts))
+	for i, part := range parts {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || name != s.Partitions[i].Name || value == "" {
+			return nil, fmt.Errorf("catalog: %s: partition path %q does not match partitions %s", s, path, partitionNames(s))
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func partitionNames(s *schema.Schema) string {
+	names := make([]string, len(s.Partitions))
+	for i, c := range s.Partitions {
+		names[i] = c.Name
+	}
+	return strings.Join(names, "/")
+}
+
+// Diff returns how a catalog table differs from the definition want in what
+// readers depend on: location, storage format, columns and partition keys.
+// Comments and parameters other than the schema version are not compared.
+func Diff(table *glue.TableData, want *glue.TableInput) []string {
+	var diffs []string
+	sd, wsd := table.StorageDescriptor, want.StorageDescriptor
+	if sd == nil {
+		return []string{"no storage descriptor"}
+	}
+	if got, w := aws.StringValue(sd.Location), aws.StringValue(wsd.Location); got != w {
+		diffs = append(diffs, fmt.Sprintf("location is %s, want %s", got, w))
+	}
+	var serde string
+	if sd.SerdeInfo != nil {
+		serde = aws.StringValue(sd.SerdeInfo.SerializationLibrary)
+	}
+	if w := aws.StringValue(wsd.SerdeInfo.SerializationLibrary); serde != w {
+		diffs = append(diffs, fmt.Sprintf("serde is %s, want %s", serde, w))
+	}
+	if got, w := aws.StringValue(sd.InputFormat), aws.StringValue(wsd.InputFormat); got != w {
+		diffs = append(diffs, fmt.Sprintf("input format is %s, want %s", got, w))
+	}
+	diffs = append(diffs, diffColumns("column", sd.Columns, wsd.Columns)...)
+	diffs = append(diffs, diffColumns("partition key", table.PartitionKeys, want.PartitionKeys)...)
+	return diffs
+}
+
+func diffColumns(what string, got, want []*glue.Column) []string {
+	var diffs []string
+	for i := 0; i < len(got) || i < len(want); i++ {
+		switch {
+		case i >= len(want):
+			diffs = append(diffs, fmt.Sprintf("unexpected %s %s", what, aws.StringValue(got[i].Name)))
+		case i >= len(got):
+			diffs = append(diffs, fmt.Sprintf("missing %s %s", what, aws.StringValue(want[i].Name)))
+		case !strings.EqualFold(aws.StringValue(got[i].Name), aws.StringValue(want[i].Name)):
+			diffs = append(diffs, fmt.Sprintf("%s %d is %s, want %s", what, i+1, aws.StringValue(got[i].Name), aws.StringValue(want[i].Name)))
+		case !strings.EqualFold(aws.StringValue(got[i].Type), aws.StringValue(want[i].Type)):
+			diffs = append(diffs, fmt.Sprintf("%s %s is %s, want %s", what, aws.StringValue(got[i].Name), aws.StringValue(got[i].Type), aws.StringValue(want[i].Type)))
+		}
+	}
+	return diffs
+}
+
+func isCode(err error, code string) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == code
+}
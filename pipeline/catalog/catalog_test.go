@@ -0,0 +1,244 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/schema"
+)
+
+func newCatalog(t *testing.T) (*Catalog, *Fake) {
+	t.Helper()
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	fake := NewFake("claim_raw_db", "claim_silver_db", "claim_gold_db")
+	return New(fake, r, "claim-dev-raw", "claim-dev-lake"), fake
+}
+
+func getTable(t *testing.T, fake *Fake, db, name string) *glue.TableData {
+	t.Helper()
+	out, err := fake.GetTableWithContext(context.Background(), &glue.GetTableInput{DatabaseName: aws.String(db), Name: aws.String(name)})
+	require.NoError(t, err)
+	return out.Table
+}
+
+func TestSyncAll(t *testing.T) {
+	c, fake := newCatalog(t)
+	ctx := context.Background()
+
+	results, err := c.SyncAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, results, len(c.Schemas.Names()))
+	for _, res := range results {
+		assert.Equal(t, Created, res.Action, res.Table)
+	}
+	assert.Equal(t, &SyncResult{Database: "claim_raw_db", Table: "tbl_837_raw_csv", Version: 2, Action: Created}, results[2])
+
+	raw := getTable(t, fake, "claim_raw_db", "tbl_834_raw_csv")
+	assert.Equal(t, "s3://claim-dev-raw/raw/834/", aws.StringValue(raw.StorageDescriptor.Location))
+	assert.Equal(t, "org.apache.hadoop.hive.serde2.OpenCSVSerde", aws.StringValue(raw.StorageDescriptor.SerdeInfo.SerializationLibrary))
+	member := getTable(t, fake, "claim_silver_db", "tbl_member")
+	assert.Equal(t, "s3://claim-dev-lake/silver/834_member/", aws.StringValue(member.StorageDescriptor.Location))
+	assert.Equal(t, "org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe", aws.StringValue(member.StorageDescriptor.SerdeInfo.SerializationLibrary))
+	assert.Len(t, member.PartitionKeys, 3)
+	dim := getTable(t, fake, "claim_gold_db", "dim_member")
+	assert.Equal(t, "s3://claim-dev-lake/gold/dim_member/", aws.StringValue(dim.StorageDescriptor.Location))
+	assert.Empty(t, dim.PartitionKeys, "Dimensions are not partitioned")
+
+	results, err = c.SyncAll(ctx)
+	require.NoError(t, err)
+	for _, res := range results {
+		assert.Equal(t, Unchanged, res.Action, res.Table)
+	}
+}
+
+func TestSyncTableUpdates(t *testing.T) {
+	c, fake := newCatalog(t)
+	ctx := context.Background()
+	v1, err := c.Schemas.Get("tbl_837_raw_csv", 1)
+	require.NoError(t, err)
+	v2, err := c.Schemas.Latest("tbl_837_raw_csv")
+	require.NoError(t, err)
+
+	action, err := c.SyncTable(ctx, v1)
+	require.NoError(t, err)
+	assert.Equal(t, Created, action)
+
+	c.DryRun = true
+	action, err = c.SyncTable(ctx, v2)
+	require.NoError(t, err)
+	assert.Equal(t, Updated, action)
+	assert.Equal(t, "1", aws.StringValue(getTable(t, fake, "claim_raw_db", "tbl_837_raw_csv").Parameters[schema.ParamSchemaVersion]),
+		"A dry run writes nothing")
+
+	c.DryRun = false
+	action, err = c.SyncTable(ctx, v2)
+	require.NoError(t, err)
+	assert.Equal(t, Updated, action)
+	table := getTable(t, fake, "claim_raw_db", "tbl_837_raw_csv")
+	assert.Equal(t, "2", aws.StringValue(table.Parameters[schema.ParamSchemaVersion]))
+	assert.Equal(t, "2", aws.StringValue(table.VersionId))
+	assert.Empty(t, Diff(table, v2.GlueTable("claim-dev-raw")))
+
+	action, err = c.SyncTable(ctx, v1)
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Empty(t, action)
+	assert.EqualError(t, err, "catalog: claim_raw_db.tbl_837_raw_csv: catalog has v2, newer than tbl_837_raw_csv v1")
+}
+
+func TestSyncTableConflicts(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name string
+		edit func(s *schema.Schema, in *glue.TableInput)
+		want string
+	}{
+		{
+			name: "retyped",
+			edit: func(s *schema.Schema, in *glue.TableInput) {
+				in.StorageDescriptor.Columns[s.Index("line_count")].Type = aws.String("string")
+			},
+			want: "column line_count is string, want bigint (v1)",
+		},
+		{
+			name: "column added by hand",
+			edit: func(_ *schema.Schema, in *glue.TableInput) {
+				in.StorageDescriptor.Columns = append(in.StorageDescriptor.Columns, &glue.Column{Name: aws.String("notes"), Type: aws.String("string")})
+			},
+			want: "unexpected column notes (v1)",
+		},
+		{
+			name: "moved and reformatted",
+			edit: func(_ *schema.Schema, in *glue.TableInput) {
+				in.StorageDescriptor.Location = aws.String("s3://elsewhere/claim_header/")
+				in.StorageDescriptor.SerdeInfo.SerializationLibrary = aws.String("org.openx.data.jsonserde.JsonSerDe")
+				in.PartitionKeys = in.PartitionKeys[:1]
+			},
+			want: "location is s3://elsewhere/claim_header/, want s3://claim-dev-lake/silver/837_claim_header/ (v1); " +
+				"serde is org.openx.data.jsonserde.JsonSerDe, want org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe (v1); " +
+				"missing partition key month (v1); missing partition key day (v1)",
+		},
+		{
+			name: "created by a crawler",
+			edit: func(_ *schema.Schema, in *glue.TableInput) { delete(in.Parameters, schema.ParamSchemaVersion) },
+			want: "not written from the schema registry (no schema_version parameter)",
+		},
+		{
+			name: "unknown version",
+			edit: func(_ *schema.Schema, in *glue.TableInput) {
+				in.Parameters[schema.ParamSchemaVersion] = aws.String("0")
+			},
+			want: "catalog has v0, which the registry lacks",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, fake := newCatalog(t)
+			s, err := c.Schemas.Latest("tbl_claim_header")
+			require.NoError(t, err)
+			in := s.GlueTable("claim-dev-lake")
+			tc.edit(s, in)
+			_, err = fake.CreateTableWithContext(ctx, &glue.CreateTableInput{DatabaseName: aws.String("claim_silver_db"), TableInput: in})
+			require.NoError(t, err)
+
+			_, err = c.SyncTable(ctx, s)
+			var conflict *ConflictError
+			require.ErrorAs(t, err, &conflict)
+			assert.Equal(t, "claim_silver_db", conflict.Database)
+			assert.Equal(t, "catalog: claim_silver_db.tbl_claim_header: "+tc.want, err.Error())
+			assert.Equal(t, "1", aws.StringValue(getTable(t, fake, "claim_silver_db", "tbl_claim_header").VersionId), "A conflicting table is not touched")
+		})
+	}
+}
+
+// racingGlue changes the table between GetTable and UpdateTable, as another
+// writer would.
+type racingGlue struct {
+	*Fake
+}
+
+func (r racingGlue) GetTableWithContext(ctx aws.Context, in *glue.GetTableInput, opts ...request.Option) (*glue.GetTableOutput, error) {
+	out, err := r.Fake.GetTableWithContext(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	input := &glue.TableInput{
+		Name: out.Table.Name, TableType: out.Table.TableType, Parameters: out.Table.Parameters,
+		PartitionKeys: out.Table.PartitionKeys, StorageDescriptor: out.Table.StorageDescriptor,
+	}
+	_, err = r.Fake.UpdateTableWithContext(ctx, &glue.UpdateTableInput{DatabaseName: in.DatabaseName, TableInput: input})
+	return out, err
+}
+
+func TestSyncTableConcurrentUpdate(t *testing.T) {
+	c, fake := newCatalog(t)
+	ctx := context.Background()
+	v1, err := c.Schemas.Get("tbl_837_raw_csv", 1)
+	require.NoError(t, err)
+	_, err = c.SyncTable(ctx, v1)
+	require.NoError(t, err)
+
+	c.client = racingGlue{fake}
+	v2, err := c.Schemas.Latest("tbl_837_raw_csv")
+	require.NoError(t, err)
+	_, err = c.SyncTable(ctx, v2)
+	assert.True(t, isCode(err, glue.ErrCodeConcurrentModificationException), "got %v", err)
+	assert.Equal(t, "1", aws.StringValue(getTable(t, fake, "claim_raw_db", "tbl_837_raw_csv").Parameters[schema.ParamSchemaVersion]))
+}
+
+func TestSyncTableMissingDatabase(t *testing.T) {
+	c, _ := newCatalog(t)
+	c.Databases[schema.Silver] = "claim_nowhere_db"
+	s, err := c.Schemas.Latest("tbl_payment")
+	require.NoError(t, err)
+	_, err = c.SyncTable(context.Background(), s)
+	var aerr awserr.Error
+	require.ErrorAs(t, err, &aerr)
+	assert.Equal(t, glue.ErrCodeEntityNotFoundException, aerr.Code())
+	assert.Contains(t, err.Error(), "create claim_nowhere_db.tbl_payment")
+}
+
+func TestAddPartitions(t *testing.T) {
+	c, fake := newCatalog(t)
+	ctx := context.Background()
+	s, err := c.Schemas.Latest("tbl_payment")
+	require.NoError(t, err)
+	_, err = c.SyncTable(ctx, s)
+	require.NoError(t, err)
+
+	var paths []string
+	for d := 0; d < 150; d++ {
+		paths = append(paths, fmt.Sprintf("year=2025/month=%02d/day=%02d", 1+d/28, 1+d%28))
+	}
+	added, err := c.AddPartitions(ctx, s, paths)
+	require.NoError(t, err)
+	assert.Equal(t, 150, added, "Added in two batches")
+
+	added, err = c.AddPartitions(ctx, s, []string{paths[0], "year=2025/month=12/day=31"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, added, "Existing partitions are skipped")
+
+	out, err := fake.GetPartitionsWithContext(ctx, &glue.GetPartitionsInput{DatabaseName: aws.String("claim_silver_db"), TableName: aws.String("tbl_payment")})
+	require.NoError(t, err)
+	require.Len(t, out.Partitions, 151)
+	p := out.Partitions[0]
+	assert.Equal(t, []string{"2025", "01", "01"}, aws.StringValueSlice(p.Values))
+	assert.Equal(t, "s3://claim-dev-lake/silver/835_payment/year=2025/month=01/day=01/", aws.StringValue(p.StorageDescriptor.Location))
+	assert.Equal(t, "org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe", aws.StringValue(p.StorageDescriptor.SerdeInfo.SerializationLibrary))
+
+	_, err = c.AddPartitions(ctx, s, []string{"year=2025/day=01"})
+	assert.EqualError(t, err, `catalog: tbl_payment v1: partition path "year=2025/day=01" does not match partitions year/month/day`)
+
+	other, err := c.Schemas.Latest("tbl_member")
+	require.NoError(t, err)
+	_, err = c.AddPartitions(ctx, other, paths[:1])
+	assert.True(t, isCode(err, glue.ErrCodeEntityNotFoundException), "Partitions need their table; got %v", err)
+}
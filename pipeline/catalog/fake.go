@@ -0,0 +1,195 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/glue/glueiface"
+)
+
+// Fake is an in-memory stand-in for the part of the Glue API Catalog uses:
+// GetTable, CreateTable, UpdateTable (with VersionId checks),
+// BatchCreatePartition and GetPartitions. It returns the error codes Glue
+// does. Other methods panic.
+type Fake struct {
+	glueiface.GlueAPI
+
+	// Now stamps create and update times; nil means time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	databases map[string]map[string]*fakeTable
+}
+
+type fakeTable struct {
+	table      *glue.TableData
+	partitions map[string]*glue.Partition
+}
+
+// NewFake returns a Fake holding the empty databases.
+func NewFake(databases ...string) *Fake {
+	f := &Fake{databases: map[string]map[string]*fakeTable{}}
+	for _, db := range databases {
+		f.databases[db] = map[string]*fakeTable{}
+	}
+	return f
+}
+
+func (f *Fake) GetTableWithContext(_ aws.Context, in *glue.GetTableInput, _ ...request.Option) (*glue.GetTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(aws.StringValue(in.DatabaseName), aws.StringValue(in.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &glue.GetTableOutput{Table: copyTable(t.table)}, nil
+}
+
+func (f *Fake) CreateTableWithContext(_ aws.Context, in *glue.CreateTableInput, _ ...request.Option) (*glue.CreateTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	db, name := aws.StringValue(in.DatabaseName), aws.StringValue(in.TableInput.Name)
+	tables, ok := f.databases[db]
+	if !ok {
+		return nil, awserr.New(glue.ErrCodeEntityNotFoundException, fmt.Sprintf("Database %s not found.", db), nil)
+	}
+	if _, ok := tables[name]; ok {
+		return nil, awserr.New(glue.ErrCodeAlreadyExistsException, fmt.Sprintf("Table %s already exists.", name), nil)
+	}
+	now := f.now()
+	t := tableOf(db, in.TableInput, "1")
+	t.CreateTime, t.UpdateTime = aws.Time(now), aws.Time(now)
+	tables[name] = &fakeTable{table: t, partitions: map[string]*glue.Partition{}}
+	return &glue.CreateTableOutput{}, nil
+}
+
+func (f *Fake) UpdateTableWithContext(_ aws.Context, in *glue.UpdateTableInput, _ ...request.Option) (*glue.UpdateTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	db := aws.StringValue(in.DatabaseName)
+	t, err := f.table(db, aws.StringValue(in.TableInput.Name))
+	if err != nil {
+		return nil, err
+	}
+	if in.VersionId != nil && aws.StringValue(in.VersionId) != aws.StringValue(t.table.VersionId) {
+		return nil, awserr.New(glue.ErrCodeConcurrentModificationException,
+			fmt.Sprintf("Table version %s is not the latest version %s.", aws.StringValue(in.VersionId), aws.StringValue(t.table.VersionId)), nil)
+	}
+	version, _ := strconv.Atoi(aws.StringValue(t.table.VersionId))
+	updated := tableOf(db, in.TableInput, strconv.Itoa(version+1))
+	updated.CreateTime, updated.UpdateTime = t.table.CreateTime, aws.Time(f.now())
+	t.table = updated
+	return &glue.UpdateTableOutput{}, nil
+}
+
+func (f *Fake) BatchCreatePartitionWithContext(_ aws.Context, in *glue.BatchCreatePartitionInput, _ ...request.Option) (*glue.BatchCreatePartitionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(in.PartitionInputList) > maxBatchPartitions {
+		return nil, awserr.New(glue.ErrCodeInvalidInputException,
+			fmt.Sprintf("The number of partitions %d exceeds the limit of %d.", len(in.PartitionInputList), maxBatchPartitions), nil)
+	}
+	db, name := aws.StringValue(in.DatabaseName), aws.StringValue(in.TableName)
+	t, err := f.table(db, name)
+	if err != nil {
+		return nil, err
+	}
+	out := &glue.BatchCreatePartitionOutput{}
+	for _, p := range in.PartitionInputList {
+		values := aws.StringValueSlice(p.Values)
+		key := strings.Join(values, "/")
+		var code, msg string
+		switch {
+		case len(values) != len(t.table.PartitionKeys):
+			code, msg = glue.ErrCodeInvalidInputException, "The number of partition keys do not match the number of partition values."
+		case t.partitions[key] != nil:
+			code, msg = glue.ErrCodeAlreadyExistsException, "Partition already exists."
+		}
+		if code != "" {
+			out.Errors = append(out.Errors, &glue.PartitionError{
+				PartitionValues: p.Values,
+				ErrorDetail:     &glue.ErrorDetail{ErrorCode: aws.String(code), ErrorMessage: aws.String(msg)},
+			})
+			continue
+		}
+		t.partitions[key] = &glue.Partition{
+			DatabaseName:      aws.String(db),
+			TableName:         aws.String(name),
+			Values:            aws.StringSlice(values),
+			StorageDescriptor: p.StorageDescriptor,
+			Parameters:        p.Parameters,
+			CreationTime:      aws.Time(f.now()),
+		}
+	}
+	return out, nil
+}
+
+// GetPartitionsWithContext returns all partitions of the table in value
+// order; Expression and paging are not supported.
+func (f *Fake) GetPartitionsWithContext(_ aws.Context, in *glue.GetPartitionsInput, _ ...request.Option) (*glue.GetPartitionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(aws.StringValue(in.DatabaseName), aws.StringValue(in.TableName))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(t.partitions))
+	for k := range t.partitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := &glue.GetPartitionsOutput{}
+	for _, k := range keys {
+		out.Partitions = append(out.Partitions, t.partitions[k])
+	}
+	return out, nil
+}
+
+func (f *Fake) table(db, name string) (*fakeTable, error) {
+	tables, ok := f.databases[db]
+	if !ok {
+		return nil, awserr.New(glue.ErrCodeEntityNotFoundException, fmt.Sprintf("Database %s not found.", db), nil)
+	}
+	t, ok := tables[name]
+	if !ok {
+		return nil, awserr.New(glue.ErrCodeEntityNotFoundException, fmt.Sprintf("Table %s not found.", name), nil)
+	}
+	return t, nil
+}
+
+func (f *Fake) now() time.Time {
+	if f.Now != nil {
+		return f.Now()
+	}
+	return time.Now()
+}
+
+func tableOf(db string, in *glue.TableInput, version string) *glue.TableData {
+	return &glue.TableData{
+		DatabaseName:      aws.String(db),
+		Name:              in.Name,
+		Description:       in.Description,
+		TableType:         in.TableType,
+		Parameters:        in.Parameters,
+		PartitionKeys:     in.PartitionKeys,
+		StorageDescriptor: in.StorageDescriptor,
+		VersionId:         aws.String(version),
+	}
+}
+
+func copyTable(t *glue.TableData) *glue.TableData {
+	c := *t
+	c.Parameters = make(map[string]*string, len(t.Parameters))
+	for k, v := range t.Parameters {
+		c.Parameters[k] = aws.String(aws.StringValue(v))
+	}
+	return &c
+}
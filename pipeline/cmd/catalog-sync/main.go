@@ -0,0 +1,52 @@
+// Command catalog-sync creates or updates the Glue table of every schema in
+// the registry and prints what it did per table:
+//
+//	catalog-sync -raw-bucket claim-dev-raw -lake-bucket claim-dev-lake
+//
+// Tables edited outside the registry are reported as conflicts and left
+// alone; the command then exits non-zero.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/glue"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/schema"
+)
+
+func main() {
+	rawBucket := flag.String("raw-bucket", os.Getenv("RAW_BUCKET"), "bucket holding the raw tables")
+	lakeBucket := flag.String("lake-bucket", os.Getenv("LAKE_BUCKET"), "bucket holding the silver and gold tables")
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "catalog-sync: ", 0)
+	if *rawBucket == "" || *lakeBucket == "" {
+		logger.Fatal("-raw-bucket and -lake-bucket are required")
+	}
+	schemas, err := schema.Builtin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	c := catalog.New(glue.New(sess), schemas, *rawBucket, *lakeBucket)
+	c.DryRun = *dryRun
+	results, syncErr := c.SyncAll(context.Background())
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		logger.Fatal(err)
+	}
+	if syncErr != nil {
+		logger.Fatal(syncErr)
+	}
+}
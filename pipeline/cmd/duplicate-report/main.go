@@ -0,0 +1,56 @@
+// Command duplicate-report prints the files marked DUPLICATE in the
+// file-metadata table, grouped by source system.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"claim-management-system/pipeline/ingest"
+	"claim-management-system/pipeline/metadata"
+)
+
+func main() {
+	table := flag.String("table", os.Getenv("METADATA_TABLE"), "file-metadata DynamoDB table name")
+	asJSON := flag.Bool("json", false, "print the full report as JSON")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "duplicate-report: ", 0)
+	if *table == "" {
+		logger.Fatal("-table is required")
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	store := metadata.NewDynamoStore(dynamodb.New(sess), *table)
+
+	report, err := ingest.BuildDuplicateReport(context.Background(), store)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tDUPLICATES\tFILE\tORIGINAL\tORIGINAL SOURCE")
+	for _, s := range report.Sources {
+		for _, f := range s.Files {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", s.SourceSystem, s.Count, f.FileName, f.OriginalFileName, f.OriginalSourceSystem)
+		}
+	}
+	tw.Flush()
+}
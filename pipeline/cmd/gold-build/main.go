@@ -0,0 +1,84 @@
+// Command gold-build rebuilds the gold dimensions and facts of the lake
+// bucket from its silver tables and prints the run manifest:
+//
+//	gold-build -lake-bucket claim-dev-lake -glue
+//
+// With -glue, each gold table is synced to the Glue catalog before it is
+// written and the partitions of the facts are added.
+//
+// With -local, a directory stands in for S3 (one subdirectory per bucket),
+// as written by silver-transform -local.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/gold"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+func main() {
+	lakeBucket := flag.String("lake-bucket", os.Getenv("LAKE_BUCKET"), "lake bucket holding the silver and gold tables")
+	local := flag.String("local", "", "directory standing in for S3")
+	format := flag.String("format", "parquet", "file format of silver and gold: parquet or csv")
+	registerGlue := flag.Bool("glue", false, "sync the gold tables to the Glue catalog and add fact partitions")
+	id := flag.String("id", "", "run id (default derived from the current time)")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "gold-build: ", log.LstdFlags)
+	if *lakeBucket == "" {
+		logger.Fatal("-lake-bucket is required")
+	}
+	schemas, err := schema.Builtin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	b := &gold.Builder{Schemas: schemas, Bucket: *lakeBucket, ID: *id, Logger: logger}
+	switch *format {
+	case "parquet":
+		b.Format = silver.Parquet{}
+	case "csv":
+		b.Format = silver.CSV{}
+	default:
+		logger.Fatalf("-format %q: need parquet or csv", *format)
+	}
+	if *local != "" {
+		if *registerGlue {
+			logger.Fatal("-glue cannot be used with -local")
+		}
+		b.Objects = objectstore.NewDir(*local)
+	} else {
+		sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+		b.Objects = objectstore.NewS3Store(s3.New(sess))
+		if *registerGlue {
+			b.Catalog = catalog.New(glue.New(sess), schemas, "", *lakeBucket)
+		}
+	}
+
+	m, err := b.Run(ctx)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+// Command iceberg-commit commits the tables of a Parquet gold run to their
+// Iceberg tables in the Glue catalog and prints the commit report:
+//
+//	iceberg-commit -lake-bucket claim-dev-lake -gold-run gold-20251203T060000Z
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"claim-management-system/pipeline/gold"
+	"claim-management-system/pipeline/iceberg"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+)
+
+func main() {
+	lakeBucket := flag.String("lake-bucket", os.Getenv("LAKE_BUCKET"), "lake bucket holding the gold tables")
+	goldRun := flag.String("gold-run", "", "id of the gold run to commit")
+	database := flag.String("database", "", "Glue database of the Iceberg tables (default claim_gold_db)")
+	attempts := flag.Int("max-attempts", iceberg.DefaultAttempts, "tries of a commit that loses to concurrent ones")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "iceberg-commit: ", log.LstdFlags)
+	switch {
+	case *lakeBucket == "":
+		logger.Fatal("-lake-bucket is required")
+	case *goldRun == "":
+		logger.Fatal("-gold-run is required")
+	}
+	schemas, err := schema.Builtin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	store := objectstore.NewS3Store(s3.New(sess))
+	m, err := gold.ReadManifest(ctx, store, *lakeBucket, *goldRun)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	w := &iceberg.Writer{
+		Objects:     store,
+		Glue:        glue.New(sess),
+		Schemas:     schemas,
+		Bucket:      *lakeBucket,
+		Database:    *database,
+		MaxAttempts: *attempts,
+		Logger:      logger,
+	}
+	rep, err := w.Publish(ctx, m)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+// Command ingest-worker consumes raw-bucket notifications from the
+// claim-<env>-s3-events queue and writes file-metadata records.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"claim-management-system/pipeline/ack"
+	"claim-management-system/pipeline/errreport"
+	"claim-management-system/pipeline/ingest"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/queue"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/validation"
+)
+
+func main() {
+	queueURL := flag.String("queue-url", os.Getenv("QUEUE_URL"), "URL of the S3 events queue")
+	table := flag.String("table", os.Getenv("METADATA_TABLE"), "file-metadata DynamoDB table name")
+	archiveBucket := flag.String("archive-bucket", os.Getenv("ARCHIVE_BUCKET"), "Object Lock bucket that X12 originals are copied to before parsing; empty disables archiving")
+	archivePrefix := flag.String("archive-prefix", "", "key prefix for archived originals")
+	errorBucket := flag.String("error-bucket", os.Getenv("ERROR_BUCKET"), "lake bucket for JSON error reports of X12 files; empty disables them")
+	errorPrefix := flag.String("error-prefix", errreport.DefaultPrefix, "key prefix for error reports")
+	ackBucket := flag.String("ack-bucket", os.Getenv("ACK_BUCKET"), "bucket for TA1/999 acknowledgments of X12 files; empty disables them (never the raw bucket)")
+	ackPrefix := flag.String("ack-prefix", ack.DefaultPrefix, "key prefix for acknowledgments")
+	validateCSV := flag.Bool("validate-csv", true, "validate CSV files against their registered schema and mark them VALIDATED or REJECTED")
+	maxErrorRows := flag.Int64("max-error-rows", -1, "bad rows a CSV file may have and still be validated; negative for no limit")
+	maxErrorRate := flag.Float64("max-error-rate", 0.01, "fraction of rows of a CSV file that may be bad; negative for no limit")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 20*time.Second, "how long an in-flight message may run after SIGTERM; keep below the queue visibility timeout")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "ingest-worker: ", log.LstdFlags)
+	if *queueURL == "" || *table == "" {
+		logger.Fatal("-queue-url and -table are required")
+	}
+
+	if *archiveBucket != "" && *archiveBucket == os.Getenv("RAW_BUCKET") {
+		logger.Fatal("-archive-bucket must not be the raw bucket: originals would overwrite themselves")
+	}
+	if *errorBucket != "" && *errorBucket == os.Getenv("RAW_BUCKET") {
+		logger.Fatal("-error-bucket must not be the raw bucket: error reports would be ingested")
+	}
+	if *ackBucket != "" && *ackBucket == os.Getenv("RAW_BUCKET") {
+		logger.Fatal("-ack-bucket must not be the raw bucket: acknowledgments would be ingested")
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	objects := objectstore.NewS3Store(s3.New(sess))
+	store := metadata.NewDynamoStore(dynamodb.New(sess), *table)
+	worker := &ingest.Worker{
+		Objects:  objects,
+		Metadata: store,
+		Logger:   logger,
+	}
+	if *archiveBucket != "" {
+		worker.Archive = &ingest.Archiver{Objects: objects, Bucket: *archiveBucket, Prefix: *archivePrefix}
+	}
+	if *errorBucket != "" {
+		worker.Errors = &errreport.Reporter{Objects: objects, Bucket: *errorBucket, Prefix: *errorPrefix}
+	}
+	if *ackBucket != "" {
+		worker.Acks = &ack.Acknowledger{Objects: objects, Bucket: *ackBucket, Prefix: *ackPrefix, Counter: store, Metadata: store}
+	}
+	if *validateCSV {
+		schemas, err := schema.Builtin()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		worker.Validator = &validation.Validator{
+			Objects: objects,
+			Checker: validation.Checker{
+				Schemas: schemas,
+				Budget:  validation.Budget{MaxErrorRows: *maxErrorRows, MaxErrorRate: *maxErrorRate},
+			},
+		}
+	}
+	consumer := &queue.Consumer{
+		Queue:           queue.NewSQSQueue(sqs.New(sess), *queueURL),
+		Handler:         worker,
+		ShutdownTimeout: *shutdownTimeout,
+		Logger:          logger,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Printf("consuming %s", *queueURL)
+	if err := consumer.Run(ctx); err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,104 @@
+// Command integrity-check checks the references between the silver tables of
+// the lake bucket and prints the report:
+//
+//	integrity-check -lake-bucket claim-dev-lake -silver-run silver-20251122T060000Z
+//
+// With -silver-run, only the rows loaded from the files of that silver run
+// are checked; without it, every row is. -policy sets the action per rule,
+// e.g. -policy claim_coverage_gap=quarantine; rules it omits keep the
+// default. PHI in the keys of issue rows is hashed as silver-transform hashes
+// rejected rows, keyed by the same PHI_HASH_KEY.
+//
+// With -local, a directory stands in for S3 (one subdirectory per bucket),
+// as written by silver-transform -local.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"claim-management-system/pipeline/integrity"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+func main() {
+	lakeBucket := flag.String("lake-bucket", os.Getenv("LAKE_BUCKET"), "lake bucket holding the silver tables")
+	local := flag.String("local", "", "directory standing in for S3")
+	silverRun := flag.String("silver-run", "", "check only the files of this silver run (default every row)")
+	policy := flag.String("policy", "", "rule=action pairs, e.g. claim_coverage_gap=quarantine (actions: flag, quarantine)")
+	format := flag.String("format", "parquet", "partition file format: parquet or csv")
+	id := flag.String("id", "", "run id (default derived from the current time)")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "integrity-check: ", log.LstdFlags)
+	if *lakeBucket == "" {
+		logger.Fatal("-lake-bucket is required")
+	}
+	schemas, err := schema.Builtin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	rules, err := integrity.ParsePolicy(*policy)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	merged := integrity.Policy{}
+	for r, a := range integrity.DefaultPolicy {
+		merged[r] = a
+	}
+	for r, a := range rules {
+		merged[r] = a
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := &integrity.Checker{
+		Schemas: schemas,
+		Bucket:  *lakeBucket,
+		Policy:  merged,
+		PHIKey:  []byte(os.Getenv("PHI_HASH_KEY")),
+		ID:      *id,
+		Logger:  logger,
+	}
+	switch *format {
+	case "parquet":
+		c.Format = silver.Parquet{}
+	case "csv":
+		c.Format = silver.CSV{}
+	default:
+		logger.Fatalf("-format %q: need parquet or csv", *format)
+	}
+	if *local != "" {
+		c.Objects = objectstore.NewDir(*local)
+	} else {
+		sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+		c.Objects = objectstore.NewS3Store(s3.New(sess))
+	}
+
+	var m *silver.Manifest
+	if *silverRun != "" {
+		if m, err = silver.ReadManifest(ctx, c.Objects, *lakeBucket, *silverRun); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	rep, err := c.Run(ctx, m)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+// Command redshift-load loads the tables of a gold run into Redshift through
+// the Data API and prints the load report:
+//
+//	redshift-load -lake-bucket claim-dev-lake -gold-run gold-20251203T060000Z \
+//	    -workgroup claim-dev -database claims \
+//	    -iam-role arn:aws:iam::123456789012:role/role-claim-redshift
+//
+// Exactly one of -cluster (provisioned, with -db-user or -secret-arn) and
+// -workgroup (serverless) is required.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"claim-management-system/pipeline/gold"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/redshift"
+	"claim-management-system/pipeline/schema"
+)
+
+func main() {
+	lakeBucket := flag.String("lake-bucket", os.Getenv("LAKE_BUCKET"), "lake bucket holding the gold tables")
+	goldRun := flag.String("gold-run", "", "id of the gold run to load")
+	cluster := flag.String("cluster", "", "provisioned cluster identifier")
+	workgroup := flag.String("workgroup", "", "serverless workgroup name")
+	database := flag.String("database", "", "Redshift database")
+	dbUser := flag.String("db-user", "", "database user (provisioned only)")
+	secretArn := flag.String("secret-arn", "", "Secrets Manager secret with the database credentials")
+	iamRole := flag.String("iam-role", "", "ARN of the role Redshift assumes to read the lake bucket")
+	target := flag.String("schema", redshift.DefaultSchema, "Redshift schema of the gold tables")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "redshift-load: ", log.LstdFlags)
+	switch {
+	case *lakeBucket == "":
+		logger.Fatal("-lake-bucket is required")
+	case *goldRun == "":
+		logger.Fatal("-gold-run is required")
+	case *database == "":
+		logger.Fatal("-database is required")
+	case *iamRole == "":
+		logger.Fatal("-iam-role is required")
+	}
+	schemas, err := schema.Builtin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	m, err := gold.ReadManifest(ctx, objectstore.NewS3Store(s3.New(sess)), *lakeBucket, *goldRun)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	l := &redshift.Loader{
+		API:               redshiftdataapiservice.New(sess),
+		ClusterIdentifier: *cluster,
+		WorkgroupName:     *workgroup,
+		Database:          *database,
+		DbUser:            *dbUser,
+		SecretArn:         *secretArn,
+		Schema:            *target,
+		Schemas:           schemas,
+		Bucket:            *lakeBucket,
+		IAMRole:           *iamRole,
+		Logger:            logger,
+	}
+	rep, err := l.Load(ctx, m)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		logger.Fatal(err)
+	}
+}
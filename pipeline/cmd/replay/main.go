@@ -0,0 +1,140 @@
+// Command replay re-enqueues raw files onto the S3 event queue so the
+// ingestion worker processes them again. Files are selected by metadata query
+// flags, by explicit keys given as arguments, or both:
+//
+//	replay -file-type 837 -source clearinghouse -from 2025-11-01 -to 2025-11-08 -reason "parser fix"
+//	replay -bucket claim-dev-raw raw/837/.../file_001.csv s3://claim-dev-raw/raw/835/.../remit.csv
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/queue"
+	"claim-management-system/pipeline/replay"
+)
+
+func main() {
+	table := flag.String("table", os.Getenv("METADATA_TABLE"), "file-metadata DynamoDB table name")
+	queueURL := flag.String("queue-url", os.Getenv("QUEUE_URL"), "URL of the claim-<env>-s3-events queue")
+	bucket := flag.String("bucket", os.Getenv("RAW_BUCKET"), "raw bucket for key arguments without an s3:// prefix")
+	from := flag.String("from", "", "select files ingested (updated, with only -status) at or after this date (YYYY-MM-DD or RFC 3339)")
+	to := flag.String("to", "", "select files ingested (updated, with only -status) before this date (exclusive)")
+	fileType := flag.String("file-type", "", "select files of this type (834, 835, 837)")
+	source := flag.String("source", "", "select files from this source system")
+	status := flag.String("status", "", "select files in this status, e.g. FAILED")
+	rate := flag.Float64("rate", 10, "maximum events sent per second (0 for no limit)")
+	max := flag.Int("max", 0, "stop after this many files (0 for no limit)")
+	dryRun := flag.Bool("dry-run", false, "list the files that would be replayed without sending anything")
+	reason := flag.String("reason", "", "reason recorded on each REPLAYED transition")
+	id := flag.String("id", "", "replay id (default derived from the current time)")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "replay: ", 0)
+	if *table == "" {
+		logger.Fatal("-table is required")
+	}
+	if *queueURL == "" && !*dryRun {
+		logger.Fatal("-queue-url is required")
+	}
+
+	sel, err := selection(*from, *to, *fileType, *source, *status, *bucket, flag.Args())
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	r := &replay.Replayer{
+		Metadata:    metadata.NewDynamoStore(dynamodb.New(sess), *table),
+		Objects:     objectstore.NewS3Store(s3.New(sess)),
+		Queue:       queue.NewSQSQueue(sqs.New(sess), *queueURL),
+		ID:          *id,
+		RequestedBy: requester(),
+		Reason:      *reason,
+		Rate:        *rate,
+		Max:         *max,
+		DryRun:      *dryRun,
+		Logger:      logger,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	res, runErr := r.Run(ctx, sel)
+	if res != nil {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACTION\tFILE ID\tSTATUS\tKEY\tREASON")
+		for _, it := range res.Items {
+			fmt.Fprintf(tw, "%s\t%s\t%s\ts3://%s/%s\t%s\n", it.Action, it.FileID, it.Status, it.Bucket, it.Key, it.Reason)
+		}
+		tw.Flush()
+		logger.Printf("%s: %d enqueued, %d skipped", res.ID, res.Enqueued, res.Skipped)
+	}
+	if runErr != nil {
+		logger.Fatal(runErr)
+	}
+}
+
+func selection(from, to, fileType, source, status, bucket string, args []string) (replay.Selection, error) {
+	var sel replay.Selection
+	if from != "" || to != "" || fileType != "" || source != "" || status != "" {
+		q := &metadata.Query{FileType: fileType, SourceSystem: source, Status: metadata.Status(strings.ToUpper(status))}
+		var err error
+		if q.From, err = parseDate(from); err != nil {
+			return sel, fmt.Errorf("-from: %w", err)
+		}
+		if q.To, err = parseDate(to); err != nil {
+			return sel, fmt.Errorf("-to: %w", err)
+		}
+		sel.Query = q
+	}
+
+	for _, arg := range args {
+		ref := replay.ObjectRef{Bucket: bucket, Key: arg}
+		if rest, ok := strings.CutPrefix(arg, "s3://"); ok {
+			ref.Bucket, ref.Key, _ = strings.Cut(rest, "/")
+		}
+		if ref.Bucket == "" || ref.Key == "" {
+			return sel, fmt.Errorf("%q: need s3://bucket/key or -bucket", arg)
+		}
+		sel.Objects = append(sel.Objects, ref)
+	}
+
+	if sel.Query == nil && len(sel.Objects) == 0 {
+		return sel, fmt.Errorf("select files with query flags or key arguments")
+	}
+	return sel, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func requester() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
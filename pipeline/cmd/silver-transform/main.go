@@ -0,0 +1,157 @@
+// Command silver-transform loads the raw CSVs marked VALIDATED in the
+// file-metadata table into the silver tables of the lake bucket and prints
+// the run manifest:
+//
+//	silver-transform -table claim-dev-file-metadata -lake-bucket claim-dev-lake
+//
+// PHI in the raw values of rejected rows is hashed with HMAC-SHA256 keyed by
+// the PHI_HASH_KEY environment variable, or with plain SHA-256 if it is unset.
+//
+// With -glue, each table written is synced to the Glue catalog and its new
+// partitions are added.
+//
+// With -local, a directory stands in for S3 (one subdirectory per bucket) and
+// the metadata table is kept in memory: the raw files given as arguments are
+// ingested and validated first, then transformed:
+//
+//	silver-transform -local ./lake -lake-bucket claim-dev-lake s3://claim-dev-raw/raw/837/year=2025/month=11/day=21/source=clearinghouse/claims.csv
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/ingest"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/parquet"
+	"claim-management-system/pipeline/s3event"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+	"claim-management-system/pipeline/validation"
+)
+
+func main() {
+	table := flag.String("table", os.Getenv("METADATA_TABLE"), "file-metadata DynamoDB table name")
+	lakeBucket := flag.String("lake-bucket", os.Getenv("LAKE_BUCKET"), "lake bucket the silver tables and manifests are written to")
+	local := flag.String("local", "", "directory standing in for S3; raw files given as arguments are ingested into an in-memory metadata table first")
+	maxFiles := flag.Int("max-files", 0, "stop after this many files (0 for no limit)")
+	id := flag.String("id", "", "run id (default derived from the current time)")
+	registerGlue := flag.Bool("glue", false, "sync the tables written to the Glue catalog and add their new partitions")
+	format := flag.String("format", "parquet", "partition file format: parquet or csv")
+	groupRows := flag.Int("row-group-rows", 0, "end Parquet row groups after this many rows (0 for no limit)")
+	groupBytes := flag.Int64("row-group-bytes", parquet.DefaultRowGroupBytes, "end Parquet row groups after about this many uncompressed bytes")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "silver-transform: ", log.LstdFlags)
+	if *lakeBucket == "" {
+		logger.Fatal("-lake-bucket is required")
+	}
+	schemas, err := schema.Builtin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	t := &silver.Transformer{
+		Schemas:  schemas,
+		Bucket:   *lakeBucket,
+		ID:       *id,
+		MaxFiles: *maxFiles,
+		Logger:   logger,
+		PHIKey:   []byte(os.Getenv("PHI_HASH_KEY")),
+	}
+	switch *format {
+	case "parquet":
+		t.Format = silver.Parquet{Options: parquet.Options{RowGroupRows: *groupRows, RowGroupBytes: *groupBytes}}
+	case "csv":
+		t.Format = silver.CSV{}
+	default:
+		logger.Fatalf("-format %q: need parquet or csv", *format)
+	}
+	if *local != "" {
+		if flag.NArg() == 0 {
+			logger.Fatal("-local needs raw files as arguments")
+		}
+		if *registerGlue {
+			logger.Fatal("-glue cannot be used with -local")
+		}
+		store := metadata.NewMemoryStore()
+		t.Objects, t.Metadata, t.Locks = objectstore.NewDir(*local), store, store
+		if err := ingestLocal(ctx, t.Objects, t.Metadata, schemas, flag.Args(), logger); err != nil {
+			logger.Fatal(err)
+		}
+	} else {
+		if *table == "" {
+			logger.Fatal("-table is required")
+		}
+		if flag.NArg() > 0 {
+			logger.Fatal("raw files may only be given with -local")
+		}
+		sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+		t.Objects = objectstore.NewS3Store(s3.New(sess))
+		store := metadata.NewDynamoStore(dynamodb.New(sess), *table)
+		t.Metadata, t.Locks = store, store
+		if *registerGlue {
+			// Silver runs only touch lake tables; no raw bucket is needed.
+			t.Catalog = catalog.New(glue.New(sess), schemas, "", *lakeBucket)
+		}
+	}
+
+	m, err := t.Run(ctx)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// ingestLocal runs the ingestion worker, with CSV validation, over the raw
+// objects named by args.
+func ingestLocal(ctx context.Context, objects objectstore.Store, store metadata.Store, schemas *schema.Registry, args []string, logger *log.Logger) error {
+	w := &ingest.Worker{
+		Objects:  objects,
+		Metadata: store,
+		Validator: &validation.Validator{
+			Objects: objects,
+			Checker: validation.Checker{Schemas: schemas, Budget: validation.Budget{MaxErrorRows: -1, MaxErrorRate: 0.01}},
+		},
+		Logger: logger,
+	}
+	for _, arg := range args {
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(arg, "s3://"), "/")
+		if !strings.HasPrefix(arg, "s3://") || key == "" {
+			return fmt.Errorf("%q: need s3://bucket/key", arg)
+		}
+		obj, err := objects.Head(ctx, bucket, key, "")
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		rec, err := w.Ingest(ctx, s3event.Record{Bucket: bucket, Key: key, VersionID: obj.VersionID, Size: obj.Size})
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		if rec != nil && rec.Status != metadata.StatusValidated {
+			logger.Printf("%s: %s, not loaded", arg, rec.Status)
+		}
+	}
+	return nil
+}
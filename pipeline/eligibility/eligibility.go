@@ -0,0 +1,210 @@
+// Package eligibility derives coverage from 834 enrollment events. Fold
+// applies the add, change, terminate and reinstate events of tbl_coverage to
+// the coverage of each member under each plan and keeps the result as
+// non-overlapping intervals, which answer whether a member was covered on a
+// date and yield the member-month rows of fact_eligibility.
+package eligibility
+
+import (
+	"sort"
+	"time"
+
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// 834 maintenance codes (INS03).
+const (
+	Change    = "001"
+	Add       = "021"
+	Terminate = "024"
+	Reinstate = "025"
+	Audit     = "030"
+)
+
+// Event is one coverage event: a tbl_coverage row.
+type Event struct {
+	Member, Plan, Code string
+	Effective, Start   time.Time
+	// End is zero when the event states no coverage_end.
+	End time.Time
+	// Ingested, FileID and Row order the events as they were stated.
+	Ingested time.Time
+	FileID   string
+	Row      int64
+}
+
+// Events returns the events of rows of tbl_coverage schema s.
+func Events(s *schema.Schema, rows []silver.Row) []Event {
+	at := func(name string) int { return s.Index(name) }
+	member, plan, code := at("member_id"), at("plan_code"), at("maintenance_code")
+	effective, start, end := at("maintenance_effective"), at("coverage_start"), at("coverage_end")
+	ingested, fileID, sourceRow := at(silver.ColIngestedAt), at(silver.ColFileID), at(silver.ColSourceRow)
+
+	events := make([]Event, len(rows))
+	for i, row := range rows {
+		ev := Event{
+			Member:    row[member].(string),
+			Plan:      row[plan].(string),
+			Code:      row[code].(string),
+			Effective: row[effective].(time.Time),
+			Start:     row[start].(time.Time),
+			Ingested:  row[ingested].(time.Time),
+			FileID:    row[fileID].(string),
+			Row:       row[sourceRow].(int64),
+		}
+		if d, ok := row[end].(time.Time); ok {
+			ev.End = d
+		}
+		events[i] = ev
+	}
+	return events
+}
+
+// Interval is a period of coverage, both ends inclusive; a zero To is open.
+type Interval struct {
+	From, To time.Time
+}
+
+// Contains reports whether d falls in i.
+func (i Interval) Contains(d time.Time) bool {
+	return !d.Before(i.From) && (i.To.IsZero() || !d.After(i.To))
+}
+
+// Enrollment is the coverage of each member under each plan_code.
+type Enrollment struct {
+	plans map[string]map[string][]Interval
+}
+
+// Fold returns the enrollment events state, whatever their order in the
+// slice. Each member's events for a plan are applied in the order they were
+// stated: ingest time, then maintenance_effective, then file and row. An
+// event replaces the coverage from its coverage_start on with the period it
+// states; a period ending before it starts cancels coverage from then on. A
+// termination (024) ends all coverage after its coverage_end, or after its
+// maintenance_effective when it has none, so a late-arriving retroactive
+// termination still ends coverage. Coverage before an event's period is
+// kept, so a reinstatement after a gap leaves the gap.
+func Fold(events []Event) *Enrollment {
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch {
+		case !a.Ingested.Equal(b.Ingested):
+			return a.Ingested.Before(b.Ingested)
+		case !a.Effective.Equal(b.Effective):
+			return a.Effective.Before(b.Effective)
+		case a.FileID != b.FileID:
+			return a.FileID < b.FileID
+		}
+		return a.Row < b.Row
+	})
+
+	e := &Enrollment{plans: map[string]map[string][]Interval{}}
+	for _, ev := range sorted {
+		plans := e.plans[ev.Member]
+		if plans == nil {
+			plans = map[string][]Interval{}
+			e.plans[ev.Member] = plans
+		}
+		plans[ev.Plan] = apply(plans[ev.Plan], ev)
+	}
+	for _, plans := range e.plans {
+		for plan, intervals := range plans {
+			plans[plan] = merge(intervals)
+		}
+	}
+	return e
+}
+
+// apply returns intervals after ev. Intervals stay in order and never
+// overlap: each event first cuts what it replaces.
+func apply(intervals []Interval, ev Event) []Interval {
+	if ev.Code == Terminate {
+		last := ev.End
+		if last.IsZero() {
+			last = ev.Effective
+		}
+		return cut(intervals, last)
+	}
+	intervals = cut(intervals, ev.Start.AddDate(0, 0, -1))
+	if ev.End.IsZero() || !ev.End.Before(ev.Start) {
+		intervals = append(intervals, Interval{From: ev.Start, To: ev.End})
+	}
+	return intervals
+}
+
+// cut returns the coverage of intervals up to and including last.
+func cut(intervals []Interval, last time.Time) []Interval {
+	var out []Interval
+	for _, i := range intervals {
+		if i.From.After(last) {
+			continue
+		}
+		if i.To.IsZero() || i.To.After(last) {
+			i.To = last
+		}
+		out = append(out, i)
+	}
+	return out
+}
+
+// merge joins ordered intervals that meet: a change that restates the
+// period from its start on leaves the coverage unbroken.
+func merge(intervals []Interval) []Interval {
+	var out []Interval
+	for _, i := range intervals {
+		if n := len(out); n > 0 && !out[n-1].To.IsZero() && !out[n-1].To.AddDate(0, 0, 1).Before(i.From) {
+			out[n-1].To = i.To
+			continue
+		}
+		out = append(out, i)
+	}
+	return out
+}
+
+// Members returns the members with any event, sorted.
+func (e *Enrollment) Members() []string {
+	return sortedKeys(e.plans)
+}
+
+// Plans returns the plans member has events for, sorted.
+func (e *Enrollment) Plans(member string) []string {
+	return sortedKeys(e.plans[member])
+}
+
+// Intervals returns the coverage of member under plan, in order. Intervals
+// neither overlap nor meet; only the last may be open.
+func (e *Enrollment) Intervals(member, plan string) []Interval {
+	return e.plans[member][plan]
+}
+
+// Covered reports whether member was covered on d under plan.
+func (e *Enrollment) Covered(member string, d time.Time, plan string) bool {
+	for _, i := range e.plans[member][plan] {
+		if i.Contains(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// CoveredAny reports whether member was covered on d under any plan.
+func (e *Enrollment) CoveredAny(member string, d time.Time) bool {
+	for plan := range e.plans[member] {
+		if e.Covered(member, d, plan) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
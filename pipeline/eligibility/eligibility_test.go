@@ -0,0 +1,313 @@
+package eligibility
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// coverageRows parses tbl_coverage rows written as
+// member,code,effective,plan,start,end,ingested-day.
+func coverageRows(t *testing.T, s *schema.Schema, lines ...string) []silver.Row {
+	t.Helper()
+	var rows []silver.Row
+	for n, line := range lines {
+		f := strings.Split(line, ",")
+		require.Len(t, f, 7, line)
+		values := map[string]string{
+			"member_id": f[0], "subscriber_id": f[0], "maintenance_code": f[1], "maintenance_effective": f[2],
+			"plan_code": f[3], "coverage_start": f[4], "coverage_end": f[5],
+			"file_id": "834-" + f[6], "source_row": strconv.Itoa(n + 1), "ingested_at": "2025-11-" + f[6] + "T08:00:00Z",
+		}
+		row := make(silver.Row, len(s.Columns))
+		for i, c := range s.Columns {
+			v, err := c.Parse(values[c.Name])
+			require.NoError(t, err, "%s: %s", line, c.Name)
+			row[i] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func day(s string) time.Time {
+	d, err := schema.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestFold(t *testing.T) {
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	s, err := r.Latest("tbl_coverage")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name      string
+		events    []string
+		covered   []string
+		gaps      []string
+		intervals []Interval
+	}{
+		{
+			name:      "open enrollment",
+			events:    []string{"M1,021,2025-01-01,HMO,2025-01-01,,01"},
+			covered:   []string{"2025-01-01", "2030-06-30"},
+			gaps:      []string{"2024-12-31"},
+			intervals: []Interval{{From: day("2025-01-01")}},
+		},
+		{
+			name: "terminated",
+			events: []string{
+				"M1,021,2025-01-01,HMO,2025-01-01,,01",
+				"M1,024,2025-06-30,HMO,2025-01-01,2025-06-30,02",
+			},
+			covered:   []string{"2025-06-30"},
+			gaps:      []string{"2025-07-01", "2025-11-20"},
+			intervals: []Interval{{From: day("2025-01-01"), To: day("2025-06-30")}},
+		},
+		{
+			name: "terminated without an end date",
+			events: []string{
+				"M1,021,2025-01-01,HMO,2025-01-01,,01",
+				"M1,024,2025-03-15,HMO,2025-01-01,,02",
+			},
+			covered: []string{"2025-03-15"},
+			gaps:    []string{"2025-03-16"},
+		},
+		{
+			name: "reinstated after a gap",
+			events: []string{
+				"M1,021,2025-01-01,HMO,2025-01-01,,01",
+				"M1,024,2025-03-31,HMO,2025-01-01,2025-03-31,02",
+				"M1,025,2025-06-01,HMO,2025-06-01,,03",
+			},
+			covered:   []string{"2025-02-01", "2025-06-01", "2025-12-31"},
+			gaps:      []string{"2025-04-01", "2025-05-31"},
+			intervals: []Interval{{From: day("2025-01-01"), To: day("2025-03-31")}, {From: day("2025-06-01")}},
+		},
+		{
+			name: "late-arriving termination",
+			events: []string{
+				"M1,021,2025-01-01,HMO,2025-01-01,,01",
+				"M1,001,2025-09-01,HMO,2025-01-01,,02",
+				// Stated last, effective earliest: it still ends coverage.
+				"M1,024,2025-04-30,HMO,2025-01-01,2025-04-30,03",
+			},
+			covered: []string{"2025-04-30"},
+			gaps:    []string{"2025-05-01", "2025-09-01"},
+		},
+		{
+			name: "change narrows the period",
+			events: []string{
+				"M1,021,2025-01-01,HMO,2025-01-01,,01",
+				"M1,001,2025-02-01,HMO,2025-02-01,2025-08-31,02",
+			},
+			covered:   []string{"2025-01-15", "2025-08-31"},
+			gaps:      []string{"2025-09-01"},
+			intervals: []Interval{{From: day("2025-01-01"), To: day("2025-08-31")}},
+		},
+		{
+			name: "cancelled before it started",
+			events: []string{
+				"M1,021,2025-01-01,HMO,2025-03-01,,01",
+				"M1,001,2025-02-15,HMO,2025-03-01,2025-02-28,02",
+			},
+			gaps: []string{"2025-03-01", "2025-02-28"},
+		},
+		{
+			name: "any plan covers",
+			events: []string{
+				"M1,021,2025-01-01,HMO,2025-01-01,2025-06-30,01",
+				"M1,021,2025-07-01,DEN,2025-07-01,,01",
+			},
+			covered: []string{"2025-06-30", "2025-07-01"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := Fold(Events(s, coverageRows(t, s, tc.events...)))
+			for _, d := range tc.covered {
+				assert.True(t, e.CoveredAny("M1", day(d)), "covered on %s", d)
+			}
+			for _, d := range tc.gaps {
+				assert.False(t, e.CoveredAny("M1", day(d)), "not covered on %s", d)
+			}
+			assert.False(t, e.CoveredAny("M2", day("2025-06-01")), "Unknown members are never covered")
+			if tc.intervals != nil {
+				assert.Equal(t, tc.intervals, e.Intervals("M1", "HMO"))
+			}
+		})
+	}
+}
+
+func TestCoveredByPlan(t *testing.T) {
+	e := Fold([]Event{
+		{Member: "M1", Plan: "HMO", Code: Add, Start: day("2025-01-01"), End: day("2025-06-30")},
+		{Member: "M1", Plan: "DEN", Code: Add, Start: day("2025-07-01"), Ingested: day("2025-01-02")},
+	})
+	assert.True(t, e.Covered("M1", day("2025-06-30"), "HMO"))
+	assert.False(t, e.Covered("M1", day("2025-06-30"), "DEN"))
+	assert.False(t, e.Covered("M1", day("2025-07-01"), "HMO"))
+	assert.True(t, e.Covered("M1", day("2025-07-01"), "DEN"))
+	assert.Equal(t, []string{"DEN", "HMO"}, e.Plans("M1"))
+	assert.Equal(t, []string{"M1"}, e.Members())
+}
+
+// Property tests: random event histories of one member under two plans,
+// checked against a day-by-day oracle.
+
+var (
+	base  = day("2025-01-01")
+	codes = []string{Add, Change, Terminate, Reinstate, Audit}
+	plans = []string{"HMO", "DEN"}
+)
+
+// window is the span of days events fall in; horizon the last day the
+// oracle fills open coverage to.
+const window, horizon = 120, 400
+
+// history is a random event history. Events are stated on distinct days, in
+// slice order.
+type history []Event
+
+func (history) Generate(r *rand.Rand, size int) reflect.Value {
+	n := 1 + r.Intn(size+1)
+	h := make(history, n)
+	for i := range h {
+		ev := Event{
+			Member:    "M1",
+			Plan:      plans[r.Intn(len(plans))],
+			Code:      codes[r.Intn(len(codes))],
+			Effective: base.AddDate(0, 0, r.Intn(window)),
+			Start:     base.AddDate(0, 0, r.Intn(window)),
+			Ingested:  base.AddDate(0, 0, i),
+			FileID:    fmt.Sprintf("834-%03d", i),
+			Row:       1,
+		}
+		if r.Intn(3) > 0 {
+			// Sometimes before Start: a cancellation.
+			ev.End = ev.Start.AddDate(0, 0, r.Intn(window)-10)
+		}
+		h[i] = ev
+	}
+	return reflect.ValueOf(h)
+}
+
+// oracle returns the days covered under each plan after h, by day offset
+// from base.
+func oracle(h history) map[string]map[int]bool {
+	days := map[string]map[int]bool{}
+	offset := func(d time.Time) int { return int(d.Sub(base).Hours() / 24) }
+	for _, ev := range h {
+		covered := days[ev.Plan]
+		if covered == nil {
+			covered = map[int]bool{}
+			days[ev.Plan] = covered
+		}
+		if ev.Code == Terminate {
+			last := ev.End
+			if last.IsZero() {
+				last = ev.Effective
+			}
+			for d := range covered {
+				if d > offset(last) {
+					delete(covered, d)
+				}
+			}
+			continue
+		}
+		for d := range covered {
+			if d >= offset(ev.Start) {
+				delete(covered, d)
+			}
+		}
+		end := horizon
+		if !ev.End.IsZero() {
+			end = offset(ev.End)
+		}
+		for d := offset(ev.Start); d <= end; d++ {
+			covered[d] = true
+		}
+	}
+	return days
+}
+
+func shuffled(h history, seed int64) []Event {
+	events := append([]Event(nil), h...)
+	rand.New(rand.NewSource(seed)).Shuffle(len(events), func(i, j int) { events[i], events[j] = events[j], events[i] })
+	return events
+}
+
+func TestFoldMatchesOracle(t *testing.T) {
+	property := func(h history, seed int64) bool {
+		e := Fold(shuffled(h, seed))
+		want := oracle(h)
+		for _, plan := range plans {
+			for d := -10; d <= horizon; d++ {
+				if e.Covered("M1", base.AddDate(0, 0, d), plan) != want[plan][d] {
+					t.Logf("%s on day %d: got %v, want %v; intervals %v", plan, d, !want[plan][d], want[plan][d], e.Intervals("M1", plan))
+					return false
+				}
+			}
+		}
+		return true
+	}
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 500}))
+}
+
+func TestFoldIntervalsAreDisjoint(t *testing.T) {
+	property := func(h history) bool {
+		e := Fold(h)
+		for _, plan := range e.Plans("M1") {
+			intervals := e.Intervals("M1", plan)
+			for i, iv := range intervals {
+				if !iv.To.IsZero() && iv.To.Before(iv.From) {
+					return false
+				}
+				if i == 0 {
+					continue
+				}
+				prev := intervals[i-1]
+				// Open or meeting intervals would have been merged.
+				if prev.To.IsZero() || !prev.To.AddDate(0, 0, 1).Before(iv.From) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 500}))
+}
+
+func TestFoldIsIdempotent(t *testing.T) {
+	property := func(h history) bool {
+		// Every event restated right after itself, as a resent file would.
+		var twice []Event
+		for _, ev := range h {
+			again := ev
+			again.FileID += "-resent"
+			twice = append(twice, ev, again)
+		}
+		once, doubled := Fold(h), Fold(twice)
+		for _, plan := range plans {
+			if !reflect.DeepEqual(once.Intervals("M1", plan), doubled.Intervals("M1", plan)) {
+				return false
+			}
+		}
+		return true
+	}
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 500}))
+}
@@ -0,0 +1,56 @@
+package eligibility
+
+import "time"
+
+// Month is the coverage of a member under a plan in one calendar month.
+type Month struct {
+	Member, Plan string
+	// Month is the first day of the month.
+	Month time.Time
+	// From and To are the first and last covered days of the month; Days
+	// counts the covered days, which a gap may leave fewer than To-From+1.
+	From, To time.Time
+	Days     int
+	// Full is set when every day of the month is covered.
+	Full bool
+}
+
+// Months returns one Month per member, plan and calendar month with any
+// coverage, through the month of through: open coverage is counted to the
+// end of that month and later coverage is left out. Months are ordered by
+// member, plan and month.
+func (e *Enrollment) Months(through time.Time) []Month {
+	end := monthOf(through).AddDate(0, 1, -1)
+	var out []Month
+	for _, member := range e.Members() {
+		for _, plan := range e.Plans(member) {
+			var cur *Month
+			for _, i := range e.Intervals(member, plan) {
+				to := i.To
+				if to.IsZero() || to.After(end) {
+					to = end
+				}
+				for from := i.From; !from.After(to); {
+					month := monthOf(from)
+					last := month.AddDate(0, 1, -1)
+					if last.After(to) {
+						last = to
+					}
+					if cur == nil || !cur.Month.Equal(month) {
+						out = append(out, Month{Member: member, Plan: plan, Month: month, From: from})
+						cur = &out[len(out)-1]
+					}
+					cur.To = last
+					cur.Days += int(last.Sub(from).Hours()/24) + 1
+					cur.Full = cur.Days == month.AddDate(0, 1, -1).Day()
+					from = last.AddDate(0, 0, 1)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func monthOf(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
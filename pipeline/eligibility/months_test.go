@@ -0,0 +1,52 @@
+package eligibility
+
+import (
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonths(t *testing.T) {
+	e := Fold([]Event{
+		{Member: "M1", Plan: "HMO", Code: Add, Start: day("2025-01-15")},
+		{Member: "M1", Plan: "HMO", Code: Terminate, Effective: day("2025-03-10"), End: day("2025-03-10"), Ingested: day("2025-01-02")},
+		{Member: "M1", Plan: "HMO", Code: Reinstate, Start: day("2025-03-20"), Ingested: day("2025-01-03")},
+		{Member: "M2", Plan: "DEN", Code: Add, Start: day("2025-04-01"), End: day("2025-04-30")},
+		// Coverage after the month of through is left out.
+		{Member: "M3", Plan: "HMO", Code: Add, Start: day("2025-06-01")},
+	})
+	assert.Equal(t, []Month{
+		{Member: "M1", Plan: "HMO", Month: day("2025-01-01"), From: day("2025-01-15"), To: day("2025-01-31"), Days: 17},
+		{Member: "M1", Plan: "HMO", Month: day("2025-02-01"), From: day("2025-02-01"), To: day("2025-02-28"), Days: 28, Full: true},
+		{Member: "M1", Plan: "HMO", Month: day("2025-03-01"), From: day("2025-03-01"), To: day("2025-03-31"), Days: 22},
+		{Member: "M1", Plan: "HMO", Month: day("2025-04-01"), From: day("2025-04-01"), To: day("2025-04-30"), Days: 30, Full: true},
+		{Member: "M1", Plan: "HMO", Month: day("2025-05-01"), From: day("2025-05-01"), To: day("2025-05-31"), Days: 31, Full: true},
+		{Member: "M2", Plan: "DEN", Month: day("2025-04-01"), From: day("2025-04-01"), To: day("2025-04-30"), Days: 30, Full: true},
+	}, e.Months(day("2025-05-12")), "The March gap leaves 22 covered days")
+}
+
+func TestMonthsCountCoveredDays(t *testing.T) {
+	through := base.AddDate(0, 0, window+40)
+	end := monthOf(through).AddDate(0, 1, -1)
+	property := func(h history) bool {
+		e := Fold(h)
+		days := 0
+		for _, m := range e.Months(through) {
+			if m.Days < 1 || m.Days > m.To.Day()-m.From.Day()+1 || m.Full != (m.Days == m.Month.AddDate(0, 1, -1).Day()) {
+				return false
+			}
+			days += m.Days
+		}
+		covered := 0
+		for _, plan := range plans {
+			for d := base.AddDate(0, 0, -10); !d.After(end); d = d.AddDate(0, 0, 1) {
+				if e.Covered("M1", d, plan) {
+					covered++
+				}
+			}
+		}
+		return days == covered
+	}
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 300}))
+}
@@ -0,0 +1,168 @@
+// Package errreport writes the JSON error report of an X12 file: every
+// envelope, segment and element problem with its exact location, so
+// operators can fix a rejected file without re-reading it by hand. Reports
+// go to the lake's silver/_error/ path and are linked from the file-metadata
+// record.
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/x12"
+	"claim-management-system/pipeline/x12/x837"
+)
+
+// DefaultPrefix is where reports are written, keyed by file_id.
+const DefaultPrefix = "silver/_error/x12/"
+
+// MetaFileID is the user metadata key naming the reported file.
+const MetaFileID = "file-id"
+
+// Report lists the problems of one file. Segment and element diagnostics
+// are collected for 837 transaction sets; envelope and syntax errors for
+// every transaction set.
+type Report struct {
+	FileID      string `json:"file_id"`
+	Source      string `json:"source"`
+	VersionID   string `json:"version_id,omitempty"`
+	FileType    string `json:"file_type,omitempty"`
+	GeneratedAt string `json:"generated_at"`
+	// SyntaxError is the tokenizer error that stopped reading, if any;
+	// nothing after it was checked.
+	SyntaxError string            `json:"syntax_error,omitempty"`
+	Envelope    []*EnvelopeError  `json:"envelope_errors"`
+	Diagnostics []*x12.Diagnostic `json:"diagnostics"`
+}
+
+// EnvelopeError is the JSON form of an x12.EnvelopeError.
+type EnvelopeError struct {
+	// Level is interchange, group or transaction set; Code is TA105, AK905
+	// or IK502 accordingly.
+	Level         string `json:"level"`
+	Code          string `json:"code"`
+	ControlNumber string `json:"control_number,omitempty"`
+	// SegmentID is empty for an envelope left open at the end of input.
+	SegmentID string `json:"segment_id,omitempty"`
+	Index     int    `json:"segment_index,omitempty"`
+	Position  int    `json:"segment_position,omitempty"`
+	Offset    int64  `json:"offset,omitempty"`
+	Msg       string `json:"message"`
+}
+
+// Count returns the number of problems in the report.
+func (r *Report) Count() int {
+	n := len(r.Envelope) + len(r.Diagnostics)
+	if r.SyntaxError != "" {
+		n++
+	}
+	return n
+}
+
+// Check reads an X12 stream to the end and collects its problems. Only I/O
+// errors are returned; a file that cannot be tokenized gets a report with
+// SyntaxError set.
+func Check(r io.Reader) (*Report, error) {
+	rep := &Report{Envelope: []*EnvelopeError{}, Diagnostics: []*x12.Diagnostic{}}
+	p := x837.NewParser(r)
+	var err error
+	for err == nil {
+		_, err = p.Next()
+	}
+	var syntax *x12.SyntaxError
+	switch {
+	case err == io.EOF:
+	case errors.As(err, &syntax), errors.Is(err, x12.ErrNoInterchange):
+		rep.SyntaxError = err.Error()
+	default:
+		return nil, err
+	}
+	for _, e := range p.Errors() {
+		rep.Envelope = append(rep.Envelope, &EnvelopeError{
+			Level:         e.Level.String(),
+			Code:          e.Code,
+			ControlNumber: e.ControlNumber,
+			SegmentID:     e.SegmentID,
+			Index:         e.Pos.Index,
+			Position:      e.Pos.TxIndex,
+			Offset:        e.Pos.Offset,
+			Msg:           e.Msg,
+		})
+	}
+	rep.Diagnostics = append(rep.Diagnostics, p.Diagnostics()...)
+	return rep, nil
+}
+
+// Reporter writes the error reports of ingested X12 files.
+type Reporter struct {
+	Objects objectstore.Store
+	// Bucket and Prefix locate the reports; an empty Prefix means
+	// DefaultPrefix.
+	Bucket string
+	Prefix string
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Key returns the object key of the report for fileID.
+func (r *Reporter) Key(fileID string) string {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return prefix + fileID + ".json"
+}
+
+// Report checks the X12 object of rec and, if it has problems, writes their
+// report and sets ErrorReport and ErrorCount on rec; the caller stores rec.
+// A clean file gets no report, and rec's error attributes are cleared.
+func (r *Reporter) Report(ctx context.Context, rec *metadata.FileRecord) (*Report, error) {
+	obj, err := r.Objects.Get(ctx, rec.Bucket, rec.Key, rec.VersionID)
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s for error report: %w", rec.Bucket, rec.Key, err)
+	}
+	defer obj.Body.Close()
+
+	rep, err := Check(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s for error report: %w", rec.Bucket, rec.Key, err)
+	}
+	rep.FileID = rec.FileID
+	rep.Source = fmt.Sprintf("s3://%s/%s", rec.Bucket, rec.Key)
+	rep.VersionID = rec.VersionID
+	rep.FileType = rec.FileType
+	rep.GeneratedAt = metadata.FormatTime(r.now())
+
+	rec.ErrorReport, rec.ErrorCount = "", rep.Count()
+	if rec.ErrorCount == 0 {
+		return rep, nil
+	}
+	body, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	key := r.Key(rec.FileID)
+	_, err = r.Objects.Put(ctx, r.Bucket, key, bytes.NewReader(body), objectstore.PutOptions{
+		ContentType: "application/json",
+		Metadata:    map[string]string{MetaFileID: rec.FileID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("write error report s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	rec.ErrorReport = fmt.Sprintf("s3://%s/%s", r.Bucket, key)
+	return rep, nil
+}
+
+func (r *Reporter) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
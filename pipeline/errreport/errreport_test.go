@@ -0,0 +1,135 @@
+package errreport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/x12"
+)
+
+const (
+	rawBucket  = "claim-dev-raw"
+	lakeBucket = "claim-dev-lake"
+)
+
+var reportTime = time.Date(2025, 11, 22, 8, 30, 0, 0, time.UTC)
+
+func putRaw(t *testing.T, objects objectstore.Store, key, body string) *metadata.FileRecord {
+	t.Helper()
+	version, err := objects.Put(context.Background(), rawBucket, key, strings.NewReader(body), objectstore.PutOptions{})
+	require.NoError(t, err)
+	return &metadata.FileRecord{
+		FileID:    metadata.NewFileID(rawBucket, key, version),
+		FileType:  "837",
+		Bucket:    rawBucket,
+		Key:       key,
+		VersionID: version,
+	}
+}
+
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestReportWritesDiagnostics(t *testing.T) {
+	objects := objectstore.NewDir(t.TempDir())
+	r := &Reporter{Objects: objects, Bucket: lakeBucket, Now: func() time.Time { return reportTime }}
+	ctx := context.Background()
+
+	body := strings.Replace(fixture(t, "837p.x12"), "DTP*431*D8*20251101~", "DTP*431*D8*20251301~", 1)
+	body = strings.Replace(body, "SE*37*0001~", "SE*36*0001~", 1)
+	rec := putRaw(t, objects, "raw/837/source=clearinghouse/claims.x12", body)
+
+	rep, err := r.Report(ctx, rec)
+	require.NoError(t, err)
+	require.Len(t, rep.Diagnostics, 1)
+	require.Len(t, rep.Envelope, 1)
+	assert.Equal(t, 2, rec.ErrorCount)
+	key := "silver/_error/x12/" + rec.FileID + ".json"
+	assert.Equal(t, "s3://"+lakeBucket+"/"+key, rec.ErrorReport)
+
+	obj, err := objects.Get(ctx, lakeBucket, key, "")
+	require.NoError(t, err)
+	defer obj.Body.Close()
+	assert.Equal(t, rec.FileID, obj.Metadata[MetaFileID])
+	data, err := io.ReadAll(obj.Body)
+	require.NoError(t, err)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, rec.FileID, got["file_id"])
+	assert.Equal(t, "s3://claim-dev-raw/raw/837/source=clearinghouse/claims.x12", got["source"])
+	assert.Equal(t, "2025-11-22T08:30:00.000Z", got["generated_at"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"level":            "transaction set",
+		"code":             x12.IK5SegmentCountMismatch,
+		"control_number":   "0001",
+		"segment_id":       "SE",
+		"segment_index":    float64(39),
+		"segment_position": float64(37),
+		"offset":           float64(strings.Index(body, "SE*36")),
+		"message":          `SE01 "36" does not match 37 segments`,
+	}}, got["envelope_errors"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"interchange_control_number": "000000101",
+		"group_control_number":       "101",
+		"transaction_control_number": "0001",
+		"segment_id":                 "DTP",
+		"segment_index":              float64(22),
+		"segment_position":           float64(20),
+		"offset":                     float64(strings.Index(body, "DTP*431")),
+		"loop":                       "2000A/2000B/2300",
+		"segment_code":               x12.IK3DataElementErrors,
+		"element":                    float64(3),
+		"element_code":               x12.IK4InvalidDate,
+		"value":                      "20251301",
+		"message":                    `"20251301" is not a valid D8 date`,
+		"snippet":                    "DTP*431*D8*20251301",
+	}}, got["diagnostics"])
+}
+
+func TestReportCleanFile(t *testing.T) {
+	objects := objectstore.NewDir(t.TempDir())
+	r := &Reporter{Objects: objects, Bucket: lakeBucket, Prefix: "errors/"}
+	rec := putRaw(t, objects, "raw/835/remit.x12", fixture(t, "835.x12"))
+	rec.ErrorReport, rec.ErrorCount = "s3://stale", 3
+
+	rep, err := r.Report(context.Background(), rec)
+	require.NoError(t, err)
+	assert.Zero(t, rep.Count())
+	assert.Empty(t, rec.ErrorReport, "A clean reprocessed file drops its old report link")
+	assert.Zero(t, rec.ErrorCount)
+	_, err = objects.Head(context.Background(), lakeBucket, "errors/"+rec.FileID+".json", "")
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+}
+
+func TestCheckSyntaxError(t *testing.T) {
+	rep, err := Check(strings.NewReader("ISA*00*bad~"))
+	require.NoError(t, err, "Untokenizable input is reported, not returned")
+	assert.Contains(t, rep.SyntaxError, "truncated ISA header")
+	assert.Equal(t, 1, rep.Count())
+
+	rep, err = Check(strings.NewReader("GS*HC~"))
+	require.NoError(t, err)
+	assert.Equal(t, x12.ErrNoInterchange.Error(), rep.SyntaxError)
+}
+
+func TestReportMissingObject(t *testing.T) {
+	r := &Reporter{Objects: objectstore.NewDir(t.TempDir()), Bucket: lakeBucket}
+	_, err := r.Report(context.Background(), &metadata.FileRecord{FileID: "f", Bucket: rawBucket, Key: "raw/837/gone.x12"})
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+}
@@ -0,0 +1,16 @@
+module claim-management-system/pipeline
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go v1.50.24
+	github.com/golang/snappy v0.0.4
+	github.com/stretchr/testify v1.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
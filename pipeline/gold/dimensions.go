@@ -0,0 +1,306 @@
+package gold
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// Dimension tables.
+const (
+	DimMember    = "dim_member"
+	DimProvider  = "dim_provider"
+	DimPlan      = "dim_plan"
+	DimDiagnosis = "dim_diagnosis"
+	DimFacility  = "dim_facility"
+)
+
+// Silver tables the dimensions are built from.
+const (
+	silverMember  = "tbl_member"
+	silverCover   = "tbl_coverage"
+	silverHeader  = "tbl_claim_header"
+	silverLine    = "tbl_claim_line"
+	silverPayment = "tbl_payment"
+)
+
+// unknownFrom is the effective_from of the unknown row of an SCD2
+// dimension, before any service date.
+var unknownFrom = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// memberAttrs are the columns of dim_member tracked for SCD2, taken from
+// tbl_member columns of the same name.
+var memberAttrs = []string{
+	"subscriber_id", "relationship", "last_name", "first_name", "middle_name", "birth_date", "gender",
+	"address_line_1", "address_line_2", "city", "state", "zip", "group_number",
+}
+
+// table is the rows of a silver table.
+type table struct {
+	schema *schema.Schema
+	rows   []silver.Row
+}
+
+// get returns the value of column name of row.
+func (t *table) get(row silver.Row, name string) interface{} {
+	return row[t.schema.Index(name)]
+}
+
+func (t *table) str(row silver.Row, name string) string {
+	v, _ := t.get(row, name).(string)
+	return v
+}
+
+func (t *table) date(row silver.Row, name string) time.Time {
+	v, _ := t.get(row, name).(time.Time)
+	return v
+}
+
+// stated returns the rows in the order they were stated: ingest time, then
+// file and source row, as silver decides which row is newer.
+func (t *table) stated() []silver.Row {
+	rows := make([]silver.Row, len(t.rows))
+	copy(rows, t.rows)
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		ta, tb := t.date(a, silver.ColIngestedAt), t.date(b, silver.ColIngestedAt)
+		if !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		if fa, fb := t.str(a, silver.ColFileID), t.str(b, silver.ColFileID); fa != fb {
+			return fa < fb
+		}
+		return t.get(a, silver.ColSourceRow).(int64) < t.get(b, silver.ColSourceRow).(int64)
+	})
+	return rows
+}
+
+// rowOf returns the row of s holding values by column name; columns values
+// lacks are null.
+func rowOf(s *schema.Schema, values map[string]interface{}) silver.Row {
+	row := make(silver.Row, len(s.Columns))
+	for i, c := range s.Columns {
+		row[i] = values[c.Name]
+	}
+	return row
+}
+
+// scd2Row returns the dim row of version v, with the attribute columns
+// named attrs.
+func scd2Row(s *schema.Schema, natural, keyCol string, attrs []string, v *version) silver.Row {
+	values := map[string]interface{}{
+		keyCol:           SurrogateKey(s.Name, v.id, v.from.Format(schema.DateLayout)),
+		natural:          v.id,
+		"effective_from": v.from,
+		"effective_to":   v.to,
+		"is_current":     v.current(),
+	}
+	if v.fileID != "" {
+		values[silver.ColFileID] = v.fileID
+	}
+	for i, name := range attrs {
+		values[name] = v.attrs[i]
+	}
+	return rowOf(s, values)
+}
+
+// buildMember returns dim_member: one version per change of a member's
+// demographics, as of each 834 event's maintenance_effective.
+func buildMember(s *schema.Schema, members *table) []silver.Row {
+	var changes []change
+	for seq, row := range members.stated() {
+		attrs := make([]interface{}, len(memberAttrs))
+		for i, name := range memberAttrs {
+			attrs[i] = members.get(row, name)
+		}
+		changes = append(changes, change{
+			id:     members.str(row, "member_id"),
+			from:   members.date(row, "maintenance_effective"),
+			seq:    seq,
+			attrs:  attrs,
+			fileID: members.str(row, silver.ColFileID),
+		})
+	}
+	rows := []silver.Row{rowOf(s, map[string]interface{}{
+		"member_key": UnknownKey, "member_id": Unknown,
+		"effective_from": unknownFrom, "effective_to": OpenEnd, "is_current": true,
+	})}
+	for _, v := range history(changes) {
+		rows = append(rows, scd2Row(s, "member_id", "member_key", memberAttrs, v))
+	}
+	return rows
+}
+
+// providerAttrs are the columns of dim_provider tracked for SCD2.
+var providerAttrs = []string{"is_billing", "is_rendering", "is_payee", "facility_type"}
+
+// sighting is one claim or payment naming a provider.
+type sighting struct {
+	npi  string
+	date time.Time
+	seq  int
+	// role is the index of the role flag in providerAttrs.
+	role         int
+	facilityType string
+}
+
+// buildProvider returns dim_provider: one version per change of the roles
+// claims and payments have named an NPI in so far, and of the facility type
+// of the latest claim it billed, as of their service and payment dates.
+func buildProvider(s *schema.Schema, headers, payments *table) []silver.Row {
+	var seen []sighting
+	for _, row := range headers.stated() {
+		date := headers.date(row, "service_from")
+		seen = append(seen, sighting{npi: headers.str(row, "billing_provider_npi"), date: date, seq: len(seen), role: 0,
+			facilityType: headers.str(row, "facility_type")})
+		if npi := headers.str(row, "rendering_provider_npi"); npi != "" {
+			seen = append(seen, sighting{npi: npi, date: date, seq: len(seen), role: 1})
+		}
+	}
+	for _, row := range payments.stated() {
+		if npi := payments.str(row, "payee_npi"); npi != "" {
+			seen = append(seen, sighting{npi: npi, date: payments.date(row, "payment_date"), seq: len(seen), role: 2})
+		}
+	}
+	sort.SliceStable(seen, func(i, j int) bool {
+		a, b := seen[i], seen[j]
+		switch {
+		case a.npi != b.npi:
+			return a.npi < b.npi
+		case !a.date.Equal(b.date):
+			return a.date.Before(b.date)
+		}
+		return a.seq < b.seq
+	})
+
+	// Each sighting states the provider's roles and facility type so far.
+	var changes []change
+	var state []interface{}
+	for i, sg := range seen {
+		if i == 0 || seen[i-1].npi != sg.npi {
+			state = []interface{}{false, false, false, nil}
+		}
+		state[sg.role] = true
+		if sg.facilityType != "" {
+			state[3] = sg.facilityType
+		}
+		attrs := make([]interface{}, len(state))
+		copy(attrs, state)
+		changes = append(changes, change{id: sg.npi, from: sg.date, seq: sg.seq, attrs: attrs})
+	}
+	rows := []silver.Row{rowOf(s, map[string]interface{}{
+		"provider_key": UnknownKey, "npi": Unknown, "is_billing": false, "is_rendering": false, "is_payee": false,
+		"effective_from": unknownFrom, "effective_to": OpenEnd, "is_current": true,
+	})}
+	for _, v := range history(changes) {
+		rows = append(rows, scd2Row(s, "npi", "provider_key", providerAttrs, v))
+	}
+	return rows
+}
+
+// buildPlan returns dim_plan: each plan_code of tbl_coverage with the
+// insurance line stated last and its earliest coverage_start.
+func buildPlan(s *schema.Schema, coverages *table) []silver.Row {
+	type plan struct {
+		line  string
+		start time.Time
+	}
+	plans := map[string]*plan{}
+	for _, row := range coverages.stated() {
+		code := coverages.str(row, "plan_code")
+		p := plans[code]
+		if p == nil {
+			p = &plan{}
+			plans[code] = p
+		}
+		if line := coverages.str(row, "insurance_line"); line != "" {
+			p.line = line
+		}
+		if start := coverages.date(row, "coverage_start"); p.start.IsZero() || start.Before(p.start) {
+			p.start = start
+		}
+	}
+	rows := []silver.Row{rowOf(s, map[string]interface{}{"plan_key": UnknownKey, "plan_code": Unknown})}
+	for _, code := range sortedKeys(plans) {
+		p := plans[code]
+		values := map[string]interface{}{"plan_key": SurrogateKey(s.Name, code), "plan_code": code, "first_coverage_start": p.start}
+		if p.line != "" {
+			values["insurance_line"] = p.line
+		}
+		rows = append(rows, rowOf(s, values))
+	}
+	return rows
+}
+
+// Diagnoses returns the ICD-10 codes of a claim: its principal diagnosis and
+// the |-separated other_diagnoses, in order, without repeats.
+func Diagnoses(principal, others string) []string {
+	var codes []string
+	seen := map[string]bool{}
+	for _, code := range append([]string{principal}, strings.Split(others, "|")...) {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// buildDiagnosis returns dim_diagnosis: every code a claim header reports.
+func buildDiagnosis(s *schema.Schema, headers *table) []silver.Row {
+	codes := map[string]bool{}
+	for _, row := range headers.rows {
+		for _, code := range Diagnoses(headers.str(row, "principal_diagnosis"), headers.str(row, "other_diagnoses")) {
+			codes[code] = true
+		}
+	}
+	rows := []silver.Row{rowOf(s, map[string]interface{}{"diagnosis_key": UnknownKey, "diagnosis_code": Unknown})}
+	for _, code := range sortedKeys(codes) {
+		display, category := code, code
+		if len(code) > 3 {
+			display, category = code[:3]+"."+code[3:], code[:3]
+		}
+		rows = append(rows, rowOf(s, map[string]interface{}{
+			"diagnosis_key": SurrogateKey(s.Name, code), "diagnosis_code": code,
+			"code_system": "ICD-10-CM", "display_code": display, "category": category,
+		}))
+	}
+	return rows
+}
+
+// buildFacility returns dim_facility: every claim_type and facility_type a
+// claim header reports.
+func buildFacility(s *schema.Schema, headers *table) []silver.Row {
+	types := map[string][2]string{}
+	for _, row := range headers.rows {
+		claimType, facility := headers.str(row, "claim_type"), headers.str(row, "facility_type")
+		if facility != "" {
+			types[claimType+"\x1f"+facility] = [2]string{claimType, facility}
+		}
+	}
+	rows := []silver.Row{rowOf(s, map[string]interface{}{"facility_key": UnknownKey, "facility_type": Unknown})}
+	for _, k := range sortedKeys(types) {
+		t := types[k]
+		codeSet := "place_of_service"
+		if t[0] == "I" {
+			codeSet = "facility_type"
+		}
+		rows = append(rows, rowOf(s, map[string]interface{}{
+			"facility_key": SurrogateKey(s.Name, t[0], t[1]), "claim_type": t[0], "facility_type": t[1], "code_set": codeSet,
+		}))
+	}
+	return rows
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
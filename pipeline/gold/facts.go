@@ -0,0 +1,382 @@
+package gold
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/eligibility"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// Fact tables.
+const (
+	FactClaim       = "fact_claim"
+	FactClaimLine   = "fact_claim_line"
+	FactPayment     = "fact_payment"
+	FactEligibility = "fact_eligibility"
+)
+
+// Claim frequency codes (CLM05-3).
+const (
+	frequencyOriginal = "1"
+	frequencyVoid     = "8"
+)
+
+// money is the zero of decimal(12,2).
+var money = schema.Fixed{Scale: 2}
+
+// keys resolves natural keys to the surrogate keys of a dimension. The
+// unknown row is never returned; a natural key the dimension lacks resolves
+// to UnknownKey.
+type keys struct {
+	// versions holds the versions of an SCD2 dimension by natural key, in
+	// date order; plain holds the key of each row of any other dimension.
+	versions map[string][]keyed
+	plain    map[string]int64
+}
+
+type keyed struct {
+	from, to time.Time
+	key      int64
+}
+
+// scd2Keys indexes the rows of SCD2 dimension d by column natural.
+func scd2Keys(d *table, natural string) *keys {
+	k := &keys{versions: map[string][]keyed{}}
+	for _, row := range d.rows {
+		id := d.str(row, natural)
+		if id == Unknown {
+			continue
+		}
+		k.versions[id] = append(k.versions[id], keyed{
+			from: d.date(row, "effective_from"),
+			to:   d.date(row, "effective_to"),
+			key:  d.get(row, d.schema.Key[0]).(int64),
+		})
+	}
+	for _, vs := range k.versions {
+		sort.Slice(vs, func(i, j int) bool { return vs[i].from.Before(vs[j].from) })
+	}
+	return k
+}
+
+// plainKeys indexes the rows of dimension d by the columns natural.
+func plainKeys(d *table, natural ...string) *keys {
+	k := &keys{plain: map[string]int64{}}
+	for _, row := range d.rows {
+		key := d.get(row, d.schema.Key[0]).(int64)
+		if key == UnknownKey {
+			continue
+		}
+		parts := make([]string, len(natural))
+		for i, name := range natural {
+			parts[i] = d.str(row, name)
+		}
+		k.plain[joinKey(parts...)] = key
+	}
+	return k
+}
+
+// asOf returns the key of the version of id in effect on d.
+func (k *keys) asOf(id string, d time.Time) int64 {
+	for _, v := range k.versions[id] {
+		if !d.Before(v.from) && !d.After(v.to) {
+			return v.key
+		}
+	}
+	return UnknownKey
+}
+
+// of returns the key of the row with natural key parts.
+func (k *keys) of(parts ...string) int64 {
+	return k.plain[joinKey(parts...)]
+}
+
+// optional returns nil when the natural key is empty, else key.
+func optional(natural string, key func() int64) interface{} {
+	if natural == "" {
+		return nil
+	}
+	return key()
+}
+
+func joinKey(parts ...string) string {
+	return strings.Join(parts, "\x1f")
+}
+
+// claimVersion is one tbl_claim_header row and what the facts derive from
+// it.
+type claimVersion struct {
+	row       silver.Row
+	key       int64
+	frequency string
+	// current is the version in effect of its claim; supersededBy the key
+	// of that version for the others.
+	current      bool
+	supersededBy int64
+	lines        int64
+	lineBilled   schema.Fixed
+}
+
+// claimPayments sums the payments of one claim_id.
+type claimPayments struct {
+	count             int64
+	paid, responsible schema.Fixed
+}
+
+// facts builds the fact tables from the silver sources and the dimensions
+// built in the same run.
+type facts struct {
+	headers, lines, payments *table
+
+	member, provider, plan, diagnosis, facility *keys
+
+	// enrolled is the coverage folded from tbl_coverage; through the last
+	// month fact_eligibility counts open coverage to.
+	enrolled *eligibility.Enrollment
+	through  time.Time
+
+	// versions are the claim versions by claim_id and frequency; current
+	// the version in effect by claim_id.
+	versions map[string]*claimVersion
+	current  map[string]*claimVersion
+	paid     map[string]*claimPayments
+}
+
+func newFacts(src, dims map[string]*table, through time.Time) *facts {
+	cover := src[silverCover]
+	f := &facts{
+		headers:   src[silverHeader],
+		lines:     src[silverLine],
+		payments:  src[silverPayment],
+		member:    scd2Keys(dims[DimMember], "member_id"),
+		provider:  scd2Keys(dims[DimProvider], "npi"),
+		plan:      plainKeys(dims[DimPlan], "plan_code"),
+		diagnosis: plainKeys(dims[DimDiagnosis], "diagnosis_code"),
+		facility:  plainKeys(dims[DimFacility], "claim_type", "facility_type"),
+		versions:  map[string]*claimVersion{},
+		current:   map[string]*claimVersion{},
+		paid:      map[string]*claimPayments{},
+		enrolled:  eligibility.Fold(eligibility.Events(cover.schema, cover.rows)),
+		through:   through,
+	}
+
+	// A replacement or void supersedes every version stated before it; an
+	// original never supersedes one, so a late original stays superseded.
+	for _, row := range f.headers.stated() {
+		id, freq := f.headers.str(row, "claim_id"), f.headers.str(row, "claim_frequency")
+		v := &claimVersion{row: row, key: SurrogateKey(FactClaim, id, freq), frequency: freq, lineBilled: money}
+		f.versions[joinKey(id, freq)] = v
+		if cur := f.current[id]; cur == nil || freq != frequencyOriginal {
+			f.current[id] = v
+		}
+	}
+	for _, v := range f.versions {
+		id := f.headers.str(v.row, "claim_id")
+		if cur := f.current[id]; cur == v {
+			v.current = true
+		} else {
+			v.supersededBy = cur.key
+		}
+	}
+	for _, row := range f.lines.rows {
+		if v := f.versions[joinKey(f.lines.str(row, "claim_id"), f.lines.str(row, "claim_frequency"))]; v != nil {
+			v.lines++
+			v.lineBilled = v.lineBilled.Add(f.lines.get(row, "line_charge").(schema.Fixed))
+		}
+	}
+	for _, row := range f.payments.rows {
+		id := f.payments.str(row, "claim_id")
+		p := f.paid[id]
+		if p == nil {
+			p = &claimPayments{paid: money, responsible: money}
+			f.paid[id] = p
+		}
+		p.count++
+		p.paid = p.paid.Add(f.payments.get(row, "paid_amount").(schema.Fixed))
+		if pr, ok := f.payments.get(row, "patient_responsibility").(schema.Fixed); ok {
+			p.responsible = p.responsible.Add(pr)
+		}
+	}
+	return f
+}
+
+// headerKeys returns the dimension keys of claim header row, as of its
+// service_from.
+func (f *facts) headerKeys(row silver.Row) map[string]interface{} {
+	h := f.headers
+	from := h.date(row, "service_from")
+	return map[string]interface{}{
+		"member_key":           f.member.asOf(h.str(row, "member_id"), from),
+		"billing_provider_key": f.provider.asOf(h.str(row, "billing_provider_npi"), from),
+		"rendering_provider_key": optional(h.str(row, "rendering_provider_npi"), func() int64 {
+			return f.provider.asOf(h.str(row, "rendering_provider_npi"), from)
+		}),
+	}
+}
+
+// factClaim returns fact_claim: one row per claim version.
+func (f *facts) factClaim(s *schema.Schema) []silver.Row {
+	h := f.headers
+	var rows []silver.Row
+	for _, k := range sortedKeys(f.versions) {
+		v := f.versions[k]
+		id := h.str(v.row, "claim_id")
+		values := f.headerKeys(v.row)
+		for name, value := range map[string]interface{}{
+			"claim_key":          v.key,
+			"claim_id":           id,
+			"claim_frequency":    v.frequency,
+			"is_current":         v.current,
+			"is_void":            v.frequency == frequencyVoid,
+			"claim_type":         h.get(v.row, "claim_type"),
+			"diagnosis_key":      UnknownKey,
+			"payer_id":           h.get(v.row, "payer_id"),
+			"service_from":       h.get(v.row, "service_from"),
+			"service_to":         h.get(v.row, "service_to"),
+			"line_count":         v.lines,
+			"billed_amount":      h.get(v.row, "total_charge"),
+			"line_billed_amount": v.lineBilled,
+			"payment_count":      int64(0),
+			silver.ColFileID:     h.get(v.row, silver.ColFileID),
+			silver.ColIngestedAt: h.get(v.row, silver.ColIngestedAt),
+		} {
+			values[name] = value
+		}
+		if codes := Diagnoses(h.str(v.row, "principal_diagnosis"), ""); len(codes) > 0 {
+			values["diagnosis_key"] = f.diagnosis.of(codes[0])
+		}
+		values["facility_key"] = optional(h.str(v.row, "facility_type"), func() int64 {
+			return f.facility.of(h.str(v.row, "claim_type"), h.str(v.row, "facility_type"))
+		})
+		if !v.current {
+			values["superseded_by_key"] = v.supersededBy
+		} else if p := f.paid[id]; p != nil {
+			values["payment_count"] = p.count
+			values["paid_amount"] = p.paid
+			values["patient_responsibility"] = p.responsible
+			values["allowed_amount"] = p.paid.Add(p.responsible)
+		}
+		rows = append(rows, rowOf(s, values))
+	}
+	return rows
+}
+
+// factClaimLine returns fact_claim_line: one row per line of tbl_claim_line.
+// A line without a header keeps the claim_key its header would have but
+// resolves to no dimension row and is never current.
+func (f *facts) factClaimLine(s *schema.Schema) []silver.Row {
+	l := f.lines
+	rows := make([]silver.Row, 0, len(l.rows))
+	for _, row := range l.rows {
+		id, freq, line := l.str(row, "claim_id"), l.str(row, "claim_frequency"), l.get(row, "line_number").(int64)
+		values := map[string]interface{}{
+			"member_key":           UnknownKey,
+			"billing_provider_key": UnknownKey,
+			"is_current":           false,
+		}
+		if v := f.versions[joinKey(id, freq)]; v != nil {
+			values = f.headerKeys(v.row)
+			values["is_current"] = v.current
+		}
+		for name, value := range map[string]interface{}{
+			"claim_line_key":     SurrogateKey(FactClaimLine, id, freq, strconv.FormatInt(line, 10)),
+			"claim_key":          SurrogateKey(FactClaim, id, freq),
+			"claim_id":           id,
+			"claim_frequency":    freq,
+			"line_number":        line,
+			"procedure_code":     l.get(row, "procedure_code"),
+			"modifiers":          l.get(row, "modifiers"),
+			"revenue_code":       l.get(row, "revenue_code"),
+			"units":              l.get(row, "units"),
+			"billed_amount":      l.get(row, "line_charge"),
+			"service_date":       l.get(row, "service_date"),
+			silver.ColFileID:     l.get(row, silver.ColFileID),
+			silver.ColIngestedAt: l.get(row, silver.ColIngestedAt),
+		} {
+			values[name] = value
+		}
+		rows = append(rows, rowOf(s, values))
+	}
+	sortRows(s, rows, "claim_id", "claim_frequency", "line_number")
+	return rows
+}
+
+// factPayment returns fact_payment: one row per row of tbl_payment, joined to
+// the current version of its claim.
+func (f *facts) factPayment(s *schema.Schema) []silver.Row {
+	p := f.payments
+	rows := make([]silver.Row, 0, len(p.rows))
+	for _, row := range p.rows {
+		paid := p.get(row, "paid_amount").(schema.Fixed)
+		allowed := paid
+		if pr, ok := p.get(row, "patient_responsibility").(schema.Fixed); ok {
+			allowed = allowed.Add(pr)
+		}
+		claimKey, member, date := UnknownKey, p.str(row, "member_id"), p.date(row, "payment_date")
+		if v := f.current[p.str(row, "claim_id")]; v != nil {
+			claimKey, member, date = v.key, f.headers.str(v.row, "member_id"), f.headers.date(v.row, "service_from")
+		}
+		values := map[string]interface{}{
+			"payment_key": SurrogateKey(FactPayment, p.str(row, "payer_id"), p.str(row, "trace_number"), p.str(row, "claim_id")),
+			"claim_key":   claimKey,
+			"member_key":  f.member.asOf(member, date),
+			"payee_provider_key": optional(p.str(row, "payee_npi"), func() int64 {
+				return f.provider.asOf(p.str(row, "payee_npi"), p.date(row, "payment_date"))
+			}),
+			"allowed_amount": allowed,
+		}
+		for _, name := range []string{
+			"payer_id", "trace_number", "claim_id", "payer_claim_id", "claim_status", "payment_method", "payment_date",
+			"billed_amount", "paid_amount", "patient_responsibility", silver.ColFileID, silver.ColIngestedAt,
+		} {
+			values[name] = p.get(row, name)
+		}
+		rows = append(rows, rowOf(s, values))
+	}
+	sortRows(s, rows, "payer_id", "trace_number", "claim_id")
+	return rows
+}
+
+// factEligibility returns fact_eligibility: one row per member, plan and
+// month with coverage, through the month of f.through.
+func (f *facts) factEligibility(s *schema.Schema) []silver.Row {
+	var rows []silver.Row
+	for _, m := range f.enrolled.Months(f.through) {
+		month := m.Month.Format(schema.DateLayout)
+		rows = append(rows, rowOf(s, map[string]interface{}{
+			"eligibility_key":   SurrogateKey(FactEligibility, m.Member, m.Plan, month),
+			"member_key":        f.member.asOf(m.Member, m.From),
+			"plan_key":          f.plan.of(m.Plan),
+			"eligibility_month": m.Month,
+			"coverage_from":     m.From,
+			"coverage_to":       m.To,
+			"covered_days":      int64(m.Days),
+			"is_full_month":     m.Full,
+		}))
+	}
+	return rows
+}
+
+// sortRows orders rows of s by the columns named by.
+func sortRows(s *schema.Schema, rows []silver.Row, by ...string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, name := range by {
+			c := s.Index(name)
+			a, b := rows[i][c], rows[j][c]
+			if n, ok := a.(int64); ok {
+				if m := b.(int64); n != m {
+					return n < m
+				}
+				continue
+			}
+			if x, y := s.Columns[c].Type.Format(a), s.Columns[c].Type.Format(b); x != y {
+				return x < y
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,153 @@
+package gold
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addClaimActivity adds to the fixture a replacement of PCN1 with its lines,
+// a void of PCN2, a reversal and repayment of PCN1, a line without a header
+// and a payment of a claim no header has.
+func (f *fixture) addClaimActivity(t *testing.T) {
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=20",
+		"PCN1,1,P,M1,NPI1,NPI2,PAYER1,11,150.00,2025-11-20,,E119,I10|e119|,2,837-a,clearinghouse,1,2025-11-21T10:00:00Z\n"+
+			"PCN1,7,P,M1,NPI1,NPI2,PAYER1,11,140.00,2025-11-20,,E119,,1,837-b,clearinghouse,1,2025-11-28T10:00:00Z\n")
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=25",
+		"PCN2,1,I,M2,NPI1,,PAYER1,13,900.00,2025-11-25,,J44,,1,837-a,clearinghouse,2,2025-11-21T10:00:00Z\n"+
+			"PCN2,8,I,M2,NPI1,,PAYER1,13,900.00,2025-11-25,,J44,,0,837-b,clearinghouse,2,2025-11-28T10:00:00Z\n")
+	f.Put(t, "tbl_claim_line", "year=2025/month=11/day=20",
+		"PCN1,1,1,99213,,,100.00,1,2025-11-20,837-a,clearinghouse,1,2025-11-21T10:00:00Z\n"+
+			"PCN1,1,2,85025,,,50.00,1,2025-11-20,837-a,clearinghouse,1,2025-11-21T10:00:00Z\n"+
+			"PCN1,7,1,99213,25,,140.00,1,2025-11-20,837-b,clearinghouse,1,2025-11-28T10:00:00Z\n")
+	f.Put(t, "tbl_claim_line", "year=2025/month=11/day=21",
+		"PCN9,1,1,99214,,,80.00,1,2025-11-21,837-c,clearinghouse,1,2025-11-29T10:00:00Z\n")
+	f.Put(t, "tbl_payment", "year=2025/month=12/day=05",
+		"TRC2,2025-12-05,ACH,PAYER1,NPI1,PCN1,,22,M1,-150.00,-120.00,-30.00,835-b,payer,1,2025-12-06T09:00:00Z\n"+
+			"TRC2,2025-12-05,ACH,PAYER1,NPI1,PCN1-R,,1,M1,140.00,110.00,30.00,835-b,payer,2,2025-12-06T09:00:00Z\n"+
+			"TRC3,2025-12-05,CHK,PAYER1,NPI1,PCN1,,1,M1,140.00,110.00,30.00,835-b,payer,3,2025-12-06T09:00:00Z\n")
+	f.Put(t, "tbl_payment", "year=2025/month=12/day=06",
+		"TRC4,2025-12-06,ACH,PAYER1,,PCNX,,4,M2,60.00,0.00,,835-c,payer,1,2025-12-07T09:00:00Z\n")
+}
+
+func TestRunBuildsFacts(t *testing.T) {
+	f := newFixture(t)
+	f.addClaimActivity(t)
+	m := f.build(t, "gold-facts")
+
+	require.Len(t, m.Tables, 9)
+	claim, line, payment := m.Tables[5], m.Tables[6], m.Tables[7]
+	assert.Equal(t, &TableResult{
+		Table: "fact_claim", Version: 1, Rows: 4, Current: 2, Source: "tbl_claim_header", SourceRows: 4,
+		Object: "s3://claim-dev-lake/gold/fact_claim/", Partitions: 2,
+	}, claim)
+	assert.Equal(t, &TableResult{
+		Table: "fact_claim_line", Version: 1, Rows: 4, Current: 1, Source: "tbl_claim_line", SourceRows: 4, Unresolved: 1,
+		Object: "s3://claim-dev-lake/gold/fact_claim_line/", Partitions: 2,
+	}, line, "The line without a header is unresolved")
+	assert.Equal(t, &TableResult{
+		Table: "fact_payment", Version: 1, Rows: 5, Source: "tbl_payment", SourceRows: 5, Unresolved: 2,
+		Object: "s3://claim-dev-lake/gold/fact_payment/", Partitions: 3,
+	}, payment, "Payments of PCN1-R and PCNX have no claim")
+
+	pcn1 := SurrogateKey(FactClaim, "PCN1", "7")
+	assert.Equal(t, []string{
+		"PCN1,1,false,false,2,150.00,150.00,,,0," + fmt.Sprint(pcn1),
+		"PCN1,7,true,false,1,140.00,140.00,140.00,110.00,3,",
+		"PCN2,1,false,false,0,900.00,0.00,,,0," + fmt.Sprint(SurrogateKey(FactClaim, "PCN2", "8")),
+		"PCN2,8,true,true,0,900.00,0.00,,,0,",
+	}, f.dim(t, "fact_claim", "claim_id", "claim_frequency", "is_current", "is_void", "line_count", "billed_amount",
+		"line_billed_amount", "allowed_amount", "paid_amount", "payment_count", "superseded_by_key"),
+		"The replacement and the void supersede the originals; payments roll up to the current version")
+
+	m1 := SurrogateKey(DimMember, "M1", "2025-06-01")
+	npi1 := SurrogateKey(DimProvider, "NPI1", "2025-11-20")
+	npi2 := SurrogateKey(DimProvider, "NPI2", "2025-11-20")
+	assert.Equal(t, []string{fmt.Sprintf("%d,%d,%d,%d,%d", pcn1, m1, npi1, npi2, SurrogateKey(DimDiagnosis, "E119"))},
+		f.dim(t, "fact_claim", "claim_key", "member_key", "billing_provider_key", "rendering_provider_key", "diagnosis_key")[1:2],
+		"Keys resolve to the versions in effect on service_from")
+	assert.Equal(t, []string{
+		fmt.Sprintf("%d,,%d", SurrogateKey(DimMember, "M2", "2025-01-01"), SurrogateKey(DimFacility, "I", "13")),
+	}, f.dim(t, "fact_claim", "member_key", "rendering_provider_key", "facility_key")[3:])
+
+	assert.Equal(t, []string{
+		"PCN1,1,1,false,99213,100.00," + fmt.Sprint(m1),
+		"PCN1,1,2,false,85025,50.00," + fmt.Sprint(m1),
+		"PCN1,7,1,true,99213,140.00," + fmt.Sprint(m1),
+		"PCN9,1,1,false,99214,80.00,0",
+	}, f.dim(t, "fact_claim_line", "claim_id", "claim_frequency", "line_number", "is_current", "procedure_code", "billed_amount", "member_key"))
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("TRC1,PCN1,%d,150.00,150.00,120.00", pcn1),
+		fmt.Sprintf("TRC2,PCN1,%d,-150.00,-150.00,-120.00", pcn1),
+		"TRC2,PCN1-R,0,140.00,140.00,110.00",
+		fmt.Sprintf("TRC3,PCN1,%d,140.00,140.00,110.00", pcn1),
+		"TRC4,PCNX,0,60.00,0.00,0.00",
+	}, f.dim(t, "fact_payment", "trace_number", "claim_id", "claim_key", "billed_amount", "allowed_amount", "paid_amount"))
+	assert.Equal(t, []string{
+		fmt.Sprintf("%d,%d", m1, SurrogateKey(DimProvider, "NPI1", "2025-12-01")),
+		fmt.Sprintf("%d,", SurrogateKey(DimMember, "M2", "2025-01-01")),
+	}, f.dim(t, "fact_payment", "member_key", "payee_provider_key")[3:],
+		"A payment without a claim resolves its member as of payment_date")
+}
+
+func TestRunEmptiesStalePartitions(t *testing.T) {
+	f := newFixture(t)
+	f.addClaimActivity(t)
+	f.build(t, "gold-1")
+
+	// Silver moved TRC4 to a corrected payment_date.
+	f.Put(t, "tbl_payment", "year=2025/month=12/day=06", "")
+	f.Put(t, "tbl_payment", "year=2025/month=12/day=08",
+		"TRC4,2025-12-08,ACH,PAYER1,,PCNX,,4,M2,60.00,0.00,,835-c,payer,1,2025-12-09T09:00:00Z\n")
+	m := f.build(t, "gold-2")
+
+	payment := m.Tables[7]
+	assert.Equal(t, 3, payment.Partitions)
+	assert.Equal(t, 1, payment.Emptied)
+	assert.Equal(t, "payment_key,claim_key,member_key,payee_provider_key,payer_id,trace_number,claim_id,payer_claim_id,claim_status,"+
+		"payment_method,payment_date,billed_amount,allowed_amount,paid_amount,patient_responsibility,file_id,ingested_at\n",
+		string(f.Read(t, "gold/fact_payment/year=2025/month=12/day=06/part-00000.csv")))
+	assert.Len(t, f.dim(t, "fact_payment", "payment_key"), 5)
+}
+
+func TestRunDeduplicatesMovedClaims(t *testing.T) {
+	f := newFixture(t)
+	// PCN1 was resent with a corrected service date before silver removed
+	// the copy in its old partition.
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=19",
+		"PCN1,1,P,M1,NPI1,NPI2,PAYER1,11,150.00,2025-11-19,,E119,,1,837-c,clearinghouse,1,2025-11-22T10:00:00Z\n")
+	m := f.build(t, "gold-moved")
+
+	claim := m.Tables[5]
+	assert.Equal(t, int64(2), claim.Rows)
+	assert.Equal(t, int64(2), claim.SourceRows, "PCN1 counts once")
+	assert.Equal(t, []string{"PCN1,2025-11-19,837-c", "PCN2,2025-11-25,837-a"},
+		f.dim(t, "fact_claim", "claim_id", "service_from", "file_id"), "The newest copy wins")
+}
+
+func TestRunBuildsEligibility(t *testing.T) {
+	f := newFixture(t)
+	// M1 terminated mid-October.
+	f.Put(t, "tbl_coverage", "year=2025/month=10/day=15",
+		"M1,S1,024,2025-10-15,G1,HMO1,HLT,FAM,2025-01-01,2025-10-15,834-d,enrollment,1,2025-11-10T08:00:00Z\n")
+	m := f.build(t, "gold-eligibility")
+
+	res := m.Tables[8]
+	assert.Equal(t, &TableResult{
+		Table: "fact_eligibility", Version: 1, Rows: 40, Unresolved: 6,
+		Object: "s3://claim-dev-lake/gold/fact_eligibility/", Partitions: 18,
+	}, res, "Open coverage counts through December, the month of the run; M2's 2024 months predate dim_member")
+
+	hmo1 := fmt.Sprint(SurrogateKey(DimPlan, "HMO1"))
+	rows := f.dim(t, "fact_eligibility", "member_key", "plan_key", "eligibility_month", "coverage_from", "coverage_to", "covered_days", "is_full_month")
+	m1 := func(from string) string { return fmt.Sprint(SurrogateKey(DimMember, "M1", from)) }
+	assert.Contains(t, rows, m1("2025-01-01")+","+hmo1+",2025-05-01,2025-05-01,2025-05-31,31,true")
+	assert.Contains(t, rows, m1("2025-06-01")+","+hmo1+",2025-10-01,2025-10-01,2025-10-15,15,false")
+	assert.Contains(t, rows, "0,"+hmo1+",2024-07-01,2024-07-01,2024-07-31,31,true")
+	for _, row := range rows {
+		assert.NotContains(t, row, m1("2025-06-01")+","+hmo1+",2025-11-01", "M1 is not covered after the termination")
+	}
+}
@@ -0,0 +1,330 @@
+// Package gold builds the gold star schema from the silver tables of the
+// lake bucket. Dimensions carry surrogate keys derived from their natural
+// keys; dim_member and dim_provider keep SCD2 history (effective_from,
+// effective_to, is_current). Each run rebuilds every table from all of
+// silver, so changes that arrive late land in the right version, and writes
+// it under gold/<table>/: a dimension as one file, a fact as one file per
+// date partition. Facts are joined to the dimension versions in effect on
+// their service or payment date; fact_eligibility counts the member-months
+// of the coverage package eligibility folds from 834 events, through the
+// month the run starts in. A manifest records what the run wrote and
+// reconciles the row count of each fact with its silver source.
+package gold
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// ManifestPrefix is where run manifests are written in the lake bucket.
+const ManifestPrefix = "gold/_manifests/"
+
+// Builder builds the gold tables of a lake bucket. Runs must not overlap:
+// each rewrites every table.
+type Builder struct {
+	Objects objectstore.Store
+	Schemas *schema.Registry
+	// Bucket is the lake bucket silver is read from and gold written to.
+	Bucket string
+	// Format encodes the silver files read and the gold files written; nil
+	// means Parquet with default options.
+	Format silver.Format
+	// Catalog, if set, syncs each gold table to Glue before it is written
+	// and adds the partitions of each fact. The catalog declares Parquet, so
+	// it needs the Parquet format.
+	Catalog *catalog.Catalog
+	// ID names the run in its manifest; Run derives one from the clock when
+	// empty.
+	ID string
+
+	Logger *log.Logger
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Manifest records what one builder run wrote.
+type Manifest struct {
+	RunID      string         `json:"run_id"`
+	StartedAt  string         `json:"started_at"`
+	FinishedAt string         `json:"finished_at"`
+	Format     string         `json:"format"`
+	Tables     []*TableResult `json:"tables"`
+}
+
+// ReadManifest reads the manifest of run id from the lake bucket.
+func ReadManifest(ctx context.Context, objects objectstore.Store, bucket, id string) (*Manifest, error) {
+	key := ManifestPrefix + id + ".json"
+	obj, err := objects.Get(ctx, bucket, key, "")
+	if err != nil {
+		return nil, fmt.Errorf("read manifest s3://%s/%s: %w", bucket, key, err)
+	}
+	defer obj.Body.Close()
+	m := &Manifest{}
+	if err := json.NewDecoder(obj.Body).Decode(m); err != nil {
+		return nil, fmt.Errorf("decode manifest s3://%s/%s: %w", bucket, key, err)
+	}
+	return m, nil
+}
+
+// TableResult is one gold table a run wrote.
+type TableResult struct {
+	Table   string `json:"table"`
+	Version int    `json:"version"`
+	// Rows counts the rows written, the unknown row included; Current the
+	// current versions of an SCD2 dimension or claim.
+	Rows    int64 `json:"rows"`
+	Current int64 `json:"current,omitempty"`
+	// Source and SourceRows are the silver table a fact is built from and
+	// its row count, which Rows must equal. Unresolved counts fact rows
+	// joined to the unknown row of a dimension.
+	Source     string `json:"source,omitempty"`
+	SourceRows int64  `json:"source_rows,omitempty"`
+	Unresolved int64  `json:"unresolved,omitempty"`
+	// Object is the file of a dimension, or the prefix of a fact's
+	// partitions. VersionID is set for a dimension only.
+	Object    string `json:"object"`
+	VersionID string `json:"version_id,omitempty"`
+	// Partitions counts the partitions of a fact written with rows; Emptied
+	// those of an earlier run left without any.
+	Partitions        int `json:"partitions,omitempty"`
+	Emptied           int `json:"emptied,omitempty"`
+	CatalogPartitions int `json:"catalog_partitions,omitempty"`
+}
+
+// Run builds every dimension, then every fact, and returns the manifest it
+// wrote. Any error stops the run; tables written before it stay.
+func (b *Builder) Run(ctx context.Context) (*Manifest, error) {
+	start := b.now()
+	if b.ID == "" {
+		b.ID = "gold-" + start.UTC().Format("20060102T150405Z")
+	}
+	format := b.format()
+	if b.Catalog != nil && format.Name() != (silver.Parquet{}).Name() {
+		return nil, fmt.Errorf("gold: the Glue catalog declares Parquet, not %s", format.Name())
+	}
+	m := &Manifest{RunID: b.ID, StartedAt: metadata.FormatTime(start), Format: format.Name()}
+
+	src := map[string]*table{}
+	for _, name := range []string{silverMember, silverCover, silverHeader, silverLine, silverPayment} {
+		t, err := b.readSilver(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		src[name] = t
+	}
+	dims := map[string]*table{}
+	for _, d := range []struct {
+		name  string
+		build func(*schema.Schema) []silver.Row
+	}{
+		{DimMember, func(s *schema.Schema) []silver.Row { return buildMember(s, src[silverMember]) }},
+		{DimProvider, func(s *schema.Schema) []silver.Row { return buildProvider(s, src[silverHeader], src[silverPayment]) }},
+		{DimPlan, func(s *schema.Schema) []silver.Row { return buildPlan(s, src[silverCover]) }},
+		{DimDiagnosis, func(s *schema.Schema) []silver.Row { return buildDiagnosis(s, src[silverHeader]) }},
+		{DimFacility, func(s *schema.Schema) []silver.Row { return buildFacility(s, src[silverHeader]) }},
+	} {
+		s, err := b.Schemas.Latest(d.name)
+		if err != nil {
+			return nil, err
+		}
+		dims[d.name] = &table{schema: s, rows: d.build(s)}
+		res, err := b.write(ctx, s, dims[d.name].rows)
+		if err != nil {
+			return nil, err
+		}
+		m.Tables = append(m.Tables, res)
+	}
+
+	f := newFacts(src, dims, start)
+	for _, d := range []struct {
+		name, source string
+		build        func(*schema.Schema) []silver.Row
+	}{
+		{FactClaim, silverHeader, f.factClaim},
+		{FactClaimLine, silverLine, f.factClaimLine},
+		{FactPayment, silverPayment, f.factPayment},
+	} {
+		s, err := b.Schemas.Latest(d.name)
+		if err != nil {
+			return nil, err
+		}
+		rows, source := d.build(s), int64(len(src[d.source].rows))
+		if int64(len(rows)) != source {
+			return nil, fmt.Errorf("gold: %s has %d rows but %s has %d", s.Name, len(rows), d.source, source)
+		}
+		res, err := b.write(ctx, s, rows)
+		if err != nil {
+			return nil, err
+		}
+		res.Source, res.SourceRows = d.source, source
+		m.Tables = append(m.Tables, res)
+	}
+	// Member-months have no one source row each: nothing to reconcile.
+	s, err := b.Schemas.Latest(FactEligibility)
+	if err != nil {
+		return nil, err
+	}
+	res, err := b.write(ctx, s, f.factEligibility(s))
+	if err != nil {
+		return nil, err
+	}
+	m.Tables = append(m.Tables, res)
+
+	key := ManifestPrefix + b.ID + ".json"
+	m.FinishedAt = metadata.FormatTime(b.now())
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.Objects.Put(ctx, b.Bucket, key, bytes.NewReader(body), objectstore.PutOptions{ContentType: "application/json"}); err != nil {
+		return nil, fmt.Errorf("write manifest s3://%s/%s: %w", b.Bucket, key, err)
+	}
+	b.logf("%s: %d tables", b.ID, len(m.Tables))
+	return m, nil
+}
+
+// readSilver reads every row of the latest version of silver table name,
+// one per key: facts are reconciled against the deduplicated count.
+func (b *Builder) readSilver(ctx context.Context, name string) (*table, error) {
+	s, err := b.Schemas.Latest(name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := silver.ReadTable(ctx, b.Objects, b.Bucket, s, b.format())
+	if err != nil {
+		return nil, err
+	}
+	if rows, err = silver.Deduplicate(s, rows); err != nil {
+		return nil, err
+	}
+	return &table{schema: s, rows: rows}, nil
+}
+
+// write replaces the files of gold table s with rows, after checking that
+// no two rows share a key.
+func (b *Builder) write(ctx context.Context, s *schema.Schema, rows []silver.Row) (*TableResult, error) {
+	res := &TableResult{Table: s.Name, Version: s.Version, Rows: int64(len(rows)), Object: fmt.Sprintf("s3://%s/%s", b.Bucket, s.Location)}
+	keyCol := s.Index(s.Key[0])
+	current := s.Index("is_current")
+	var dimKeys []int
+	for i, c := range s.Columns {
+		if i != keyCol && strings.HasSuffix(c.Name, "_key") {
+			dimKeys = append(dimKeys, i)
+		}
+	}
+	seen := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		k := row[keyCol].(int64)
+		if seen[k] {
+			return nil, fmt.Errorf("gold: %s: surrogate key %d is not unique", s, k)
+		}
+		seen[k] = true
+		if current >= 0 && row[current] == true && k != UnknownKey {
+			res.Current++
+		}
+		for _, i := range dimKeys {
+			if row[i] == UnknownKey {
+				res.Unresolved++
+				break
+			}
+		}
+	}
+
+	if b.Catalog != nil {
+		if _, err := b.Catalog.SyncTable(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+	if s.PartitionBy == "" {
+		key := s.Location + silver.PartFile + b.format().Ext()
+		res.Object = fmt.Sprintf("s3://%s/%s", b.Bucket, key)
+		var err error
+		res.VersionID, err = b.put(ctx, s, key, rows)
+		return res, err
+	}
+	return res, b.writePartitions(ctx, s, rows, res)
+}
+
+// writePartitions writes one file per date partition of rows. A partition
+// an earlier run wrote that holds no rows now is rewritten empty: the store
+// cannot delete.
+func (b *Builder) writePartitions(ctx context.Context, s *schema.Schema, rows []silver.Row, res *TableResult) error {
+	suffix := "/" + silver.PartFile + b.format().Ext()
+	parts := map[string][]silver.Row{}
+	by := s.Index(s.PartitionBy)
+	for _, row := range rows {
+		path := s.PartitionPath(schema.DatePartition(row[by].(time.Time)))
+		parts[path] = append(parts[path], row)
+	}
+	written := sortedKeys(parts)
+	existing, err := b.Objects.List(ctx, b.Bucket, s.Location)
+	if err != nil {
+		return fmt.Errorf("list %s: %w", res.Object, err)
+	}
+	for _, key := range existing {
+		path, ok := strings.CutSuffix(strings.TrimPrefix(key, s.Location), suffix)
+		if _, found := parts[path]; ok && !found {
+			parts[path] = nil
+			res.Emptied++
+		}
+	}
+
+	for _, path := range sortedKeys(parts) {
+		if _, err := b.put(ctx, s, s.Location+path+suffix, parts[path]); err != nil {
+			return err
+		}
+	}
+	res.Partitions = len(written)
+	if b.Catalog != nil {
+		if res.CatalogPartitions, err = b.Catalog.AddPartitions(ctx, s, written); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put encodes rows of s as the object key and returns its version ID.
+func (b *Builder) put(ctx context.Context, s *schema.Schema, key string, rows []silver.Row) (string, error) {
+	format := b.format()
+	object := fmt.Sprintf("s3://%s/%s", b.Bucket, key)
+	var buf bytes.Buffer
+	if err := format.Write(&buf, s, rows); err != nil {
+		return "", fmt.Errorf("encode %s: %w", object, err)
+	}
+	versionID, err := b.Objects.Put(ctx, b.Bucket, key, &buf, objectstore.PutOptions{ContentType: format.ContentType()})
+	if err != nil {
+		return "", fmt.Errorf("write %s: %w", object, err)
+	}
+	return versionID, nil
+}
+
+func (b *Builder) format() silver.Format {
+	if b.Format == nil {
+		return silver.Parquet{}
+	}
+	return b.Format
+}
+
+func (b *Builder) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+func (b *Builder) logf(format string, args ...interface{}) {
+	if b.Logger != nil {
+		b.Logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,194 @@
+package gold
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/parquet"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+	"claim-management-system/pipeline/silver/silvertest"
+)
+
+const lakeBucket = "claim-dev-lake"
+
+type fixture struct {
+	*silvertest.Lake
+}
+
+// newFixture writes the silver tables of a small lake: members M1 (who moved
+// in June) and M2, two claims billed by NPI1 and a payment to it.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{silvertest.New(t, lakeBucket)}
+
+	f.Put(t, "tbl_member", "year=2025/month=01/day=01",
+		"M1,S1,18,021,,2025-01-01,DOE,JANE,,1980-02-03,F,1 Main St,,Springfield,IL,62701,G1,834-a,enrollment,1,2025-11-01T08:00:00Z\n"+
+			"M2,S1,01,021,,2025-01-01,DOE,JOHN,,1979-05-06,M,1 Main St,,Springfield,IL,62701,G1,834-a,enrollment,2,2025-11-01T08:00:00Z\n")
+	f.Put(t, "tbl_member", "year=2025/month=06/day=01",
+		"M1,S1,18,001,,2025-06-01,DOE,JANE,,1980-02-03,F,9 Oak Ave,,Springfield,IL,62704,G1,834-b,enrollment,1,2025-11-02T08:00:00Z\n")
+	f.Put(t, "tbl_coverage", "year=2025/month=01/day=01",
+		"M1,S1,021,2025-01-01,G1,HMO1,HLT,FAM,2025-01-01,,834-a,enrollment,1,2025-11-01T08:00:00Z\n"+
+			"M2,S1,021,2025-01-01,G1,HMO1,,FAM,2024-07-01,,834-a,enrollment,2,2025-11-01T08:00:00Z\n"+
+			"M2,S1,021,2025-01-01,G1,DEN1,DEN,FAM,2025-01-01,,834-a,enrollment,3,2025-11-01T08:00:00Z\n")
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=20",
+		"PCN1,1,P,M1,NPI1,NPI2,PAYER1,11,150.00,2025-11-20,,E119,I10|e119|,1,837-a,clearinghouse,1,2025-11-21T10:00:00Z\n")
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=25",
+		"PCN2,1,I,M2,NPI1,,PAYER1,13,900.00,2025-11-25,,J44,,1,837-a,clearinghouse,2,2025-11-21T10:00:00Z\n")
+	f.Put(t, "tbl_payment", "year=2025/month=12/day=01",
+		"TRC1,2025-12-01,ACH,PAYER1,NPI1,PCN1,,1,M1,150.00,120.00,30.00,835-a,payer,1,2025-12-02T09:00:00Z\n")
+	return f
+}
+
+func (f *fixture) build(t *testing.T, id string) *Manifest {
+	t.Helper()
+	b := &Builder{
+		Objects: f.Objects,
+		Schemas: f.Schemas,
+		Bucket:  lakeBucket,
+		Format:  silver.CSV{},
+		ID:      id,
+		Now:     func() time.Time { return time.Date(2025, 12, 3, 6, 0, 0, 0, time.UTC) },
+	}
+	m, err := b.Run(context.Background())
+	require.NoError(t, err)
+	return m
+}
+
+// dim returns the rows of gold table name, in partition order, as the
+// comma-joined values of columns.
+func (f *fixture) dim(t *testing.T, name string, columns ...string) []string {
+	t.Helper()
+	s, err := f.Schemas.Latest(name)
+	require.NoError(t, err)
+	rows, err := silver.ReadTable(context.Background(), f.Objects, lakeBucket, s, silver.CSV{})
+	require.NoError(t, err)
+	var out []string
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, name := range columns {
+			values[i] = s.Column(name).Type.Format(row[s.Index(name)])
+		}
+		out = append(out, strings.Join(values, ","))
+	}
+	return out
+}
+
+func TestRunBuildsDimensions(t *testing.T) {
+	f := newFixture(t)
+	m := f.build(t, "gold-test")
+
+	assert.Equal(t, "csv", m.Format)
+	require.Len(t, m.Tables, 9)
+	assert.Equal(t, &TableResult{
+		Table: "dim_member", Version: 1, Rows: 4, Current: 2,
+		Object: "s3://claim-dev-lake/gold/dim_member/part-00000.csv", VersionID: m.Tables[0].VersionID,
+	}, m.Tables[0])
+
+	assert.Equal(t, []string{
+		"UNKNOWN,,,1900-01-01,9999-12-31,true,",
+		"M1,DOE,1 Main St,2025-01-01,2025-05-31,false,834-a",
+		"M1,DOE,9 Oak Ave,2025-06-01,9999-12-31,true,834-b",
+		"M2,DOE,1 Main St,2025-01-01,9999-12-31,true,834-a",
+	}, f.dim(t, "dim_member", "member_id", "last_name", "address_line_1", "effective_from", "effective_to", "is_current", "file_id"))
+	keys := f.dim(t, "dim_member", "member_key")
+	assert.Equal(t, "0", keys[0], "The unknown member has key 0")
+	assert.Equal(t, SurrogateKey("dim_member", "M1", "2025-01-01"), mustInt(t, keys[1]))
+
+	assert.Equal(t, []string{
+		"UNKNOWN,false,false,false,,1900-01-01,9999-12-31",
+		"NPI1,true,false,false,11,2025-11-20,2025-11-24",
+		"NPI1,true,false,false,13,2025-11-25,2025-11-30",
+		"NPI1,true,false,true,13,2025-12-01,9999-12-31",
+		"NPI2,false,true,false,,2025-11-20,9999-12-31",
+	}, f.dim(t, "dim_provider", "npi", "is_billing", "is_rendering", "is_payee", "facility_type", "effective_from", "effective_to"))
+	assert.Equal(t, []string{"UNKNOWN,,", "DEN1,DEN,2025-01-01", "HMO1,HLT,2024-07-01"},
+		f.dim(t, "dim_plan", "plan_code", "insurance_line", "first_coverage_start"))
+	assert.Equal(t, []string{"UNKNOWN,,", "E119,E11.9,E11", "I10,I10,I10", "J44,J44,J44"},
+		f.dim(t, "dim_diagnosis", "diagnosis_code", "display_code", "category"))
+	assert.Equal(t, []string{",UNKNOWN,", "I,13,facility_type", "P,11,place_of_service"},
+		f.dim(t, "dim_facility", "claim_type", "facility_type", "code_set"))
+	facilityKeys := f.dim(t, "dim_facility", "facility_key")
+	assert.Equal(t, SurrogateKey("dim_facility", "P", "11"), mustInt(t, facilityKeys[2]))
+
+	var stored Manifest
+	require.NoError(t, json.Unmarshal(f.Read(t, "gold/_manifests/gold-test.json"), &stored))
+	assert.Equal(t, m, &stored)
+	read, err := ReadManifest(context.Background(), f.Objects, lakeBucket, "gold-test")
+	require.NoError(t, err)
+	assert.Equal(t, m, read)
+}
+
+func TestRunLateArrivingChanges(t *testing.T) {
+	f := newFixture(t)
+	f.build(t, "gold-1")
+	before := f.dim(t, "dim_member", "member_key", "member_id", "effective_from")
+
+	// An address change effective in March, sent after the June move.
+	f.Put(t, "tbl_member", "year=2025/month=03/day=01",
+		"M1,S1,18,001,,2025-03-01,DOE,JANE,,1980-02-03,F,5 Elm St,,Springfield,IL,62702,G1,834-c,enrollment,1,2025-11-05T08:00:00Z\n")
+	// A claim of NPI2 as billing provider, serviced before the first claim
+	// naming it.
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=10",
+		"PCN3,1,P,M2,NPI2,,PAYER1,11,75.00,2025-11-10,,J44,,1,837-b,clearinghouse,1,2025-11-30T10:00:00Z\n")
+	m := f.build(t, "gold-2")
+	assert.Equal(t, int64(5), m.Tables[0].Rows)
+
+	assert.Equal(t, []string{
+		"M1,1 Main St,2025-01-01,2025-02-28,false",
+		"M1,5 Elm St,2025-03-01,2025-05-31,false",
+		"M1,9 Oak Ave,2025-06-01,9999-12-31,true",
+	}, f.dim(t, "dim_member", "member_id", "address_line_1", "effective_from", "effective_to", "is_current")[1:4],
+		"The late change splits the first version")
+	after := f.dim(t, "dim_member", "member_key", "member_id", "effective_from")
+	assert.Equal(t, before[1], after[1], "A version keeps its key when it is shortened")
+	assert.Equal(t, before[2], after[3])
+
+	assert.Equal(t, []string{
+		"NPI2,true,false,11,2025-11-10,2025-11-19",
+		"NPI2,true,true,11,2025-11-20,9999-12-31",
+	}, f.dim(t, "dim_provider", "npi", "is_billing", "is_rendering", "facility_type", "effective_from", "effective_to")[4:])
+}
+
+func TestRunWritesParquet(t *testing.T) {
+	f := newFixture(t)
+	// Silver in Parquet, as the transformer writes it by default.
+	for _, name := range []string{"tbl_member", "tbl_coverage", "tbl_claim_header", "tbl_payment"} {
+		s, err := f.Schemas.Latest(name)
+		require.NoError(t, err)
+		rows, err := silver.ReadTable(context.Background(), f.Objects, lakeBucket, s, silver.CSV{})
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, silver.Parquet{}.Write(&buf, s, rows))
+		_, err = f.Objects.Put(context.Background(), lakeBucket, s.Location+"year=2025/month=01/day=01/part-00000.parquet", &buf, objectstore.PutOptions{})
+		require.NoError(t, err)
+	}
+
+	b := &Builder{Objects: f.Objects, Schemas: f.Schemas, Bucket: lakeBucket, ID: "gold-parquet"}
+	m, err := b.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "parquet", m.Format)
+	assert.Equal(t, "s3://claim-dev-lake/gold/dim_member/part-00000.parquet", m.Tables[0].Object)
+	assert.Equal(t, int64(4), m.Tables[0].Rows)
+
+	data := f.Read(t, "gold/dim_member/part-00000.parquet")
+	file, err := parquet.Open(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), file.Rows)
+	assert.Equal(t, "dim_member v1", file.KeyValues["schema"])
+}
+
+func mustInt(t *testing.T, s string) int64 {
+	t.Helper()
+	v, err := (&schema.Column{Type: schema.Type{Kind: schema.Int}}).Parse(s)
+	require.NoError(t, err)
+	return v.(int64)
+}
@@ -0,0 +1,105 @@
+package gold
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// UnknownKey is the surrogate key of the row every dimension has for facts
+// whose natural key it lacks.
+const UnknownKey int64 = 0
+
+// Unknown is the natural key of the unknown row.
+const Unknown = "UNKNOWN"
+
+// OpenEnd is the effective_to of a current SCD2 version.
+var OpenEnd = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// SurrogateKey derives the surrogate key of a dimension row from the table
+// and the row's natural key: the first 63 bits of their SHA-256. Equal
+// natural keys get equal keys in every run, so rebuilding a dimension does
+// not break the facts joined to it.
+func SurrogateKey(table string, natural ...string) int64 {
+	h := sha256.New()
+	h.Write([]byte(table))
+	for _, part := range natural {
+		h.Write([]byte{0x1f})
+		h.Write([]byte(part))
+	}
+	k := int64(binary.BigEndian.Uint64(h.Sum(nil)) >> 1)
+	if k == UnknownKey {
+		k = 1
+	}
+	return k
+}
+
+// change is one dated statement of the tracked attributes of an entity.
+type change struct {
+	id string
+	// from is the date the statement takes effect; seq orders statements of
+	// the same date, the later winning.
+	from time.Time
+	seq  int
+	// attrs are the values of the tracked attributes.
+	attrs []interface{}
+	// fileID is the lineage of the statement, if any.
+	fileID string
+}
+
+// version is one SCD2 version of an entity: attrs held from from to to,
+// both inclusive.
+type version struct {
+	id       string
+	from, to time.Time
+	attrs    []interface{}
+	fileID   string
+}
+
+func (v *version) current() bool {
+	return v.to.Equal(OpenEnd)
+}
+
+// history folds changes into SCD2 versions, per entity and in date order.
+// Statements are ordered by effective date, not by arrival, so a change that
+// arrives late splits the version it falls in and every version after it is
+// rebuilt. Of several statements on one date the last one (by seq) holds.
+// A statement restating the attributes of the version before it opens no
+// version. Each version ends the day before the next one starts; the last
+// ends at OpenEnd.
+func history(changes []change) []*version {
+	sorted := make([]change, len(changes))
+	copy(sorted, changes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch {
+		case a.id != b.id:
+			return a.id < b.id
+		case !a.from.Equal(b.from):
+			return a.from.Before(b.from)
+		}
+		return a.seq < b.seq
+	})
+
+	var out []*version
+	for i, c := range sorted {
+		if i+1 < len(sorted) && sorted[i+1].id == c.id && sorted[i+1].from.Equal(c.from) {
+			// A later statement of the same date holds.
+			continue
+		}
+		var prev *version
+		if n := len(out); n > 0 && out[n-1].id == c.id {
+			prev = out[n-1]
+		}
+		if prev != nil && reflect.DeepEqual(prev.attrs, c.attrs) {
+			continue
+		}
+		if prev != nil {
+			prev.to = c.from.AddDate(0, 0, -1)
+		}
+		out = append(out, &version{id: c.id, from: c.from, to: OpenEnd, attrs: c.attrs, fileID: c.fileID})
+	}
+	return out
+}
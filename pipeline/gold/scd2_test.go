@@ -0,0 +1,110 @@
+package gold
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// versions renders history as id from..to attrs.
+func versions(vs []*version) []string {
+	var out []string
+	for _, v := range vs {
+		to := v.to.Format("2006-01-02")
+		if v.current() {
+			to = "open"
+		}
+		out = append(out, fmt.Sprintf("%s %s..%s %v", v.id, v.from.Format("2006-01-02"), to, v.attrs))
+	}
+	return out
+}
+
+func TestHistory(t *testing.T) {
+	ch := func(id, from string, seq int, attrs ...interface{}) change {
+		return change{id: id, from: date(from), seq: seq, attrs: attrs}
+	}
+	for _, tc := range []struct {
+		name    string
+		changes []change
+		want    []string
+	}{
+		{
+			name: "changes in order",
+			changes: []change{
+				ch("M1", "2025-01-01", 0, "A"),
+				ch("M1", "2025-03-01", 1, "B"),
+				ch("M2", "2025-02-01", 2, "X"),
+			},
+			want: []string{"M1 2025-01-01..2025-02-28 [A]", "M1 2025-03-01..open [B]", "M2 2025-02-01..open [X]"},
+		},
+		{
+			name: "restatements open no version",
+			changes: []change{
+				ch("M1", "2025-01-01", 0, "A"),
+				ch("M1", "2025-02-01", 1, "A"),
+				ch("M1", "2025-03-01", 2, "B"),
+				ch("M1", "2025-04-01", 3, "B"),
+			},
+			want: []string{"M1 2025-01-01..2025-02-28 [A]", "M1 2025-03-01..open [B]"},
+		},
+		{
+			name: "late change splits a version",
+			changes: []change{
+				ch("M1", "2025-01-01", 0, "A"),
+				ch("M1", "2025-06-01", 1, "C"),
+				// Arrives last, effective in between.
+				ch("M1", "2025-03-15", 2, "B"),
+			},
+			want: []string{"M1 2025-01-01..2025-03-14 [A]", "M1 2025-03-15..2025-05-31 [B]", "M1 2025-06-01..open [C]"},
+		},
+		{
+			name: "late change before the first",
+			changes: []change{
+				ch("M1", "2025-03-01", 0, "B"),
+				ch("M1", "2024-12-01", 1, "A"),
+			},
+			want: []string{"M1 2024-12-01..2025-02-28 [A]", "M1 2025-03-01..open [B]"},
+		},
+		{
+			name: "late correction of the same date",
+			changes: []change{
+				ch("M1", "2025-01-01", 0, "A"),
+				ch("M1", "2025-03-01", 1, "typo"),
+				ch("M1", "2025-03-01", 2, "B"),
+			},
+			want: []string{"M1 2025-01-01..2025-02-28 [A]", "M1 2025-03-01..open [B]"},
+		},
+		{
+			name: "late change undoing one",
+			changes: []change{
+				ch("M1", "2025-01-01", 0, "A"),
+				ch("M1", "2025-03-01", 1, "B"),
+				ch("M1", "2025-03-01", 2, "A"),
+			},
+			want: []string{"M1 2025-01-01..open [A]"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, versions(history(tc.changes)))
+		})
+	}
+}
+
+func TestSurrogateKey(t *testing.T) {
+	k := SurrogateKey("dim_member", "M1", "2025-01-01")
+	assert.Equal(t, k, SurrogateKey("dim_member", "M1", "2025-01-01"), "Keys are stable")
+	assert.Positive(t, k)
+	assert.NotEqual(t, k, SurrogateKey("dim_member", "M1", "2025-03-01"))
+	assert.NotEqual(t, k, SurrogateKey("dim_provider", "M1", "2025-01-01"))
+	assert.NotEqual(t, SurrogateKey("dim_facility", "P1", "1"), SurrogateKey("dim_facility", "P", "11"), "Parts are delimited")
+}
@@ -0,0 +1,566 @@
+package iceberg
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/golang/snappy"
+)
+
+// Iceberg's manifests and manifest lists are Avro object container files.
+// This file holds the part of Avro they need: schemas with Iceberg's field
+// ids, the binary encoding, and container files with the null codec on
+// write and the null, deflate and snappy codecs on read.
+
+var avroMagic = []byte("Obj\x01")
+
+var errAvroTruncated = errors.New("iceberg: avro: truncated data")
+
+// avroSchema is an Avro schema: a primitive, or a record, array, map, union,
+// fixed or enum.
+type avroSchema struct {
+	Type string
+	// Name names a record, fixed or enum.
+	Name string
+	// Fields are the fields of a record.
+	Fields []*avroField
+	// Items are the items of an array or the values of a map.
+	Items *avroSchema
+	// ElementID is the Iceberg field id of an array's items.
+	ElementID int
+	// Branches are the schemas of a union.
+	Branches []*avroSchema
+	Size     int
+	Symbols  []string
+	// LogicalType annotates a primitive, e.g. date on int.
+	LogicalType string
+}
+
+// avroField is a field of a record. Iceberg identifies fields by ID, their
+// field-id; a field without one has ID -1.
+type avroField struct {
+	Name string
+	Type *avroSchema
+	ID   int
+}
+
+// avroRecord is a record value: its field values by field id. Fields
+// without an id are not kept.
+type avroRecord map[int]interface{}
+
+var (
+	avroNull    = &avroSchema{Type: "null"}
+	avroBoolean = &avroSchema{Type: "boolean"}
+	avroInt     = &avroSchema{Type: "int"}
+	avroLong    = &avroSchema{Type: "long"}
+	avroString  = &avroSchema{Type: "string"}
+	avroBytes   = &avroSchema{Type: "bytes"}
+	avroDate    = &avroSchema{Type: "int", LogicalType: "date"}
+)
+
+// optional returns the union of null and s, as Iceberg writes its optional
+// fields.
+func optional(s *avroSchema) *avroSchema {
+	return &avroSchema{Type: "union", Branches: []*avroSchema{avroNull, s}}
+}
+
+func (s *avroSchema) MarshalJSON() ([]byte, error) {
+	switch s.Type {
+	case "union":
+		return json.Marshal(s.Branches)
+	case "record":
+		fields := make([]map[string]interface{}, len(s.Fields))
+		for i, f := range s.Fields {
+			fields[i] = map[string]interface{}{"name": f.Name, "type": f.Type}
+			if f.ID >= 0 {
+				fields[i]["field-id"] = f.ID
+			}
+			if f.Type.Type == "union" && f.Type.Branches[0].Type == "null" {
+				fields[i]["default"] = nil
+			}
+		}
+		return json.Marshal(map[string]interface{}{"type": "record", "name": s.Name, "fields": fields})
+	case "array":
+		return json.Marshal(map[string]interface{}{"type": "array", "items": s.Items, "element-id": s.ElementID})
+	case "map":
+		return json.Marshal(map[string]interface{}{"type": "map", "values": s.Items})
+	case "fixed":
+		return json.Marshal(map[string]interface{}{"type": "fixed", "name": s.Name, "size": s.Size})
+	case "enum":
+		return json.Marshal(map[string]interface{}{"type": "enum", "name": s.Name, "symbols": s.Symbols})
+	}
+	if s.LogicalType != "" {
+		return json.Marshal(map[string]interface{}{"type": s.Type, "logicalType": s.LogicalType})
+	}
+	return json.Marshal(s.Type)
+}
+
+// parseAvroSchema parses the JSON of a writer schema.
+func parseAvroSchema(data []byte) (*avroSchema, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("iceberg: avro schema: %w", err)
+	}
+	return parseAvro(v, map[string]*avroSchema{})
+}
+
+func parseAvro(v interface{}, named map[string]*avroSchema) (*avroSchema, error) {
+	switch v := v.(type) {
+	case string:
+		switch v {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{Type: v}, nil
+		}
+		if s, ok := named[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("iceberg: avro schema: unknown type %q", v)
+	case []interface{}:
+		s := &avroSchema{Type: "union"}
+		for _, b := range v {
+			branch, err := parseAvro(b, named)
+			if err != nil {
+				return nil, err
+			}
+			s.Branches = append(s.Branches, branch)
+		}
+		return s, nil
+	case map[string]interface{}:
+		typ, _ := v["type"].(string)
+		s := &avroSchema{Type: typ}
+		s.Name, _ = v["name"].(string)
+		switch typ {
+		case "record", "error":
+			s.Type = "record"
+			named[s.Name] = s
+			fields, _ := v["fields"].([]interface{})
+			for _, f := range fields {
+				fm, _ := f.(map[string]interface{})
+				field := &avroField{ID: -1}
+				field.Name, _ = fm["name"].(string)
+				if id, ok := fm["field-id"].(float64); ok {
+					field.ID = int(id)
+				}
+				t, err := parseAvro(fm["type"], named)
+				if err != nil {
+					return nil, fmt.Errorf("%w (field %s of %s)", err, field.Name, s.Name)
+				}
+				field.Type = t
+				s.Fields = append(s.Fields, field)
+			}
+			return s, nil
+		case "array", "map":
+			items := v["items"]
+			if typ == "map" {
+				items = v["values"]
+			}
+			t, err := parseAvro(items, named)
+			if err != nil {
+				return nil, err
+			}
+			s.Items = t
+			if id, ok := v["element-id"].(float64); ok {
+				s.ElementID = int(id)
+			}
+			return s, nil
+		case "fixed":
+			size, _ := v["size"].(float64)
+			s.Size = int(size)
+			named[s.Name] = s
+			return s, nil
+		case "enum":
+			symbols, _ := v["symbols"].([]interface{})
+			for _, sym := range symbols {
+				name, _ := sym.(string)
+				s.Symbols = append(s.Symbols, name)
+			}
+			named[s.Name] = s
+			return s, nil
+		}
+		// A primitive with attributes, e.g. a logical type.
+		prim, err := parseAvro(typ, named)
+		if err != nil {
+			return nil, err
+		}
+		prim.LogicalType, _ = v["logicalType"].(string)
+		return prim, nil
+	}
+	return nil, fmt.Errorf("iceberg: avro schema: unexpected %T", v)
+}
+
+// avroEncoder appends the binary encoding of values.
+type avroEncoder struct {
+	buf []byte
+}
+
+func (e *avroEncoder) long(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *avroEncoder) bytes(v []byte) {
+	e.long(int64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// encode appends v, of schema s.
+func (e *avroEncoder) encode(s *avroSchema, v interface{}) error {
+	switch s.Type {
+	case "null":
+		if v != nil {
+			return fmt.Errorf("iceberg: avro: %T for null", v)
+		}
+		return nil
+	case "union":
+		for i, b := range s.Branches {
+			if (v == nil) == (b.Type == "null") {
+				e.long(int64(i))
+				return e.encode(b, v)
+			}
+		}
+		return fmt.Errorf("iceberg: avro: no union branch for %T", v)
+	case "record":
+		r, ok := v.(avroRecord)
+		if !ok {
+			return fmt.Errorf("iceberg: avro: %T for record %s", v, s.Name)
+		}
+		for _, f := range s.Fields {
+			if err := e.encode(f.Type, r[f.ID]); err != nil {
+				return fmt.Errorf("%w (field %s)", err, f.Name)
+			}
+		}
+		return nil
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("iceberg: avro: %T for array", v)
+		}
+		if len(items) > 0 {
+			e.long(int64(len(items)))
+			for _, item := range items {
+				if err := e.encode(s.Items, item); err != nil {
+					return err
+				}
+			}
+		}
+		e.long(0)
+		return nil
+	}
+
+	switch v := v.(type) {
+	case bool:
+		if s.Type == "boolean" {
+			b := byte(0)
+			if v {
+				b = 1
+			}
+			e.buf = append(e.buf, b)
+			return nil
+		}
+	case int32:
+		if s.Type == "int" {
+			e.long(int64(v))
+			return nil
+		}
+	case int64:
+		if s.Type == "long" {
+			e.long(v)
+			return nil
+		}
+	case string:
+		if s.Type == "string" {
+			e.bytes([]byte(v))
+			return nil
+		}
+	case []byte:
+		if s.Type == "bytes" {
+			e.bytes(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("iceberg: avro: %T for %s", v, s.Type)
+}
+
+// avroDecoder reads binary-encoded values.
+type avroDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *avroDecoder) long() (int64, error) {
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errAvroTruncated
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *avroDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errAvroTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *avroDecoder) bytes() ([]byte, error) {
+	n, err := d.long()
+	if err != nil {
+		return nil, err
+	}
+	return d.next(int(n))
+}
+
+// blocks calls item once per item of an array or map.
+func (d *avroDecoder) blocks(item func() error) error {
+	for {
+		n, err := d.long()
+		if err != nil || n == 0 {
+			return err
+		}
+		if n < 0 {
+			// A negative count is followed by the block's size in bytes.
+			n = -n
+			if _, err := d.long(); err != nil {
+				return err
+			}
+		}
+		for ; n > 0; n-- {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// decode reads a value of schema s.
+func (d *avroDecoder) decode(s *avroSchema) (interface{}, error) {
+	switch s.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int":
+		v, err := d.long()
+		return int32(v), err
+	case "long":
+		return d.long()
+	case "float":
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		b, err := d.bytes()
+		return append([]byte(nil), b...), err
+	case "string":
+		b, err := d.bytes()
+		return string(b), err
+	case "fixed":
+		b, err := d.next(s.Size)
+		return append([]byte(nil), b...), err
+	case "enum":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.Symbols) {
+			return nil, fmt.Errorf("iceberg: avro: enum %s has no symbol %d", s.Name, i)
+		}
+		return s.Symbols[i], nil
+	case "union":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.Branches) {
+			return nil, fmt.Errorf("iceberg: avro: union has no branch %d", i)
+		}
+		return d.decode(s.Branches[i])
+	case "record":
+		r := avroRecord{}
+		for _, f := range s.Fields {
+			v, err := d.decode(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			if f.ID >= 0 {
+				r[f.ID] = v
+			}
+		}
+		return r, nil
+	case "array":
+		items := []interface{}{}
+		err := d.blocks(func() error {
+			v, err := d.decode(s.Items)
+			items = append(items, v)
+			return err
+		})
+		return items, err
+	case "map":
+		m := map[string]interface{}{}
+		err := d.blocks(func() error {
+			k, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			v, err := d.decode(s.Items)
+			m[string(k)] = v
+			return err
+		})
+		return m, err
+	}
+	return nil, fmt.Errorf("iceberg: avro: cannot decode %s", s.Type)
+}
+
+// avroFile is a decoded container file.
+type avroFile struct {
+	Schema *avroSchema
+	// Meta is the file's metadata, avro.schema and avro.codec included.
+	Meta    map[string]string
+	Records []interface{}
+}
+
+// writeAvro returns the container file holding records of s, one block
+// without compression, with the metadata meta besides the schema.
+func writeAvro(s *avroSchema, meta map[string]string, records []avroRecord) ([]byte, error) {
+	schemaJSON, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	e := &avroEncoder{buf: append([]byte(nil), avroMagic...)}
+	e.long(int64(len(meta) + 2))
+	for _, k := range sortedKeys(meta) {
+		e.bytes([]byte(k))
+		e.bytes([]byte(meta[k]))
+	}
+	e.bytes([]byte("avro.schema"))
+	e.bytes(schemaJSON)
+	e.bytes([]byte("avro.codec"))
+	e.bytes([]byte("null"))
+	e.long(0)
+	sync := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, sync); err != nil {
+		return nil, err
+	}
+	e.buf = append(e.buf, sync...)
+
+	if len(records) > 0 {
+		block := &avroEncoder{}
+		for _, r := range records {
+			if err := block.encode(s, r); err != nil {
+				return nil, err
+			}
+		}
+		e.long(int64(len(records)))
+		e.bytes(block.buf)
+		e.buf = append(e.buf, sync...)
+	}
+	return e.buf, nil
+}
+
+// readAvro decodes a container file.
+func readAvro(data []byte) (*avroFile, error) {
+	if !bytes.HasPrefix(data, avroMagic) {
+		return nil, errors.New("iceberg: avro: not an object container file")
+	}
+	d := &avroDecoder{data: data, pos: len(avroMagic)}
+	f := &avroFile{Meta: map[string]string{}}
+	err := d.blocks(func() error {
+		k, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		v, err := d.bytes()
+		f.Meta[string(k)] = string(v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sync, err := d.next(16)
+	if err != nil {
+		return nil, err
+	}
+	if f.Schema, err = parseAvroSchema([]byte(f.Meta["avro.schema"])); err != nil {
+		return nil, err
+	}
+
+	for d.pos < len(data) {
+		n, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		block, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		if block, err = decompress(f.Meta["avro.codec"], block); err != nil {
+			return nil, err
+		}
+		bd := &avroDecoder{data: block}
+		for ; n > 0; n-- {
+			r, err := bd.decode(f.Schema)
+			if err != nil {
+				return nil, err
+			}
+			f.Records = append(f.Records, r)
+		}
+		marker, err := d.next(16)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(marker, sync) {
+			return nil, errors.New("iceberg: avro: block does not end with the sync marker")
+		}
+	}
+	return f, nil
+}
+
+func decompress(codec string, block []byte) ([]byte, error) {
+	switch codec {
+	case "", "null":
+		return block, nil
+	case "deflate":
+		out, err := io.ReadAll(flate.NewReader(bytes.NewReader(block)))
+		if err != nil {
+			return nil, fmt.Errorf("iceberg: avro: deflate: %w", err)
+		}
+		return out, nil
+	case "snappy":
+		// The compressed block is followed by the CRC-32 of the
+		// uncompressed one.
+		if len(block) < 4 {
+			return nil, errAvroTruncated
+		}
+		out, err := snappy.Decode(nil, block[:len(block)-4])
+		if err != nil {
+			return nil, fmt.Errorf("iceberg: avro: snappy: %w", err)
+		}
+		if crc32.ChecksumIEEE(out) != binary.BigEndian.Uint32(block[len(block)-4:]) {
+			return nil, errors.New("iceberg: avro: snappy: checksum mismatch")
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("iceberg: avro: unsupported codec %q", codec)
+}
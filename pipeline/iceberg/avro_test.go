@@ -0,0 +1,104 @@
+package iceberg
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvroRoundTrip(t *testing.T) {
+	mf := &manifestFile{
+		path: "s3://claim-dev-lake/gold/_iceberg/fact_claim/metadata/a-m0.avro", length: 812, specID: 0, content: contentData,
+		sequence: 3, minSequence: 1, snapshotID: 42, added: 2, existing: 1, deleted: 0,
+		addedRows: 10, existingRows: 4, deletedRows: 0,
+		partitions: []interface{}{avroRecord{509: false, 518: nil, 510: []byte("2025"), 511: []byte("2026")}},
+	}
+	data, err := writeManifestList(&Snapshot{SnapshotID: 42, SequenceNumber: 3}, []*manifestFile{mf, {path: "s3://b/m1.avro"}})
+	require.NoError(t, err)
+
+	f, err := readAvro(data)
+	require.NoError(t, err)
+	assert.Equal(t, "42", f.Meta["snapshot-id"])
+	assert.Equal(t, "null", f.Meta["parent-snapshot-id"])
+	assert.Equal(t, "null", f.Meta["avro.codec"])
+	assert.Equal(t, "manifest_file", f.Schema.Name)
+	assert.Equal(t, 507, f.Schema.Fields[13].ID, "Field ids survive the schema JSON")
+	assert.Equal(t, 508, f.Schema.Fields[13].Type.Branches[1].ElementID)
+
+	list, err := readManifestList(data)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, mf, list[0])
+	assert.Nil(t, list[1].partitions)
+}
+
+func TestAvroSchemaJSON(t *testing.T) {
+	s := entrySchema(partitionSchema([]partitionCol{{name: "year", id: 1000, typ: "string"}, {name: "day", id: 1001, typ: "date"}}))
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `{"default":null,"field-id":1,"name":"snapshot_id","type":["null","long"]}`)
+	assert.Contains(t, string(data), `{"default":null,"field-id":1001,"name":"day","type":["null",{"logicalType":"date","type":"int"}]}`)
+
+	parsed, err := parseAvroSchema(data)
+	require.NoError(t, err)
+	again, err := json.Marshal(parsed)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+}
+
+func TestAvroReadsOtherWriters(t *testing.T) {
+	// A named type used twice, a map, an enum, a fixed and a field without
+	// a field id, in a deflate block as Java writers compress them.
+	schemaJSON := `{"type":"record","name":"r","fields":[
+		{"name":"a","type":{"type":"fixed","name":"f16","size":2},"field-id":1},
+		{"name":"b","type":"f16","field-id":2},
+		{"name":"c","type":{"type":"map","values":"long"},"field-id":3},
+		{"name":"d","type":{"type":"enum","name":"e","symbols":["X","Y"]},"field-id":4},
+		{"name":"e","type":"double"}]}`
+	e := &avroEncoder{buf: []byte("hiyo")}
+	// A map block of one entry, stated with its byte size.
+	e.long(-1)
+	e.long(3)
+	e.bytes([]byte("k"))
+	e.long(7)
+	e.long(0)
+	e.long(1)
+	e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f) // 1.0
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = fw.Write(e.buf)
+	require.NoError(t, err)
+	require.NoError(t, fw.Close())
+
+	file := &avroEncoder{buf: append([]byte(nil), avroMagic...)}
+	file.long(2)
+	file.bytes([]byte("avro.schema"))
+	file.bytes([]byte(schemaJSON))
+	file.bytes([]byte("avro.codec"))
+	file.bytes([]byte("deflate"))
+	file.long(0)
+	sync := []byte("0123456789abcdef")
+	file.buf = append(file.buf, sync...)
+	file.long(1)
+	file.bytes(compressed.Bytes())
+	file.buf = append(file.buf, sync...)
+
+	f, err := readAvro(file.buf)
+	require.NoError(t, err)
+	assert.Equal(t, "r", f.Schema.Name)
+	assert.Equal(t, []interface{}{avroRecord{
+		1: []byte("hi"), 2: []byte("yo"), 3: map[string]interface{}{"k": int64(7)}, 4: "Y",
+	}}, f.Records)
+
+	file.buf[len(file.buf)-1] = 'x'
+	_, err = readAvro(file.buf)
+	assert.EqualError(t, err, "iceberg: avro: block does not end with the sync marker")
+	_, err = readAvro([]byte("PAR1"))
+	assert.EqualError(t, err, "iceberg: avro: not an object container file")
+}
@@ -0,0 +1,695 @@
+// Package iceberg commits Parquet files to Apache Iceberg tables registered
+// in the Glue catalog, the Athena alternative to loading gold into
+// Redshift. A table's metadata lives beside its data under
+// gold/_iceberg/<table>/: a JSON metadata file per commit (format version
+// 2), and per snapshot a manifest list and manifests, both Avro. The Glue
+// table <table>_iceberg in claim_gold_db points to the current metadata
+// file.
+//
+// A commit appends files or overwrites partitions in one new snapshot. It
+// reads the current metadata, writes the new one and swaps the Glue
+// table's metadata_location, passing the Glue version it read: if another
+// commit swapped first, Glue refuses and the commit starts over from the
+// newer metadata.
+package iceberg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/glue/glueiface"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+)
+
+// Prefix is where the Iceberg tables are in the lake bucket. It is apart
+// from gold/<table>/, which Redshift copies and the Hive tables read.
+const Prefix = "gold/_iceberg/"
+
+// TableSuffix suffixes the Glue name of an Iceberg table; the plain names
+// are the Hive tables catalog.SyncTable keeps.
+const TableSuffix = "_iceberg"
+
+// DefaultAttempts is how many times a commit is tried before it gives up
+// to concurrent ones.
+const DefaultAttempts = 4
+
+// Glue table parameters of an Iceberg table.
+const (
+	paramTableType        = "table_type"
+	paramMetadataLocation = "metadata_location"
+	paramPreviousLocation = "previous_metadata_location"
+	tableTypeIceberg      = "ICEBERG"
+)
+
+// ErrConflict is returned when every attempt of a commit lost to a
+// concurrent one.
+var ErrConflict = errors.New("iceberg: the table changed during the commit")
+
+// Writer commits files to the Iceberg tables of registry schemas.
+type Writer struct {
+	Objects objectstore.Store
+	Glue    glueiface.GlueAPI
+	// Schemas resolves the tables of a gold run for Publish.
+	Schemas *schema.Registry
+	// Bucket is the lake bucket holding the tables.
+	Bucket string
+	// Database is the Glue database of the tables; empty means the gold
+	// database of catalog.DefaultDatabases.
+	Database string
+	// MaxAttempts bounds the tries of a commit; zero means DefaultAttempts.
+	MaxAttempts int
+
+	Logger *log.Logger
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Commit is what one commit did.
+type Commit struct {
+	// Table is the Glue table.
+	Table            string `json:"table"`
+	Operation        string `json:"operation"`
+	SnapshotID       int64  `json:"snapshot_id"`
+	SequenceNumber   int64  `json:"sequence_number"`
+	MetadataLocation string `json:"metadata_location"`
+	AddedFiles       int    `json:"added_files"`
+	DeletedFiles     int    `json:"deleted_files"`
+	AddedRecords     int64  `json:"added_records"`
+	DeletedRecords   int64  `json:"deleted_records"`
+	// TotalRecords counts the table's records after the commit.
+	TotalRecords int64 `json:"total_records"`
+	// Attempts counts the tries; more than one means a concurrent commit
+	// won a race.
+	Attempts int `json:"attempts"`
+}
+
+// TableName returns the Glue name of the Iceberg table of s.
+func TableName(s *schema.Schema) string {
+	return s.Name + TableSuffix
+}
+
+// Location returns the s3:// location of the Iceberg table of s.
+func (w *Writer) Location(s *schema.Schema) string {
+	return "s3://" + w.Bucket + "/" + Prefix + s.Name
+}
+
+// Append adds files to the table of s in a new snapshot, creating the
+// table if it does not exist.
+func (w *Writer) Append(ctx context.Context, s *schema.Schema, files []DataFile) (*Commit, error) {
+	return w.commit(ctx, s, OpAppend, nil, files, nil)
+}
+
+// Overwrite replaces, in one snapshot, the files of the partitions at
+// paths, as schema.Schema.PartitionPath returns them, with files, which
+// must lie in those partitions. An unpartitioned table is replaced whole
+// and paths are ignored.
+func (w *Writer) Overwrite(ctx context.Context, s *schema.Schema, paths []string, files []DataFile) (*Commit, error) {
+	return w.commit(ctx, s, OpOverwrite, paths, files, nil)
+}
+
+// Metadata returns the current metadata of the table of s, or nil if the
+// table does not exist.
+func (w *Writer) Metadata(ctx context.Context, s *schema.Schema) (*Metadata, error) {
+	t, err := w.load(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return t.meta, nil
+}
+
+// Files returns the data files of the current snapshot of the table of s,
+// in path order.
+func (w *Writer) Files(ctx context.Context, s *schema.Schema) ([]DataFile, error) {
+	t, err := w.load(ctx, s)
+	if err != nil || t.meta == nil || t.meta.CurrentSnapshot() == nil {
+		return nil, err
+	}
+	manifests, err := w.manifests(ctx, t.meta.CurrentSnapshot())
+	if err != nil {
+		return nil, err
+	}
+	var files []DataFile
+	for _, mf := range manifests {
+		if mf.content != contentData {
+			continue
+		}
+		entries, err := w.entries(ctx, t.meta, mf)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.status != statusDeleted {
+				files = append(files, e.file)
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// table is a table as a commit found it.
+type table struct {
+	// glue is nil for a table not created yet.
+	glue *glue.TableData
+	// location is the metadata file meta was read from.
+	location string
+	meta     *Metadata
+}
+
+// load reads the Glue table of s and its current metadata.
+func (w *Writer) load(ctx context.Context, s *schema.Schema) (*table, error) {
+	db, name := w.database(), TableName(s)
+	out, err := w.Glue.GetTableWithContext(ctx, &glue.GetTableInput{DatabaseName: aws.String(db), Name: aws.String(name)})
+	if isCode(err, glue.ErrCodeEntityNotFoundException) {
+		return &table{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get %s.%s: %w", db, name, err)
+	}
+	t := &table{glue: out.Table}
+	if !strings.EqualFold(aws.StringValue(out.Table.Parameters[paramTableType]), tableTypeIceberg) {
+		return nil, fmt.Errorf("iceberg: %s.%s is not an Iceberg table", db, name)
+	}
+	t.location = aws.StringValue(out.Table.Parameters[paramMetadataLocation])
+	data, err := w.read(ctx, t.location)
+	if err != nil {
+		return nil, err
+	}
+	t.meta = &Metadata{}
+	if err := json.Unmarshal(data, t.meta); err != nil {
+		return nil, fmt.Errorf("iceberg: decode %s: %w", t.location, err)
+	}
+	if t.meta.FormatVersion != 2 {
+		return nil, fmt.Errorf("iceberg: %s: format version %d is not supported", t.location, t.meta.FormatVersion)
+	}
+	return t, nil
+}
+
+// commit tries op until it succeeds, fails other than by a conflict, or
+// runs out of attempts. Overwrites delete the files in paths; summary adds
+// to the snapshot summary.
+func (w *Writer) commit(ctx context.Context, s *schema.Schema, op string, paths []string, files []DataFile, summary map[string]string) (*Commit, error) {
+	attempts := w.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		t, err := w.load(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		c, err := w.attempt(ctx, s, t, op, paths, files, summary)
+		if errors.Is(err, ErrConflict) && attempt < attempts {
+			w.logf("%s: lost to a concurrent commit; retrying", TableName(s))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Attempts = attempt
+		w.logf("%s: %s snapshot %d: +%d files, -%d files, %d records", c.Table, op, c.SnapshotID, c.AddedFiles, c.DeletedFiles, c.TotalRecords)
+		return c, nil
+	}
+}
+
+// attempt commits op on top of t. The files it writes before a conflict
+// stay in the bucket, unreferenced.
+func (w *Writer) attempt(ctx context.Context, s *schema.Schema, t *table, op string, paths []string, files []DataFile, summary map[string]string) (*Commit, error) {
+	now := w.now()
+	m := t.meta
+	if m == nil {
+		m = w.newMetadata(s)
+	}
+	if err := evolve(m, s); err != nil {
+		return nil, err
+	}
+	spec := m.Spec()
+	cols, err := partitionCols(m, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	replace := map[string]bool{}
+	for _, path := range paths {
+		if _, err := parsePartition(cols, path); err != nil {
+			return nil, err
+		}
+		replace[path] = true
+	}
+	replaced := func(e *entry) bool {
+		return op == OpOverwrite && (len(cols) == 0 || replace[e.file.Partition])
+	}
+
+	seq := m.LastSequenceNumber + 1
+	snap := &Snapshot{SnapshotID: newSnapshotID(), SequenceNumber: seq, TimestampMS: now.UnixMilli(), SchemaID: m.CurrentSchemaID}
+	c := &Commit{Table: TableName(s), Operation: op, SnapshotID: snap.SnapshotID, SequenceNumber: seq}
+	var added []*entry
+	for _, f := range files {
+		e := &entry{status: statusAdded, snapshotID: snap.SnapshotID, sequence: seq, fileSequence: seq, file: f}
+		if e.values, err = parsePartition(cols, f.Partition); err != nil {
+			return nil, fmt.Errorf("%w (file %s)", err, f.Path)
+		}
+		if op == OpOverwrite && !replaced(e) {
+			return nil, fmt.Errorf("iceberg: %s: file %s is not in an overwritten partition", c.Table, f.Path)
+		}
+		added = append(added, e)
+		c.AddedFiles++
+		c.AddedRecords += f.Records
+	}
+
+	var manifests []*manifestFile
+	var addedSize, deletedSize int64
+	if parent := m.CurrentSnapshot(); parent != nil {
+		snap.ParentSnapshotID = aws.Int64(parent.SnapshotID)
+		prev, err := w.manifests(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, mf := range prev {
+			if op != OpOverwrite || mf.content != contentData {
+				manifests = append(manifests, mf)
+				continue
+			}
+			entries, err := w.entries(ctx, m, mf)
+			if err != nil {
+				return nil, err
+			}
+			// Entries deleted before are dropped, and so is a manifest of
+			// nothing else; a manifest with no replaced entry is kept as
+			// it is.
+			var kept []*entry
+			hit := false
+			for _, e := range entries {
+				if e.status == statusDeleted {
+					continue
+				}
+				if replaced(e) {
+					hit = true
+					e.status, e.snapshotID = statusDeleted, snap.SnapshotID
+					c.DeletedFiles++
+					c.DeletedRecords += e.file.Records
+					deletedSize += e.file.Size
+				} else {
+					e.status = statusExisting
+				}
+				kept = append(kept, e)
+			}
+			if !hit {
+				if len(kept) > 0 {
+					manifests = append(manifests, mf)
+				}
+				continue
+			}
+			rewritten, err := w.writeManifest(ctx, m, spec, cols, snap, kept)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, rewritten)
+		}
+	}
+	if len(added) > 0 {
+		mf, err := w.writeManifest(ctx, m, spec, cols, snap, added)
+		if err != nil {
+			return nil, err
+		}
+		// New files come first, as Iceberg lists them.
+		manifests = append([]*manifestFile{mf}, manifests...)
+	}
+	for _, e := range added {
+		addedSize += e.file.Size
+	}
+
+	snap.Summary = snapshotSummary(m.CurrentSnapshot(), op, c, addedSize, deletedSize)
+	for k, v := range summary {
+		snap.Summary[k] = v
+	}
+	c.TotalRecords, _ = strconv.ParseInt(snap.Summary["total-records"], 10, 64)
+	list, err := writeManifestList(snap, manifests)
+	if err != nil {
+		return nil, err
+	}
+	snap.ManifestList = fmt.Sprintf("%s/metadata/snap-%d-%s.avro", m.Location, snap.SnapshotID, newUUID())
+	if err := w.write(ctx, snap.ManifestList, list, "avro/binary"); err != nil {
+		return nil, err
+	}
+
+	m.Snapshots = append(m.Snapshots, snap)
+	m.CurrentSnapshotID = snap.SnapshotID
+	m.Refs[mainBranch] = &Ref{SnapshotID: snap.SnapshotID, Type: "branch"}
+	m.SnapshotLog = append(m.SnapshotLog, &SnapshotLog{TimestampMS: snap.TimestampMS, SnapshotID: snap.SnapshotID})
+	m.LastSequenceNumber = seq
+	if t.location != "" {
+		m.MetadataLog = append(m.MetadataLog, &MetadataLog{TimestampMS: m.LastUpdatedMS, MetadataFile: t.location})
+	}
+	m.LastUpdatedMS = snap.TimestampMS
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	c.MetadataLocation = fmt.Sprintf("%s/metadata/%05d-%s.metadata.json", m.Location, metadataVersion(t.location)+1, newUUID())
+	if err := w.write(ctx, c.MetadataLocation, data, "application/json"); err != nil {
+		return nil, err
+	}
+	return c, w.swap(ctx, s, t, m, c.MetadataLocation)
+}
+
+// newMetadata returns the metadata of a new table of s, without schemas
+// or snapshots.
+func (w *Writer) newMetadata(s *schema.Schema) *Metadata {
+	return &Metadata{
+		FormatVersion:     2,
+		TableUUID:         newUUID(),
+		Location:          w.Location(s),
+		LastPartitionID:   partitionFieldBase - 1,
+		SortOrders:        []*SortOrder{{OrderID: 0, Fields: []interface{}{}}},
+		Properties:        map[string]string{propFormat: "parquet", propCompression: "snappy"},
+		CurrentSnapshotID: -1,
+		Refs:              map[string]*Ref{},
+		Snapshots:         []*Snapshot{},
+		SnapshotLog:       []*SnapshotLog{},
+		MetadataLog:       []*MetadataLog{},
+	}
+}
+
+// evolve brings the schema of m to s. Columns keep their ids; a schema
+// with other fields is added and made current. Partitioning cannot change.
+func evolve(m *Metadata, s *schema.Schema) error {
+	current := m.CurrentSchema()
+	ts, last := tableSchema(s, current, m.LastColumnID)
+	if current == nil || !sameFields(ts, current) {
+		for _, old := range m.Schemas {
+			if old.SchemaID >= ts.SchemaID {
+				ts.SchemaID = old.SchemaID + 1
+			}
+		}
+		m.Schemas = append(m.Schemas, ts)
+		m.CurrentSchemaID = ts.SchemaID
+		m.LastColumnID = last
+	}
+	if m.Refs == nil {
+		m.Refs = map[string]*Ref{}
+	}
+	if m.Properties == nil {
+		m.Properties = map[string]string{}
+	}
+	m.Properties[propNameMapping] = nameMapping(m)
+
+	spec := partitionSpec(s, ts)
+	if len(m.PartitionSpecs) == 0 {
+		m.PartitionSpecs = []*PartitionSpec{spec}
+		m.LastPartitionID = partitionFieldBase + len(spec.Fields) - 1
+		return nil
+	}
+	if current := m.Spec(); current == nil || !sameSpec(spec, current) {
+		return fmt.Errorf("iceberg: %s: %s partitions by %v, the table by %v; partition evolution is not supported", TableName(s), s, spec, current)
+	}
+	return nil
+}
+
+// snapshotSummary returns the summary of a snapshot doing c after parent.
+func snapshotSummary(parent *Snapshot, op string, c *Commit, addedSize, deletedSize int64) map[string]string {
+	total := func(key string) int64 {
+		if parent == nil {
+			return 0
+		}
+		n, _ := strconv.ParseInt(parent.Summary[key], 10, 64)
+		return n
+	}
+	itoa := func(n int64) string { return strconv.FormatInt(n, 10) }
+	summary := map[string]string{
+		"operation":              op,
+		"added-data-files":       itoa(int64(c.AddedFiles)),
+		"added-records":          itoa(c.AddedRecords),
+		"added-files-size":       itoa(addedSize),
+		"total-data-files":       itoa(total("total-data-files") + int64(c.AddedFiles-c.DeletedFiles)),
+		"total-records":          itoa(total("total-records") + c.AddedRecords - c.DeletedRecords),
+		"total-files-size":       itoa(total("total-files-size") + addedSize - deletedSize),
+		"total-delete-files":     itoa(total("total-delete-files")),
+		"total-position-deletes": itoa(total("total-position-deletes")),
+		"total-equality-deletes": itoa(total("total-equality-deletes")),
+	}
+	if c.DeletedFiles > 0 {
+		summary["deleted-data-files"] = itoa(int64(c.DeletedFiles))
+		summary["deleted-records"] = itoa(c.DeletedRecords)
+		summary["removed-files-size"] = itoa(deletedSize)
+	}
+	return summary
+}
+
+// writeManifest writes entries as a manifest of snapshot snap and returns
+// its manifest list record.
+func (w *Writer) writeManifest(ctx context.Context, m *Metadata, spec *PartitionSpec, cols []partitionCol, snap *Snapshot, entries []*entry) (*manifestFile, error) {
+	data, err := writeManifest(m, spec, cols, entries)
+	if err != nil {
+		return nil, err
+	}
+	mf := &manifestFile{
+		path:        fmt.Sprintf("%s/metadata/%s-m0.avro", m.Location, newUUID()),
+		length:      int64(len(data)),
+		specID:      int32(spec.SpecID),
+		content:     contentData,
+		sequence:    snap.SequenceNumber,
+		minSequence: snap.SequenceNumber,
+		snapshotID:  snap.SnapshotID,
+		partitions:  summarize(cols, entries),
+	}
+	for _, e := range entries {
+		switch e.status {
+		case statusAdded:
+			mf.added++
+			mf.addedRows += e.file.Records
+		case statusExisting:
+			mf.existing++
+			mf.existingRows += e.file.Records
+		case statusDeleted:
+			mf.deleted++
+			mf.deletedRows += e.file.Records
+		}
+		if e.status != statusDeleted && e.sequence < mf.minSequence {
+			mf.minSequence = e.sequence
+		}
+	}
+	return mf, w.write(ctx, mf.path, data, "avro/binary")
+}
+
+// manifests reads the manifest list of snap.
+func (w *Writer) manifests(ctx context.Context, snap *Snapshot) ([]*manifestFile, error) {
+	data, err := w.read(ctx, snap.ManifestList)
+	if err != nil {
+		return nil, err
+	}
+	list, err := readManifestList(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, snap.ManifestList)
+	}
+	return list, nil
+}
+
+// entries reads the entries of data manifest mf of table m.
+func (w *Writer) entries(ctx context.Context, m *Metadata, mf *manifestFile) ([]*entry, error) {
+	var spec *PartitionSpec
+	for _, s := range m.PartitionSpecs {
+		if s.SpecID == int(mf.specID) {
+			spec = s
+		}
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("iceberg: manifest %s: no partition spec %d", mf.path, mf.specID)
+	}
+	cols, err := partitionCols(m, spec)
+	if err != nil {
+		return nil, err
+	}
+	data, err := w.read(ctx, mf.path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readManifest(data, mf, cols)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, mf.path)
+	}
+	return entries, nil
+}
+
+// swap points the Glue table of s to the metadata file location, creating
+// the table if t has none. It returns ErrConflict if the table changed
+// since t was read.
+func (w *Writer) swap(ctx context.Context, s *schema.Schema, t *table, m *Metadata, location string) error {
+	db := w.database()
+	in := w.glueTable(s, m, location, t.location)
+	if t.glue == nil {
+		_, err := w.Glue.CreateTableWithContext(ctx, &glue.CreateTableInput{DatabaseName: aws.String(db), TableInput: in})
+		if isCode(err, glue.ErrCodeAlreadyExistsException) {
+			return fmt.Errorf("%w: %s.%s was created concurrently", ErrConflict, db, *in.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("create %s.%s: %w", db, *in.Name, err)
+		}
+		return nil
+	}
+	_, err := w.Glue.UpdateTableWithContext(ctx, &glue.UpdateTableInput{
+		DatabaseName: aws.String(db),
+		TableInput:   in,
+		// Glue refuses the update if the table changed since GetTable.
+		VersionId: t.glue.VersionId,
+	})
+	if isCode(err, glue.ErrCodeConcurrentModificationException) {
+		return fmt.Errorf("%w: %s.%s: %v", ErrConflict, db, *in.Name, err)
+	}
+	if err != nil {
+		return fmt.Errorf("update %s.%s: %w", db, *in.Name, err)
+	}
+	return nil
+}
+
+// glueTable returns the Glue definition of the Iceberg table of s at
+// metadata file location. Athena reads the schema from the metadata; the
+// columns are for the console and crawlers.
+func (w *Writer) glueTable(s *schema.Schema, m *Metadata, location, previous string) *glue.TableInput {
+	hive := s.GlueTable(w.Bucket)
+	params := map[string]*string{
+		paramTableType:            aws.String(tableTypeIceberg),
+		paramMetadataLocation:     aws.String(location),
+		schema.ParamSchemaVersion: aws.String(strconv.Itoa(s.Version)),
+		"EXTERNAL":                aws.String("TRUE"),
+	}
+	if previous != "" {
+		params[paramPreviousLocation] = aws.String(previous)
+	}
+	return &glue.TableInput{
+		Name:        aws.String(TableName(s)),
+		Description: hive.Description,
+		TableType:   aws.String("EXTERNAL_TABLE"),
+		Parameters:  params,
+		StorageDescriptor: &glue.StorageDescriptor{
+			Location: aws.String(m.Location),
+			Columns:  append(hive.StorageDescriptor.Columns, hive.PartitionKeys...),
+		},
+	}
+}
+
+// read returns the object at an s3:// location of the bucket.
+func (w *Writer) read(ctx context.Context, location string) ([]byte, error) {
+	key, err := w.key(location)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := w.Objects.Get(ctx, w.Bucket, key, "")
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", location, err)
+	}
+	defer obj.Body.Close()
+	data, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", location, err)
+	}
+	return data, nil
+}
+
+// write puts data at an s3:// location of the bucket.
+func (w *Writer) write(ctx context.Context, location string, data []byte, contentType string) error {
+	key, err := w.key(location)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Objects.Put(ctx, w.Bucket, key, bytes.NewReader(data), objectstore.PutOptions{ContentType: contentType}); err != nil {
+		return fmt.Errorf("write %s: %w", location, err)
+	}
+	return nil
+}
+
+func (w *Writer) key(location string) (string, error) {
+	key, ok := strings.CutPrefix(location, "s3://"+w.Bucket+"/")
+	if !ok {
+		return "", fmt.Errorf("iceberg: %s is not in bucket %s", location, w.Bucket)
+	}
+	return key, nil
+}
+
+func (w *Writer) database() string {
+	if w.Database == "" {
+		return catalog.DefaultDatabases[schema.Gold]
+	}
+	return w.Database
+}
+
+func (w *Writer) now() time.Time {
+	if w.Now != nil {
+		return w.Now()
+	}
+	return time.Now()
+}
+
+func (w *Writer) logf(format string, args ...interface{}) {
+	if w.Logger != nil {
+		w.Logger.Printf(format, args...)
+	}
+}
+
+// metadataVersion returns the version a metadata file name starts with,
+// -1 for none.
+func metadataVersion(location string) int {
+	if location == "" {
+		return -1
+	}
+	name := location[strings.LastIndex(location, "/")+1:]
+	digits, _, _ := strings.Cut(name, "-")
+	v, err := strconv.Atoi(digits)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// newSnapshotID returns a random positive snapshot id.
+func newSnapshotID() int64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isCode(err error, code string) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == code
+}
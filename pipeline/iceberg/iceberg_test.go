@@ -0,0 +1,437 @@
+package iceberg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+)
+
+const bucket = "claim-dev-lake"
+
+// newWriter returns a Writer over a warehouse in a temporary directory and
+// an in-memory Glue catalog.
+func newWriter(t *testing.T) (*Writer, *catalog.Fake) {
+	t.Helper()
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	glueAPI := catalog.NewFake("claim_gold_db")
+	clock := time.Date(2025, 12, 3, 7, 0, 0, 0, time.UTC)
+	return &Writer{
+		Objects: objectstore.NewDir(t.TempDir()),
+		Glue:    glueAPI,
+		Schemas: r,
+		Bucket:  bucket,
+		Now: func() time.Time {
+			clock = clock.Add(time.Minute)
+			return clock
+		},
+	}, glueAPI
+}
+
+func latest(t *testing.T, w *Writer, name string) *schema.Schema {
+	t.Helper()
+	s, err := w.Schemas.Latest(name)
+	require.NoError(t, err)
+	return s
+}
+
+// file returns a data file of the table of s; the commit does not read it.
+func file(w *Writer, s *schema.Schema, partition, name string, records int64) DataFile {
+	dir := w.Location(s) + "/data/"
+	if partition != "" {
+		dir += partition + "/"
+	}
+	return DataFile{Path: dir + name + ".parquet", Partition: partition, Records: records, Size: 100 * records}
+}
+
+const (
+	day1 = "year=2025/month=12/day=01"
+	day2 = "year=2025/month=12/day=02"
+	day3 = "year=2025/month=12/day=03"
+)
+
+func TestAppendCreatesTable(t *testing.T) {
+	ctx := context.Background()
+	w, glueAPI := newWriter(t)
+	s := latest(t, w, "fact_payment")
+
+	c, err := w.Append(ctx, s, []DataFile{file(w, s, day1, "a", 3), file(w, s, day2, "b", 2)})
+	require.NoError(t, err)
+	assert.Equal(t, "fact_payment_iceberg", c.Table)
+	assert.Equal(t, OpAppend, c.Operation)
+	assert.Equal(t, int64(1), c.SequenceNumber)
+	assert.Equal(t, 2, c.AddedFiles)
+	assert.Equal(t, int64(5), c.AddedRecords)
+	assert.Equal(t, int64(5), c.TotalRecords)
+	assert.Equal(t, 1, c.Attempts)
+	assert.Regexp(t, `^s3://claim-dev-lake/gold/_iceberg/fact_payment/metadata/00000-[0-9a-f-]{36}\.metadata\.json$`, c.MetadataLocation)
+
+	out, err := glueAPI.GetTableWithContext(ctx, &glue.GetTableInput{DatabaseName: aws.String("claim_gold_db"), Name: aws.String("fact_payment_iceberg")})
+	require.NoError(t, err)
+	params := aws.StringValueMap(out.Table.Parameters)
+	assert.Equal(t, "ICEBERG", params["table_type"])
+	assert.Equal(t, c.MetadataLocation, params["metadata_location"])
+	assert.NotContains(t, params, "previous_metadata_location")
+	assert.Equal(t, "1", params[schema.ParamSchemaVersion])
+	assert.Equal(t, "s3://claim-dev-lake/gold/_iceberg/fact_payment", aws.StringValue(out.Table.StorageDescriptor.Location))
+	assert.Len(t, out.Table.StorageDescriptor.Columns, len(s.Columns)+3, "Partition columns are columns of an Iceberg table")
+	assert.Empty(t, out.Table.PartitionKeys)
+
+	m, err := w.Metadata(ctx, s)
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.FormatVersion)
+	assert.Equal(t, w.Location(s), m.Location)
+	ts := m.CurrentSchema()
+	require.NotNil(t, ts)
+	assert.Equal(t, &Field{ID: 1, Name: "payment_key", Required: true, Type: "long", Doc: "Surrogate key of the claim payment."}, ts.Fields[0])
+	assert.Equal(t, &Field{ID: 15, Name: "patient_responsibility", Type: "decimal(12, 2)"}, ts.Fields[14])
+	assert.Equal(t, &Field{ID: 18, Name: "year", Required: true, Type: "string"}, ts.Fields[17])
+	assert.Equal(t, 20, m.LastColumnID)
+	assert.Equal(t, []*PartitionField{
+		{SourceID: 18, FieldID: 1000, Name: "year", Transform: "identity"},
+		{SourceID: 19, FieldID: 1001, Name: "month", Transform: "identity"},
+		{SourceID: 20, FieldID: 1002, Name: "day", Transform: "identity"},
+	}, m.Spec().Fields)
+	assert.Equal(t, 1002, m.LastPartitionID)
+	assert.Contains(t, m.Properties[propNameMapping], `{"field-id":1,"names":["payment_key"]}`)
+	assert.Equal(t, "parquet", m.Properties[propFormat])
+
+	snap := m.CurrentSnapshot()
+	require.NotNil(t, snap)
+	assert.Nil(t, snap.ParentSnapshotID)
+	assert.Equal(t, c.SnapshotID, snap.SnapshotID)
+	assert.Equal(t, map[string]string{
+		"operation": "append", "added-data-files": "2", "added-records": "5", "added-files-size": "500",
+		"total-data-files": "2", "total-records": "5", "total-files-size": "500",
+		"total-delete-files": "0", "total-position-deletes": "0", "total-equality-deletes": "0",
+	}, snap.Summary)
+	assert.Equal(t, &Ref{SnapshotID: snap.SnapshotID, Type: "branch"}, m.Refs["main"])
+
+	manifests, err := w.manifests(ctx, snap)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	mf := manifests[0]
+	assert.Equal(t, int32(2), mf.added)
+	assert.Equal(t, int64(5), mf.addedRows)
+	assert.Equal(t, int64(1), mf.sequence)
+	assert.Equal(t, snap.SnapshotID, mf.snapshotID)
+	assert.Equal(t, avroRecord{509: false, 518: nil, 510: []byte("01"), 511: []byte("02")}, mf.partitions[2], "The manifest list bounds the days")
+
+	files, err := w.Files(ctx, s)
+	require.NoError(t, err)
+	assert.Equal(t, []DataFile{file(w, s, day1, "a", 3), file(w, s, day2, "b", 2)}, files)
+
+	// A second append builds on the first.
+	c2, err := w.Append(ctx, s, []DataFile{file(w, s, day1, "c", 4)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), c2.SequenceNumber)
+	assert.Equal(t, int64(9), c2.TotalRecords)
+	assert.Regexp(t, `/metadata/00001-`, c2.MetadataLocation)
+
+	m, err = w.Metadata(ctx, s)
+	require.NoError(t, err)
+	require.Len(t, m.Snapshots, 2)
+	assert.Equal(t, c.SnapshotID, aws.Int64Value(m.CurrentSnapshot().ParentSnapshotID))
+	assert.Equal(t, []*MetadataLog{{TimestampMS: snap.TimestampMS, MetadataFile: c.MetadataLocation}}, m.MetadataLog)
+	assert.Len(t, m.SnapshotLog, 2)
+	assert.Len(t, m.Schemas, 1, "An unchanged schema is not added again")
+
+	manifests, err = w.manifests(ctx, m.CurrentSnapshot())
+	require.NoError(t, err)
+	require.Len(t, manifests, 2, "The first manifest is kept as it is")
+	assert.Equal(t, int64(2), manifests[0].sequence)
+	assert.Equal(t, mf, manifests[1])
+
+	out, err = glueAPI.GetTableWithContext(ctx, &glue.GetTableInput{DatabaseName: aws.String("claim_gold_db"), Name: aws.String("fact_payment_iceberg")})
+	require.NoError(t, err)
+	assert.Equal(t, c.MetadataLocation, aws.StringValue(out.Table.Parameters["previous_metadata_location"]))
+
+	files, err = w.Files(ctx, s)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+}
+
+func TestOverwritePartitions(t *testing.T) {
+	ctx := context.Background()
+	w, _ := newWriter(t)
+	s := latest(t, w, "fact_payment")
+
+	_, err := w.Append(ctx, s, []DataFile{file(w, s, day1, "a", 3), file(w, s, day2, "b", 2), file(w, s, day3, "c", 1)})
+	require.NoError(t, err)
+	_, err = w.Append(ctx, s, []DataFile{file(w, s, day1, "d", 5)})
+	require.NoError(t, err)
+
+	// Day 1 gets a new file, day 2 is emptied and day 3 is left alone.
+	c, err := w.Overwrite(ctx, s, []string{day1, day2}, []DataFile{file(w, s, day1, "e", 7)})
+	require.NoError(t, err)
+	assert.Equal(t, OpOverwrite, c.Operation)
+	assert.Equal(t, 1, c.AddedFiles)
+	assert.Equal(t, 3, c.DeletedFiles)
+	assert.Equal(t, int64(10), c.DeletedRecords)
+	assert.Equal(t, int64(8), c.TotalRecords)
+
+	files, err := w.Files(ctx, s)
+	require.NoError(t, err)
+	assert.Equal(t, []DataFile{file(w, s, day1, "e", 7), file(w, s, day3, "c", 1)}, files)
+
+	m, err := w.Metadata(ctx, s)
+	require.NoError(t, err)
+	snap := m.CurrentSnapshot()
+	assert.Equal(t, "3", snap.Summary["deleted-data-files"])
+	assert.Equal(t, "10", snap.Summary["deleted-records"])
+	assert.Equal(t, "2", snap.Summary["total-data-files"])
+
+	manifests, err := w.manifests(ctx, snap)
+	require.NoError(t, err)
+	require.Len(t, manifests, 3, "The added files, then both earlier manifests rewritten")
+	var statuses []string
+	for _, mf := range manifests {
+		entries, err := w.entries(ctx, m, mf)
+		require.NoError(t, err)
+		for _, e := range entries {
+			statuses = append(statuses, fmt.Sprintf("%s:%d@%d", e.file.Path[len(e.file.Path)-9:], e.status, e.sequence))
+		}
+	}
+	assert.Equal(t, []string{
+		"e.parquet:1@3",
+		"d.parquet:2@2",
+		"a.parquet:2@1", "b.parquet:2@1", "c.parquet:0@1",
+	}, statuses, "Existing and deleted entries keep the sequence number they were added with")
+	assert.Equal(t, int64(1), manifests[2].minSequence)
+	assert.Equal(t, int64(3), manifests[2].sequence)
+	assert.Equal(t, int32(1), manifests[2].existing)
+	assert.Equal(t, int32(2), manifests[2].deleted)
+
+	// A later overwrite drops the entries deleted before, and the manifest
+	// holding nothing else.
+	_, err = w.Overwrite(ctx, s, []string{day3}, nil)
+	require.NoError(t, err)
+	m, err = w.Metadata(ctx, s)
+	require.NoError(t, err)
+	manifests, err = w.manifests(ctx, m.CurrentSnapshot())
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+	entries, err := w.entries(ctx, m, manifests[1])
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, int32(statusDeleted), entries[0].status)
+	assert.Equal(t, m.CurrentSnapshotID, entries[0].snapshotID)
+
+	files, err = w.Files(ctx, s)
+	require.NoError(t, err)
+	assert.Equal(t, []DataFile{file(w, s, day1, "e", 7)}, files)
+}
+
+func TestOverwriteRejects(t *testing.T) {
+	ctx := context.Background()
+	w, _ := newWriter(t)
+	s := latest(t, w, "fact_payment")
+
+	_, err := w.Overwrite(ctx, s, []string{day1}, []DataFile{file(w, s, day2, "a", 1)})
+	assert.EqualError(t, err, "iceberg: fact_payment_iceberg: file s3://claim-dev-lake/gold/_iceberg/fact_payment/data/year=2025/month=12/day=02/a.parquet is not in an overwritten partition")
+	_, err = w.Overwrite(ctx, s, []string{"year=2025/month=12"}, nil)
+	assert.EqualError(t, err, `iceberg: partition "year=2025/month=12" does not match the partition fields`)
+	_, err = w.Append(ctx, s, []DataFile{file(w, s, "", "a", 1)})
+	assert.EqualError(t, err, `iceberg: partition "" does not match the partition fields (file s3://claim-dev-lake/gold/_iceberg/fact_payment/data/a.parquet)`)
+
+	m, err := w.Metadata(ctx, s)
+	require.NoError(t, err)
+	assert.Nil(t, m, "Nothing was committed")
+}
+
+func TestOverwriteUnpartitioned(t *testing.T) {
+	ctx := context.Background()
+	w, _ := newWriter(t)
+	s := latest(t, w, "dim_plan")
+
+	_, err := w.Append(ctx, s, []DataFile{file(w, s, "", "a", 3), file(w, s, "", "b", 1)})
+	require.NoError(t, err)
+	c, err := w.Overwrite(ctx, s, nil, []DataFile{file(w, s, "", "c", 4)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.DeletedFiles)
+
+	files, err := w.Files(ctx, s)
+	require.NoError(t, err)
+	assert.Equal(t, []DataFile{file(w, s, "", "c", 4)}, files)
+	m, err := w.Metadata(ctx, s)
+	require.NoError(t, err)
+	assert.Empty(t, m.Spec().Fields)
+	assert.Equal(t, 999, m.LastPartitionID)
+}
+
+func TestSchemaEvolution(t *testing.T) {
+	ctx := context.Background()
+	w, _ := newWriter(t)
+	s := latest(t, w, "dim_plan")
+	_, err := w.Append(ctx, s, []DataFile{file(w, s, "", "a", 3)})
+	require.NoError(t, err)
+
+	// v2 renames insurance_line and adds a column.
+	v2 := *s
+	v2.Version = 2
+	v2.Columns = append([]*schema.Column{}, s.Columns...)
+	renamed := *s.Column("insurance_line")
+	renamed.Name, renamed.RenamedFrom = "line_of_business", "insurance_line"
+	v2.Columns[2] = &renamed
+	v2.Columns = append(v2.Columns, &schema.Column{Name: "plan_name", Type: schema.Type{Kind: schema.String}, Nullable: true})
+	_, err = w.Append(ctx, &v2, []DataFile{file(w, s, "", "b", 1)})
+	require.NoError(t, err)
+
+	m, err := w.Metadata(ctx, s)
+	require.NoError(t, err)
+	require.Len(t, m.Schemas, 2)
+	assert.Equal(t, 1, m.CurrentSchemaID)
+	assert.Equal(t, 1, m.CurrentSnapshot().SchemaID)
+	assert.Equal(t, 3, m.CurrentSchema().Field("line_of_business").ID, "A renamed column keeps its id")
+	assert.Equal(t, 5, m.CurrentSchema().Field("plan_name").ID)
+	assert.Equal(t, 5, m.LastColumnID)
+	assert.Contains(t, m.Properties[propNameMapping], `{"field-id":3,"names":["insurance_line","line_of_business"]}`,
+		"Files written before the rename still map")
+	assert.NotNil(t, m.Schemas[0].Field("insurance_line"), "Earlier schemas are kept")
+
+	// Partitioning cannot change.
+	f := latest(t, w, "fact_payment")
+	_, err = w.Append(ctx, f, nil)
+	require.NoError(t, err)
+	monthly := *f
+	monthly.Partitions = f.Partitions[:2]
+	_, err = w.Append(ctx, &monthly, nil)
+	assert.EqualError(t, err, "iceberg: fact_payment_iceberg: fact_payment v1 partitions by [identity(year), identity(month)], "+
+		"the table by [identity(year), identity(month), identity(day)]; partition evolution is not supported")
+}
+
+// racingGlue runs race, once, before the next create or update of a table:
+// a commit made while another was between reading the table and swapping
+// its metadata.
+type racingGlue struct {
+	*catalog.Fake
+	race func()
+}
+
+func (g *racingGlue) run() {
+	if race := g.race; race != nil {
+		g.race = nil
+		race()
+	}
+}
+
+func (g *racingGlue) CreateTableWithContext(ctx aws.Context, in *glue.CreateTableInput, opts ...request.Option) (*glue.CreateTableOutput, error) {
+	g.run()
+	return g.Fake.CreateTableWithContext(ctx, in, opts...)
+}
+
+func (g *racingGlue) UpdateTableWithContext(ctx aws.Context, in *glue.UpdateTableInput, opts ...request.Option) (*glue.UpdateTableOutput, error) {
+	g.run()
+	return g.Fake.UpdateTableWithContext(ctx, in, opts...)
+}
+
+func TestCommitRetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+	other, glueAPI := newWriter(t)
+	racing := &racingGlue{Fake: glueAPI}
+	w := *other
+	w.Glue = racing
+	s := latest(t, &w, "fact_payment")
+
+	// Both create the table; the other writer wins.
+	racing.race = func() {
+		_, err := other.Append(ctx, s, []DataFile{file(other, s, day1, "a", 1)})
+		require.NoError(t, err)
+	}
+	c, err := w.Append(ctx, s, []DataFile{file(&w, s, day1, "b", 2)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.Attempts)
+	assert.Equal(t, int64(2), c.SequenceNumber)
+	assert.Equal(t, int64(3), c.TotalRecords)
+
+	// An overwrite loses to an append, and is redone on top of it.
+	racing.race = func() {
+		_, err := other.Append(ctx, s, []DataFile{file(other, s, day2, "c", 4)})
+		require.NoError(t, err)
+	}
+	c, err = w.Overwrite(ctx, s, []string{day1}, []DataFile{file(&w, s, day1, "d", 8)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.Attempts)
+	assert.Equal(t, 2, c.DeletedFiles)
+	files, err := w.Files(ctx, s)
+	require.NoError(t, err)
+	assert.Equal(t, []DataFile{file(&w, s, day1, "d", 8), file(&w, s, day2, "c", 4)}, files)
+
+	m, err := w.Metadata(ctx, s)
+	require.NoError(t, err)
+	assert.Len(t, m.Snapshots, 4, "Lost attempts leave no snapshot")
+	assert.Len(t, m.MetadataLog, 3)
+	for i, snap := range m.Snapshots {
+		assert.Equal(t, int64(i+1), snap.SequenceNumber)
+		if i > 0 {
+			assert.Equal(t, m.Snapshots[i-1].SnapshotID, aws.Int64Value(snap.ParentSnapshotID))
+		}
+	}
+
+	// Out of attempts, the commit fails.
+	w.MaxAttempts = 1
+	racing.race = func() {
+		_, err := other.Append(ctx, s, nil)
+		require.NoError(t, err)
+	}
+	_, err = w.Append(ctx, s, []DataFile{file(&w, s, day3, "e", 1)})
+	assert.True(t, errors.Is(err, ErrConflict), "%v", err)
+	files, err = w.Files(ctx, s)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestLoadRejectsOtherTables(t *testing.T) {
+	ctx := context.Background()
+	w, glueAPI := newWriter(t)
+	s := latest(t, w, "dim_plan")
+	hive := s.GlueTable(bucket)
+	hive.Name = aws.String(TableName(s))
+	_, err := glueAPI.CreateTableWithContext(ctx, &glue.CreateTableInput{DatabaseName: aws.String("claim_gold_db"), TableInput: hive})
+	require.NoError(t, err)
+
+	_, err = w.Append(ctx, s, nil)
+	assert.EqualError(t, err, "iceberg: claim_gold_db.dim_plan_iceberg is not an Iceberg table")
+}
+
+func TestMetadataJSON(t *testing.T) {
+	ctx := context.Background()
+	w, _ := newWriter(t)
+	s := latest(t, w, "dim_plan")
+	c, err := w.Append(ctx, s, nil)
+	require.NoError(t, err)
+
+	key, err := w.key(c.MetadataLocation)
+	require.NoError(t, err)
+	obj, err := w.Objects.Get(ctx, bucket, key, "")
+	require.NoError(t, err)
+	defer obj.Body.Close()
+	data, err := io.ReadAll(obj.Body)
+	require.NoError(t, err)
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	for _, field := range []string{
+		"format-version", "table-uuid", "location", "last-sequence-number", "last-updated-ms", "last-column-id",
+		"schemas", "current-schema-id", "partition-specs", "default-spec-id", "last-partition-id",
+		"sort-orders", "default-sort-order-id", "current-snapshot-id", "refs", "snapshots", "snapshot-log", "metadata-log",
+	} {
+		assert.Contains(t, raw, field, "Version 2 metadata needs %s", field)
+	}
+	assert.Equal(t, []interface{}{map[string]interface{}{"order-id": float64(0), "fields": []interface{}{}}}, raw["sort-orders"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"spec-id": float64(0), "fields": []interface{}{}}}, raw["partition-specs"])
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, raw["table-uuid"])
+}
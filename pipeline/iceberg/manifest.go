@@ -0,0 +1,436 @@
+package iceberg
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/schema"
+)
+
+// DataFile is a Parquet data file of a table.
+type DataFile struct {
+	// Path is the file's s3:// location.
+	Path string `json:"path"`
+	// Partition is the file's partition path, as
+	// schema.Schema.PartitionPath returns it; empty in an unpartitioned
+	// table.
+	Partition string `json:"partition,omitempty"`
+	Records   int64  `json:"records"`
+	Size      int64  `json:"size"`
+}
+
+// Statuses of a manifest entry.
+const (
+	statusExisting = 0
+	statusAdded    = 1
+	statusDeleted  = 2
+)
+
+// Contents of a manifest.
+const (
+	contentData    = 0
+	contentDeletes = 1
+)
+
+// entry is a manifest entry: a data file and its status in the snapshot
+// that wrote the manifest. Sequence numbers and the snapshot id are as
+// inherited from the manifest when the entry leaves them out.
+type entry struct {
+	status       int32
+	snapshotID   int64
+	sequence     int64
+	fileSequence int64
+	file         DataFile
+	// values are the file's partition values, typed.
+	values []interface{}
+}
+
+// manifestFile is a manifest as its manifest list records it.
+type manifestFile struct {
+	path                                 string
+	length                               int64
+	specID                               int32
+	content                              int32
+	sequence, minSequence                int64
+	snapshotID                           int64
+	added, existing, deleted             int32
+	addedRows, existingRows, deletedRows int64
+	// partitions summarizes each partition field, as Avro records.
+	partitions []interface{}
+}
+
+var manifestListSchema = &avroSchema{Type: "record", Name: "manifest_file", Fields: []*avroField{
+	{Name: "manifest_path", Type: avroString, ID: 500},
+	{Name: "manifest_length", Type: avroLong, ID: 501},
+	{Name: "partition_spec_id", Type: avroInt, ID: 502},
+	{Name: "content", Type: avroInt, ID: 517},
+	{Name: "sequence_number", Type: avroLong, ID: 515},
+	{Name: "min_sequence_number", Type: avroLong, ID: 516},
+	{Name: "added_snapshot_id", Type: avroLong, ID: 503},
+	{Name: "added_files_count", Type: avroInt, ID: 504},
+	{Name: "existing_files_count", Type: avroInt, ID: 505},
+	{Name: "deleted_files_count", Type: avroInt, ID: 506},
+	{Name: "added_rows_count", Type: avroLong, ID: 512},
+	{Name: "existing_rows_count", Type: avroLong, ID: 513},
+	{Name: "deleted_rows_count", Type: avroLong, ID: 514},
+	{Name: "partitions", ID: 507, Type: optional(&avroSchema{Type: "array", ElementID: 508, Items: &avroSchema{
+		Type: "record", Name: "r508", Fields: []*avroField{
+			{Name: "contains_null", Type: avroBoolean, ID: 509},
+			{Name: "contains_nan", Type: optional(avroBoolean), ID: 518},
+			{Name: "lower_bound", Type: optional(avroBytes), ID: 510},
+			{Name: "upper_bound", Type: optional(avroBytes), ID: 511},
+		},
+	}})},
+}}
+
+// entrySchema returns the schema of the entries of a manifest whose
+// partition tuples are of schema partition.
+func entrySchema(partition *avroSchema) *avroSchema {
+	return &avroSchema{Type: "record", Name: "manifest_entry", Fields: []*avroField{
+		{Name: "status", Type: avroInt, ID: 0},
+		{Name: "snapshot_id", Type: optional(avroLong), ID: 1},
+		{Name: "sequence_number", Type: optional(avroLong), ID: 3},
+		{Name: "file_sequence_number", Type: optional(avroLong), ID: 4},
+		{Name: "data_file", ID: 2, Type: &avroSchema{Type: "record", Name: "r2", Fields: []*avroField{
+			{Name: "content", Type: avroInt, ID: 134},
+			{Name: "file_path", Type: avroString, ID: 100},
+			{Name: "file_format", Type: avroString, ID: 101},
+			{Name: "partition", Type: partition, ID: 102},
+			{Name: "record_count", Type: avroLong, ID: 103},
+			{Name: "file_size_in_bytes", Type: avroLong, ID: 104},
+		}}},
+	}}
+}
+
+// partitionCol is a field of a partition spec and the type of its source
+// column.
+type partitionCol struct {
+	name string
+	id   int
+	typ  string
+}
+
+// partitionCols returns the fields of spec, a spec of m.
+func partitionCols(m *Metadata, spec *PartitionSpec) ([]partitionCol, error) {
+	cols := make([]partitionCol, len(spec.Fields))
+	for i, f := range spec.Fields {
+		var source *Field
+		for _, s := range m.Schemas {
+			for _, sf := range s.Fields {
+				if sf.ID == f.SourceID {
+					source = sf
+				}
+			}
+		}
+		if source == nil {
+			return nil, fmt.Errorf("iceberg: partition field %s has no source column %d", f.Name, f.SourceID)
+		}
+		if f.Transform != "identity" {
+			return nil, fmt.Errorf("iceberg: partition field %s: transform %s is not supported", f.Name, f.Transform)
+		}
+		switch source.Type {
+		case "string", "long", "int", "date", "boolean":
+		default:
+			return nil, fmt.Errorf("iceberg: partition field %s: type %s is not supported", f.Name, source.Type)
+		}
+		cols[i] = partitionCol{name: f.Name, id: f.FieldID, typ: source.Type}
+	}
+	return cols, nil
+}
+
+// partitionSchema returns the schema of the partition tuples of cols.
+func partitionSchema(cols []partitionCol) *avroSchema {
+	s := &avroSchema{Type: "record", Name: "r102"}
+	for _, c := range cols {
+		t := map[string]*avroSchema{"string": avroString, "long": avroLong, "int": avroInt, "date": avroDate, "boolean": avroBoolean}[c.typ]
+		s.Fields = append(s.Fields, &avroField{Name: c.name, Type: optional(t), ID: c.id})
+	}
+	return s
+}
+
+// parsePartition returns the typed values of partition path.
+func parsePartition(cols []partitionCol, path string) ([]interface{}, error) {
+	if len(cols) == 0 {
+		if path != "" {
+			return nil, fmt.Errorf("iceberg: partition %q of an unpartitioned table", path)
+		}
+		return nil, nil
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) != len(cols) {
+		return nil, fmt.Errorf("iceberg: partition %q does not match the partition fields", path)
+	}
+	values := make([]interface{}, len(cols))
+	for i, part := range parts {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || name != cols[i].name {
+			return nil, fmt.Errorf("iceberg: partition %q does not match the partition fields", path)
+		}
+		var err error
+		switch cols[i].typ {
+		case "string":
+			values[i] = value
+		case "long":
+			values[i], err = strconv.ParseInt(value, 10, 64)
+		case "int":
+			var n int64
+			n, err = strconv.ParseInt(value, 10, 32)
+			values[i] = int32(n)
+		case "date":
+			var d time.Time
+			d, err = schema.ParseDate(value)
+			values[i] = int32(d.Unix() / 86400)
+		case "boolean":
+			values[i], err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("iceberg: partition %q: %s: %w", path, cols[i].name, err)
+		}
+	}
+	return values, nil
+}
+
+// formatPartition returns the partition path of values.
+func formatPartition(cols []partitionCol, values []interface{}) string {
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		v := values[i]
+		if c.typ == "date" {
+			if days, ok := v.(int32); ok {
+				v = time.Unix(int64(days)*86400, 0).UTC().Format(schema.DateLayout)
+			}
+		}
+		parts[i] = fmt.Sprintf("%s=%v", c.name, v)
+	}
+	return strings.Join(parts, "/")
+}
+
+// bound returns the single-value serialization of a partition value.
+func bound(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return []byte(v)
+	case int64:
+		return binary.LittleEndian.AppendUint64(nil, uint64(v))
+	case int32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(v))
+	case bool:
+		if v {
+			return []byte{1}
+		}
+		return []byte{0}
+	}
+	return nil
+}
+
+// less orders partition values of one field.
+func less(a, b interface{}) bool {
+	switch a := a.(type) {
+	case string:
+		return a < b.(string)
+	case int64:
+		return a < b.(int64)
+	case int32:
+		return a < b.(int32)
+	case bool:
+		return !a && b.(bool)
+	}
+	return false
+}
+
+// summarize returns the field summaries of the partitions of entries.
+func summarize(cols []partitionCol, entries []*entry) []interface{} {
+	out := make([]interface{}, len(cols))
+	for i := range cols {
+		var lower, upper interface{}
+		null := false
+		for _, e := range entries {
+			v := e.values[i]
+			if v == nil {
+				null = true
+				continue
+			}
+			if lower == nil || less(v, lower) {
+				lower = v
+			}
+			if upper == nil || less(upper, v) {
+				upper = v
+			}
+		}
+		r := avroRecord{509: null, 518: nil, 510: nil, 511: nil}
+		if lower != nil {
+			r[510], r[511] = bound(lower), bound(upper)
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// writeManifest encodes entries as a data manifest of table m. An entry
+// keeps its sequence numbers and snapshot id unless it was added by the
+// snapshot writing the manifest, which they are inherited from.
+func writeManifest(m *Metadata, spec *PartitionSpec, cols []partitionCol, entries []*entry) ([]byte, error) {
+	ts, err := json.Marshal(m.CurrentSchema())
+	if err != nil {
+		return nil, err
+	}
+	sp, err := json.Marshal(spec.Fields)
+	if err != nil {
+		return nil, err
+	}
+	meta := map[string]string{
+		"schema":            string(ts),
+		"schema-id":         strconv.Itoa(m.CurrentSchemaID),
+		"partition-spec":    string(sp),
+		"partition-spec-id": strconv.Itoa(spec.SpecID),
+		"format-version":    "2",
+		"content":           "data",
+	}
+	records := make([]avroRecord, len(entries))
+	for i, e := range entries {
+		partition := avroRecord{}
+		for j, c := range cols {
+			partition[c.id] = e.values[j]
+		}
+		r := avroRecord{
+			0: e.status,
+			2: avroRecord{
+				134: int32(contentData),
+				100: e.file.Path,
+				101: "PARQUET",
+				102: partition,
+				103: e.file.Records,
+				104: e.file.Size,
+			},
+		}
+		if e.status != statusAdded {
+			r[1], r[3], r[4] = e.snapshotID, e.sequence, e.fileSequence
+		}
+		records[i] = r
+	}
+	return writeAvro(entrySchema(partitionSchema(cols)), meta, records)
+}
+
+// readManifest decodes the entries of data manifest mf, whose partition
+// fields are cols.
+func readManifest(data []byte, mf *manifestFile, cols []partitionCol) ([]*entry, error) {
+	f, err := readAvro(data)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*entry
+	for _, v := range f.Records {
+		r, _ := v.(avroRecord)
+		df, _ := r[2].(avroRecord)
+		partition, _ := df[102].(avroRecord)
+		status, ok1 := r.long(0)
+		records, ok2 := df.long(103)
+		size, ok3 := df.long(104)
+		path, ok4 := df[100].(string)
+		if !ok1 || !ok2 || !ok3 || !ok4 || partition == nil {
+			return nil, fmt.Errorf("iceberg: manifest %s: entry lacks required fields", mf.path)
+		}
+		e := &entry{status: int32(status), file: DataFile{Path: path, Records: records, Size: size}}
+		// Added entries may leave out what the manifest list has.
+		var ok bool
+		if e.snapshotID, ok = r.long(1); !ok {
+			e.snapshotID = mf.snapshotID
+		}
+		if e.sequence, ok = r.long(3); !ok {
+			e.sequence = mf.sequence
+		}
+		if e.fileSequence, ok = r.long(4); !ok {
+			e.fileSequence = mf.sequence
+		}
+		for _, c := range cols {
+			e.values = append(e.values, partition[c.id])
+		}
+		e.file.Partition = formatPartition(cols, e.values)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (mf *manifestFile) record() avroRecord {
+	r := avroRecord{
+		500: mf.path, 501: mf.length, 502: mf.specID, 517: mf.content,
+		515: mf.sequence, 516: mf.minSequence, 503: mf.snapshotID,
+		504: mf.added, 505: mf.existing, 506: mf.deleted,
+		512: mf.addedRows, 513: mf.existingRows, 514: mf.deletedRows,
+	}
+	if mf.partitions != nil {
+		r[507] = mf.partitions
+	}
+	return r
+}
+
+// writeManifestList encodes the manifest list of a snapshot.
+func writeManifestList(snap *Snapshot, manifests []*manifestFile) ([]byte, error) {
+	parent := "null"
+	if snap.ParentSnapshotID != nil {
+		parent = strconv.FormatInt(*snap.ParentSnapshotID, 10)
+	}
+	meta := map[string]string{
+		"snapshot-id":        strconv.FormatInt(snap.SnapshotID, 10),
+		"parent-snapshot-id": parent,
+		"sequence-number":    strconv.FormatInt(snap.SequenceNumber, 10),
+		"format-version":     "2",
+	}
+	records := make([]avroRecord, len(manifests))
+	for i, mf := range manifests {
+		records[i] = mf.record()
+	}
+	return writeAvro(manifestListSchema, meta, records)
+}
+
+// readManifestList decodes a manifest list.
+func readManifestList(data []byte) ([]*manifestFile, error) {
+	f, err := readAvro(data)
+	if err != nil {
+		return nil, err
+	}
+	var out []*manifestFile
+	for _, v := range f.Records {
+		r, _ := v.(avroRecord)
+		mf := &manifestFile{}
+		var ok bool
+		if mf.path, ok = r[500].(string); !ok {
+			return nil, fmt.Errorf("iceberg: manifest list entry without a manifest path")
+		}
+		longs := map[int]*int64{
+			501: &mf.length, 515: &mf.sequence, 516: &mf.minSequence, 503: &mf.snapshotID,
+			512: &mf.addedRows, 513: &mf.existingRows, 514: &mf.deletedRows,
+		}
+		for id, p := range longs {
+			if *p, ok = r.long(id); !ok {
+				return nil, fmt.Errorf("iceberg: manifest list entry %s lacks field %d", mf.path, id)
+			}
+		}
+		ints := map[int]*int32{502: &mf.specID, 517: &mf.content, 504: &mf.added, 505: &mf.existing, 506: &mf.deleted}
+		for id, p := range ints {
+			n, ok := r.long(id)
+			if !ok {
+				return nil, fmt.Errorf("iceberg: manifest list entry %s lacks field %d", mf.path, id)
+			}
+			*p = int32(n)
+		}
+		mf.partitions, _ = r[507].([]interface{})
+		out = append(out, mf)
+	}
+	return out, nil
+}
+
+// long returns the int or long field id of r.
+func (r avroRecord) long(id int) (int64, bool) {
+	switch v := r[id].(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	}
+	return 0, false
+}
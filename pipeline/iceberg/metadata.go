@@ -0,0 +1,298 @@
+package iceberg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"claim-management-system/pipeline/schema"
+)
+
+// Metadata is an Iceberg table metadata file, format version 2.
+type Metadata struct {
+	FormatVersion      int               `json:"format-version"`
+	TableUUID          string            `json:"table-uuid"`
+	Location           string            `json:"location"`
+	LastSequenceNumber int64             `json:"last-sequence-number"`
+	LastUpdatedMS      int64             `json:"last-updated-ms"`
+	LastColumnID       int               `json:"last-column-id"`
+	CurrentSchemaID    int               `json:"current-schema-id"`
+	Schemas            []*Schema         `json:"schemas"`
+	DefaultSpecID      int               `json:"default-spec-id"`
+	PartitionSpecs     []*PartitionSpec  `json:"partition-specs"`
+	LastPartitionID    int               `json:"last-partition-id"`
+	DefaultSortOrderID int               `json:"default-sort-order-id"`
+	SortOrders         []*SortOrder      `json:"sort-orders"`
+	Properties         map[string]string `json:"properties"`
+	// CurrentSnapshotID is -1 before the first snapshot.
+	CurrentSnapshotID int64           `json:"current-snapshot-id"`
+	Refs              map[string]*Ref `json:"refs"`
+	Snapshots         []*Snapshot     `json:"snapshots"`
+	SnapshotLog       []*SnapshotLog  `json:"snapshot-log"`
+	MetadataLog       []*MetadataLog  `json:"metadata-log"`
+}
+
+// Schema is a table schema: a struct of top-level fields.
+type Schema struct {
+	Type     string   `json:"type"`
+	SchemaID int      `json:"schema-id"`
+	Fields   []*Field `json:"fields"`
+}
+
+// Field is a column of a table schema.
+type Field struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Type     string `json:"type"`
+	Doc      string `json:"doc,omitempty"`
+}
+
+// PartitionSpec is how a table's rows are partitioned.
+type PartitionSpec struct {
+	SpecID int               `json:"spec-id"`
+	Fields []*PartitionField `json:"fields"`
+}
+
+// PartitionField partitions by a transform of the source column.
+type PartitionField struct {
+	SourceID  int    `json:"source-id"`
+	FieldID   int    `json:"field-id"`
+	Name      string `json:"name"`
+	Transform string `json:"transform"`
+}
+
+// SortOrder is how data files are sorted; the tables written here are
+// unsorted.
+type SortOrder struct {
+	OrderID int           `json:"order-id"`
+	Fields  []interface{} `json:"fields"`
+}
+
+// Ref is a named branch or tag.
+type Ref struct {
+	SnapshotID int64  `json:"snapshot-id"`
+	Type       string `json:"type"`
+}
+
+// Snapshot is the state of a table after a commit.
+type Snapshot struct {
+	SnapshotID       int64  `json:"snapshot-id"`
+	ParentSnapshotID *int64 `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64  `json:"sequence-number"`
+	TimestampMS      int64  `json:"timestamp-ms"`
+	// ManifestList is the location of the snapshot's manifest list.
+	ManifestList string            `json:"manifest-list"`
+	Summary      map[string]string `json:"summary"`
+	SchemaID     int               `json:"schema-id"`
+}
+
+// SnapshotLog records when a snapshot became current.
+type SnapshotLog struct {
+	TimestampMS int64 `json:"timestamp-ms"`
+	SnapshotID  int64 `json:"snapshot-id"`
+}
+
+// MetadataLog records a metadata file the table had before.
+type MetadataLog struct {
+	TimestampMS  int64  `json:"timestamp-ms"`
+	MetadataFile string `json:"metadata-file"`
+}
+
+// Table properties set on every table.
+const (
+	propFormat      = "write.format.default"
+	propCompression = "write.parquet.compression-codec"
+	// propNameMapping maps the columns of the data files, which carry no
+	// Iceberg field ids, to fields by name.
+	propNameMapping = "schema.name-mapping.default"
+)
+
+// Snapshot operations.
+const (
+	OpAppend    = "append"
+	OpOverwrite = "overwrite"
+)
+
+// mainBranch is the branch a commit moves.
+const mainBranch = "main"
+
+// CurrentSchema returns the table's current schema.
+func (m *Metadata) CurrentSchema() *Schema {
+	for _, s := range m.Schemas {
+		if s.SchemaID == m.CurrentSchemaID {
+			return s
+		}
+	}
+	return nil
+}
+
+// Spec returns the table's default partition spec.
+func (m *Metadata) Spec() *PartitionSpec {
+	for _, s := range m.PartitionSpecs {
+		if s.SpecID == m.DefaultSpecID {
+			return s
+		}
+	}
+	return nil
+}
+
+// CurrentSnapshot returns the snapshot main points to, or nil before the
+// first commit.
+func (m *Metadata) CurrentSnapshot() *Snapshot {
+	for _, s := range m.Snapshots {
+		if s.SnapshotID == m.CurrentSnapshotID {
+			return s
+		}
+	}
+	return nil
+}
+
+// Field returns the field named name, or nil.
+func (s *Schema) Field(name string) *Field {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// icebergType returns the Iceberg type of t. Timestamps are UTC but typed
+// without a zone, as Athena creates them.
+func icebergType(t schema.Type) string {
+	switch t.Kind {
+	case schema.Int:
+		return "long"
+	case schema.Decimal:
+		return fmt.Sprintf("decimal(%d, %d)", t.Precision, t.Scale)
+	case schema.Date:
+		return "date"
+	case schema.Timestamp:
+		return "timestamp"
+	case schema.Boolean:
+		return "boolean"
+	}
+	return "string"
+}
+
+// tableSchema returns the Iceberg schema of s: its columns, then its
+// partition columns. A column keeps the id it has in prev, found by name
+// or, for a renamed column, by its name before; new columns take ids after
+// lastID. It returns the last id assigned.
+func tableSchema(s *schema.Schema, prev *Schema, lastID int) (*Schema, int) {
+	out := &Schema{Type: "struct"}
+	add := func(c *schema.Column, required bool) {
+		f := &Field{Name: c.Name, Required: required, Type: icebergType(c.Type), Doc: c.Description}
+		if prev != nil {
+			old := prev.Field(c.Name)
+			if old == nil && c.RenamedFrom != "" {
+				old = prev.Field(c.RenamedFrom)
+			}
+			if old != nil {
+				f.ID = old.ID
+			}
+		}
+		if f.ID == 0 {
+			lastID++
+			f.ID = lastID
+		}
+		out.Fields = append(out.Fields, f)
+	}
+	for _, c := range s.Columns {
+		add(c, !c.Nullable)
+	}
+	for _, c := range s.Partitions {
+		add(c, true)
+	}
+	return out, lastID
+}
+
+// sameFields reports whether two schemas have the same fields.
+func sameFields(a, b *Schema) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i, f := range a.Fields {
+		if *f != *b.Fields[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// partitionFieldBase is the id of the first partition field.
+const partitionFieldBase = 1000
+
+// partitionSpec returns the spec partitioning by the identity of each
+// partition column of s, whose fields are in ts.
+func partitionSpec(s *schema.Schema, ts *Schema) *PartitionSpec {
+	spec := &PartitionSpec{Fields: []*PartitionField{}}
+	for i, c := range s.Partitions {
+		spec.Fields = append(spec.Fields, &PartitionField{
+			SourceID:  ts.Field(c.Name).ID,
+			FieldID:   partitionFieldBase + i,
+			Name:      c.Name,
+			Transform: "identity",
+		})
+	}
+	return spec
+}
+
+// sameSpec reports whether two specs partition alike.
+func sameSpec(a, b *PartitionSpec) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i, f := range a.Fields {
+		g := b.Fields[i]
+		if f.SourceID != g.SourceID || f.Name != g.Name || f.Transform != g.Transform {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *PartitionSpec) String() string {
+	names := make([]string, len(p.Fields))
+	for i, f := range p.Fields {
+		names[i] = f.Transform + "(" + f.Name + ")"
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// nameMapping returns the name mapping of the table's fields, for the
+// property propNameMapping. A field maps every name it has had, so files
+// written before a rename still resolve.
+func nameMapping(m *Metadata) string {
+	type mapped struct {
+		FieldID int      `json:"field-id"`
+		Names   []string `json:"names"`
+	}
+	var out []*mapped
+	byID := map[int]*mapped{}
+	for _, s := range m.Schemas {
+		for _, f := range s.Fields {
+			fm := byID[f.ID]
+			if fm == nil {
+				fm = &mapped{FieldID: f.ID}
+				byID[f.ID] = fm
+				out = append(out, fm)
+			}
+			if !contains(fm.Names, f.Name) {
+				fm.Names = append(fm.Names, f.Name)
+			}
+		}
+	}
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package iceberg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"claim-management-system/pipeline/gold"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/parquet"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// Report records what one Publish did.
+type Report struct {
+	GoldRun    string    `json:"gold_run"`
+	StartedAt  string    `json:"started_at"`
+	FinishedAt string    `json:"finished_at"`
+	Tables     []*Commit `json:"tables"`
+}
+
+// summaryGoldRun names, in a snapshot summary, the gold run it published.
+const summaryGoldRun = "gold-run"
+
+// Publish commits every table of gold run m, in the run's order, as an
+// overwrite of the partitions the run wrote or emptied; a dimension is
+// replaced whole. Each gold file with rows is first copied to a key of its
+// own under the table's data/ prefix: gold rewrites its files in place,
+// while a snapshot's files must not change. The files must still be those
+// of the run: their rows are checked against the manifest. Any error stops
+// Publish; tables committed before it stay.
+func (w *Writer) Publish(ctx context.Context, m *gold.Manifest) (*Report, error) {
+	format := silver.Parquet{}
+	if m.Format != format.Name() {
+		return nil, fmt.Errorf("iceberg: gold run %s is %s; Iceberg tables hold parquet", m.RunID, m.Format)
+	}
+	rep := &Report{GoldRun: m.RunID, StartedAt: metadata.FormatTime(w.now())}
+	for _, res := range m.Tables {
+		s, err := w.Schemas.Get(res.Table, res.Version)
+		if err != nil {
+			return nil, err
+		}
+		keys := map[string]string{"": s.Location + silver.PartFile + format.Ext()}
+		if s.PartitionBy != "" {
+			if keys, err = w.partitionFiles(ctx, s); err != nil {
+				return nil, err
+			}
+		}
+
+		var paths []string
+		var files []DataFile
+		var rows int64
+		for _, path := range sortedKeys(keys) {
+			paths = append(paths, path)
+			f, err := w.stage(ctx, s, path, keys[path])
+			if err != nil {
+				return nil, err
+			}
+			if f != nil {
+				files = append(files, *f)
+				rows += f.Records
+			}
+		}
+		if rows != res.Rows {
+			return nil, fmt.Errorf("iceberg: %s holds %d rows but gold run %s wrote %d; the files changed since the run", s.Location, rows, m.RunID, res.Rows)
+		}
+
+		c, err := w.commit(ctx, s, OpOverwrite, paths, files, map[string]string{summaryGoldRun: m.RunID})
+		if err != nil {
+			return nil, err
+		}
+		rep.Tables = append(rep.Tables, c)
+	}
+	rep.FinishedAt = metadata.FormatTime(w.now())
+	return rep, nil
+}
+
+// partitionFiles returns the gold files of the partitions of s by
+// partition path.
+func (w *Writer) partitionFiles(ctx context.Context, s *schema.Schema) (map[string]string, error) {
+	suffix := "/" + silver.PartFile + silver.Parquet{}.Ext()
+	keys, err := w.Objects.List(ctx, w.Bucket, s.Location)
+	if err != nil {
+		return nil, fmt.Errorf("list s3://%s/%s: %w", w.Bucket, s.Location, err)
+	}
+	files := map[string]string{}
+	for _, key := range keys {
+		if path, ok := strings.CutSuffix(strings.TrimPrefix(key, s.Location), suffix); ok {
+			files[path] = key
+		}
+	}
+	return files, nil
+}
+
+// stage copies the gold file key, of the partition at path, to a new key
+// under the data/ prefix of the Iceberg table of s. A file without rows is
+// not copied and nil is returned.
+func (w *Writer) stage(ctx context.Context, s *schema.Schema, path, key string) (*DataFile, error) {
+	object := fmt.Sprintf("s3://%s/%s", w.Bucket, key)
+	obj, err := w.Objects.Get(ctx, w.Bucket, key, "")
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", object, err)
+	}
+	data, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", object, err)
+	}
+	pf, err := parquet.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", object, err)
+	}
+	if pf.Rows == 0 {
+		return nil, nil
+	}
+
+	dir := w.Location(s) + "/data/"
+	if path != "" {
+		dir += path + "/"
+	}
+	f := &DataFile{Path: dir + newUUID() + silver.Parquet{}.Ext(), Partition: path, Records: pf.Rows, Size: int64(len(data))}
+	dst, err := w.key(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	// The version read is the one copied, in case gold rewrites it now.
+	_, err = w.Objects.Copy(ctx, w.Bucket, key, obj.VersionID, w.Bucket, dst, objectstore.PutOptions{ContentType: silver.Parquet{}.ContentType()})
+	if err != nil {
+		return nil, fmt.Errorf("copy %s to %s: %w", object, f.Path, err)
+	}
+	return f, nil
+}
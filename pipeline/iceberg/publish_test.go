@@ -0,0 +1,131 @@
+package iceberg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/gold"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// rows returns n rows of s whose key column counts from first.
+func rows(s *schema.Schema, first, n int) []silver.Row {
+	out := make([]silver.Row, n)
+	for i := range out {
+		row := make(silver.Row, len(s.Columns))
+		for j, c := range s.Columns {
+			switch c.Type.Kind {
+			case schema.Int:
+				row[j] = int64(first + i)
+			case schema.Decimal:
+				row[j] = schema.Fixed{Unscaled: int64(100 * (first + i)), Scale: c.Type.Scale}
+			case schema.Date, schema.Timestamp:
+				row[j] = time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+			case schema.Boolean:
+				row[j] = true
+			default:
+				row[j] = "1"
+			}
+		}
+		out[i] = row
+	}
+	return out
+}
+
+// putGold writes rows as the gold file key.
+func putGold(t *testing.T, w *Writer, s *schema.Schema, key string, rows []silver.Row) {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, silver.Parquet{}.Write(&buf, s, rows))
+	_, err := w.Objects.Put(context.Background(), bucket, key, &buf, objectstore.PutOptions{})
+	require.NoError(t, err)
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	w, _ := newWriter(t)
+	plan, payment := latest(t, w, "dim_plan"), latest(t, w, "fact_payment")
+
+	putGold(t, w, plan, "gold/dim_plan/part-00000.parquet", rows(plan, 0, 3))
+	putGold(t, w, payment, "gold/fact_payment/"+day1+"/part-00000.parquet", rows(payment, 1, 2))
+	putGold(t, w, payment, "gold/fact_payment/"+day2+"/part-00000.parquet", rows(payment, 3, 1))
+	m := &gold.Manifest{RunID: "gold-20251203T060000Z", Format: "parquet", Tables: []*gold.TableResult{
+		{Table: "dim_plan", Version: 1, Rows: 3},
+		{Table: "fact_payment", Version: 1, Rows: 3},
+	}}
+
+	rep, err := w.Publish(ctx, m)
+	require.NoError(t, err)
+	assert.Equal(t, "gold-20251203T060000Z", rep.GoldRun)
+	require.Len(t, rep.Tables, 2)
+	assert.Equal(t, "dim_plan_iceberg", rep.Tables[0].Table)
+	assert.Equal(t, OpOverwrite, rep.Tables[0].Operation)
+	assert.Equal(t, 1, rep.Tables[0].AddedFiles)
+	assert.Equal(t, int64(3), rep.Tables[0].TotalRecords)
+	assert.Equal(t, 2, rep.Tables[1].AddedFiles)
+	assert.Equal(t, int64(3), rep.Tables[1].TotalRecords)
+
+	files, err := w.Files(ctx, payment)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Regexp(t, `^s3://claim-dev-lake/gold/_iceberg/fact_payment/data/year=2025/month=12/day=01/[0-9a-f-]{36}\.parquet$`, files[0].Path)
+	assert.Equal(t, day1, files[0].Partition)
+	assert.Equal(t, int64(2), files[0].Records)
+
+	// The copy holds the gold rows.
+	key, err := w.key(files[0].Path)
+	require.NoError(t, err)
+	obj, err := w.Objects.Get(ctx, bucket, key, "")
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, files[0].Size, int64(len(data)))
+	got, err := silver.Parquet{}.Read(data, payment)
+	require.NoError(t, err)
+	assert.Equal(t, rows(payment, 1, 2), got)
+
+	meta, err := w.Metadata(ctx, payment)
+	require.NoError(t, err)
+	assert.Equal(t, "gold-20251203T060000Z", meta.CurrentSnapshot().Summary["gold-run"])
+
+	// The next run empties day 1 and writes day 3; day 2 is rewritten.
+	putGold(t, w, payment, "gold/fact_payment/"+day1+"/part-00000.parquet", nil)
+	putGold(t, w, payment, "gold/fact_payment/"+day2+"/part-00000.parquet", rows(payment, 3, 1))
+	putGold(t, w, payment, "gold/fact_payment/"+day3+"/part-00000.parquet", rows(payment, 4, 4))
+	m.RunID = "gold-20251204T060000Z"
+	m.Tables = []*gold.TableResult{{Table: "fact_payment", Version: 1, Rows: 5}}
+	rep, err = w.Publish(ctx, m)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rep.Tables[0].AddedFiles)
+	assert.Equal(t, 2, rep.Tables[0].DeletedFiles)
+	assert.Equal(t, int64(5), rep.Tables[0].TotalRecords)
+
+	files, err = w.Files(ctx, payment)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, []string{day2, day3}, []string{files[0].Partition, files[1].Partition})
+	assert.NotContains(t, []string{files[0].Path, files[1].Path}, rep.Tables[0].MetadataLocation)
+
+	// Files rewritten since the run are not published.
+	putGold(t, w, payment, "gold/fact_payment/"+day3+"/part-00000.parquet", rows(payment, 4, 1))
+	_, err = w.Publish(ctx, m)
+	assert.EqualError(t, err, "iceberg: gold/fact_payment/ holds 2 rows but gold run gold-20251204T060000Z wrote 5; the files changed since the run")
+	files, err = w.Files(ctx, payment)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestPublishRejectsCSV(t *testing.T) {
+	w, _ := newWriter(t)
+	_, err := w.Publish(context.Background(), &gold.Manifest{RunID: "gold-csv", Format: "csv"})
+	assert.EqualError(t, err, "iceberg: gold run gold-csv is csv; Iceberg tables hold parquet")
+}
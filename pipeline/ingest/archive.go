@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+)
+
+// User metadata keys set on archived originals.
+const (
+	MetaFileID        = "file-id"
+	MetaSourceVersion = "source-version-id"
+)
+
+// Archiver copies X12 originals to the Object Lock (WORM) archive bucket
+// before they are parsed. The bucket's default retention locks each copy;
+// the archiver never sets retention itself.
+type Archiver struct {
+	Objects objectstore.Store
+	// Bucket is the archive bucket. Prefix is prepended to the raw key, so
+	// archived originals keep the raw layer's partitioning.
+	Bucket string
+	Prefix string
+}
+
+// Key returns the archive key of the raw object rawKey.
+func (a *Archiver) Key(rawKey string) string {
+	return a.Prefix + rawKey
+}
+
+// Archive copies the object of rec to the archive and sets ArchiveLocation
+// and ArchiveVersionID; the caller stores rec. userMeta, the original's user
+// metadata, is kept on the copy. A record that was already archived is left
+// alone, so retries and replays do not pile up locked versions.
+func (a *Archiver) Archive(ctx context.Context, rec *metadata.FileRecord, userMeta map[string]string) error {
+	if rec.ArchiveVersionID != "" {
+		return nil
+	}
+
+	meta := make(map[string]string, len(userMeta)+2)
+	for k, v := range userMeta {
+		meta[k] = v
+	}
+	meta[MetaFileID] = rec.FileID
+	if rec.VersionID != "" {
+		meta[MetaSourceVersion] = rec.VersionID
+	}
+
+	key := a.Key(rec.Key)
+	version, err := a.Objects.Copy(ctx, rec.Bucket, rec.Key, rec.VersionID, a.Bucket, key, objectstore.PutOptions{
+		ContentType: "application/edi-x12",
+		Metadata:    meta,
+	})
+	if err != nil {
+		return fmt.Errorf("archive s3://%s/%s: %w", rec.Bucket, rec.Key, err)
+	}
+	rec.ArchiveLocation = fmt.Sprintf("s3://%s/%s", a.Bucket, key)
+	rec.ArchiveVersionID = version
+	return nil
+}
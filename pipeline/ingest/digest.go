@@ -0,0 +1,163 @@
+// Package ingest implements the worker that records metadata for every object
+// landing in the raw bucket.
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ErrUnterminatedQuote is returned when a CSV stream ends inside a quoted field.
+var ErrUnterminatedQuote = errors.New("ingest: csv ends inside a quoted field")
+
+// copyBufferSize is the only per-object buffer the digest allocates, so memory
+// use does not grow with object size.
+const copyBufferSize = 64 << 10
+
+type csvState uint8
+
+const (
+	fieldStart csvState = iota
+	unquoted
+	quoted
+	quoteInQuoted
+)
+
+// RowCounter counts CSV records in the bytes written to it. Newlines inside
+// quoted fields do not end a record, doubled quotes are treated as escapes and
+// blank lines are skipped, matching encoding/csv.
+type RowCounter struct {
+	// Delimiter separates fields; zero means ','.
+	Delimiter byte
+
+	state      csvState
+	rowHasData bool
+	records    int64
+}
+
+func (c *RowCounter) Write(p []byte) (int, error) {
+	delim := c.Delimiter
+	if delim == 0 {
+		delim = ','
+	}
+
+	for _, b := range p {
+		if b == '\n' && c.state != quoted {
+			if c.rowHasData {
+				c.records++
+			}
+			c.state = fieldStart
+			c.rowHasData = false
+			continue
+		}
+		if b != '\r' {
+			c.rowHasData = true
+		}
+
+		switch c.state {
+		case fieldStart:
+			switch b {
+			case '"':
+				c.state = quoted
+			case delim, '\r':
+			default:
+				c.state = unquoted
+			}
+		case unquoted:
+			if b == delim {
+				c.state = fieldStart
+			}
+		case quoted:
+			if b == '"' {
+				c.state = quoteInQuoted
+			}
+		case quoteInQuoted:
+			switch b {
+			case '"':
+				c.state = quoted
+			case delim:
+				c.state = fieldStart
+			case '\r':
+			default:
+				c.state = unquoted
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Records returns the number of complete records seen, counting a final record
+// without a trailing newline.
+func (c *RowCounter) Records() (int64, error) {
+	n := c.records
+	if c.rowHasData {
+		n++
+	}
+	if c.state == quoted {
+		return n, ErrUnterminatedQuote
+	}
+	return n, nil
+}
+
+// Digest is the result of a single pass over an object.
+type Digest struct {
+	SHA256 string
+	Bytes  int64
+	// Records is the number of CSV records including the header row, or zero
+	// when rows were not counted.
+	Records int64
+}
+
+// Scan reads r once, hashing every byte and, if countRows is set, counting CSV
+// records in the same pass.
+func Scan(r io.Reader, countRows bool) (Digest, error) {
+	h := sha256.New()
+	var counter *RowCounter
+	var w io.Writer = h
+	if countRows {
+		counter = &RowCounter{}
+		w = io.MultiWriter(h, counter)
+	}
+
+	buf := make([]byte, copyBufferSize)
+	n, err := io.CopyBuffer(onlyWriter{w}, onlyReader{r}, buf)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	d := Digest{SHA256: hexSum(h), Bytes: n}
+	if counter != nil {
+		d.Records, err = counter.Records()
+	}
+	return d, err
+}
+
+// NormalizeChecksum converts a client-declared SHA-256 in hex or base64 to
+// lower-case hex. It returns false when the value is neither.
+func NormalizeChecksum(v string) (string, bool) {
+	v = strings.TrimSpace(v)
+	if len(v) == sha256.Size*2 {
+		if raw, err := hex.DecodeString(v); err == nil {
+			return hex.EncodeToString(raw), true
+		}
+	}
+	if raw, err := base64.StdEncoding.DecodeString(v); err == nil && len(raw) == sha256.Size {
+		return hex.EncodeToString(raw), true
+	}
+	return "", false
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// onlyReader and onlyWriter hide ReaderFrom/WriterTo so io.CopyBuffer always
+// uses the fixed buffer instead of an implementation-chosen one.
+type onlyReader struct{ io.Reader }
+
+type onlyWriter struct{ io.Writer }
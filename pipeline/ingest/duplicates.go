@@ -0,0 +1,99 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"claim-management-system/pipeline/metadata"
+)
+
+// unknownSource groups files whose source_system could not be determined.
+const unknownSource = "unknown"
+
+// DuplicateReport lists files marked DUPLICATE, grouped by the source system
+// that resent them.
+type DuplicateReport struct {
+	Sources []SourceDuplicates `json:"sources"`
+}
+
+// SourceDuplicates is the set of duplicates received from one source system.
+type SourceDuplicates struct {
+	SourceSystem string          `json:"source_system"`
+	Count        int             `json:"count"`
+	Files        []DuplicateFile `json:"files"`
+}
+
+// DuplicateFile is one duplicate and the original it repeats.
+type DuplicateFile struct {
+	FileID               string `json:"file_id"`
+	FileName             string `json:"file_name"`
+	FileType             string `json:"file_type,omitempty"`
+	Key                  string `json:"object_key"`
+	IngestTime           string `json:"ingest_time"`
+	DuplicateOf          string `json:"duplicate_of"`
+	OriginalFileName     string `json:"original_file_name,omitempty"`
+	OriginalSourceSystem string `json:"original_source_system,omitempty"`
+}
+
+// BuildDuplicateReport collects every DUPLICATE record in store.
+func BuildDuplicateReport(ctx context.Context, store metadata.Store) (*DuplicateReport, error) {
+	var dups []*metadata.FileRecord
+	err := metadata.QueryAll(ctx, store, metadata.Query{Status: metadata.StatusDuplicate}, func(r *metadata.FileRecord) error {
+		dups = append(dups, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	originals := make(map[string]*metadata.FileRecord)
+	bySource := make(map[string]*SourceDuplicates)
+	for _, d := range dups {
+		orig, ok := originals[d.DuplicateOf]
+		if !ok {
+			orig, err = store.Get(ctx, d.DuplicateOf)
+			if errors.Is(err, metadata.ErrNotFound) {
+				orig = nil
+			} else if err != nil {
+				return nil, fmt.Errorf("load original of %s: %w", d.FileID, err)
+			}
+			originals[d.DuplicateOf] = orig
+		}
+
+		entry := DuplicateFile{
+			FileID:      d.FileID,
+			FileName:    d.FileName,
+			FileType:    d.FileType,
+			Key:         d.Key,
+			IngestTime:  d.IngestTime,
+			DuplicateOf: d.DuplicateOf,
+		}
+		if orig != nil {
+			entry.OriginalFileName = orig.FileName
+			entry.OriginalSourceSystem = orig.SourceSystem
+		}
+
+		source := d.SourceSystem
+		if source == "" {
+			source = unknownSource
+		}
+		group, ok := bySource[source]
+		if !ok {
+			group = &SourceDuplicates{SourceSystem: source}
+			bySource[source] = group
+		}
+		group.Files = append(group.Files, entry)
+		group.Count++
+	}
+
+	report := &DuplicateReport{Sources: []SourceDuplicates{}}
+	for _, g := range bySource {
+		report.Sources = append(report.Sources, *g)
+	}
+	sort.Slice(report.Sources, func(i, j int) bool {
+		return report.Sources[i].SourceSystem < report.Sources[j].SourceSystem
+	})
+	return report, nil
+}
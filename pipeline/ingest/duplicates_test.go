@@ -0,0 +1,50 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDuplicateReport(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	ctx := context.Background()
+
+	ingest := func(key, body string) {
+		_, err := w.Ingest(ctx, putObject(t, objects, key, body, nil))
+		require.NoError(t, err)
+	}
+	ingest("raw/837/source=payer-portal/claims_0101.csv", "id\nA\n")
+	ingest("raw/837/source=clearinghouse/claims_0101_resend.csv", "id\nA\n")
+	ingest("raw/837/source=clearinghouse/claims_0101_resend2.csv", "id\nA\n")
+	ingest("raw/835/remit.csv", "id\nR\n")
+	ingest("raw/835/remit_copy.csv", "id\nR\n")
+	ingest("raw/837/source=clearinghouse/unique.csv", "id\nU\n")
+
+	report, err := BuildDuplicateReport(ctx, store)
+	require.NoError(t, err)
+	require.Len(t, report.Sources, 2)
+
+	ch := report.Sources[0]
+	assert.Equal(t, "clearinghouse", ch.SourceSystem)
+	assert.Equal(t, 2, ch.Count)
+	for _, f := range ch.Files {
+		assert.Equal(t, "claims_0101.csv", f.OriginalFileName)
+		assert.Equal(t, "payer-portal", f.OriginalSourceSystem)
+		assert.Equal(t, "837", f.FileType)
+	}
+
+	unknown := report.Sources[1]
+	assert.Equal(t, unknownSource, unknown.SourceSystem)
+	assert.Equal(t, 1, unknown.Count)
+	assert.Equal(t, "remit_copy.csv", unknown.Files[0].FileName)
+}
+
+func TestBuildDuplicateReportEmpty(t *testing.T) {
+	_, _, store := newTestWorker(t)
+	report, err := BuildDuplicateReport(context.Background(), store)
+	require.NoError(t, err)
+	assert.Empty(t, report.Sources)
+}
@@ -0,0 +1,330 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/ack"
+	"claim-management-system/pipeline/errreport"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/queue"
+	"claim-management-system/pipeline/s3event"
+	"claim-management-system/pipeline/validation"
+	"claim-management-system/pipeline/x12"
+)
+
+// User metadata keys read from uploaded objects (x-amz-meta-<key>).
+const (
+	MetaChecksum     = "checksum-sha256"
+	MetaSourceSystem = "source-system"
+	MetaUploader     = "uploader"
+)
+
+// Worker handles raw-bucket notifications: it streams each new object once,
+// records checksum and row count, marks content already seen under another
+// file_id as a duplicate, and writes the file-metadata record.
+type Worker struct {
+	Objects  objectstore.Store
+	Metadata metadata.Store
+	// Archive, if set, copies X12 originals to the WORM archive before
+	// anything parses them.
+	Archive *Archiver
+	// Errors, if set, writes the JSON error report of ingested X12 files
+	// with problems; its diagnostics also go into the acknowledgment.
+	Errors *errreport.Reporter
+	// Acks, if set, answers ingested X12 files with a TA1/999.
+	Acks *ack.Acknowledger
+	// Validator, if set, checks CSV files against their registered schema
+	// and marks them VALIDATED or REJECTED instead of INGESTED.
+	Validator *validation.Validator
+	Logger    *log.Logger
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Handle implements queue.Handler.
+func (w *Worker) Handle(ctx context.Context, msg queue.Message) error {
+	records, err := s3event.Parse(msg.Body)
+	if err != nil {
+		// A body that is not an S3 notification will never parse; let the
+		// redrive policy move it to the DLQ.
+		return err
+	}
+	for _, r := range records {
+		if _, err := w.Ingest(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ingest processes a single object event and returns the resulting record.
+// Events for objects that were already ingested are ignored, so redelivery is
+// harmless.
+func (w *Worker) Ingest(ctx context.Context, ev s3event.Record) (*metadata.FileRecord, error) {
+	rec, err := w.claim(ctx, ev)
+	if err != nil || rec == nil {
+		return rec, err
+	}
+
+	obj, err := w.Objects.Get(ctx, ev.Bucket, ev.Key, ev.VersionID)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		// The object version is gone; retrying cannot help.
+		return rec, w.fail(ctx, rec, "object not found")
+	}
+	if err != nil {
+		return rec, w.retry(ctx, rec, err)
+	}
+	defer obj.Body.Close()
+
+	w.applyObjectMetadata(rec, obj)
+
+	if w.Archive != nil && IsX12(ev.Key) {
+		if err := w.Archive.Archive(ctx, rec, obj.Metadata); err != nil {
+			return rec, w.retry(ctx, rec, err)
+		}
+	}
+
+	digest, err := Scan(obj.Body, IsCSV(ev.Key))
+	if errors.Is(err, ErrUnterminatedQuote) {
+		rec.Checksum = digest.SHA256
+		return rec, w.fail(ctx, rec, err.Error())
+	}
+	if err != nil {
+		return rec, w.retry(ctx, rec, fmt.Errorf("read s3://%s/%s: %w", ev.Bucket, ev.Key, err))
+	}
+
+	rec.Checksum = digest.SHA256
+	rec.SizeBytes = digest.Bytes
+	if digest.Records > 0 {
+		// The first CSV record is the header.
+		rec.RecordCount = digest.Records - 1
+	}
+
+	status, reason := metadata.StatusIngested, ""
+	if declared, ok := obj.Metadata[MetaChecksum]; ok {
+		normalized, valid := NormalizeChecksum(declared)
+		rec.ClientChecksum = normalized
+		switch {
+		case !valid:
+			rec.ChecksumMismatch = true
+			status, reason = metadata.StatusChecksumMismatch, fmt.Sprintf("unparseable %s metadata %q", MetaChecksum, declared)
+		case normalized != rec.Checksum:
+			rec.ChecksumMismatch = true
+			status, reason = metadata.StatusChecksumMismatch, "content does not match client checksum"
+		}
+	}
+
+	if status == metadata.StatusIngested {
+		original, err := w.findOriginal(ctx, rec)
+		if err != nil {
+			return rec, w.retry(ctx, rec, err)
+		}
+		if original != nil {
+			rec.DuplicateOf = original.FileID
+			status = metadata.StatusDuplicate
+			reason = fmt.Sprintf("content matches %s (%s, source %q)", original.FileID, original.FileName, original.SourceSystem)
+		}
+	}
+
+	if status == metadata.StatusIngested && w.Validator != nil && IsCSV(ev.Key) {
+		valid, err := w.Validator.Validate(ctx, rec)
+		if err != nil {
+			return rec, w.retry(ctx, rec, err)
+		}
+		status = metadata.StatusValidated
+		if !valid {
+			status, reason = metadata.StatusRejected, rec.Validation.Reason
+		}
+	}
+
+	var diags []*x12.Diagnostic
+	if status == metadata.StatusIngested && w.Errors != nil && IsX12(ev.Key) {
+		rep, err := w.Errors.Report(ctx, rec)
+		if err != nil {
+			return rec, w.retry(ctx, rec, err)
+		}
+		diags = rep.Diagnostics
+		if rec.ErrorCount > 0 {
+			w.logf("file %s (s3://%s/%s) has %d X12 errors, see %s", rec.FileID, rec.Bucket, rec.Key, rec.ErrorCount, rec.ErrorReport)
+		}
+	}
+
+	if status == metadata.StatusIngested && w.Acks != nil && IsX12(ev.Key) {
+		// Acknowledged before the final status is written, so a failed
+		// write leaves the record retryable on redelivery.
+		if _, err := w.Acks.Acknowledge(ctx, rec, diags); err != nil {
+			return rec, w.retry(ctx, rec, err)
+		}
+		if rec.AckStatus != metadata.AckAccepted {
+			w.logf("file %s (s3://%s/%s) acknowledged %s", rec.FileID, rec.Bucket, rec.Key, rec.AckStatus)
+		}
+	}
+
+	rec.Transition(status, reason, w.now())
+	if err := w.Metadata.Put(ctx, rec); err != nil {
+		return rec, err
+	}
+	if status != metadata.StatusIngested && status != metadata.StatusValidated {
+		w.logf("file %s (s3://%s/%s) %s: %s", rec.FileID, rec.Bucket, rec.Key, status, reason)
+	}
+	return rec, nil
+}
+
+// findOriginal returns the earliest ingested file with the same content as
+// rec, or nil if rec is the first copy. Files that are themselves duplicates,
+// that were rejected, or that never finished ingesting, are not candidates;
+// nor is a replayed file until it is reprocessed, as its previous outcome no
+// longer holds. Matches come from the checksum index, which projects
+// duplicate_of and checksum_mismatch for the checks below.
+func (w *Worker) findOriginal(ctx context.Context, rec *metadata.FileRecord) (*metadata.FileRecord, error) {
+	matches, err := w.Metadata.FindByChecksum(ctx, rec.Checksum)
+	if err != nil {
+		return nil, fmt.Errorf("look up duplicates of %s: %w", rec.FileID, err)
+	}
+	for _, m := range matches {
+		if m.FileID == rec.FileID {
+			continue
+		}
+		switch m.Status {
+		case metadata.StatusReceived, metadata.StatusFailed, metadata.StatusChecksumMismatch, metadata.StatusDuplicate, metadata.StatusRejected,
+			metadata.StatusReplayed:
+			continue
+		}
+		if m.DuplicateOf != "" || m.ChecksumMismatch {
+			continue
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// claim returns the record to process for ev, creating it if needed. It
+// returns nil when the object has already been ingested, unless ev is a replay
+// of a record the replay command marked REPLAYED.
+func (w *Worker) claim(ctx context.Context, ev s3event.Record) (*metadata.FileRecord, error) {
+	fileID := metadata.NewFileID(ev.Bucket, ev.Key, ev.VersionID)
+
+	rec, err := w.Metadata.Get(ctx, fileID)
+	if err == nil {
+		switch {
+		case rec.Status == metadata.StatusReceived, rec.Status == metadata.StatusFailed:
+			return rec, nil
+		case rec.Status == metadata.StatusReplayed && ev.Replay != nil:
+			// Outcomes of the previous run are recomputed from scratch.
+			rec.ClientChecksum = ""
+			rec.ChecksumMismatch = false
+			rec.DuplicateOf = ""
+			rec.Validation = nil
+			rec.Silver = nil
+			return rec, nil
+		}
+		return nil, nil
+	}
+	if !errors.Is(err, metadata.ErrNotFound) {
+		return nil, err
+	}
+
+	now := w.now()
+	fileType, source := ParseKey(ev.Key)
+	rec = &metadata.FileRecord{
+		FileID:       fileID,
+		FileName:     path.Base(ev.Key),
+		FileType:     fileType,
+		SourceSystem: source,
+		Bucket:       ev.Bucket,
+		Key:          ev.Key,
+		VersionID:    ev.VersionID,
+		SizeBytes:    ev.Size,
+		IngestTime:   metadata.FormatTime(now),
+	}
+	rec.Transition(metadata.StatusReceived, "", now)
+
+	err = w.Metadata.Create(ctx, rec)
+	if errors.Is(err, metadata.ErrExists) {
+		// Another worker received the same event concurrently.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (w *Worker) applyObjectMetadata(rec *metadata.FileRecord, obj *objectstore.Object) {
+	if v := obj.Metadata[MetaSourceSystem]; v != "" && rec.SourceSystem == "" {
+		rec.SourceSystem = v
+	}
+	if v := obj.Metadata[MetaUploader]; v != "" {
+		rec.Uploader = v
+	}
+	if rec.VersionID == "" {
+		rec.VersionID = obj.VersionID
+	}
+}
+
+// fail marks the record FAILED for a permanent error and acknowledges the event.
+func (w *Worker) fail(ctx context.Context, rec *metadata.FileRecord, reason string) error {
+	rec.Transition(metadata.StatusFailed, reason, w.now())
+	w.logf("file %s (s3://%s/%s) failed: %s", rec.FileID, rec.Bucket, rec.Key, reason)
+	return w.Metadata.Put(ctx, rec)
+}
+
+// retry marks the record FAILED for a transient error and returns cause so the
+// message is redelivered.
+func (w *Worker) retry(ctx context.Context, rec *metadata.FileRecord, cause error) error {
+	rec.Transition(metadata.StatusFailed, cause.Error(), w.now())
+	if err := w.Metadata.Put(ctx, rec); err != nil {
+		return fmt.Errorf("%v (and recording failure: %w)", cause, err)
+	}
+	return cause
+}
+
+func (w *Worker) now() time.Time {
+	if w.Now != nil {
+		return w.Now()
+	}
+	return time.Now()
+}
+
+func (w *Worker) logf(format string, args ...interface{}) {
+	if w.Logger != nil {
+		w.Logger.Printf(format, args...)
+	}
+}
+
+// ParseKey extracts file_type and source_system from a raw-layer key such as
+// raw/837/year=2025/month=11/day=21/source=clearinghouse/file.csv.
+func ParseKey(key string) (fileType, source string) {
+	for _, part := range strings.Split(key, "/") {
+		switch {
+		case part == "834" || part == "835" || part == "837":
+			if fileType == "" {
+				fileType = part
+			}
+		case strings.HasPrefix(part, "source="):
+			source = strings.TrimPrefix(part, "source=")
+		}
+	}
+	return fileType, source
+}
+
+// IsX12 reports whether key names an X12 object, which is acknowledged.
+func IsX12(key string) bool {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".x12", ".edi":
+		return true
+	}
+	return false
+}
+
+// IsCSV reports whether key names a CSV object, whose rows are counted.
+func IsCSV(key string) bool {
+	return strings.EqualFold(path.Ext(key), ".csv")
+}
@@ -0,0 +1,495 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/ack"
+	"claim-management-system/pipeline/errreport"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/s3event"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/validation"
+)
+
+const rawBucket = "claim-dev-raw"
+
+func newTestWorker(t *testing.T) (*Worker, *objectstore.Dir, *metadata.MemoryStore) {
+	t.Helper()
+	objects := objectstore.NewDir(t.TempDir())
+	store := metadata.NewMemoryStore()
+	fixed := time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC)
+	return &Worker{Objects: objects, Metadata: store, Now: func() time.Time { return fixed }}, objects, store
+}
+
+func putObject(t *testing.T, objects objectstore.Store, key, body string, meta map[string]string) s3event.Record {
+	t.Helper()
+	version, err := objects.Put(context.Background(), rawBucket, key, strings.NewReader(body), objectstore.PutOptions{Metadata: meta})
+	require.NoError(t, err)
+	return s3event.Record{Bucket: rawBucket, Key: key, VersionID: version, Size: int64(len(body))}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIngestRecordsChecksumAndRowCount(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	body := "claim_id,amount\nC1,10.00\nC2,\"1,000.00\"\n"
+	key := "raw/837/year=2025/month=11/day=21/source=clearinghouse/file_001.csv"
+	ev := putObject(t, objects, key, body, map[string]string{
+		MetaChecksum: sha256Hex(body),
+		MetaUploader: "sftp-clearinghouse",
+	})
+
+	rec, err := w.Ingest(context.Background(), ev)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+
+	stored, err := store.Get(context.Background(), rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, stored.Status)
+	assert.Equal(t, sha256Hex(body), stored.Checksum)
+	assert.Equal(t, sha256Hex(body), stored.ClientChecksum)
+	assert.False(t, stored.ChecksumMismatch)
+	assert.Equal(t, int64(2), stored.RecordCount, "Header row should not be counted")
+	assert.Equal(t, int64(len(body)), stored.SizeBytes)
+	assert.Equal(t, "837", stored.FileType)
+	assert.Equal(t, "clearinghouse", stored.SourceSystem)
+	assert.Equal(t, "sftp-clearinghouse", stored.Uploader)
+	assert.Equal(t, "file_001.csv", stored.FileName)
+	assert.Equal(t, "2025-11-21T10:00:00.000Z", stored.IngestTime)
+	require.Len(t, stored.Transitions, 2)
+	assert.Equal(t, metadata.StatusReceived, stored.Transitions[0].Status)
+}
+
+func TestIngestFlagsChecksumMismatch(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	ev := putObject(t, objects, "raw/834/source=payer/members.csv", "id\n1\n", map[string]string{
+		MetaChecksum: sha256Hex("something else"),
+	})
+
+	rec, err := w.Ingest(context.Background(), ev)
+	require.NoError(t, err)
+
+	stored, err := store.Get(context.Background(), rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusChecksumMismatch, stored.Status)
+	assert.True(t, stored.ChecksumMismatch)
+	assert.Equal(t, sha256Hex("id\n1\n"), stored.Checksum)
+	assert.Equal(t, int64(1), stored.RecordCount)
+}
+
+func TestIngestFlagsUnparseableClientChecksum(t *testing.T) {
+	w, objects, _ := newTestWorker(t)
+	ev := putObject(t, objects, "raw/834/members.csv", "id\n1\n", map[string]string{MetaChecksum: "abc"})
+
+	rec, err := w.Ingest(context.Background(), ev)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusChecksumMismatch, rec.Status)
+	assert.True(t, rec.ChecksumMismatch)
+}
+
+func TestIngestIsIdempotent(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	ev := putObject(t, objects, "raw/835/remit.csv", "a\n1\n", nil)
+
+	first, err := w.Ingest(context.Background(), ev)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+
+	second, err := w.Ingest(context.Background(), ev)
+	require.NoError(t, err)
+	assert.Nil(t, second, "Redelivered events should be ignored")
+
+	stored, err := store.Get(context.Background(), first.FileID)
+	require.NoError(t, err)
+	assert.Len(t, stored.Transitions, 2)
+}
+
+func TestIngestMissingObjectFails(t *testing.T) {
+	w, _, store := newTestWorker(t)
+	ev := s3event.Record{Bucket: rawBucket, Key: "raw/837/gone.csv", VersionID: "v1"}
+
+	rec, err := w.Ingest(context.Background(), ev)
+	require.NoError(t, err, "A missing object is permanent and should not be retried")
+
+	stored, err := store.Get(context.Background(), rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusFailed, stored.Status)
+}
+
+func TestIngestSkipsRowCountForX12(t *testing.T) {
+	w, objects, _ := newTestWorker(t)
+	ev := putObject(t, objects, "raw/837/batch.x12", "ISA*00*~\nGS*HC~\n", nil)
+
+	rec, err := w.Ingest(context.Background(), ev)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, rec.Status)
+	assert.Equal(t, int64(0), rec.RecordCount)
+	assert.NotEmpty(t, rec.Checksum)
+}
+
+func TestIngestAcknowledgesX12(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	w.Acks = &ack.Acknowledger{Objects: objects, Bucket: "claim-dev-lake", Counter: store, Metadata: store, Now: w.Now}
+	ctx := context.Background()
+
+	data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", "835.x12"))
+	require.NoError(t, err)
+	ev := putObject(t, objects, "raw/835/source=payer/remit.x12", string(data), nil)
+	rec, err := w.Ingest(ctx, ev)
+	require.NoError(t, err)
+
+	stored, err := store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, stored.Status)
+	assert.Equal(t, metadata.AckAccepted, stored.AckStatus)
+	assert.Equal(t, "s3://claim-dev-lake/outbound/ack/"+rec.FileID+".x12", stored.AckLocation)
+	_, err = objects.Head(ctx, "claim-dev-lake", "outbound/ack/"+rec.FileID+".x12", "")
+	assert.NoError(t, err)
+
+	// CSV files are not acknowledged.
+	csv := putObject(t, objects, "raw/835/source=payer/remit.csv", "a\n1\n", nil)
+	rec, err = w.Ingest(ctx, csv)
+	require.NoError(t, err)
+	assert.Empty(t, rec.AckStatus)
+}
+
+func TestIngestRetriesFailedAcknowledgment(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	w.Acks = &ack.Acknowledger{Objects: failingPuts{objects}, Bucket: "claim-dev-lake", Counter: store, Metadata: store}
+	ctx := context.Background()
+
+	data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", "837p.x12"))
+	require.NoError(t, err)
+	ev := putObject(t, objects, "raw/837/claims.x12", string(data), nil)
+	_, err = w.Ingest(ctx, ev)
+	require.Error(t, err, "A failed acknowledgment write is retried")
+
+	stored, err := store.Get(ctx, metadata.NewFileID(ev.Bucket, ev.Key, ev.VersionID))
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusFailed, stored.Status)
+	assert.Equal(t, 1, stored.AckControlNumber, "The drawn number is recorded with the failure")
+
+	w.Acks.Objects = objects
+	rec, err := w.Ingest(ctx, ev)
+	require.NoError(t, err)
+	require.NotNil(t, rec, "FAILED records are picked up again")
+	assert.Equal(t, metadata.StatusIngested, rec.Status)
+	assert.Equal(t, metadata.AckAccepted, rec.AckStatus)
+	assert.Equal(t, 1, rec.AckControlNumber, "The retry reuses the number")
+	last, err := store.Next(ctx, ack.ControlSequence, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), last, "Only one number was drawn")
+}
+
+func TestIngestReportsX12Errors(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	w.Errors = &errreport.Reporter{Objects: objects, Bucket: "claim-dev-lake", Now: w.Now}
+	w.Acks = &ack.Acknowledger{Objects: objects, Bucket: "claim-dev-lake", Counter: store, Metadata: store, Now: w.Now}
+	ctx := context.Background()
+
+	data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", "837p.x12"))
+	require.NoError(t, err)
+	body := strings.Replace(string(data), "CLM*PCN0001*150.00*", "CLM*PCN0001*15O.00*", 1)
+	ev := putObject(t, objects, "raw/837/source=clearinghouse/claims.x12", body, nil)
+	rec, err := w.Ingest(ctx, ev)
+	require.NoError(t, err)
+
+	stored, err := store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, stored.Status, "Element errors are acknowledged, not failed")
+	assert.Equal(t, 1, stored.ErrorCount)
+	assert.Equal(t, "s3://claim-dev-lake/silver/_error/x12/"+rec.FileID+".json", stored.ErrorReport)
+	assert.Equal(t, metadata.AckRejected, stored.AckStatus, "The only transaction set has an error")
+	_, err = objects.Head(ctx, "claim-dev-lake", "silver/_error/x12/"+rec.FileID+".json", "")
+	assert.NoError(t, err)
+
+	ack999, err := objects.Get(ctx, "claim-dev-lake", "outbound/ack/"+rec.FileID+".x12", "")
+	require.NoError(t, err)
+	defer ack999.Body.Close()
+	out, err := io.ReadAll(ack999.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "IK3*CLM*19*2300*8~")
+	assert.Contains(t, string(out), "IK4*2**6*15O.00~")
+
+	// A clean file gets no report.
+	clean := putObject(t, objects, "raw/837/source=clearinghouse/clean.x12", string(data), nil)
+	rec, err = w.Ingest(ctx, clean)
+	require.NoError(t, err)
+	assert.Zero(t, rec.ErrorCount)
+	assert.Empty(t, rec.ErrorReport)
+}
+
+func TestIngestValidatesCSV(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	schemas, err := schema.Builtin()
+	require.NoError(t, err)
+	w.Validator = &validation.Validator{Objects: objects, Checker: validation.Checker{Schemas: schemas}, Now: w.Now}
+	ctx := context.Background()
+
+	header := "trace_number,payment_date,payment_method,payer_id,claim_id,claim_status,billed_amount,paid_amount\n"
+	good := putObject(t, objects, "raw/835/source=payer/remit.csv", header+"TRN1,2025-11-20,ACH,PAYER1,PCN0001,1,150.00,120.00\n", nil)
+	rec, err := w.Ingest(ctx, good)
+	require.NoError(t, err)
+	stored, err := store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusValidated, stored.Status)
+	assert.True(t, stored.ReadyForProcessing())
+	require.NotNil(t, stored.Validation)
+	assert.Equal(t, "tbl_835_raw_csv", stored.Validation.Schema)
+	assert.Equal(t, int64(1), stored.Validation.Rows)
+	assert.Equal(t, "2025-11-21T10:00:00.000Z", stored.Validation.ValidatedAt)
+
+	bad := putObject(t, objects, "raw/835/source=payer/remit2.csv", header+"TRN2,2025-11-20,ACH,PAYER1,PCN0002,1,150.00,12O.00\n", nil)
+	rec, err = w.Ingest(ctx, bad)
+	require.NoError(t, err)
+	stored, err = store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusRejected, stored.Status)
+	assert.False(t, stored.ReadyForProcessing(), "Rejected files are skipped downstream")
+	assert.Equal(t, "1 of 1 rows have errors, over the budget of 0 rows and 0% of rows", stored.Transitions[len(stored.Transitions)-1].Reason)
+	require.Len(t, stored.Validation.Samples, 1)
+	assert.Equal(t, "paid_amount", stored.Validation.Samples[0].Column)
+
+	// X12 files are not validated.
+	data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", "835.x12"))
+	require.NoError(t, err)
+	rec, err = w.Ingest(ctx, putObject(t, objects, "raw/835/source=payer/remit.x12", string(data), nil))
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, rec.Status)
+	assert.Nil(t, rec.Validation)
+}
+
+// failingPuts is a store whose writes fail.
+type failingPuts struct{ objectstore.Store }
+
+func (failingPuts) Put(context.Context, string, string, io.Reader, objectstore.PutOptions) (string, error) {
+	return "", errors.New("put failed")
+}
+
+// failingCopies is a store whose copies fail.
+type failingCopies struct{ objectstore.Store }
+
+func (failingCopies) Copy(context.Context, string, string, string, string, string, objectstore.PutOptions) (string, error) {
+	return "", errors.New("copy failed")
+}
+
+func TestIngestArchivesX12(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	w.Archive = &Archiver{Objects: objects, Bucket: "claim-dev-edi-archive"}
+	ctx := context.Background()
+
+	key := "raw/837/source=clearinghouse/claims.x12"
+	ev := putObject(t, objects, key, "ISA*00*~\nGS*HC~\n", map[string]string{MetaUploader: "sftp"})
+	rec, err := w.Ingest(ctx, ev)
+	require.NoError(t, err)
+
+	stored, err := store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, stored.Status)
+	assert.Equal(t, "s3://claim-dev-edi-archive/"+key, stored.ArchiveLocation)
+	require.NotEmpty(t, stored.ArchiveVersionID)
+
+	archived, err := objects.Get(ctx, "claim-dev-edi-archive", key, stored.ArchiveVersionID)
+	require.NoError(t, err, "The recorded version id names the archived copy")
+	defer archived.Body.Close()
+	body, err := io.ReadAll(archived.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ISA*00*~\nGS*HC~\n", string(body))
+	assert.Equal(t, rec.FileID, archived.Metadata[MetaFileID])
+	assert.Equal(t, ev.VersionID, archived.Metadata[MetaSourceVersion])
+	assert.Equal(t, "sftp", archived.Metadata[MetaUploader], "The original's metadata is kept")
+
+	// CSV files are not archived.
+	csv := putObject(t, objects, "raw/837/claims.csv", "a\n1\n", nil)
+	rec, err = w.Ingest(ctx, csv)
+	require.NoError(t, err)
+	assert.Empty(t, rec.ArchiveLocation)
+	assert.Empty(t, rec.ArchiveVersionID)
+}
+
+func TestIngestRetriesFailedArchive(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	w.Archive = &Archiver{Objects: failingCopies{objects}, Bucket: "claim-dev-edi-archive", Prefix: "edi/"}
+	w.Acks = &ack.Acknowledger{Objects: failingPuts{objects}, Bucket: "claim-dev-lake", Counter: store, Metadata: store}
+	ctx := context.Background()
+
+	data, err := os.ReadFile(filepath.Join("..", "x12", "testdata", "837p.x12"))
+	require.NoError(t, err)
+	ev := putObject(t, objects, "raw/837/claims.x12", string(data), nil)
+	_, err = w.Ingest(ctx, ev)
+	require.Error(t, err, "A failed archive copy is retried")
+
+	fileID := metadata.NewFileID(ev.Bucket, ev.Key, ev.VersionID)
+	stored, err := store.Get(ctx, fileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusFailed, stored.Status)
+	assert.Empty(t, stored.ArchiveVersionID)
+	assert.Empty(t, stored.AckStatus, "Nothing parses a file before it is archived")
+
+	// The archive succeeds but the acknowledgment fails; the archived
+	// version is recorded with the failure.
+	w.Archive.Objects = objects
+	_, err = w.Ingest(ctx, ev)
+	require.Error(t, err)
+	stored, err = store.Get(ctx, fileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusFailed, stored.Status)
+	archived := stored.ArchiveVersionID
+	require.NotEmpty(t, archived)
+
+	// The next attempt does not archive the file again.
+	w.Acks.Objects = objects
+	rec, err := w.Ingest(ctx, ev)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, rec.Status)
+	assert.Equal(t, archived, rec.ArchiveVersionID)
+	assert.Equal(t, "s3://claim-dev-edi-archive/edi/raw/837/claims.x12", rec.ArchiveLocation)
+	head, err := objects.Head(ctx, "claim-dev-edi-archive", "edi/raw/837/claims.x12", "")
+	require.NoError(t, err)
+	assert.Equal(t, archived, head.VersionID, "The archived copy was written once")
+}
+
+func TestParseKey(t *testing.T) {
+	fileType, source := ParseKey("raw/835/year=2025/month=11/day=21/source=clearinghouse/file.csv")
+	assert.Equal(t, "835", fileType)
+	assert.Equal(t, "clearinghouse", source)
+
+	fileType, source = ParseKey("uploads/file.csv")
+	assert.Empty(t, fileType)
+	assert.Empty(t, source)
+}
+
+func TestIngestMarksDuplicateAcrossSources(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	ctx := context.Background()
+	body := "claim_id,amount\nC1,10.00\n"
+
+	first := putObject(t, objects, "raw/837/source=clearinghouse-a/batch_001.csv", body, nil)
+	original, err := w.Ingest(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, original.Status)
+	assert.True(t, original.ReadyForProcessing())
+
+	resent := putObject(t, objects, "raw/837/source=clearinghouse-b/resend_17.csv", body, nil)
+	dup, err := w.Ingest(ctx, resent)
+	require.NoError(t, err)
+
+	stored, err := store.Get(ctx, dup.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusDuplicate, stored.Status)
+	assert.Equal(t, original.FileID, stored.DuplicateOf)
+	assert.False(t, stored.ReadyForProcessing(), "Duplicates should be skipped downstream")
+	assert.Equal(t, int64(1), stored.RecordCount, "Duplicates still record their own metadata")
+
+	// The original is untouched.
+	orig, err := store.Get(ctx, original.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, orig.Status)
+	assert.Empty(t, orig.DuplicateOf)
+}
+
+func TestIngestDuplicateIgnoresUnfinishedAndMismatchedFiles(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	ctx := context.Background()
+	body := "a\n1\n"
+
+	// A stuck RECEIVED record and a checksum mismatch with the same content
+	// are not valid originals.
+	require.NoError(t, store.Put(ctx, &metadata.FileRecord{FileID: "stuck", Checksum: sha256Hex(body), Status: metadata.StatusReceived}))
+	bad := putObject(t, objects, "raw/834/bad.csv", body, map[string]string{MetaChecksum: sha256Hex("other")})
+	mismatched, err := w.Ingest(ctx, bad)
+	require.NoError(t, err)
+	require.Equal(t, metadata.StatusChecksumMismatch, mismatched.Status)
+
+	good := putObject(t, objects, "raw/834/good.csv", body, nil)
+	rec, err := w.Ingest(ctx, good)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, rec.Status)
+	assert.Empty(t, rec.DuplicateOf)
+}
+
+func TestIngestReprocessesReplayedFiles(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	ctx := context.Background()
+	body := "a\n1\n"
+
+	first := putObject(t, objects, "raw/834/source=x/first.csv", body, nil)
+	original, err := w.Ingest(ctx, first)
+	require.NoError(t, err)
+	second := putObject(t, objects, "raw/834/source=y/second.csv", body, nil)
+	dup, err := w.Ingest(ctx, second)
+	require.NoError(t, err)
+	require.Equal(t, metadata.StatusDuplicate, dup.Status)
+
+	// Without a replay marker a redelivered event is still ignored.
+	rec, err := w.Ingest(ctx, first)
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+
+	for _, id := range []string{original.FileID, dup.FileID} {
+		stored, err := store.Get(ctx, id)
+		require.NoError(t, err)
+		stored.Transition(metadata.StatusReplayed, "replay r1", w.now())
+		require.NoError(t, store.Put(ctx, stored))
+	}
+
+	// Replayed in ingest order, the original is reprocessed first and the
+	// duplicate finds it again.
+	first.Replay = &s3event.Replay{ID: "r1"}
+	rec, err = w.Ingest(ctx, first)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	assert.Equal(t, metadata.StatusIngested, rec.Status)
+	statuses := []metadata.Status{}
+	for _, tr := range rec.Transitions {
+		statuses = append(statuses, tr.Status)
+	}
+	assert.Equal(t, []metadata.Status{metadata.StatusReceived, metadata.StatusIngested, metadata.StatusReplayed, metadata.StatusIngested}, statuses)
+
+	second.Replay = &s3event.Replay{ID: "r1"}
+	rec, err = w.Ingest(ctx, second)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	assert.Equal(t, metadata.StatusDuplicate, rec.Status)
+	assert.Equal(t, original.FileID, rec.DuplicateOf)
+}
+
+func TestIngestDuplicateIgnoresReplayedFiles(t *testing.T) {
+	w, objects, store := newTestWorker(t)
+	ctx := context.Background()
+	body := "a\n1\n"
+
+	// A former checksum mismatch pending replay is not a valid original,
+	// whatever its flags say until it is reprocessed.
+	bad := putObject(t, objects, "raw/834/bad.csv", body, map[string]string{MetaChecksum: sha256Hex("other")})
+	mismatched, err := w.Ingest(ctx, bad)
+	require.NoError(t, err)
+	require.Equal(t, metadata.StatusChecksumMismatch, mismatched.Status)
+	stored, err := store.Get(ctx, mismatched.FileID)
+	require.NoError(t, err)
+	stored.ChecksumMismatch = false
+	stored.Transition(metadata.StatusReplayed, "replay r1", w.now())
+	require.NoError(t, store.Put(ctx, stored))
+
+	good := putObject(t, objects, "raw/834/good.csv", body, nil)
+	rec, err := w.Ingest(ctx, good)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, rec.Status)
+	assert.Empty(t, rec.DuplicateOf)
+}
@@ -0,0 +1,563 @@
+// Package integrity checks the references between silver tables after a
+// silver load: claim lines need their claim header, claims a member enrolled
+// on their service date, and payments a known claim. Each row that fails a
+// check is flagged or quarantined, as the Policy says, and recorded in
+// tbl_integrity_issue. A report counts the issues per file and per source.
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/eligibility"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// Rule names one referential check.
+type Rule string
+
+const (
+	// ClaimMemberUnknown is a claim header whose member_id is in neither
+	// tbl_member nor tbl_coverage.
+	ClaimMemberUnknown Rule = "claim_member_unknown"
+	// ClaimCoverageGap is a claim header of a known member who has no
+	// coverage on the claim's service_from.
+	ClaimCoverageGap Rule = "claim_coverage_gap"
+	// LineWithoutHeader is a claim line with no claim header of the same
+	// claim_id and claim_frequency.
+	LineWithoutHeader Rule = "line_without_header"
+	// PaymentWithoutClaim is a payment whose claim_id no claim header has.
+	PaymentWithoutClaim Rule = "payment_without_claim"
+)
+
+// Rules lists every rule in the order the checks run. Headers are checked
+// first, so the lines of a quarantined header are orphans too.
+var Rules = []Rule{ClaimMemberUnknown, ClaimCoverageGap, LineWithoutHeader, PaymentWithoutClaim}
+
+// orphan reports whether r is about a missing row rather than a coverage
+// gap.
+func (r Rule) orphan() bool {
+	return r != ClaimCoverageGap
+}
+
+// Action is what is done with a row that fails a rule.
+type Action string
+
+const (
+	// Flag records the issue and leaves the row in its table.
+	Flag Action = "flag"
+	// Quarantine records the issue and moves the row out of its table, to
+	// the same partition path under QuarantinePrefix.
+	Quarantine Action = "quarantine"
+)
+
+// Policy decides the action for each rule; rules it omits are flagged.
+type Policy map[Rule]Action
+
+// DefaultPolicy quarantines claim lines without a header, which no claim
+// query can use, and flags the rest: enrollment and claims often arrive
+// after the claims and payments that reference them.
+var DefaultPolicy = Policy{LineWithoutHeader: Quarantine}
+
+// Action returns the action for r.
+func (p Policy) Action(r Rule) Action {
+	if a, ok := p[r]; ok {
+		return a
+	}
+	return Flag
+}
+
+// ParsePolicy reads a policy written as rule=action pairs separated by
+// commas, e.g. "line_without_header=quarantine,claim_coverage_gap=flag".
+func ParsePolicy(text string) (Policy, error) {
+	p := Policy{}
+	for _, pair := range strings.Split(text, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, action, ok := strings.Cut(pair, "=")
+		r := Rule(strings.TrimSpace(name))
+		a := Action(strings.TrimSpace(action))
+		if !ok || !knownRule(r) {
+			return nil, fmt.Errorf("integrity: policy %q: unknown rule %q", pair, r)
+		}
+		if a != Flag && a != Quarantine {
+			return nil, fmt.Errorf("integrity: policy %q: action must be %s or %s", pair, Flag, Quarantine)
+		}
+		p[r] = a
+	}
+	return p, nil
+}
+
+func knownRule(r Rule) bool {
+	for _, known := range Rules {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IssuesTable is the silver table every issue is recorded in, partitioned by
+// the ingest date of the row's file.
+const IssuesTable = "tbl_integrity_issue"
+
+// QuarantinePrefix is where quarantined rows are kept in the lake bucket, as
+// <prefix><table>/<partition path>/part-00000.<ext>, outside every table
+// location so no query reads them.
+const QuarantinePrefix = "silver/_quarantine/"
+
+// ReportPrefix is where run reports are written in the lake bucket.
+const ReportPrefix = "silver/_integrity/"
+
+// Tables the checks read.
+const (
+	claimHeader = "tbl_claim_header"
+	claimLine   = "tbl_claim_line"
+	payment     = "tbl_payment"
+	member      = "tbl_member"
+	coverage    = "tbl_coverage"
+)
+
+// Checker runs the checks over the silver tables of a lake bucket. Runs must
+// not overlap silver runs: quarantining rewrites the partitions it takes
+// rows from.
+type Checker struct {
+	Objects objectstore.Store
+	Schemas *schema.Registry
+	// Bucket is the lake bucket holding the silver tables.
+	Bucket string
+	// Format is the format of the silver partition files; nil means Parquet.
+	Format silver.Format
+	// Policy decides what is done with failing rows; nil means
+	// DefaultPolicy.
+	Policy Policy
+	// PHIKey keys the HMAC that hashes PHI key values in issue rows, as
+	// silver.HashValue does.
+	PHIKey []byte
+	// ID names the run in its report and issue rows; Run derives one from
+	// the clock when empty.
+	ID string
+
+	Logger *log.Logger
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Run checks the rows the files of m loaded, against every row of the
+// tables they reference, and returns the report it wrote. With a nil m,
+// every row is checked. Files m failed to load are skipped.
+func (c *Checker) Run(ctx context.Context, m *silver.Manifest) (*Report, error) {
+	start := c.now()
+	if c.ID == "" {
+		c.ID = "integrity-" + start.UTC().Format("20060102T150405Z")
+	}
+	policy := c.Policy
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+	rep := &Report{RunID: c.ID, StartedAt: metadata.FormatTime(start), Policy: policy}
+	r := &run{Checker: c, policy: policy, report: newTally(rep)}
+	if m != nil {
+		rep.SilverRun = m.RunID
+		r.files = map[string]bool{}
+		for _, f := range m.Files {
+			if f.Error == "" {
+				r.files[f.FileID] = true
+			}
+		}
+	}
+
+	tables := map[string]*table{}
+	for _, name := range []string{claimHeader, claimLine, payment, member, coverage} {
+		t, err := r.load(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = t
+	}
+	r.checkHeaders(tables[claimHeader], tables[member], tables[coverage])
+	r.checkLines(tables[claimLine], tables[claimHeader])
+	r.checkPayments(tables[payment], tables[claimHeader])
+
+	for _, name := range []string{claimHeader, claimLine, payment} {
+		if err := r.quarantine(ctx, tables[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.writeIssues(ctx); err != nil {
+		return nil, err
+	}
+
+	r.report.finish()
+	key := ReportPrefix + c.ID + ".json"
+	rep.FinishedAt = metadata.FormatTime(c.now())
+	body, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Objects.Put(ctx, c.Bucket, key, bytes.NewReader(body), objectstore.PutOptions{ContentType: "application/json"}); err != nil {
+		return nil, fmt.Errorf("write report s3://%s/%s: %w", c.Bucket, key, err)
+	}
+	c.logf("%s: %d rows checked, %d flagged, %d quarantined", c.ID, rep.Rows, rep.Flagged, rep.Quarantined)
+	return rep, nil
+}
+
+// part is one partition file of a table.
+type part struct {
+	key  string
+	rows []silver.Row
+	// quarantined holds the indexes of rows to move out of the file.
+	quarantined map[int]bool
+}
+
+// table is every partition file of a silver table.
+type table struct {
+	schema *schema.Schema
+	parts  []*part
+}
+
+// col returns the index of column name, which the table's schema must have.
+func (t *table) col(name string) int {
+	i := t.schema.Index(name)
+	if i < 0 {
+		panic(fmt.Sprintf("integrity: %s has no column %s", t.schema, name))
+	}
+	return i
+}
+
+// each calls f with every row not yet quarantined.
+func (t *table) each(f func(p *part, i int, row silver.Row)) {
+	for _, p := range t.parts {
+		for i, row := range p.rows {
+			if !p.quarantined[i] {
+				f(p, i, row)
+			}
+		}
+	}
+}
+
+type run struct {
+	*Checker
+	policy Policy
+	// files holds the file_ids to check; nil means every file.
+	files  map[string]bool
+	report *tally
+	issues []*issue
+}
+
+// issue is one failed check of a row.
+type issue struct {
+	table  *table
+	part   *part
+	row    silver.Row
+	rule   Rule
+	action Action
+	msg    string
+	// date is the date the reference was checked as of, if any.
+	date time.Time
+}
+
+// load reads every partition file of the latest version of table name.
+func (r *run) load(ctx context.Context, name string) (*table, error) {
+	s, err := r.Schemas.Latest(name)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := r.Objects.List(ctx, r.Bucket, s.Location)
+	if err != nil {
+		return nil, err
+	}
+	t := &table{schema: s}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, r.format().Ext()) {
+			continue
+		}
+		rows, err := r.read(ctx, key, s)
+		if err != nil {
+			return nil, err
+		}
+		t.parts = append(t.parts, &part{key: key, rows: rows, quarantined: map[int]bool{}})
+	}
+	return t, nil
+}
+
+// inScope reports whether row, of table t, is to be checked, and counts it.
+func (r *run) inScope(t *table, row silver.Row) bool {
+	fileID := row[t.col(silver.ColFileID)].(string)
+	if r.files != nil && !r.files[fileID] {
+		return false
+	}
+	r.report.row(fileID, sourceOf(t, row))
+	return true
+}
+
+// fail records that row of p failed rule and marks it for quarantine if the
+// policy says so.
+func (r *run) fail(t *table, p *part, i int, rule Rule, msg string, date time.Time) {
+	is := &issue{table: t, part: p, row: p.rows[i], rule: rule, action: r.policy.Action(rule), msg: msg, date: date}
+	if is.action == Quarantine {
+		p.quarantined[i] = true
+	}
+	r.issues = append(r.issues, is)
+	r.report.issue(is.row[t.col(silver.ColFileID)].(string), sourceOf(t, is.row), rule, is.action)
+}
+
+func (r *run) checkHeaders(headers, members, coverages *table) {
+	known := map[string]bool{}
+	members.each(func(_ *part, _ int, row silver.Row) {
+		known[row[members.col("member_id")].(string)] = true
+	})
+	coverages.each(func(_ *part, _ int, row silver.Row) {
+		known[row[coverages.col("member_id")].(string)] = true
+	})
+	var rows []silver.Row
+	coverages.each(func(_ *part, _ int, row silver.Row) { rows = append(rows, row) })
+	enrolled := eligibility.Fold(eligibility.Events(coverages.schema, rows))
+
+	memberID, serviceFrom := headers.col("member_id"), headers.col("service_from")
+	headers.each(func(p *part, i int, row silver.Row) {
+		if !r.inScope(headers, row) {
+			return
+		}
+		id, from := row[memberID].(string), row[serviceFrom].(time.Time)
+		switch {
+		case !known[id]:
+			r.fail(headers, p, i, ClaimMemberUnknown, fmt.Sprintf("member is in neither %s nor %s", member, coverage), from)
+		case !enrolled.CoveredAny(id, from):
+			r.fail(headers, p, i, ClaimCoverageGap, "member has no coverage on "+from.Format(schema.DateLayout), from)
+		}
+	})
+}
+
+func (r *run) checkLines(lines, headers *table) {
+	claims := map[string]bool{}
+	headers.each(func(_ *part, _ int, row silver.Row) {
+		claims[row[headers.col("claim_id")].(string)+"\x1f"+row[headers.col("claim_frequency")].(string)] = true
+	})
+	claimID, frequency := lines.col("claim_id"), lines.col("claim_frequency")
+	lines.each(func(p *part, i int, row silver.Row) {
+		if !r.inScope(lines, row) {
+			return
+		}
+		id, freq := row[claimID].(string), row[frequency].(string)
+		if !claims[id+"\x1f"+freq] {
+			r.fail(lines, p, i, LineWithoutHeader, fmt.Sprintf("no %s row for claim_id %s, claim_frequency %s", claimHeader, id, freq), time.Time{})
+		}
+	})
+}
+
+func (r *run) checkPayments(payments, headers *table) {
+	claims := map[string]bool{}
+	headers.each(func(_ *part, _ int, row silver.Row) {
+		claims[row[headers.col("claim_id")].(string)] = true
+	})
+	claimID := payments.col("claim_id")
+	payments.each(func(p *part, i int, row silver.Row) {
+		if !r.inScope(payments, row) {
+			return
+		}
+		if id := row[claimID].(string); !claims[id] {
+			r.fail(payments, p, i, PaymentWithoutClaim, fmt.Sprintf("no %s row for claim_id %s", claimHeader, id), time.Time{})
+		}
+	})
+}
+
+// quarantine moves the quarantined rows of t to their quarantine partition
+// files, then rewrites the partitions they came from without them. A failure
+// in between leaves a row in both places, never in neither.
+func (r *run) quarantine(ctx context.Context, t *table) error {
+	for _, p := range t.parts {
+		if len(p.quarantined) == 0 {
+			continue
+		}
+		var kept, moved []silver.Row
+		for i, row := range p.rows {
+			if p.quarantined[i] {
+				moved = append(moved, row)
+			} else {
+				kept = append(kept, row)
+			}
+		}
+		if err := r.merge(ctx, quarantineKey(t.schema, p.key), t.schema, moved); err != nil {
+			return err
+		}
+		if err := r.write(ctx, p.key, t.schema, kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quarantineKey returns where rows of the partition file key are
+// quarantined.
+func quarantineKey(s *schema.Schema, key string) string {
+	return QuarantinePrefix + s.Name + "/" + strings.TrimPrefix(key, s.Location)
+}
+
+// writeIssues adds the run's issues to IssuesTable.
+func (r *run) writeIssues(ctx context.Context) error {
+	if len(r.issues) == 0 {
+		return nil
+	}
+	s, err := r.Schemas.Latest(IssuesTable)
+	if err != nil {
+		return err
+	}
+	parts := map[string][]silver.Row{}
+	checked := metadata.FormatTime(r.now())
+	for _, is := range r.issues {
+		t, row := is.table, is.row
+		ingested := row[t.col(silver.ColIngestedAt)].(time.Time).UTC()
+		object := is.part.key
+		if is.action == Quarantine {
+			object = quarantineKey(t.schema, object)
+		}
+		values := map[string]string{
+			silver.ColFileID:       row[t.col(silver.ColFileID)].(string),
+			silver.ColSourceSystem: sourceOf(t, row),
+			"source_table":         t.schema.Name,
+			silver.ColSourceRow:    strconv.FormatInt(row[t.col(silver.ColSourceRow)].(int64), 10),
+			"rule":                 string(is.rule),
+			"action":               string(is.action),
+			"row_key":              r.rowKey(t.schema, row),
+			"message":              is.msg,
+			"object":               fmt.Sprintf("s3://%s/%s", r.Bucket, object),
+			"run_id":               r.ID,
+			"checked_at":           checked,
+			silver.ColIngestedAt:   metadata.FormatTime(ingested),
+			"ingest_date":          ingested.Format(schema.DateLayout),
+		}
+		if !is.date.IsZero() {
+			values["reference_date"] = is.date.Format(schema.DateLayout)
+		}
+		out := make(silver.Row, len(s.Columns))
+		for i, c := range s.Columns {
+			if out[i], err = c.Parse(values[c.Name]); err != nil {
+				return fmt.Errorf("integrity: %s: %s: %w", IssuesTable, c.Name, err)
+			}
+		}
+		key := s.Location + s.PartitionPath(schema.DatePartition(ingested)) + "/" + silver.PartFile + r.format().Ext()
+		parts[key] = append(parts[key], out)
+	}
+
+	keys := make([]string, 0, len(parts))
+	for key := range parts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := r.merge(ctx, key, s, parts[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowKey returns the JSON object of the key columns of row, PHI hashed.
+func (r *run) rowKey(s *schema.Schema, row silver.Row) string {
+	values := make(map[string]string, len(s.Key))
+	for _, name := range s.Key {
+		c := s.Column(name)
+		v := c.Type.Format(row[s.Index(name)])
+		if c.PHI {
+			v = silver.HashValue(r.PHIKey, v)
+		}
+		values[name] = v
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// merge adds rows to the partition file key of s, replacing rows with the
+// same key, and rewrites it sorted by key.
+func (r *run) merge(ctx context.Context, key string, s *schema.Schema, rows []silver.Row) error {
+	existing, err := r.read(ctx, key, s)
+	if err != nil {
+		return err
+	}
+	byKey := map[string]silver.Row{}
+	for _, row := range append(existing, rows...) {
+		byKey[silver.KeyOf(s, row)] = row
+	}
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	merged := make([]silver.Row, len(keys))
+	for i, k := range keys {
+		merged[i] = byKey[k]
+	}
+	return r.write(ctx, key, s, merged)
+}
+
+// read decodes the partition file key of s; a missing file has no rows.
+func (r *run) read(ctx context.Context, key string, s *schema.Schema) ([]silver.Row, error) {
+	obj, err := r.Objects.Get(ctx, r.Bucket, key, "")
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	data, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	rows, err := r.format().Read(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	return rows, nil
+}
+
+func (r *run) write(ctx context.Context, key string, s *schema.Schema, rows []silver.Row) error {
+	var buf bytes.Buffer
+	if err := r.format().Write(&buf, s, rows); err != nil {
+		return fmt.Errorf("encode s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	if _, err := r.Objects.Put(ctx, r.Bucket, key, &buf, objectstore.PutOptions{ContentType: r.format().ContentType()}); err != nil {
+		return fmt.Errorf("write s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	return nil
+}
+
+// sourceOf returns the source_system of row, or "" if it has none.
+func sourceOf(t *table, row silver.Row) string {
+	v, _ := row[t.col(silver.ColSourceSystem)].(string)
+	return v
+}
+
+func (c *Checker) format() silver.Format {
+	if c.Format == nil {
+		return silver.Parquet{}
+	}
+	return c.Format
+}
+
+func (c *Checker) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *Checker) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
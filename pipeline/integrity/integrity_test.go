@@ -0,0 +1,193 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/silver"
+	"claim-management-system/pipeline/silver/silvertest"
+)
+
+const lakeBucket = "claim-dev-lake"
+
+type fixture struct {
+	*silvertest.Lake
+}
+
+// newFixture writes a small lake: members M1, M2 and M3, of whom M1 is
+// enrolled and M2 was terminated in June; claims from files 837-a
+// (clearinghouse) and 837-b (portal); payments from 835-a (payer).
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{silvertest.New(t, lakeBucket)}
+
+	f.Put(t, "tbl_member", "year=2025/month=01/day=01",
+		"M1,S1,18,021,,2025-01-01,DOE,JANE,,1980-02-03,F,,,,,,G1,834-a,enrollment,1,2025-11-01T08:00:00Z\n"+
+			"M2,S2,18,021,,2025-01-01,ROE,RICH,,1975-04-05,M,,,,,,G1,834-a,enrollment,2,2025-11-01T08:00:00Z\n"+
+			"M3,S3,18,021,,2025-01-01,POE,ANN,,1990-06-07,F,,,,,,G1,834-a,enrollment,3,2025-11-01T08:00:00Z\n")
+	f.Put(t, "tbl_coverage", "year=2025/month=01/day=01",
+		"M1,S1,021,2025-01-01,G1,HMO,HLT,IND,2025-01-01,,834-a,enrollment,1,2025-11-01T08:00:00Z\n"+
+			"M2,S2,021,2025-01-01,G1,HMO,HLT,IND,2025-01-01,,834-a,enrollment,2,2025-11-01T08:00:00Z\n")
+	f.Put(t, "tbl_coverage", "year=2025/month=06/day=30",
+		"M2,S2,024,2025-06-30,G1,HMO,HLT,IND,2025-01-01,2025-06-30,834-b,enrollment,1,2025-11-02T08:00:00Z\n")
+
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=20",
+		"PCN1,1,P,M1,1234567893,,PAYER1,11,150.00,2025-11-20,,E119,,1,837-a,clearinghouse,1,2025-11-21T10:00:00Z\n"+
+			"PCN2,1,P,M2,1234567893,,PAYER1,11,80.00,2025-11-20,,J449,,1,837-a,clearinghouse,3,2025-11-21T10:00:00Z\n")
+	f.Put(t, "tbl_claim_header", "year=2025/month=11/day=21",
+		"PCN3,1,P,MX,1234567893,,PAYER1,11,60.00,2025-11-21,,I10,,1,837-a,clearinghouse,4,2025-11-21T10:00:00Z\n"+
+			"PCN5,1,P,MX,1234567893,,PAYER1,11,40.00,2025-11-21,,I10,,1,837-b,portal,1,2025-11-21T11:00:00Z\n")
+	f.Put(t, "tbl_claim_line", "year=2025/month=11/day=20",
+		"PCN1,1,1,99213,,,150.00,1.000,2025-11-20,837-a,clearinghouse,1,2025-11-21T10:00:00Z\n"+
+			"PCN1,7,1,99213,,,150.00,1.000,2025-11-20,837-a,clearinghouse,2,2025-11-21T10:00:00Z\n"+
+			"PCN2,1,1,99212,,,80.00,1.000,2025-11-20,837-a,clearinghouse,3,2025-11-21T10:00:00Z\n")
+	f.Put(t, "tbl_claim_line", "year=2025/month=11/day=21",
+		"PCN3,1,1,99211,,,60.00,1.000,2025-11-21,837-a,clearinghouse,4,2025-11-21T10:00:00Z\n"+
+			"PCN4,1,1,99211,,,30.00,1.000,2025-11-21,837-b,portal,2,2025-11-21T11:00:00Z\n")
+	f.Put(t, "tbl_payment", "year=2025/month=11/day=25",
+		"TRC1,2025-11-25,ACH,PAYER1,,PCN1,,1,,150.00,120.00,30.00,835-a,payer,1,2025-11-26T09:00:00Z\n"+
+			"TRC1,2025-11-25,ACH,PAYER1,,PCN9,,1,,50.00,50.00,,835-a,payer,2,2025-11-26T09:00:00Z\n")
+	return f
+}
+
+func (f *fixture) checker(id string, policy Policy) *Checker {
+	return &Checker{
+		Objects: f.Objects,
+		Schemas: f.Schemas,
+		Bucket:  lakeBucket,
+		Format:  silver.CSV{},
+		Policy:  policy,
+		ID:      id,
+		Now:     func() time.Time { return time.Date(2025, 11, 27, 6, 0, 0, 0, time.UTC) },
+	}
+}
+
+// column returns the values of column name in a partition file of table.
+func (f *fixture) column(t *testing.T, table, key, name string) []string {
+	t.Helper()
+	s, err := f.Schemas.Latest(table)
+	require.NoError(t, err)
+	rows, err := silver.CSV{}.Read(f.Read(t, key), s)
+	require.NoError(t, err)
+	var out []string
+	for _, row := range rows {
+		out = append(out, s.Column(name).Type.Format(row[s.Index(name)]))
+	}
+	return out
+}
+
+func TestRunChecksEveryRow(t *testing.T) {
+	f := newFixture(t)
+	rep, err := f.checker("integrity-test", nil).Run(context.Background(), nil)
+	require.NoError(t, err)
+
+	assert.Empty(t, rep.SilverRun)
+	assert.Equal(t, DefaultPolicy, rep.Policy)
+	assert.Equal(t, Counts{
+		Rows: 11, Orphans: 5, CoverageGaps: 1,
+		ByRule:  map[Rule]int64{ClaimMemberUnknown: 2, ClaimCoverageGap: 1, LineWithoutHeader: 2, PaymentWithoutClaim: 1},
+		Flagged: 4, Quarantined: 2,
+	}, rep.Counts)
+	assert.Equal(t, []*FileReport{
+		{FileID: "835-a", SourceSystem: "payer", Counts: Counts{
+			Rows: 2, Orphans: 1, ByRule: map[Rule]int64{PaymentWithoutClaim: 1}, Flagged: 1,
+		}},
+		{FileID: "837-a", SourceSystem: "clearinghouse", Counts: Counts{
+			Rows: 7, Orphans: 2, CoverageGaps: 1,
+			ByRule:  map[Rule]int64{ClaimMemberUnknown: 1, ClaimCoverageGap: 1, LineWithoutHeader: 1},
+			Flagged: 2, Quarantined: 1,
+		}},
+		{FileID: "837-b", SourceSystem: "portal", Counts: Counts{
+			Rows: 2, Orphans: 2, ByRule: map[Rule]int64{ClaimMemberUnknown: 1, LineWithoutHeader: 1}, Flagged: 1, Quarantined: 1,
+		}},
+	}, rep.Files)
+	require.Len(t, rep.Sources, 3)
+	assert.Equal(t, &SourceReport{SourceSystem: "clearinghouse", Files: 1, Counts: rep.Files[1].Counts}, rep.Sources[0])
+
+	lines := "silver/837_claim_line/year=2025/month=11/day=20/part-00000.csv"
+	assert.Equal(t, []string{"PCN1", "PCN2"}, f.column(t, "tbl_claim_line", lines, "claim_id"),
+		"The line of a claim version without a header is quarantined")
+	assert.Equal(t, []string{"7"}, f.column(t, "tbl_claim_line", "silver/_quarantine/tbl_claim_line/year=2025/month=11/day=20/part-00000.csv", "claim_frequency"))
+	assert.Equal(t, []string{"PCN3"}, f.column(t, "tbl_claim_line", "silver/837_claim_line/year=2025/month=11/day=21/part-00000.csv", "claim_id"))
+	assert.Equal(t, []string{"PCN1", "PCN2"}, f.column(t, "tbl_claim_header", "silver/837_claim_header/year=2025/month=11/day=20/part-00000.csv", "claim_id"),
+		"Flagged rows stay")
+
+	issues := "silver/_error/integrity/year=2025/month=11/day=21/part-00000.csv"
+	assert.Equal(t, []string{"claim_coverage_gap", "claim_member_unknown", "line_without_header", "claim_member_unknown", "line_without_header"},
+		f.column(t, "tbl_integrity_issue", issues, "rule"), "Sorted by file, table and row")
+	assert.Contains(t, string(f.Read(t, issues)),
+		`837-a,clearinghouse,tbl_claim_header,3,claim_coverage_gap,flag,"{""claim_frequency"":""1"",""claim_id"":""PCN2""}",`+
+			"member has no coverage on 2025-11-20,2025-11-20,"+
+			"s3://claim-dev-lake/silver/837_claim_header/year=2025/month=11/day=20/part-00000.csv,"+
+			"integrity-test,2025-11-27T06:00:00Z,2025-11-21T10:00:00Z,2025-11-21\n")
+	assert.Contains(t, string(f.Read(t, issues)),
+		"837-a,clearinghouse,tbl_claim_line,2,line_without_header,quarantine,"+
+			`"{""claim_frequency"":""7"",""claim_id"":""PCN1"",""line_number"":""1""}",`+
+			`"no tbl_claim_header row for claim_id PCN1, claim_frequency 7",,`+
+			"s3://claim-dev-lake/silver/_quarantine/tbl_claim_line/year=2025/month=11/day=20/part-00000.csv,")
+	assert.Equal(t, []string{"payment_without_claim"},
+		f.column(t, "tbl_integrity_issue", "silver/_error/integrity/year=2025/month=11/day=26/part-00000.csv", "rule"))
+
+	var stored Report
+	require.NoError(t, json.Unmarshal(f.Read(t, "silver/_integrity/integrity-test.json"), &stored))
+	assert.Equal(t, rep, &stored)
+
+	again, err := f.checker("integrity-again", nil).Run(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), again.Rows, "Quarantined rows are no longer checked")
+	assert.Equal(t, int64(4), again.Flagged)
+	assert.Zero(t, again.Quarantined)
+	assert.Equal(t, []string{"integrity-again", "integrity-again", "integrity-test", "integrity-again", "integrity-test"},
+		f.column(t, "tbl_integrity_issue", issues, "run_id"), "Issues are keyed by row and rule; a flagged row's is replaced")
+}
+
+func TestRunChecksManifestFiles(t *testing.T) {
+	f := newFixture(t)
+	m := &silver.Manifest{RunID: "silver-test", Files: []*silver.FileResult{
+		{FileID: "837-b", FileType: "837", SourceSystem: "portal"},
+		{FileID: "835-a", FileType: "835", Error: "object not found"},
+	}}
+	rep, err := f.checker("integrity-test", nil).Run(context.Background(), m)
+	require.NoError(t, err)
+	assert.Equal(t, "silver-test", rep.SilverRun)
+	assert.Equal(t, int64(2), rep.Rows, "Only rows of the run's loaded files are checked")
+	require.Len(t, rep.Files, 1)
+	assert.Equal(t, "837-b", rep.Files[0].FileID)
+	assert.Equal(t, []*SourceReport{{SourceSystem: "portal", Files: 1, Counts: rep.Files[0].Counts}}, rep.Sources)
+	assert.Equal(t, []string{"PCN1", "PCN1", "PCN2"},
+		f.column(t, "tbl_claim_line", "silver/837_claim_line/year=2025/month=11/day=20/part-00000.csv", "claim_id"),
+		"Orphans of other files are left alone")
+}
+
+func TestRunQuarantinesHeaders(t *testing.T) {
+	f := newFixture(t)
+	policy := Policy{ClaimMemberUnknown: Quarantine, ClaimCoverageGap: Quarantine, LineWithoutHeader: Quarantine, PaymentWithoutClaim: Flag}
+	rep, err := f.checker("integrity-test", policy).Run(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[Rule]int64{ClaimMemberUnknown: 2, ClaimCoverageGap: 1, LineWithoutHeader: 4, PaymentWithoutClaim: 1}, rep.ByRule,
+		"The lines of quarantined headers are orphans")
+	assert.Equal(t, int64(7), rep.Quarantined)
+	assert.Equal(t, []string{"PCN1"}, f.column(t, "tbl_claim_header", "silver/837_claim_header/year=2025/month=11/day=20/part-00000.csv", "claim_id"))
+	assert.Empty(t, f.column(t, "tbl_claim_header", "silver/837_claim_header/year=2025/month=11/day=21/part-00000.csv", "claim_id"))
+	assert.Equal(t, []string{"PCN3", "PCN5"},
+		f.column(t, "tbl_claim_header", "silver/_quarantine/tbl_claim_header/year=2025/month=11/day=21/part-00000.csv", "claim_id"))
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy(" claim_coverage_gap=quarantine, line_without_header=flag,")
+	require.NoError(t, err)
+	assert.Equal(t, Policy{ClaimCoverageGap: Quarantine, LineWithoutHeader: Flag}, p)
+	assert.Equal(t, Flag, p.Action(PaymentWithoutClaim), "Omitted rules are flagged")
+
+	_, err = ParsePolicy("orphans=quarantine")
+	assert.EqualError(t, err, `integrity: policy "orphans=quarantine": unknown rule "orphans"`)
+	_, err = ParsePolicy("claim_coverage_gap=drop")
+	assert.EqualError(t, err, `integrity: policy "claim_coverage_gap=drop": action must be flag or quarantine`)
+	_, err = ParsePolicy("claim_coverage_gap")
+	assert.Error(t, err)
+}
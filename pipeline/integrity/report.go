@@ -0,0 +1,113 @@
+package integrity
+
+import "sort"
+
+// Report records what one integrity run checked and found. It is written to
+// ReportPrefix + run id + ".json".
+type Report struct {
+	RunID string `json:"run_id"`
+	// SilverRun is the silver run whose files were checked; empty when every
+	// row was.
+	SilverRun  string `json:"silver_run,omitempty"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	Policy     Policy `json:"policy"`
+	Counts
+	Files   []*FileReport   `json:"files"`
+	Sources []*SourceReport `json:"sources"`
+}
+
+// Counts are the rows checked and the issues found among them. Orphans are
+// rows whose referenced row is missing; coverage gaps are claims of known
+// members with no coverage on their service date.
+type Counts struct {
+	Rows         int64          `json:"rows"`
+	Orphans      int64          `json:"orphans"`
+	CoverageGaps int64          `json:"coverage_gaps"`
+	ByRule       map[Rule]int64 `json:"by_rule,omitempty"`
+	Flagged      int64          `json:"flagged"`
+	Quarantined  int64          `json:"quarantined"`
+}
+
+func (c *Counts) issue(rule Rule, action Action) {
+	if rule.orphan() {
+		c.Orphans++
+	} else {
+		c.CoverageGaps++
+	}
+	if c.ByRule == nil {
+		c.ByRule = map[Rule]int64{}
+	}
+	c.ByRule[rule]++
+	if action == Quarantine {
+		c.Quarantined++
+	} else {
+		c.Flagged++
+	}
+}
+
+// FileReport counts the checked rows loaded from one raw file.
+type FileReport struct {
+	FileID       string `json:"file_id"`
+	SourceSystem string `json:"source_system,omitempty"`
+	Counts
+}
+
+// SourceReport counts the checked rows of one source_system; the rows of
+// files without one count under "".
+type SourceReport struct {
+	SourceSystem string `json:"source_system"`
+	Files        int    `json:"files"`
+	Counts
+}
+
+// tally adds up a report as rows are checked.
+type tally struct {
+	*Report
+	files   map[string]*FileReport
+	sources map[string]*SourceReport
+}
+
+func newTally(rep *Report) *tally {
+	return &tally{Report: rep, files: map[string]*FileReport{}, sources: map[string]*SourceReport{}}
+}
+
+func (t *tally) counts(fileID, source string) []*Counts {
+	s, ok := t.sources[source]
+	if !ok {
+		s = &SourceReport{SourceSystem: source}
+		t.sources[source] = s
+	}
+	f, ok := t.files[fileID]
+	if !ok {
+		f = &FileReport{FileID: fileID, SourceSystem: source}
+		t.files[fileID] = f
+		s.Files++
+	}
+	return []*Counts{&t.Report.Counts, &f.Counts, &s.Counts}
+}
+
+func (t *tally) row(fileID, source string) {
+	for _, c := range t.counts(fileID, source) {
+		c.Rows++
+	}
+}
+
+func (t *tally) issue(fileID, source string, rule Rule, action Action) {
+	for _, c := range t.counts(fileID, source) {
+		c.issue(rule, action)
+	}
+}
+
+// finish lists the files and sources in order.
+func (t *tally) finish() {
+	t.Files, t.Sources = []*FileReport{}, []*SourceReport{}
+	for _, f := range t.files {
+		t.Files = append(t.Files, f)
+	}
+	sort.Slice(t.Files, func(i, j int) bool { return t.Files[i].FileID < t.Files[j].FileID })
+	for _, s := range t.sources {
+		t.Sources = append(t.Sources, s)
+	}
+	sort.Slice(t.Sources, func(i, j int) bool { return t.Sources[i].SourceSystem < t.Sources[j].SourceSystem })
+}
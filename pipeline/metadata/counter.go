@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// sequencePrefix keys the counter items in the file-metadata table. File IDs
+// are hex digests, so the prefix cannot collide with them, and the items have
+// none of the index keys, so no query returns them.
+const sequencePrefix = "#sequence/"
+
+// Counter hands out numbers from named sequences that outlive any process.
+type Counter interface {
+	// Next reserves n consecutive numbers of sequence name and returns the
+	// last of them. Sequences start at 1 and never hand out a number twice.
+	Next(ctx context.Context, name string, n int) (int64, error)
+}
+
+// Next increments the sequence item atomically, so concurrent workers always
+// draw disjoint numbers.
+func (s *DynamoStore) Next(ctx context.Context, name string, n int) (int64, error) {
+	if n < 1 {
+		return 0, fmt.Errorf("next %s: reserve at least one number, not %d", name, n)
+	}
+	out, err := s.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       map[string]*dynamodb.AttributeValue{"file_id": {S: aws.String(sequencePrefix + name)}},
+		UpdateExpression:          aws.String("ADD #v :n"),
+		ExpressionAttributeNames:  map[string]*string{"#v": aws.String("value")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":n": {N: aws.String(strconv.Itoa(n))}},
+		ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("next %s: %w", name, err)
+	}
+	v := out.Attributes["value"]
+	if v == nil || v.N == nil {
+		return 0, fmt.Errorf("next %s: update returned no value", name)
+	}
+	last, err := strconv.ParseInt(*v.N, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("next %s: %w", name, err)
+	}
+	return last, nil
+}
+
+func (s *MemoryStore) Next(_ context.Context, name string, n int) (int64, error) {
+	if n < 1 {
+		return 0, fmt.Errorf("next %s: reserve at least one number, not %d", name, n)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sequences == nil {
+		s.sequences = make(map[string]int64)
+	}
+	s.sequences[name] += int64(n)
+	return s.sequences[name], nil
+}
@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreNext(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	last, err := s.Next(ctx, "a", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), last)
+	last, err = s.Next(ctx, "a", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), last, "Reserves 2, 3 and 4")
+	last, err = s.Next(ctx, "b", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), last, "Sequences are independent")
+
+	_, err = s.Next(ctx, "a", 0)
+	assert.Error(t, err)
+}
+
+type fakeCounter struct {
+	fakeDynamo
+	updates []*dynamodb.UpdateItemInput
+}
+
+func (f *fakeCounter) UpdateItemWithContext(_ aws.Context, in *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	f.updates = append(f.updates, in)
+	return &dynamodb.UpdateItemOutput{Attributes: map[string]*dynamodb.AttributeValue{
+		"value": {N: aws.String("7")},
+	}}, nil
+}
+
+func TestDynamoStoreNext(t *testing.T) {
+	fake := &fakeCounter{}
+	store := NewDynamoStore(fake, "claim-dev-file-metadata")
+
+	last, err := store.Next(context.Background(), "ack-interchange", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), last)
+
+	in := fake.updates[0]
+	assert.Equal(t, "claim-dev-file-metadata", *in.TableName)
+	assert.Equal(t, "#sequence/ack-interchange", *in.Key["file_id"].S)
+	assert.Equal(t, "ADD #v :n", *in.UpdateExpression)
+	assert.Equal(t, "2", *in.ExpressionAttributeValues[":n"].N)
+	assert.Equal(t, dynamodb.ReturnValueUpdatedNew, *in.ReturnValues)
+}
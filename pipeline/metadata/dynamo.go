@@ -0,0 +1,220 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DynamoStore is the Store backed by the file-metadata DynamoDB table.
+type DynamoStore struct {
+	// Index names, defaulting to the names in infra/modules/dynamodb.
+	ChecksumIndex     string
+	FileTypeIndex     string
+	SourceSystemIndex string
+	StatusIndex       string
+
+	client dynamodbiface.DynamoDBAPI
+	table  string
+}
+
+// NewDynamoStore returns a Store writing to table through client.
+func NewDynamoStore(client dynamodbiface.DynamoDBAPI, table string) *DynamoStore {
+	return &DynamoStore{
+		ChecksumIndex:     DefaultChecksumIndex,
+		FileTypeIndex:     DefaultFileTypeIndex,
+		SourceSystemIndex: DefaultSourceSystemIndex,
+		StatusIndex:       DefaultStatusIndex,
+		client:            client,
+		table:             table,
+	}
+}
+
+func (s *DynamoStore) Get(ctx context.Context, fileID string) (*FileRecord, error) {
+	out, err := s.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]*dynamodb.AttributeValue{"file_id": {S: aws.String(fileID)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", fileID, err)
+	}
+	if len(out.Item) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var rec FileRecord
+	if err := dynamodbattribute.UnmarshalMap(out.Item, &rec); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", fileID, err)
+	}
+	return &rec, nil
+}
+
+func (s *DynamoStore) Create(ctx context.Context, rec *FileRecord) error {
+	err := s.put(ctx, rec, aws.String("attribute_not_exists(file_id)"))
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrExists
+	}
+	return err
+}
+
+func (s *DynamoStore) Put(ctx context.Context, rec *FileRecord) error {
+	return s.put(ctx, rec, nil)
+}
+
+func (s *DynamoStore) put(ctx context.Context, rec *FileRecord, condition *string) error {
+	item, err := dynamodbattribute.MarshalMap(rec)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", rec.FileID, err)
+	}
+
+	_, err = s.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: condition,
+	})
+	if err != nil {
+		return fmt.Errorf("put %s: %w", rec.FileID, err)
+	}
+	return nil
+}
+
+func (s *DynamoStore) FindByChecksum(ctx context.Context, checksum string) ([]*FileRecord, error) {
+	var out []*FileRecord
+	var decodeErr error
+	err := s.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.table),
+		IndexName:                 aws.String(s.ChecksumIndex),
+		KeyConditionExpression:    aws.String("checksum = :c"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":c": {S: aws.String(checksum)}},
+		ScanIndexForward:          aws.Bool(true),
+	}, func(page *dynamodb.QueryOutput, _ bool) bool {
+		out, decodeErr = appendItems(out, page.Items)
+		return decodeErr == nil
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query %s by checksum: %w", s.ChecksumIndex, err)
+	}
+	return out, nil
+}
+
+func (s *DynamoStore) Query(ctx context.Context, q Query) (*Page, error) {
+	in, p, err := s.queryInput(q)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.client.QueryWithContext(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", aws.StringValue(in.IndexName), err)
+	}
+
+	page := &Page{}
+	if page.Records, err = appendItems(nil, out.Items); err != nil {
+		return nil, fmt.Errorf("decode %s page: %w", aws.StringValue(in.IndexName), err)
+	}
+	if len(out.LastEvaluatedKey) > 0 {
+		key := make(map[string]string, len(out.LastEvaluatedKey))
+		for k, v := range out.LastEvaluatedKey {
+			key[k] = aws.StringValue(v.S)
+		}
+		page.NextPageToken = encodePageToken(p.kind, key)
+	}
+	return page, nil
+}
+
+func (s *DynamoStore) queryInput(q Query) (*dynamodb.QueryInput, queryPlan, error) {
+	p, err := q.plan()
+	if err != nil {
+		return nil, p, err
+	}
+	start, err := decodePageToken(q.PageToken, p)
+	if err != nil {
+		return nil, p, err
+	}
+
+	index := map[indexKind]string{
+		kindFileType:     s.FileTypeIndex,
+		kindSourceSystem: s.SourceSystemIndex,
+		kindStatus:       s.StatusIndex,
+	}[p.kind]
+
+	names := map[string]*string{"#h": aws.String(p.hashAttr)}
+	values := map[string]*dynamodb.AttributeValue{":h": {S: aws.String(p.hashValue)}}
+	keyCond := "#h = :h"
+	from, to := rangeBounds(q)
+	if from != "" || to != "" {
+		names["#r"] = aws.String(p.rangeAttr)
+	}
+	switch {
+	case from != "" && to != "":
+		// BETWEEN is inclusive; step To back one tick of TimeLayout.
+		values[":from"] = &dynamodb.AttributeValue{S: aws.String(from)}
+		values[":to"] = &dynamodb.AttributeValue{S: aws.String(FormatTime(q.To.Add(-time.Millisecond)))}
+		keyCond += " AND #r BETWEEN :from AND :to"
+	case from != "":
+		values[":from"] = &dynamodb.AttributeValue{S: aws.String(from)}
+		keyCond += " AND #r >= :from"
+	case to != "":
+		values[":to"] = &dynamodb.AttributeValue{S: aws.String(to)}
+		keyCond += " AND #r < :to"
+	}
+
+	var filters []string
+	attrs := make([]string, 0, len(p.filters))
+	for attr := range p.filters {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	for i, attr := range attrs {
+		n, v := fmt.Sprintf("#f%d", i), fmt.Sprintf(":f%d", i)
+		names[n] = aws.String(attr)
+		values[v] = &dynamodb.AttributeValue{S: aws.String(p.filters[attr])}
+		filters = append(filters, n+" = "+v)
+	}
+
+	in := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.table),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    aws.String(keyCond),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(!q.Descending),
+	}
+	if len(filters) > 0 {
+		in.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	if q.Limit > 0 {
+		in.Limit = aws.Int64(int64(q.Limit))
+	}
+	if start != nil {
+		in.ExclusiveStartKey = make(map[string]*dynamodb.AttributeValue, len(start))
+		for k, v := range start {
+			in.ExclusiveStartKey[k] = &dynamodb.AttributeValue{S: aws.String(v)}
+		}
+	}
+	return in, p, nil
+}
+
+func appendItems(out []*FileRecord, items []map[string]*dynamodb.AttributeValue) ([]*FileRecord, error) {
+	for _, item := range items {
+		var rec FileRecord
+		if err := dynamodbattribute.UnmarshalMap(item, &rec); err != nil {
+			return out, err
+		}
+		out = append(out, &rec)
+	}
+	return out, nil
+}
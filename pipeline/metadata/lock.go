@@ -0,0 +1,109 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// lockPrefix keys the lock items in the file-metadata table, next to the
+// sequences of sequencePrefix.
+const lockPrefix = "#lock/"
+
+// ErrLocked is returned by Lock while another owner holds the lock.
+var ErrLocked = errors.New("metadata: locked by another owner")
+
+// Locker grants named locks that expire, so a crashed holder cannot block
+// others for longer than its lease.
+type Locker interface {
+	// Lock takes lock name for owner until ttl from now. It fails with
+	// ErrLocked while another owner holds an unexpired lease; the owner
+	// itself may call it again to extend its lease.
+	Lock(ctx context.Context, name, owner string, ttl time.Duration) error
+	// Unlock releases the lock if owner holds it.
+	Unlock(ctx context.Context, name, owner string) error
+}
+
+// Lock writes the lock item on condition that it is absent, expired or
+// already owner's.
+func (s *DynamoStore) Lock(ctx context.Context, name, owner string, ttl time.Duration) error {
+	now := time.Now()
+	_, err := s.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"file_id":    {S: aws.String(lockPrefix + name)},
+			"owner":      {S: aws.String(owner)},
+			"expires_at": {N: aws.String(strconv.FormatInt(now.Add(ttl).UnixMilli(), 10))},
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(file_id) OR expires_at < :now OR #o = :owner"),
+		ExpressionAttributeNames: map[string]*string{"#o": aws.String("owner")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":   {N: aws.String(strconv.FormatInt(now.UnixMilli(), 10))},
+			":owner": {S: aws.String(owner)},
+		},
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return fmt.Errorf("lock %s: %w", name, ErrLocked)
+	}
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", name, err)
+	}
+	return nil
+}
+
+// Unlock deletes the lock item on condition that owner holds it.
+func (s *DynamoStore) Unlock(ctx context.Context, name, owner string) error {
+	_, err := s.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       map[string]*dynamodb.AttributeValue{"file_id": {S: aws.String(lockPrefix + name)}},
+		ConditionExpression:       aws.String("#o = :owner"),
+		ExpressionAttributeNames:  map[string]*string{"#o": aws.String("owner")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":owner": {S: aws.String(owner)}},
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// The lease expired and another owner took it; nothing to release.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unlock %s: %w", name, err)
+	}
+	return nil
+}
+
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+func (s *MemoryStore) Lock(_ context.Context, name, owner string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if l, ok := s.locks[name]; ok && l.owner != owner && now.Before(l.expires) {
+		return fmt.Errorf("lock %s: %w", name, ErrLocked)
+	}
+	if s.locks == nil {
+		s.locks = make(map[string]lease)
+	}
+	s.locks[name] = lease{owner: owner, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Unlock(_ context.Context, name, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.locks[name]; ok && l.owner == owner {
+		delete(s.locks, name)
+	}
+	return nil
+}
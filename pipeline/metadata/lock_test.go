@@ -0,0 +1,67 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreLock(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	require.NoError(t, s.Lock(ctx, "silver", "run-1", time.Hour))
+	require.NoError(t, s.Lock(ctx, "silver", "run-1", time.Hour), "The owner may extend its lease")
+	assert.ErrorIs(t, s.Lock(ctx, "silver", "run-2", time.Hour), ErrLocked)
+	require.NoError(t, s.Lock(ctx, "gold", "run-2", time.Hour), "Locks are independent")
+
+	require.NoError(t, s.Unlock(ctx, "silver", "run-2"), "Only the owner releases")
+	assert.ErrorIs(t, s.Lock(ctx, "silver", "run-2", time.Hour), ErrLocked)
+	require.NoError(t, s.Unlock(ctx, "silver", "run-1"))
+	require.NoError(t, s.Lock(ctx, "silver", "run-2", -time.Second))
+	require.NoError(t, s.Lock(ctx, "silver", "run-3", time.Hour), "An expired lease is taken over")
+}
+
+type fakeLocker struct {
+	fakeDynamo
+	puts    []*dynamodb.PutItemInput
+	deletes []*dynamodb.DeleteItemInput
+	err     error
+}
+
+func (f *fakeLocker) PutItemWithContext(_ aws.Context, in *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	f.puts = append(f.puts, in)
+	return &dynamodb.PutItemOutput{}, f.err
+}
+
+func (f *fakeLocker) DeleteItemWithContext(_ aws.Context, in *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	f.deletes = append(f.deletes, in)
+	return &dynamodb.DeleteItemOutput{}, f.err
+}
+
+func TestDynamoStoreLock(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeLocker{}
+	store := NewDynamoStore(fake, "claim-dev-file-metadata")
+
+	require.NoError(t, store.Lock(ctx, "silver", "run-1", time.Hour))
+	in := fake.puts[0]
+	assert.Equal(t, "#lock/silver", *in.Item["file_id"].S)
+	assert.Equal(t, "run-1", *in.Item["owner"].S)
+	assert.Equal(t, "attribute_not_exists(file_id) OR expires_at < :now OR #o = :owner", *in.ConditionExpression)
+	assert.Greater(t, *in.Item["expires_at"].N, *in.ExpressionAttributeValues[":now"].N)
+
+	require.NoError(t, store.Unlock(ctx, "silver", "run-1"))
+	assert.Equal(t, "#o = :owner", *fake.deletes[0].ConditionExpression)
+
+	fake.err = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "held", nil)
+	assert.ErrorIs(t, store.Lock(ctx, "silver", "run-2", time.Hour), ErrLocked)
+	assert.NoError(t, store.Unlock(ctx, "silver", "run-2"), "A lease another owner took over is not released")
+}
@@ -0,0 +1,192 @@
+package metadata
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Index names created by infra/modules/dynamodb.
+const (
+	DefaultChecksumIndex     = "checksum-index"
+	DefaultFileTypeIndex     = "file-type-index"
+	DefaultSourceSystemIndex = "source-system-index"
+	DefaultStatusIndex       = "status-index"
+)
+
+var (
+	// ErrInvalidQuery is returned for a Query that no index can serve.
+	ErrInvalidQuery = errors.New("metadata: query needs file_type, source_system or status")
+	// ErrInvalidPageToken is returned for a token that was not produced by the
+	// same kind of query.
+	ErrInvalidPageToken = errors.New("metadata: invalid page token")
+)
+
+// Query selects records through a secondary index instead of a table scan.
+// At least one of FileType, SourceSystem and Status must be set. The most
+// selective one picks the index (source_system, then file_type, then status)
+// and the others are applied as filters.
+type Query struct {
+	FileType     string
+	SourceSystem string
+	Status       Status
+
+	// From (inclusive) and To (exclusive) bound the index sort key: ingest_time
+	// for the file-type and source-system indexes, updated_at when only Status
+	// is set. Zero values leave that side open.
+	From time.Time
+	To   time.Time
+
+	// Descending returns the newest entries first.
+	Descending bool
+	// Limit caps how many index entries are read per page. As in DynamoDB it
+	// applies before filters, so a page may hold fewer records, or none, while
+	// still returning a NextPageToken. Zero reads until the end.
+	Limit int
+	// PageToken resumes after the previous page.
+	PageToken string
+}
+
+// Page is one page of query results.
+type Page struct {
+	Records []*FileRecord
+	// NextPageToken is empty on the last page.
+	NextPageToken string
+}
+
+// indexKind names an index independently of its deployed name.
+type indexKind string
+
+const (
+	kindFileType     indexKind = "file_type"
+	kindSourceSystem indexKind = "source_system"
+	kindStatus       indexKind = "status"
+)
+
+type queryPlan struct {
+	kind      indexKind
+	hashAttr  string
+	hashValue string
+	rangeAttr string
+	// filters are equality conditions on attributes other than the hash key.
+	filters map[string]string
+}
+
+func (q Query) plan() (queryPlan, error) {
+	conds := map[string]string{}
+	if q.SourceSystem != "" {
+		conds["source_system"] = q.SourceSystem
+	}
+	if q.FileType != "" {
+		conds["file_type"] = q.FileType
+	}
+	if q.Status != "" {
+		conds["status"] = string(q.Status)
+	}
+
+	var p queryPlan
+	switch {
+	case q.SourceSystem != "":
+		p = queryPlan{kind: kindSourceSystem, hashAttr: "source_system", rangeAttr: "ingest_time"}
+	case q.FileType != "":
+		p = queryPlan{kind: kindFileType, hashAttr: "file_type", rangeAttr: "ingest_time"}
+	case q.Status != "":
+		p = queryPlan{kind: kindStatus, hashAttr: "status", rangeAttr: "updated_at"}
+	default:
+		return p, ErrInvalidQuery
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return p, fmt.Errorf("%w: From must be before To", ErrInvalidQuery)
+	}
+
+	p.hashValue = conds[p.hashAttr]
+	delete(conds, p.hashAttr)
+	p.filters = conds
+	return p, nil
+}
+
+// pageToken is the decoded form of Page.NextPageToken: the index it belongs to
+// and the key attributes of the last entry read.
+type pageToken struct {
+	Index indexKind         `json:"i"`
+	Key   map[string]string `json:"k"`
+}
+
+func encodePageToken(kind indexKind, key map[string]string) string {
+	data, _ := json.Marshal(pageToken{Index: kind, Key: key})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(token string, p queryPlan) (map[string]string, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var t pageToken
+	if err := json.Unmarshal(data, &t); err != nil || t.Index != p.kind {
+		return nil, ErrInvalidPageToken
+	}
+	for _, attr := range []string{"file_id", p.hashAttr, p.rangeAttr} {
+		if t.Key[attr] == "" {
+			return nil, ErrInvalidPageToken
+		}
+	}
+	return t.Key, nil
+}
+
+// QueryAll runs q to completion, calling fn for each record in order. It stops
+// at the first error from fn.
+func QueryAll(ctx context.Context, s Store, q Query, fn func(*FileRecord) error) error {
+	for {
+		page, err := s.Query(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, rec := range page.Records {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		q.PageToken = page.NextPageToken
+	}
+}
+
+// rangeBounds formats the query's time range as sort-key strings; an empty
+// string leaves that side open.
+func rangeBounds(q Query) (from, to string) {
+	if !q.From.IsZero() {
+		from = FormatTime(q.From)
+	}
+	if !q.To.IsZero() {
+		to = FormatTime(q.To)
+	}
+	return from, to
+}
+
+// attribute returns the string value of a key or filter attribute.
+func (r *FileRecord) attribute(name string) string {
+	switch name {
+	case "file_id":
+		return r.FileID
+	case "file_type":
+		return r.FileType
+	case "source_system":
+		return r.SourceSystem
+	case "status":
+		return string(r.Status)
+	case "ingest_time":
+		return r.IngestTime
+	case "updated_at":
+		return r.UpdatedAt
+	}
+	return ""
+}
@@ -0,0 +1,209 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var day0 = time.Date(2025, 11, 17, 0, 0, 0, 0, time.UTC)
+
+// seed writes ten files: alternating 835/837, sources a/b/c in rotation, one
+// per day from day0, every third one FAILED.
+func seed(t *testing.T) *MemoryStore {
+	t.Helper()
+	s := NewMemoryStore()
+	for i := 0; i < 10; i++ {
+		rec := &FileRecord{
+			FileID:       fmt.Sprintf("f%02d", i),
+			FileType:     []string{"835", "837"}[i%2],
+			SourceSystem: []string{"a", "b", "c"}[i%3],
+			IngestTime:   FormatTime(day0.AddDate(0, 0, i)),
+		}
+		status := StatusIngested
+		if i%3 == 0 {
+			status = StatusFailed
+		}
+		rec.Transition(status, "", day0.AddDate(0, 0, i).Add(time.Hour))
+		require.NoError(t, s.Put(context.Background(), rec))
+	}
+	// Not in the source-system index: no source_system attribute.
+	require.NoError(t, s.Put(context.Background(), &FileRecord{FileID: "nosrc", FileType: "835", IngestTime: FormatTime(day0), Status: StatusIngested, UpdatedAt: FormatTime(day0)}))
+	return s
+}
+
+func ids(recs []*FileRecord) []string {
+	out := make([]string, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, r.FileID)
+	}
+	return out
+}
+
+func TestMemoryQueryByFileTypeAndRange(t *testing.T) {
+	s := seed(t)
+	page, err := s.Query(context.Background(), Query{
+		FileType: "835",
+		From:     day0.AddDate(0, 0, 2),
+		To:       day0.AddDate(0, 0, 8),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"f02", "f04", "f06"}, ids(page.Records), "To should be exclusive")
+	assert.Empty(t, page.NextPageToken)
+}
+
+func TestMemoryQueryBySourceWithFilter(t *testing.T) {
+	s := seed(t)
+	// "All 835 files from source a": source index, file_type filter.
+	page, err := s.Query(context.Background(), Query{SourceSystem: "a", FileType: "835"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"f00", "f06"}, ids(page.Records))
+}
+
+func TestMemoryQueryByStatusUsesUpdatedAt(t *testing.T) {
+	s := seed(t)
+	page, err := s.Query(context.Background(), Query{
+		Status:     StatusFailed,
+		From:       day0.AddDate(0, 0, 3).Add(time.Hour),
+		Descending: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"f09", "f06", "f03"}, ids(page.Records))
+}
+
+func TestMemoryQueryPagination(t *testing.T) {
+	s := seed(t)
+	for _, desc := range []bool{false, true} {
+		q := Query{FileType: "837", Limit: 2, Descending: desc}
+		var got []string
+		pages := 0
+		for {
+			page, err := s.Query(context.Background(), q)
+			require.NoError(t, err)
+			got = append(got, ids(page.Records)...)
+			pages++
+			if page.NextPageToken == "" {
+				break
+			}
+			q.PageToken = page.NextPageToken
+		}
+		want := []string{"f01", "f03", "f05", "f07", "f09"}
+		if desc {
+			want = []string{"f09", "f07", "f05", "f03", "f01"}
+		}
+		assert.Equal(t, want, got)
+		assert.Equal(t, 3, pages)
+	}
+}
+
+func TestMemoryQueryLimitAppliesBeforeFilter(t *testing.T) {
+	s := seed(t)
+	var got []string
+	err := QueryAll(context.Background(), s, Query{FileType: "835", Status: StatusFailed, Limit: 1}, func(r *FileRecord) error {
+		got = append(got, r.FileID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"f00", "f06"}, got)
+}
+
+func TestQueryValidation(t *testing.T) {
+	s := seed(t)
+	ctx := context.Background()
+
+	_, err := s.Query(ctx, Query{})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+
+	_, err = s.Query(ctx, Query{FileType: "835", From: day0, To: day0})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+
+	_, err = s.Query(ctx, Query{FileType: "835", PageToken: "garbage!"})
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+
+	page, err := s.Query(ctx, Query{FileType: "835", Limit: 1})
+	require.NoError(t, err)
+	_, err = s.Query(ctx, Query{Status: StatusFailed, PageToken: page.NextPageToken})
+	assert.ErrorIs(t, err, ErrInvalidPageToken, "Tokens should not cross indexes")
+}
+
+type fakeDynamo struct {
+	dynamodbiface.DynamoDBAPI
+	inputs  []*dynamodb.QueryInput
+	outputs []*dynamodb.QueryOutput
+}
+
+func (f *fakeDynamo) QueryWithContext(_ aws.Context, in *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	f.inputs = append(f.inputs, in)
+	out := f.outputs[0]
+	f.outputs = f.outputs[1:]
+	return out, nil
+}
+
+func TestDynamoQueryInput(t *testing.T) {
+	fake := &fakeDynamo{outputs: []*dynamodb.QueryOutput{
+		{
+			Items: []map[string]*dynamodb.AttributeValue{{
+				"file_id":       {S: aws.String("f1")},
+				"source_system": {S: aws.String("x")},
+				"ingest_time":   {S: aws.String("2025-11-18T00:00:00.000Z")},
+				"file_type":     {S: aws.String("835")},
+			}},
+			LastEvaluatedKey: map[string]*dynamodb.AttributeValue{
+				"file_id":       {S: aws.String("f1")},
+				"source_system": {S: aws.String("x")},
+				"ingest_time":   {S: aws.String("2025-11-18T00:00:00.000Z")},
+			},
+		},
+		{},
+	}}
+	store := NewDynamoStore(fake, "claim-dev-file-metadata")
+
+	q := Query{FileType: "835", SourceSystem: "x", From: day0, To: day0.AddDate(0, 0, 7), Limit: 50}
+	page, err := store.Query(context.Background(), q)
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1)
+	require.NotEmpty(t, page.NextPageToken)
+
+	in := fake.inputs[0]
+	assert.Equal(t, DefaultSourceSystemIndex, *in.IndexName)
+	assert.Equal(t, "#h = :h AND #r BETWEEN :from AND :to", *in.KeyConditionExpression)
+	assert.Equal(t, "source_system", *in.ExpressionAttributeNames["#h"])
+	assert.Equal(t, "ingest_time", *in.ExpressionAttributeNames["#r"])
+	assert.Equal(t, "2025-11-17T00:00:00.000Z", *in.ExpressionAttributeValues[":from"].S)
+	assert.Equal(t, "2025-11-23T23:59:59.999Z", *in.ExpressionAttributeValues[":to"].S)
+	assert.Equal(t, "#f0 = :f0", *in.FilterExpression)
+	assert.Equal(t, "file_type", *in.ExpressionAttributeNames["#f0"])
+	assert.Equal(t, int64(50), *in.Limit)
+	assert.True(t, *in.ScanIndexForward)
+	assert.Nil(t, in.ExclusiveStartKey)
+
+	q.PageToken = page.NextPageToken
+	_, err = store.Query(context.Background(), q)
+	require.NoError(t, err)
+	start := fake.inputs[1].ExclusiveStartKey
+	assert.Equal(t, "f1", *start["file_id"].S)
+	assert.Equal(t, "2025-11-18T00:00:00.000Z", *start["ingest_time"].S)
+}
+
+func TestDynamoQueryInputStatusOnly(t *testing.T) {
+	fake := &fakeDynamo{outputs: []*dynamodb.QueryOutput{{}}}
+	store := NewDynamoStore(fake, "claim-dev-file-metadata")
+
+	_, err := store.Query(context.Background(), Query{Status: StatusFailed, Descending: true})
+	require.NoError(t, err)
+
+	in := fake.inputs[0]
+	assert.Equal(t, DefaultStatusIndex, *in.IndexName)
+	assert.Equal(t, "#h = :h", *in.KeyConditionExpression)
+	assert.NotContains(t, in.ExpressionAttributeNames, "#r", "Unused names are rejected by DynamoDB")
+	assert.Nil(t, in.FilterExpression)
+	assert.False(t, *in.ScanIndexForward)
+}
@@ -0,0 +1,198 @@
+// Package metadata models the per-file lineage records kept in the
+// claim-<env>-file-metadata DynamoDB table.
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// TimeLayout is the fixed-width UTC layout used for every timestamp attribute.
+// Fixed width keeps string comparison in key conditions chronological.
+const TimeLayout = "2006-01-02T15:04:05.000Z"
+
+// FormatTime renders t in TimeLayout.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeLayout)
+}
+
+// ParseTime parses a value produced by FormatTime.
+func ParseTime(s string) (time.Time, error) {
+	return time.Parse(TimeLayout, s)
+}
+
+// Status is the lifecycle state of a file record.
+type Status string
+
+const (
+	StatusReceived         Status = "RECEIVED"
+	StatusIngested         Status = "INGESTED"
+	StatusChecksumMismatch Status = "CHECKSUM_MISMATCH"
+	// StatusDuplicate marks a file whose content was already ingested under
+	// another file_id; DuplicateOf names the original.
+	StatusDuplicate Status = "DUPLICATE"
+	StatusFailed    Status = "FAILED"
+	// StatusValidated and StatusRejected replace INGESTED for CSV files the
+	// validation stage checked: the file matched its registered schema within
+	// the error budget, or it did not. Validation says why.
+	StatusValidated Status = "VALIDATED"
+	StatusRejected  Status = "REJECTED"
+	// StatusTransformed marks a VALIDATED file whose rows the silver
+	// transformer loaded; Silver says by which run.
+	StatusTransformed Status = "TRANSFORMED"
+	// StatusReplayed marks a file re-enqueued by the replay command; the
+	// worker reprocesses it when the synthetic event arrives.
+	StatusReplayed Status = "REPLAYED"
+)
+
+// AckStatus is the overall result of the TA1/999 acknowledgment sent for an
+// X12 file.
+type AckStatus string
+
+const (
+	AckAccepted           AckStatus = "ACCEPTED"
+	AckAcceptedWithErrors AckStatus = "ACCEPTED_WITH_ERRORS"
+	AckPartiallyAccepted  AckStatus = "PARTIALLY_ACCEPTED"
+	AckRejected           AckStatus = "REJECTED"
+)
+
+// Transition is one entry in a record's status history.
+type Transition struct {
+	Status Status `dynamodbav:"status"`
+	At     string `dynamodbav:"at"`
+	Reason string `dynamodbav:"reason,omitempty"`
+}
+
+// FileRecord is the metadata written for every object that lands in the raw
+// bucket. Attribute names follow the Bronze layer spec (file_name, file_type,
+// source_system, ingest_time, checksum, record_count, uploader).
+type FileRecord struct {
+	FileID       string `dynamodbav:"file_id"`
+	FileName     string `dynamodbav:"file_name"`
+	FileType     string `dynamodbav:"file_type,omitempty"`
+	SourceSystem string `dynamodbav:"source_system,omitempty"`
+	Uploader     string `dynamodbav:"uploader,omitempty"`
+	Bucket       string `dynamodbav:"bucket"`
+	Key          string `dynamodbav:"object_key"`
+	VersionID    string `dynamodbav:"version_id,omitempty"`
+	SizeBytes    int64  `dynamodbav:"size_bytes"`
+	IngestTime   string `dynamodbav:"ingest_time"`
+	UpdatedAt    string `dynamodbav:"updated_at"`
+	Status       Status `dynamodbav:"status"`
+
+	// Checksum is the hex SHA-256 of the object body as read by the worker.
+	Checksum string `dynamodbav:"checksum,omitempty"`
+	// ClientChecksum is the hex SHA-256 the uploader declared, if any.
+	ClientChecksum   string `dynamodbav:"client_checksum,omitempty"`
+	ChecksumMismatch bool   `dynamodbav:"checksum_mismatch,omitempty"`
+	// RecordCount is the number of CSV data rows, excluding the header.
+	RecordCount int64 `dynamodbav:"record_count"`
+	// DuplicateOf is the file_id of the earlier file with identical content.
+	DuplicateOf string `dynamodbav:"duplicate_of,omitempty"`
+
+	// AckStatus, AckLocation and AckTime describe the acknowledgment written
+	// for an X12 file; they are empty for CSV files. AckLocation is an s3://
+	// URI and is empty when the file had no interchange header to answer.
+	AckStatus   AckStatus `dynamodbav:"ack_status,omitempty"`
+	AckLocation string    `dynamodbav:"ack_location,omitempty"`
+	AckTime     string    `dynamodbav:"ack_time,omitempty"`
+	// AckControlNumber is the ISA13/GS06 of the first outbound interchange,
+	// drawn once so a redelivered file rewrites the same acknowledgment.
+	AckControlNumber int `dynamodbav:"ack_control_number,omitempty"`
+
+	// ErrorReport is the s3:// URI of the JSON diagnostics written for an X12
+	// file with envelope, segment or element errors, and ErrorCount the
+	// number of errors it lists. Both are empty for a clean file.
+	ErrorReport string `dynamodbav:"error_report,omitempty"`
+	ErrorCount  int    `dynamodbav:"error_count,omitempty"`
+
+	// ArchiveLocation and ArchiveVersionID identify the write-once copy of an
+	// X12 original in the EDI archive bucket; they are empty for CSV files.
+	// The version id is what a retention audit or restore asks for.
+	ArchiveLocation  string `dynamodbav:"archive_location,omitempty"`
+	ArchiveVersionID string `dynamodbav:"archive_version_id,omitempty"`
+
+	// Validation summarizes the check of a CSV file against its registered
+	// schema; it is nil for X12 files and when validation is off.
+	Validation *Validation `dynamodbav:"validation,omitempty"`
+
+	// Silver summarizes the load of the file's rows into the silver tables.
+	Silver *SilverLoad `dynamodbav:"silver,omitempty"`
+
+	Transitions []Transition `dynamodbav:"transitions,omitempty"`
+}
+
+// Validation is the result of validating a CSV file.
+type Validation struct {
+	// Schema and SchemaVersion name the registered schema the rows were
+	// checked against, always its latest version. HeaderVersion is the
+	// version whose columns are exactly the file's, if any.
+	Schema        string `dynamodbav:"schema,omitempty"`
+	SchemaVersion int    `dynamodbav:"schema_version,omitempty"`
+	HeaderVersion int    `dynamodbav:"header_version,omitempty"`
+	// Encoding is UTF-8 or UTF-8-BOM.
+	Encoding string `dynamodbav:"encoding,omitempty"`
+	// HeaderChanges are the differences between the header and the schema,
+	// e.g. a column under a former name.
+	HeaderChanges []string `dynamodbav:"header_changes,omitempty"`
+	// Rows counts data rows; ErrorRows those with at least one error and
+	// Errors the errors found.
+	Rows      int64 `dynamodbav:"rows"`
+	ErrorRows int64 `dynamodbav:"error_rows"`
+	Errors    int64 `dynamodbav:"errors"`
+	// Samples are the first row errors, in file order.
+	Samples []RowError `dynamodbav:"samples,omitempty"`
+	// Reason says why the file was rejected; it is empty for a valid file.
+	Reason      string `dynamodbav:"reason,omitempty"`
+	ValidatedAt string `dynamodbav:"validated_at"`
+}
+
+// SilverLoad is the result of transforming a file into silver.
+type SilverLoad struct {
+	// RunID names the transformer run and Manifest is the s3:// URI of its
+	// manifest.
+	RunID    string `dynamodbav:"run_id"`
+	Manifest string `dynamodbav:"manifest"`
+	// Rows counts data rows read and RejectedRows those that could not be
+	// converted to the silver types.
+	Rows         int64 `dynamodbav:"rows"`
+	RejectedRows int64 `dynamodbav:"rejected_rows"`
+	// RejectedByRule counts rejected rows by the first rule each broke.
+	// The rows themselves are in ErrorObject, the s3:// URI of the
+	// tbl_rejected_row partition file of the file's ingest date.
+	RejectedByRule map[string]int64 `dynamodbav:"rejected_by_rule,omitempty"`
+	ErrorObject    string           `dynamodbav:"error_object,omitempty"`
+	LoadedAt       string           `dynamodbav:"loaded_at"`
+}
+
+// RowError is one problem of one CSV row.
+type RowError struct {
+	// Row is the 1-based data row, Line the file line the row starts on.
+	Row    int64  `dynamodbav:"row"`
+	Line   int    `dynamodbav:"line"`
+	Column string `dynamodbav:"column,omitempty"`
+	Rule   string `dynamodbav:"rule"`
+	Msg    string `dynamodbav:"message"`
+}
+
+// NewFileID derives a stable file_id from the object's location so redelivered
+// notifications for the same object version map to the same record.
+func NewFileID(bucket, key, versionID string) string {
+	sum := sha256.Sum256([]byte(bucket + "/" + key + "#" + versionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ReadyForProcessing reports whether downstream stages should pick the file
+// up. Duplicates, mismatches, rejections and failures are skipped.
+func (r *FileRecord) ReadyForProcessing() bool {
+	return r.Status == StatusIngested || r.Status == StatusValidated
+}
+
+// Transition moves the record to status and appends the change to its history.
+func (r *FileRecord) Transition(status Status, reason string, at time.Time) {
+	ts := FormatTime(at)
+	r.Status = status
+	r.UpdatedAt = ts
+	r.Transitions = append(r.Transitions, Transition{Status: status, At: ts, Reason: reason})
+}
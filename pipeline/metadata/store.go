@@ -0,0 +1,205 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned when no record exists for a file_id.
+	ErrNotFound = errors.New("metadata: record not found")
+	// ErrExists is returned by Create when the file_id is already taken.
+	ErrExists = errors.New("metadata: record already exists")
+)
+
+// Store persists file records.
+type Store interface {
+	Get(ctx context.Context, fileID string) (*FileRecord, error)
+	// Create writes rec only if no record with the same file_id exists.
+	Create(ctx context.Context, rec *FileRecord) error
+	// Put writes rec unconditionally.
+	Put(ctx context.Context, rec *FileRecord) error
+	// FindByChecksum returns the records whose content hash is checksum,
+	// oldest ingest_time first. DynamoStore serves this from the checksum
+	// index, so only its projected attributes are populated.
+	FindByChecksum(ctx context.Context, checksum string) ([]*FileRecord, error)
+	// Query reads one page of records through a secondary index.
+	Query(ctx context.Context, q Query) (*Page, error)
+}
+
+// MemoryStore is an in-process stand-in for the DynamoDB table, used by tests
+// and local runs.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]*FileRecord
+	sequences map[string]int64
+	locks     map[string]lease
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*FileRecord)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, fileID string) (*FileRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[fileID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return rec.clone(), nil
+}
+
+func (s *MemoryStore) Create(_ context.Context, rec *FileRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[rec.FileID]; ok {
+		return ErrExists
+	}
+	s.records[rec.FileID] = rec.clone()
+	return nil
+}
+
+func (s *MemoryStore) Put(_ context.Context, rec *FileRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[rec.FileID] = rec.clone()
+	return nil
+}
+
+func (s *MemoryStore) FindByChecksum(_ context.Context, checksum string) ([]*FileRecord, error) {
+	return s.filter(func(r *FileRecord) bool { return r.Checksum == checksum }), nil
+}
+
+// Query mirrors DynamoDB GSI semantics: records missing the index keys are
+// not indexed, Limit counts entries before filtering, and ties on the sort key
+// are broken by file_id.
+func (s *MemoryStore) Query(_ context.Context, q Query) (*Page, error) {
+	p, err := q.plan()
+	if err != nil {
+		return nil, err
+	}
+	start, err := decodePageToken(q.PageToken, p)
+	if err != nil {
+		return nil, err
+	}
+	from, to := rangeBounds(q)
+
+	entries := s.filter(func(r *FileRecord) bool {
+		sortKey := r.attribute(p.rangeAttr)
+		if r.attribute(p.hashAttr) != p.hashValue || sortKey == "" {
+			return false
+		}
+		return (from == "" || sortKey >= from) && (to == "" || sortKey < to)
+	})
+	less := func(a, b *FileRecord) bool {
+		ka, kb := a.attribute(p.rangeAttr), b.attribute(p.rangeAttr)
+		if ka != kb {
+			return ka < kb
+		}
+		return a.FileID < b.FileID
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if q.Descending {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+
+	if start != nil {
+		marker := &FileRecord{FileID: start["file_id"]}
+		setAttribute(marker, p.rangeAttr, start[p.rangeAttr])
+		i := sort.Search(len(entries), func(i int) bool {
+			if q.Descending {
+				return less(entries[i], marker)
+			}
+			return less(marker, entries[i])
+		})
+		entries = entries[i:]
+	}
+
+	page := &Page{}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		last := entries[q.Limit-1]
+		page.NextPageToken = encodePageToken(p.kind, map[string]string{
+			"file_id":   last.FileID,
+			p.hashAttr:  last.attribute(p.hashAttr),
+			p.rangeAttr: last.attribute(p.rangeAttr),
+		})
+		entries = entries[:q.Limit]
+	}
+	for _, r := range entries {
+		if matchesFilters(r, p.filters) {
+			page.Records = append(page.Records, r)
+		}
+	}
+	return page, nil
+}
+
+// filter returns copies of matching records ordered by ingest_time, then file_id.
+func (s *MemoryStore) filter(match func(*FileRecord) bool) []*FileRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []*FileRecord
+	for _, r := range s.records {
+		if match(r) {
+			out = append(out, r.clone())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].IngestTime != out[j].IngestTime {
+			return out[i].IngestTime < out[j].IngestTime
+		}
+		return out[i].FileID < out[j].FileID
+	})
+	return out
+}
+
+func matchesFilters(r *FileRecord, filters map[string]string) bool {
+	for attr, want := range filters {
+		if r.attribute(attr) != want {
+			return false
+		}
+	}
+	return true
+}
+
+func setAttribute(r *FileRecord, name, value string) {
+	switch name {
+	case "ingest_time":
+		r.IngestTime = value
+	case "updated_at":
+		r.UpdatedAt = value
+	}
+}
+
+// clone copies r deeply, as a DynamoDB round trip would, so callers of the
+// MemoryStore never share state with the stored record.
+func (r *FileRecord) clone() *FileRecord {
+	c := *r
+	c.Transitions = append([]Transition(nil), r.Transitions...)
+	if r.Validation != nil {
+		v := *r.Validation
+		v.HeaderChanges = append([]string(nil), v.HeaderChanges...)
+		v.Samples = append([]RowError(nil), v.Samples...)
+		c.Validation = &v
+	}
+	if r.Silver != nil {
+		sl := *r.Silver
+		if sl.RejectedByRule != nil {
+			sl.RejectedByRule = make(map[string]int64, len(r.Silver.RejectedByRule))
+			for rule, n := range r.Silver.RejectedByRule {
+				sl.RejectedByRule[rule] = n
+			}
+		}
+		c.Silver = &sl
+	}
+	return &c
+}
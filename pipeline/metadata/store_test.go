@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	rec := &FileRecord{FileID: "f1", FileName: "a.csv"}
+	rec.Transition(StatusReceived, "", time.Date(2025, 11, 21, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, s.Create(ctx, rec))
+	assert.ErrorIs(t, s.Create(ctx, rec), ErrExists)
+
+	got, err := s.Get(ctx, "f1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusReceived, got.Status)
+
+	// Mutating the returned copy must not affect the stored record.
+	got.Transition(StatusIngested, "", time.Now())
+	again, err := s.Get(ctx, "f1")
+	require.NoError(t, err)
+	assert.Len(t, again.Transitions, 1)
+
+	// Nor may mutating what was put, or the nested results of a copy.
+	rec.Validation = &Validation{
+		HeaderChanges: []string{"renamed procedure"},
+		Rows:          2,
+		Samples:       []RowError{{Row: 1, Line: 2, Rule: "date", Msg: "bad date"}},
+	}
+	rec.Silver = &SilverLoad{RunID: "r1", RejectedByRule: map[string]int64{"convert": 1}}
+	require.NoError(t, s.Put(ctx, rec))
+	rec.Validation.Rows = 99
+	rec.Validation.Samples[0].Msg = "changed"
+	rec.Silver.RejectedByRule["convert"] = 99
+	got, err = s.Get(ctx, "f1")
+	require.NoError(t, err)
+	got.Validation.HeaderChanges[0] = "changed"
+	got.Silver.RunID = "changed"
+	again, err = s.Get(ctx, "f1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), again.Validation.Rows)
+	assert.Equal(t, "bad date", again.Validation.Samples[0].Msg)
+	assert.Equal(t, "renamed procedure", again.Validation.HeaderChanges[0])
+	assert.Equal(t, map[string]int64{"convert": 1}, again.Silver.RejectedByRule)
+	assert.Equal(t, "r1", again.Silver.RunID)
+
+	_, err = s.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFileRecordAttributeNames(t *testing.T) {
+	rec := &FileRecord{FileID: "f1", Checksum: "abc", RecordCount: 3, IngestTime: FormatTime(time.Unix(0, 0))}
+	item, err := dynamodbattribute.MarshalMap(rec)
+	require.NoError(t, err)
+
+	for _, name := range []string{"file_id", "file_name", "ingest_time", "checksum", "record_count", "status"} {
+		assert.Contains(t, item, name, "Item should carry the %s attribute", name)
+	}
+	assert.NotContains(t, item, "client_checksum", "Empty optional attributes should be omitted")
+	assert.Equal(t, "1970-01-01T00:00:00.000Z", *item["ingest_time"].S)
+}
+
+func TestNewFileIDIsStable(t *testing.T) {
+	a := NewFileID("claim-dev-raw", "raw/837/a.csv", "v1")
+	assert.Equal(t, a, NewFileID("claim-dev-raw", "raw/837/a.csv", "v1"))
+	assert.NotEqual(t, a, NewFileID("claim-dev-raw", "raw/837/a.csv", "v2"))
+	assert.Len(t, a, 32)
+}
@@ -0,0 +1,195 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// metaDir holds per-object sidecars; it sits beside the buckets under Root.
+const metaDir = ".objectstore-meta"
+
+// Dir is a filesystem-backed stand-in for S3. Each bucket is a directory under
+// Root and each key a file path below it. Only the latest version of an object
+// is kept, but version ids are still assigned so callers behave as on a
+// versioned bucket.
+type Dir struct {
+	Root string
+}
+
+// NewDir returns a Dir rooted at root.
+func NewDir(root string) *Dir {
+	return &Dir{Root: root}
+}
+
+type sidecar struct {
+	VersionID   string            `json:"version_id"`
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+func (d *Dir) objectPath(bucket, key string) string {
+	return filepath.Join(d.Root, bucket, filepath.FromSlash(key))
+}
+
+func (d *Dir) sidecarPath(bucket, key string) string {
+	return filepath.Join(d.Root, metaDir, bucket, filepath.FromSlash(key)+".json")
+}
+
+func (d *Dir) Get(ctx context.Context, bucket, key, versionID string) (*Object, error) {
+	obj, err := d.Head(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(d.objectPath(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	obj.Body = f
+	return obj, nil
+}
+
+func (d *Dir) Head(_ context.Context, bucket, key, versionID string) (*Object, error) {
+	sc, err := d.readSidecar(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if versionID != "" && versionID != sc.VersionID {
+		return nil, ErrNotFound
+	}
+
+	info, err := os.Stat(d.objectPath(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	meta := sc.Metadata
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	return &Object{Size: info.Size(), VersionID: sc.VersionID, Metadata: meta}, nil
+}
+
+func (d *Dir) Put(_ context.Context, bucket, key string, body io.Reader, opts PutOptions) (string, error) {
+	path := d.objectPath(bucket, key)
+	if err := writeAtomic(path, body); err != nil {
+		return "", fmt.Errorf("put %s/%s: %w", bucket, key, err)
+	}
+
+	sc := sidecar{VersionID: newVersionID(), ContentType: opts.ContentType, Metadata: opts.Metadata}
+	data, err := json.Marshal(sc)
+	if err != nil {
+		return "", err
+	}
+	if err := writeAtomic(d.sidecarPath(bucket, key), bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("put %s/%s metadata: %w", bucket, key, err)
+	}
+	return sc.VersionID, nil
+}
+
+func (d *Dir) Copy(ctx context.Context, srcBucket, srcKey, srcVersionID, dstBucket, dstKey string, opts PutOptions) (string, error) {
+	obj, err := d.Get(ctx, srcBucket, srcKey, srcVersionID)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Body.Close()
+	return d.Put(ctx, dstBucket, dstKey, obj.Body, opts)
+}
+
+func (d *Dir) List(_ context.Context, bucket, prefix string) ([]string, error) {
+	root := filepath.Join(d.Root, bucket)
+	// Walk only the deepest directory the prefix names.
+	start := root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		start = filepath.Join(root, filepath.FromSlash(prefix[:i]))
+	}
+	var keys []string
+	err := filepath.WalkDir(start, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		// Temporary files of writeAtomic are not objects yet.
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s/%s: %w", bucket, prefix, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (d *Dir) readSidecar(bucket, key string) (sidecar, error) {
+	var sc sidecar
+	data, err := os.ReadFile(d.sidecarPath(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Objects copied into the tree by hand have no sidecar.
+		if _, statErr := os.Stat(d.objectPath(bucket, key)); statErr != nil {
+			return sc, ErrNotFound
+		}
+		return sc, nil
+	}
+	if err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return sc, fmt.Errorf("decode metadata for %s/%s: %w", bucket, key, err)
+	}
+	return sc, nil
+}
+
+func writeAtomic(path string, body io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func newVersionID() string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,172 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3Store is the Store backed by Amazon S3.
+type S3Store struct {
+	client   s3iface.S3API
+	uploader *s3manager.Uploader
+}
+
+// NewS3Store returns a Store using client. Uploads are streamed in parts so
+// bodies of unknown length never need to be buffered whole.
+func NewS3Store(client s3iface.S3API) *S3Store {
+	return &S3Store{
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+	}
+}
+
+func (s *S3Store) Get(ctx context.Context, bucket, key, versionID string) (*Object, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		in.VersionId = aws.String(versionID)
+	}
+
+	out, err := s.client.GetObjectWithContext(ctx, in)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get s3://%s/%s: %w", bucket, key, err)
+	}
+	return &Object{
+		Body:      out.Body,
+		Size:      aws.Int64Value(out.ContentLength),
+		VersionID: aws.StringValue(out.VersionId),
+		Metadata:  lowerKeys(out.Metadata),
+	}, nil
+}
+
+func (s *S3Store) Head(ctx context.Context, bucket, key, versionID string) (*Object, error) {
+	in := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		in.VersionId = aws.String(versionID)
+	}
+
+	out, err := s.client.HeadObjectWithContext(ctx, in)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("head s3://%s/%s: %w", bucket, key, err)
+	}
+	return &Object{
+		Size:      aws.Int64Value(out.ContentLength),
+		VersionID: aws.StringValue(out.VersionId),
+		Metadata:  lowerKeys(out.Metadata),
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, bucket, key string, body io.Reader, opts PutOptions) (string, error) {
+	in := &s3manager.UploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		Body:     body,
+		Metadata: aws.StringMap(opts.Metadata),
+	}
+	if opts.ContentType != "" {
+		in.ContentType = aws.String(opts.ContentType)
+	}
+
+	out, err := s.uploader.UploadWithContext(ctx, in)
+	if err != nil {
+		return "", fmt.Errorf("put s3://%s/%s: %w", bucket, key, err)
+	}
+	return aws.StringValue(out.VersionID), nil
+}
+
+// Copy is a server-side CopyObject, so the body never passes through the
+// caller. Unlike a multipart upload it needs no Content-MD5 when the
+// destination bucket has Object Lock enabled. Objects above 5 GB cannot be
+// copied this way.
+func (s *S3Store) Copy(ctx context.Context, srcBucket, srcKey, srcVersionID, dstBucket, dstKey string, opts PutOptions) (string, error) {
+	source := copySource(srcBucket, srcKey)
+	if srcVersionID != "" {
+		source += "?versionId=" + url.QueryEscape(srcVersionID)
+	}
+	in := &s3.CopyObjectInput{
+		Bucket:            aws.String(dstBucket),
+		Key:               aws.String(dstKey),
+		CopySource:        aws.String(source),
+		Metadata:          aws.StringMap(opts.Metadata),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+	}
+	if opts.ContentType != "" {
+		in.ContentType = aws.String(opts.ContentType)
+	}
+
+	out, err := s.client.CopyObjectWithContext(ctx, in)
+	if err != nil {
+		if isNotFound(err) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("copy s3://%s/%s to s3://%s/%s: %w", srcBucket, srcKey, dstBucket, dstKey, err)
+	}
+	return aws.StringValue(out.VersionId), nil
+}
+
+// List pages through ListObjectsV2, which returns keys in UTF-8 binary
+// order.
+func (s *S3Store) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	in := &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)}
+	err := s.client.ListObjectsV2PagesWithContext(ctx, in, func(out *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range out.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list s3://%s/%s: %w", bucket, prefix, err)
+	}
+	return keys, nil
+}
+
+// copySource renders the URL-encoded bucket/key of x-amz-copy-source.
+func copySource(bucket, key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return bucket + "/" + strings.Join(parts, "/")
+}
+
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	switch aerr.Code() {
+	case s3.ErrCodeNoSuchKey, "NoSuchVersion", "NotFound":
+		return true
+	}
+	return false
+}
+
+func lowerKeys(m map[string]*string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	return out
+}
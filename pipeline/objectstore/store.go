@@ -0,0 +1,43 @@
+// Package objectstore abstracts the S3 buckets the pipeline reads and writes so
+// workers can run against a local directory instead of AWS.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when the requested object does not exist.
+var ErrNotFound = errors.New("objectstore: object not found")
+
+// Object is an open object body plus its attributes. Callers must close Body.
+type Object struct {
+	Body      io.ReadCloser
+	Size      int64
+	VersionID string
+	// Metadata holds user metadata (x-amz-meta-*) with lower-cased keys.
+	Metadata map[string]string
+}
+
+// PutOptions carries optional attributes for Put.
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
+
+// Store reads and writes objects by bucket and key.
+type Store interface {
+	// Get opens an object. An empty versionID selects the latest version.
+	Get(ctx context.Context, bucket, key, versionID string) (*Object, error)
+	// Head returns an object's attributes without opening it; Body is nil.
+	Head(ctx context.Context, bucket, key, versionID string) (*Object, error)
+	// Put writes body and returns the new version id, if the store versions.
+	Put(ctx context.Context, bucket, key string, body io.Reader, opts PutOptions) (string, error)
+	// Copy copies a version of an object to dstBucket/dstKey, replacing its
+	// content type and user metadata with opts, and returns the new version
+	// id. An empty srcVersionID selects the latest version.
+	Copy(ctx context.Context, srcBucket, srcKey, srcVersionID, dstBucket, dstKey string, opts PutOptions) (string, error)
+	// List returns the keys of the latest objects under prefix, in key order.
+	List(ctx context.Context, bucket, prefix string) ([]string, error)
+}
@@ -0,0 +1,106 @@
+// Package parquet writes and reads the Parquet files of the silver and gold
+// tables. A file's columns are those of a registry schema, flat and in
+// order; partition columns stay in the directory path. Values are typed as
+// schema.Column.Parse returns them and stored the way Athena, Glue and
+// Redshift Spectrum read them:
+//
+//	string     BYTE_ARRAY (UTF8)
+//	int        INT64
+//	decimal    FIXED_LEN_BYTE_ARRAY (DECIMAL), the smallest width for the precision
+//	date       INT32 (DATE), days since 1970-01-01
+//	timestamp  INT64 (TIMESTAMP_MICROS), UTC
+//	boolean    BOOLEAN
+//
+// Pages are PLAIN-encoded data pages (v1), one per column chunk, compressed
+// with Snappy. Nullable columns are OPTIONAL and carry RLE definition levels.
+// Rows are buffered into row groups bounded by Options.
+package parquet
+
+import (
+	"fmt"
+	"math"
+
+	"claim-management-system/pipeline/schema"
+)
+
+var magic = []byte("PAR1")
+
+// Physical types.
+const (
+	typeBoolean           = 0
+	typeInt32             = 1
+	typeInt64             = 2
+	typeByteArray         = 6
+	typeFixedLenByteArray = 7
+)
+
+var typeNames = map[int64]string{
+	typeBoolean: "BOOLEAN", typeInt32: "INT32", typeInt64: "INT64", 3: "INT96", 4: "FLOAT", 5: "DOUBLE",
+	typeByteArray: "BYTE_ARRAY", typeFixedLenByteArray: "FIXED_LEN_BYTE_ARRAY",
+}
+
+// Converted types.
+const (
+	convertedNone            = -1
+	convertedUTF8            = 0
+	convertedDecimal         = 5
+	convertedDate            = 6
+	convertedTimestampMicros = 10
+)
+
+// Field repetition types.
+const (
+	required = 0
+	optional = 1
+)
+
+// Encodings, page types and codecs.
+const (
+	encodingPlain = 0
+	encodingRLE   = 3
+	pageData      = 0
+	codecNone     = 0
+	codecSnappy   = 1
+)
+
+var codecNames = map[int64]string{codecNone: "UNCOMPRESSED", codecSnappy: "SNAPPY", 2: "GZIP", 3: "LZO", 4: "BROTLI", 5: "LZ4", 6: "ZSTD", 7: "LZ4_RAW"}
+
+// physical is how a column is stored.
+type physical struct {
+	typ       int64
+	length    int
+	converted int32
+}
+
+func physicalType(c *schema.Column) physical {
+	switch c.Type.Kind {
+	case schema.Int:
+		return physical{typ: typeInt64, converted: convertedNone}
+	case schema.Decimal:
+		return physical{typ: typeFixedLenByteArray, length: decimalWidth(c.Type.Precision), converted: convertedDecimal}
+	case schema.Date:
+		return physical{typ: typeInt32, converted: convertedDate}
+	case schema.Timestamp:
+		return physical{typ: typeInt64, converted: convertedTimestampMicros}
+	case schema.Boolean:
+		return physical{typ: typeBoolean, converted: convertedNone}
+	}
+	return physical{typ: typeByteArray, converted: convertedUTF8}
+}
+
+func (p physical) String() string {
+	if p.typ == typeFixedLenByteArray {
+		return fmt.Sprintf("%s(%d)", typeNames[p.typ], p.length)
+	}
+	return typeNames[p.typ]
+}
+
+// decimalWidth is the number of bytes of a two's complement integer that
+// holds every value of the precision.
+func decimalWidth(precision int) int {
+	n := 1
+	for float64(8*n-1) < float64(precision)*math.Log2(10) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,203 @@
+package parquet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/schema"
+)
+
+func testSchema(t *testing.T) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse([]byte(`
+name: tbl_test
+layer: silver
+version: 1
+location: silver/test/
+columns:
+  - {name: id, type: string}
+  - {name: note, type: string, nullable: true}
+  - {name: count, type: int}
+  - {name: amount, type: "decimal(12,2)"}
+  - {name: big, type: "decimal(38,4)", nullable: true}
+  - {name: day, type: date}
+  - {name: at, type: timestamp, nullable: true}
+  - {name: flag, type: boolean, nullable: true}
+`))
+	require.NoError(t, err)
+	return s
+}
+
+func testRows(n int) [][]interface{} {
+	rows := make([][]interface{}, n)
+	for i := range rows {
+		row := []interface{}{
+			string(rune('A' + i%26)),
+			nil,
+			int64(i) - 2,
+			schema.Fixed{Unscaled: int64(i)*12345 - 50000, Scale: 2},
+			nil,
+			time.Date(1969+i, 12, 31, 0, 0, 0, 0, time.UTC),
+			nil,
+			i%3 == 0,
+		}
+		if i%2 == 0 {
+			row[1] = "note é"
+			row[4] = schema.Fixed{Unscaled: -int64(i) * 1_000_000_000_000, Scale: 4}
+			row[6] = time.Date(2025, 11, 21, 10, 0, i, 123456000, time.UTC)
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func write(t *testing.T, s *schema.Schema, rows [][]interface{}, opts Options) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, s, opts)
+	for _, row := range rows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := testSchema(t)
+	rows := testRows(7)
+	data := write(t, s, rows, Options{})
+	assert.Equal(t, "PAR1", string(data[:4]))
+	assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+
+	got, err := Read(data, s)
+	require.NoError(t, err)
+	assert.Equal(t, rows, got)
+
+	f, err := Open(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), f.Rows)
+	assert.Equal(t, CreatedBy, f.CreatedBy)
+	assert.Equal(t, "tbl_test v1", f.KeyValues["schema"])
+	require.Len(t, f.RowGroups, 1)
+	assert.Equal(t, []string{"SNAPPY", "SNAPPY", "SNAPPY", "SNAPPY", "SNAPPY", "SNAPPY", "SNAPPY", "SNAPPY"}, f.RowGroups[0].Codecs)
+	assert.Equal(t, []Column{
+		{Name: "id", Type: "BYTE_ARRAY"},
+		{Name: "note", Type: "BYTE_ARRAY", Nullable: true},
+		{Name: "count", Type: "INT64"},
+		{Name: "amount", Type: "FIXED_LEN_BYTE_ARRAY(6)"},
+		{Name: "big", Type: "FIXED_LEN_BYTE_ARRAY(16)", Nullable: true},
+		{Name: "day", Type: "INT32"},
+		{Name: "at", Type: "INT64", Nullable: true},
+		{Name: "flag", Type: "BOOLEAN", Nullable: true},
+	}, columns(f))
+
+	uncompressed := write(t, s, rows, Options{Uncompressed: true})
+	got, err = Read(uncompressed, s)
+	require.NoError(t, err)
+	assert.Equal(t, rows, got)
+}
+
+// columns returns the file's columns without their unexported fields.
+func columns(f *File) []Column {
+	out := make([]Column, len(f.Columns))
+	for i, c := range f.Columns {
+		out[i] = Column{Name: c.Name, Type: c.Type, Nullable: c.Nullable}
+	}
+	return out
+}
+
+func TestRowGroups(t *testing.T) {
+	s := testSchema(t)
+	rows := testRows(25)
+
+	data := write(t, s, rows, Options{RowGroupRows: 10})
+	f, err := Open(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	var sizes []int64
+	for _, g := range f.RowGroups {
+		sizes = append(sizes, g.Rows)
+	}
+	assert.Equal(t, []int64{10, 10, 5}, sizes)
+	got, err := f.Read(s)
+	require.NoError(t, err)
+	assert.Equal(t, rows, got, "Rows come back in order across row groups")
+
+	data = write(t, s, rows, Options{RowGroupBytes: 200})
+	f, err = Open(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Greater(t, len(f.RowGroups), 2, "Byte limit splits row groups")
+	for _, g := range f.RowGroups {
+		assert.Less(t, g.Rows, int64(25))
+	}
+
+	data = write(t, s, nil, Options{})
+	got, err = Read(data, s)
+	require.NoError(t, err)
+	assert.Empty(t, got, "A file without rows is valid")
+}
+
+func TestReadEvolvedSchema(t *testing.T) {
+	s := testSchema(t)
+	data := write(t, s, testRows(2), Options{})
+
+	v2, err := schema.Parse([]byte(`
+name: tbl_test
+layer: silver
+version: 2
+location: silver/test/
+columns:
+  - {name: id, type: string}
+  - {name: amount, type: "decimal(12,2)"}
+  - {name: email, type: string, nullable: true}
+`))
+	require.NoError(t, err)
+	got, err := Read(data, v2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{"A", schema.Fixed{Unscaled: -50000, Scale: 2}, nil},
+		{"B", schema.Fixed{Unscaled: -37655, Scale: 2}, nil},
+	}, got)
+
+	v2.Columns[2].Nullable = false
+	_, err = Read(data, v2)
+	assert.EqualError(t, err, "parquet: tbl_test v2: file lacks required column email")
+
+	v2.Columns[2] = &schema.Column{Name: "count", Type: schema.Type{Kind: schema.String}}
+	_, err = Read(data, v2)
+	assert.EqualError(t, err, "parquet: tbl_test v2: column count is stored as INT64, not string")
+}
+
+func TestWriteErrors(t *testing.T) {
+	s := testSchema(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, s, Options{})
+	assert.EqualError(t, w.Write([]interface{}{"A"}), "parquet: tbl_test v1: row has 1 values, schema has 8 columns")
+
+	row := testRows(1)[0]
+	row[0] = nil
+	assert.EqualError(t, w.Write(row), "parquet: tbl_test v1: id: null in required column")
+
+	w = NewWriter(&buf, s, Options{})
+	row = testRows(1)[0]
+	row[3] = schema.Fixed{Unscaled: 1, Scale: 3}
+	assert.EqualError(t, w.Write(row), "parquet: tbl_test v1: amount: 0.001 has scale 3, column has 2")
+	assert.Error(t, w.Close(), "A failed writer stays failed")
+
+	w = NewWriter(&buf, s, Options{})
+	row = testRows(1)[0]
+	row[2] = 5
+	assert.EqualError(t, w.Write(row), "parquet: tbl_test v1: count: int is not a int value")
+
+	_, err := Read([]byte("id,amount\n"), s)
+	assert.ErrorIs(t, err, ErrNotParquet)
+}
+
+func TestDecimalWidth(t *testing.T) {
+	for precision, want := range map[int]int{1: 1, 2: 1, 3: 2, 9: 4, 10: 5, 12: 6, 18: 8, 19: 9, 38: 16} {
+		assert.Equal(t, want, decimalWidth(precision), "precision %d", precision)
+	}
+}
@@ -0,0 +1,373 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"claim-management-system/pipeline/schema"
+)
+
+// ErrNotParquet is returned for data that does not start and end with the
+// Parquet magic.
+var ErrNotParquet = errors.New("parquet: not a parquet file")
+
+// File is an open Parquet file.
+type File struct {
+	r    io.ReaderAt
+	meta tstruct
+	// Rows counts the file's rows.
+	Rows int64
+	// Columns are the file's columns in order.
+	Columns []Column
+	// RowGroups are the row groups in file order.
+	RowGroups []RowGroup
+	// KeyValues is the footer's key-value metadata.
+	KeyValues map[string]string
+	CreatedBy string
+}
+
+// Column describes a column of a file.
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+
+	phys     physical
+	scale    int
+	repeated bool
+}
+
+// RowGroup describes a row group of a file.
+type RowGroup struct {
+	Rows int64
+	// Codecs are the compression codecs of the column chunks, e.g. SNAPPY.
+	Codecs []string
+	// CompressedBytes and UncompressedBytes are the sizes of its column
+	// chunks.
+	CompressedBytes   int64
+	UncompressedBytes int64
+}
+
+// Open reads the footer of the size-byte file r.
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	if size < 12 {
+		return nil, ErrNotParquet
+	}
+	tail := make([]byte, 8)
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+	head := make([]byte, 4)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(tail[4:], magic) || !bytes.Equal(head, magic) {
+		return nil, ErrNotParquet
+	}
+	n := int64(binary.LittleEndian.Uint32(tail))
+	if n > size-12 {
+		return nil, ErrNotParquet
+	}
+	footer := make([]byte, n)
+	if _, err := r.ReadAt(footer, size-8-n); err != nil {
+		return nil, err
+	}
+	meta, err := (&decoder{data: footer}).strct()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{r: r, meta: meta, Rows: meta.int(3), KeyValues: map[string]string{}, CreatedBy: meta.str(6)}
+	elems := meta.list(2)
+	if len(elems) == 0 {
+		return nil, errors.New("parquet: footer has no schema")
+	}
+	for _, v := range elems[1:] {
+		el, _ := v.(tstruct)
+		if el.int(5) > 0 {
+			return nil, fmt.Errorf("parquet: nested column %s is not supported", el.str(4))
+		}
+		c := Column{
+			Name:     el.str(4),
+			Nullable: el.int(3) == optional,
+			repeated: el.int(3) == 2,
+			scale:    int(el.int(7)),
+			phys:     physical{typ: el.int(1), length: int(el.int(2)), converted: convertedNone},
+		}
+		if el.has(6) {
+			c.phys.converted = int32(el.int(6))
+		}
+		c.Type = c.phys.String()
+		f.Columns = append(f.Columns, c)
+	}
+	for _, v := range meta.list(4) {
+		rg, _ := v.(tstruct)
+		g := RowGroup{Rows: rg.int(3)}
+		for _, cv := range rg.list(1) {
+			md := cv.(tstruct).strct(3)
+			g.Codecs = append(g.Codecs, codecNames[md.int(4)])
+			g.CompressedBytes += md.int(7)
+			g.UncompressedBytes += md.int(6)
+		}
+		f.RowGroups = append(f.RowGroups, g)
+	}
+	for _, v := range meta.list(5) {
+		kv, _ := v.(tstruct)
+		f.KeyValues[kv.str(1)] = kv.str(2)
+	}
+	return f, nil
+}
+
+// Read decodes a whole file held in memory.
+func Read(data []byte, s *schema.Schema) ([][]interface{}, error) {
+	f, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return f.Read(s)
+}
+
+// Read returns the rows of the file as rows of s, typed as
+// schema.Column.Parse returns them. Columns are matched by name: columns s
+// lacks are skipped and nullable columns the file lacks are nil, so files
+// written before a compatible schema change still read.
+func (f *File) Read(s *schema.Schema) ([][]interface{}, error) {
+	index := make([]int, len(s.Columns))
+	for i, c := range s.Columns {
+		index[i] = -1
+		for j, fc := range f.Columns {
+			if fc.Name != c.Name {
+				continue
+			}
+			if want := physicalType(c); fc.phys.typ != want.typ || fc.phys.converted != want.converted ||
+				c.Type.Kind == schema.Decimal && fc.scale != c.Type.Scale {
+				return nil, fmt.Errorf("parquet: %s: column %s is stored as %s, not %s", s, c.Name, fc.Type, c.Type)
+			}
+			if fc.repeated {
+				return nil, fmt.Errorf("parquet: %s: repeated column %s is not supported", s, c.Name)
+			}
+			index[i] = j
+		}
+		if index[i] < 0 && !c.Nullable {
+			return nil, fmt.Errorf("parquet: %s: file lacks required column %s", s, c.Name)
+		}
+	}
+
+	var rows [][]interface{}
+	for g, v := range f.meta.list(4) {
+		rg, _ := v.(tstruct)
+		n := int(rg.int(3))
+		group := make([][]interface{}, n)
+		for r := range group {
+			group[r] = make([]interface{}, len(s.Columns))
+		}
+		chunks := rg.list(1)
+		for i, c := range s.Columns {
+			if index[i] < 0 {
+				continue
+			}
+			if index[i] >= len(chunks) {
+				return nil, fmt.Errorf("parquet: row group %d lacks column %s", g, c.Name)
+			}
+			values, err := f.readChunk(chunks[index[i]].(tstruct).strct(3), f.Columns[index[i]], c, n)
+			if err != nil {
+				return nil, fmt.Errorf("parquet: %s: row group %d: %s: %w", s, g, c.Name, err)
+			}
+			for r, val := range values {
+				if val == nil && !c.Nullable {
+					return nil, fmt.Errorf("parquet: %s: row group %d: %s: null in required column", s, g, c.Name)
+				}
+				group[r][i] = val
+			}
+		}
+		rows = append(rows, group...)
+	}
+	return rows, nil
+}
+
+// readChunk decodes the n values of a column chunk.
+func (f *File) readChunk(md tstruct, fc Column, c *schema.Column, n int) ([]interface{}, error) {
+	if md.has(11) {
+		return nil, errors.New("dictionary pages are not supported")
+	}
+	data := make([]byte, md.int(7))
+	if _, err := f.r.ReadAt(data, md.int(9)); err != nil {
+		return nil, err
+	}
+	codec := md.int(4)
+	if codec != codecNone && codec != codecSnappy {
+		return nil, fmt.Errorf("codec %s is not supported", codecNames[codec])
+	}
+
+	var values []interface{}
+	d := &decoder{data: data}
+	for len(values) < n && d.pos < len(data) {
+		h, err := d.strct()
+		if err != nil {
+			return nil, err
+		}
+		size := int(h.int(3))
+		if h.int(1) != pageData || size > len(data)-d.pos {
+			return nil, fmt.Errorf("unsupported or truncated page of type %d", h.int(1))
+		}
+		body := data[d.pos : d.pos+size]
+		d.pos += size
+		if codec == codecSnappy {
+			if body, err = snappy.Decode(nil, body); err != nil {
+				return nil, err
+			}
+		}
+		dph := h.strct(5)
+		if dph.int(2) != encodingPlain {
+			return nil, fmt.Errorf("encoding %d is not supported", dph.int(2))
+		}
+		page, err := decodePage(body, int(dph.int(1)), fc, c)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, page...)
+	}
+	if len(values) != n {
+		return nil, fmt.Errorf("chunk has %d values, row group has %d rows", len(values), n)
+	}
+	return values, nil
+}
+
+var errShortPage = errors.New("page is shorter than its values")
+
+// decodePage decodes the n values of a data page body.
+func decodePage(body []byte, n int, fc Column, c *schema.Column) ([]interface{}, error) {
+	present := n
+	var levels []byte
+	if fc.Nullable {
+		if len(body) < 4 {
+			return nil, errShortPage
+		}
+		size := int(binary.LittleEndian.Uint32(body))
+		if size > len(body)-4 {
+			return nil, errShortPage
+		}
+		var err error
+		if levels, err = decodeLevels(body[4:4+size], n); err != nil {
+			return nil, err
+		}
+		body = body[4+size:]
+		present = 0
+		for _, l := range levels {
+			present += int(l)
+		}
+	}
+
+	plain := make([]interface{}, present)
+	pos := 0
+	take := func(k int) ([]byte, error) {
+		if k < 0 || pos+k > len(body) {
+			return nil, errShortPage
+		}
+		pos += k
+		return body[pos-k : pos], nil
+	}
+	for i := range plain {
+		switch fc.phys.typ {
+		case typeBoolean:
+			if i/8 >= len(body) {
+				return nil, errShortPage
+			}
+			plain[i] = body[i/8]&(1<<(i%8)) != 0
+		case typeInt32:
+			b, err := take(4)
+			if err != nil {
+				return nil, err
+			}
+			days := int64(int32(binary.LittleEndian.Uint32(b)))
+			plain[i] = time.Unix(days*86400, 0).UTC()
+		case typeInt64:
+			b, err := take(8)
+			if err != nil {
+				return nil, err
+			}
+			v := int64(binary.LittleEndian.Uint64(b))
+			if c.Type.Kind == schema.Timestamp {
+				plain[i] = time.UnixMicro(v).UTC()
+			} else {
+				plain[i] = v
+			}
+		case typeFixedLenByteArray:
+			b, err := take(fc.phys.length)
+			if err != nil {
+				return nil, err
+			}
+			var v int64
+			if len(b) > 0 && b[0]&0x80 != 0 {
+				v = -1
+			}
+			for _, x := range b {
+				v = v<<8 | int64(x)
+			}
+			plain[i] = schema.Fixed{Unscaled: v, Scale: c.Type.Scale}
+		case typeByteArray:
+			b, err := take(4)
+			if err != nil {
+				return nil, err
+			}
+			s, err := take(int(binary.LittleEndian.Uint32(b)))
+			if err != nil {
+				return nil, err
+			}
+			plain[i] = string(s)
+		}
+	}
+
+	if levels == nil {
+		return plain, nil
+	}
+	values := make([]interface{}, n)
+	j := 0
+	for i, l := range levels {
+		if l == 1 {
+			values[i] = plain[j]
+			j++
+		}
+	}
+	return values, nil
+}
+
+// decodeLevels reads n definition levels of bit width 1 in the RLE/bit-packed
+// hybrid encoding.
+func decodeLevels(data []byte, n int) ([]byte, error) {
+	levels := make([]byte, 0, n)
+	d := &decoder{data: data}
+	for len(levels) < n {
+		header, err := d.uvarint()
+		if err != nil {
+			return nil, errShortPage
+		}
+		if header&1 == 1 {
+			for groups := int(header >> 1); groups > 0; groups-- {
+				b, err := d.byte()
+				if err != nil {
+					return nil, errShortPage
+				}
+				for bit := 0; bit < 8; bit++ {
+					levels = append(levels, b>>bit&1)
+				}
+			}
+			continue
+		}
+		v, err := d.byte()
+		if err != nil {
+			return nil, errShortPage
+		}
+		for run := int(header >> 1); run > 0 && len(levels) < n; run-- {
+			levels = append(levels, v&1)
+		}
+	}
+	// Bit-packed groups are padded to 8 values.
+	return levels[:n], nil
+}
@@ -0,0 +1,252 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Parquet's page headers and footer are Thrift structs in the compact
+// protocol. Only what parquet.thrift uses is implemented: integers, binary,
+// booleans, structs and lists.
+
+// Compact protocol type ids.
+const (
+	tBoolTrue  = 1
+	tBoolFalse = 2
+	tByte      = 3
+	tI16       = 4
+	tI32       = 5
+	tI64       = 6
+	tDouble    = 7
+	tBinary    = 8
+	tList      = 9
+	tSet       = 10
+	tMap       = 11
+	tStruct    = 12
+)
+
+// encoder appends compact-protocol values to buf.
+type encoder struct {
+	buf []byte
+	// last is the id of the previous field of each open struct.
+	last []int16
+}
+
+func (e *encoder) varint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (e *encoder) field(id int16, typ byte) {
+	last := &e.last[len(e.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (e *encoder) begin() { e.last = append(e.last, 0) }
+
+func (e *encoder) end() {
+	e.buf = append(e.buf, 0)
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *encoder) i32(id int16, v int32) {
+	e.field(id, tI32)
+	e.varint(zigzag(int64(v)))
+}
+
+func (e *encoder) i64(id int16, v int64) {
+	e.field(id, tI64)
+	e.varint(zigzag(v))
+}
+
+func (e *encoder) bool(id int16, v bool) {
+	if v {
+		e.field(id, tBoolTrue)
+	} else {
+		e.field(id, tBoolFalse)
+	}
+}
+
+func (e *encoder) binary(id int16, v string) {
+	e.field(id, tBinary)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// list starts a list field of n elements of typ. Struct elements are
+// written with begin and end; integer elements with listI32.
+func (e *encoder) list(id int16, typ byte, n int) {
+	e.field(id, tList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|typ)
+	} else {
+		e.buf = append(e.buf, 0xF0|typ)
+		e.varint(uint64(n))
+	}
+}
+
+func (e *encoder) listI32(v int32) { e.varint(zigzag(int64(v))) }
+
+func (e *encoder) listBinary(v string) {
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// tstruct is a decoded struct: its fields by id. Values are int64, bool,
+// []byte, tstruct or []interface{}.
+type tstruct map[int16]interface{}
+
+func (s tstruct) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s tstruct) has(id int16) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func (s tstruct) str(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s tstruct) bool(id int16) bool {
+	v, _ := s[id].(bool)
+	return v
+}
+
+func (s tstruct) strct(id int16) tstruct {
+	v, _ := s[id].(tstruct)
+	return v
+}
+
+func (s tstruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+var errTruncated = errors.New("parquet: truncated thrift struct")
+
+// decoder reads compact-protocol values from data.
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *decoder) varint() (int64, error) {
+	u, err := d.uvarint()
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errTruncated
+	}
+	d.pos++
+	return d.data[d.pos-1], nil
+}
+
+func (d *decoder) strct() (tstruct, error) {
+	s := tstruct{}
+	var last int16
+	for {
+		b, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return s, nil
+		}
+		typ := b & 0x0F
+		if delta := int16(b >> 4); delta != 0 {
+			last += delta
+		} else {
+			id, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			last = int16(id)
+		}
+		if typ == tBoolTrue || typ == tBoolFalse {
+			s[last] = typ == tBoolTrue
+			continue
+		}
+		if s[last], err = d.value(typ); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *decoder) value(typ byte) (interface{}, error) {
+	switch typ {
+	case tBoolTrue, tBoolFalse:
+		// Booleans inside lists take a byte.
+		b, err := d.byte()
+		return b == tBoolTrue, err
+	case tByte:
+		b, err := d.byte()
+		return int64(int8(b)), err
+	case tI16, tI32, tI64:
+		return d.varint()
+	case tDouble:
+		if d.pos+8 > len(d.data) {
+			return nil, errTruncated
+		}
+		d.pos += 8
+		return nil, nil
+	case tBinary:
+		n, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(d.data)-d.pos) < n {
+			return nil, errTruncated
+		}
+		d.pos += int(n)
+		return d.data[d.pos-int(n) : d.pos], nil
+	case tList, tSet:
+		b, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		n, elem := uint64(b>>4), b&0x0F
+		if n == 15 {
+			if n, err = d.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		if n > uint64(len(d.data)) {
+			return nil, errTruncated
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], err = d.value(elem); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case tStruct:
+		return d.strct()
+	}
+	return nil, fmt.Errorf("parquet: unsupported thrift type %d", typ)
+}
@@ -0,0 +1,355 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"claim-management-system/pipeline/schema"
+)
+
+// DefaultRowGroupBytes bounds the uncompressed size of a row group when
+// Options.RowGroupBytes is zero. Athena splits work by row group.
+const DefaultRowGroupBytes = 128 << 20
+
+// CreatedBy is written to the footer of every file.
+const CreatedBy = "claim-management-system pipeline"
+
+// Options control a file's layout.
+type Options struct {
+	// RowGroupRows ends a row group once it holds this many rows; zero means
+	// no limit. RowGroupBytes ends it once its values take about this many
+	// bytes before compression; zero means DefaultRowGroupBytes.
+	RowGroupRows  int
+	RowGroupBytes int64
+	// Uncompressed turns Snappy off.
+	Uncompressed bool
+}
+
+// Writer writes one Parquet file. Rows are buffered until their row group
+// is full; Close writes the last row group and the footer.
+type Writer struct {
+	w      io.Writer
+	s      *schema.Schema
+	opts   Options
+	chunks []*chunk
+
+	offset int64
+	rows   int64
+	bytes  int64
+	total  int64
+	groups []*encoder
+	err    error
+}
+
+// chunk buffers the values of one column of the current row group.
+type chunk struct {
+	col  *schema.Column
+	phys physical
+	// levels are the definition levels of a nullable column: 1 for a
+	// value, 0 for null.
+	levels []byte
+	values []byte
+	bools  []bool
+	// n counts the values, nulls included.
+	n int
+}
+
+// NewWriter returns a writer of rows of s to w.
+func NewWriter(w io.Writer, s *schema.Schema, opts Options) *Writer {
+	pw := &Writer{w: w, s: s, opts: opts}
+	for _, c := range s.Columns {
+		pw.chunks = append(pw.chunks, &chunk{col: c, phys: physicalType(c)})
+	}
+	return pw
+}
+
+// Write adds a row holding the values of the schema's columns in order.
+func (w *Writer) Write(row []interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.chunks) {
+		return fmt.Errorf("parquet: %s: row has %d values, schema has %d columns", w.s, len(row), len(w.chunks))
+	}
+	for i, c := range w.chunks {
+		n, err := c.add(row[i])
+		if err != nil {
+			// Earlier columns already took the row; the file is unusable.
+			w.err = fmt.Errorf("parquet: %s: %s: %w", w.s, c.col.Name, err)
+			return w.err
+		}
+		w.bytes += int64(n)
+	}
+	w.rows++
+	limit := w.opts.RowGroupBytes
+	if limit == 0 {
+		limit = DefaultRowGroupBytes
+	}
+	if w.opts.RowGroupRows > 0 && w.rows >= int64(w.opts.RowGroupRows) || w.bytes >= limit {
+		w.err = w.flush()
+	}
+	return w.err
+}
+
+// Close writes the buffered rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.offset == 0 {
+		if w.err = w.write(magic); w.err != nil {
+			return w.err
+		}
+	}
+	if w.rows > 0 {
+		if w.err = w.flush(); w.err != nil {
+			return w.err
+		}
+	}
+
+	e := &encoder{}
+	e.begin()
+	e.i32(1, 1)
+	e.list(2, tStruct, len(w.chunks)+1)
+	e.begin()
+	e.binary(4, "schema")
+	e.i32(5, int32(len(w.chunks)))
+	e.end()
+	for _, c := range w.chunks {
+		e.begin()
+		e.i32(1, int32(c.phys.typ))
+		if c.phys.typ == typeFixedLenByteArray {
+			e.i32(2, int32(c.phys.length))
+		}
+		if c.col.Nullable {
+			e.i32(3, optional)
+		} else {
+			e.i32(3, required)
+		}
+		e.binary(4, c.col.Name)
+		if c.phys.converted != convertedNone {
+			e.i32(6, c.phys.converted)
+		}
+		if c.phys.converted == convertedDecimal {
+			e.i32(7, int32(c.col.Type.Scale))
+			e.i32(8, int32(c.col.Type.Precision))
+		}
+		e.end()
+	}
+	e.i64(3, w.total)
+	e.list(4, tStruct, len(w.groups))
+	for _, g := range w.groups {
+		e.buf = append(e.buf, g.buf...)
+	}
+	e.list(5, tStruct, 1)
+	e.begin()
+	e.binary(1, "schema")
+	e.binary(2, w.s.String())
+	e.end()
+	e.binary(6, CreatedBy)
+	e.end()
+
+	footer := binary.LittleEndian.AppendUint32(e.buf, uint32(len(e.buf)))
+	w.err = w.write(append(footer, magic...))
+	return w.err
+}
+
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+// flush writes the buffered rows as a row group and encodes its metadata.
+func (w *Writer) flush() error {
+	if w.offset == 0 {
+		if err := w.write(magic); err != nil {
+			return err
+		}
+	}
+	codec := int32(codecSnappy)
+	if w.opts.Uncompressed {
+		codec = codecNone
+	}
+
+	g := &encoder{}
+	g.begin()
+	g.list(1, tStruct, len(w.chunks))
+	start := w.offset
+	var uncompressed int64
+	for _, c := range w.chunks {
+		body := c.page()
+		data := body
+		if codec == codecSnappy {
+			data = snappy.Encode(nil, body)
+		}
+
+		h := &encoder{}
+		h.begin()
+		h.i32(1, pageData)
+		h.i32(2, int32(len(body)))
+		h.i32(3, int32(len(data)))
+		h.field(5, tStruct)
+		h.begin()
+		h.i32(1, int32(c.n))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.end()
+
+		offset := w.offset
+		if err := w.write(h.buf); err != nil {
+			return err
+		}
+		if err := w.write(data); err != nil {
+			return err
+		}
+		size := int64(len(h.buf) + len(body))
+		uncompressed += size
+
+		g.begin()
+		g.i64(2, offset)
+		g.field(3, tStruct)
+		g.begin()
+		g.i32(1, int32(c.phys.typ))
+		g.list(2, tI32, 2)
+		g.listI32(encodingPlain)
+		g.listI32(encodingRLE)
+		g.list(3, tBinary, 1)
+		g.listBinary(c.col.Name)
+		g.i32(4, codec)
+		g.i64(5, int64(c.n))
+		g.i64(6, size)
+		g.i64(7, int64(len(h.buf)+len(data)))
+		g.i64(9, offset)
+		g.end()
+		g.end()
+		c.reset()
+	}
+	g.i64(2, uncompressed)
+	g.i64(3, w.rows)
+	g.i64(5, start)
+	g.i64(6, w.offset-start)
+	g.end()
+	w.groups = append(w.groups, g)
+
+	w.total += w.rows
+	w.rows, w.bytes = 0, 0
+	return nil
+}
+
+// add buffers v and returns about how many bytes it takes.
+func (c *chunk) add(v interface{}) (int, error) {
+	c.n++
+	if v == nil {
+		if !c.col.Nullable {
+			return 0, fmt.Errorf("null in required column")
+		}
+		c.levels = append(c.levels, 0)
+		return 1, nil
+	}
+	if c.col.Nullable {
+		c.levels = append(c.levels, 1)
+	}
+	before := len(c.values)
+	switch c.col.Type.Kind {
+	case schema.String:
+		s, ok := v.(string)
+		if !ok {
+			return 0, typeError(v, c.col)
+		}
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(s)))
+		c.values = append(c.values, s...)
+	case schema.Int:
+		n, ok := v.(int64)
+		if !ok {
+			return 0, typeError(v, c.col)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(n))
+	case schema.Decimal:
+		d, ok := v.(schema.Fixed)
+		if !ok {
+			return 0, typeError(v, c.col)
+		}
+		if d.Scale != c.col.Type.Scale {
+			return 0, fmt.Errorf("%s has scale %d, column has %d", d, d.Scale, c.col.Type.Scale)
+		}
+		for i := c.phys.length - 1; i >= 0; i-- {
+			// Bytes past the int64 repeat its sign.
+			shift := i * 8
+			if shift > 63 {
+				shift = 63
+			}
+			c.values = append(c.values, byte(d.Unscaled>>uint(shift)))
+		}
+	case schema.Date:
+		t, ok := v.(time.Time)
+		if !ok {
+			return 0, typeError(v, c.col)
+		}
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(int32(t.Unix()/86400)))
+	case schema.Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return 0, typeError(v, c.col)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(t.UnixMicro()))
+	case schema.Boolean:
+		b, ok := v.(bool)
+		if !ok {
+			return 0, typeError(v, c.col)
+		}
+		c.bools = append(c.bools, b)
+		return 1, nil
+	}
+	return len(c.values) - before, nil
+}
+
+func typeError(v interface{}, c *schema.Column) error {
+	return fmt.Errorf("%T is not a %s value", v, c.Type)
+}
+
+// page returns the uncompressed body of the chunk's data page.
+func (c *chunk) page() []byte {
+	var body []byte
+	if c.col.Nullable {
+		levels := encodeLevels(c.levels)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(levels)))
+		body = append(body, levels...)
+	}
+	if c.col.Type.Kind == schema.Boolean {
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, b := range c.bools {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append(body, packed...)
+	}
+	return append(body, c.values...)
+}
+
+func (c *chunk) reset() {
+	c.levels, c.values, c.bools, c.n = c.levels[:0], c.values[:0], c.bools[:0], 0
+}
+
+// encodeLevels writes definition levels of bit width 1 as RLE runs.
+func encodeLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,203 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Handler processes one message. Returning nil deletes the message; returning
+// an error leaves it on the queue so SQS redelivers it and, after the redrive
+// limit, moves it to the DLQ.
+type Handler interface {
+	Handle(ctx context.Context, msg Message) error
+}
+
+// HandlerFunc adapts a function to Handler.
+type HandlerFunc func(ctx context.Context, msg Message) error
+
+func (f HandlerFunc) Handle(ctx context.Context, msg Message) error { return f(ctx, msg) }
+
+// Consumer long-polls a queue and dispatches messages to a handler.
+//
+// Cancelling the context passed to Run starts a graceful shutdown: no further
+// receives are made, the message being handled may finish within
+// ShutdownTimeout, and messages of the current batch that were not started
+// are released (visibility set to zero) so another consumer picks them up at
+// once instead of after the visibility timeout.
+type Consumer struct {
+	Queue   Queue
+	Handler Handler
+	// BatchSize is the maximum number of messages per receive (SQS allows 10).
+	BatchSize int
+	// WaitTime is the long-poll duration per receive.
+	WaitTime time.Duration
+	// ShutdownTimeout bounds how long the in-flight message may keep running
+	// after shutdown starts; then its context is cancelled and, if the
+	// handler gives up, it is released. It should stay below both the queue's
+	// visibility timeout and the deadline the orchestrator allows between
+	// SIGTERM and SIGKILL.
+	ShutdownTimeout time.Duration
+	// RetryDelay is the pause after a failed receive; it doubles with each
+	// consecutive failure up to MaxRetryDelay, so an outage such as expired
+	// credentials or a deleted queue does not spin. Zero means 1s and 1m.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	Logger        *log.Logger
+
+	received  atomic.Int64
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	released  atomic.Int64
+}
+
+// Stats counts messages by outcome since the consumer was created.
+type Stats struct {
+	Received  int64
+	Succeeded int64
+	// Failed messages stay on the queue and are redelivered after their
+	// visibility timeout.
+	Failed int64
+	// Released messages were made visible again during shutdown.
+	Released int64
+}
+
+// InFlight is the number of received messages without an outcome yet.
+func (s Stats) InFlight() int64 {
+	return s.Received - s.Succeeded - s.Failed - s.Released
+}
+
+// Stats returns a snapshot of the counters. It is safe to call while Run is
+// executing.
+func (c *Consumer) Stats() Stats {
+	return Stats{
+		Received:  c.received.Load(),
+		Succeeded: c.succeeded.Load(),
+		Failed:    c.failed.Load(),
+		Released:  c.released.Load(),
+	}
+}
+
+// ackTimeout bounds deletes and releases, which run on a context detached
+// from shutdown so finished work is not redelivered.
+const ackTimeout = 5 * time.Second
+
+// Run consumes until ctx is cancelled and in-flight work is settled.
+func (c *Consumer) Run(ctx context.Context) error {
+	batch := c.BatchSize
+	if batch <= 0 {
+		batch = 10
+	}
+	wait := c.WaitTime
+	if wait <= 0 {
+		wait = 20 * time.Second
+	}
+	grace := c.ShutdownTimeout
+	if grace <= 0 {
+		grace = 20 * time.Second
+	}
+	minDelay, maxDelay := c.RetryDelay, c.MaxRetryDelay
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = time.Minute
+	}
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+
+	// Handlers run on work, which outlives ctx by at most grace.
+	work, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	go func() {
+		select {
+		case <-work.Done():
+			return
+		case <-ctx.Done():
+		}
+		t := time.NewTimer(grace)
+		defer t.Stop()
+		select {
+		case <-work.Done():
+		case <-t.C:
+			cancelWork()
+		}
+	}()
+
+	delay := time.Duration(0)
+	for ctx.Err() == nil {
+		msgs, err := c.Queue.Receive(ctx, batch, wait)
+		c.received.Add(int64(len(msgs)))
+		if err != nil && ctx.Err() == nil {
+			if delay = min(2*delay, maxDelay); delay == 0 {
+				delay = minDelay
+			}
+			c.logf("receive failed, retrying in %s: %v", delay, err)
+			c.pause(ctx, delay)
+			continue
+		}
+		delay = 0
+		for i, msg := range msgs {
+			if ctx.Err() != nil {
+				c.release(ctx, msgs[i:])
+				break
+			}
+			c.process(ctx, work, msg)
+		}
+	}
+
+	s := c.Stats()
+	c.logf("stopped: received %d, succeeded %d, failed %d, released %d", s.Received, s.Succeeded, s.Failed, s.Released)
+	return nil
+}
+
+// pause waits for d or until ctx is cancelled.
+func (c *Consumer) pause(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+func (c *Consumer) process(ctx, work context.Context, msg Message) {
+	if err := c.Handler.Handle(work, msg); err != nil {
+		if work.Err() != nil {
+			// Cut off by the shutdown deadline rather than a real failure.
+			c.release(ctx, []Message{msg})
+			return
+		}
+		c.failed.Add(1)
+		c.logf("message %s failed (receive %d): %v", msg.ID, msg.ReceiveCount, err)
+		return
+	}
+	c.succeeded.Add(1)
+
+	actx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ackTimeout)
+	defer cancel()
+	if err := c.Queue.Delete(actx, msg.ReceiptHandle); err != nil {
+		c.logf("delete %s failed: %v", msg.ID, err)
+	}
+}
+
+// release makes msgs visible again. A message that cannot be released is
+// still counted: it reappears when its visibility timeout expires.
+func (c *Consumer) release(ctx context.Context, msgs []Message) {
+	actx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ackTimeout)
+	defer cancel()
+	for _, msg := range msgs {
+		c.released.Add(1)
+		if err := c.Queue.ChangeVisibility(actx, msg.ReceiptHandle, 0); err != nil {
+			c.logf("release %s failed: %v", msg.ID, err)
+		}
+	}
+}
+
+func (c *Consumer) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
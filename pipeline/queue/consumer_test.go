@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumerDeletesHandledMessages(t *testing.T) {
+	q := NewFake()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, q.Send(ctx, "ok", nil))
+	require.NoError(t, q.Send(ctx, "fail", nil))
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	c := &Consumer{
+		Queue: q,
+		Handler: HandlerFunc(func(_ context.Context, msg Message) error {
+			mu.Lock()
+			seen[msg.Body]++
+			mu.Unlock()
+			if msg.Body == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		}),
+		WaitTime: 10 * time.Millisecond,
+	}
+
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return seen["ok"] == 1 && seen["fail"] == 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, 1, q.Len(), "Failed message should stay on the queue")
+	assert.Equal(t, 0, q.Visible(), "Failed message should stay hidden until its visibility timeout")
+	assert.Equal(t, Stats{Received: 2, Succeeded: 1, Failed: 1}, c.Stats())
+}
+
+// blockingConsumer returns a consumer over a queue holding three messages
+// whose handler signals started and then waits for proceed or its context.
+func blockingConsumer(t *testing.T, grace time.Duration) (*Consumer, *Fake, chan string, chan struct{}) {
+	t.Helper()
+	q := NewFake()
+	for _, body := range []string{"m1", "m2", "m3"} {
+		require.NoError(t, q.Send(context.Background(), body, nil))
+	}
+	started := make(chan string, 3)
+	proceed := make(chan struct{})
+	c := &Consumer{
+		Queue: q,
+		Handler: HandlerFunc(func(ctx context.Context, msg Message) error {
+			started <- msg.Body
+			select {
+			case <-proceed:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}),
+		WaitTime:        10 * time.Millisecond,
+		ShutdownTimeout: grace,
+	}
+	return c, q, started, proceed
+}
+
+func TestConsumerShutdownFinishesInFlightAndReleasesRest(t *testing.T) {
+	c, q, started, proceed := blockingConsumer(t, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	assert.Equal(t, "m1", <-started)
+	cancel() // SIGTERM while m1 is being handled.
+	assert.Equal(t, int64(3), c.Stats().InFlight(), "m1 is in progress and m2, m3 are waiting")
+	close(proceed)
+	require.NoError(t, <-done)
+
+	assert.Len(t, started, 0, "No message should start after shutdown")
+	assert.Equal(t, Stats{Received: 3, Succeeded: 1, Released: 2}, c.Stats())
+	assert.Equal(t, 2, q.Len(), "m1 should be deleted")
+	assert.Equal(t, 2, q.Visible(), "Unstarted messages should be visible again at once")
+}
+
+func TestConsumerShutdownDeadlineReleasesInFlight(t *testing.T) {
+	c, q, started, _ := blockingConsumer(t, 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run should return once the shutdown deadline passes")
+	}
+
+	s := c.Stats()
+	assert.Equal(t, Stats{Received: 3, Released: 3}, s)
+	assert.Zero(t, s.InFlight())
+	assert.Equal(t, 3, q.Visible(), "A message cut off by the deadline should be released, not left hidden")
+
+	msgs, err := q.Receive(context.Background(), 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, msgs, 3)
+}
+
+// failingQueue fails every receive while failing is set.
+type failingQueue struct {
+	*Fake
+	failing  atomic.Bool
+	receives atomic.Int64
+}
+
+func (q *failingQueue) Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error) {
+	q.receives.Add(1)
+	if q.failing.Load() {
+		return nil, errors.New("ExpiredToken: the security token included in the request is expired")
+	}
+	return q.Fake.Receive(ctx, max, wait)
+}
+
+func TestConsumerBacksOffFailedReceives(t *testing.T) {
+	q := &failingQueue{Fake: NewFake()}
+	q.failing.Store(true)
+	require.NoError(t, q.Send(context.Background(), "m1", nil))
+	handled := make(chan string, 1)
+	c := &Consumer{
+		Queue:         q,
+		Handler:       HandlerFunc(func(_ context.Context, msg Message) error { handled <- msg.Body; return nil }),
+		WaitTime:      time.Millisecond,
+		RetryDelay:    10 * time.Millisecond,
+		MaxRetryDelay: 40 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	time.Sleep(200 * time.Millisecond)
+	n := q.receives.Load()
+	assert.GreaterOrEqual(t, n, int64(3))
+	assert.LessOrEqual(t, n, int64(10), "Receives wait 10, 20, then 40ms apart")
+
+	q.failing.Store(false)
+	select {
+	case body := <-handled:
+		assert.Equal(t, "m1", body)
+	case <-time.After(time.Second):
+		t.Fatal("The consumer should resume once receives succeed")
+	}
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestConsumerShutdownInterruptsBackoff(t *testing.T) {
+	q := &failingQueue{Fake: NewFake()}
+	q.failing.Store(true)
+	c := &Consumer{Queue: q, Handler: HandlerFunc(func(context.Context, Message) error { return nil }), RetryDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return q.receives.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run should return during a retry delay once ctx is cancelled")
+	}
+	assert.Equal(t, int64(1), q.receives.Load())
+}
+
+func TestFakeDeadLettersAfterMaxReceives(t *testing.T) {
+	q := &Fake{VisibilityTimeout: 0, MaxReceiveCount: 2}
+	ctx := context.Background()
+	require.NoError(t, q.Send(ctx, "poison", map[string]string{"k": "v"}))
+
+	for i := 1; i <= 2; i++ {
+		msgs, err := q.Receive(ctx, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, i, msgs[0].ReceiveCount)
+		assert.Equal(t, "v", msgs[0].Attributes["k"])
+	}
+
+	msgs, err := q.Receive(ctx, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+	require.Len(t, q.DeadLetters(), 1, "Message should be dead-lettered after MaxReceiveCount")
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestFakeChangeVisibility(t *testing.T) {
+	q := NewFake()
+	ctx := context.Background()
+	require.NoError(t, q.Send(ctx, "m", nil))
+
+	msgs, err := q.Receive(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, 0, q.Visible())
+
+	require.NoError(t, q.ChangeVisibility(ctx, msgs[0].ReceiptHandle, 0))
+	assert.Equal(t, 1, q.Visible(), "Zero visibility should release the message")
+
+	assert.ErrorIs(t, q.Delete(ctx, msgs[0].ReceiptHandle+"x"), ErrUnknownReceipt)
+}
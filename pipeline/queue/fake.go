@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrUnknownReceipt is returned by the fake queue for stale receipt handles.
+var ErrUnknownReceipt = errors.New("queue: unknown receipt handle")
+
+// Fake is an in-memory Queue with SQS visibility semantics: received messages
+// stay hidden for VisibilityTimeout and reappear unless deleted. After
+// MaxReceiveCount receives a message moves to DeadLetters, mirroring the
+// redrive policy on claim-<env>-s3-events.
+type Fake struct {
+	VisibilityTimeout time.Duration
+	MaxReceiveCount   int
+
+	mu          sync.Mutex
+	seq         int
+	messages    []*fakeMessage
+	deadLetters []Message
+}
+
+type fakeMessage struct {
+	msg       Message
+	visibleAt time.Time
+	receipt   string
+}
+
+// NewFake returns a Fake matching the dev queue settings (30s visibility,
+// three receives before dead-lettering).
+func NewFake() *Fake {
+	return &Fake{VisibilityTimeout: 30 * time.Second, MaxReceiveCount: 3}
+}
+
+func (f *Fake) Send(_ context.Context, body string, attrs map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	copied := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		copied[k] = v
+	}
+	f.messages = append(f.messages, &fakeMessage{
+		msg: Message{ID: fmt.Sprintf("msg-%d", f.seq), Body: body, Attributes: copied},
+	})
+	return nil
+}
+
+func (f *Fake) Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		if msgs := f.receiveVisible(max); len(msgs) > 0 {
+			return msgs, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func (f *Fake) receiveVisible(max int) []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	var out []Message
+	kept := f.messages[:0]
+	for _, m := range f.messages {
+		if len(out) >= max || now.Before(m.visibleAt) {
+			kept = append(kept, m)
+			continue
+		}
+		if f.MaxReceiveCount > 0 && m.msg.ReceiveCount >= f.MaxReceiveCount {
+			f.deadLetters = append(f.deadLetters, m.msg)
+			continue
+		}
+		f.seq++
+		m.msg.ReceiveCount++
+		m.receipt = fmt.Sprintf("rh-%d", f.seq)
+		m.visibleAt = now.Add(f.VisibilityTimeout)
+		kept = append(kept, m)
+
+		msg := m.msg
+		msg.ReceiptHandle = m.receipt
+		out = append(out, msg)
+	}
+	f.messages = kept
+	return out
+}
+
+func (f *Fake) Delete(_ context.Context, receiptHandle string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, m := range f.messages {
+		if m.receipt == receiptHandle {
+			f.messages = append(f.messages[:i], f.messages[i+1:]...)
+			return nil
+		}
+	}
+	return ErrUnknownReceipt
+}
+
+func (f *Fake) ChangeVisibility(_ context.Context, receiptHandle string, timeout time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, m := range f.messages {
+		if m.receipt == receiptHandle {
+			m.visibleAt = time.Now().Add(timeout)
+			return nil
+		}
+	}
+	return ErrUnknownReceipt
+}
+
+// Len reports how many messages are still on the queue, visible or not.
+func (f *Fake) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.messages)
+}
+
+// Visible reports how many messages could be received right now.
+func (f *Fake) Visible() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for _, m := range f.messages {
+		if !now.Before(m.visibleAt) {
+			n++
+		}
+	}
+	return n
+}
+
+// DeadLetters returns the messages that exceeded MaxReceiveCount.
+func (f *Fake) DeadLetters() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.deadLetters...)
+}
@@ -0,0 +1,30 @@
+// Package queue wraps the SQS queue that carries raw-bucket notifications and
+// provides the consumer loop the pipeline workers run.
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a received queue message.
+type Message struct {
+	ID            string
+	ReceiptHandle string
+	Body          string
+	Attributes    map[string]string
+	// ReceiveCount is the approximate number of times the message has been
+	// received, including this time.
+	ReceiveCount int
+}
+
+// Queue is the subset of SQS the pipeline uses.
+type Queue interface {
+	// Receive long-polls for up to max messages, waiting at most wait.
+	Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error)
+	Delete(ctx context.Context, receiptHandle string) error
+	// ChangeVisibility sets how long the message stays hidden from other
+	// consumers. A zero timeout makes it visible immediately.
+	ChangeVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error
+	Send(ctx context.Context, body string, attrs map[string]string) error
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// SQSQueue is the Queue backed by Amazon SQS.
+type SQSQueue struct {
+	client sqsiface.SQSAPI
+	url    string
+}
+
+// NewSQSQueue returns a Queue for the queue at url.
+func NewSQSQueue(client sqsiface.SQSAPI, url string) *SQSQueue {
+	return &SQSQueue{client: client, url: url}
+}
+
+func (q *SQSQueue) Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error) {
+	out, err := q.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(q.url),
+		MaxNumberOfMessages:   aws.Int64(int64(max)),
+		WaitTimeSeconds:       aws.Int64(int64(wait / time.Second)),
+		AttributeNames:        []*string{aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount)},
+		MessageAttributeNames: []*string{aws.String("All")},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("receive from %s: %w", q.url, err)
+	}
+
+	msgs := make([]Message, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		attrs := make(map[string]string, len(m.MessageAttributes))
+		for k, v := range m.MessageAttributes {
+			attrs[k] = aws.StringValue(v.StringValue)
+		}
+		count, _ := strconv.Atoi(aws.StringValue(m.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+		msgs = append(msgs, Message{
+			ID:            aws.StringValue(m.MessageId),
+			ReceiptHandle: aws.StringValue(m.ReceiptHandle),
+			Body:          aws.StringValue(m.Body),
+			Attributes:    attrs,
+			ReceiveCount:  count,
+		})
+	}
+	return msgs, nil
+}
+
+func (q *SQSQueue) Delete(ctx context.Context, receiptHandle string) error {
+	_, err := q.client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: aws.String(receiptHandle),
+	})
+	if err != nil {
+		return fmt.Errorf("delete from %s: %w", q.url, err)
+	}
+	return nil
+}
+
+func (q *SQSQueue) ChangeVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	_, err := q.client.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.url),
+		ReceiptHandle:     aws.String(receiptHandle),
+		VisibilityTimeout: aws.Int64(int64(timeout / time.Second)),
+	})
+	if err != nil {
+		return fmt.Errorf("change visibility on %s: %w", q.url, err)
+	}
+	return nil
+}
+
+func (q *SQSQueue) Send(ctx context.Context, body string, attrs map[string]string) error {
+	in := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.url),
+		MessageBody: aws.String(body),
+	}
+	if len(attrs) > 0 {
+		in.MessageAttributes = make(map[string]*sqs.MessageAttributeValue, len(attrs))
+		for k, v := range attrs {
+			in.MessageAttributes[k] = &sqs.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(v),
+			}
+		}
+	}
+
+	if _, err := q.client.SendMessageWithContext(ctx, in); err != nil {
+		return fmt.Errorf("send to %s: %w", q.url, err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package redshift
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice"
+)
+
+// Fake is an HTTP stand-in for the part of the Redshift Data API the Loader
+// uses: ExecuteStatement, DescribeStatement, GetStatementResult and
+// CancelStatement, in the API's JSON protocol and with its error codes. A
+// client reaches it with its URL as endpoint. Other operations fail.
+type Fake struct {
+	// Exec decides how each statement ends; nil finishes every statement
+	// without rows.
+	Exec func(sql string) FakeResult
+
+	mu         sync.Mutex
+	statements []*FakeStatement
+}
+
+// FakeResult is how a statement ends.
+type FakeResult struct {
+	// Pending is how many times DescribeStatement reports the statement
+	// STARTED before it ends.
+	Pending int
+	// Error, if set, fails the statement with Redshift's message.
+	Error string
+	// Rows is the ResultRows reported.
+	Rows int64
+	// Count, if set, is the statement's one-value result set, as of
+	// SELECT COUNT(*).
+	Count *int64
+}
+
+// FakeStatement is a statement the fake was asked to execute.
+type FakeStatement struct {
+	ID, SQL, Database       string
+	ClusterIdentifier       string
+	WorkgroupName           string
+	DbUser, SecretArn, Name string
+	Status                  string
+	result                  FakeResult
+}
+
+// NewFake returns a Fake without statements.
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Statements returns the statements executed so far, in order.
+func (f *Fake) Statements() []FakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]FakeStatement, len(f.statements))
+	for i, st := range f.statements {
+		out[i] = *st
+	}
+	return out
+}
+
+// SQL returns the SQL of the statements executed so far, in order.
+func (f *Fake) SQL() []string {
+	var out []string
+	for _, st := range f.Statements() {
+		out = append(out, st.SQL)
+	}
+	return out
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var in map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		fakeError(w, "SerializationException", err.Error())
+		return
+	}
+	str := func(name string) string {
+		s, _ := in[name].(string)
+		return s
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "RedshiftData."); op {
+	case "ExecuteStatement":
+		st := &FakeStatement{
+			ID:  fmt.Sprintf("00000000-0000-0000-0000-%012d", len(f.statements)+1),
+			SQL: str("Sql"), Database: str("Database"),
+			ClusterIdentifier: str("ClusterIdentifier"), WorkgroupName: str("WorkgroupName"),
+			DbUser: str("DbUser"), SecretArn: str("SecretArn"), Name: str("StatementName"),
+			Status: redshiftdataapiservice.StatusStringSubmitted,
+		}
+		switch {
+		case st.SQL == "" || st.Database == "":
+			fakeError(w, redshiftdataapiservice.ErrCodeValidationException, "Sql and Database are required.")
+			return
+		case (st.ClusterIdentifier == "") == (st.WorkgroupName == ""):
+			fakeError(w, redshiftdataapiservice.ErrCodeValidationException, "Either ClusterIdentifier or WorkgroupName must be specified.")
+			return
+		}
+		if f.Exec != nil {
+			st.result = f.Exec(st.SQL)
+		}
+		f.statements = append(f.statements, st)
+		fakeReply(w, map[string]interface{}{"Id": st.ID, "Database": st.Database})
+
+	case "DescribeStatement":
+		st := f.statement(w, str("Id"))
+		if st == nil {
+			return
+		}
+		switch {
+		case st.Status == redshiftdataapiservice.StatusStringAborted:
+		case st.result.Pending > 0:
+			st.result.Pending--
+			st.Status = redshiftdataapiservice.StatusStringStarted
+		case st.result.Error != "":
+			st.Status = redshiftdataapiservice.StatusStringFailed
+		default:
+			st.Status = redshiftdataapiservice.StatusStringFinished
+		}
+		out := map[string]interface{}{
+			"Id": st.ID, "Status": st.Status, "QueryString": st.SQL, "Database": st.Database,
+			"HasResultSet": st.result.Count != nil, "ResultRows": st.result.Rows,
+		}
+		if st.Status == redshiftdataapiservice.StatusStringFailed {
+			out["Error"] = st.result.Error
+		}
+		fakeReply(w, out)
+
+	case "GetStatementResult":
+		st := f.statement(w, str("Id"))
+		switch {
+		case st == nil:
+		case st.Status != redshiftdataapiservice.StatusStringFinished || st.result.Count == nil:
+			fakeError(w, redshiftdataapiservice.ErrCodeValidationException, "Query does not have result. Please check query status with DescribeStatement.")
+		default:
+			fakeReply(w, map[string]interface{}{
+				"Records":        [][]map[string]interface{}{{{"longValue": *st.result.Count}}},
+				"ColumnMetadata": []map[string]interface{}{{"name": "count", "typeName": "int8"}},
+				"TotalNumRows":   1,
+			})
+		}
+
+	case "CancelStatement":
+		st := f.statement(w, str("Id"))
+		if st == nil {
+			return
+		}
+		ok := st.Status == redshiftdataapiservice.StatusStringSubmitted || st.Status == redshiftdataapiservice.StatusStringStarted
+		if ok {
+			st.Status = redshiftdataapiservice.StatusStringAborted
+		}
+		fakeReply(w, map[string]interface{}{"Status": ok})
+
+	default:
+		fakeError(w, "UnknownOperationException", "operation "+op+" is not supported by the fake")
+	}
+}
+
+// statement returns the statement id, or writes the error the API returns
+// for an unknown one.
+func (f *Fake) statement(w http.ResponseWriter, id string) *FakeStatement {
+	for _, st := range f.statements {
+		if st.ID == id {
+			return st
+		}
+	}
+	fakeError(w, redshiftdataapiservice.ErrCodeResourceNotFoundException, "Query does not exist.")
+	return nil
+}
+
+func fakeReply(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(body)
+}
+
+func fakeError(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": message})
+}
@@ -0,0 +1,318 @@
+// Package redshift loads the gold tables into Redshift through the Redshift
+// Data API. For each table of a gold run it creates the target and a
+// staging table if missing, COPYs the table's Parquet files into staging,
+// MERGEs staging into the target on the surrogate key, deletes the target
+// rows gold no longer has and checks the target's row count against the
+// run's manifest. Each statement is polled until it finishes; a failed one
+// stops the load with its SQL and Redshift's error.
+package redshift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice/redshiftdataapiserviceiface"
+
+	"claim-management-system/pipeline/gold"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver"
+)
+
+// DefaultSchema is the Redshift schema the gold tables are loaded into.
+const DefaultSchema = "gold"
+
+// StagingPrefix prefixes the name of a table's staging table.
+const StagingPrefix = "stg_"
+
+// DefaultPollInterval is how often a running statement is described.
+const DefaultPollInterval = time.Second
+
+// Loader loads gold runs into a Redshift database. Loads must not overlap:
+// they share the staging tables.
+type Loader struct {
+	API redshiftdataapiserviceiface.RedshiftDataAPIServiceAPI
+	// ClusterIdentifier names a provisioned cluster, WorkgroupName a
+	// serverless workgroup; exactly one is set.
+	ClusterIdentifier string
+	WorkgroupName     string
+	Database          string
+	// DbUser (provisioned only) or SecretArn authenticate the statements;
+	// with neither, a serverless workgroup uses the caller's IAM identity.
+	DbUser    string
+	SecretArn string
+	// Schema is the Redshift schema of the targets; empty means
+	// DefaultSchema. It must exist.
+	Schema  string
+	Schemas *schema.Registry
+	// Bucket is the lake bucket holding the gold files.
+	Bucket string
+	// IAMRole is the ARN of the role Redshift assumes to read them.
+	IAMRole string
+	// PollInterval is how often statements are described; zero means
+	// DefaultPollInterval.
+	PollInterval time.Duration
+
+	Logger *log.Logger
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Report records what one load did.
+type Report struct {
+	GoldRun    string       `json:"gold_run"`
+	StartedAt  string       `json:"started_at"`
+	FinishedAt string       `json:"finished_at"`
+	Tables     []*TableLoad `json:"tables"`
+}
+
+// TableLoad is the load of one gold table.
+type TableLoad struct {
+	Table   string `json:"table"`
+	Target  string `json:"target"`
+	Staging string `json:"staging"`
+	// Source is the s3:// prefix copied.
+	Source string `json:"source"`
+	// Copied counts the rows COPY loaded into staging, Merged those MERGE
+	// updated or inserted and Deleted the target rows gold no longer has.
+	// Rows is the target's row count after the load, equal to the gold
+	// manifest's.
+	Copied  int64 `json:"copied"`
+	Merged  int64 `json:"merged"`
+	Deleted int64 `json:"deleted"`
+	Rows    int64 `json:"rows"`
+}
+
+// StatementError is a statement that failed or was aborted.
+type StatementError struct {
+	Table string
+	// Step is what the statement was for, e.g. copy or merge.
+	Step   string
+	ID     string
+	Status string
+	SQL    string
+	// Message is Redshift's error.
+	Message string
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("redshift: %s %s: statement %s %s: %s\n%s", e.Table, e.Step, e.ID, strings.ToLower(e.Status), e.Message, e.SQL)
+}
+
+// Load loads every table of gold run m, in the run's order: dimensions
+// before facts. Any error stops the load; tables loaded before it stay.
+func (l *Loader) Load(ctx context.Context, m *gold.Manifest) (*Report, error) {
+	if m.Format != (silver.Parquet{}).Name() {
+		return nil, fmt.Errorf("redshift: gold run %s is %s; COPY needs parquet", m.RunID, m.Format)
+	}
+	if (l.ClusterIdentifier == "") == (l.WorkgroupName == "") {
+		return nil, errors.New("redshift: set exactly one of the cluster identifier and the workgroup name")
+	}
+	rep := &Report{GoldRun: m.RunID, StartedAt: metadata.FormatTime(l.now())}
+	for _, res := range m.Tables {
+		s, err := l.Schemas.Get(res.Table, res.Version)
+		if err != nil {
+			return nil, err
+		}
+		t, err := l.loadTable(ctx, s, res.Rows)
+		if err != nil {
+			return nil, err
+		}
+		rep.Tables = append(rep.Tables, t)
+	}
+	rep.FinishedAt = metadata.FormatTime(l.now())
+	return rep, nil
+}
+
+// loadTable loads gold table s, which the run wrote rows of.
+func (l *Loader) loadTable(ctx context.Context, s *schema.Schema, rows int64) (*TableLoad, error) {
+	t := &TableLoad{
+		Table:   s.Name,
+		Target:  l.schema() + "." + s.Name,
+		Staging: l.schema() + "." + StagingPrefix + s.Name,
+		Source:  fmt.Sprintf("s3://%s/%s", l.Bucket, s.Location),
+	}
+	key := s.Key[0]
+	names := s.ColumnNames()
+	set := make([]string, 0, len(names))
+	values := make([]string, len(names))
+	for i, name := range names {
+		if name != key {
+			set = append(set, fmt.Sprintf("%s = s.%s", name, name))
+		}
+		values[i] = "s." + name
+	}
+
+	for _, st := range []struct {
+		step, sql string
+		rows      *int64
+	}{
+		{step: "create", sql: createTable(t.Target, s)},
+		{step: "create staging", sql: fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s)", t.Staging, t.Target)},
+		{step: "truncate staging", sql: "TRUNCATE " + t.Staging},
+		{step: "copy", rows: &t.Copied, sql: fmt.Sprintf("COPY %s FROM %s IAM_ROLE %s FORMAT AS PARQUET", t.Staging, quote(t.Source), quote(l.IAMRole))},
+		{step: "merge", rows: &t.Merged, sql: fmt.Sprintf(
+			"MERGE INTO %s USING %s AS s ON %s.%s = s.%s WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+			t.Target, t.Staging, t.Target, key, key, strings.Join(set, ", "), strings.Join(names, ", "), strings.Join(values, ", "))},
+		{step: "delete", rows: &t.Deleted, sql: fmt.Sprintf("DELETE FROM %s WHERE %s NOT IN (SELECT %s FROM %s)", t.Target, key, key, t.Staging)},
+	} {
+		d, err := l.run(ctx, s.Name, st.step, st.sql)
+		if err != nil {
+			return nil, err
+		}
+		if st.rows != nil {
+			*st.rows = aws.Int64Value(d.ResultRows)
+		}
+	}
+
+	count, err := l.count(ctx, s.Name, t.Target)
+	if err != nil {
+		return nil, err
+	}
+	t.Rows = count
+	if count != rows {
+		return nil, fmt.Errorf("redshift: %s has %d rows after the load but gold has %d", t.Target, count, rows)
+	}
+	l.logf("%s: copied %d, merged %d, deleted %d", t.Target, t.Copied, t.Merged, t.Deleted)
+	return t, nil
+}
+
+// count returns the row count of table.
+func (l *Loader) count(ctx context.Context, table, target string) (int64, error) {
+	sql := "SELECT COUNT(*) FROM " + target
+	d, err := l.run(ctx, table, "count", sql)
+	if err != nil {
+		return 0, err
+	}
+	out, err := l.API.GetStatementResultWithContext(ctx, &redshiftdataapiservice.GetStatementResultInput{Id: d.Id})
+	if err != nil {
+		return 0, fmt.Errorf("redshift: %s count: result of statement %s: %w", table, aws.StringValue(d.Id), err)
+	}
+	if len(out.Records) != 1 || len(out.Records[0]) != 1 || out.Records[0][0].LongValue == nil {
+		return 0, fmt.Errorf("redshift: %s count: statement %s returned no count", table, aws.StringValue(d.Id))
+	}
+	return *out.Records[0][0].LongValue, nil
+}
+
+// run executes sql and waits for it to finish. If ctx ends first, the
+// statement is cancelled.
+func (l *Loader) run(ctx context.Context, table, step, sql string) (*redshiftdataapiservice.DescribeStatementOutput, error) {
+	in := &redshiftdataapiservice.ExecuteStatementInput{
+		Database:      aws.String(l.Database),
+		Sql:           aws.String(sql),
+		StatementName: aws.String("gold-load-" + table),
+	}
+	if l.ClusterIdentifier != "" {
+		in.ClusterIdentifier = aws.String(l.ClusterIdentifier)
+	} else {
+		in.WorkgroupName = aws.String(l.WorkgroupName)
+	}
+	if l.DbUser != "" {
+		in.DbUser = aws.String(l.DbUser)
+	}
+	if l.SecretArn != "" {
+		in.SecretArn = aws.String(l.SecretArn)
+	}
+	out, err := l.API.ExecuteStatementWithContext(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("redshift: %s %s: execute: %w\n%s", table, step, err, sql)
+	}
+	id := aws.StringValue(out.Id)
+
+	interval := l.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		d, err := l.API.DescribeStatementWithContext(ctx, &redshiftdataapiservice.DescribeStatementInput{Id: out.Id})
+		if ctx.Err() != nil {
+			return nil, l.cancel(ctx, table, step, id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redshift: %s %s: describe statement %s: %w", table, step, id, err)
+		}
+		switch status := aws.StringValue(d.Status); status {
+		case redshiftdataapiservice.StatusStringFinished:
+			return d, nil
+		case redshiftdataapiservice.StatusStringFailed, redshiftdataapiservice.StatusStringAborted:
+			return nil, &StatementError{Table: table, Step: step, ID: id, Status: status, SQL: sql, Message: aws.StringValue(d.Error)}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, l.cancel(ctx, table, step, id)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// cancel cancels statement id, which would run on after ctx is done, and
+// returns ctx's error. The cancellation uses a fresh context.
+func (l *Loader) cancel(ctx context.Context, table, step, id string) error {
+	_, err := l.API.CancelStatementWithContext(context.Background(), &redshiftdataapiservice.CancelStatementInput{Id: aws.String(id)})
+	if err != nil {
+		err = fmt.Errorf("redshift: %s %s: cancel statement %s: %w", table, step, id, err)
+	}
+	return errors.Join(fmt.Errorf("redshift: %s %s: statement %s: %w", table, step, id, ctx.Err()), err)
+}
+
+// createTable returns the DDL creating the Redshift table of s, its
+// partition columns left out as they are of the Parquet files.
+func createTable(target string, s *schema.Schema) string {
+	cols := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		cols[i] = c.Name + " " + columnType(c.Type)
+		if !c.Nullable {
+			cols[i] += " NOT NULL"
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, PRIMARY KEY (%s))", target, strings.Join(cols, ", "), s.Key[0])
+}
+
+// columnType returns the Redshift type of t.
+func columnType(t schema.Type) string {
+	switch t.Kind {
+	case schema.Int:
+		return "BIGINT"
+	case schema.Decimal:
+		return "DECIMAL(" + strconv.Itoa(t.Precision) + "," + strconv.Itoa(t.Scale) + ")"
+	case schema.Date:
+		return "DATE"
+	case schema.Timestamp:
+		return "TIMESTAMP"
+	case schema.Boolean:
+		return "BOOLEAN"
+	}
+	return "VARCHAR(65535)"
+}
+
+// quote returns s as an SQL string literal.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (l *Loader) schema() string {
+	if l.Schema == "" {
+		return DefaultSchema
+	}
+	return l.Schema
+}
+
+func (l *Loader) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+func (l *Loader) logf(format string, args ...interface{}) {
+	if l.Logger != nil {
+		l.Logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,205 @@
+package redshift
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/gold"
+	"claim-management-system/pipeline/schema"
+)
+
+// newLoader returns a Loader for a serverless workgroup whose Data API is
+// fake, reached over HTTP by the SDK client.
+func newLoader(t *testing.T, fake *Fake) *Loader {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	require.NoError(t, err)
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	return &Loader{
+		API:           redshiftdataapiservice.New(sess),
+		WorkgroupName: "claim-dev",
+		Database:      "claims",
+		Schemas:       r,
+		Bucket:        "claim-dev-lake",
+		IAMRole:       "arn:aws:iam::123456789012:role/role-claim-redshift",
+		PollInterval:  time.Millisecond,
+		Now:           func() time.Time { return time.Date(2025, 12, 3, 7, 0, 0, 0, time.UTC) },
+	}
+}
+
+func manifest() *gold.Manifest {
+	return &gold.Manifest{RunID: "gold-20251203T060000Z", Format: "parquet", Tables: []*gold.TableResult{
+		{Table: "dim_plan", Version: 1, Rows: 3},
+		{Table: "fact_payment", Version: 1, Rows: 2},
+	}}
+}
+
+// counts makes the fake report rows for each step, as a load of a table
+// with rows that replaces stale target rows would.
+func counts(rows map[string]int64, stale int64) func(string) FakeResult {
+	return func(sql string) FakeResult {
+		for table, n := range rows {
+			switch {
+			case strings.HasPrefix(sql, "COPY gold.stg_"+table+" "):
+				// Large copies take a few polls.
+				return FakeResult{Rows: n, Pending: 2}
+			case strings.HasPrefix(sql, "MERGE INTO gold."+table+" "):
+				return FakeResult{Rows: n}
+			case strings.HasPrefix(sql, "DELETE FROM gold."+table+" "):
+				return FakeResult{Rows: stale}
+			case sql == "SELECT COUNT(*) FROM gold."+table:
+				return FakeResult{Rows: 1, Count: aws.Int64(n)}
+			}
+		}
+		return FakeResult{}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fake := NewFake()
+	fake.Exec = counts(map[string]int64{"dim_plan": 3, "fact_payment": 2}, 1)
+	l := newLoader(t, fake)
+
+	rep, err := l.Load(context.Background(), manifest())
+	require.NoError(t, err)
+	assert.Equal(t, &Report{
+		GoldRun: "gold-20251203T060000Z", StartedAt: "2025-12-03T07:00:00.000Z", FinishedAt: "2025-12-03T07:00:00.000Z",
+		Tables: []*TableLoad{
+			{Table: "dim_plan", Target: "gold.dim_plan", Staging: "gold.stg_dim_plan", Source: "s3://claim-dev-lake/gold/dim_plan/",
+				Copied: 3, Merged: 3, Deleted: 1, Rows: 3},
+			{Table: "fact_payment", Target: "gold.fact_payment", Staging: "gold.stg_fact_payment", Source: "s3://claim-dev-lake/gold/fact_payment/",
+				Copied: 2, Merged: 2, Deleted: 1, Rows: 2},
+		},
+	}, rep)
+
+	sql := fake.SQL()
+	require.Len(t, sql, 14)
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS gold.dim_plan (plan_key BIGINT NOT NULL, plan_code VARCHAR(65535) NOT NULL, " +
+			"insurance_line VARCHAR(65535), first_coverage_start DATE, PRIMARY KEY (plan_key))",
+		"CREATE TABLE IF NOT EXISTS gold.stg_dim_plan (LIKE gold.dim_plan)",
+		"TRUNCATE gold.stg_dim_plan",
+		"COPY gold.stg_dim_plan FROM 's3://claim-dev-lake/gold/dim_plan/' IAM_ROLE 'arn:aws:iam::123456789012:role/role-claim-redshift' FORMAT AS PARQUET",
+		"MERGE INTO gold.dim_plan USING gold.stg_dim_plan AS s ON gold.dim_plan.plan_key = s.plan_key " +
+			"WHEN MATCHED THEN UPDATE SET plan_code = s.plan_code, insurance_line = s.insurance_line, first_coverage_start = s.first_coverage_start " +
+			"WHEN NOT MATCHED THEN INSERT (plan_key, plan_code, insurance_line, first_coverage_start) " +
+			"VALUES (s.plan_key, s.plan_code, s.insurance_line, s.first_coverage_start)",
+		"DELETE FROM gold.dim_plan WHERE plan_key NOT IN (SELECT plan_key FROM gold.stg_dim_plan)",
+		"SELECT COUNT(*) FROM gold.dim_plan",
+	}, sql[:7])
+	assert.Contains(t, sql[7], "allowed_amount DECIMAL(12,2) NOT NULL, paid_amount DECIMAL(12,2) NOT NULL, patient_responsibility DECIMAL(12,2),")
+	assert.Contains(t, sql[7], "payment_date DATE NOT NULL,")
+	assert.Contains(t, sql[7], "ingested_at TIMESTAMP NOT NULL, PRIMARY KEY (payment_key))")
+	assert.NotContains(t, sql[7], "year", "Partition columns are not in the Parquet files")
+
+	for _, st := range fake.Statements() {
+		assert.Equal(t, "claim-dev", st.WorkgroupName)
+		assert.Equal(t, "claims", st.Database)
+		assert.Equal(t, redshiftdataapiservice.StatusStringFinished, st.Status, "Every statement is polled to completion: %s", st.SQL)
+	}
+	assert.Equal(t, "gold-load-fact_payment", fake.Statements()[13].Name)
+}
+
+func TestLoadProvisionedCluster(t *testing.T) {
+	fake := NewFake()
+	fake.Exec = counts(map[string]int64{"dim_plan": 3, "fact_payment": 2}, 0)
+	l := newLoader(t, fake)
+	l.WorkgroupName, l.ClusterIdentifier, l.DbUser, l.Schema = "", "claim-dev-cluster", "etl", "analytics"
+
+	_, err := l.Load(context.Background(), &gold.Manifest{Format: "parquet"})
+	require.NoError(t, err)
+	assert.Empty(t, fake.Statements())
+
+	fake.Exec = func(sql string) FakeResult { return FakeResult{Count: aws.Int64(3)} }
+	_, err = l.Load(context.Background(), &gold.Manifest{Format: "parquet", Tables: []*gold.TableResult{{Table: "dim_plan", Version: 1, Rows: 3}}})
+	require.NoError(t, err)
+	st := fake.Statements()[0]
+	assert.Equal(t, "claim-dev-cluster", st.ClusterIdentifier)
+	assert.Equal(t, "etl", st.DbUser)
+	assert.Empty(t, st.WorkgroupName)
+	assert.True(t, strings.HasPrefix(st.SQL, "CREATE TABLE IF NOT EXISTS analytics.dim_plan "), st.SQL)
+}
+
+func TestLoadSurfacesSQLErrors(t *testing.T) {
+	fake := NewFake()
+	rows := counts(map[string]int64{"dim_plan": 3}, 0)
+	fake.Exec = func(sql string) FakeResult {
+		if strings.HasPrefix(sql, "COPY gold.stg_fact_payment") {
+			return FakeResult{Pending: 1, Error: "ERROR: Spectrum Scan Error. File 's3://claim-dev-lake/gold/fact_payment/year=2025/month=12/day=01/part-00000.parquet' has an incompatible Parquet schema"}
+		}
+		return rows(sql)
+	}
+	l := newLoader(t, fake)
+
+	_, err := l.Load(context.Background(), manifest())
+	var se *StatementError
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, "fact_payment", se.Table)
+	assert.Equal(t, "copy", se.Step)
+	assert.Equal(t, redshiftdataapiservice.StatusStringFailed, se.Status)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000011", se.ID)
+	assert.Contains(t, se.Message, "incompatible Parquet schema")
+	assert.Contains(t, err.Error(), "redshift: fact_payment copy: statement 00000000-0000-0000-0000-000000000011 failed: ERROR: Spectrum Scan Error")
+	assert.Contains(t, err.Error(), "\nCOPY gold.stg_fact_payment FROM 's3://claim-dev-lake/gold/fact_payment/'", "The error carries the SQL")
+	assert.Len(t, fake.Statements(), 11, "Nothing runs after the failed statement")
+}
+
+func TestLoadSurfacesAPIErrors(t *testing.T) {
+	l := newLoader(t, NewFake())
+	l.Database = ""
+	_, err := l.Load(context.Background(), manifest())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "redshift: dim_plan create: execute: ValidationException: Sql and Database are required.")
+}
+
+func TestLoadChecksRowCount(t *testing.T) {
+	fake := NewFake()
+	fake.Exec = counts(map[string]int64{"dim_plan": 2}, 0)
+	l := newLoader(t, fake)
+
+	_, err := l.Load(context.Background(), manifest())
+	assert.EqualError(t, err, "redshift: gold.dim_plan has 2 rows after the load but gold has 3")
+}
+
+func TestLoadCancelsOnContextDone(t *testing.T) {
+	fake := NewFake()
+	fake.Exec = func(sql string) FakeResult { return FakeResult{Pending: 1 << 20} }
+	l := newLoader(t, fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := l.Load(ctx, manifest())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	st := fake.Statements()
+	require.Len(t, st, 1)
+	assert.Equal(t, redshiftdataapiservice.StatusStringAborted, st[0].Status, "The running statement is cancelled")
+}
+
+func TestLoadRejects(t *testing.T) {
+	l := newLoader(t, NewFake())
+	_, err := l.Load(context.Background(), &gold.Manifest{RunID: "gold-csv", Format: "csv"})
+	assert.EqualError(t, err, "redshift: gold run gold-csv is csv; COPY needs parquet")
+
+	l.ClusterIdentifier = "claim-dev-cluster"
+	_, err = l.Load(context.Background(), manifest())
+	assert.EqualError(t, err, "redshift: set exactly one of the cluster identifier and the workgroup name")
+}
@@ -0,0 +1,323 @@
+// Package replay re-enqueues raw files onto the S3 event queue so the ingestion
+// worker processes them again, for backfills and for reruns after a fix.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/queue"
+	"claim-management-system/pipeline/s3event"
+)
+
+// AttrReplayID is the message attribute carrying the replay id, so replayed
+// messages can be told apart in queue metrics and the DLQ without parsing the
+// body.
+const AttrReplayID = "replay-id"
+
+// fileTypes are fanned out over when a selection has a time range but no
+// indexed attribute to query by.
+var fileTypes = []string{"834", "835", "837"}
+
+// Selection picks the files to replay: records matching Query, objects named
+// in Objects, or both.
+type Selection struct {
+	Query   *metadata.Query
+	Objects []ObjectRef
+}
+
+// ObjectRef names a raw object. An empty VersionID selects the latest version.
+type ObjectRef struct {
+	Bucket    string
+	Key       string
+	VersionID string
+}
+
+// Action is what Run did with one selected file.
+type Action string
+
+const (
+	ActionEnqueued Action = "ENQUEUED"
+	// ActionWouldEnqueue is reported instead of ActionEnqueued in dry runs.
+	ActionWouldEnqueue Action = "WOULD_ENQUEUE"
+	ActionSkipped      Action = "SKIPPED"
+)
+
+// Item reports one selected file.
+type Item struct {
+	FileID    string
+	Bucket    string
+	Key       string
+	VersionID string
+	// Status is the record's status before the replay; empty for objects that
+	// have no metadata record yet.
+	Status metadata.Status
+	Action Action
+	Reason string
+}
+
+// Result summarizes a run.
+type Result struct {
+	ID       string
+	Items    []Item
+	Enqueued int
+	Skipped  int
+}
+
+// Replayer sends synthetic ObjectCreated events, marked with s3event.Replay,
+// for selected files and records a REPLAYED transition on each record before
+// its event is sent.
+type Replayer struct {
+	Metadata metadata.Store
+	Objects  objectstore.Store
+	Queue    queue.Queue
+
+	// ID identifies the run in transitions and events; Run generates one
+	// from the clock when empty.
+	ID          string
+	RequestedBy string
+	Reason      string
+	// Rate caps events sent per second. Zero means no limit.
+	Rate float64
+	// Max stops the run after this many files have been enqueued. Zero means
+	// no limit.
+	Max int
+	// DryRun reports what would be enqueued without writing anything.
+	DryRun bool
+
+	Logger *log.Logger
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// errMaxReached stops iteration once Max files were enqueued.
+var errMaxReached = errors.New("replay: max reached")
+
+// Run replays sel. Files are visited in query order, then in the order of
+// sel.Objects; a file selected twice is replayed once.
+func (r *Replayer) Run(ctx context.Context, sel Selection) (*Result, error) {
+	if sel.Query == nil && len(sel.Objects) == 0 {
+		return nil, errors.New("replay: empty selection")
+	}
+	if r.ID == "" {
+		r.ID = "replay-" + r.now().UTC().Format("20060102T150405Z")
+	}
+
+	run := &run{Replayer: r, res: &Result{ID: r.ID}, seen: map[string]bool{}}
+	if r.Rate > 0 {
+		run.interval = time.Duration(float64(time.Second) / r.Rate)
+	}
+
+	err := run.selectQuery(ctx, sel.Query)
+	if err == nil {
+		err = run.selectObjects(ctx, sel.Objects)
+	}
+	if errors.Is(err, errMaxReached) {
+		err = nil
+	}
+	return run.res, err
+}
+
+type run struct {
+	*Replayer
+	res      *Result
+	seen     map[string]bool
+	interval time.Duration
+	lastSend time.Time
+}
+
+func (r *run) selectQuery(ctx context.Context, q *metadata.Query) error {
+	if q == nil {
+		return nil
+	}
+	queries := []metadata.Query{*q}
+	if q.FileType == "" && q.SourceSystem == "" && q.Status == "" {
+		queries = queries[:0]
+		for _, ft := range fileTypes {
+			fq := *q
+			fq.FileType = ft
+			queries = append(queries, fq)
+		}
+	}
+
+	for _, fq := range queries {
+		err := metadata.QueryAll(ctx, r.Metadata, fq, func(entry *metadata.FileRecord) error {
+			// Index entries carry only projected attributes.
+			rec, err := r.Metadata.Get(ctx, entry.FileID)
+			if err != nil {
+				return fmt.Errorf("replay: get %s: %w", entry.FileID, err)
+			}
+			return r.replay(ctx, rec, ObjectRef{Bucket: rec.Bucket, Key: rec.Key, VersionID: rec.VersionID})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *run) selectObjects(ctx context.Context, refs []ObjectRef) error {
+	for _, ref := range refs {
+		if ref.VersionID == "" {
+			obj, err := r.Objects.Head(ctx, ref.Bucket, ref.Key, "")
+			if errors.Is(err, objectstore.ErrNotFound) {
+				r.skip(Item{Bucket: ref.Bucket, Key: ref.Key}, "object not found")
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("replay: %w", err)
+			}
+			ref.VersionID = obj.VersionID
+		}
+
+		rec, err := r.Metadata.Get(ctx, metadata.NewFileID(ref.Bucket, ref.Key, ref.VersionID))
+		if errors.Is(err, metadata.ErrNotFound) {
+			// Never ingested, e.g. uploaded before the worker ran; the
+			// worker creates the record when the event arrives.
+			rec, err = nil, nil
+		}
+		if err != nil {
+			return fmt.Errorf("replay: %w", err)
+		}
+		if err := r.replay(ctx, rec, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replay enqueues one file. rec is nil for objects without a record.
+func (r *run) replay(ctx context.Context, rec *metadata.FileRecord, ref ObjectRef) error {
+	item := Item{
+		FileID:    metadata.NewFileID(ref.Bucket, ref.Key, ref.VersionID),
+		Bucket:    ref.Bucket,
+		Key:       ref.Key,
+		VersionID: ref.VersionID,
+	}
+	if r.seen[item.FileID] {
+		return nil
+	}
+	r.seen[item.FileID] = true
+
+	if rec != nil {
+		item.Status = rec.Status
+		if rec.Status == metadata.StatusReceived {
+			r.skip(item, "ingestion in progress")
+			return nil
+		}
+	}
+
+	if r.DryRun {
+		item.Action = ActionWouldEnqueue
+		return r.enqueued(item)
+	}
+
+	body, err := s3event.Build([]s3event.Record{{
+		EventName: "ObjectCreated:Put",
+		EventTime: metadata.FormatTime(r.now()),
+		Bucket:    ref.Bucket,
+		Key:       ref.Key,
+		VersionID: ref.VersionID,
+		Size:      sizeOf(rec),
+		Replay:    &s3event.Replay{ID: r.ID, RequestedBy: r.RequestedBy, Reason: r.Reason},
+	}})
+	if err != nil {
+		return err
+	}
+
+	if err := r.wait(ctx); err != nil {
+		return err
+	}
+
+	// The transition is written first so that the worker, which may receive
+	// the event at once, finds the record in REPLAYED.
+	if rec != nil {
+		rec.Transition(metadata.StatusReplayed, r.transitionReason(), r.now())
+		if err := r.Metadata.Put(ctx, rec); err != nil {
+			return fmt.Errorf("replay: record %s: %w", rec.FileID, err)
+		}
+	}
+	if err := r.Queue.Send(ctx, body, map[string]string{AttrReplayID: r.ID}); err != nil {
+		err = fmt.Errorf("replay: enqueue %s: %w", item.FileID, err)
+		if rec != nil {
+			rec.Transition(item.Status, err.Error(), r.now())
+			if perr := r.Metadata.Put(ctx, rec); perr != nil {
+				return fmt.Errorf("%v (and restoring status: %w)", err, perr)
+			}
+		}
+		return err
+	}
+
+	item.Action = ActionEnqueued
+	return r.enqueued(item)
+}
+
+func (r *run) enqueued(item Item) error {
+	r.res.Items = append(r.res.Items, item)
+	r.res.Enqueued++
+	r.logf("%s s3://%s/%s (%s)", item.Action, item.Bucket, item.Key, item.FileID)
+	if r.Max > 0 && r.res.Enqueued >= r.Max {
+		return errMaxReached
+	}
+	return nil
+}
+
+func (r *run) skip(item Item, reason string) {
+	item.Action, item.Reason = ActionSkipped, reason
+	r.res.Items = append(r.res.Items, item)
+	r.res.Skipped++
+	r.logf("skipped s3://%s/%s: %s", item.Bucket, item.Key, reason)
+}
+
+// wait blocks until the next send is allowed by Rate.
+func (r *run) wait(ctx context.Context) error {
+	if r.interval > 0 && !r.lastSend.IsZero() {
+		if d := r.interval - time.Since(r.lastSend); d > 0 {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+	r.lastSend = time.Now()
+	return nil
+}
+
+func (r *run) transitionReason() string {
+	reason := "replay " + r.ID
+	if r.RequestedBy != "" {
+		reason += " by " + r.RequestedBy
+	}
+	if r.Reason != "" {
+		reason += ": " + r.Reason
+	}
+	return reason
+}
+
+func (r *Replayer) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func (r *Replayer) logf(format string, args ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Printf(format, args...)
+	}
+}
+
+func sizeOf(rec *metadata.FileRecord) int64 {
+	if rec == nil {
+		return 0
+	}
+	return rec.SizeBytes
+}
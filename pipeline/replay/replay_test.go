@@ -0,0 +1,247 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/ingest"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/queue"
+	"claim-management-system/pipeline/s3event"
+)
+
+const rawBucket = "claim-dev-raw"
+
+var fixed = time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC)
+
+type env struct {
+	objects *objectstore.Dir
+	store   *metadata.MemoryStore
+	queue   *queue.Fake
+	worker  *ingest.Worker
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	e := &env{
+		objects: objectstore.NewDir(t.TempDir()),
+		store:   metadata.NewMemoryStore(),
+		queue:   queue.NewFake(),
+	}
+	e.worker = &ingest.Worker{Objects: e.objects, Metadata: e.store, Now: func() time.Time { return fixed }}
+	return e
+}
+
+// ingest uploads body under key and runs the worker on it directly.
+func (e *env) ingest(t *testing.T, key, body string) *metadata.FileRecord {
+	t.Helper()
+	version, err := e.objects.Put(context.Background(), rawBucket, key, strings.NewReader(body), objectstore.PutOptions{})
+	require.NoError(t, err)
+	rec, err := e.worker.Ingest(context.Background(), s3event.Record{Bucket: rawBucket, Key: key, VersionID: version})
+	require.NoError(t, err)
+	return rec
+}
+
+func (e *env) replayer() *Replayer {
+	return &Replayer{
+		Metadata:    e.store,
+		Objects:     e.objects,
+		Queue:       e.queue,
+		ID:          "r1",
+		RequestedBy: "ops",
+		Reason:      "silver schema v2",
+		Now:         func() time.Time { return fixed.Add(time.Hour) },
+	}
+}
+
+// drain feeds every queued message to the worker.
+func (e *env) drain(t *testing.T) []queue.Message {
+	t.Helper()
+	ctx := context.Background()
+	msgs, err := e.queue.Receive(ctx, 10, 0)
+	require.NoError(t, err)
+	for _, m := range msgs {
+		require.NoError(t, e.worker.Handle(ctx, m))
+		require.NoError(t, e.queue.Delete(ctx, m.ReceiptHandle))
+	}
+	return msgs
+}
+
+func statuses(rec *metadata.FileRecord) []metadata.Status {
+	out := make([]metadata.Status, 0, len(rec.Transitions))
+	for _, tr := range rec.Transitions {
+		out = append(out, tr.Status)
+	}
+	return out
+}
+
+func TestReplayByQuery(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	a := e.ingest(t, "raw/837/source=a/one.csv", "id\n1\n")
+	e.ingest(t, "raw/835/source=a/two.csv", "id\n2\n")
+	c := e.ingest(t, "raw/837/source=b/three.csv", "id\n3\n")
+
+	res, err := e.replayer().Run(ctx, Selection{Query: &metadata.Query{FileType: "837"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Enqueued)
+	assert.Equal(t, 2, e.queue.Len())
+
+	stored, err := e.store.Get(ctx, a.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusReplayed, stored.Status)
+	assert.Equal(t, "replay r1 by ops: silver schema v2", stored.Transitions[len(stored.Transitions)-1].Reason)
+
+	msgs := e.drain(t)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "r1", msgs[0].Attributes[AttrReplayID])
+	records, err := s3event.Parse(msgs[0].Body)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.NotNil(t, records[0].Replay)
+	assert.Equal(t, "silver schema v2", records[0].Replay.Reason)
+
+	for _, id := range []string{a.FileID, c.FileID} {
+		stored, err := e.store.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []metadata.Status{
+			metadata.StatusReceived, metadata.StatusIngested, metadata.StatusReplayed, metadata.StatusIngested,
+		}, statuses(stored))
+	}
+}
+
+func TestReplayDateRangeFansOutOverFileTypes(t *testing.T) {
+	e := newEnv(t)
+	e.ingest(t, "raw/837/one.csv", "id\n1\n")
+	e.ingest(t, "raw/834/two.csv", "id\n2\n")
+
+	r := e.replayer()
+	r.DryRun = true
+	res, err := r.Run(context.Background(), Selection{Query: &metadata.Query{From: fixed, To: fixed.Add(time.Minute)}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Enqueued)
+}
+
+func TestReplayDryRunWritesNothing(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	rec := e.ingest(t, "raw/837/one.csv", "id\n1\n")
+
+	r := e.replayer()
+	r.DryRun = true
+	res, err := r.Run(ctx, Selection{Query: &metadata.Query{FileType: "837"}})
+	require.NoError(t, err)
+	require.Len(t, res.Items, 1)
+	assert.Equal(t, ActionWouldEnqueue, res.Items[0].Action)
+	assert.Equal(t, metadata.StatusIngested, res.Items[0].Status)
+	assert.Zero(t, e.queue.Len())
+
+	stored, err := e.store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, stored.Status)
+}
+
+func TestReplayExplicitKeys(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	rec := e.ingest(t, "raw/837/one.csv", "id\n1\n")
+
+	// Uploaded before the worker existed: no metadata record yet.
+	_, err := e.objects.Put(ctx, rawBucket, "raw/835/backfill.csv", strings.NewReader("id\n9\n"), objectstore.PutOptions{})
+	require.NoError(t, err)
+
+	res, err := e.replayer().Run(ctx, Selection{
+		Query: &metadata.Query{FileType: "837"},
+		Objects: []ObjectRef{
+			{Bucket: rawBucket, Key: "raw/837/one.csv"},
+			{Bucket: rawBucket, Key: "raw/835/backfill.csv"},
+			{Bucket: rawBucket, Key: "raw/835/missing.csv"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Enqueued, "A file selected twice should be replayed once")
+	assert.Equal(t, 1, res.Skipped)
+	assert.Equal(t, "object not found", res.Items[2].Reason)
+	assert.Equal(t, rec.FileID, res.Items[0].FileID)
+	assert.Empty(t, res.Items[1].Status)
+
+	e.drain(t)
+	backfilled, err := e.store.Get(ctx, res.Items[1].FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, backfilled.Status)
+	assert.Equal(t, int64(1), backfilled.RecordCount)
+}
+
+func TestReplaySkipsInFlightFiles(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	require.NoError(t, e.store.Put(ctx, &metadata.FileRecord{
+		FileID: "busy", FileType: "837", Bucket: rawBucket, Key: "raw/837/busy.csv",
+		IngestTime: metadata.FormatTime(fixed), Status: metadata.StatusReceived,
+	}))
+
+	res, err := e.replayer().Run(ctx, Selection{Query: &metadata.Query{FileType: "837"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Skipped)
+	assert.Zero(t, e.queue.Len())
+}
+
+func TestReplayMax(t *testing.T) {
+	e := newEnv(t)
+	for _, k := range []string{"a", "b", "c"} {
+		e.ingest(t, "raw/837/"+k+".csv", "id\n1"+k+"\n")
+	}
+
+	r := e.replayer()
+	r.Max = 2
+	res, err := r.Run(context.Background(), Selection{Query: &metadata.Query{FileType: "837"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Enqueued)
+	assert.Equal(t, 2, e.queue.Len())
+}
+
+func TestReplayRateLimit(t *testing.T) {
+	e := newEnv(t)
+	for _, k := range []string{"a", "b", "c"} {
+		e.ingest(t, "raw/837/"+k+".csv", "id\n1"+k+"\n")
+	}
+
+	r := e.replayer()
+	r.Rate = 20
+	start := time.Now()
+	_, err := r.Run(context.Background(), Selection{Query: &metadata.Query{FileType: "837"}})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "Three sends at 20/s need two 50ms gaps")
+}
+
+type failingQueue struct{ queue.Queue }
+
+func (failingQueue) Send(context.Context, string, map[string]string) error {
+	return errors.New("throttled")
+}
+
+func TestReplayRestoresStatusWhenSendFails(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	rec := e.ingest(t, "raw/837/one.csv", "id\n1\n")
+
+	r := e.replayer()
+	r.Queue = failingQueue{}
+	_, err := r.Run(ctx, Selection{Query: &metadata.Query{FileType: "837"}})
+	require.Error(t, err)
+
+	stored, err := e.store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusIngested, stored.Status)
+	assert.Equal(t, []metadata.Status{
+		metadata.StatusReceived, metadata.StatusIngested, metadata.StatusReplayed, metadata.StatusIngested,
+	}, statuses(stored))
+	assert.Contains(t, stored.Transitions[3].Reason, "throttled")
+}
@@ -0,0 +1,136 @@
+// Package s3event decodes the S3 event notifications delivered to the
+// claim-<env>-s3-events queue.
+package s3event
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ReplaySource is the eventSource of synthetic notifications produced by the
+// replay command, distinguishing them from events S3 emitted.
+const ReplaySource = "claim:replay"
+
+// Record is one object event, with the key already URL-decoded.
+type Record struct {
+	EventName string
+	EventTime string
+	Bucket    string
+	Key       string
+	VersionID string
+	Size      int64
+	ETag      string
+	// Replay is set on synthetic events re-enqueued for reprocessing.
+	Replay *Replay
+}
+
+// Replay marks a synthetic event and says who asked for it and why.
+type Replay struct {
+	ID          string `json:"id"`
+	RequestedBy string `json:"requestedBy,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Notification mirrors the JSON document S3 publishes to SQS.
+type Notification struct {
+	Records []NotificationRecord `json:"Records"`
+	// Event is set to "s3:TestEvent" on the message S3 sends when the
+	// notification configuration is first saved.
+	Event string `json:"Event,omitempty"`
+}
+
+// NotificationRecord is a single entry of Notification.Records.
+type NotificationRecord struct {
+	EventVersion string `json:"eventVersion"`
+	EventSource  string `json:"eventSource"`
+	AWSRegion    string `json:"awsRegion,omitempty"`
+	EventTime    string `json:"eventTime"`
+	EventName    string `json:"eventName"`
+	S3           struct {
+		Bucket struct {
+			Name string `json:"name"`
+			ARN  string `json:"arn,omitempty"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      int64  `json:"size"`
+			ETag      string `json:"eTag,omitempty"`
+			VersionID string `json:"versionId,omitempty"`
+			Sequencer string `json:"sequencer,omitempty"`
+		} `json:"object"`
+	} `json:"s3"`
+	// Replay is not part of the S3 schema; see ReplaySource.
+	Replay *Replay `json:"replay,omitempty"`
+}
+
+// Parse decodes a queue message body. Test events yield no records. Only
+// ObjectCreated events are returned; other event types are dropped.
+func Parse(body string) ([]Record, error) {
+	var n Notification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		return nil, fmt.Errorf("s3event: decode notification: %w", err)
+	}
+	if n.Event == "s3:TestEvent" {
+		return nil, nil
+	}
+
+	records := make([]Record, 0, len(n.Records))
+	for i, r := range n.Records {
+		if !strings.HasPrefix(r.EventName, "ObjectCreated:") {
+			continue
+		}
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("s3event: record %d: decode key %q: %w", i, r.S3.Object.Key, err)
+		}
+		if r.S3.Bucket.Name == "" || key == "" {
+			return nil, fmt.Errorf("s3event: record %d: missing bucket or key", i)
+		}
+		records = append(records, Record{
+			EventName: r.EventName,
+			EventTime: r.EventTime,
+			Bucket:    r.S3.Bucket.Name,
+			Key:       key,
+			VersionID: r.S3.Object.VersionID,
+			Size:      r.S3.Object.Size,
+			ETag:      r.S3.Object.ETag,
+			Replay:    r.Replay,
+		})
+	}
+	return records, nil
+}
+
+// Build renders records as an S3 notification body. Keys are URL-encoded the
+// way S3 encodes them, so Parse(Build(rs)) returns rs.
+func Build(records []Record) (string, error) {
+	n := Notification{Records: make([]NotificationRecord, 0, len(records))}
+	for _, r := range records {
+		var nr NotificationRecord
+		nr.EventVersion = "2.1"
+		nr.EventSource = "aws:s3"
+		if r.Replay != nil {
+			nr.EventSource = ReplaySource
+		}
+		nr.EventTime = r.EventTime
+		nr.EventName = r.EventName
+		if nr.EventName == "" {
+			nr.EventName = "ObjectCreated:Put"
+		}
+		nr.S3.Bucket.Name = r.Bucket
+		nr.S3.Bucket.ARN = "arn:aws:s3:::" + r.Bucket
+		nr.S3.Object.Key = url.QueryEscape(r.Key)
+		nr.S3.Object.Size = r.Size
+		nr.S3.Object.ETag = r.ETag
+		nr.S3.Object.VersionID = r.VersionID
+		nr.Replay = r.Replay
+		n.Records = append(n.Records, nr)
+	}
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		return "", fmt.Errorf("s3event: encode notification: %w", err)
+	}
+	return string(data), nil
+}
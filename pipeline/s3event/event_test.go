@@ -0,0 +1,64 @@
+package s3event
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	body := `{"Records":[
+		{"eventVersion":"2.1","eventSource":"aws:s3","eventTime":"2025-11-21T10:00:00.000Z",
+		 "eventName":"ObjectCreated:Put",
+		 "s3":{"bucket":{"name":"claim-dev-raw"},
+		       "object":{"key":"raw/837/year%3D2025/batch+one.csv","size":42,"versionId":"v1"}}},
+		{"eventVersion":"2.1","eventSource":"aws:s3","eventTime":"2025-11-21T10:00:01.000Z",
+		 "eventName":"ObjectRemoved:Delete",
+		 "s3":{"bucket":{"name":"claim-dev-raw"},"object":{"key":"raw/837/old.csv"}}}
+	]}`
+
+	records, err := Parse(body)
+	require.NoError(t, err)
+	require.Len(t, records, 1, "Only ObjectCreated events should be returned")
+
+	assert.Equal(t, "claim-dev-raw", records[0].Bucket)
+	assert.Equal(t, "raw/837/year=2025/batch one.csv", records[0].Key, "Key should be URL-decoded")
+	assert.Equal(t, "v1", records[0].VersionID)
+	assert.Equal(t, int64(42), records[0].Size)
+}
+
+func TestParseTestEvent(t *testing.T) {
+	records, err := Parse(`{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"claim-dev-raw"}`)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestParseRejectsMalformed(t *testing.T) {
+	_, err := Parse(`not json`)
+	assert.Error(t, err)
+
+	_, err = Parse(`{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":""},"object":{"key":"a"}}}]}`)
+	assert.Error(t, err, "Records without a bucket should be rejected")
+}
+
+func TestBuildRoundTrip(t *testing.T) {
+	in := []Record{{
+		EventName: "ObjectCreated:Put",
+		EventTime: "2025-11-21T10:00:00.000Z",
+		Bucket:    "claim-dev-raw",
+		Key:       "raw/837/year=2025/source=clearinghouse/batch one+two.csv",
+		VersionID: "v1",
+		Size:      7,
+		Replay:    &Replay{ID: "replay-1", RequestedBy: "ops", Reason: "silver v2"},
+	}}
+
+	body, err := Build(in)
+	require.NoError(t, err)
+	assert.Contains(t, body, `"eventSource":"claim:replay"`)
+	assert.Contains(t, body, "year%3D2025", "Keys should be URL-encoded like S3 does")
+
+	out, err := Parse(body)
+	require.NoError(t, err)
+	assert.Equal(t, in, out)
+}
@@ -0,0 +1,186 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeKind is the kind of a difference between two schema versions, or
+// between a schema and a CSV header.
+type ChangeKind string
+
+const (
+	Added       ChangeKind = "added"
+	Dropped     ChangeKind = "dropped"
+	Renamed     ChangeKind = "renamed"
+	Retyped     ChangeKind = "retyped"
+	Nullability ChangeKind = "nullability"
+	PHIFlag     ChangeKind = "phi"
+	Codes       ChangeKind = "values"
+	KeyChanged  ChangeKind = "key"
+	Partitioned ChangeKind = "partitions"
+	// Missing and Unknown are header changes: a schema column the header
+	// lacks, and a header column the schema has never had.
+	Missing   ChangeKind = "missing"
+	Unknown   ChangeKind = "unknown"
+	Duplicate ChangeKind = "duplicate"
+)
+
+// Change is one difference. Compatible changes let data written under the
+// old version (or with the header) be read under the new one.
+type Change struct {
+	Kind       ChangeKind
+	Column     string
+	Compatible bool
+	Msg        string
+}
+
+func (c *Change) String() string {
+	s := fmt.Sprintf("%s: %s", c.Kind, c.Msg)
+	if c.Column != "" {
+		s = fmt.Sprintf("%s %s: %s", c.Kind, c.Column, c.Msg)
+	}
+	if !c.Compatible {
+		s += " (incompatible)"
+	}
+	return s
+}
+
+// Incompatible returns the incompatible changes of changes.
+func Incompatible(changes []*Change) []*Change {
+	var out []*Change
+	for _, c := range changes {
+		if !c.Compatible {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Compare returns the changes from old to new. The rules are those of
+// readers of the old data, which new must still read:
+//
+//   - adding a column is compatible if it is nullable, since old rows lack it
+//   - dropping a column is compatible if it was nullable, since consumers
+//     already handle its absence
+//   - renaming (RenamedFrom) is compatible; renaming without declaring it is
+//     a drop and an add
+//   - changing a type is compatible if the new type widens the old one
+//   - making a column nullable is compatible, making it required is not
+//   - flagging a column PHI is compatible, unflagging it is not, since that
+//     would expose PHI already stored
+//   - adding enumerated codes (or dropping the enumeration) is compatible,
+//     removing codes is not
+//   - changing the key or the partitions is incompatible
+func Compare(old, new *Schema) []*Change {
+	var changes []*Change
+	add := func(kind ChangeKind, column string, compatible bool, format string, args ...interface{}) {
+		changes = append(changes, &Change{Kind: kind, Column: column, Compatible: compatible, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	matched := map[string]bool{}
+	for _, c := range new.Columns {
+		prev := old.Column(c.Name)
+		if prev == nil && c.RenamedFrom != "" {
+			if prev = old.Column(c.RenamedFrom); prev != nil {
+				add(Renamed, c.Name, true, "renamed from %s", prev.Name)
+			}
+		}
+		if prev == nil {
+			if c.RenamedFrom != "" {
+				add(Added, c.Name, false, "renamed from %s, which v%d does not have", c.RenamedFrom, old.Version)
+			} else {
+				add(Added, c.Name, c.Nullable, "added as %s", describe(c))
+			}
+			continue
+		}
+		matched[prev.Name] = true
+		if c.Type != prev.Type {
+			add(Retyped, c.Name, c.Type.Widens(prev.Type), "%s to %s", prev.Type, c.Type)
+		}
+		if c.Nullable != prev.Nullable {
+			add(Nullability, c.Name, c.Nullable, "%s to %s", nullability(prev), nullability(c))
+		}
+		if c.PHI != prev.PHI {
+			if c.PHI {
+				add(PHIFlag, c.Name, true, "flagged PHI")
+			} else {
+				add(PHIFlag, c.Name, false, "no longer flagged PHI")
+			}
+		}
+		if removed := removedCodes(prev.Values, c.Values); len(removed) > 0 {
+			add(Codes, c.Name, false, "codes %s removed", strings.Join(removed, ","))
+		} else if len(c.Values) != len(prev.Values) {
+			add(Codes, c.Name, true, "codes %v to %v", prev.Values, c.Values)
+		}
+	}
+	for _, c := range old.Columns {
+		if !matched[c.Name] {
+			add(Dropped, c.Name, c.Nullable, "dropped %s column", nullability(c))
+		}
+	}
+
+	if strings.Join(rename(new, old.Key), ",") != strings.Join(new.Key, ",") {
+		add(KeyChanged, "", false, "key %v to %v", old.Key, new.Key)
+	}
+	if partitions(old) != partitions(new) {
+		add(Partitioned, "", false, "partitions %s to %s", partitions(old), partitions(new))
+	} else if old.PartitionBy != "" && rename(new, []string{old.PartitionBy})[0] != new.PartitionBy ||
+		old.PartitionBy == "" && new.PartitionBy != "" {
+		add(Partitioned, "", false, "partitioned by %q to %q", old.PartitionBy, new.PartitionBy)
+	}
+	return changes
+}
+
+func describe(c *Column) string {
+	return nullability(c) + " " + c.Type.String()
+}
+
+func nullability(c *Column) string {
+	if c.Nullable {
+		return "nullable"
+	}
+	return "required"
+}
+
+// removedCodes returns the codes of old that new lacks. An enumeration
+// dropped altogether removes nothing: the column then takes any value.
+func removedCodes(old, new []string) []string {
+	if len(new) == 0 {
+		return nil
+	}
+	have := map[string]bool{}
+	for _, v := range new {
+		have[v] = true
+	}
+	var removed []string
+	for _, v := range old {
+		if !have[v] {
+			removed = append(removed, v)
+		}
+	}
+	return removed
+}
+
+// rename returns key, an old version's key, with the columns new renamed
+// under their new names.
+func rename(new *Schema, key []string) []string {
+	out := make([]string, len(key))
+	for i, k := range key {
+		out[i] = k
+		for _, c := range new.Columns {
+			if c.RenamedFrom == k && new.Column(k) == nil {
+				out[i] = c.Name
+			}
+		}
+	}
+	return out
+}
+
+func partitions(s *Schema) string {
+	parts := make([]string, len(s.Partitions))
+	for i, c := range s.Partitions {
+		parts[i] = c.Name + " " + c.Type.String()
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,209 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const membersV1 = `
+name: tbl_members
+layer: raw
+version: 1
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: last_name, type: string, phi: true}
+  - {name: zip, type: string, nullable: true, phi: true}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+`
+
+// changes returns Compare(v1, v2) as strings.
+func changes(t *testing.T, v1, v2 string) []string {
+	t.Helper()
+	var out []string
+	for _, c := range Compare(mustParse(t, v1), mustParse(t, v2)) {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		name, v2 string
+		want     []string
+	}{
+		{"unchanged", membersV1, nil},
+		{"add nullable", membersV1 + "  - {name: email, type: string, nullable: true, phi: true}\n",
+			[]string{"added email: added as nullable string"}},
+		{"add required", membersV1 + "  - {name: email, type: string}\n",
+			[]string{"added email: added as required string (incompatible)"}},
+		{"drop nullable", `
+name: tbl_members
+layer: raw
+version: 2
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: last_name, type: string, phi: true}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+`, []string{"dropped zip: dropped nullable column"}},
+		{"drop required", `
+name: tbl_members
+layer: raw
+version: 2
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: zip, type: string, nullable: true, phi: true}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+`, []string{"dropped last_name: dropped required column (incompatible)"}},
+		{"rename", `
+name: tbl_members
+layer: raw
+version: 2
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: surname, type: string, phi: true, renamed_from: last_name}
+  - {name: postal_code, type: string, nullable: true, phi: true, renamed_from: zip}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+`, []string{"renamed surname: renamed from last_name", "renamed postal_code: renamed from zip"}},
+		{"undeclared rename", `
+name: tbl_members
+layer: raw
+version: 2
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: surname, type: string, phi: true}
+  - {name: zip, type: string, nullable: true, phi: true}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+`, []string{
+			"added surname: added as required string (incompatible)",
+			"dropped last_name: dropped required column (incompatible)",
+		}},
+		{"rename from nothing", `
+name: tbl_members
+layer: raw
+version: 2
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: last_name, type: string, phi: true}
+  - {name: zip, type: string, nullable: true, phi: true}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+  - {name: email, type: string, nullable: true, renamed_from: mail}
+`, []string{"added email: renamed from mail, which v1 does not have (incompatible)"}},
+		{"types, nullability, phi and codes", `
+name: tbl_members
+layer: raw
+version: 2
+location: raw/834/
+columns:
+  - {name: member_id, type: string}
+  - {name: last_name, type: string, nullable: true, phi: true}
+  - {name: zip, type: string, phi: true}
+  - {name: plan_code, type: string, values: [A, C]}
+  - {name: visits, type: "decimal(20,1)", nullable: true}
+`, []string{
+			"phi member_id: no longer flagged PHI (incompatible)",
+			"nullability last_name: required to nullable",
+			"nullability zip: nullable to required (incompatible)",
+			"values plan_code: codes B removed (incompatible)",
+			"retyped visits: int to decimal(20,1)",
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, changes(t, membersV1, c.v2))
+		})
+	}
+}
+
+func TestCompareKeyAndPartitions(t *testing.T) {
+	v1 := `
+name: tbl_a
+layer: silver
+version: 1
+location: silver/a/
+key: [id]
+columns: [{name: id, type: string}, {name: day, type: date}]
+partitions: [{name: year, type: string}]
+`
+	renamedKey := `
+name: tbl_a
+layer: silver
+version: 2
+location: silver/a/
+key: [member_id]
+columns: [{name: member_id, type: string, renamed_from: id}, {name: day, type: date}]
+partitions: [{name: year, type: string}]
+`
+	assert.Equal(t, []string{"renamed member_id: renamed from id"}, changes(t, v1, renamedKey),
+		"Renaming a key column keeps the key")
+
+	rekeyed := `
+name: tbl_a
+layer: silver
+version: 2
+location: silver/a/
+key: [id, day]
+columns: [{name: id, type: string}, {name: day, type: date}]
+partitions: [{name: year, type: string}, {name: month, type: string}]
+`
+	assert.Equal(t, []string{
+		"key: key [id] to [id day] (incompatible)",
+		"partitions: partitions [year string] to [year string, month string] (incompatible)",
+	}, changes(t, v1, rekeyed))
+
+	repartitioned := `
+name: tbl_a
+layer: silver
+version: 2
+location: silver/a/
+key: [id]
+partition_by: day
+columns: [{name: id, type: string}, {name: day, type: date}]
+partitions: [{name: year, type: string}]
+`
+	assert.Equal(t, []string{`partitions: partitioned by "" to "day" (incompatible)`}, changes(t, v1, repartitioned))
+}
+
+func TestTypeWidens(t *testing.T) {
+	parse := func(s string) Type {
+		typ, err := ParseType(s)
+		require.NoError(t, err, s)
+		return typ
+	}
+	cases := []struct {
+		old, new string
+		want     bool
+	}{
+		{"int", "string", true},
+		{"date", "string", true},
+		{"int", "decimal(19,0)", true},
+		{"int", "decimal(20,2)", false},
+		{"decimal(12,2)", "decimal(14,4)", true},
+		{"decimal(12,2)", "decimal(12,4)", false},
+		{"decimal(12,2)", "decimal(12,1)", false},
+		{"string", "int", false},
+		{"date", "timestamp", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, parse(c.new).Widens(parse(c.old)), "%s to %s", c.old, c.new)
+	}
+
+	for _, bad := range []string{"decimal(39,2)", "decimal(4,5)", "decimal(x)", "decimal(10,2", "varchar"} {
+		_, err := ParseType(bad)
+		assert.Error(t, err, bad)
+	}
+	assert.Equal(t, "decimal(12,2)", parse(" decimal(12, 2) ").String())
+}
@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/glue"
+)
+
+// Glue table parameters written by GlueTable.
+const (
+	ParamSchemaVersion = "schema_version"
+	ParamPHI           = "phi"
+)
+
+// Storage formats of the tables: raw CSVs are read with OpenCSVSerde,
+// silver and gold tables are Parquet.
+const (
+	textInputFormat     = "org.apache.hadoop.mapred.TextInputFormat"
+	textOutputFormat    = "org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat"
+	openCSVSerde        = "org.apache.hadoop.hive.serde2.OpenCSVSerde"
+	parquetInputFormat  = "org.apache.hadoop.hive.ql.io.parquet.MapredParquetInputFormat"
+	parquetOutputFormat = "org.apache.hadoop.hive.ql.io.parquet.MapredParquetOutputFormat"
+	parquetSerde        = "org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe"
+)
+
+// GlueType returns the Hive type of t as Glue and Athena write it.
+func (t Type) GlueType() string {
+	if t.Kind == Int {
+		return "bigint"
+	}
+	return t.String()
+}
+
+// GlueTable returns the Glue table definition of s with its data under
+// bucket. Columns keep the schema's order and carry its description as
+// their comment and "phi"="true" when flagged.
+//
+// A raw table is an external CSV table skipping the header row. OpenCSVSerde
+// reads every column as a string, whatever the schema declares, and reads
+// columns by position: the declared types are enforced by validation, not by
+// Athena. Silver and gold tables are Parquet, typed and partitioned.
+func (s *Schema) GlueTable(bucket string) *glue.TableInput {
+	sd := &glue.StorageDescriptor{
+		Location: aws.String("s3://" + bucket + "/" + s.Location),
+	}
+	params := map[string]*string{
+		ParamSchemaVersion: aws.String(strconv.Itoa(s.Version)),
+		"EXTERNAL":         aws.String("TRUE"),
+	}
+	if s.Layer == Raw {
+		params["classification"] = aws.String("csv")
+		params["skip.header.line.count"] = aws.String("1")
+		sd.InputFormat = aws.String(textInputFormat)
+		sd.OutputFormat = aws.String(textOutputFormat)
+		sd.SerdeInfo = &glue.SerDeInfo{
+			SerializationLibrary: aws.String(openCSVSerde),
+			Parameters: map[string]*string{
+				"separatorChar": aws.String(","),
+				"quoteChar":     aws.String(`"`),
+				"escapeChar":    aws.String(`\`),
+			},
+		}
+	} else {
+		params["classification"] = aws.String("parquet")
+		params["parquet.compression"] = aws.String("SNAPPY")
+		sd.InputFormat = aws.String(parquetInputFormat)
+		sd.OutputFormat = aws.String(parquetOutputFormat)
+		sd.SerdeInfo = &glue.SerDeInfo{
+			SerializationLibrary: aws.String(parquetSerde),
+			Parameters:           map[string]*string{"serialization.format": aws.String("1")},
+		}
+	}
+	for _, c := range s.Columns {
+		typ := c.Type.GlueType()
+		if s.Layer == Raw {
+			typ = string(String)
+		}
+		sd.Columns = append(sd.Columns, glueColumn(c, typ))
+	}
+
+	t := &glue.TableInput{
+		Name:              aws.String(s.Name),
+		TableType:         aws.String("EXTERNAL_TABLE"),
+		Parameters:        params,
+		StorageDescriptor: sd,
+	}
+	if s.Description != "" {
+		t.Description = aws.String(s.Description)
+	}
+	for _, c := range s.Partitions {
+		t.PartitionKeys = append(t.PartitionKeys, glueColumn(c, c.Type.GlueType()))
+	}
+	return t
+}
+
+func glueColumn(c *Column, typ string) *glue.Column {
+	col := &glue.Column{Name: aws.String(c.Name), Type: aws.String(typ)}
+	if c.Description != "" {
+		col.Comment = aws.String(c.Description)
+	}
+	if c.PHI {
+		col.Parameters = map[string]*string{ParamPHI: aws.String("true")}
+	}
+	return col
+}
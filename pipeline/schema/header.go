@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HeaderMatch is a CSV header matched to the latest version of a schema.
+// Columns are matched by name, so their order does not matter.
+type HeaderMatch struct {
+	Schema *Schema
+	// Version is the newest version whose columns are exactly the header's,
+	// or 0 if there is none.
+	Version int
+	// Index holds, for each column of Schema, its position in the header,
+	// or -1 if the header lacks it.
+	Index []int
+	// Changes are the differences between the header and Schema.
+	Changes []*Change
+}
+
+// Compatible reports whether the header's rows can be read under Schema.
+func (m *HeaderMatch) Compatible() bool {
+	return len(Incompatible(m.Changes)) == 0
+}
+
+// Row returns the values of a header-ordered record in Schema column order;
+// a column the header lacks is empty.
+func (m *HeaderMatch) Row(record []string) []string {
+	row := make([]string, len(m.Index))
+	for i, at := range m.Index {
+		if at >= 0 && at < len(record) {
+			row[i] = record[at]
+		}
+	}
+	return row
+}
+
+// MatchHeader matches a CSV header to the latest version of a schema. Names
+// are compared trimmed and lower-cased. A header column may carry the name a
+// column had in any earlier version, and may be a column an earlier version
+// dropped, in which case it is ignored. The header is incompatible if it
+// lacks a required column, repeats a column, or has a column no version of
+// the schema has ever had: such drift must be registered as a new version
+// before files carrying it are read.
+func (r *Registry) MatchHeader(name string, header []string) (*HeaderMatch, error) {
+	latest, err := r.Latest(name)
+	if err != nil {
+		return nil, err
+	}
+	versions := r.Versions(name)
+	m := &HeaderMatch{Schema: latest, Index: make([]int, len(latest.Columns))}
+	add := func(kind ChangeKind, column string, compatible bool, format string, args ...interface{}) {
+		m.Changes = append(m.Changes, &Change{Kind: kind, Column: column, Compatible: compatible, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	at := map[string]int{}
+	for i, h := range header {
+		h = normalize(h)
+		if _, dup := at[h]; dup {
+			add(Duplicate, h, false, "header repeats column %d", i+1)
+			continue
+		}
+		at[h] = i
+	}
+
+	used := map[string]bool{}
+	for i, c := range latest.Columns {
+		m.Index[i] = -1
+		for _, n := range names(versions, i) {
+			if pos, ok := at[n]; ok {
+				m.Index[i] = pos
+				used[n] = true
+				if n != c.Name {
+					add(Renamed, c.Name, true, "header has its former name %s", n)
+				}
+				break
+			}
+		}
+		if m.Index[i] < 0 {
+			add(Missing, c.Name, c.Nullable, "header lacks %s column", nullability(c))
+		}
+	}
+
+	dropped := map[string]int{}
+	for _, v := range versions[:len(versions)-1] {
+		for _, c := range v.Columns {
+			dropped[c.Name] = v.Version
+		}
+	}
+	for i, h := range header {
+		h = normalize(h)
+		switch {
+		case used[h] || at[h] != i:
+		case dropped[h] > 0:
+			add(Dropped, h, true, "last in v%d, ignored", dropped[h])
+		default:
+			add(Unknown, h, false, "no version of %s has this column", name)
+		}
+	}
+
+	for j := len(versions) - 1; j >= 0; j-- {
+		if sameColumns(versions[j], at) {
+			m.Version = versions[j].Version
+			break
+		}
+	}
+	return m, nil
+}
+
+// names returns the names column i of the latest version had, newest first.
+func names(versions []*Schema, i int) []string {
+	c := versions[len(versions)-1].Columns[i]
+	out := []string{c.Name}
+	for j := len(versions) - 2; j >= 0 && c != nil; j-- {
+		name := c.Name
+		if c.RenamedFrom != "" {
+			name = c.RenamedFrom
+			out = append(out, name)
+		}
+		c = versions[j].Column(name)
+	}
+	return out
+}
+
+// sameColumns reports whether header (by name) has exactly the columns of s.
+func sameColumns(s *Schema, header map[string]int) bool {
+	if len(header) != len(s.Columns) {
+		return false
+	}
+	for _, c := range s.Columns {
+		if _, ok := header[c.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// evolved returns a registry holding three versions of tbl_members: v2 adds
+// email and renames zip, v3 drops email and renames postal_code again.
+func evolved(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	require.NoError(t, r.Register(mustParse(t, membersV1)))
+	require.NoError(t, r.Register(mustParse(t, `
+name: tbl_members
+layer: raw
+version: 2
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: last_name, type: string, phi: true}
+  - {name: postal_code, type: string, nullable: true, phi: true, renamed_from: zip}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+  - {name: email, type: string, nullable: true, phi: true}
+`)))
+	require.NoError(t, r.Register(mustParse(t, `
+name: tbl_members
+layer: raw
+version: 3
+location: raw/834/
+columns:
+  - {name: member_id, type: string, phi: true}
+  - {name: last_name, type: string, phi: true}
+  - {name: post_code, type: string, nullable: true, phi: true, renamed_from: postal_code}
+  - {name: plan_code, type: string, values: [A, B]}
+  - {name: visits, type: int, nullable: true}
+`)))
+	return r
+}
+
+func headerChanges(m *HeaderMatch) []string {
+	var out []string
+	for _, c := range m.Changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestMatchHeader(t *testing.T) {
+	r := evolved(t)
+
+	m, err := r.MatchHeader("tbl_members", []string{"member_id", "last_name", "post_code", "plan_code", "visits"})
+	require.NoError(t, err)
+	assert.True(t, m.Compatible())
+	assert.Equal(t, 3, m.Version)
+	assert.Empty(t, m.Changes)
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, m.Index)
+
+	// A v1 file, reordered and with different case and spacing.
+	m, err = r.MatchHeader("tbl_members", []string{" Plan_Code", "ZIP", "member_id", "last_name", "visits "})
+	require.NoError(t, err)
+	assert.True(t, m.Compatible())
+	assert.Equal(t, 1, m.Version)
+	assert.Equal(t, []string{"renamed post_code: header has its former name zip"}, headerChanges(m))
+	assert.Equal(t, []int{2, 3, 1, 0, 4}, m.Index)
+	assert.Equal(t, []string{"M1", "DOE", "12345", "A", "2"}, m.Row([]string{"A", "12345", "M1", "DOE", "2"}))
+
+	// A v2 file: email was dropped since.
+	m, err = r.MatchHeader("tbl_members", []string{"member_id", "last_name", "postal_code", "plan_code", "visits", "email"})
+	require.NoError(t, err)
+	assert.True(t, m.Compatible())
+	assert.Equal(t, 2, m.Version)
+	assert.Equal(t, []string{
+		"renamed post_code: header has its former name postal_code",
+		"dropped email: last in v2, ignored",
+	}, headerChanges(m))
+
+	// Optional columns may be left out.
+	m, err = r.MatchHeader("tbl_members", []string{"member_id", "last_name", "plan_code"})
+	require.NoError(t, err)
+	assert.True(t, m.Compatible())
+	assert.Zero(t, m.Version, "No version has exactly these columns")
+	assert.Equal(t, []string{
+		"missing post_code: header lacks nullable column",
+		"missing visits: header lacks nullable column",
+	}, headerChanges(m))
+	assert.Equal(t, []string{"M1", "DOE", "", "A", ""}, m.Row([]string{"M1", "DOE", "A"}))
+}
+
+func TestMatchHeaderDrift(t *testing.T) {
+	r := evolved(t)
+
+	m, err := r.MatchHeader("tbl_members", []string{"member_id", "plan_code", "plan_code", "phone", "visits"})
+	require.NoError(t, err)
+	assert.False(t, m.Compatible())
+	assert.Equal(t, []string{
+		"duplicate plan_code: header repeats column 3 (incompatible)",
+		"missing last_name: header lacks required column (incompatible)",
+		"missing post_code: header lacks nullable column",
+		"unknown phone: no version of tbl_members has this column (incompatible)",
+	}, headerChanges(m))
+
+	_, err = r.MatchHeader("tbl_unknown", []string{"a"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMatchHeaderBuiltin(t *testing.T) {
+	r, err := Builtin()
+	require.NoError(t, err)
+	v1, err := r.Get("tbl_837_raw_csv", 1)
+	require.NoError(t, err)
+
+	m, err := r.MatchHeader("tbl_837_raw_csv", v1.ColumnNames())
+	require.NoError(t, err)
+	assert.True(t, m.Compatible(), "Files written against v1 still load")
+	assert.Equal(t, 1, m.Version)
+	assert.Equal(t, []string{
+		"missing rendering_provider_npi: header lacks nullable column",
+		"renamed procedure_code: header has its former name procedure",
+	}, headerChanges(m))
+}
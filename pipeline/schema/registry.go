@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed schemas/*/*.yaml
+var schemaFiles embed.FS
+
+// ErrNotFound is returned for a schema or version the registry lacks.
+var ErrNotFound = errors.New("schema: not found")
+
+// Registry holds every version of every schema.
+type Registry struct {
+	// versions holds the versions of each schema, version 1 first.
+	versions map[string][]*Schema
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{versions: map[string][]*Schema{}}
+}
+
+// Builtin returns the registry of the schemas in schema/schemas.
+func Builtin() (*Registry, error) {
+	return Load(schemaFiles, "schemas")
+}
+
+// Load reads the schema files under dir of fsys, laid out as
+// <name>/v<version>.yaml, and registers them in version order.
+func Load(fsys fs.FS, dir string) (*Registry, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*", "v*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var all []*Schema
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		s, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		name := path.Base(path.Dir(file))
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path.Base(file), "v"), ".yaml"))
+		if err != nil || s.Name != name || s.Version != version {
+			return nil, fmt.Errorf("%s: holds %s, want %s/v<version>.yaml", file, s, s.Name)
+		}
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].Version < all[j].Version
+	})
+
+	r := NewRegistry()
+	for _, s := range all {
+		if err := r.Register(s); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds the next version of a schema, or its first. The version is
+// validated and must evolve compatibly from the previous one (see Compare);
+// its layer, file type and location may not change.
+func (r *Registry) Register(s *Schema) error {
+	if err := joinErrors(Validate(s)); err != nil {
+		return err
+	}
+	versions := r.versions[s.Name]
+	if s.Version != len(versions)+1 {
+		return &Error{Schema: s.String(), Field: "version", Msg: fmt.Sprintf("the next version is %d", len(versions)+1)}
+	}
+	if len(versions) == 0 {
+		for _, c := range s.Columns {
+			if c.RenamedFrom != "" {
+				return &Error{Schema: s.String(), Field: fmt.Sprintf("columns[%s].renamed_from", c.Name), Msg: "version 1 renames nothing"}
+			}
+		}
+		r.versions[s.Name] = []*Schema{s}
+		return nil
+	}
+
+	prev := versions[len(versions)-1]
+	var errs []*Error
+	if s.Layer != prev.Layer || s.FileType != prev.FileType || s.Location != prev.Location {
+		errs = append(errs, &Error{Schema: s.String(), Msg: fmt.Sprintf("layer, file type and location must stay %s, %q, %s", prev.Layer, prev.FileType, prev.Location)})
+	}
+	for _, c := range Incompatible(Compare(prev, s)) {
+		errs = append(errs, &Error{Schema: s.String(), Msg: fmt.Sprintf("incompatible with v%d: %s", prev.Version, c)})
+	}
+	if err := joinErrors(errs); err != nil {
+		return err
+	}
+	r.versions[s.Name] = append(versions, s)
+	return nil
+}
+
+// Get returns a version of a schema; version 0 is the latest.
+func (r *Registry) Get(name string, version int) (*Schema, error) {
+	versions := r.versions[name]
+	switch {
+	case len(versions) == 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	case version == 0:
+		return versions[len(versions)-1], nil
+	case version < 0 || version > len(versions):
+		return nil, fmt.Errorf("%w: %s v%d", ErrNotFound, name, version)
+	}
+	return versions[version-1], nil
+}
+
+// Latest returns the latest version of a schema.
+func (r *Registry) Latest(name string) (*Schema, error) {
+	return r.Get(name, 0)
+}
+
+// Versions returns every version of a schema, version 1 first.
+func (r *Registry) Versions(name string) []*Schema {
+	return r.versions[name]
+}
+
+// Names returns the schema names in order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.versions))
+	for name := range r.versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Layer returns the latest version of each schema of a layer, by name.
+func (r *Registry) Layer(layer Layer) []*Schema {
+	var out []*Schema
+	for _, name := range r.Names() {
+		if s, _ := r.Latest(name); s.Layer == layer {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ForFileType returns the latest raw schema of a file_type.
+func (r *Registry) ForFileType(fileType string) (*Schema, error) {
+	for _, s := range r.Layer(Raw) {
+		if s.FileType == fileType {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no raw schema for file type %q", ErrNotFound, fileType)
+}
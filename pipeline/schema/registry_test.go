@@ -0,0 +1,196 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltin(t *testing.T) {
+	r, err := Builtin()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"dim_diagnosis", "dim_facility", "dim_member", "dim_plan", "dim_provider",
+		"fact_claim", "fact_claim_line", "fact_eligibility", "fact_payment",
+		"tbl_834_raw_csv", "tbl_835_raw_csv", "tbl_837_raw_csv",
+		"tbl_claim_header", "tbl_claim_line", "tbl_coverage", "tbl_integrity_issue", "tbl_member", "tbl_payment", "tbl_rejected_row",
+	}, r.Names())
+
+	for _, fileType := range []string{"834", "835", "837"} {
+		s, err := r.ForFileType(fileType)
+		require.NoError(t, err, fileType)
+		assert.Equal(t, "tbl_"+fileType+"_raw_csv", s.Name)
+		assert.Equal(t, "raw/"+fileType+"/", s.Location)
+	}
+	_, err = r.ForFileType("270")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	s, err := r.Latest("tbl_837_raw_csv")
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Version)
+	assert.Equal(t, "procedure", s.Column("procedure_code").RenamedFrom)
+	v1, err := r.Get("tbl_837_raw_csv", 1)
+	require.NoError(t, err)
+	assert.NotNil(t, v1.Column("procedure"))
+	_, err = r.Get("tbl_837_raw_csv", 3)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, s := range r.Layer(Silver) {
+		assert.Equal(t, []string{"year", "month", "day"}, []string{s.Partitions[0].Name, s.Partitions[1].Name, s.Partitions[2].Name}, s.Name)
+		assert.NotEmpty(t, s.Key, s.Name)
+		assert.NotNil(t, s.Column("file_id"), s.Name)
+		assert.NotEmpty(t, s.PartitionBy, s.Name)
+	}
+	for _, s := range r.Layer(Gold) {
+		require.Len(t, s.Key, 1, s.Name)
+		assert.Equal(t, Type{Kind: Int}, s.Column(s.Key[0]).Type, "%s is keyed by its surrogate key", s.Name)
+		assert.Equal(t, "gold/"+s.Name+"/", s.Location)
+		if strings.HasPrefix(s.Name, "fact_") {
+			assert.NotEmpty(t, s.PartitionBy, s.Name)
+		}
+	}
+	member, err := r.Latest("tbl_member")
+	require.NoError(t, err)
+	assert.Equal(t, Type{Kind: Date}, member.Column("birth_date").Type)
+	assert.True(t, member.Column("birth_date").PHI)
+	assert.Contains(t, member.PHIColumns(), "last_name")
+	assert.Equal(t, "year=2025/month=03/day=01",
+		member.PartitionPath(DatePartition(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))))
+}
+
+func TestLoadChecksFileNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"s/tbl_a/v2.yaml": {Data: []byte("name: tbl_a\nlayer: raw\nversion: 1\nlocation: raw/a/\ncolumns: [{name: a, type: string}]\n")},
+	}
+	_, err := Load(fsys, "s")
+	assert.ErrorContains(t, err, "s/tbl_a/v2.yaml: holds tbl_a v1")
+}
+
+func TestRegister(t *testing.T) {
+	r := NewRegistry()
+	v1 := mustParse(t, `
+name: tbl_a
+layer: silver
+version: 1
+location: silver/a/
+key: [id]
+columns:
+  - {name: id, type: string}
+  - {name: amount, type: "decimal(12,2)"}
+partitions: [{name: year, type: string}]
+`)
+	require.NoError(t, r.Register(v1))
+
+	skip := *v1
+	skip.Version = 3
+	assert.ErrorContains(t, r.Register(&skip), "version: the next version is 2")
+
+	moved := *v1
+	moved.Version, moved.Location = 2, "silver/b/"
+	assert.ErrorContains(t, r.Register(&moved), "location must stay silver, \"\", silver/a/")
+
+	narrowed := mustParse(t, `
+name: tbl_a
+layer: silver
+version: 2
+location: silver/a/
+key: [id]
+columns:
+  - {name: id, type: string}
+  - {name: amount, type: "decimal(10,2)"}
+partitions: [{name: year, type: string}]
+`)
+	err := r.Register(narrowed)
+	assert.ErrorContains(t, err, "tbl_a v2: incompatible with v1: retyped amount: decimal(12,2) to decimal(10,2) (incompatible)")
+	assert.Len(t, r.Versions("tbl_a"), 1, "A rejected version is not registered")
+
+	renamedV1 := mustParse(t, "name: tbl_b\nlayer: raw\nversion: 1\nlocation: raw/b/\ncolumns: [{name: a, type: string, renamed_from: b}]\n")
+	assert.ErrorContains(t, r.Register(renamedV1), "version 1 renames nothing")
+}
+
+func TestValidate(t *testing.T) {
+	s := mustParse(t, `
+name: Tbl
+layer: bronze
+version: 0
+location: /raw
+key: [id, note]
+partition_by: note
+columns:
+  - {name: id, type: int, values: ["1"]}
+  - {name: id, type: string}
+  - {name: note, type: string, nullable: true}
+  - {name: Note, type: string}
+`)
+	var got []string
+	for _, e := range Validate(s) {
+		got = append(got, e.Field+": "+e.Msg)
+	}
+	assert.Equal(t, []string{
+		`name: "Tbl" is not a lower-case table name`,
+		`layer: "bronze" is not raw, silver or gold`,
+		"version: must be 1 or more",
+		`location: "/raw" is not a key prefix ending in /`,
+		"columns[id].values: only string columns are enumerated",
+		"columns[id]: duplicate column",
+		`columns[Note]: "Note" is not a lower-case column name`,
+		`partition_by: column "note" is not a required date`,
+		"partition_by: needs year, month and day string partitions",
+		`key: column "note" is nullable`,
+	}, got)
+
+	_, err := Parse([]byte("name: a\ncolumns: [{name: a, type: text}]\n"))
+	assert.ErrorContains(t, err, `line 2: unknown type "text"`)
+	_, err = Parse([]byte("name: a\ncolour: red\n"))
+	assert.ErrorContains(t, err, "field colour not found", "Unknown keys are rejected")
+	_, err = Parse(nil)
+	assert.EqualError(t, err, "schema: empty schema")
+}
+
+func TestGlueTable(t *testing.T) {
+	r, err := Builtin()
+	require.NoError(t, err)
+
+	raw, err := r.Latest("tbl_834_raw_csv")
+	require.NoError(t, err)
+	in := raw.GlueTable("claim-dev-raw")
+	assert.Equal(t, "tbl_834_raw_csv", aws.StringValue(in.Name))
+	assert.Equal(t, "EXTERNAL_TABLE", aws.StringValue(in.TableType))
+	assert.Equal(t, "s3://claim-dev-raw/raw/834/", aws.StringValue(in.StorageDescriptor.Location))
+	assert.Equal(t, "org.apache.hadoop.hive.serde2.OpenCSVSerde", aws.StringValue(in.StorageDescriptor.SerdeInfo.SerializationLibrary))
+	assert.Equal(t, "1", aws.StringValue(in.Parameters["skip.header.line.count"]))
+	assert.Equal(t, "1", aws.StringValue(in.Parameters[ParamSchemaVersion]))
+	require.Len(t, in.StorageDescriptor.Columns, len(raw.Columns))
+	birth := in.StorageDescriptor.Columns[raw.Index("birth_date")]
+	assert.Equal(t, "string", aws.StringValue(birth.Type), "OpenCSVSerde reads strings only")
+	assert.Equal(t, "true", aws.StringValue(birth.Parameters[ParamPHI]))
+	assert.Empty(t, in.PartitionKeys)
+
+	silver, err := r.Latest("tbl_claim_line")
+	require.NoError(t, err)
+	in = silver.GlueTable("claim-dev-lake")
+	assert.Equal(t, "s3://claim-dev-lake/silver/837_claim_line/", aws.StringValue(in.StorageDescriptor.Location))
+	assert.Equal(t, "parquet", aws.StringValue(in.Parameters["classification"]))
+	assert.Equal(t, "org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe", aws.StringValue(in.StorageDescriptor.SerdeInfo.SerializationLibrary))
+	types := map[string]string{}
+	for _, c := range in.StorageDescriptor.Columns {
+		types[aws.StringValue(c.Name)] = aws.StringValue(c.Type)
+	}
+	assert.Equal(t, "bigint", types["line_number"])
+	assert.Equal(t, "decimal(12,2)", types["line_charge"])
+	assert.Equal(t, "date", types["service_date"])
+	require.Len(t, in.PartitionKeys, 3)
+	assert.Equal(t, "year", aws.StringValue(in.PartitionKeys[0].Name))
+}
+
+func mustParse(t *testing.T, yaml string) *Schema {
+	t.Helper()
+	s, err := Parse([]byte(yaml))
+	require.NoError(t, err)
+	return s
+}
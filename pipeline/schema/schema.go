@@ -0,0 +1,252 @@
+// Package schema is the registry of the pipeline's CSV schemas: the raw CSVs
+// uploaded to the raw bucket (tables of claim_raw_db) and the silver entities
+// derived from them (claim_silver_db). Each version of a schema is a YAML
+// file declaring its columns, their types, nullability and PHI flag.
+//
+// The registry checks that every version evolves compatibly from the one
+// before it, matches incoming CSV headers to the latest version, and emits
+// the Glue table definition of each schema.
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layer is the lake layer a schema belongs to.
+type Layer string
+
+const (
+	Raw    Layer = "raw"
+	Silver Layer = "silver"
+	Gold   Layer = "gold"
+)
+
+// Schema is one version of a table.
+type Schema struct {
+	// Name is the Glue table name, e.g. tbl_834_raw_csv or tbl_member.
+	Name    string `yaml:"name"`
+	Layer   Layer  `yaml:"layer"`
+	Version int    `yaml:"version"`
+	// FileType is the file_type (834, 835, 837) of the raw files the table
+	// holds or is derived from.
+	FileType    string `yaml:"file_type,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// Location is the key prefix of the table's data in its bucket, e.g.
+	// raw/834/ or silver/834_member/.
+	Location string `yaml:"location"`
+	// Key is the natural key of a silver or gold row: rows with equal key
+	// values are the same entity.
+	Key     []string  `yaml:"key,omitempty"`
+	Columns []*Column `yaml:"columns"`
+	// Partitions are the partition columns of a silver or gold table, in
+	// directory order. They are not stored in the data files.
+	Partitions []*Column `yaml:"partitions,omitempty"`
+	// PartitionBy names the date column whose year, month and day fill the
+	// year, month and day partitions.
+	PartitionBy string `yaml:"partition_by,omitempty"`
+}
+
+// Column is one column of a schema.
+type Column struct {
+	Name     string `yaml:"name"`
+	Type     Type   `yaml:"type"`
+	Nullable bool   `yaml:"nullable,omitempty"`
+	// PHI marks protected health information. PHI columns are masked or
+	// hashed wherever row values leave the lake.
+	PHI bool `yaml:"phi,omitempty"`
+	// Values, if set, are the codes an enumerated string column may hold.
+	Values []string `yaml:"values,omitempty"`
+	// RenamedFrom is the column's name in the previous version.
+	RenamedFrom string `yaml:"renamed_from,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// Column returns the column named name, or nil.
+func (s *Schema) Column(name string) *Column {
+	if i := s.Index(name); i >= 0 {
+		return s.Columns[i]
+	}
+	return nil
+}
+
+// Index returns the position of the column named name, or -1.
+func (s *Schema) Index(name string) int {
+	for i, c := range s.Columns {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ColumnNames returns the column names in order.
+func (s *Schema) ColumnNames() []string {
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// PHIColumns returns the names of the PHI columns in order.
+func (s *Schema) PHIColumns() []string {
+	var names []string
+	for _, c := range s.Columns {
+		if c.PHI {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// PartitionPath returns the Hive-style directory of a row whose partition
+// columns hold values, e.g. year=2025/month=11/day=20.
+func (s *Schema) PartitionPath(values []string) string {
+	parts := make([]string, len(s.Partitions))
+	for i, c := range s.Partitions {
+		parts[i] = c.Name + "=" + values[i]
+	}
+	return strings.Join(parts, "/")
+}
+
+// DatePartition returns the year, month and day partition values of d.
+func DatePartition(d time.Time) []string {
+	return []string{d.Format("2006"), d.Format("01"), d.Format("02")}
+}
+
+func (s *Schema) String() string {
+	return fmt.Sprintf("%s v%d", s.Name, s.Version)
+}
+
+// Error is a problem with a schema file.
+type Error struct {
+	Schema string
+	// Field locates the problem, e.g. columns[member_id].type.
+	Field string
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("schema: %s: %s", e.Schema, e.Msg)
+	}
+	return fmt.Sprintf("schema: %s: %s: %s", e.Schema, e.Field, e.Msg)
+}
+
+// Parse reads a schema in YAML or JSON. Unknown keys are errors. The schema
+// is not validated; Validate and Registry.Register do that.
+func Parse(data []byte) (*Schema, error) {
+	s := &Schema{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("schema: empty schema")
+		}
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return s, nil
+}
+
+// namePattern is what Glue and Athena accept as a table or column name
+// without quoting.
+var namePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Validate returns the problems of s on its own; Registry.Register also
+// checks it against the previous version.
+func Validate(s *Schema) []*Error {
+	var errs []*Error
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, &Error{Schema: s.String(), Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+	if !namePattern.MatchString(s.Name) {
+		add("name", "%q is not a lower-case table name", s.Name)
+	}
+	switch s.Layer {
+	case Raw, Silver, Gold:
+	default:
+		add("layer", "%q is not raw, silver or gold", s.Layer)
+	}
+	if s.Version < 1 {
+		add("version", "must be 1 or more")
+	}
+	if s.Location == "" || !strings.HasSuffix(s.Location, "/") || strings.HasPrefix(s.Location, "/") {
+		add("location", "%q is not a key prefix ending in /", s.Location)
+	}
+	if len(s.Columns) == 0 {
+		add("columns", "no columns")
+	}
+	if s.Layer == Raw && (len(s.Key) > 0 || len(s.Partitions) > 0) {
+		add("", "a raw table has neither key nor partitions")
+	}
+
+	seen := map[string]bool{}
+	check := func(list string, c *Column) {
+		field := fmt.Sprintf("%s[%s]", list, c.Name)
+		switch {
+		case !namePattern.MatchString(c.Name):
+			add(field, "%q is not a lower-case column name", c.Name)
+		case seen[c.Name]:
+			add(field, "duplicate column")
+		}
+		seen[c.Name] = true
+		if c.Type.Kind == "" {
+			add(field+".type", "missing")
+		}
+		if len(c.Values) > 0 && c.Type.Kind != String {
+			add(field+".values", "only string columns are enumerated")
+		}
+		if c.RenamedFrom == c.Name && c.Name != "" {
+			add(field+".renamed_from", "names the column itself")
+		}
+	}
+	for _, c := range s.Columns {
+		check("columns", c)
+	}
+	for _, c := range s.Partitions {
+		check("partitions", c)
+		if c.Nullable {
+			add(fmt.Sprintf("partitions[%s]", c.Name), "partition columns are not nullable")
+		}
+	}
+	if s.PartitionBy != "" {
+		switch c := s.Column(s.PartitionBy); {
+		case c == nil:
+			add("partition_by", "no column %q", s.PartitionBy)
+		case c.Type.Kind != Date || c.Nullable:
+			add("partition_by", "column %q is not a required date", s.PartitionBy)
+		}
+		if partitions(s) != "[year string, month string, day string]" {
+			add("partition_by", "needs year, month and day string partitions")
+		}
+	}
+	for _, k := range s.Key {
+		switch c := s.Column(k); {
+		case c == nil:
+			add("key", "no column %q", k)
+		case c.Nullable:
+			add("key", "column %q is nullable", k)
+		}
+	}
+	return errs
+}
+
+// joinErrors returns the errors of Validate as one error, or nil.
+func joinErrors(errs []*Error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	joined := make([]error, len(errs))
+	for i, e := range errs {
+		joined[i] = e
+	}
+	return errors.Join(joined...)
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kind is the kind of a column type.
+type Kind string
+
+const (
+	String    Kind = "string"
+	Int       Kind = "int"
+	Decimal   Kind = "decimal"
+	Date      Kind = "date"
+	Timestamp Kind = "timestamp"
+	Boolean   Kind = "boolean"
+)
+
+// maxPrecision is the largest decimal precision Glue, Parquet and Redshift
+// all accept.
+const maxPrecision = 38
+
+// Type is a column type. Precision and Scale are set for decimals only:
+// money is decimal(12,2), a fixed-point number with two fractional digits.
+type Type struct {
+	Kind      Kind
+	Precision int
+	Scale     int
+}
+
+// ParseType reads a type as written in schema files: string, int,
+// decimal(p,s), date, timestamp or boolean.
+func ParseType(s string) (Type, error) {
+	s = strings.TrimSpace(s)
+	switch Kind(s) {
+	case String, Int, Date, Timestamp, Boolean:
+		return Type{Kind: Kind(s)}, nil
+	}
+	args, ok := strings.CutPrefix(s, "decimal(")
+	if ok {
+		args, ok = strings.CutSuffix(args, ")")
+	}
+	if !ok {
+		return Type{}, fmt.Errorf("unknown type %q", s)
+	}
+	p, sc, ok := strings.Cut(args, ",")
+	precision, err1 := strconv.Atoi(strings.TrimSpace(p))
+	scale, err2 := strconv.Atoi(strings.TrimSpace(sc))
+	if !ok || err1 != nil || err2 != nil {
+		return Type{}, fmt.Errorf("type %q is not decimal(precision,scale)", s)
+	}
+	if precision < 1 || precision > maxPrecision || scale < 0 || scale > precision {
+		return Type{}, fmt.Errorf("type %q needs 1 <= precision <= %d and 0 <= scale <= precision", s, maxPrecision)
+	}
+	return Type{Kind: Decimal, Precision: precision, Scale: scale}, nil
+}
+
+func (t Type) String() string {
+	if t.Kind == Decimal {
+		return fmt.Sprintf("decimal(%d,%d)", t.Precision, t.Scale)
+	}
+	return string(t.Kind)
+}
+
+// UnmarshalYAML reads a type with ParseType.
+func (t *Type) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	parsed, err := ParseType(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*t = parsed
+	return nil
+}
+
+// MarshalYAML writes a type as ParseType reads it.
+func (t Type) MarshalYAML() (interface{}, error) {
+	return t.String(), nil
+}
+
+// Widens reports whether every value of old is a value of t, so data written
+// as old reads as t: any type widens to string, int (64-bit) to a decimal
+// with at least 19 integer digits, and a decimal to one with at least as
+// many integer and fractional digits.
+func (t Type) Widens(old Type) bool {
+	switch {
+	case t == old, t.Kind == String:
+		return true
+	case t.Kind != Decimal:
+		return false
+	case old.Kind == Int:
+		return t.Precision-t.Scale >= 19
+	case old.Kind == Decimal:
+		return t.Scale >= old.Scale && t.Precision-t.Scale >= old.Precision-old.Scale
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayout is how silver and gold write dates. Raw CSVs may also write
+// them as CCYYMMDD, the X12 D8 format.
+const DateLayout = "2006-01-02"
+
+// Fixed is a fixed-point decimal: Unscaled / 10^Scale. Money is never held
+// in a float.
+type Fixed struct {
+	Unscaled int64
+	Scale    int
+}
+
+// ParseDecimal reads an optionally signed decimal number with at most scale
+// fractional digits and precision-scale integer digits, and returns it with
+// exactly scale fractional digits.
+func ParseDecimal(s string, precision, scale int) (Fixed, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" || !allDigits(whole) || !allDigits(frac) {
+		return Fixed{}, fmt.Errorf("%q is not a decimal number", s)
+	}
+	if len(frac) > scale {
+		return Fixed{}, fmt.Errorf("%q has more than %d decimal places", s, scale)
+	}
+	whole = strings.TrimLeft(whole, "0")
+	if len(whole) > precision-scale {
+		return Fixed{}, fmt.Errorf("%q has more than %d integer digits", s, precision-scale)
+	}
+	unscaled := whole + frac + strings.Repeat("0", scale-len(frac))
+	if len(strings.TrimLeft(unscaled, "0")) > 18 {
+		return Fixed{}, fmt.Errorf("%q has more than 18 significant digits", s)
+	}
+	n, _ := strconv.ParseInt("0"+unscaled, 10, 64)
+	if strings.HasPrefix(s, "-") {
+		n = -n
+	}
+	return Fixed{Unscaled: n, Scale: scale}, nil
+}
+
+func (d Fixed) String() string {
+	if d.Scale == 0 {
+		return strconv.FormatInt(d.Unscaled, 10)
+	}
+	sign, n := "", d.Unscaled
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	s := fmt.Sprintf("%0*d", d.Scale+1, n)
+	return sign + s[:len(s)-d.Scale] + "." + s[len(s)-d.Scale:]
+}
+
+// Add returns d+e; both must have the same scale.
+func (d Fixed) Add(e Fixed) Fixed {
+	return Fixed{Unscaled: d.Unscaled + e.Unscaled, Scale: d.Scale}
+}
+
+// Float returns d as a float64, for display and statistics only.
+func (d Fixed) Float() float64 {
+	return float64(d.Unscaled) / math.Pow10(d.Scale)
+}
+
+// ParseDate reads a date written as YYYY-MM-DD or CCYYMMDD.
+func ParseDate(s string) (time.Time, error) {
+	layout := DateLayout
+	if len(s) == 8 && allDigits(s) {
+		layout = "20060102"
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD or CCYYMMDD date", s)
+	}
+	return t, nil
+}
+
+// ErrRequired is returned by Column.Parse for an empty required value.
+var ErrRequired = errors.New("required value is empty")
+
+// Parse converts a CSV value to the column's type: string, int64, Fixed,
+// time.Time (a UTC date or a timestamp) or bool. An empty value is nil, or
+// ErrRequired if the column is not nullable. Enumerated columns only accept
+// their codes.
+func (c *Column) Parse(s string) (interface{}, error) {
+	if s == "" {
+		if c.Nullable {
+			return nil, nil
+		}
+		return nil, ErrRequired
+	}
+	switch c.Type.Kind {
+	case Int:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", s)
+		}
+		return n, nil
+	case Decimal:
+		return ParseDecimal(s, c.Type.Precision, c.Type.Scale)
+	case Date:
+		return ParseDate(s)
+	case Timestamp:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an RFC 3339 timestamp", s)
+		}
+		return t.UTC(), nil
+	case Boolean:
+		switch strings.ToLower(s) {
+		case "true", "t", "y", "yes", "1":
+			return true, nil
+		case "false", "f", "n", "no", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", s)
+	}
+	if len(c.Values) > 0 && !contains(c.Values, s) {
+		return nil, fmt.Errorf("%q is not one of %s", s, strings.Join(c.Values, ", "))
+	}
+	return s, nil
+}
+
+// Format writes a value returned by Parse as silver CSVs hold it: dates as
+// YYYY-MM-DD, timestamps as RFC 3339 UTC, decimals with their full scale and
+// nil as the empty string.
+func (t Type) Format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case Fixed:
+		return v.String()
+	case time.Time:
+		if t.Kind == Date {
+			return v.Format(DateLayout)
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDecimal(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"150", "150.00"},
+		{"150.5", "150.50"},
+		{"-0.07", "-0.07"},
+		{"+12.25", "12.25"},
+		{".5", "0.50"},
+		{"0000000001.00", "1.00"},
+		{"9999999999.99", "9999999999.99"},
+	}
+	for _, c := range cases {
+		d, err := ParseDecimal(c.in, 12, 2)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.want, d.String(), c.in)
+	}
+	d, _ := ParseDecimal("-12.34", 12, 2)
+	assert.Equal(t, Fixed{Unscaled: -1234, Scale: 2}, d)
+	assert.Equal(t, "-12.34", d.String())
+	assert.Equal(t, "0.66", d.Add(Fixed{Unscaled: 1300, Scale: 2}).String())
+	assert.InDelta(t, -12.34, d.Float(), 1e-9)
+
+	for in, msg := range map[string]string{
+		"15O.00":         `"15O.00" is not a decimal number`,
+		"1.005":          `"1.005" has more than 2 decimal places`,
+		"10000000000.00": `"10000000000.00" has more than 10 integer digits`,
+		"1,000.00":       `"1,000.00" is not a decimal number`,
+		".":              `"." is not a decimal number`,
+		"-":              `"-" is not a decimal number`,
+	} {
+		_, err := ParseDecimal(in, 12, 2)
+		assert.EqualError(t, err, msg, in)
+	}
+	_, err := ParseDecimal("12345678901234567890", 38, 0)
+	assert.EqualError(t, err, `"12345678901234567890" has more than 18 significant digits`)
+}
+
+func TestColumnParse(t *testing.T) {
+	col := func(typ string, nullable bool, values ...string) *Column {
+		parsed, err := ParseType(typ)
+		require.NoError(t, err)
+		return &Column{Name: "c", Type: parsed, Nullable: nullable, Values: values}
+	}
+
+	v, err := col("date", false).Parse("2025-11-03")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC), v)
+	v, err = col("date", false).Parse("20251103")
+	require.NoError(t, err, "CCYYMMDD dates are accepted")
+	assert.Equal(t, "2025-11-03", Type{Kind: Date}.Format(v))
+	_, err = col("date", false).Parse("2025-02-30")
+	assert.EqualError(t, err, `"2025-02-30" is not a YYYY-MM-DD or CCYYMMDD date`)
+
+	v, err = col("timestamp", false).Parse("2025-11-03T10:15:00-05:00")
+	require.NoError(t, err)
+	assert.Equal(t, "2025-11-03T15:15:00Z", Type{Kind: Timestamp}.Format(v))
+
+	v, err = col("int", false).Parse("-7")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-7), v)
+	_, err = col("int", false).Parse("7.0")
+	assert.EqualError(t, err, `"7.0" is not an integer`)
+
+	v, err = col("boolean", false).Parse("Y")
+	require.NoError(t, err)
+	assert.Equal(t, true, v)
+
+	_, err = col("string", false).Parse("")
+	assert.ErrorIs(t, err, ErrRequired)
+	v, err = col("decimal(12,2)", true).Parse("")
+	require.NoError(t, err)
+	assert.Nil(t, v, "An empty nullable value is null")
+	assert.Equal(t, "", Type{Kind: Decimal}.Format(v))
+
+	_, err = col("string", false, "1", "7", "8").Parse("6")
+	assert.EqualError(t, err, `"6" is not one of 1, 7, 8`)
+	v, err = col("string", false, "1", "7", "8").Parse("7")
+	require.NoError(t, err)
+	assert.Equal(t, "7", v)
+}
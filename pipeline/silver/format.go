@@ -0,0 +1,168 @@
+package silver
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/parquet"
+	"claim-management-system/pipeline/schema"
+)
+
+// Row is one row of a silver table: the values of its columns in schema
+// order, typed as schema.Column.Parse returns them.
+type Row []interface{}
+
+// Format encodes the partition files of silver tables.
+type Format interface {
+	// Name identifies the format in manifests and flags, e.g. csv.
+	Name() string
+	// Ext is the extension of partition files, e.g. .csv.
+	Ext() string
+	ContentType() string
+	Write(w io.Writer, s *schema.Schema, rows []Row) error
+	// Read decodes a partition file written by Write, possibly against an
+	// earlier version of s.
+	Read(data []byte, s *schema.Schema) ([]Row, error)
+}
+
+// CSV writes partition files as CSV with a header row, values formatted by
+// schema.Type.Format.
+type CSV struct{}
+
+func (CSV) Name() string        { return "csv" }
+func (CSV) Ext() string         { return ".csv" }
+func (CSV) ContentType() string { return "text/csv" }
+
+func (CSV) Write(w io.Writer, s *schema.Schema, rows []Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(s.ColumnNames()); err != nil {
+		return err
+	}
+	record := make([]string, len(s.Columns))
+	for _, row := range rows {
+		for i, c := range s.Columns {
+			record[i] = c.Type.Format(row[i])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Read maps the file's columns to s by name. Columns s lacks are ignored and
+// nullable columns the file lacks are nil, so files written before a
+// compatible schema change still read.
+func (CSV) Read(data []byte, s *schema.Schema) ([]Row, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := make([]int, len(s.Columns))
+	for i, c := range s.Columns {
+		index[i] = -1
+		for j, name := range header {
+			if name == c.Name {
+				index[i] = j
+			}
+		}
+		if index[i] < 0 && !c.Nullable {
+			return nil, fmt.Errorf("%s: file lacks required column %s", s, c.Name)
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(Row, len(s.Columns))
+		for i, c := range s.Columns {
+			if index[i] < 0 {
+				continue
+			}
+			if row[i], err = c.Parse(record[index[i]]); err != nil {
+				line, _ := cr.FieldPos(index[i])
+				return nil, fmt.Errorf("%s: line %d: %s: %w", s, line, c.Name, err)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// Parquet writes partition files as Snappy-compressed Parquet, the format the
+// Glue catalog declares for silver tables.
+type Parquet struct {
+	Options parquet.Options
+}
+
+func (Parquet) Name() string        { return "parquet" }
+func (Parquet) Ext() string         { return ".parquet" }
+func (Parquet) ContentType() string { return "application/vnd.apache.parquet" }
+
+func (p Parquet) Write(w io.Writer, s *schema.Schema, rows []Row) error {
+	pw := parquet.NewWriter(w, s, p.Options)
+	for _, row := range rows {
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+// Read maps the file's columns to s by name, as parquet.File.Read does.
+func (Parquet) Read(data []byte, s *schema.Schema) ([]Row, error) {
+	values, err := parquet.Read(data, s)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, len(values))
+	for i, v := range values {
+		rows[i] = v
+	}
+	return rows, nil
+}
+
+// ReadTable reads every partition file of s under bucket, in key order.
+// Files of another format than format are skipped.
+func ReadTable(ctx context.Context, objects objectstore.Store, bucket string, s *schema.Schema, format Format) ([]Row, error) {
+	keys, err := objects.List(ctx, bucket, s.Location)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for _, key := range keys {
+		if !strings.HasSuffix(key, format.Ext()) {
+			continue
+		}
+		obj, err := objects.Get(ctx, bucket, key, "")
+		if err != nil {
+			return nil, fmt.Errorf("read s3://%s/%s: %w", bucket, key, err)
+		}
+		data, err := io.ReadAll(obj.Body)
+		obj.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read s3://%s/%s: %w", bucket, key, err)
+		}
+		part, err := format.Read(data, s)
+		if err != nil {
+			return nil, fmt.Errorf("read s3://%s/%s: %w", bucket, key, err)
+		}
+		rows = append(rows, part...)
+	}
+	return rows, nil
+}
@@ -0,0 +1,106 @@
+package silver
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/parquet"
+	"claim-management-system/pipeline/schema"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	s, err := r.Latest("tbl_coverage")
+	require.NoError(t, err)
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	row := make(Row, len(s.Columns))
+	for i, c := range s.Columns {
+		switch c.Type.Kind {
+		case schema.Date:
+			row[i] = day
+		case schema.Int:
+			row[i] = int64(7)
+		case schema.Timestamp:
+			row[i] = time.Date(2025, 1, 2, 3, 4, 5, 600000000, time.UTC)
+		default:
+			if !c.Nullable {
+				row[i] = "X, \"quoted\""
+			}
+		}
+	}
+	row[s.Index("maintenance_code")] = "021"
+	row[s.Index("coverage_end")] = nil
+
+	var buf bytes.Buffer
+	require.NoError(t, CSV{}.Write(&buf, s, []Row{row}))
+	rows, err := CSV{}.Read(buf.Bytes(), s)
+	require.NoError(t, err)
+	assert.Equal(t, []Row{row}, rows)
+
+	rows, err = CSV{}.Read(nil, s)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	s, err := r.Latest("tbl_payment")
+	require.NoError(t, err)
+
+	var rows []Row
+	for i := 0; i < 5; i++ {
+		row := make(Row, len(s.Columns))
+		for j, c := range s.Columns {
+			switch c.Type.Kind {
+			case schema.Date:
+				row[j] = time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC)
+			case schema.Timestamp:
+				row[j] = time.Date(2025, 11, 21, 10, 0, i, 0, time.UTC)
+			case schema.Int:
+				row[j] = int64(i)
+			case schema.Decimal:
+				row[j] = schema.Fixed{Unscaled: int64(i*100 - 150), Scale: c.Type.Scale}
+			default:
+				if !c.Nullable || i%2 == 0 {
+					row[j] = "V"
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	p := Parquet{Options: parquet.Options{RowGroupRows: 2}}
+	require.NoError(t, p.Write(&buf, s, rows))
+	got, err := p.Read(buf.Bytes(), s)
+	require.NoError(t, err)
+	assert.Equal(t, rows, got)
+
+	f, err := parquet.Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Len(t, f.RowGroups, 3)
+}
+
+func TestCSVReadOlderFile(t *testing.T) {
+	s := &schema.Schema{Name: "tbl_a", Version: 2, Columns: []*schema.Column{
+		{Name: "id", Type: schema.Type{Kind: schema.String}},
+		{Name: "amount", Type: schema.Type{Kind: schema.Decimal, Precision: 12, Scale: 2}},
+		{Name: "note", Type: schema.Type{Kind: schema.String}, Nullable: true},
+	}}
+	rows, err := CSV{}.Read([]byte("amount,id,dropped\n1.50,A,x\n"), s)
+	require.NoError(t, err)
+	assert.Equal(t, []Row{{"A", schema.Fixed{Unscaled: 150, Scale: 2}, nil}}, rows,
+		"Columns are matched by name; the file predates note")
+
+	_, err = CSV{}.Read([]byte("id\nA\n"), s)
+	assert.EqualError(t, err, "tbl_a v2: file lacks required column amount")
+	_, err = CSV{}.Read([]byte("id,amount\nA,x\n"), s)
+	assert.EqualError(t, err, `tbl_a v2: line 2: amount: "x" is not a decimal number`)
+}
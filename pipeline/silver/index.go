@@ -0,0 +1,151 @@
+package silver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+)
+
+// IndexPrefix is where the key index of each silver table is written in the
+// lake bucket, outside every table location. For each key it names the
+// partitions that may hold its row, so a run reads only the partitions its
+// rows go to or come from instead of the whole table.
+const IndexPrefix = "silver/_index/"
+
+// indexShards is how many objects a table's index is split into by key
+// hash; a run reads only the shards of its keys.
+const indexShards = 64
+
+// keyIndex is the part of a table's key index a run has read: shard number
+// to key to partition paths.
+type keyIndex struct {
+	schema *schema.Schema
+	shards map[int]map[string][]string
+	dirty  map[int]bool
+}
+
+func shardOf(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % indexShards)
+}
+
+func (x *keyIndex) key(shard int) string {
+	return fmt.Sprintf("%s%s/%02d.json", IndexPrefix, x.schema.Name, shard)
+}
+
+// paths returns the partitions that may hold key.
+func (x *keyIndex) paths(key string) []string {
+	return x.shards[shardOf(key)][key]
+}
+
+// set records that key is held by paths.
+func (x *keyIndex) set(key string, paths ...string) {
+	shard := shardOf(key)
+	if x.shards[shard] == nil {
+		x.shards[shard] = map[string][]string{}
+	}
+	x.shards[shard][key] = paths
+	x.dirty[shard] = true
+}
+
+// index reads the shards of s's key index that hold keys. A table without
+// an index, written before there was one, is indexed from its partition
+// files once.
+func (r *run) index(ctx context.Context, s *schema.Schema, keys []string) (*keyIndex, error) {
+	x := &keyIndex{schema: s, shards: map[int]map[string][]string{}, dirty: map[int]bool{}}
+	prefix := IndexPrefix + s.Name + "/"
+	objects, err := r.Objects.List(ctx, r.Bucket, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("list s3://%s/%s: %w", r.Bucket, prefix, err)
+	}
+	if len(objects) == 0 {
+		parts, err := r.partitions(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range sortedKeys(parts) {
+			for _, row := range parts[path] {
+				k := KeyOf(s, row)
+				x.set(k, appendPath(x.paths(k), path)...)
+			}
+		}
+		return x, nil
+	}
+
+	for _, k := range keys {
+		shard := shardOf(k)
+		if _, ok := x.shards[shard]; ok {
+			continue
+		}
+		entries := map[string][]string{}
+		data, err := r.get(ctx, x.key(shard))
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return nil, fmt.Errorf("read s3://%s/%s: %w", r.Bucket, x.key(shard), err)
+			}
+		}
+		x.shards[shard] = entries
+	}
+	return x, nil
+}
+
+// save writes the shards changed since the last save.
+func (r *run) save(ctx context.Context, x *keyIndex) error {
+	shards := make([]int, 0, len(x.dirty))
+	for shard := range x.dirty {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	for _, shard := range shards {
+		body, err := json.Marshal(x.shards[shard])
+		if err != nil {
+			return err
+		}
+		key := x.key(shard)
+		if _, err := r.Objects.Put(ctx, r.Bucket, key, bytes.NewReader(body), objectstore.PutOptions{ContentType: "application/json"}); err != nil {
+			return fmt.Errorf("write s3://%s/%s: %w", r.Bucket, key, err)
+		}
+	}
+	x.dirty = map[int]bool{}
+	return nil
+}
+
+// get returns the content of key in the lake bucket, or nil if there is no
+// such object.
+func (r *run) get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := r.Objects.Get(ctx, r.Bucket, key, "")
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	defer obj.Body.Close()
+	data, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	return data, nil
+}
+
+// appendPath adds path to paths unless it is there already.
+func appendPath(paths []string, path string) []string {
+	for _, p := range paths {
+		if p == path {
+			return paths
+		}
+	}
+	return append(append([]string(nil), paths...), path)
+}
@@ -0,0 +1,90 @@
+package silver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"claim-management-system/pipeline/objectstore"
+)
+
+// ManifestPrefix is where run manifests are written in the lake bucket,
+// outside every table location.
+const ManifestPrefix = "silver/_manifests/"
+
+// Manifest records what one transformer run read and wrote.
+type Manifest struct {
+	RunID      string         `json:"run_id"`
+	StartedAt  string         `json:"started_at"`
+	FinishedAt string         `json:"finished_at"`
+	Format     string         `json:"format"`
+	Files      []*FileResult  `json:"files"`
+	Tables     []*TableResult `json:"tables"`
+}
+
+// ReadManifest reads the manifest of run id from the lake bucket.
+func ReadManifest(ctx context.Context, objects objectstore.Store, bucket, id string) (*Manifest, error) {
+	key := ManifestPrefix + id + ".json"
+	obj, err := objects.Get(ctx, bucket, key, "")
+	if err != nil {
+		return nil, fmt.Errorf("read manifest s3://%s/%s: %w", bucket, key, err)
+	}
+	defer obj.Body.Close()
+	m := &Manifest{}
+	if err := json.NewDecoder(obj.Body).Decode(m); err != nil {
+		return nil, fmt.Errorf("decode manifest s3://%s/%s: %w", bucket, key, err)
+	}
+	return m, nil
+}
+
+// FileResult is what a run read from one raw file.
+type FileResult struct {
+	FileID       string `json:"file_id"`
+	FileType     string `json:"file_type"`
+	SourceSystem string `json:"source_system,omitempty"`
+	// Object is the s3:// URI of the raw object.
+	Object string `json:"object"`
+	// Schema is the raw schema the rows were read with, e.g. tbl_837_raw_csv v2.
+	Schema string `json:"schema,omitempty"`
+	// Rows counts data rows; RejectedRows those that could not be converted.
+	Rows         int64 `json:"rows"`
+	RejectedRows int64 `json:"rejected_rows"`
+	// RejectedByRule counts rejected rows by the first rule each broke, and
+	// ErrorObject is the s3:// URI of the tbl_rejected_row partition file
+	// holding them.
+	RejectedByRule map[string]int64 `json:"rejected_by_rule,omitempty"`
+	ErrorObject    string           `json:"error_object,omitempty"`
+	// Error says why the file was not loaded; its record is marked FAILED.
+	Error string `json:"error,omitempty"`
+}
+
+// TableResult is what a run wrote to one silver table.
+type TableResult struct {
+	Table   string `json:"table"`
+	Version int    `json:"version"`
+	// RowsIn counts the rows the run's files produced for the table.
+	RowsIn int64 `json:"rows_in"`
+	// Duplicates counts rows dropped because another row with the same key,
+	// from this run or already in the table, was ingested later.
+	Duplicates int64              `json:"duplicates"`
+	Partitions []*PartitionResult `json:"partitions"`
+	// CatalogPartitions counts the partitions newly added to the Glue
+	// catalog.
+	CatalogPartitions int `json:"catalog_partitions,omitempty"`
+}
+
+// PartitionResult is one partition file a run rewrote.
+type PartitionResult struct {
+	// Path is the partition directory, e.g. year=2025/month=11/day=20.
+	Path      string `json:"path"`
+	Object    string `json:"object"`
+	VersionID string `json:"version_id,omitempty"`
+	// Rows counts the rows of the file as written; Inserted the keys that
+	// were new to it and Updated the rows the run replaced. Moved counts
+	// rows removed because a newer row with their key is in another
+	// partition, e.g. a claim resent with a corrected service date.
+	Rows     int64 `json:"rows"`
+	Inserted int64 `json:"inserted"`
+	Updated  int64 `json:"updated"`
+	Moved    int64 `json:"moved,omitempty"`
+}
@@ -0,0 +1,141 @@
+package silver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/schema"
+)
+
+// RejectsTable is the silver table every rejected raw row is written to,
+// partitioned by the ingest date of its file.
+const RejectsTable = "tbl_rejected_row"
+
+// RuleConvert is the rule of a row that passed validation but whose values
+// do not convert to a silver table, e.g. a derived column.
+const RuleConvert = "convert"
+
+// rejection is one rejected raw row and the first rule it broke.
+type rejection struct {
+	row        int64
+	line       int
+	err        metadata.RowError
+	violations int
+	// values is the JSON object of raw_values, or empty if the row could not
+	// be split into fields.
+	values string
+}
+
+// rejectsRun returns the run's tableRun of RejectsTable, creating it with
+// the first rejection.
+func (r *run) rejectsRun() (*tableRun, error) {
+	if tr, ok := r.tables[RejectsTable]; ok {
+		return tr, nil
+	}
+	s, err := r.Schemas.Latest(RejectsTable)
+	if err != nil {
+		return nil, err
+	}
+	// Every column of the table is built from a value of the same name.
+	t, err := newTable(s, s)
+	if err != nil {
+		return nil, err
+	}
+	tr := &tableRun{table: t, res: &TableResult{Table: s.Name, Version: s.Version}, parts: map[string]map[string]Row{}}
+	r.tables[RejectsTable] = tr
+	return tr, nil
+}
+
+// reject adds rj, a row of the raw file of rec read with raw, to the rejects
+// table and counts it in res.
+func (r *run) reject(rec *metadata.FileRecord, raw *schema.Schema, res *FileResult, rj *rejection) error {
+	res.RejectedRows++
+	if res.RejectedByRule == nil {
+		res.RejectedByRule = map[string]int64{}
+	}
+	res.RejectedByRule[rj.err.Rule]++
+
+	tr, err := r.rejectsRun()
+	if err != nil {
+		return err
+	}
+	ingested, err := time.Parse(time.RFC3339, rec.IngestTime)
+	if err != nil {
+		return fmt.Errorf("silver: %s: ingest_time %q: %w", rec.FileID, rec.IngestTime, err)
+	}
+	values := map[string]string{
+		"file_type":   rec.FileType,
+		"raw_schema":  raw.String(),
+		"line":        strconv.Itoa(rj.line),
+		"rule":        rj.err.Rule,
+		"column":      rj.err.Column,
+		"message":     rj.err.Msg,
+		"violations":  strconv.Itoa(rj.violations),
+		"raw_values":  rj.values,
+		"run_id":      r.ID,
+		"rejected_at": metadata.FormatTime(r.now()),
+		"ingest_date": ingested.UTC().Format(schema.DateLayout),
+	}
+	src := &source{rec: rec, raw: tr.schema, row: rj.row, values: make([]string, len(tr.schema.Columns))}
+	for i, c := range tr.schema.Columns {
+		src.values[i] = values[c.Name]
+	}
+	row, rowErr := tr.build(src)
+	if rowErr != nil {
+		return fmt.Errorf("silver: %s row %d: %s: %s", RejectsTable, rj.row, rowErr.Column, rowErr.Msg)
+	}
+	tr.add(row)
+	return nil
+}
+
+// rawValues returns the JSON object of a header-ordered record by column
+// name. Only values of non-PHI columns of the latest schema appear as they
+// are; PHI values, and values of columns the schema has dropped or fields
+// past the header, are hashed with key.
+func rawValues(m *schema.HeaderMatch, header, record []string, key []byte) string {
+	columns := make(map[int]*schema.Column, len(m.Index))
+	for i, at := range m.Index {
+		if at >= 0 {
+			columns[at] = m.Schema.Columns[i]
+		}
+	}
+	values := make(map[string]string, len(record))
+	for i, v := range record {
+		c := columns[i]
+		name := fmt.Sprintf("_field_%d", i+1)
+		switch {
+		case c != nil:
+			name = c.Name
+		case i < len(header):
+			name = strings.TrimSpace(header[i])
+		}
+		if c == nil || c.PHI {
+			v = HashValue(key, v)
+		}
+		values[name] = v
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// HashValue hides a PHI value while keeping equal values equal: HMAC-SHA256
+// with key, or plain SHA-256 if key is empty. Empty values stay empty.
+func HashValue(key []byte, v string) string {
+	if v == "" {
+		return ""
+	}
+	if len(key) == 0 {
+		sum := sha256.Sum256([]byte(v))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(v))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,495 @@
+// Package silver is the bronze-to-silver transformer. A run loads the raw
+// CSVs marked VALIDATED into the silver tables of the schema registry:
+// values are converted to their column types, rows are deduplicated by the
+// table's natural key, and each year/month/day partition the run touches is
+// rewritten as one file under the lake bucket. With a catalog, tables and
+// new partitions are registered in Glue. A manifest records what the run
+// read and wrote, and each file is marked TRANSFORMED.
+package silver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/validation"
+)
+
+// PartFile is the name of the single data file of each partition, before
+// the format's extension. The store cannot delete, so a partition is
+// rewritten in place rather than extended with new files.
+const PartFile = "part-00000"
+
+// LockName is the lock a run holds while it runs.
+const LockName = "silver-transform"
+
+// DefaultLockTTL is the lease of the run lock when LockTTL is zero.
+const DefaultLockTTL = time.Hour
+
+// Transformer loads validated raw files into silver.
+type Transformer struct {
+	Objects  objectstore.Store
+	Metadata metadata.Store
+	Schemas  *schema.Registry
+	// Locks holds LockName for the run, usually in the file-metadata table:
+	// each run rewrites the partitions it touches, so runs must not overlap.
+	// The lease lasts LockTTL and is renewed before each table is written; a
+	// run that cannot take or renew it fails.
+	Locks   metadata.Locker
+	LockTTL time.Duration
+	// Bucket is the lake bucket silver tables and manifests are written to.
+	Bucket string
+	// Format encodes partition files; nil means Parquet with default
+	// options. A table's files must all have the same format.
+	Format Format
+	// ID names the run in its manifest and on each record; Run derives one
+	// from the clock when empty.
+	ID string
+	// MaxFiles bounds the files one run loads. Zero means no limit.
+	MaxFiles int
+	// PHIKey keys the HMAC that hashes PHI in the raw values of rejected
+	// rows; empty means plain SHA-256, which does not hide short values
+	// such as dates from a determined reader.
+	PHIKey []byte
+	// Catalog, if set, syncs each table the run writes to Glue before
+	// anything is written, and adds the partitions it wrote. The catalog
+	// declares Parquet, so it needs the Parquet format.
+	Catalog *catalog.Catalog
+
+	Logger *log.Logger
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// errMaxReached stops the query once MaxFiles were selected.
+var errMaxReached = errors.New("silver: max files reached")
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Run loads every VALIDATED file, oldest update first, and returns the
+// manifest it wrote. Files that cannot be loaded, because their object is
+// gone or no longer matches its schema, are marked FAILED; any other error,
+// a catalog conflict included, stops the run before anything is written.
+func (t *Transformer) Run(ctx context.Context) (*Manifest, error) {
+	start := t.now()
+	if t.ID == "" {
+		t.ID = "silver-" + start.UTC().Format("20060102T150405Z")
+	}
+	format := t.format()
+	m := &Manifest{RunID: t.ID, StartedAt: metadata.FormatTime(start), Format: format.Name()}
+
+	if t.Locks == nil {
+		return nil, errors.New("silver: no lock to keep runs from overlapping")
+	}
+	if err := t.Locks.Lock(ctx, LockName, t.ID, t.lockTTL()); err != nil {
+		return nil, fmt.Errorf("silver: %w", err)
+	}
+	defer func() {
+		if err := t.Locks.Unlock(context.WithoutCancel(ctx), LockName, t.ID); err != nil {
+			t.logf("%s: %v", t.ID, err)
+		}
+	}()
+
+	var recs []*metadata.FileRecord
+	err := metadata.QueryAll(ctx, t.Metadata, metadata.Query{Status: metadata.StatusValidated}, func(entry *metadata.FileRecord) error {
+		if t.MaxFiles > 0 && len(recs) == t.MaxFiles {
+			return errMaxReached
+		}
+		// Index entries carry only projected attributes, and the record is
+		// written back whole.
+		rec, err := t.Metadata.Get(ctx, entry.FileID)
+		if err != nil {
+			return fmt.Errorf("silver: get %s: %w", entry.FileID, err)
+		}
+		recs = append(recs, rec)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errMaxReached) {
+		return nil, err
+	}
+
+	r := &run{Transformer: t, targets: map[string][]*tableRun{}, tables: map[string]*tableRun{}}
+	for _, rec := range recs {
+		res, err := r.load(ctx, rec)
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, res)
+	}
+
+	names := make([]string, 0, len(r.tables))
+	for name := range r.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if t.Catalog != nil {
+		if format.Name() != (Parquet{}).Name() {
+			return nil, fmt.Errorf("silver: the Glue catalog declares Parquet, not %s", format.Name())
+		}
+		for _, name := range names {
+			if _, err := t.Catalog.SyncTable(ctx, r.tables[name].schema); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range names {
+		tr := r.tables[name]
+		if err := t.Locks.Lock(ctx, LockName, t.ID, t.lockTTL()); err != nil {
+			return nil, fmt.Errorf("silver: renew: %w", err)
+		}
+		if err := r.write(ctx, tr); err != nil {
+			return nil, err
+		}
+		if t.Catalog != nil {
+			paths := make([]string, len(tr.res.Partitions))
+			for i, p := range tr.res.Partitions {
+				paths[i] = p.Path
+			}
+			if tr.res.CatalogPartitions, err = t.Catalog.AddPartitions(ctx, tr.schema, paths); err != nil {
+				return nil, err
+			}
+		}
+		m.Tables = append(m.Tables, tr.res)
+	}
+
+	if tr, ok := r.tables[RejectsTable]; ok {
+		objects := map[string]string{}
+		for _, p := range tr.res.Partitions {
+			objects[p.Path] = p.Object
+		}
+		for i, res := range m.Files {
+			if res.RejectedRows > 0 {
+				ingested, _ := time.Parse(time.RFC3339, recs[i].IngestTime)
+				res.ErrorObject = objects[tr.schema.PartitionPath(schema.DatePartition(ingested.UTC()))]
+			}
+		}
+	}
+
+	manifest := ManifestPrefix + t.ID + ".json"
+	m.FinishedAt = metadata.FormatTime(t.now())
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.Objects.Put(ctx, t.Bucket, manifest, bytes.NewReader(body), objectstore.PutOptions{ContentType: "application/json"}); err != nil {
+		return nil, fmt.Errorf("write manifest s3://%s/%s: %w", t.Bucket, manifest, err)
+	}
+
+	for i, rec := range recs {
+		res := m.Files[i]
+		if res.Error != "" {
+			rec.Transition(metadata.StatusFailed, res.Error, t.now())
+		} else {
+			rec.Silver = &metadata.SilverLoad{
+				RunID:          t.ID,
+				Manifest:       fmt.Sprintf("s3://%s/%s", t.Bucket, manifest),
+				Rows:           res.Rows,
+				RejectedRows:   res.RejectedRows,
+				RejectedByRule: res.RejectedByRule,
+				ErrorObject:    res.ErrorObject,
+				LoadedAt:       m.FinishedAt,
+			}
+			rec.Transition(metadata.StatusTransformed, "silver run "+t.ID, t.now())
+		}
+		if err := t.Metadata.Put(ctx, rec); err != nil {
+			return m, fmt.Errorf("update %s: %w", rec.FileID, err)
+		}
+	}
+	t.logf("%s: %d files, %d tables", t.ID, len(m.Files), len(m.Tables))
+	return m, nil
+}
+
+type run struct {
+	*Transformer
+	// targets are the tables fed by each file_type, tables the same by name.
+	targets map[string][]*tableRun
+	tables  map[string]*tableRun
+}
+
+// targetsFor returns the latest raw schema of fileType and the silver tables
+// derived from it.
+func (r *run) targetsFor(fileType string) (*schema.Schema, []*tableRun, error) {
+	raw, err := r.Schemas.ForFileType(fileType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if trs, ok := r.targets[fileType]; ok {
+		return raw, trs, nil
+	}
+	var trs []*tableRun
+	for _, s := range r.Schemas.Layer(schema.Silver) {
+		if s.FileType != fileType {
+			continue
+		}
+		t, err := newTable(raw, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		tr := &tableRun{table: t, res: &TableResult{Table: s.Name, Version: s.Version}, parts: map[string]map[string]Row{}}
+		trs = append(trs, tr)
+		r.tables[s.Name] = tr
+	}
+	r.targets[fileType] = trs
+	return raw, trs, nil
+}
+
+// load reads the raw object of rec into the run's tables.
+func (r *run) load(ctx context.Context, rec *metadata.FileRecord) (*FileResult, error) {
+	res := &FileResult{
+		FileID:       rec.FileID,
+		FileType:     rec.FileType,
+		SourceSystem: rec.SourceSystem,
+		Object:       fmt.Sprintf("s3://%s/%s", rec.Bucket, rec.Key),
+	}
+	raw, trs, err := r.targetsFor(rec.FileType)
+	if errors.Is(err, schema.ErrNotFound) {
+		res.Error = fmt.Sprintf("no schema is registered for file type %q", rec.FileType)
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Schema = raw.String()
+
+	obj, err := r.Objects.Get(ctx, rec.Bucket, rec.Key, rec.VersionID)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		res.Error = "object not found"
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", res.Object, err)
+	}
+	defer obj.Body.Close()
+
+	br := bufio.NewReader(obj.Body)
+	if head, _ := br.Peek(len(utf8BOM)); bytes.Equal(head, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if err == io.EOF || errors.As(err, &parseErr) {
+			res.Error = "file has no header"
+			return res, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", res.Object, err)
+	}
+	match, err := r.Schemas.MatchHeader(raw.Name, header)
+	if err != nil {
+		return nil, err
+	}
+	if !match.Compatible() {
+		var bad []string
+		for _, ch := range schema.Incompatible(match.Changes) {
+			bad = append(bad, ch.String())
+		}
+		res.Error = fmt.Sprintf("header does not match %s: %s", raw, strings.Join(bad, "; "))
+		return res, nil
+	}
+
+	folds := make([]*fold, len(trs))
+	for i, tr := range trs {
+		folds[i] = newFold(tr.table)
+	}
+	rows := make([]Row, len(trs))
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		res.Rows++
+		rj := &rejection{row: res.Rows, violations: 1}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			rj.line = parseErr.StartLine
+			rj.err = metadata.RowError{Rule: validation.RuleMalformed, Msg: parseErr.Err.Error()}
+		case err != nil:
+			return nil, fmt.Errorf("read %s: %w", res.Object, err)
+		default:
+			rj.line, _ = cr.FieldPos(0)
+			rj.values = rawValues(match, header, record, r.PHIKey)
+		}
+		if rj.err.Rule == "" && len(record) != len(header) {
+			rj.err = metadata.RowError{Rule: validation.RuleFieldCount, Msg: fmt.Sprintf("row has %d fields, header has %d", len(record), len(header))}
+		}
+		if rj.err.Rule == "" && !validation.ValidUTF8(record) {
+			rj.err = metadata.RowError{Rule: validation.RuleMalformed, Msg: "row is not valid UTF-8"}
+		}
+		var src *source
+		if rj.err.Rule == "" {
+			src = &source{rec: rec, raw: raw, row: res.Rows, values: match.Row(record)}
+			if errs := validation.CheckRow(raw, src.values, nil); len(errs) > 0 {
+				rj.err, rj.violations = errs[0], len(errs)
+			}
+		}
+		for i := 0; rj.err.Rule == "" && i < len(trs); i++ {
+			if row, rowErr := trs[i].build(src); rowErr != nil {
+				rj.err = *rowErr
+			} else {
+				rows[i] = row
+			}
+		}
+		if rj.err.Rule != "" {
+			if err := r.reject(rec, raw, res, rj); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for i, f := range folds {
+			f.add(rows[i], src)
+		}
+	}
+
+	for i, f := range folds {
+		trs[i].res.Duplicates += f.duplicates
+		for _, k := range f.order {
+			trs[i].add(f.rows[k])
+		}
+	}
+	return res, nil
+}
+
+// write merges each partition the run touched with the file already there
+// and rewrites it. A key lives in one partition, so a row whose partition
+// column changed replaces the row in its old partition, which is rewritten
+// without it. The table's key index names those old partitions; only they
+// and the run's own partitions are read.
+//
+// The index is written twice: before the partitions, naming both the old
+// and the new partition of each key, and after, naming only the new one. A
+// run that stops between the writes leaves an index that names too many
+// partitions, never too few.
+func (r *run) write(ctx context.Context, tr *tableRun) error {
+	format := r.format()
+	keys := tr.keys()
+	index, err := r.index(ctx, tr.schema, keys)
+	if err != nil {
+		return err
+	}
+	existing := map[string][]Row{}
+	read := func(path string) error {
+		if _, ok := existing[path]; ok {
+			return nil
+		}
+		rows, err := r.partition(ctx, tr.schema, path)
+		existing[path] = rows
+		return err
+	}
+	for _, path := range sortedKeys(tr.parts) {
+		if err := read(path); err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		for _, path := range index.paths(k) {
+			if err := read(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	paths := tr.place(existing)
+	for _, k := range sortedKeys(tr.home) {
+		index.set(k, appendPath(index.paths(k), tr.home[k])...)
+	}
+	if err := r.save(ctx, index); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		key := tr.schema.Location + path + "/" + PartFile + format.Ext()
+		res := &PartitionResult{Path: path, Object: fmt.Sprintf("s3://%s/%s", r.Bucket, key)}
+		var buf bytes.Buffer
+		if err := format.Write(&buf, tr.schema, tr.merge(path, existing[path], res)); err != nil {
+			return fmt.Errorf("encode %s: %w", res.Object, err)
+		}
+		res.VersionID, err = r.Objects.Put(ctx, r.Bucket, key, &buf, objectstore.PutOptions{ContentType: format.ContentType()})
+		if err != nil {
+			return fmt.Errorf("write %s: %w", res.Object, err)
+		}
+		tr.res.Partitions = append(tr.res.Partitions, res)
+	}
+	for k, path := range tr.home {
+		index.set(k, path)
+	}
+	return r.save(ctx, index)
+}
+
+// partition reads the partition file at path of s; a partition without a
+// file has no rows.
+func (r *run) partition(ctx context.Context, s *schema.Schema, path string) ([]Row, error) {
+	key := s.Location + path + "/" + PartFile + r.format().Ext()
+	data, err := r.get(ctx, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	rows, err := r.format().Read(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("read s3://%s/%s: %w", r.Bucket, key, err)
+	}
+	return rows, nil
+}
+
+// partitions reads every partition file of s by partition path, which only
+// indexing a table written before the key index needs.
+func (r *run) partitions(ctx context.Context, s *schema.Schema) (map[string][]Row, error) {
+	suffix := "/" + PartFile + r.format().Ext()
+	keys, err := r.Objects.List(ctx, r.Bucket, s.Location)
+	if err != nil {
+		return nil, fmt.Errorf("list s3://%s/%s: %w", r.Bucket, s.Location, err)
+	}
+	parts := map[string][]Row{}
+	for _, key := range keys {
+		path, ok := strings.CutSuffix(strings.TrimPrefix(key, s.Location), suffix)
+		if !ok {
+			continue
+		}
+		if parts[path], err = r.partition(ctx, s, path); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+func (t *Transformer) format() Format {
+	if t.Format == nil {
+		return Parquet{}
+	}
+	return t.Format
+}
+
+func (t *Transformer) lockTTL() time.Duration {
+	if t.LockTTL == 0 {
+		return DefaultLockTTL
+	}
+	return t.LockTTL
+}
+
+func (t *Transformer) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+func (t *Transformer) logf(format string, args ...interface{}) {
+	if t.Logger != nil {
+		t.Logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,570 @@
+package silver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/catalog"
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/silver/silvertest"
+)
+
+const (
+	rawBucket  = "claim-dev-raw"
+	lakeBucket = "claim-dev-lake"
+)
+
+const claimHeader = "claim_id,claim_frequency,claim_type,member_id,billing_provider_npi,rendering_provider_npi,payer_id,facility_type,total_charge,service_from,service_to,principal_diagnosis,other_diagnoses,line_number,procedure_code,modifiers,revenue_code,line_charge,units,line_service_date\n"
+
+const paymentHeader = "trace_number,payment_date,payment_method,payer_id,payee_npi,claim_id,payer_claim_id,claim_status,member_id,billed_amount,paid_amount,patient_responsibility\n"
+
+type fixture struct {
+	*silvertest.Lake
+	store *metadata.MemoryStore
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	return &fixture{Lake: silvertest.New(t, lakeBucket), store: metadata.NewMemoryStore()}
+}
+
+// validated uploads body and records it as a VALIDATED file ingested at
+// ingested.
+func (f *fixture) validated(t *testing.T, fileType, key, body string, ingested time.Time) *metadata.FileRecord {
+	t.Helper()
+	ctx := context.Background()
+	version, err := f.Objects.Put(ctx, rawBucket, key, strings.NewReader(body), objectstore.PutOptions{})
+	require.NoError(t, err)
+	rec := &metadata.FileRecord{
+		FileID:       metadata.NewFileID(rawBucket, key, version),
+		FileType:     fileType,
+		SourceSystem: "clearinghouse",
+		Bucket:       rawBucket,
+		Key:          key,
+		VersionID:    version,
+		IngestTime:   metadata.FormatTime(ingested),
+	}
+	rec.Transition(metadata.StatusValidated, "", ingested)
+	require.NoError(t, f.store.Create(ctx, rec))
+	return rec
+}
+
+func (f *fixture) run(t *testing.T, id string) *Manifest {
+	t.Helper()
+	tr := &Transformer{
+		Objects:  f.Objects,
+		Metadata: f.store,
+		Locks:    f.store,
+		Schemas:  f.Schemas,
+		Bucket:   lakeBucket,
+		Format:   CSV{},
+		ID:       id,
+		Now:      func() time.Time { return time.Date(2025, 11, 22, 6, 0, 0, 0, time.UTC) },
+	}
+	m, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	return m
+}
+
+// paid returns claim_id: paid_amount of a tbl_payment partition file.
+func (f *fixture) paid(t *testing.T, key string) map[string]string {
+	t.Helper()
+	s, err := f.Schemas.Latest("tbl_payment")
+	require.NoError(t, err)
+	rows, err := CSV{}.Read(f.Read(t, key), s)
+	require.NoError(t, err)
+	out := map[string]string{}
+	for _, row := range rows {
+		out[row[s.Index("claim_id")].(string)] = row[s.Index("paid_amount")].(schema.Fixed).String()
+	}
+	return out
+}
+
+func TestRunLoadsClaims(t *testing.T) {
+	f := newFixture(t)
+	ingested := time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC)
+	rec := f.validated(t, "837", "raw/837/clearinghouse/claims.csv", claimHeader+
+		"PCN1,1,P,MBR1,1234567893,,PAYER1,11,150.00,20251120,,E119,,1,99213,25,,100.00,1,2025-11-20\n"+
+		"PCN1,1,P,MBR1,1234567893,,PAYER1,11,150.00,20251120,,E119,,2,85025,,,50.00,1,\n"+
+		"PCN2,1,P,MBR2,1234567893,,PAYER1,11,80.00,2025-11-21,,J449,,1,99212,,,80.00,,2025-11-21\n",
+		ingested)
+
+	m := f.run(t, "silver-test")
+	assert.Equal(t, "csv", m.Format)
+	assert.Equal(t, []*FileResult{{
+		FileID: rec.FileID, FileType: "837", SourceSystem: "clearinghouse",
+		Object: "s3://claim-dev-raw/raw/837/clearinghouse/claims.csv", Schema: "tbl_837_raw_csv v2", Rows: 3,
+	}}, m.Files)
+	require.Len(t, m.Tables, 2)
+	assert.Equal(t, "tbl_claim_header", m.Tables[0].Table)
+	assert.Equal(t, int64(2), m.Tables[0].RowsIn, "Lines fold into their claim")
+	assert.Zero(t, m.Tables[0].Duplicates)
+	assert.Equal(t, []*PartitionResult{
+		{Path: "year=2025/month=11/day=20", Object: "s3://claim-dev-lake/silver/837_claim_header/year=2025/month=11/day=20/part-00000.csv",
+			VersionID: m.Tables[0].Partitions[0].VersionID, Rows: 1, Inserted: 1},
+		{Path: "year=2025/month=11/day=21", Object: "s3://claim-dev-lake/silver/837_claim_header/year=2025/month=11/day=21/part-00000.csv",
+			VersionID: m.Tables[0].Partitions[1].VersionID, Rows: 1, Inserted: 1},
+	}, m.Tables[0].Partitions)
+	assert.Equal(t, "tbl_claim_line", m.Tables[1].Table)
+	assert.Equal(t, int64(3), m.Tables[1].RowsIn)
+
+	assert.Equal(t,
+		"claim_id,claim_frequency,claim_type,member_id,billing_provider_npi,rendering_provider_npi,payer_id,facility_type,total_charge,service_from,service_to,principal_diagnosis,other_diagnoses,line_count,file_id,source_system,source_row,ingested_at\n"+
+			"PCN1,1,P,MBR1,1234567893,,PAYER1,11,150.00,2025-11-20,,E119,,2,"+rec.FileID+",clearinghouse,2,2025-11-21T10:00:00Z\n",
+		string(f.Read(t, "silver/837_claim_header/year=2025/month=11/day=20/part-00000.csv")))
+	assert.Equal(t,
+		"claim_id,claim_frequency,line_number,procedure_code,modifiers,revenue_code,line_charge,units,service_date,file_id,source_system,source_row,ingested_at\n"+
+			"PCN1,1,1,99213,25,,100.00,1.000,2025-11-20,"+rec.FileID+",clearinghouse,1,2025-11-21T10:00:00Z\n"+
+			"PCN1,1,2,85025,,,50.00,1.000,2025-11-20,"+rec.FileID+",clearinghouse,2,2025-11-21T10:00:00Z\n",
+		string(f.Read(t, "silver/837_claim_line/year=2025/month=11/day=20/part-00000.csv")),
+		"A line without a service date takes the claim's")
+
+	var stored Manifest
+	require.NoError(t, json.Unmarshal(f.Read(t, "silver/_manifests/silver-test.json"), &stored))
+	assert.Equal(t, m, &stored)
+	read, err := ReadManifest(context.Background(), f.Objects, lakeBucket, "silver-test")
+	require.NoError(t, err)
+	assert.Equal(t, m, read)
+	headers, err := f.Schemas.Latest("tbl_claim_header")
+	require.NoError(t, err)
+	rows, err := ReadTable(context.Background(), f.Objects, lakeBucket, headers, CSV{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2, "Every partition is read")
+	assert.Equal(t, "PCN2", rows[1][0])
+	rows, err = ReadTable(context.Background(), f.Objects, lakeBucket, headers, Parquet{})
+	require.NoError(t, err)
+	assert.Empty(t, rows, "Files of another format are skipped")
+
+	got, err := f.store.Get(context.Background(), rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusTransformed, got.Status)
+	assert.Equal(t, &metadata.SilverLoad{
+		RunID: "silver-test", Manifest: "s3://claim-dev-lake/silver/_manifests/silver-test.json",
+		Rows: 3, LoadedAt: "2025-11-22T06:00:00.000Z",
+	}, got.Silver)
+
+	m = f.run(t, "silver-empty")
+	assert.Empty(t, m.Files, "TRANSFORMED files are not loaded again")
+	assert.Empty(t, m.Tables)
+}
+
+func TestRunDeduplicates(t *testing.T) {
+	f := newFixture(t)
+	day := func(h int) time.Time { return time.Date(2025, 11, 21, h, 0, 0, 0, time.UTC) }
+	row := func(claim, paid string) string {
+		return "TRN1,2025-11-20,ACH,PAYER1,,PCN" + claim + ",,1,,200.00," + paid + ",\n"
+	}
+	const part = "silver/835_payment/year=2025/month=11/day=20/part-00000.csv"
+
+	f.validated(t, "835", "raw/835/a.csv", paymentHeader+row("1", "100.00")+row("1", "110.00")+row("2", "50.00"), day(10))
+	f.validated(t, "835", "raw/835/b.csv", paymentHeader+row("1", "120.00"), day(11))
+	m := f.run(t, "run-1")
+	require.Len(t, m.Tables, 1)
+	assert.Equal(t, int64(3), m.Tables[0].RowsIn)
+	assert.Equal(t, int64(2), m.Tables[0].Duplicates, "A repeated row of a.csv and a.csv's PCN1, which b.csv replaced")
+	assert.Equal(t, map[string]string{"PCN1": "120.00", "PCN2": "50.00"}, f.paid(t, part))
+
+	// c.csv arrives late with an older upload; d.csv corrects PCN2.
+	f.validated(t, "835", "raw/835/c.csv", paymentHeader+row("1", "90.00")+row("3", "30.00"), day(9))
+	f.validated(t, "835", "raw/835/d.csv", paymentHeader+row("2", "55.00"), day(12))
+	m = f.run(t, "run-2")
+	assert.Equal(t, int64(1), m.Tables[0].Duplicates, "c.csv's PCN1 is older than the row in silver")
+	p := m.Tables[0].Partitions[0]
+	assert.Equal(t, []int64{3, 1, 1}, []int64{p.Rows, p.Inserted, p.Updated})
+	assert.Equal(t, map[string]string{"PCN1": "120.00", "PCN2": "55.00", "PCN3": "30.00"}, f.paid(t, part))
+}
+
+func TestRunMovesCorrectedRows(t *testing.T) {
+	f := newFixture(t)
+	day := func(h int) time.Time { return time.Date(2025, 11, 21, h, 0, 0, 0, time.UTC) }
+	row := func(claim, date string) string {
+		return "TRN1," + date + ",ACH,PAYER1,,PCN" + claim + ",,1,,200.00,100.00,\n"
+	}
+	part := func(d string) string { return "silver/835_payment/year=2025/month=11/day=" + d + "/part-00000.csv" }
+
+	f.validated(t, "835", "raw/835/a.csv", paymentHeader+row("1", "2025-11-20")+row("2", "2025-11-20"), day(10))
+	f.run(t, "run-1")
+
+	// b.csv corrects the payment date of PCN1.
+	f.validated(t, "835", "raw/835/b.csv", paymentHeader+row("1", "2025-11-19"), day(11))
+	m := f.run(t, "run-2")
+	assert.Equal(t, int64(1), m.Tables[0].Duplicates, "The row in its old partition")
+	require.Len(t, m.Tables[0].Partitions, 2)
+	p19, p20 := m.Tables[0].Partitions[0], m.Tables[0].Partitions[1]
+	assert.Equal(t, []int64{1, 1, 0, 0}, []int64{p19.Rows, p19.Inserted, p19.Updated, p19.Moved})
+	assert.Equal(t, []int64{1, 0, 0, 1}, []int64{p20.Rows, p20.Inserted, p20.Updated, p20.Moved})
+	assert.Equal(t, map[string]string{"PCN1": "100.00"}, f.paid(t, part("19")))
+	assert.Equal(t, map[string]string{"PCN2": "100.00"}, f.paid(t, part("20")))
+
+	// Within a run the newest date wins too, and a late file with an older
+	// upload does not move a row back.
+	f.validated(t, "835", "raw/835/c.csv", paymentHeader+row("3", "2025-11-18"), day(13))
+	f.validated(t, "835", "raw/835/d.csv", paymentHeader+row("3", "2025-11-17"), day(14))
+	f.validated(t, "835", "raw/835/e.csv", paymentHeader+row("1", "2025-11-21"), day(8))
+	m = f.run(t, "run-3")
+	assert.Equal(t, int64(3), m.Tables[0].RowsIn)
+	assert.Equal(t, int64(2), m.Tables[0].Duplicates, "c.csv's PCN3 and e.csv's PCN1")
+	require.Len(t, m.Tables[0].Partitions, 1)
+	assert.Equal(t, "year=2025/month=11/day=17", m.Tables[0].Partitions[0].Path)
+	assert.Equal(t, map[string]string{"PCN1": "100.00"}, f.paid(t, part("19")))
+	for _, d := range []string{"18", "21"} {
+		_, err := f.Objects.Get(context.Background(), lakeBucket, part(d), "")
+		assert.ErrorIs(t, err, objectstore.ErrNotFound, "day %s is not written", d)
+	}
+}
+
+// recordedGets is a store that records the keys it reads.
+type recordedGets struct {
+	objectstore.Store
+	keys []string
+}
+
+func (s *recordedGets) Get(ctx context.Context, bucket, key, versionID string) (*objectstore.Object, error) {
+	s.keys = append(s.keys, key)
+	return s.Store.Get(ctx, bucket, key, versionID)
+}
+
+func TestRunReadsOnlyTouchedPartitions(t *testing.T) {
+	f := newFixture(t)
+	row := func(claim, date string) string {
+		return "TRN1," + date + ",ACH,PAYER1,,PCN" + claim + ",,1,,200.00,100.00,\n"
+	}
+	part := func(d string) string { return "silver/835_payment/year=2025/month=11/day=" + d + "/part-00000.csv" }
+	f.validated(t, "835", "raw/835/a.csv", paymentHeader+row("1", "2025-11-10")+row("2", "2025-11-11")+row("3", "2025-11-12"),
+		time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC))
+	f.run(t, "run-1")
+
+	// b.csv moves PCN1 from the 10th to the 13th.
+	f.validated(t, "835", "raw/835/b.csv", paymentHeader+row("1", "2025-11-13"), time.Date(2025, 11, 21, 11, 0, 0, 0, time.UTC))
+	gets := &recordedGets{Store: f.Objects}
+	tr := &Transformer{Objects: gets, Metadata: f.store, Locks: f.store, Schemas: f.Schemas, Bucket: lakeBucket, Format: CSV{}, ID: "run-2"}
+	m, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, m.Tables[0].Partitions, 2)
+	assert.Equal(t, int64(1), m.Tables[0].Partitions[0].Moved)
+
+	var read []string
+	for _, key := range gets.keys {
+		if strings.HasPrefix(key, "silver/835_payment/") {
+			read = append(read, key)
+		}
+	}
+	assert.ElementsMatch(t, []string{part("13"), part("10")}, read, "The days of PCN2 and PCN3 are not read")
+	assert.Empty(t, f.paid(t, part("10")))
+	assert.Equal(t, map[string]string{"PCN1": "100.00"}, f.paid(t, part("13")))
+	assert.Equal(t, map[string]string{"PCN2": "100.00"}, f.paid(t, part("11")))
+}
+
+func TestRunIndexesTablesWrittenBefore(t *testing.T) {
+	f := newFixture(t)
+	// A partition written before tables had a key index.
+	f.Put(t, "tbl_payment", "year=2025/month=11/day=20",
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN1,,1,,200.00,100.00,,835-a,clearinghouse,1,2025-11-20T10:00:00Z\n")
+
+	f.validated(t, "835", "raw/835/b.csv", paymentHeader+"TRN1,2025-11-19,ACH,PAYER1,,PCN1,,1,,200.00,90.00,\n",
+		time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC))
+	m := f.run(t, "run-1")
+	require.Len(t, m.Tables[0].Partitions, 2, "The old partition is found without an index")
+	assert.Equal(t, int64(1), m.Tables[0].Partitions[1].Moved)
+	assert.Empty(t, f.paid(t, "silver/835_payment/year=2025/month=11/day=20/part-00000.csv"))
+
+	keys, err := f.Objects.List(context.Background(), lakeBucket, IndexPrefix+"tbl_payment/")
+	require.NoError(t, err)
+	assert.Len(t, keys, 1, "One key, one shard")
+	var index map[string][]string
+	require.NoError(t, json.Unmarshal(f.Read(t, keys[0]), &index))
+	assert.Equal(t, map[string][]string{"PAYER1\x1fTRN1\x1fPCN1": {"year=2025/month=11/day=19"}}, index)
+}
+
+func TestRunHoldsLock(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	a := f.validated(t, "835", "raw/835/a.csv", paymentHeader+"TRN1,2025-11-20,ACH,PAYER1,,PCN1,,1,,200.00,100.00,\n",
+		time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC))
+
+	require.NoError(t, f.store.Lock(ctx, LockName, "other-run", time.Hour))
+	tr := &Transformer{Objects: f.Objects, Metadata: f.store, Locks: f.store, Schemas: f.Schemas, Bucket: lakeBucket, Format: CSV{}, ID: "run-1"}
+	_, err := tr.Run(ctx)
+	assert.ErrorIs(t, err, metadata.ErrLocked)
+	rec, err := f.store.Get(ctx, a.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusValidated, rec.Status, "Nothing was loaded")
+
+	require.NoError(t, f.store.Unlock(ctx, LockName, "other-run"))
+	f.run(t, "run-1")
+	assert.NoError(t, f.store.Lock(ctx, LockName, "other-run", time.Hour), "The run released the lock")
+
+	tr.Locks = nil
+	_, err = tr.Run(ctx)
+	assert.EqualError(t, err, "silver: no lock to keep runs from overlapping")
+}
+
+func TestRunWritesParquet(t *testing.T) {
+	f := newFixture(t)
+	row := func(claim, paid string) string {
+		return "TRN1,2025-11-20,ACH,PAYER1,,PCN" + claim + ",,1,,200.00," + paid + ",\n"
+	}
+	f.validated(t, "835", "raw/835/a.csv", paymentHeader+row("1", "100.00")+row("2", "50.00"), time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC))
+	tr := &Transformer{Objects: f.Objects, Metadata: f.store, Locks: f.store, Schemas: f.Schemas, Bucket: lakeBucket, ID: "run-1"}
+	m, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "parquet", m.Format, "Parquet is the default format")
+	require.Len(t, m.Tables, 1)
+	const part = "silver/835_payment/year=2025/month=11/day=20/part-00000.parquet"
+	assert.Equal(t, "s3://claim-dev-lake/"+part, m.Tables[0].Partitions[0].Object)
+
+	// The second run merges into the Parquet partition the first wrote.
+	f.validated(t, "835", "raw/835/b.csv", paymentHeader+row("2", "55.00"), time.Date(2025, 11, 21, 11, 0, 0, 0, time.UTC))
+	tr.ID = "run-2"
+	m, err = tr.Run(context.Background())
+	require.NoError(t, err)
+	p := m.Tables[0].Partitions[0]
+	assert.Equal(t, []int64{2, 0, 1}, []int64{p.Rows, p.Inserted, p.Updated})
+
+	s, err := f.Schemas.Latest("tbl_payment")
+	require.NoError(t, err)
+	rows, err := Parquet{}.Read(f.Read(t, part), s)
+	require.NoError(t, err)
+	paid := map[string]string{}
+	for _, r := range rows {
+		paid[r[s.Index("claim_id")].(string)] = r[s.Index("paid_amount")].(schema.Fixed).String()
+	}
+	assert.Equal(t, map[string]string{"PCN1": "100.00", "PCN2": "55.00"}, paid)
+}
+
+func TestRunRegistersPartitions(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	glueAPI := catalog.NewFake("claim_raw_db", "claim_silver_db", "claim_gold_db")
+	tr := &Transformer{Objects: f.Objects, Metadata: f.store, Locks: f.store, Schemas: f.Schemas, Bucket: lakeBucket, ID: "run-1",
+		Catalog: catalog.New(glueAPI, f.Schemas, rawBucket, lakeBucket)}
+	f.validated(t, "835", "raw/835/a.csv", paymentHeader+
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN1,,1,,200.00,100.00,\n"+
+		"TRN2,2025-11-21,ACH,PAYER1,,PCN2,,1,,200.00,50.00,\n",
+		time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC))
+	m, err := tr.Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, m.Tables, 1)
+	assert.Equal(t, 2, m.Tables[0].CatalogPartitions)
+
+	out, err := glueAPI.GetPartitionsWithContext(ctx, &glue.GetPartitionsInput{DatabaseName: aws.String("claim_silver_db"), TableName: aws.String("tbl_payment")})
+	require.NoError(t, err)
+	require.Len(t, out.Partitions, 2)
+	assert.Equal(t, "s3://claim-dev-lake/silver/835_payment/year=2025/month=11/day=21/", aws.StringValue(out.Partitions[1].StorageDescriptor.Location))
+
+	f.validated(t, "835", "raw/835/b.csv", paymentHeader+"TRN1,2025-11-20,ACH,PAYER1,,PCN1,,1,,200.00,110.00,\n",
+		time.Date(2025, 11, 21, 11, 0, 0, 0, time.UTC))
+	tr.ID = "run-2"
+	m, err = tr.Run(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, m.Tables[0].CatalogPartitions, "The partition is already registered")
+
+	tr.Format, tr.ID = CSV{}, "run-3"
+	f.validated(t, "835", "raw/835/c.csv", paymentHeader, time.Date(2025, 11, 21, 12, 0, 0, 0, time.UTC))
+	_, err = tr.Run(ctx)
+	assert.EqualError(t, err, "silver: the Glue catalog declares Parquet, not csv")
+}
+
+func TestRunRejectsRows(t *testing.T) {
+	f := newFixture(t)
+	ingested := time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC)
+	// Validation let these rows through within the error budget.
+	rec := f.validated(t, "835", "raw/835/remit.csv", paymentHeader+
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN1,,1,MBR9,200.00,abc,\n"+
+		"TRN1,2025-11-20,ACH,PAYER1\n"+
+		"TRN1,2025-11-20,WIRE,PAYER1,,PCN2,,1,,200.00,1.00,\n"+
+		"TRN1,\"2025\"-11-20,ACH,PAYER1,,PCN4,,1,,200.00,1.00,\n"+
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN3,,1,,200.00,1.00,\n",
+		ingested)
+	missing := &metadata.FileRecord{FileID: "gone", FileType: "835", Bucket: rawBucket, Key: "raw/835/gone.csv", IngestTime: metadata.FormatTime(ingested)}
+	missing.Transition(metadata.StatusValidated, "", ingested)
+	require.NoError(t, f.store.Create(context.Background(), missing))
+
+	m := f.run(t, "run-1")
+	require.Len(t, m.Files, 2)
+	byID := map[string]*FileResult{m.Files[0].FileID: m.Files[0], m.Files[1].FileID: m.Files[1]}
+	assert.Equal(t, int64(5), byID[rec.FileID].Rows)
+	assert.Equal(t, int64(4), byID[rec.FileID].RejectedRows)
+	assert.Equal(t, map[string]int64{"type": 1, "field_count": 1, "values": 1, "malformed": 1}, byID[rec.FileID].RejectedByRule)
+	const errorPart = "silver/_error/rows/year=2025/month=11/day=21/part-00000.csv"
+	assert.Equal(t, "s3://claim-dev-lake/"+errorPart, byID[rec.FileID].ErrorObject)
+	assert.Equal(t, "object not found", byID["gone"].Error)
+	assert.Equal(t, map[string]string{"PCN3": "1.00"},
+		f.paid(t, "silver/835_payment/year=2025/month=11/day=20/part-00000.csv"))
+	require.Len(t, m.Tables, 2)
+	assert.Equal(t, RejectsTable, m.Tables[1].Table)
+
+	s, err := f.Schemas.Latest(RejectsTable)
+	require.NoError(t, err)
+	rows, err := CSV{}.Read(f.Read(t, errorPart), s)
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	get := func(row Row, name string) interface{} { return row[s.Index(name)] }
+	var got []string
+	for _, row := range rows {
+		assert.Equal(t, rec.FileID, get(row, "file_id"))
+		assert.Equal(t, "tbl_835_raw_csv v1", get(row, "raw_schema"))
+		assert.Equal(t, "run-1", get(row, "run_id"))
+		assert.Equal(t, ingested, get(row, "ingested_at"))
+		got = append(got, fmt.Sprintf("%d line %d: %s %v: %s", get(row, "source_row"), get(row, "line"), get(row, "rule"), get(row, "column"), get(row, "message")))
+	}
+	assert.Equal(t, []string{
+		`1 line 2: type paid_amount: "abc" is not a decimal number`,
+		"2 line 3: field_count <nil>: row has 4 fields, header has 12",
+		"3 line 4: values payment_method: \"WIRE\" is not one of ACH, CHK, NON, BOP, FWT",
+		`4 line 5: malformed <nil>: extraneous or missing " in quoted-field`,
+	}, got)
+
+	var values map[string]string
+	require.NoError(t, json.Unmarshal([]byte(get(rows[0], "raw_values").(string)), &values))
+	assert.Equal(t, "abc", values["paid_amount"])
+	assert.Equal(t, HashValue(nil, "MBR9"), values["member_id"], "PHI is hashed")
+	assert.Empty(t, values["payee_npi"])
+	require.NoError(t, json.Unmarshal([]byte(get(rows[1], "raw_values").(string)), &values))
+	assert.Equal(t, "TRN1", values["trace_number"])
+	assert.Nil(t, get(rows[3], "raw_values"), "A malformed row has no fields")
+
+	got2, err := f.store.Get(context.Background(), rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), got2.Silver.RejectedRows)
+	assert.Equal(t, int64(1), got2.Silver.RejectedByRule["field_count"])
+	assert.Equal(t, "s3://claim-dev-lake/"+errorPart, got2.Silver.ErrorObject)
+	got2, err = f.store.Get(context.Background(), "gone")
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusFailed, got2.Status)
+	assert.Nil(t, got2.Silver)
+
+	// A replay of the file rejects the same rows again; the rows already
+	// in the partition stay.
+	rec, err = f.store.Get(context.Background(), rec.FileID)
+	require.NoError(t, err)
+	rec.Transition(metadata.StatusValidated, "replay", ingested)
+	require.NoError(t, f.store.Put(context.Background(), rec))
+	m = f.run(t, "run-2")
+	p := m.Tables[1].Partitions[0]
+	assert.Equal(t, []int64{4, 0, 0}, []int64{p.Rows, p.Inserted, p.Updated})
+	assert.Equal(t, int64(4), m.Tables[1].Duplicates)
+}
+
+func TestRawValues(t *testing.T) {
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	header := []string{"Trace_Number", "payment_date", "member_id", "retired"}
+	m, err := r.MatchHeader("tbl_835_raw_csv", header)
+	require.NoError(t, err)
+
+	key := []byte("secret")
+	got := rawValues(m, header, []string{"TRN1", "2025-11-20", "MBR1", "x", "extra"}, key)
+	var values map[string]string
+	require.NoError(t, json.Unmarshal([]byte(got), &values))
+	assert.Equal(t, map[string]string{
+		"trace_number": "TRN1",
+		"payment_date": "2025-11-20",
+		"member_id":    HashValue(key, "MBR1"),
+		"retired":      HashValue(key, "x"),
+		"_field_5":     HashValue(key, "extra"),
+	}, values, "Only known non-PHI columns are in the clear")
+	assert.NotEqual(t, HashValue(nil, "MBR1"), HashValue(key, "MBR1"))
+	assert.Regexp(t, "^hmac-sha256:[0-9a-f]{64}$", HashValue(key, "MBR1"))
+	assert.Empty(t, HashValue(key, ""))
+}
+
+func TestRunMaxFiles(t *testing.T) {
+	f := newFixture(t)
+	for i, key := range []string{"raw/835/a.csv", "raw/835/b.csv"} {
+		f.validated(t, "835", key, paymentHeader, time.Date(2025, 11, 21, 10+i, 0, 0, 0, time.UTC))
+	}
+	tr := &Transformer{Objects: f.Objects, Metadata: f.store, Locks: f.store, Schemas: f.Schemas, Bucket: lakeBucket, MaxFiles: 1,
+		Now: func() time.Time { return time.Date(2025, 11, 22, 6, 0, 0, 0, time.UTC) }}
+	m, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "silver-20251122T060000Z", m.RunID)
+	require.Len(t, m.Files, 1)
+	assert.Equal(t, "s3://claim-dev-raw/raw/835/a.csv", m.Files[0].Object)
+}
+
+// projectedStore answers queries as the status index of the DynamoDB table
+// does: with the projected attributes only.
+type projectedStore struct {
+	*metadata.MemoryStore
+}
+
+func (s projectedStore) Query(ctx context.Context, q metadata.Query) (*metadata.Page, error) {
+	page, err := s.MemoryStore.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range page.Records {
+		page.Records[i] = &metadata.FileRecord{
+			FileID: r.FileID, FileName: r.FileName, FileType: r.FileType, SourceSystem: r.SourceSystem,
+			IngestTime: r.IngestTime, UpdatedAt: r.UpdatedAt, Status: r.Status, Bucket: r.Bucket, Key: r.Key,
+			VersionID: r.VersionID, Checksum: r.Checksum, RecordCount: r.RecordCount, SizeBytes: r.SizeBytes,
+			DuplicateOf: r.DuplicateOf,
+		}
+	}
+	return page, nil
+}
+
+func TestRunKeepsUnprojectedAttributes(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	ingested := time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC)
+	rec := f.validated(t, "835", "raw/835/a.csv", paymentHeader+"TRN1,2025-11-20,ACH,PAYER1,,PCN1,,1,,200.00,100.00,\n", ingested)
+	rec.Uploader = "sftp-clearinghouse"
+	rec.ClientChecksum = "abc"
+	rec.Validation = &metadata.Validation{Schema: "tbl_835_raw_csv", SchemaVersion: 1, Rows: 1}
+	require.NoError(t, f.store.Put(ctx, rec))
+
+	tr := &Transformer{Objects: f.Objects, Metadata: projectedStore{f.store}, Locks: f.store, Schemas: f.Schemas, Bucket: lakeBucket, Format: CSV{}, ID: "silver-test",
+		Now: func() time.Time { return time.Date(2025, 11, 22, 6, 0, 0, 0, time.UTC) }}
+	m, err := tr.Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, m.Files, 1)
+	assert.Equal(t, int64(1), m.Files[0].Rows)
+
+	got, err := f.store.Get(ctx, rec.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.StatusTransformed, got.Status)
+	assert.Equal(t, "sftp-clearinghouse", got.Uploader)
+	assert.Equal(t, "abc", got.ClientChecksum)
+	assert.Equal(t, rec.Validation, got.Validation)
+	assert.Equal(t, []metadata.Status{metadata.StatusValidated, metadata.StatusTransformed},
+		[]metadata.Status{got.Transitions[0].Status, got.Transitions[1].Status}, "The history is kept")
+}
+
+func TestNewTableChecksSources(t *testing.T) {
+	raw := &schema.Schema{Name: "tbl_a_raw", Layer: schema.Raw, Version: 1, Columns: []*schema.Column{
+		{Name: "id", Type: schema.Type{Kind: schema.String}},
+		{Name: "day", Type: schema.Type{Kind: schema.Date}},
+	}}
+	silver := &schema.Schema{Name: "tbl_a", Layer: schema.Silver, Version: 1, Key: []string{"id"}, PartitionBy: "day",
+		Columns: []*schema.Column{
+			{Name: "id", Type: schema.Type{Kind: schema.String}},
+			{Name: "day", Type: schema.Type{Kind: schema.Date}},
+			{Name: "amount", Type: schema.Type{Kind: schema.Decimal, Precision: 12, Scale: 2}},
+			{Name: ColFileID, Type: schema.Type{Kind: schema.String}},
+			{Name: ColSourceRow, Type: schema.Type{Kind: schema.Int}},
+			{Name: ColIngestedAt, Type: schema.Type{Kind: schema.Timestamp}},
+		}}
+	_, err := newTable(raw, silver)
+	assert.EqualError(t, err, "silver: tbl_a v1 column amount has no source in tbl_a_raw v1")
+
+	silver.Columns = append(silver.Columns[:2], silver.Columns[3:]...)
+	_, err = newTable(raw, silver)
+	assert.NoError(t, err)
+
+	silver.Columns = silver.Columns[:3]
+	_, err = newTable(raw, silver)
+	assert.EqualError(t, err, "silver: tbl_a v1 lacks the file_id, source_row and ingested_at lineage columns")
+}
@@ -0,0 +1,52 @@
+// Package silvertest builds small lakes of silver partition files for the
+// tests of the stages that read silver.
+package silvertest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+)
+
+// Lake is a lake bucket in a temporary directory, with the built-in schemas.
+type Lake struct {
+	Objects *objectstore.Dir
+	Schemas *schema.Registry
+	Bucket  string
+}
+
+// New returns an empty Lake holding bucket.
+func New(t testing.TB, bucket string) *Lake {
+	t.Helper()
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	return &Lake{Objects: objectstore.NewDir(t.TempDir()), Schemas: r, Bucket: bucket}
+}
+
+// Put writes rows, CSV lines without the header, as the partition file at
+// path (year=…/month=…/day=…) of silver table name.
+func (l *Lake) Put(t testing.TB, name, path, rows string) {
+	t.Helper()
+	s, err := l.Schemas.Latest(name)
+	require.NoError(t, err)
+	body := strings.Join(s.ColumnNames(), ",") + "\n" + rows
+	_, err = l.Objects.Put(context.Background(), l.Bucket, s.Location+path+"/part-00000.csv", strings.NewReader(body), objectstore.PutOptions{})
+	require.NoError(t, err)
+}
+
+// Read returns the content of the object at key of the lake bucket.
+func (l *Lake) Read(t testing.TB, key string) []byte {
+	t.Helper()
+	obj, err := l.Objects.Get(context.Background(), l.Bucket, key, "")
+	require.NoError(t, err)
+	defer obj.Body.Close()
+	data, err := io.ReadAll(obj.Body)
+	require.NoError(t, err)
+	return data
+}
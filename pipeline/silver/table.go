@@ -0,0 +1,399 @@
+package silver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/schema"
+)
+
+// Lineage columns every silver table carries, filled from the file record.
+const (
+	ColFileID       = "file_id"
+	ColSourceSystem = "source_system"
+	ColSourceRow    = "source_row"
+	ColIngestedAt   = "ingested_at"
+)
+
+// entity declares how a silver table is built beyond copying the raw columns
+// of the same name.
+type entity struct {
+	// derive computes columns the raw schema has no column for, from the raw
+	// values by name.
+	derive map[string]func(get func(string) string) string
+	// count names an int column holding the number of distinct countOf
+	// values among the raw rows folded into the row.
+	count, countOf string
+}
+
+var entities = map[string]entity{
+	"tbl_claim_header": {count: "line_count", countOf: "line_number"},
+	"tbl_claim_line": {derive: map[string]func(func(string) string) string{
+		"service_date": func(get func(string) string) string {
+			if d := get("line_service_date"); d != "" {
+				return d
+			}
+			return get("service_from")
+		},
+	}},
+}
+
+// source is one raw row being transformed.
+type source struct {
+	rec *metadata.FileRecord
+	raw *schema.Schema
+	// row is the 1-based data row and values its fields in raw column order.
+	row    int64
+	values []string
+}
+
+func (s *source) get(name string) string {
+	if i := s.raw.Index(name); i >= 0 {
+		return s.values[i]
+	}
+	return ""
+}
+
+// table is a silver table and how its rows are built from one raw schema.
+type table struct {
+	schema *schema.Schema
+	entity
+	// value[i] returns the text column i is parsed from.
+	value []func(*source) string
+	key   []int
+	by    int
+	// count is the index of entity.count, or -1.
+	count int
+	// Lineage column indexes, which decide which of two rows is newer.
+	fileID, sourceRow, ingestedAt int
+}
+
+func newTable(raw, s *schema.Schema) (*table, error) {
+	t := &table{schema: s, entity: entities[s.Name], by: s.Index(s.PartitionBy), count: s.Index(entities[s.Name].count)}
+	t.fileID, t.sourceRow, t.ingestedAt = s.Index(ColFileID), s.Index(ColSourceRow), s.Index(ColIngestedAt)
+	if t.fileID < 0 || t.sourceRow < 0 || t.ingestedAt < 0 {
+		return nil, fmt.Errorf("silver: %s lacks the %s, %s and %s lineage columns", s, ColFileID, ColSourceRow, ColIngestedAt)
+	}
+	if t.by < 0 {
+		return nil, fmt.Errorf("silver: %s has no partition_by column", s)
+	}
+	if t.countOf != "" && raw.Index(t.countOf) < 0 {
+		return nil, fmt.Errorf("silver: %s counts %s, which %s lacks", s, t.countOf, raw)
+	}
+	for _, k := range s.Key {
+		t.key = append(t.key, s.Index(k))
+	}
+
+	for _, c := range s.Columns {
+		var f func(*source) string
+		switch j := raw.Index(c.Name); {
+		case c.Name == ColFileID:
+			f = func(src *source) string { return src.rec.FileID }
+		case c.Name == ColSourceSystem:
+			f = func(src *source) string { return src.rec.SourceSystem }
+		case c.Name == ColSourceRow:
+			f = func(src *source) string { return strconv.FormatInt(src.row, 10) }
+		case c.Name == ColIngestedAt:
+			// TimeLayout is RFC 3339.
+			f = func(src *source) string { return src.rec.IngestTime }
+		case t.derive[c.Name] != nil:
+			derive := t.derive[c.Name]
+			f = func(src *source) string { return derive(src.get) }
+		case c.Name == t.entity.count:
+			f = func(*source) string { return "0" }
+		case j >= 0:
+			f = func(src *source) string { return src.values[j] }
+		default:
+			return nil, fmt.Errorf("silver: %s column %s has no source in %s", s, c.Name, raw)
+		}
+		t.value = append(t.value, f)
+	}
+	return t, nil
+}
+
+// build converts src to a row of t. A value that does not convert is
+// reported as a RuleConvert error; the message of a PHI column never quotes
+// the value.
+func (t *table) build(src *source) (Row, *metadata.RowError) {
+	row := make(Row, len(t.schema.Columns))
+	for i, c := range t.schema.Columns {
+		v, err := c.Parse(t.value[i](src))
+		if err != nil {
+			e := &metadata.RowError{Column: c.Name, Rule: RuleConvert, Msg: fmt.Sprintf("%s: %s", t.schema.Name, err)}
+			if c.PHI {
+				e.Msg = fmt.Sprintf("%s: value is not a valid %s (PHI, not shown)", t.schema.Name, c.Type)
+			}
+			return nil, e
+		}
+		row[i] = v
+	}
+	return row, nil
+}
+
+// keyOf returns the natural key of row as one string.
+func (t *table) keyOf(row Row) string {
+	return joinKey(t.schema, t.key, row)
+}
+
+// KeyOf returns the natural key of row of silver table s as one string, the
+// form runs merge and deduplicate by.
+func KeyOf(s *schema.Schema, row Row) string {
+	key := make([]int, len(s.Key))
+	for i, name := range s.Key {
+		key[i] = s.Index(name)
+	}
+	return joinKey(s, key, row)
+}
+
+// joinKey joins the values of the key columns of row.
+func joinKey(s *schema.Schema, key []int, row Row) string {
+	parts := make([]string, len(key))
+	for i, k := range key {
+		parts[i] = s.Columns[k].Type.Format(row[k])
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+// partition returns the partition values of row.
+func (t *table) partition(row Row) []string {
+	return schema.DatePartition(row[t.by].(time.Time))
+}
+
+// newer reports whether row a supersedes row b with the same key: the row
+// of the file ingested last wins, then the later row of the same file. Files
+// ingested at the same instant are ordered by file_id so every run picks the
+// same winner.
+func (t *table) newer(a, b Row) bool {
+	ta, tb := a[t.ingestedAt].(time.Time), b[t.ingestedAt].(time.Time)
+	if !ta.Equal(tb) {
+		return ta.After(tb)
+	}
+	if fa, fb := a[t.fileID].(string), b[t.fileID].(string); fa != fb {
+		return fa > fb
+	}
+	return a[t.sourceRow].(int64) >= b[t.sourceRow].(int64)
+}
+
+// Deduplicate keeps, of the rows of silver table s with equal keys, the
+// newest by the rule the transformer merges with, in the order of their
+// first occurrence. Rows written before a run removed their stale copies
+// can hold a key in two partitions.
+func Deduplicate(s *schema.Schema, rows []Row) ([]Row, error) {
+	t := &table{schema: s, fileID: s.Index(ColFileID), sourceRow: s.Index(ColSourceRow), ingestedAt: s.Index(ColIngestedAt)}
+	if t.fileID < 0 || t.sourceRow < 0 || t.ingestedAt < 0 {
+		return nil, fmt.Errorf("silver: %s lacks the %s, %s and %s lineage columns", s, ColFileID, ColSourceRow, ColIngestedAt)
+	}
+	for _, k := range s.Key {
+		t.key = append(t.key, s.Index(k))
+	}
+	at := map[string]int{}
+	var out []Row
+	for _, row := range rows {
+		k := t.keyOf(row)
+		i, ok := at[k]
+		switch {
+		case !ok:
+			at[k] = len(out)
+			out = append(out, row)
+		case t.newer(row, out[i]):
+			out[i] = row
+		}
+	}
+	return out, nil
+}
+
+// fold collects the rows one file produces for a table. Rows with equal
+// keys fold into one: the later row's values, and for a counting entity the
+// number of distinct counted values.
+type fold struct {
+	*table
+	rows    map[string]Row
+	order   []string
+	counted map[string]map[string]bool
+	// duplicates counts rows replaced by a later row of the file.
+	duplicates int64
+}
+
+func newFold(t *table) *fold {
+	return &fold{table: t, rows: map[string]Row{}, counted: map[string]map[string]bool{}}
+}
+
+func (f *fold) add(row Row, src *source) {
+	k := f.keyOf(row)
+	if _, ok := f.rows[k]; !ok {
+		f.order = append(f.order, k)
+		f.counted[k] = map[string]bool{}
+	} else if f.count < 0 {
+		f.duplicates++
+	}
+	f.rows[k] = row
+	if f.count >= 0 {
+		f.counted[k][src.get(f.countOf)] = true
+		row[f.count] = int64(len(f.counted[k]))
+	}
+}
+
+// tableRun collects the rows a run writes to one table, by partition path.
+type tableRun struct {
+	*table
+	res   *TableResult
+	parts map[string]map[string]Row
+	// stale holds, by partition path, the keys whose rows in the partition
+	// file a newer row in another partition replaces.
+	stale map[string]map[string]bool
+	// home is the partition path that holds each key of the run, and each
+	// stale key, once the run's partitions are written.
+	home map[string]string
+}
+
+// keys returns the keys of the run's rows.
+func (r *tableRun) keys() []string {
+	var keys []string
+	for _, rows := range r.parts {
+		for k := range rows {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *tableRun) add(row Row) {
+	r.res.RowsIn++
+	path := r.schema.PartitionPath(r.partition(row))
+	rows := r.parts[path]
+	if rows == nil {
+		rows = map[string]Row{}
+		r.parts[path] = rows
+	}
+	k := r.keyOf(row)
+	if prev, ok := rows[k]; ok {
+		r.res.Duplicates++
+		if r.newer(prev, row) {
+			return
+		}
+	}
+	rows[k] = row
+}
+
+// place keeps each key in one partition: the newest row with the key, of
+// the run's rows and the rows of the table's partition files by path, wins.
+// A run row that loses is dropped; a file row that loses is marked stale,
+// to be removed when its partition is rewritten. It returns the paths to
+// rewrite, sorted.
+func (r *tableRun) place(existing map[string][]Row) []string {
+	type held struct {
+		path string
+		row  Row
+	}
+	best := map[string]held{}
+	win := func(k string, c held) {
+		if b, ok := best[k]; !ok || !r.newer(b.row, c.row) {
+			best[k] = c
+		}
+	}
+	for _, path := range sortedKeys(r.parts) {
+		for k, row := range r.parts[path] {
+			win(k, held{path, row})
+		}
+	}
+	for _, path := range sortedKeys(existing) {
+		for _, row := range existing[path] {
+			win(r.keyOf(row), held{path, row})
+		}
+	}
+
+	r.home = map[string]string{}
+	for path, rows := range r.parts {
+		for k := range rows {
+			r.home[k] = best[k].path
+			if best[k].path != path {
+				delete(rows, k)
+				r.res.Duplicates++
+			}
+		}
+		if len(rows) == 0 {
+			delete(r.parts, path)
+		}
+	}
+	r.stale = map[string]map[string]bool{}
+	for path, rows := range existing {
+		for _, row := range rows {
+			k := r.keyOf(row)
+			if best[k].path == path {
+				continue
+			}
+			if r.stale[path] == nil {
+				r.stale[path] = map[string]bool{}
+			}
+			r.stale[path][k] = true
+			r.home[k] = best[k].path
+			r.res.Duplicates++
+		}
+	}
+
+	paths := sortedKeys(r.parts)
+	for path := range r.stale {
+		if _, ok := r.parts[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// merge adds the rows already in a partition file to the run's rows of the
+// partition, less the stale ones, and returns them sorted by key.
+func (r *tableRun) merge(path string, existing []Row, res *PartitionResult) []Row {
+	rows := r.parts[path]
+	if rows == nil {
+		rows = map[string]Row{}
+	}
+	seen := map[string]bool{}
+	for _, old := range existing {
+		k := r.keyOf(old)
+		if r.stale[path][k] {
+			res.Moved++
+			continue
+		}
+		seen[k] = true
+		row, ok := rows[k]
+		switch {
+		case !ok:
+			rows[k] = old
+		case r.newer(old, row):
+			rows[k] = old
+			r.res.Duplicates++
+		default:
+			res.Updated++
+		}
+	}
+	keys := make([]string, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+		if !seen[k] {
+			res.Inserted++
+		}
+	}
+	sort.Strings(keys)
+	out := make([]Row, len(keys))
+	for i, k := range keys {
+		out[i] = rows[k]
+	}
+	res.Rows = int64(len(out))
+	return out
+}
@@ -0,0 +1,286 @@
+// Package validation checks CSV uploads against the schema registered for
+// their file_type before anything downstream reads them: encoding,
+// delimiter, header, and the type and presence of every value, within an
+// error budget. The result is a metadata.Validation summary; the ingestion
+// worker then marks the file VALIDATED or REJECTED.
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+)
+
+// Rules a row error may break.
+const (
+	RuleMalformed  = "malformed"
+	RuleFieldCount = "field_count"
+	RuleRequired   = "required"
+	RuleType       = "type"
+	RuleValues     = "values"
+)
+
+// DefaultMaxSamples bounds the row errors kept on the record, which DynamoDB
+// limits to 400 KB.
+const DefaultMaxSamples = 20
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Budget is how many bad rows a file may have and still be accepted. A file
+// is rejected when its bad rows exceed MaxErrorRows or make up more than
+// MaxErrorRate of its rows; negative values disable a limit. The zero Budget
+// rejects any bad row.
+type Budget struct {
+	MaxErrorRows int64
+	MaxErrorRate float64
+}
+
+// Exceeded reports whether errorRows bad rows out of rows break the budget.
+func (b Budget) Exceeded(errorRows, rows int64) bool {
+	if errorRows == 0 {
+		return false
+	}
+	if b.MaxErrorRows >= 0 && errorRows > b.MaxErrorRows {
+		return true
+	}
+	return b.MaxErrorRate >= 0 && float64(errorRows) > b.MaxErrorRate*float64(rows)
+}
+
+func (b Budget) String() string {
+	var limits []string
+	if b.MaxErrorRows >= 0 {
+		limits = append(limits, fmt.Sprintf("%d rows", b.MaxErrorRows))
+	}
+	if b.MaxErrorRate >= 0 {
+		limits = append(limits, fmt.Sprintf("%g%% of rows", b.MaxErrorRate*100))
+	}
+	if len(limits) == 0 {
+		return "no limit"
+	}
+	return strings.Join(limits, " and ")
+}
+
+// Checker validates CSV streams.
+type Checker struct {
+	Schemas *schema.Registry
+	Budget  Budget
+	// MaxSamples bounds Validation.Samples; zero means DefaultMaxSamples.
+	MaxSamples int
+}
+
+// Check reads a CSV of the given file_type to the end. Only I/O errors are
+// returned; problems with the file go into the summary, whose Reason is set
+// if the file is rejected. The summary's ValidatedAt is left to the caller.
+func (c *Checker) Check(r io.Reader, fileType string) (*metadata.Validation, error) {
+	v := &metadata.Validation{Encoding: "UTF-8"}
+	s, err := c.Schemas.ForFileType(fileType)
+	if err != nil {
+		v.Reason = fmt.Sprintf("no schema is registered for file type %q", fileType)
+		return v, nil
+	}
+	v.Schema, v.SchemaVersion = s.Name, s.Version
+
+	br := bufio.NewReader(r)
+	head, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		v.Encoding = "UTF-8-BOM"
+		br.Discard(len(utf8BOM))
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}), bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		v.Encoding = "UTF-16"
+		v.Reason = "file is UTF-16; upload UTF-8"
+		return v, nil
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		v.Reason = "file is empty"
+		return v, nil
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		v.Reason = "malformed header: " + parseErr.Err.Error()
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ValidUTF8(header) {
+		v.Reason = "header is not valid UTF-8"
+		return v, nil
+	}
+	if d := sniffDelimiter(header); d != "" {
+		v.Reason = fmt.Sprintf("header is delimited by %s; files must be comma-separated", d)
+		return v, nil
+	}
+
+	header = append([]string(nil), header...)
+	m, err := c.Schemas.MatchHeader(s.Name, header)
+	if err != nil {
+		return nil, err
+	}
+	v.HeaderVersion = m.Version
+	for _, ch := range m.Changes {
+		v.HeaderChanges = append(v.HeaderChanges, ch.String())
+	}
+	if !m.Compatible() {
+		var bad []string
+		for _, ch := range schema.Incompatible(m.Changes) {
+			bad = append(bad, ch.String())
+		}
+		v.Reason = fmt.Sprintf("header does not match %s: %s", s, strings.Join(bad, "; "))
+		return v, nil
+	}
+
+	maxSamples := c.MaxSamples
+	if maxSamples == 0 {
+		maxSamples = DefaultMaxSamples
+	}
+	var rowErrs []metadata.RowError
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		v.Rows++
+		if errors.As(err, &parseErr) {
+			v.ErrorRows++
+			v.Errors++
+			v.Samples = sample(v.Samples, maxSamples, metadata.RowError{
+				Row: v.Rows, Line: parseErr.StartLine, Rule: RuleMalformed, Msg: parseErr.Err.Error(),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		rowErrs = rowErrs[:0]
+		switch {
+		case !ValidUTF8(record):
+			rowErrs = append(rowErrs, metadata.RowError{Rule: RuleMalformed, Msg: "row is not valid UTF-8"})
+		case len(record) != len(header):
+			rowErrs = append(rowErrs, metadata.RowError{Rule: RuleFieldCount, Msg: fmt.Sprintf("row has %d fields, header has %d", len(record), len(header))})
+		default:
+			rowErrs = CheckRow(s, m.Row(record), rowErrs)
+		}
+		if len(rowErrs) == 0 {
+			continue
+		}
+		v.ErrorRows++
+		v.Errors += int64(len(rowErrs))
+		for _, e := range rowErrs {
+			e.Row, e.Line = v.Rows, line
+			v.Samples = sample(v.Samples, maxSamples, e)
+		}
+	}
+
+	if c.Budget.Exceeded(v.ErrorRows, v.Rows) {
+		v.Reason = fmt.Sprintf("%d of %d rows have errors, over the budget of %s", v.ErrorRows, v.Rows, c.Budget)
+	}
+	return v, nil
+}
+
+// CheckRow appends the errors of row, whose values are in s's column order,
+// to errs. Messages of PHI columns never quote the value.
+func CheckRow(s *schema.Schema, row []string, errs []metadata.RowError) []metadata.RowError {
+	for i, col := range s.Columns {
+		_, err := col.Parse(row[i])
+		if err == nil {
+			continue
+		}
+		e := metadata.RowError{Column: col.Name, Rule: RuleType, Msg: err.Error()}
+		switch {
+		case errors.Is(err, schema.ErrRequired):
+			e.Rule = RuleRequired
+		case len(col.Values) > 0:
+			e.Rule = RuleValues
+		}
+		if col.PHI && e.Rule != RuleRequired {
+			e.Msg = fmt.Sprintf("value is not a valid %s (PHI, not shown)", col.Type)
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+func sample(samples []metadata.RowError, max int, e metadata.RowError) []metadata.RowError {
+	if len(samples) < max {
+		samples = append(samples, e)
+	}
+	return samples
+}
+
+// sniffDelimiter returns the name of the delimiter a one-field header was
+// written with, if it is not a comma.
+func sniffDelimiter(header []string) string {
+	if len(header) != 1 {
+		return ""
+	}
+	best, count := "", 0
+	for _, d := range []struct{ char, name string }{{";", "semicolons"}, {"\t", "tabs"}, {"|", "pipes"}} {
+		if n := strings.Count(header[0], d.char); n > count {
+			best, count = d.name, n
+		}
+	}
+	return best
+}
+
+// ValidUTF8 reports whether every field of record is valid UTF-8.
+// encoding/csv passes invalid bytes through unchanged.
+func ValidUTF8(record []string) bool {
+	for _, f := range record {
+		if !utf8.ValidString(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validator validates CSV files in the object store and records the result.
+type Validator struct {
+	Objects objectstore.Store
+	Checker
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// Validate checks the CSV object of rec and sets rec.Validation; the caller
+// stores rec. It reports whether the file is valid.
+func (v *Validator) Validate(ctx context.Context, rec *metadata.FileRecord) (bool, error) {
+	obj, err := v.Objects.Get(ctx, rec.Bucket, rec.Key, rec.VersionID)
+	if err != nil {
+		return false, fmt.Errorf("read s3://%s/%s for validation: %w", rec.Bucket, rec.Key, err)
+	}
+	defer obj.Body.Close()
+
+	summary, err := v.Check(obj.Body, rec.FileType)
+	if err != nil {
+		return false, fmt.Errorf("read s3://%s/%s for validation: %w", rec.Bucket, rec.Key, err)
+	}
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	summary.ValidatedAt = metadata.FormatTime(now)
+	rec.Validation = summary
+	return summary.Reason == "", nil
+}
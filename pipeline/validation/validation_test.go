@@ -0,0 +1,205 @@
+package validation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/metadata"
+	"claim-management-system/pipeline/objectstore"
+	"claim-management-system/pipeline/schema"
+	"claim-management-system/pipeline/x12/x834"
+	"claim-management-system/pipeline/x12/x835"
+	"claim-management-system/pipeline/x12/x837"
+)
+
+const paymentHeader = "trace_number,payment_date,payment_method,payer_id,payee_npi,claim_id,payer_claim_id,claim_status,member_id,billed_amount,paid_amount,patient_responsibility\n"
+
+const payments = paymentHeader +
+	"TRN1,2025-11-20,ACH,PAYER1,1234567893,PCN0001,PAY0001,1,MBR0001,150.00,120.00,30.00\n" +
+	"TRN1,20251120,ACH,PAYER1,,PCN0002,,4,,80.00,0,\n"
+
+func checker(t *testing.T, budget Budget) *Checker {
+	t.Helper()
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	return &Checker{Schemas: r, Budget: budget}
+}
+
+func TestCheckValid(t *testing.T) {
+	v, err := checker(t, Budget{}).Check(strings.NewReader(payments), "835")
+	require.NoError(t, err)
+	assert.Equal(t, &metadata.Validation{
+		Schema: "tbl_835_raw_csv", SchemaVersion: 1, HeaderVersion: 1,
+		Encoding: "UTF-8", Rows: 2,
+	}, v)
+
+	// A BOM, CRLF line ends and a header in another order and case.
+	body := "\xEF\xBB\xBFPAID_AMOUNT,trace_number,payment_date,payment_method,payer_id,claim_id,claim_status,billed_amount\r\n" +
+		"1.5,TRN9,2025-11-21,CHK,PAYER2,PCN9,1,2.00\r\n"
+	v, err = checker(t, Budget{}).Check(strings.NewReader(body), "835")
+	require.NoError(t, err)
+	assert.Empty(t, v.Reason)
+	assert.Equal(t, "UTF-8-BOM", v.Encoding)
+	assert.Zero(t, v.HeaderVersion, "Optional columns are missing")
+	assert.Contains(t, v.HeaderChanges, "missing payee_npi: header lacks nullable column")
+	assert.Equal(t, int64(1), v.Rows)
+}
+
+func TestCheckRowErrors(t *testing.T) {
+	body := paymentHeader +
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN0001,,1,MBR0001,150.00,120.00,\n" +
+		"TRN1,2025-13-01,WIRE,PAYER1,,,,1,MBR0002,15O.00,1.005,\n" +
+		"TRN1,2025-11-20,ACH,PAYER1\n" +
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN0003,,1,M\xE9R,1.00,1.00,\n" +
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN0004,,1,\"MBR\"0004,1.00,1.00,\n" +
+		"TRN1,2025-11-20,ACH,PAYER1,,PCN0005,,1,MBR0005,1.00,1.00,\n"
+
+	v, err := checker(t, Budget{MaxErrorRows: -1, MaxErrorRate: 0.5}).Check(strings.NewReader(body), "835")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), v.Rows)
+	assert.Equal(t, int64(4), v.ErrorRows)
+	assert.Equal(t, int64(8), v.Errors)
+	assert.Equal(t, []metadata.RowError{
+		{Row: 2, Line: 3, Column: "payment_date", Rule: RuleType, Msg: `"2025-13-01" is not a YYYY-MM-DD or CCYYMMDD date`},
+		{Row: 2, Line: 3, Column: "payment_method", Rule: RuleValues, Msg: `"WIRE" is not one of ACH, CHK, NON, BOP, FWT`},
+		{Row: 2, Line: 3, Column: "claim_id", Rule: RuleRequired, Msg: "required value is empty"},
+		{Row: 2, Line: 3, Column: "billed_amount", Rule: RuleType, Msg: `"15O.00" is not a decimal number`},
+		{Row: 2, Line: 3, Column: "paid_amount", Rule: RuleType, Msg: `"1.005" has more than 2 decimal places`},
+		{Row: 3, Line: 4, Rule: RuleFieldCount, Msg: "row has 4 fields, header has 12"},
+		{Row: 4, Line: 5, Rule: RuleMalformed, Msg: "row is not valid UTF-8"},
+		{Row: 5, Line: 6, Rule: RuleMalformed, Msg: `extraneous or missing " in quoted-field`},
+	}, v.Samples)
+	assert.Equal(t, "4 of 6 rows have errors, over the budget of 50% of rows", v.Reason)
+
+	v, err = checker(t, Budget{MaxErrorRows: 4, MaxErrorRate: -1}).Check(strings.NewReader(body), "835")
+	require.NoError(t, err)
+	assert.Empty(t, v.Reason, "Four bad rows are within the budget")
+
+	c := checker(t, Budget{MaxErrorRows: -1, MaxErrorRate: -1})
+	c.MaxSamples = 2
+	v, err = c.Check(strings.NewReader(body), "835")
+	require.NoError(t, err)
+	assert.Len(t, v.Samples, 2)
+	assert.Equal(t, int64(8), v.Errors, "Errors past the samples are still counted")
+}
+
+func TestCheckMasksPHI(t *testing.T) {
+	r, err := schema.Builtin()
+	require.NoError(t, err)
+	s, err := r.ForFileType("834")
+	require.NoError(t, err)
+	row := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		switch c.Type.Kind {
+		case schema.Date:
+			row[i] = "2025-01-01"
+		default:
+			row[i] = "X"
+		}
+	}
+	row[s.Index("relationship")] = "18"
+	row[s.Index("maintenance_code")] = "021"
+	row[s.Index("gender")] = "F"
+	row[s.Index("birth_date")] = "1980-02-30"
+	row[s.Index("last_name")] = ""
+
+	errs := CheckRow(s, row, nil)
+	assert.Equal(t, []metadata.RowError{
+		{Column: "last_name", Rule: RuleRequired, Msg: "required value is empty"},
+		{Column: "birth_date", Rule: RuleType, Msg: "value is not a valid date (PHI, not shown)"},
+	}, errs)
+}
+
+func TestCheckRejectsFile(t *testing.T) {
+	cases := []struct {
+		name, fileType, body, reason string
+	}{
+		{"unknown file type", "270", payments, `no schema is registered for file type "270"`},
+		{"empty", "835", "", "file is empty"},
+		{"utf-16", "835", "\xFF\xFEt\x00r\x00", "file is UTF-16; upload UTF-8"},
+		{"semicolons", "835", strings.ReplaceAll(payments, ",", ";"), "header is delimited by semicolons; files must be comma-separated"},
+		{"tabs", "835", strings.ReplaceAll(payments, ",", "\t"), "header is delimited by tabs; files must be comma-separated"},
+		{"latin-1 header", "835", strings.Replace(payments, "trace_number", "trac\xE9", 1), "header is not valid UTF-8"},
+		{"drift", "835", strings.Replace(payments, "payer_claim_id", "check_amount", 1),
+			"header does not match tbl_835_raw_csv v1: unknown check_amount: no version of tbl_835_raw_csv has this column (incompatible)"},
+		{"missing required", "835", strings.Replace(payments, "claim_status", "status", 1),
+			"header does not match tbl_835_raw_csv v1: missing claim_status: header lacks required column (incompatible); " +
+				"unknown status: no version of tbl_835_raw_csv has this column (incompatible)"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v, err := checker(t, Budget{MaxErrorRows: -1, MaxErrorRate: -1}).Check(strings.NewReader(c.body), c.fileType)
+			require.NoError(t, err)
+			assert.Equal(t, c.reason, v.Reason)
+			assert.Zero(t, v.Rows)
+		})
+	}
+}
+
+// The raw schemas describe the CSV extracts partners upload under
+// raw/<type>/. The CSVs converted from X12 are another layout, one table per
+// loop; landed under raw/<type>/ they are rejected, not half-loaded.
+func TestX12CSVsAreNotRawFeeds(t *testing.T) {
+	for _, c := range []struct {
+		fileType string
+		csvs     map[string][]string
+	}{
+		{"834", map[string][]string{"member": x834.MemberColumns, "coverage": x834.CoverageColumns}},
+		{"835", map[string][]string{
+			"payment": x835.PaymentColumns, "claim_payment": x835.ClaimPaymentColumns,
+			"service_payment": x835.ServicePaymentColumns, "adjustment": x835.AdjustmentColumns,
+		}},
+		{"837", map[string][]string{
+			"claim_header": x837.ClaimHeaderColumns, "claim_line": x837.ClaimLineColumns,
+			"diagnosis": x837.DiagnosisColumns, "provider": x837.ProviderColumns,
+		}},
+	} {
+		for name, columns := range c.csvs {
+			v, err := checker(t, Budget{}).Check(strings.NewReader(strings.Join(columns, ",")+"\n"), c.fileType)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(v.Reason, "header does not match tbl_"+c.fileType+"_raw_csv"),
+				"%s %s CSV: %s", c.fileType, name, v.Reason)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	assert.False(t, Budget{}.Exceeded(0, 10))
+	assert.True(t, Budget{}.Exceeded(1, 10), "The zero budget tolerates nothing")
+	assert.False(t, Budget{MaxErrorRows: 2, MaxErrorRate: -1}.Exceeded(2, 10))
+	assert.True(t, Budget{MaxErrorRows: 2, MaxErrorRate: -1}.Exceeded(3, 10))
+	assert.False(t, Budget{MaxErrorRows: -1, MaxErrorRate: 0.1}.Exceeded(1, 10))
+	assert.True(t, Budget{MaxErrorRows: -1, MaxErrorRate: 0.1}.Exceeded(2, 10))
+	assert.True(t, Budget{MaxErrorRows: 5, MaxErrorRate: 0.1}.Exceeded(2, 10), "Both limits apply")
+	assert.Equal(t, "5 rows and 10% of rows", Budget{MaxErrorRows: 5, MaxErrorRate: 0.1}.String())
+	assert.Equal(t, "no limit", Budget{MaxErrorRows: -1, MaxErrorRate: -1}.String())
+}
+
+func TestValidate(t *testing.T) {
+	objects := objectstore.NewDir(t.TempDir())
+	ctx := context.Background()
+	version, err := objects.Put(ctx, "claim-dev-raw", "raw/835/remit.csv", strings.NewReader(payments), objectstore.PutOptions{})
+	require.NoError(t, err)
+
+	v := &Validator{
+		Objects: objects,
+		Checker: *checker(t, Budget{}),
+		Now:     func() time.Time { return time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC) },
+	}
+	rec := &metadata.FileRecord{FileType: "835", Bucket: "claim-dev-raw", Key: "raw/835/remit.csv", VersionID: version}
+	ok, err := v.Validate(ctx, rec)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NotNil(t, rec.Validation)
+	assert.Equal(t, "2025-11-21T10:00:00.000Z", rec.Validation.ValidatedAt)
+	assert.Equal(t, int64(2), rec.Validation.Rows)
+
+	rec.Key = "raw/835/gone.csv"
+	_, err = v.Validate(ctx, rec)
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+}
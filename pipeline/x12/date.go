@@ -0,0 +1,55 @@
+package x12
+
+import (
+	"strings"
+	"time"
+)
+
+// DateRange converts a date element to YYYY-MM-DD. format is the date/time
+// period format qualifier (DTP02): D8 is CCYYMMDD, RD8 is CCYYMMDD-CCYYMMDD
+// and DT is CCYYMMDDHHMM, whose hour is dropped; an empty format is treated
+// as D8, as in DTM02 and CLP/BPR dates. For D8 and DT, from and to are
+// equal. ok is false if the value does not match the format.
+func DateRange(format, value string) (from, to string, ok bool) {
+	switch format {
+	case "", "D8":
+		d, ok := isoDate(value)
+		return d, d, ok
+	case "DT":
+		t, err := time.Parse("200601021504", value)
+		if err != nil {
+			return "", "", false
+		}
+		d := t.Format("2006-01-02")
+		return d, d, true
+	case "RD8":
+		a, b, found := strings.Cut(value, "-")
+		if !found {
+			return "", "", false
+		}
+		from, ok1 := isoDate(a)
+		to, ok2 := isoDate(b)
+		if !ok1 || !ok2 || to < from {
+			return "", "", false
+		}
+		return from, to, true
+	}
+	return "", "", false
+}
+
+// TimeOfDay converts a TM (HHMM) element, e.g. a discharge hour, to HH:MM.
+func TimeOfDay(value string) (string, bool) {
+	t, err := time.Parse("1504", value)
+	if err != nil {
+		return "", false
+	}
+	return t.Format("15:04"), true
+}
+
+func isoDate(v string) (string, bool) {
+	t, err := time.Parse("20060102", v)
+	if err != nil {
+		return "", false
+	}
+	return t.Format("2006-01-02"), true
+}
@@ -0,0 +1,162 @@
+package x12
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IK304 segment syntax error codes.
+const (
+	IK3UnrecognizedSegment = "1"
+	IK3UnexpectedSegment   = "2"
+	IK3MissingSegment      = "3"
+	IK3LoopOverMaximum     = "4"
+	IK3SegmentOverMaximum  = "5"
+	IK3NotInTransactionSet = "6"
+	IK3OutOfSequence       = "7"
+	IK3DataElementErrors   = "8"
+)
+
+// IK403 data element syntax error codes.
+const (
+	IK4MissingElement     = "1"
+	IK4MissingConditional = "2"
+	IK4TooManyElements    = "3"
+	IK4TooShort           = "4"
+	IK4TooLong            = "5"
+	IK4InvalidCharacter   = "6"
+	IK4InvalidCode        = "7"
+	IK4InvalidDate        = "8"
+	IK4InvalidTime        = "9"
+	IK4ExclusionViolated  = "10"
+	IK4TooManyRepetitions = "12"
+	IK4TooManyComponents  = "13"
+)
+
+// maxSnippet bounds Diagnostic.Snippet. Segments carry member data, so the
+// snippet is no longer than it needs to be to find the segment by eye.
+const maxSnippet = 80
+
+// Diagnostic is a segment or data element problem found while parsing a
+// transaction set. It is located precisely enough to fix the file by hand,
+// and its codes are the ones a 999 reports: SegmentCode is IK304 and, for an
+// element note, ElementCode is IK403. A missing segment is located at the
+// segment that opens the loop lacking it.
+type Diagnostic struct {
+	// Interchange, Group and Transaction are the control numbers (ISA13,
+	// GS06, ST02) of the enclosing envelopes.
+	Interchange string `json:"interchange_control_number,omitempty"`
+	Group       string `json:"group_control_number,omitempty"`
+	Transaction string `json:"transaction_control_number,omitempty"`
+
+	SegmentID string `json:"segment_id"`
+	// Index, Position and Offset are the segment's Pos: its ordinal in the
+	// file, its ordinal in the transaction set (IK302) and its byte offset.
+	Index    int   `json:"segment_index"`
+	Position int   `json:"segment_position"`
+	Offset   int64 `json:"offset"`
+	// Loop is the path of loops enclosing the segment, outermost first, e.g.
+	// 2000A/2000B/2300/2400. Its last element is IK303.
+	Loop        string `json:"loop,omitempty"`
+	SegmentCode string `json:"segment_code"`
+
+	// Element, Component and Repeat are IK401, 1-based; Element is zero in
+	// a segment note.
+	Element     int    `json:"element,omitempty"`
+	Component   int    `json:"component,omitempty"`
+	Repeat      int    `json:"repeat,omitempty"`
+	ElementCode string `json:"element_code,omitempty"`
+	// Value is the offending data (IK404).
+	Value string `json:"value,omitempty"`
+
+	Msg string `json:"message"`
+	// Snippet is the start of the segment as it appeared in the file.
+	Snippet string `json:"snippet"`
+}
+
+func (d *Diagnostic) Error() string {
+	where := fmt.Sprintf("segment %d (%s)", d.Index, d.SegmentID)
+	if d.Element > 0 {
+		where += fmt.Sprintf(" element %02d", d.Element)
+		if d.Component > 0 {
+			where += fmt.Sprintf("-%d", d.Component)
+		}
+	}
+	if d.Loop != "" {
+		where += " in " + d.Loop
+	}
+	return fmt.Sprintf("x12: %s: %s", where, d.Msg)
+}
+
+// LoopID returns the innermost loop of Loop, the IK303 loop identifier.
+func (d *Diagnostic) LoopID() string {
+	if i := strings.LastIndexByte(d.Loop, '/'); i >= 0 {
+		return d.Loop[i+1:]
+	}
+	return d.Loop
+}
+
+// Diagnose returns a segment note for seg, which r has just read, with its
+// location, envelope control numbers and snippet filled in.
+func (r *Reader) Diagnose(seg *Segment, loop, code, format string, args ...interface{}) *Diagnostic {
+	d := &Diagnostic{
+		SegmentID:   seg.ID,
+		Index:       seg.Pos.Index,
+		Position:    seg.Pos.TxIndex,
+		Offset:      seg.Pos.Offset,
+		Loop:        loop,
+		SegmentCode: code,
+		Msg:         fmt.Sprintf(format, args...),
+		Snippet:     Snippet(seg),
+	}
+	if ic := r.Interchange(); ic != nil {
+		d.Interchange = ic.ControlNumber
+	}
+	if g := r.Group(); g != nil {
+		d.Group = g.ControlNumber
+	}
+	if st := r.Transaction(); st != nil && seg.Pos.TxIndex > 0 {
+		d.Transaction = st.ControlNumber
+	}
+	return d
+}
+
+// DiagnoseElement returns an element note for component comp of element n
+// of seg; comp is zero for a simple element.
+func (r *Reader) DiagnoseElement(seg *Segment, loop string, n, comp int, code, format string, args ...interface{}) *Diagnostic {
+	d := r.Diagnose(seg, loop, IK3DataElementErrors, format, args...)
+	d.Element, d.Component, d.ElementCode = n, comp, code
+	if comp > 0 {
+		d.Value = seg.Component(n, comp)
+	} else {
+		d.Value = seg.Element(n)
+	}
+	return d
+}
+
+// Snippet renders seg as it appeared in the file, cut to a bounded length.
+func Snippet(seg *Segment) string {
+	s := seg.String()
+	if len(s) > maxSnippet {
+		return s[:maxSnippet-3] + "..."
+	}
+	return s
+}
+
+// ValidDecimal reports whether s is an X12 decimal (R) value: an optional
+// minus sign and digits with at most one decimal point.
+func ValidDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	digits, point := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			digits++
+		case s[i] == '.' && !point:
+			point = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
@@ -0,0 +1,371 @@
+package x12
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Interchange is the typed form of an ISA header.
+type Interchange struct {
+	SenderQualifier   string
+	SenderID          string
+	ReceiverQualifier string
+	ReceiverID        string
+	Date              string
+	Time              string
+	Version           string
+	ControlNumber     string
+	// AckRequested reports ISA14 = 1, a request for a TA1.
+	AckRequested bool
+	// Usage is P for production and T for test data.
+	Usage      string
+	Delimiters Delimiters
+	Pos        Position
+}
+
+// Group is the typed form of a GS header.
+type Group struct {
+	// FunctionalID is GS01, e.g. HC for 837, HP for 835, BE for 834.
+	FunctionalID  string
+	SenderCode    string
+	ReceiverCode  string
+	Date          string
+	Time          string
+	ControlNumber string
+	// Version is the implementation convention, e.g. 005010X222A1.
+	Version string
+	Pos     Position
+}
+
+// Transaction is the typed form of an ST header.
+type Transaction struct {
+	// SetID is ST01, e.g. 837.
+	SetID             string
+	ControlNumber     string
+	ImplementationRef string
+	Pos               Position
+}
+
+// functionalIDs maps transaction sets to the GS01 code that must enclose
+// them. Sets not listed are not checked.
+var functionalIDs = map[string]string{
+	"270": "HS",
+	"271": "HB",
+	"276": "HR",
+	"277": "HN",
+	"820": "RA",
+	"834": "BE",
+	"835": "HP",
+	"837": "HC",
+	"997": "FA",
+	"999": "FA",
+}
+
+// Level says which envelope an EnvelopeError belongs to, and so which
+// acknowledgment segment reports it.
+type Level int
+
+const (
+	// LevelInterchange errors are reported in a TA1 (TA105).
+	LevelInterchange Level = iota + 1
+	// LevelGroup errors are reported in AK9 (AK905).
+	LevelGroup
+	// LevelTransaction errors are reported in IK5 (IK502).
+	LevelTransaction
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelInterchange:
+		return "interchange"
+	case LevelGroup:
+		return "group"
+	case LevelTransaction:
+		return "transaction set"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// TA1 interchange note codes (TA105).
+const (
+	TA1ControlNumberMismatch = "001"
+	TA1InvalidControlNumber  = "018"
+	TA1GroupCountMismatch    = "021"
+	TA1PrematureEnd          = "023"
+	TA1InvalidContent        = "024"
+)
+
+// AK9 functional group error codes (AK905).
+const (
+	AK9TrailerMissing            = "3"
+	AK9ControlNumberMismatch     = "4"
+	AK9TransactionCountMismatch  = "5"
+	AK9InvalidGroupControlNumber = "6"
+)
+
+// IK5 transaction set error codes (IK502).
+const (
+	IK5TrailerMissing        = "2"
+	IK5ControlNumberMismatch = "3"
+	IK5SegmentCountMismatch  = "4"
+	IK5SegmentsInError       = "5"
+	IK5InvalidSetIdentifier  = "6"
+	IK5InvalidControlNumber  = "7"
+)
+
+// EnvelopeError is a structural problem in an interchange, group or
+// transaction set envelope.
+type EnvelopeError struct {
+	Level Level
+	// Code is the acknowledgment code for Level (TA105, AK905 or IK502).
+	Code string
+	// ControlNumber identifies the envelope at Level (ISA13, GS06 or ST02).
+	ControlNumber string
+	// SegmentID and Pos locate the segment where the problem was detected;
+	// for a missing trailer this is the segment that ended the envelope.
+	SegmentID string
+	Pos       Position
+	Msg       string
+}
+
+func (e *EnvelopeError) Error() string {
+	where := "end of input"
+	if e.SegmentID != "" {
+		where = fmt.Sprintf("segment %d (%s)", e.Pos.Index, e.SegmentID)
+	}
+	return fmt.Sprintf("x12: %s: %s %s: %s", where, e.Level, e.ControlNumber, e.Msg)
+}
+
+// envelope tracks the open ISA/GS/ST envelopes and their counts.
+type envelope struct {
+	isa *Interchange
+	gs  *Group
+	st  *Transaction
+
+	inISA, inGS, inST bool
+	groups            int
+	sets              int
+	segments          int
+}
+
+func (e *envelope) track(r *Reader, seg *Segment) {
+	report := func(level Level, code, format string, args ...interface{}) {
+		r.errs = append(r.errs, &EnvelopeError{
+			Level:         level,
+			Code:          code,
+			ControlNumber: e.controlNumber(level),
+			SegmentID:     seg.ID,
+			Pos:           seg.Pos,
+			Msg:           fmt.Sprintf(format, args...),
+		})
+	}
+
+	switch seg.ID {
+	case "ISA":
+		e.closeST(report)
+		e.closeGS(report)
+		if e.inISA {
+			report(LevelInterchange, TA1PrematureEnd, "IEA missing before next ISA")
+		}
+		e.isa = parseISA(seg)
+		e.inISA, e.groups = true, 0
+		if !isDigits(e.isa.ControlNumber, 9, 9) {
+			report(LevelInterchange, TA1InvalidControlNumber, "control number %q is not 9 digits", e.isa.ControlNumber)
+		}
+
+	case "GS":
+		e.closeST(report)
+		e.closeGS(report)
+		if !e.inISA {
+			report(LevelInterchange, TA1InvalidContent, "GS outside an interchange")
+		}
+		e.gs = &Group{
+			FunctionalID:  seg.Element(1),
+			SenderCode:    seg.Element(2),
+			ReceiverCode:  seg.Element(3),
+			Date:          seg.Element(4),
+			Time:          seg.Element(5),
+			ControlNumber: seg.Element(6),
+			Version:       seg.Element(8),
+			Pos:           seg.Pos,
+		}
+		e.inGS, e.sets = true, 0
+		if !isDigits(e.gs.ControlNumber, 1, 9) {
+			report(LevelGroup, AK9InvalidGroupControlNumber, "control number %q is not 1-9 digits", e.gs.ControlNumber)
+		}
+
+	case "ST":
+		e.closeST(report)
+		if !e.inGS {
+			report(LevelInterchange, TA1InvalidContent, "ST outside a functional group")
+		}
+		e.st = &Transaction{
+			SetID:             seg.Element(1),
+			ControlNumber:     seg.Element(2),
+			ImplementationRef: seg.Element(3),
+			Pos:               seg.Pos,
+		}
+		e.inST, e.segments = true, 1
+		seg.Pos.TxIndex = 1
+		if n := len(e.st.ControlNumber); n < 4 || n > 9 {
+			report(LevelTransaction, IK5InvalidControlNumber, "control number %q is not 4-9 characters", e.st.ControlNumber)
+		}
+		if want, ok := functionalIDs[e.st.SetID]; !ok && !isDigits(e.st.SetID, 3, 3) {
+			report(LevelTransaction, IK5InvalidSetIdentifier, "invalid transaction set identifier %q", e.st.SetID)
+		} else if ok && e.gs != nil && e.gs.FunctionalID != want {
+			report(LevelTransaction, IK5InvalidSetIdentifier, "transaction set %s in a %s group, want %s", e.st.SetID, e.gs.FunctionalID, want)
+		}
+
+	case "SE":
+		if !e.inST {
+			report(LevelInterchange, TA1InvalidContent, "SE without ST")
+			return
+		}
+		e.segments++
+		seg.Pos.TxIndex = e.segments
+		if n, err := strconv.Atoi(seg.Element(1)); err != nil || n != e.segments {
+			report(LevelTransaction, IK5SegmentCountMismatch, "SE01 %q does not match %d segments", seg.Element(1), e.segments)
+		}
+		if seg.Element(2) != e.st.ControlNumber {
+			report(LevelTransaction, IK5ControlNumberMismatch, "SE02 %q does not match ST02 %q", seg.Element(2), e.st.ControlNumber)
+		}
+		e.inST = false
+		e.sets++
+
+	case "GE":
+		e.closeST(report)
+		if !e.inGS {
+			report(LevelInterchange, TA1InvalidContent, "GE without GS")
+			return
+		}
+		if n, err := strconv.Atoi(seg.Element(1)); err != nil || n != e.sets {
+			report(LevelGroup, AK9TransactionCountMismatch, "GE01 %q does not match %d transaction sets", seg.Element(1), e.sets)
+		}
+		if seg.Element(2) != e.gs.ControlNumber {
+			report(LevelGroup, AK9ControlNumberMismatch, "GE02 %q does not match GS06 %q", seg.Element(2), e.gs.ControlNumber)
+		}
+		e.inGS = false
+		e.groups++
+
+	case "IEA":
+		e.closeST(report)
+		e.closeGS(report)
+		if !e.inISA {
+			report(LevelInterchange, TA1InvalidContent, "IEA without ISA")
+			return
+		}
+		if n, err := strconv.Atoi(seg.Element(1)); err != nil || n != e.groups {
+			report(LevelInterchange, TA1GroupCountMismatch, "IEA01 %q does not match %d functional groups", seg.Element(1), e.groups)
+		}
+		if seg.Element(2) != e.isa.ControlNumber {
+			report(LevelInterchange, TA1ControlNumberMismatch, "IEA02 %q does not match ISA13 %q", seg.Element(2), e.isa.ControlNumber)
+		}
+		e.inISA = false
+
+	default:
+		if e.inST {
+			e.segments++
+			seg.Pos.TxIndex = e.segments
+		}
+		switch {
+		case seg.ID == "TA1" && e.inISA && !e.inGS:
+			// Interchange acknowledgments sit between ISA and the first GS.
+		case !e.inST:
+			report(LevelInterchange, TA1InvalidContent, "segment outside a transaction set")
+		case !validSegmentID(seg.ID):
+			report(LevelTransaction, IK5SegmentsInError, "invalid segment id %q", seg.ID)
+		}
+	}
+}
+
+// finish reports envelopes still open at the end of input.
+func (e *envelope) finish(r *Reader) {
+	report := func(level Level, code, format string, args ...interface{}) {
+		r.errs = append(r.errs, &EnvelopeError{
+			Level:         level,
+			Code:          code,
+			ControlNumber: e.controlNumber(level),
+			Msg:           fmt.Sprintf(format, args...),
+		})
+	}
+	e.closeST(report)
+	e.closeGS(report)
+	if e.inISA {
+		report(LevelInterchange, TA1PrematureEnd, "IEA missing")
+		e.inISA = false
+	}
+}
+
+type reportFunc func(level Level, code, format string, args ...interface{})
+
+func (e *envelope) closeST(report reportFunc) {
+	if e.inST {
+		report(LevelTransaction, IK5TrailerMissing, "SE missing")
+		e.inST = false
+		e.sets++
+	}
+}
+
+func (e *envelope) closeGS(report reportFunc) {
+	if e.inGS {
+		report(LevelGroup, AK9TrailerMissing, "GE missing")
+		e.inGS = false
+		e.groups++
+	}
+}
+
+func (e *envelope) controlNumber(level Level) string {
+	switch {
+	case level == LevelInterchange && e.isa != nil:
+		return e.isa.ControlNumber
+	case level == LevelGroup && e.gs != nil:
+		return e.gs.ControlNumber
+	case level == LevelTransaction && e.st != nil:
+		return e.st.ControlNumber
+	}
+	return ""
+}
+
+func parseISA(seg *Segment) *Interchange {
+	return &Interchange{
+		SenderQualifier:   seg.Element(5),
+		SenderID:          strings.TrimSpace(seg.Element(6)),
+		ReceiverQualifier: seg.Element(7),
+		ReceiverID:        strings.TrimSpace(seg.Element(8)),
+		Date:              seg.Element(9),
+		Time:              seg.Element(10),
+		Version:           seg.Element(12),
+		ControlNumber:     seg.Element(13),
+		AckRequested:      seg.Element(14) == "1",
+		Usage:             seg.Element(15),
+		Delimiters:        seg.delims,
+		Pos:               seg.Pos,
+	}
+}
+
+func isDigits(s string, min, max int) bool {
+	if len(s) < min || len(s) > max {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validSegmentID reports whether id is two or three upper-case letters or
+// digits, starting with a letter.
+func validSegmentID(id string) bool {
+	if len(id) < 2 || len(id) > 3 || id[0] < 'A' || id[0] > 'Z' {
+		return false
+	}
+	for i := 1; i < len(id); i++ {
+		if !(id[i] >= 'A' && id[i] <= 'Z' || id[i] >= '0' && id[i] <= '9') {
+			return false
+		}
+	}
+	return true
+}
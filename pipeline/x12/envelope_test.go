@@ -0,0 +1,142 @@
+package x12
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interchange builds an interchange from segments between ISA and IEA.
+func interchange(control string, body ...string) string {
+	return isaHeader(control) + strings.Join(body, "~") + "~"
+}
+
+func TestValidateEnvelopeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		level Level
+		code  string
+		ctrl  string
+	}{
+		{
+			name:  "segment count",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "BHT*0019", "SE*4*0001", "GE*1*7", "IEA*1*000000001"),
+			level: LevelTransaction, code: IK5SegmentCountMismatch, ctrl: "0001",
+		},
+		{
+			name:  "SE control number",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "SE*2*0002", "GE*1*7", "IEA*1*000000001"),
+			level: LevelTransaction, code: IK5ControlNumberMismatch, ctrl: "0001",
+		},
+		{
+			name:  "missing SE",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "BHT*0019", "GE*1*7", "IEA*1*000000001"),
+			level: LevelTransaction, code: IK5TrailerMissing, ctrl: "0001",
+		},
+		{
+			name:  "short ST control number",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*01", "SE*2*01", "GE*1*7", "IEA*1*000000001"),
+			level: LevelTransaction, code: IK5InvalidControlNumber, ctrl: "01",
+		},
+		{
+			name:  "set in wrong group",
+			input: interchange("000000001", "GS*HP*A*B*20251121*1000*7*X*005010X221A1", "ST*837*0001", "SE*2*0001", "GE*1*7", "IEA*1*000000001"),
+			level: LevelTransaction, code: IK5InvalidSetIdentifier, ctrl: "0001",
+		},
+		{
+			name:  "bad segment id",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "bht*0019", "SE*3*0001", "GE*1*7", "IEA*1*000000001"),
+			level: LevelTransaction, code: IK5SegmentsInError, ctrl: "0001",
+		},
+		{
+			name:  "GE count",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "SE*2*0001", "GE*2*7", "IEA*1*000000001"),
+			level: LevelGroup, code: AK9TransactionCountMismatch, ctrl: "7",
+		},
+		{
+			name:  "GE control number",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "SE*2*0001", "GE*1*8", "IEA*1*000000001"),
+			level: LevelGroup, code: AK9ControlNumberMismatch, ctrl: "7",
+		},
+		{
+			name:  "missing GE",
+			input: interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "SE*2*0001", "IEA*1*000000001"),
+			level: LevelGroup, code: AK9TrailerMissing, ctrl: "7",
+		},
+		{
+			name:  "IEA count",
+			input: interchange("000000001", "IEA*1*000000001"),
+			level: LevelInterchange, code: TA1GroupCountMismatch, ctrl: "000000001",
+		},
+		{
+			name:  "IEA control number",
+			input: interchange("000000001", "IEA*0*000000002"),
+			level: LevelInterchange, code: TA1ControlNumberMismatch, ctrl: "000000001",
+		},
+		{
+			name:  "ISA control number",
+			input: interchange("00000000A", "IEA*0*00000000A"),
+			level: LevelInterchange, code: TA1InvalidControlNumber, ctrl: "00000000A",
+		},
+		{
+			name:  "segment outside set",
+			input: interchange("000000001", "BHT*0019", "IEA*0*000000001"),
+			level: LevelInterchange, code: TA1InvalidContent, ctrl: "000000001",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs, err := Validate(strings.NewReader(tc.input))
+			require.NoError(t, err)
+			require.Len(t, errs, 1, "%v", errs)
+			assert.Equal(t, tc.level, errs[0].Level)
+			assert.Equal(t, tc.code, errs[0].Code)
+			assert.Equal(t, tc.ctrl, errs[0].ControlNumber)
+		})
+	}
+}
+
+func TestValidateAllowsTA1BeforeGroups(t *testing.T) {
+	errs, err := Validate(strings.NewReader(interchange("000000001", "TA1*000000009*251121*1000*A*000", "IEA*0*000000001")))
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	errs, err = Validate(strings.NewReader(interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "TA1*000000009*251121*1000*A*000", "GE*0*7", "IEA*1*000000001")))
+	require.NoError(t, err)
+	require.Len(t, errs, 1, "TA1 inside a group is out of place")
+	assert.Equal(t, TA1InvalidContent, errs[0].Code)
+}
+
+func TestValidateTruncatedFile(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "837p.x12"))
+	require.NoError(t, err)
+
+	// Cut in the middle of the claim loop.
+	cut := strings.Index(string(data), "LX*2")
+	errs, err := Validate(strings.NewReader(string(data[:cut])))
+	require.NoError(t, err)
+
+	var codes []string
+	for _, e := range errs {
+		codes = append(codes, e.Level.String()+":"+e.Code)
+		assert.Empty(t, e.SegmentID, "End-of-input errors have no segment")
+	}
+	assert.Equal(t, []string{"transaction set:2", "group:3", "interchange:023"}, codes)
+	assert.Contains(t, errs[0].Error(), "end of input")
+}
+
+func TestEnvelopeErrorLocatesSegment(t *testing.T) {
+	errs, err := Validate(strings.NewReader(interchange("000000001", "GS*HC*A*B*20251121*1000*7*X*005010X222A1", "ST*837*0001", "BHT*0019", "SE*9*0001", "GE*1*7", "IEA*1*000000001")))
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+
+	e := errs[0]
+	assert.Equal(t, "SE", e.SegmentID)
+	assert.Equal(t, 5, e.Pos.Index)
+	assert.Equal(t, 3, e.Pos.TxIndex)
+	assert.Equal(t, `x12: segment 5 (SE): transaction set 0001: SE01 "9" does not match 3 segments`, e.Error())
+}
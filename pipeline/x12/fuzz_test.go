@@ -0,0 +1,142 @@
+package x12
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func addFixtures(f *testing.F) {
+	f.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.x12"))
+	require.NoError(f, err)
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+}
+
+// FuzzReader checks that arbitrary input never panics and that every segment
+// returned can be found verbatim at its reported offset.
+func FuzzReader(f *testing.F) {
+	addFixtures(f)
+	f.Add([]byte("ISA*"))
+	f.Add([]byte(isaHeader("000000001") + "GS*HC~ST*837~SE~GE~IEA~"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := NewReader(strings.NewReader(string(data)))
+		prev := int64(-1)
+		for i := 1; ; i++ {
+			seg, err := r.Next()
+			if err != nil {
+				// Errors are sticky.
+				_, again := r.Next()
+				if again != err {
+					t.Fatalf("Next returned %v after %v", again, err)
+				}
+				return
+			}
+			if seg.Pos.Index != i {
+				t.Fatalf("segment %d has index %d", i, seg.Pos.Index)
+			}
+			if seg.Pos.Offset <= prev || seg.Pos.Offset >= int64(len(data)) {
+				t.Fatalf("segment %d offset %d out of order (previous %d, len %d)", i, seg.Pos.Offset, prev, len(data))
+			}
+			if !strings.HasPrefix(string(data[seg.Pos.Offset:]), seg.String()) {
+				t.Fatalf("segment %d %q not found at offset %d", i, seg.String(), seg.Pos.Offset)
+			}
+			prev = seg.Pos.Offset
+		}
+	})
+}
+
+// FuzzDelimiters re-encodes a fixture with arbitrary separators and checks it
+// tokenizes to the same elements.
+func FuzzDelimiters(f *testing.F) {
+	f.Add(byte('*'), byte(':'), byte('^'), byte('~'))
+	f.Add(byte('|'), byte('>'), byte('!'), byte('\n'))
+	f.Add(byte(0x1d), byte(0x1f), byte(0x1e), byte(0x1c))
+
+	data, err := os.ReadFile(filepath.Join("testdata", "835.x12"))
+	require.NoError(f, err)
+	want := readAll(f, NewReader(strings.NewReader(string(data))))
+	var values strings.Builder
+	for _, seg := range want {
+		for i, e := range seg.Elements {
+			if seg.ID != "ISA" || (i != 10 && i != 15) {
+				values.WriteString(strings.ReplaceAll(e, ":", ""))
+			}
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, el, sub, rep, term byte) {
+		d := Delimiters{Element: el, SubElement: sub, Repetition: rep, Segment: term}
+		if !usable(d, values.String()) {
+			t.Skip()
+		}
+
+		var b strings.Builder
+		for _, seg := range want {
+			b.WriteString(seg.ID)
+			for i, e := range seg.Elements {
+				b.WriteByte(el)
+				switch {
+				case seg.ID == "ISA" && i == 10:
+					b.WriteByte(rep)
+				case seg.ID == "ISA" && i == 15:
+					b.WriteByte(sub)
+				default:
+					b.WriteString(strings.ReplaceAll(e, ":", sepString(sub)))
+				}
+			}
+			b.WriteByte(term)
+		}
+
+		r := NewReader(strings.NewReader(b.String()))
+		got := readAll(t, r)
+		if r.Delimiters() != d {
+			t.Fatalf("detected %+v, want %+v", r.Delimiters(), d)
+		}
+		if len(r.Errors()) > 0 {
+			t.Fatalf("envelope errors: %v", r.Errors())
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d segments, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i].ID == "ISA" {
+				continue
+			}
+			for n := 1; n <= len(want[i].Elements); n++ {
+				if strings.Join(got[i].Components(n), ":") != want[i].Element(n) {
+					t.Fatalf("segment %d element %d: got %q, want %q", i+1, n, got[i].Element(n), want[i].Element(n))
+				}
+			}
+		}
+	})
+}
+
+// usable reports whether d can re-encode the fixture: separators must be
+// distinct, not alphanumeric, space or NUL, absent from the element values, and
+// line breaks (which the reader skips between segments) may only terminate.
+func usable(d Delimiters, values string) bool {
+	seps := []byte{d.Element, d.SubElement, d.Repetition, d.Segment}
+	for i, b := range seps {
+		if isAlnum(b) || b == ' ' || b == 0 || b == '\r' || strings.IndexByte(values, b) >= 0 {
+			return false
+		}
+		if b == '\n' && i != 3 {
+			return false
+		}
+		for _, c := range seps[i+1:] {
+			if b == c {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package mapping
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed specs/*.yaml
+var specFiles embed.FS
+
+// Builtin returns a built-in mapping: 834, 835, 837i or 837p. Each produces
+// the CSVs of the matching x834, x835 or x837 writer.
+func Builtin(name string) (*Mapping, error) {
+	data, err := specFiles.ReadFile("specs/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("mapping: no built-in spec %q", name)
+	}
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("specs/%s.yaml: %w", name, err)
+	}
+	return New(spec)
+}
+
+// BuiltinNames lists the built-in mappings.
+func BuiltinNames() []string {
+	entries, _ := specFiles.ReadDir("specs")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
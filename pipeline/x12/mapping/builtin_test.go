@@ -0,0 +1,222 @@
+package mapping
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/x12/x834"
+	"claim-management-system/pipeline/x12/x835"
+	"claim-management-system/pipeline/x12/x837"
+)
+
+// handwritten runs the x834, x835 or x837 writer a built-in spec stands in
+// for and returns its CSVs by entity.
+type handwritten func(t *testing.T, r io.Reader, fileID string) map[string]string
+
+func buffers(names ...string) (map[string]*bytes.Buffer, []io.Writer) {
+	bufs := map[string]*bytes.Buffer{}
+	ws := make([]io.Writer, len(names))
+	for i, n := range names {
+		bufs[n] = &bytes.Buffer{}
+		ws[i] = bufs[n]
+	}
+	return bufs, ws
+}
+
+func strs(bufs map[string]*bytes.Buffer) map[string]string {
+	out := map[string]string{}
+	for n, b := range bufs {
+		out[n] = b.String()
+	}
+	return out
+}
+
+func x837Convert(t *testing.T, r io.Reader, fileID string) map[string]string {
+	bufs, ws := buffers("claim_header", "claim_line", "diagnosis", "provider")
+	w, err := x837.NewWriter(fileID, ws[0], ws[1], ws[2], ws[3])
+	require.NoError(t, err)
+	_, _, err = x837.Convert(r, w)
+	require.NoError(t, err)
+	return strs(bufs)
+}
+
+func x834Convert(t *testing.T, r io.Reader, fileID string) map[string]string {
+	bufs, ws := buffers("member", "coverage")
+	w, err := x834.NewWriter(fileID, ws[0], ws[1])
+	require.NoError(t, err)
+	_, _, err = x834.Convert(r, w)
+	require.NoError(t, err)
+	return strs(bufs)
+}
+
+func x835Convert(t *testing.T, r io.Reader, fileID string) map[string]string {
+	bufs, ws := buffers("payment", "claim_payment", "service_payment", "adjustment")
+	w, err := x835.NewWriter(fileID, ws[0], ws[1], ws[2], ws[3])
+	require.NoError(t, err)
+	_, _, _, err = x835.Convert(r, w)
+	require.NoError(t, err)
+	return strs(bufs)
+}
+
+var builtins = []struct {
+	name    string
+	golden  string
+	convert handwritten
+	columns map[string][]string
+}{
+	{"837p", "../x837/testdata/837p", x837Convert, map[string][]string{
+		"claim_header": x837.ClaimHeaderColumns,
+		"claim_line":   x837.ClaimLineColumns,
+		"diagnosis":    x837.DiagnosisColumns,
+		"provider":     x837.ProviderColumns,
+	}},
+	{"837i", "../x837/testdata/837i", x837Convert, map[string][]string{
+		"claim_header": x837.ClaimHeaderColumns,
+		"claim_line":   x837.ClaimLineColumns,
+		"diagnosis":    x837.DiagnosisColumns,
+		"provider":     x837.ProviderColumns,
+	}},
+	{"834", "../x834/testdata/834", x834Convert, map[string][]string{
+		"member":   x834.MemberColumns,
+		"coverage": x834.CoverageColumns,
+	}},
+	{"835", "../x835/testdata/835", x835Convert, map[string][]string{
+		"payment":         x835.PaymentColumns,
+		"claim_payment":   x835.ClaimPaymentColumns,
+		"service_payment": x835.ServicePaymentColumns,
+		"adjustment":      x835.AdjustmentColumns,
+	}},
+}
+
+// convert runs a built-in mapping over input and returns its CSVs by entity.
+func convert(t *testing.T, name, input, fileID string) map[string]string {
+	t.Helper()
+	m, err := Builtin(name)
+	require.NoError(t, err)
+
+	outputs := map[string]io.Writer{}
+	bufs := map[string]*bytes.Buffer{}
+	for _, e := range m.Entities() {
+		bufs[e] = &bytes.Buffer{}
+		outputs[e] = bufs[e]
+	}
+	w, err := m.NewWriter(fileID, outputs)
+	require.NoError(t, err)
+	_, envErrs, err := Convert(strings.NewReader(input), w)
+	require.NoError(t, err)
+	require.Empty(t, envErrs)
+	return strs(bufs)
+}
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "testdata", name+".x12"))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBuiltinNames(t *testing.T) {
+	assert.Equal(t, []string{"834", "835", "837i", "837p"}, BuiltinNames())
+	_, err := Builtin("270")
+	assert.Error(t, err, "There is no built-in eligibility inquiry spec")
+}
+
+func TestBuiltinColumns(t *testing.T) {
+	for _, b := range builtins {
+		m, err := Builtin(b.name)
+		require.NoError(t, err, b.name)
+		assert.Len(t, m.Entities(), len(b.columns), "%s entities", b.name)
+		for _, e := range m.Entities() {
+			assert.Equal(t, b.columns[e], m.Columns(e), "%s %s columns follow the handwritten writer", b.name, e)
+		}
+	}
+}
+
+func TestBuiltinGolden(t *testing.T) {
+	for _, b := range builtins {
+		t.Run(b.name, func(t *testing.T) {
+			got := convert(t, b.name, readFixture(t, b.name), "f-"+b.name)
+			for e, csv := range got {
+				want, err := os.ReadFile(filepath.Join(b.golden, e+".csv"))
+				require.NoError(t, err)
+				assert.Equal(t, string(want), csv, "%s matches the handwritten golden CSV", e)
+			}
+		})
+	}
+}
+
+// TestBuiltinMatchesHandwritten covers what the fixtures do not: patients
+// other than the subscriber, line level providers, dependents, terminations
+// and provider adjustments.
+func TestBuiltinMatchesHandwritten(t *testing.T) {
+	const isa = "ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       *251121*1000*^*00501*000000201*0*T*:~"
+	cases := []struct {
+		name, spec, body string
+	}{
+		{"837p patient and line providers", "837p", isa +
+			"GS*HC*S*R*20251121*1000*201*X*005010X222A1~ST*837*0001*005010X222A1~BHT*0019*00*B1*20251121*1000*CH~" +
+			"HL*1**20*1~PRV*BI*PXC*111~NM1*85*2*CLINIC*****XX*1000000001~N3*1 MAIN ST*STE 2~N4*TOWN*IL*60000~REF*EI*990000001~" +
+			"HL*2*1*22*1~SBR*P**GRP******CI~NM1*IL*1*DOE*JOHN****MI*SUB1~DMG*D8*19700101*M~NM1*PR*2*PAYER*****PI*P1~" +
+			"HL*3*2*23*0~PAT*19~NM1*QC*1*DOE*KID~DMG*D8*20150505*F~" +
+			"CLM*C1*300***11:B:1*Y*A*Y*Y~DTP*434*RD8*20251001-20251002~REF*F8*ORIG1~HI*BK:J10*BF:R05:::::::Y~" +
+			"NM1*DN*1*REF*ERIN****XX*1000000002~NM1*77*2*FACILITY*****XX*1000000003~N3*9 SIDE ST~N4*TOWN*IL*60001~" +
+			"LX*1~SV1*HC:99213:25:59:GT:76:77*100*UN*1*11**1:2:3~DTP*472*D8*20251001~" +
+			"NM1*82*1*LINE*RAY****XX*1000000004~PRV*PE*PXC*222~NM1*DK*1*ORDER*OLA****XX*1000000005~N3*5 LANE~N4*TOWN*IL*60002~" +
+			"LX*2~SV1*HC:99214*200*UN*2~DTP*472*RD8*20250930-20251002~" +
+			"SE*37*0001~GE*1*201~IEA*1*000000201~"},
+		{"837i claim providers", "837i", isa +
+			"GS*HC*S*R*20251121*1000*201*X*005010X223A3~ST*837*0001*005010X223A3~BHT*0019*00*B1*20251121*1000*CH~" +
+			"HL*1**20*1~PRV*BI*PXC*282N00000X~NM1*85*2*HOSPITAL*****XX*1000000001~REF*EI*990000001~" +
+			"HL*2*1*22*0~SBR*P*18*GRP******MB~NM1*IL*1*ROE*RAY****MI*SUB2~NM1*PR*2*PAYER*****PI*P1~" +
+			"CLM*C2*900***13:A:7**A*Y*Y~DTP*434*RD8*20251001-20251003~CL1*2*1*01~REF*F8*ORIG2~HI*ABK:I10~" +
+			"NM1*71*1*ATTEND*ANN****XX*1000000006~PRV*AT*PXC*207R00000X~NM1*72*1*OPER*OTTO****XX*1000000007~" +
+			"NM1*82*1*RENDER*RITA****XX*1000000008~" +
+			"LX*1~SV2*0300*HC:80053:QW*300*UN*1~DTP*472*D8*20251002~NM1*82*1*LINE*LEE****XX*1000000010~" +
+			"LX*2~SV2*0120**600*DA*3~" +
+			"SE*26*0001~GE*1*201~IEA*1*000000201~"},
+		{"834 dependents and terminations", "834", isa +
+			"GS*BE*S*R*20251121*1000*201*X*005010X220A1~ST*834*0001*005010X220A1~BGN*00*REF1*20251121*1000****2~REF*38*POL~" +
+			"N1*P5*SPONSOR*FI*111~N1*IN*PAYER*FI*222~" +
+			"INS*Y*18*024*07*A***TE~REF*0F*S1~REF*1L*G1~DTP*357*D8*20251130~NM1*IL*1*ONE*AL~" +
+			"NM1*31*1~N3*PO BOX 1~N4*ELSEWHERE*IL*60009~" +
+			"HD*024**HLT*PLAN A*FAM~DTP*348*D8*20240101~HD***DEN*PLAN D*FAM~DTP*348*D8*20240101~DTP*349*D8*20251015~" +
+			"LX*1~NM1*P3*1*PCP*PAT~DTP*348*D8*20240201~" +
+			"INS*N*01*021*28*A~REF*0F*S1~REF*ZZ*S1-SPOUSE~NM1*IL*1*ONE*BEA~DMG*D8*19750707*F~" +
+			"HD*021**HLT*PLAN A*FAM~DTP*348*D8*20251201~DTP*303*D8*20251120~" +
+			"INS*N*19*001*25*A~REF*0F*S1~NM1*IL*1*ONE*CY~HD*001**HLT*PLAN A*FAM~" +
+			"SE*34*0001~" +
+			"ST*834*0002*005010X220A1~BGN*00*REF2*20251122*1000****4~N1*P5*SPONSOR*FI*111~N1*IN*PAYER*FI*222~" +
+			"INS*Y*18*030*XN*A~REF*0F*S9~NM1*IL*1*NINE*NED~HD*030**VIS*PLAN V*EMP~DTP*348*D8*20250101~" +
+			"SE*10*0002~GE*2*201~IEA*1*000000201~"},
+		{"835 claim and provider adjustments", "835", isa +
+			"GS*HP*S*R*20251121*1000*201*X*005010X221A1~ST*835*0001*005010X221A1~" +
+			"BPR*I*95*C*CHK************20251121~TRN*1*CHK1*1512345678~DTM*405*20251120~" +
+			"N1*PR*PAYER~REF*2U*P1~N1*PE*CLINIC*XX*1000000001~REF*TJ*990000001~" +
+			"LX*1~CLP*C1*1*150*100*25*12*CTL1*11*1~CAS*PR*1*25~CAS*CO*45*25*1*94*0~NM1*QC*1*DOE*KID****MI*M2~NM1*IL*1*DOE*JOHN****MI*SUB1~" +
+			"DTM*232*20251001~DTM*233*20251002~" +
+			"SVC*HC:99213:25:59*150*100**1~DTM*150*20251001~DTM*151*20251002~REF*6R*LINE1~AMT*B6*125~CAS*CO*45*25~" +
+			"CLP*C2*4*50*0**12*CTL2*11*1~CAS*CO*50*50~" +
+			"PLB*1000000001*20251231*WO:C0*5*L6*-1~" +
+			"SE*26*0001~GE*1*201~IEA*1*000000201~"},
+	}
+	convs := map[string]handwritten{}
+	for _, b := range builtins {
+		convs[b.name] = b.convert
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			want := convs[c.spec](t, strings.NewReader(c.body), "f1")
+			got := convert(t, c.spec, c.body, "f1")
+			for e := range want {
+				assert.Equal(t, want[e], got[e], "%s matches the handwritten writer", e)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package mapping
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Writer writes the rows of a Mapping, one CSV per entity.
+type Writer struct {
+	// FileID is the file-metadata file_id of the source X12 file, the
+	// file_id param of the spec.
+	FileID string
+
+	m    *Mapping
+	csvs []*csv.Writer
+}
+
+// NewWriter returns a Writer over one output per entity, keyed by entity
+// name, and writes the header row of each CSV.
+func (m *Mapping) NewWriter(fileID string, outputs map[string]io.Writer) (*Writer, error) {
+	w := &Writer{FileID: fileID, m: m}
+	for _, e := range m.entities {
+		out, ok := outputs[e.name]
+		if !ok {
+			return nil, fmt.Errorf("mapping: no output for entity %s", e.name)
+		}
+		cw := csv.NewWriter(out)
+		if err := cw.Write(m.Columns(e.name)); err != nil {
+			return nil, err
+		}
+		w.csvs = append(w.csvs, cw)
+	}
+	if len(outputs) != len(m.entities) {
+		return nil, fmt.Errorf("mapping: outputs %d, but the spec has %d entities", len(outputs), len(m.entities))
+	}
+	return w, nil
+}
+
+// write writes the rows of every entity for one transaction set.
+func (w *Writer) write(tx *transaction) error {
+	for i, e := range w.m.entities {
+		for _, r := range e.rows(tx) {
+			rec := make([]string, len(e.columns))
+			for j, c := range e.columns {
+				v, err := c.value(r)
+				if err != nil {
+					return fmt.Errorf("mapping: %s.%s: %w", e.name, c.name, err)
+				}
+				rec[j] = v
+			}
+			if err := w.csvs[i].Write(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush flushes every CSV and returns the first error.
+func (w *Writer) Flush() error {
+	for _, cw := range w.csvs {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert reads an X12 stream and writes the rows of each transaction set to
+// w. One transaction set is held in memory at a time. A set missing its SE
+// is written when the next envelope segment or the end of input is reached;
+// the envelope error is in envErrs. err is set when the input cannot be read
+// or tokenized, or holds a transaction set other than the spec's.
+func Convert(r io.Reader, w *Writer) (transactions int, envErrs []*x12.EnvelopeError, err error) {
+	xr := x12.NewReader(r)
+	schema := w.m.schema
+	b := &builder{schema: schema, sequence: map[string]int{}}
+
+	var (
+		isa, gs *x12.Segment
+		tx      *transaction
+	)
+	flush := func() error {
+		if tx == nil {
+			return nil
+		}
+		t := tx
+		tx = nil
+		transactions++
+		return w.write(t)
+	}
+
+	for {
+		seg, err := xr.Next()
+		if err == io.EOF {
+			if err := flush(); err != nil {
+				return transactions, xr.Errors(), err
+			}
+			return transactions, xr.Errors(), w.Flush()
+		}
+		if err != nil {
+			return transactions, xr.Errors(), err
+		}
+
+		switch seg.ID {
+		case "ISA", "GS", "GE", "IEA":
+			if err := flush(); err != nil {
+				return transactions, xr.Errors(), err
+			}
+			switch seg.ID {
+			case "ISA":
+				isa, gs = seg, nil
+			case "GS":
+				gs = seg
+			}
+			continue
+		case "ST":
+			if err := flush(); err != nil {
+				return transactions, xr.Errors(), err
+			}
+			if id := seg.Element(1); id != schema.Transaction {
+				return transactions, xr.Errors(), fmt.Errorf("mapping: transaction set %s %s: spec %s maps %s", id, seg.Element(2), w.m.spec.Name, schema.Transaction)
+			}
+			b.reset()
+			tx = &transaction{fileID: w.FileID, root: b.root}
+			for _, env := range []*x12.Segment{isa, gs} {
+				if env != nil {
+					b.root.segments = append(b.root.segments, env)
+					tx.segments = append(tx.segments, env)
+				}
+			}
+		}
+		if tx == nil {
+			continue
+		}
+		b.add(seg)
+		tx.segments = append(tx.segments, seg)
+		if seg.ID == "SE" {
+			if err := flush(); err != nil {
+				return transactions, xr.Errors(), err
+			}
+		}
+	}
+}
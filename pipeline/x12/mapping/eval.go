@@ -0,0 +1,262 @@
+package mapping
+
+import (
+	"sort"
+	"strconv"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// transaction is one transaction set assigned to loops.
+type transaction struct {
+	fileID string
+	root   *node
+	// segments is the set from ST to SE, preceded by its ISA and GS.
+	segments []*x12.Segment
+}
+
+// row is one row of an entity: an occurrence of a loop or, for Rows.Each,
+// an element group of a segment.
+type row struct {
+	tx   *transaction
+	node *node
+	// ctx is the for_each occurrence, or the root.
+	ctx *node
+	// seg and elem locate the first element of an item row.
+	seg     *x12.Segment
+	elem    int
+	ordinal int
+}
+
+// rows returns the rows of e in tx: per for_each occurrence, the rows of
+// every source in file order.
+func (e *entity) rows(tx *transaction) []*row {
+	if len(e.sources) == 0 {
+		return []*row{{tx: tx, node: tx.root, ctx: tx.root, ordinal: 1}}
+	}
+	ctxs := []*node{tx.root}
+	if e.forEach != "" {
+		ctxs = tx.root.descendants(e.forEach, nil)
+	}
+	var out []*row
+	for _, ctx := range ctxs {
+		base := &row{tx: tx, node: ctx, ctx: ctx}
+		var rows []*row
+		for _, src := range e.sources {
+			rows = append(rows, src.collect(base)...)
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := rows[i].index(), rows[j].index()
+			if a != b {
+				return a < b
+			}
+			return rows[i].elem < rows[j].elem
+		})
+		for i, r := range rows {
+			r.ordinal = i + 1
+		}
+		out = append(out, rows...)
+	}
+	return out
+}
+
+func (s *rowSource) collect(base *row) []*row {
+	var rows []*row
+	for _, id := range s.loops {
+		for _, n := range base.loops(id) {
+			rows = append(rows, &row{tx: base.tx, node: n, ctx: base.ctx})
+		}
+	}
+	if p := s.each; p != nil {
+		for _, n := range base.targets(p) {
+			for _, seg := range n.segments {
+				if !p.matches(seg) {
+					continue
+				}
+				for _, e := range p.elem.positions(len(seg.Elements)) {
+					if !emptyGroup(seg, e, p.elem.step) {
+						rows = append(rows, &row{tx: base.tx, node: n, ctx: base.ctx, seg: seg, elem: e})
+					}
+				}
+			}
+		}
+	}
+
+	kept := rows[:0]
+	for _, r := range rows {
+		if r.holds(s.where) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+func emptyGroup(seg *x12.Segment, from, n int) bool {
+	for e := from; e < from+n; e++ {
+		if seg.Element(e) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *row) index() int {
+	if r.seg != nil {
+		return r.seg.Pos.Index
+	}
+	return r.node.index()
+}
+
+// loops resolves a loop id for the row: the row's occurrence or an ancestor
+// (of the row or of its for_each occurrence) with that id; else the
+// occurrences nested in the row's loop; else a non-repeating occurrence
+// directly under one of those ancestors.
+func (r *row) loops(id string) []*node {
+	if n := r.enclosing(id); n != nil {
+		return []*node{n}
+	}
+	if found := r.node.descendants(id, nil); len(found) > 0 {
+		return found
+	}
+	for _, start := range []*node{r.node, r.ctx} {
+		for n := start; n != nil; n = n.parent {
+			for _, c := range n.children {
+				if c.id() == id && !c.loop.Repeat {
+					return []*node{c}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// enclosing returns the row's occurrence or ancestor with loop id, looking
+// through the for_each occurrence's ancestors too.
+func (r *row) enclosing(id string) *node {
+	for _, start := range []*node{r.node, r.ctx} {
+		for n := start; n != nil; n = n.parent {
+			if n.id() == id {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// targets returns the occurrences whose segments p reads.
+func (r *row) targets(p *path) []*node {
+	switch p.kind {
+	case inTransaction:
+		return []*node{r.tx.root}
+	case inLoop:
+		return r.loops(p.loop)
+	}
+	return []*node{r.node}
+}
+
+// values returns the non-empty values p selects for the row, in file order.
+func (r *row) values(p *path) []value {
+	var vals []value
+	if p.kind == inItem {
+		if r.seg != nil {
+			vals = p.extract(vals, r.seg, r.elem)
+		}
+		return vals
+	}
+	for _, n := range r.targets(p) {
+		for _, seg := range n.segments {
+			if p.matches(seg) {
+				vals = p.extract(vals, seg, 0)
+			}
+		}
+	}
+	return vals
+}
+
+// first returns the values of the first path that selects any.
+func (r *row) first(paths []*path) []value {
+	for _, p := range paths {
+		if vals := r.values(p); len(vals) > 0 {
+			return vals
+		}
+	}
+	return nil
+}
+
+func (r *row) holds(conds []*condition) bool {
+	for _, c := range conds {
+		if !c.holds(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *condition) holds(r *row) bool {
+	if len(c.loops) > 0 {
+		return contains(c.loops, r.node.id())
+	}
+	v := ""
+	if vals := r.first(c.paths); len(vals) > 0 {
+		v = vals[0].s
+	}
+	return contains(c.values, v) != c.negate
+}
+
+func (c *column) value(r *row) (string, error) {
+	for _, alt := range c.cases {
+		if r.holds(alt.where) {
+			return alt.src.value(r)
+		}
+	}
+	return c.src.value(r)
+}
+
+func (s *source) value(r *row) (string, error) {
+	switch {
+	case s.constant != nil:
+		return *s.constant, nil
+	case s.param != "":
+		return r.tx.fileID, nil
+	case s.fn != nil:
+		return s.fn(r.tx.segments)
+	case s.ordinal == "row":
+		return strconv.Itoa(r.ordinal), nil
+	case s.ordinal != "":
+		if n := r.enclosing(s.ordinal); n != nil {
+			return strconv.Itoa(n.ordinal), nil
+		}
+		return "", nil
+	case s.sequence != "":
+		if n := r.enclosing(s.sequence); n != nil {
+			return strconv.Itoa(n.sequence), nil
+		}
+		return "", nil
+	}
+
+	vals := r.first(s.paths)
+	if len(vals) == 0 && !s.aggregate.numeric {
+		return s.apply("", nil), nil
+	}
+	out := make([]string, len(vals))
+	for i, v := range vals {
+		out[i] = s.apply(v.s, v.seg)
+	}
+	return s.aggregate.fn(out), nil
+}
+
+func (s *source) apply(v string, seg *x12.Segment) string {
+	for _, t := range s.transform {
+		v = t(v, seg)
+	}
+	return v
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
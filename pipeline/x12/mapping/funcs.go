@@ -0,0 +1,38 @@
+package mapping
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+
+	"claim-management-system/pipeline/x12"
+	"claim-management-system/pipeline/x12/x835"
+)
+
+// Func computes a column value over a whole transaction set, given from ST
+// to SE and preceded by its ISA and GS. Funcs cover rules that do not fit a
+// path and already live in Go.
+type Func func(segments []*x12.Segment) (string, error)
+
+// funcs are the names a Source.Func may use.
+var funcs = map[string]Func{
+	"x835_balanced": x835Balanced,
+}
+
+// x835Balanced reports whether an 835 payment balances, by reading the set
+// back through the x835 parser so the balancing rules stay in one place.
+func x835Balanced(segments []*x12.Segment) (string, error) {
+	var b bytes.Buffer
+	for _, seg := range segments {
+		b.WriteString(seg.String())
+		b.WriteByte(seg.Delimiters().Segment)
+	}
+	pay, err := x835.NewParser(&b).Next()
+	if err == io.EOF {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatBool(len(pay.Balance()) == 0), nil
+}
@@ -0,0 +1,376 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Mapping is a validated Spec, ready to convert transaction sets.
+type Mapping struct {
+	spec     *Spec
+	schema   *Schema
+	entities []*entity
+}
+
+// SpecError is a problem with a Spec.
+type SpecError struct {
+	// Field locates the problem, e.g.
+	// entities[claim_line].columns[charge].path.
+	Field string
+	Msg   string
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("mapping: %s: %s", e.Field, e.Msg)
+}
+
+// New validates spec and compiles it. The error joins every SpecError.
+func New(spec *Spec) (*Mapping, error) {
+	m, errs := compile(spec)
+	if len(errs) > 0 {
+		joined := make([]error, len(errs))
+		for i, e := range errs {
+			joined[i] = e
+		}
+		return nil, errors.Join(joined...)
+	}
+	return m, nil
+}
+
+// Validate checks spec against the schema it names: loops, segments and
+// element positions of every path, and the names of params, funcs,
+// transforms, aggregates and lookup tables.
+func Validate(spec *Spec) []*SpecError {
+	_, errs := compile(spec)
+	return errs
+}
+
+// Entities returns the entity names in spec order.
+func (m *Mapping) Entities() []string {
+	names := make([]string, len(m.entities))
+	for i, e := range m.entities {
+		names[i] = e.name
+	}
+	return names
+}
+
+// Columns returns the column names of an entity, or nil if there is no such
+// entity.
+func (m *Mapping) Columns(entity string) []string {
+	for _, e := range m.entities {
+		if e.name == entity {
+			names := make([]string, len(e.columns))
+			for i, c := range e.columns {
+				names[i] = c.name
+			}
+			return names
+		}
+	}
+	return nil
+}
+
+type entity struct {
+	name    string
+	forEach string
+	sources []*rowSource
+	columns []*column
+}
+
+type rowSource struct {
+	loops []string
+	each  *path
+	where []*condition
+}
+
+type condition struct {
+	loops  []string
+	paths  []*path
+	values []string
+	negate bool
+}
+
+type column struct {
+	name  string
+	cases []*alternative
+	src   *source
+}
+
+type alternative struct {
+	where []*condition
+	src   *source
+}
+
+type source struct {
+	paths     []*path
+	constant  *string
+	param     string
+	ordinal   string
+	sequence  string
+	fn        Func
+	transform []transformFunc
+	aggregate aggregator
+}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// compiler turns a Spec into a Mapping, collecting every problem rather
+// than stopping at the first.
+type compiler struct {
+	spec   *Spec
+	schema *Schema
+	errs   []*SpecError
+}
+
+func (c *compiler) fail(field, format string, args ...any) {
+	c.errs = append(c.errs, &SpecError{Field: field, Msg: fmt.Sprintf(format, args...)})
+}
+
+func compile(spec *Spec) (*Mapping, []*SpecError) {
+	c := &compiler{spec: spec}
+	schema, ok := schemaFor(spec.Schema)
+	if !ok {
+		c.fail("schema", "unknown schema %q", spec.Schema)
+		return nil, c.errs
+	}
+	c.schema = schema
+	m := &Mapping{spec: spec, schema: schema}
+
+	if len(spec.Entities) == 0 {
+		c.fail("entities", "no entities")
+	}
+	seen := map[string]bool{}
+	for i, e := range spec.Entities {
+		field := fmt.Sprintf("entities[%s]", label(i, e.Name))
+		if !namePattern.MatchString(e.Name) {
+			c.fail(field+".name", "%q is not a lower case name", e.Name)
+		} else if seen[e.Name] {
+			c.fail(field+".name", "duplicate entity")
+		}
+		seen[e.Name] = true
+		m.entities = append(m.entities, c.entity(field, e))
+	}
+	return m, c.errs
+}
+
+func (c *compiler) entity(field string, e *Entity) *entity {
+	out := &entity{name: e.Name, forEach: e.ForEach}
+	if e.ForEach != "" && c.schema.loops[e.ForEach] == nil {
+		c.fail(field+".for_each", "unknown loop %s", e.ForEach)
+	}
+
+	// rowLoops are the loops an unqualified path of a column may be read
+	// from; "" is the transaction set level. items says whether every row
+	// is an element group that item paths (./) can read.
+	var rowLoops []string
+	items := len(e.Rows) > 0
+	if len(e.Rows) == 0 {
+		rowLoops = []string{""}
+	}
+	for i, r := range e.Rows {
+		f := fmt.Sprintf("%s.rows[%d]", field, i)
+		src := &rowSource{loops: r.Loop}
+		switch {
+		case len(r.Loop) > 0 && r.Each != "", len(r.Loop) == 0 && r.Each == "":
+			c.fail(f, "set one of loop and each")
+		case r.Each != "":
+			// An unqualified each path reads the for_each loop.
+			src.each = c.path(f+".each", r.Each, []string{e.ForEach}, false)
+			p := src.each
+			if p == nil {
+				break
+			}
+			if p.kind == inItem || !p.comp.zero() {
+				c.fail(f+".each", "%q must select elements of a segment", r.Each)
+			}
+			if p.kind == inRow {
+				rowLoops = append(rowLoops, e.ForEach)
+			} else {
+				rowLoops = append(rowLoops, p.loop)
+			}
+		default:
+			items = false
+			for _, id := range r.Loop {
+				if c.schema.loops[id] == nil {
+					c.fail(f+".loop", "unknown loop %s", id)
+				}
+			}
+			rowLoops = append(rowLoops, r.Loop...)
+		}
+		out.sources = append(out.sources, src)
+	}
+	for i, r := range e.Rows {
+		out.sources[i].where = c.conditions(fmt.Sprintf("%s.rows[%d].where", field, i), r.Where, rowLoops, items)
+	}
+
+	if len(e.Columns) == 0 {
+		c.fail(field+".columns", "no columns")
+	}
+	names := map[string]bool{}
+	for i, col := range e.Columns {
+		f := fmt.Sprintf("%s.columns[%s]", field, label(i, col.Name))
+		if !namePattern.MatchString(col.Name) {
+			c.fail(f+".name", "%q is not a lower case name", col.Name)
+		} else if names[col.Name] {
+			c.fail(f+".name", "duplicate column")
+		}
+		names[col.Name] = true
+
+		cc := &column{name: col.Name}
+		for j, cs := range col.Cases {
+			cf := fmt.Sprintf("%s.cases[%d]", f, j)
+			if len(cs.Where) == 0 {
+				c.fail(cf+".where", "a case needs conditions")
+			}
+			cc.cases = append(cc.cases, &alternative{
+				where: c.conditions(cf+".where", cs.Where, rowLoops, items),
+				src:   c.source(cf, &cs.Source, false, rowLoops, items),
+			})
+		}
+		cc.src = c.source(f, &col.Source, len(col.Cases) > 0, rowLoops, items)
+		out.columns = append(out.columns, cc)
+	}
+	return out
+}
+
+func (c *compiler) conditions(field string, conds []*Condition, rowLoops []string, items bool) []*condition {
+	var out []*condition
+	for i, cond := range conds {
+		f := fmt.Sprintf("%s[%d]", field, i)
+		cc := &condition{loops: cond.Loop}
+		switch {
+		case len(cond.Loop) > 0 && len(cond.Path) > 0, len(cond.Loop) == 0 && len(cond.Path) == 0:
+			c.fail(f, "set one of loop and path")
+		case len(cond.Loop) > 0:
+			for _, id := range cond.Loop {
+				if c.schema.loops[id] == nil {
+					c.fail(f+".loop", "unknown loop %s", id)
+				}
+			}
+			if len(cond.In) > 0 || len(cond.NotIn) > 0 {
+				c.fail(f, "in and not_in apply to path conditions")
+			}
+			out = append(out, cc)
+			continue
+		}
+		for _, raw := range cond.Path {
+			cc.paths = append(cc.paths, c.path(f+".path", raw, rowLoops, items))
+		}
+		switch {
+		case len(cond.In) > 0 && len(cond.NotIn) > 0, len(cond.In) == 0 && len(cond.NotIn) == 0:
+			c.fail(f, "set one of in and not_in")
+		case len(cond.In) > 0:
+			cc.values = cond.In
+		default:
+			cc.values, cc.negate = cond.NotIn, true
+		}
+		out = append(out, cc)
+	}
+	return out
+}
+
+// source compiles one value source. optional allows none, for columns whose
+// cases give the value: the column is then empty when no case holds.
+func (c *compiler) source(field string, s *Source, optional bool, rowLoops []string, items bool) *source {
+	out := &source{param: s.Param, ordinal: s.Ordinal, sequence: s.Sequence, constant: s.Const}
+	set := 0
+	for _, ok := range []bool{len(s.Path) > 0, s.Const != nil, s.Param != "", s.Ordinal != "", s.Sequence != "", s.Func != ""} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set == 0 && optional:
+		empty := ""
+		out.constant = &empty
+	case set == 0:
+		c.fail(field, "no source: set one of path, const, param, ordinal, sequence and func")
+	case set > 1:
+		c.fail(field, "set only one of path, const, param, ordinal, sequence and func")
+	}
+
+	for _, raw := range s.Path {
+		out.paths = append(out.paths, c.path(field+".path", raw, rowLoops, items))
+	}
+	if s.Param != "" && s.Param != "file_id" {
+		c.fail(field+".param", "unknown param %q", s.Param)
+	}
+	if s.Ordinal != "" && s.Ordinal != "row" && c.schema.loops[s.Ordinal] == nil {
+		c.fail(field+".ordinal", "unknown loop %s", s.Ordinal)
+	}
+	if s.Sequence != "" && c.schema.loops[s.Sequence] == nil {
+		c.fail(field+".sequence", "unknown loop %s", s.Sequence)
+	}
+	if s.Func != "" {
+		if out.fn = funcs[s.Func]; out.fn == nil {
+			c.fail(field+".func", "unknown func %q", s.Func)
+		}
+	}
+
+	var err error
+	if out.transform, err = parseTransforms(s.Transform, c.spec.Lookups); err != nil {
+		c.fail(field+".transform", "%v", err)
+	}
+	if out.aggregate, err = parseAggregate(s.Aggregate); err != nil {
+		c.fail(field+".aggregate", "%v", err)
+	}
+	return out
+}
+
+// path parses raw and checks it against the schema. rowLoops are the loops
+// an unqualified path may be read from; item paths are allowed when the
+// rows are element groups.
+func (c *compiler) path(field, raw string, rowLoops []string, items bool) *path {
+	p, err := parsePath(raw)
+	if err != nil {
+		c.fail(field, "%v", err)
+		return nil
+	}
+	switch p.kind {
+	case inItem:
+		if !items {
+			c.fail(field, "%q reads an element group, but the rows are not made with each", raw)
+		}
+		return p
+	case inLoop:
+		if c.schema.loops[p.loop] == nil {
+			c.fail(field, "%q: unknown loop %s", raw, p.loop)
+			return p
+		}
+		if !c.schema.allows(p.loop, p.id) {
+			c.fail(field, "%q: loop %s has no %s segment", raw, p.loop, p.id)
+		}
+	case inTransaction:
+		if !c.schema.allows("", p.id) {
+			c.fail(field, "%q: the transaction set level has no %s segment", raw, p.id)
+		}
+	case inRow:
+		found := false
+		for _, l := range rowLoops {
+			found = found || c.schema.allows(l, p.id)
+		}
+		if !found {
+			c.fail(field, "%q: the row loops %v have no %s segment", raw, rowLoops, p.id)
+		}
+	}
+
+	max := elementCounts[p.id]
+	if p.elem.from > max || p.elem.to > max {
+		c.fail(field, "%q: %s has %d elements", raw, p.id, max)
+	}
+	for _, q := range p.quals {
+		if q.elem > max {
+			c.fail(field, "%q: qualifier on element %02d, but %s has %d elements", raw, q.elem, p.id, max)
+		}
+	}
+	return p
+}
+
+// label names an entry in a SpecError: its name, or its index if unnamed.
+func label(i int, name string) string {
+	if name != "" {
+		return name
+	}
+	return strconv.Itoa(i)
+}
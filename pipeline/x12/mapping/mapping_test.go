@@ -0,0 +1,261 @@
+package mapping
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testISA = "ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       *251121*1000*^*00501*000000301*0*T*:~"
+
+// test837 is a one-claim 837P with a repeated HI element, an other
+// subscriber loop (2320) and two service lines.
+const test837 = testISA + "GS*HC*S*R*20251121*1000*301*X*005010X222A1~ST*837*0001*005010X222A1~" +
+	"BHT*0019*00*B1*20251121*1000*CH~" +
+	"HL*1**20*1~NM1*85*2*CLINIC*****XX*1000000001~REF*EI*990000001~" +
+	"HL*2*1*22*0~SBR*P*18*GRP1******CI~NM1*IL*1*DOE*JOHN****MI*SUB1~DMG*D8*19700101*M~" +
+	"CLM*C1*12345***11:B:1~DTP*434*RD8*20251001-20251003~HI*BK:J10^BK:J11*BF:R05~" +
+	"SBR*S*01*GRP2******CI~NM1*IL*1*DOE*JANE****MI*OTH1~" +
+	"LX*1~SV1*HC:99213:25:59*100.5*UN*1~DTP*472*D8*20251002~" +
+	"LX*2~SV1*HC:99214*200*UN*2~DTP*472*D8*20251001~" +
+	"SE*21*0001~GE*1*301~IEA*1*000000301~"
+
+// run converts input with a spec and returns the parsed CSVs by entity,
+// header row included.
+func run(t *testing.T, spec, input string) map[string][][]string {
+	t.Helper()
+	s, err := ParseSpec([]byte(spec))
+	require.NoError(t, err)
+	m, err := New(s)
+	require.NoError(t, err)
+
+	bufs := map[string]*bytes.Buffer{}
+	outputs := map[string]io.Writer{}
+	for _, e := range m.Entities() {
+		bufs[e] = &bytes.Buffer{}
+		outputs[e] = bufs[e]
+	}
+	w, err := m.NewWriter("f1", outputs)
+	require.NoError(t, err)
+	_, envErrs, err := Convert(strings.NewReader(input), w)
+	require.NoError(t, err)
+	require.Empty(t, envErrs)
+
+	out := map[string][][]string{}
+	for e, b := range bufs {
+		rows, err := csv.NewReader(b).ReadAll()
+		require.NoError(t, err)
+		out[e] = rows
+	}
+	return out
+}
+
+func TestConvertPaths(t *testing.T) {
+	got := run(t, `
+name: test
+schema: 005010X222
+entities:
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns:
+      - {name: id, path: CLM/01}
+      - {name: charge, path: CLM/02, transform: implied(2)}
+      - {name: statement_to, path: "DTP[434]/03", transform: date_to(02)}
+      - {name: principal, path: "HI[01-1=BK]/01-2", aggregate: join(;)}
+      - {name: relationship, path: 2000B/SBR/02}
+      - {name: subscriber, path: 2010BA/NM1/09}
+      - {name: lines, path: 2400/LX/01, aggregate: count}
+      - {name: first_service, path: "2400/DTP[472]/03", transform: date, aggregate: min}
+      - {name: total, path: 2400/SV1/02, aggregate: sum(2)}
+      - {name: billing_npi, path: "2010AA/NM1[85][08=XX]/09"}
+      - {name: missing, path: "2010AA/NM1[08=ZZ]/09", transform: bool(Y)}
+`, test837)
+
+	assert.Equal(t, [][]string{
+		{"id", "charge", "statement_to", "principal", "relationship", "subscriber", "lines", "first_service", "total", "billing_npi", "missing"},
+		{"C1", "123.45", "2025-10-03", "J10;J11", "18", "SUB1", "2", "2025-10-01", "300.50", "1000000001", "false"},
+	}, got["claim"], "The 2320 SBR and NM1 do not leak into the subscriber columns")
+}
+
+func TestConvertItems(t *testing.T) {
+	got := run(t, `
+name: test
+schema: 005010X222
+entities:
+  - name: code
+    for_each: "2300"
+    rows:
+      - each: HI/*
+    columns:
+      - {name: n, ordinal: row}
+      - {name: qualifier, path: ./+0-1}
+      - {name: code, path: ./+0-2}
+  - name: modifier
+    rows:
+      - each: 2400/SV1/01
+    columns:
+      - {name: line, ordinal: "2400"}
+      - {name: modifiers, path: ./+0-3..6, aggregate: join}
+      - {name: last, path: ./+0-3..6, aggregate: last}
+`, test837)
+
+	assert.Equal(t, [][]string{
+		{"n", "qualifier", "code"},
+		{"1", "BK", "J10"},
+		{"2", "BF", "R05"},
+	}, got["code"], "A repeated element is one item; its path yields the first repetition")
+
+	assert.Equal(t, [][]string{
+		{"line", "modifiers", "last"},
+		{"1", "25,59", "59"},
+		{"2", "", ""},
+	}, got["modifier"])
+}
+
+func TestConvertCases(t *testing.T) {
+	got := run(t, `
+name: test
+schema: 005010X222
+entities:
+  - name: party
+    for_each: "2300"
+    rows:
+      - loop: [2010BA, 2330A]
+    columns:
+      - {name: id, path: NM1/09}
+      - name: kind
+        const: subscriber
+        cases:
+          - {where: [{loop: 2330A}], const: other}
+      - name: group
+        cases:
+          - {where: [{path: NM1/09, in: [SUB1]}], path: 2000B/SBR/03}
+          - {where: [{path: NM1/09, not_in: [SUB1]}], path: 2320/SBR/03}
+`, test837)
+
+	assert.Equal(t, [][]string{
+		{"id", "kind", "group"},
+		{"SUB1", "subscriber", "GRP1"},
+		{"OTH1", "other", "GRP2"},
+	}, got["party"])
+}
+
+func TestConvertOneRowPerTransaction(t *testing.T) {
+	twoSets := strings.Replace(test837, "GE*1*301~", "ST*837*0002*005010X222A1~BHT*0019*00*B2*20251121*1000*CH~SE*3*0002~GE*2*301~", 1)
+	got := run(t, `
+name: test
+schema: 005010X222
+entities:
+  - name: batch
+    columns:
+      - {name: file_id, param: file_id}
+      - {name: control, path: /ST/02}
+      - {name: batch, path: /BHT/03}
+      - {name: claims, path: 2300/CLM/01, aggregate: count}
+      - {name: sender, path: /ISA/06, transform: trim|upper}
+`, twoSets)
+
+	assert.Equal(t, [][]string{
+		{"file_id", "control", "batch", "claims", "sender"},
+		{"f1", "0001", "B1", "1", "SENDER"},
+		{"f1", "0002", "B2", "0", "SENDER"},
+	}, got["batch"])
+}
+
+func TestConvertWrongTransaction(t *testing.T) {
+	m, err := Builtin("835")
+	require.NoError(t, err)
+	outputs := map[string]io.Writer{}
+	for _, e := range m.Entities() {
+		outputs[e] = io.Discard
+	}
+	w, err := m.NewWriter("f1", outputs)
+	require.NoError(t, err)
+	_, _, err = Convert(strings.NewReader(test837), w)
+	assert.ErrorContains(t, err, "transaction set 837", "An 837 is not converted with an 835 spec")
+
+	_, err = m.NewWriter("f1", map[string]io.Writer{"payment": io.Discard})
+	assert.Error(t, err, "Every entity needs an output")
+}
+
+func TestTransforms(t *testing.T) {
+	cases := []struct {
+		transform, in, want string
+	}{
+		{"implied(2)", "12345", "123.45"},
+		{"implied(2)", "-5", "-0.05"},
+		{"implied(2)", "1.5", "1.5"},
+		{"decimal(2)", "1.5", "1.50"},
+		{"decimal(2)", "1.555", "1.555"},
+		{"decimal(0)", "7", "7"},
+		{"date", "20251121", "2025-11-21"},
+		{"date", "20251321", "20251321"},
+		{"date_from", "20251001-20251003", "2025-10-01"},
+		{"date_to", "20251001-20251003", "2025-10-03"},
+		{"date_to", "20251001", "2025-10-01"},
+		{"upper|trim", " ab ", "AB"},
+		{"lookup(codes)", "021", "add"},
+		{"lookup(codes)", "999", ""},
+		{"bool(Y,W)", "W", "true"},
+		{"bool(Y,W)", "", "false"},
+	}
+	lookups := map[string]map[string]string{"codes": {"021": "add"}}
+	for _, c := range cases {
+		ts, err := parseTransforms(c.transform, lookups)
+		require.NoError(t, err, c.transform)
+		v := c.in
+		for _, tf := range ts {
+			v = tf(v, nil)
+		}
+		assert.Equal(t, c.want, v, "%s of %q", c.transform, c.in)
+	}
+
+	for _, bad := range []string{"nope", "implied", "implied(x)", "date(1)", "lookup(none)", "bool", "date_from(0)", "upper(", "(x)"} {
+		_, err := parseTransforms(bad, lookups)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestAggregates(t *testing.T) {
+	vals := []string{"3", "1.5", "x", "2"}
+	cases := map[string]string{
+		"":        "3",
+		"first":   "3",
+		"last":    "2",
+		"nth(2)":  "1.5",
+		"nth(9)":  "",
+		"min":     "1.5",
+		"max":     "x",
+		"count":   "4",
+		"sum(2)":  "6.50",
+		"join":    "3,1.5,x,2",
+		"join(+)": "3+1.5+x+2",
+	}
+	for agg, want := range cases {
+		a, err := parseAggregate(agg)
+		require.NoError(t, err, agg)
+		assert.Equal(t, want, a.fn(vals), agg)
+	}
+
+	for _, bad := range []string{"avg", "nth", "nth(0)", "sum", "count(1)"} {
+		_, err := parseAggregate(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	for _, id := range []string{"005010X220", "005010X221", "005010X222", "005010X223"} {
+		s, ok := schemaFor(id)
+		require.True(t, ok, id)
+		for _, l := range s.Loops {
+			assert.NotEmpty(t, l.Name, "%s loop %s", id, l.ID)
+		}
+	}
+	_, ok := schemaFor("005010X222A1")
+	assert.False(t, ok, "Schema ids omit the errata suffix")
+}
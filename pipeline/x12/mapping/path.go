@@ -0,0 +1,287 @@
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// pathKind says where a path looks for its segment.
+type pathKind int
+
+const (
+	// inRow is a path without a loop: the segment is in the row's loop.
+	inRow pathKind = iota
+	// inLoop names a loop, e.g. 2010BA/NM1/03.
+	inLoop
+	// inTransaction starts with "/": the transaction set level, which holds
+	// the ISA, GS, ST and SE of the set as well as its header and summary
+	// segments.
+	inTransaction
+	// inItem starts with "./": the element group a row was made from.
+	inItem
+)
+
+// span is a range of element or component positions. to is -1 for "to the
+// last one"; step is 1 except for element ranges like 02..19+3.
+type span struct {
+	from, to, step int
+}
+
+func (s span) zero() bool { return s.from == 0 }
+
+// positions returns the positions of s in a list of n.
+func (s span) positions(n int) []int {
+	to := s.to
+	if to < 0 || to > n {
+		to = n
+	}
+	var ps []int
+	for p := s.from; p <= to; p += s.step {
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// qualifier requires an element (or one of its components) of a segment to
+// hold one of values, e.g. [434] or [03=20].
+type qualifier struct {
+	elem   int
+	comp   int
+	values []string
+}
+
+func (q qualifier) holds(seg *x12.Segment) bool {
+	v := seg.Element(q.elem)
+	if q.comp > 0 {
+		v = seg.Component(q.elem, q.comp)
+	}
+	for _, want := range q.values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentRef is a segment id with its qualifiers, e.g. NM1[85] or HL[03=20].
+type segmentRef struct {
+	id    string
+	quals []qualifier
+}
+
+func (r segmentRef) matches(seg *x12.Segment) bool {
+	if seg.ID != r.id {
+		return false
+	}
+	for _, q := range r.quals {
+		if !q.holds(seg) {
+			return false
+		}
+	}
+	return true
+}
+
+// path is a parsed element path; see the Column documentation for the
+// grammar.
+type path struct {
+	raw  string
+	kind pathKind
+	loop string
+	segmentRef
+	elem span
+	// comp is zero when the path selects whole elements.
+	comp span
+	// offset is set for ./+N item paths: elem counts from the item's first
+	// element.
+	offset bool
+}
+
+func parsePath(s string) (*path, error) {
+	p := &path{raw: s}
+	rest := s
+	switch {
+	case strings.HasPrefix(s, "./"):
+		p.kind = inItem
+		return p, p.parseItem(s[2:])
+	case strings.HasPrefix(s, "/"):
+		p.kind = inTransaction
+		rest = s[1:]
+	}
+
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) == 3 && p.kind == inRow:
+		p.kind, p.loop = inLoop, parts[0]
+		if p.loop == "" {
+			return nil, fmt.Errorf("empty loop in path %q", s)
+		}
+		parts = parts[1:]
+	case len(parts) != 2:
+		return nil, fmt.Errorf("path %q is not [loop/]segment/element", s)
+	}
+
+	ref, err := parseSegmentRef(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("path %q: %w", s, err)
+	}
+	p.segmentRef = ref
+	elem, comp, hasComp := strings.Cut(parts[1], "-")
+	if p.elem, err = parseSpan(elem, true); err != nil {
+		return nil, fmt.Errorf("path %q: element: %w", s, err)
+	}
+	if hasComp {
+		if p.comp, err = parseSpan(comp, false); err != nil {
+			return nil, fmt.Errorf("path %q: component: %w", s, err)
+		}
+	}
+	return p, nil
+}
+
+// parseItem parses the part of an item path after "./": an element of the
+// item's segment (01) or an offset from the item's first element (+1),
+// either optionally followed by a component.
+func (p *path) parseItem(s string) error {
+	elem, comp, hasComp := strings.Cut(s, "-")
+	if strings.HasPrefix(elem, "+") {
+		n, err := strconv.Atoi(elem[1:])
+		if err != nil || n < 0 {
+			return fmt.Errorf("path %q: bad offset %q", p.raw, elem)
+		}
+		p.offset, p.elem = true, span{from: n, to: n, step: 1}
+	} else {
+		n, err := strconv.Atoi(elem)
+		if err != nil || n < 1 {
+			return fmt.Errorf("path %q: bad element %q", p.raw, elem)
+		}
+		p.elem = span{from: n, to: n, step: 1}
+	}
+	if hasComp {
+		var err error
+		if p.comp, err = parseSpan(comp, false); err != nil {
+			return fmt.Errorf("path %q: component: %w", p.raw, err)
+		}
+	}
+	return nil
+}
+
+// parseSegmentRef parses a segment id followed by qualifiers in brackets.
+func parseSegmentRef(s string) (segmentRef, error) {
+	id, quals, _ := strings.Cut(s, "[")
+	if !validSegmentID(id) {
+		return segmentRef{}, fmt.Errorf("bad segment id %q", id)
+	}
+	ref := segmentRef{id: id}
+	if quals == "" {
+		if strings.Contains(s, "[") {
+			return ref, fmt.Errorf("empty qualifier in %q", s)
+		}
+		return ref, nil
+	}
+	for _, q := range strings.Split("["+quals, "[")[1:] {
+		body, ok := strings.CutSuffix(q, "]")
+		if !ok || body == "" {
+			return ref, fmt.Errorf("bad qualifier in %q", s)
+		}
+		qual := qualifier{elem: 1}
+		if target, values, found := strings.Cut(body, "="); found {
+			e, c, _ := strings.Cut(target, "-")
+			var err error
+			if qual.elem, err = strconv.Atoi(e); err != nil || qual.elem < 1 {
+				return ref, fmt.Errorf("bad qualifier element %q in %q", target, s)
+			}
+			if c != "" {
+				if qual.comp, err = strconv.Atoi(c); err != nil || qual.comp < 1 {
+					return ref, fmt.Errorf("bad qualifier component %q in %q", target, s)
+				}
+			}
+			body = values
+		}
+		qual.values = strings.Split(body, ",")
+		ref.quals = append(ref.quals, qual)
+	}
+	return ref, nil
+}
+
+// parseSpan parses n, a..b, * and, for elements, a..b+step.
+func parseSpan(s string, allowStep bool) (span, error) {
+	if s == "*" {
+		return span{from: 1, to: -1, step: 1}, nil
+	}
+	sp := span{step: 1}
+	if r, step, found := strings.Cut(s, "+"); found {
+		if !allowStep {
+			return sp, fmt.Errorf("step not allowed in %q", s)
+		}
+		n, err := strconv.Atoi(step)
+		if err != nil || n < 1 {
+			return sp, fmt.Errorf("bad step in %q", s)
+		}
+		sp.step, s = n, r
+	}
+	from, to, isRange := strings.Cut(s, "..")
+	var err error
+	if sp.from, err = strconv.Atoi(from); err != nil || sp.from < 1 {
+		return sp, fmt.Errorf("bad position %q", s)
+	}
+	sp.to = sp.from
+	if isRange {
+		if sp.to, err = strconv.Atoi(to); err != nil || sp.to < sp.from {
+			return sp, fmt.Errorf("bad range %q", s)
+		}
+	} else if sp.step != 1 {
+		return sp, fmt.Errorf("step without a range in %q", s)
+	}
+	return sp, nil
+}
+
+// value is one element value selected by a path, with the segment it came
+// from for transforms that read its neighbours.
+type value struct {
+	s   string
+	seg *x12.Segment
+}
+
+// extract appends the values of the path's elements in seg, skipping empty
+// ones. base is the first element of the row's item for ./+N paths.
+// Repeated elements yield one value per repetition.
+func (p *path) extract(vals []value, seg *x12.Segment, base int) []value {
+	elems := p.elem.positions(len(seg.Elements))
+	if p.offset {
+		elems = []int{base + p.elem.from}
+	}
+	for _, e := range elems {
+		for _, rep := range seg.Repeats(e) {
+			if p.comp.zero() {
+				if rep != "" {
+					vals = append(vals, value{rep, seg})
+				}
+				continue
+			}
+			comps := strings.Split(rep, string([]byte{seg.Delimiters().SubElement}))
+			for _, c := range p.comp.positions(len(comps)) {
+				if v := comps[c-1]; v != "" {
+					vals = append(vals, value{v, seg})
+				}
+			}
+		}
+	}
+	return vals
+}
+
+// validSegmentID mirrors the tokenizer's check: two or three upper case
+// letters or digits, starting with a letter.
+func validSegmentID(id string) bool {
+	if len(id) < 2 || len(id) > 3 || id[0] < 'A' || id[0] > 'Z' {
+		return false
+	}
+	for i := 1; i < len(id); i++ {
+		c := id[i]
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package mapping
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		in   string
+		want path
+	}{
+		{"CLM/02", path{kind: inRow, segmentRef: segmentRef{id: "CLM"}, elem: span{2, 2, 1}}},
+		{"2300/CLM/05-3", path{kind: inLoop, loop: "2300", segmentRef: segmentRef{id: "CLM"}, elem: span{5, 5, 1}, comp: span{3, 3, 1}}},
+		{"/PLB/04..14+2", path{kind: inTransaction, segmentRef: segmentRef{id: "PLB"}, elem: span{4, 14, 2}}},
+		{"HI/*", path{kind: inRow, segmentRef: segmentRef{id: "HI"}, elem: span{1, -1, 1}}},
+		{"SV1/01-3..6", path{kind: inRow, segmentRef: segmentRef{id: "SV1"}, elem: span{1, 1, 1}, comp: span{3, 6, 1}}},
+		{"2010AA/NM1[85][08=XX]/09", path{kind: inLoop, loop: "2010AA", segmentRef: segmentRef{id: "NM1", quals: []qualifier{
+			{elem: 1, values: []string{"85"}},
+			{elem: 8, values: []string{"XX"}},
+		}}, elem: span{9, 9, 1}}},
+		{"HI[01-1=ABK,BK]/01-2", path{kind: inRow, segmentRef: segmentRef{id: "HI", quals: []qualifier{
+			{elem: 1, comp: 1, values: []string{"ABK", "BK"}},
+		}}, elem: span{1, 1, 1}, comp: span{2, 2, 1}}},
+		{"./01", path{kind: inItem, elem: span{1, 1, 1}}},
+		{"./+1-2", path{kind: inItem, offset: true, elem: span{1, 1, 1}, comp: span{2, 2, 1}}},
+	}
+	for _, c := range cases {
+		got, err := parsePath(c.in)
+		require.NoError(t, err, c.in)
+		c.want.raw = c.in
+		assert.Equal(t, &c.want, got, c.in)
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"CLM",
+		"clm/01",
+		"2300/CLM/01/02",
+		"/2300/CLM/01",
+		"/CLM/00",
+		"CLM/05-",
+		"CLM/05-1+1",
+		"CLM/3..2",
+		"CLM/02+3",
+		"NM1[]/03",
+		"NM1[85/03",
+		"NM1[x=1]/03",
+		"./",
+		"./+x",
+		"./0",
+	} {
+		_, err := parsePath(in)
+		assert.Error(t, err, "%q is not a path", in)
+	}
+}
+
+func TestSpanPositions(t *testing.T) {
+	assert.Equal(t, []int{2, 5, 8}, span{2, 19, 3}.positions(10), "A step range stops at the last element")
+	assert.Equal(t, []int{1, 2, 3}, span{1, -1, 1}.positions(3))
+	assert.Empty(t, span{4, 4, 1}.positions(3), "Positions past the segment are dropped")
+}
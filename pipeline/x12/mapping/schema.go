@@ -0,0 +1,298 @@
+package mapping
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Schema is the loop structure of one implementation guide: enough to assign
+// each segment of a transaction set to its loop and to check the paths of a
+// Spec. Schema ids omit the errata suffix (005010X222, not 005010X222A1);
+// the loops are the same.
+type Schema struct {
+	ID          string `yaml:"id"`
+	Transaction string `yaml:"transaction"`
+	Name        string `yaml:"name"`
+	// Segments are the transaction set level segments besides ISA, GS, ST
+	// and SE.
+	Segments []string `yaml:"segments"`
+	Loops    []*Loop  `yaml:"loops"`
+
+	loops    map[string]*Loop
+	children map[string][]*Loop
+}
+
+// Loop is one loop of a Schema.
+type Loop struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// Parent lists the loops this one nests in; empty means the
+	// transaction set level. 2300 of the 837, for one, follows either the
+	// subscriber (2000B) or the patient (2000C).
+	Parent List `yaml:"parent"`
+	// Start is the segment that opens the loop, with the qualifiers that
+	// tell it from its siblings, e.g. NM1[85] or HL[03=20].
+	Start string `yaml:"start"`
+	// Repeat is set when the loop may occur more than once in its parent.
+	Repeat bool `yaml:"repeat"`
+	// Segments are the other segments of the loop.
+	Segments []string `yaml:"segments"`
+
+	start segmentRef
+}
+
+func (l *Loop) has(id string) bool {
+	for _, s := range l.Segments {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether a path may address segment id in loop; an empty
+// loop is the transaction set level.
+func (s *Schema) allows(loop, id string) bool {
+	if loop == "" {
+		switch id {
+		case "ISA", "GS", "ST", "SE":
+			return true
+		}
+		for _, seg := range s.Segments {
+			if seg == id {
+				return true
+			}
+		}
+		return false
+	}
+	l := s.loops[loop]
+	return l != nil && (l.start.id == id || l.has(id))
+}
+
+// rootHas reports whether id is a transaction set level segment.
+func (s *Schema) rootHas(id string) bool {
+	return s.allows("", id)
+}
+
+//go:embed schemas/*.yaml
+var schemaFiles embed.FS
+
+var (
+	loadSchemas sync.Once
+	schemas     map[string]*Schema
+	// elementCounts is the number of data elements of each segment, from
+	// schemas/segments.yaml.
+	elementCounts map[string]int
+)
+
+// schemaFor returns the built-in schema with id.
+func schemaFor(id string) (*Schema, bool) {
+	loadSchemas.Do(func() {
+		var err error
+		if schemas, elementCounts, err = parseSchemas(); err != nil {
+			// The files are embedded; TestSchemas keeps them valid.
+			panic(err)
+		}
+	})
+	s, ok := schemas[id]
+	return s, ok
+}
+
+func parseSchemas() (map[string]*Schema, map[string]int, error) {
+	data, err := schemaFiles.ReadFile("schemas/segments.yaml")
+	if err != nil {
+		return nil, nil, err
+	}
+	counts := map[string]int{}
+	if err := yaml.Unmarshal(data, &counts); err != nil {
+		return nil, nil, fmt.Errorf("schemas/segments.yaml: %w", err)
+	}
+
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		return nil, nil, err
+	}
+	out := map[string]*Schema{}
+	for _, e := range entries {
+		name := "schemas/" + e.Name()
+		if e.Name() == "segments.yaml" {
+			continue
+		}
+		data, err := schemaFiles.ReadFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		s := &Schema{}
+		if err := strictUnmarshal(data, s); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := s.index(counts); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		out[s.ID] = s
+	}
+	return out, counts, nil
+}
+
+// index checks the schema and builds its lookup tables.
+func (s *Schema) index(counts map[string]int) error {
+	s.loops = map[string]*Loop{}
+	s.children = map[string][]*Loop{}
+	for _, id := range s.Segments {
+		if _, ok := counts[id]; !ok {
+			return fmt.Errorf("unknown segment %s", id)
+		}
+	}
+	for _, l := range s.Loops {
+		if l.ID == "" || s.loops[l.ID] != nil {
+			return fmt.Errorf("loop id %q is empty or repeated", l.ID)
+		}
+		s.loops[l.ID] = l
+		var err error
+		if l.start, err = parseSegmentRef(l.Start); err != nil {
+			return fmt.Errorf("loop %s: start: %w", l.ID, err)
+		}
+		for _, id := range append([]string{l.start.id}, l.Segments...) {
+			if _, ok := counts[id]; !ok {
+				return fmt.Errorf("loop %s: unknown segment %s", l.ID, id)
+			}
+		}
+	}
+	for _, l := range s.Loops {
+		if len(l.Parent) == 0 {
+			s.children[""] = append(s.children[""], l)
+		}
+		for _, p := range l.Parent {
+			if s.loops[p] == nil {
+				return fmt.Errorf("loop %s: unknown parent %s", l.ID, p)
+			}
+			s.children[p] = append(s.children[p], l)
+		}
+	}
+	return nil
+}
+
+// node is one occurrence of a loop in a transaction set; the root node, with
+// a nil loop, is the transaction set level.
+type node struct {
+	loop     *Loop
+	parent   *node
+	children []*node
+	// segments are the segments of the loop itself, starting with its
+	// start segment; those of nested loops are in the children.
+	segments []*x12.Segment
+	// ordinal is the 1-based position among same-loop siblings; sequence
+	// counts the loop's occurrences in the whole file.
+	ordinal  int
+	sequence int
+}
+
+func (n *node) id() string {
+	if n.loop == nil {
+		return ""
+	}
+	return n.loop.ID
+}
+
+// index is the stream position of the node's first segment, for ordering
+// rows.
+func (n *node) index() int {
+	if len(n.segments) == 0 {
+		return 0
+	}
+	return n.segments[0].Pos.Index
+}
+
+// descendants appends the nested occurrences of loop id in stream order.
+func (n *node) descendants(id string, out []*node) []*node {
+	for _, c := range n.children {
+		if c.id() == id {
+			out = append(out, c)
+		}
+		out = c.descendants(id, out)
+	}
+	return out
+}
+
+// builder assigns the segments of a transaction set to loops as they are
+// read.
+type builder struct {
+	schema *Schema
+	root   *node
+	// stack holds the open loops, the root first.
+	stack []*node
+	// sequence counts loop occurrences across transaction sets.
+	sequence map[string]int
+}
+
+func (b *builder) reset() {
+	b.root = &node{}
+	b.stack = []*node{b.root}
+}
+
+// add places seg. Walking out from the innermost open loop, the first loop
+// that either has a child loop started by seg or lists seg as one of its own
+// segments takes it; the loops inside it are closed. A segment no open loop
+// expects stays in the innermost one.
+func (b *builder) add(seg *x12.Segment) {
+	for i := len(b.stack) - 1; i >= 0; i-- {
+		n := b.stack[i]
+		for _, l := range b.schema.children[n.id()] {
+			if l.start.matches(seg) {
+				b.stack = append(b.stack[:i+1], b.open(n, l, seg))
+				return
+			}
+		}
+		if (n.loop == nil && b.schema.rootHas(seg.ID)) || (n.loop != nil && n.loop.has(seg.ID)) {
+			b.stack = b.stack[:i+1]
+			n.segments = append(n.segments, seg)
+			return
+		}
+	}
+	cur := b.stack[len(b.stack)-1]
+	cur.segments = append(cur.segments, seg)
+}
+
+func (b *builder) open(parent *node, l *Loop, seg *x12.Segment) *node {
+	n := &node{loop: l, parent: parent, segments: []*x12.Segment{seg}, ordinal: 1}
+	for _, c := range parent.children {
+		if c.loop == l {
+			n.ordinal++
+		}
+	}
+	b.sequence[l.ID]++
+	n.sequence = b.sequence[l.ID]
+	parent.children = append(parent.children, n)
+	return n
+}
+
+// List is a string or a list of strings in a spec or schema file.
+type List []string
+
+// UnmarshalYAML accepts a scalar as a one-element list.
+func (l *List) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = List{n.Value}
+		return nil
+	}
+	var s []string
+	if err := n.Decode(&s); err != nil {
+		return err
+	}
+	*l = s
+	return nil
+}
+
+// strictUnmarshal decodes YAML (or JSON) and rejects unknown fields, so a
+// misspelt key fails instead of being ignored.
+func strictUnmarshal(data []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(v)
+}
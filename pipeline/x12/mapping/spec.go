@@ -0,0 +1,143 @@
+// Package mapping converts X12 transaction sets to CSVs as a declarative
+// Spec directs, so that a mapping can change without a code release. A Spec
+// names an implementation guide schema and, for each target entity (CSV),
+// where its rows come from and how each column is read: an element path such
+// as 2300/CLM/05-3, with qualifiers, repeats, transforms and aggregates.
+//
+// The built-in specs (see Builtin) reproduce the CSVs of the x834, x835 and
+// x837 writers.
+package mapping
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Spec is a mapping from one kind of transaction set to CSVs. Specs are
+// written in YAML or JSON.
+type Spec struct {
+	Name string `yaml:"name"`
+	// Schema is the implementation guide the paths refer to, e.g.
+	// 005010X222 for the 837 professional claim.
+	Schema string `yaml:"schema"`
+	// Lookups are code tables for the lookup transform.
+	Lookups  map[string]map[string]string `yaml:"lookups,omitempty"`
+	Entities []*Entity                    `yaml:"entities"`
+}
+
+// Entity is one target CSV.
+type Entity struct {
+	Name string `yaml:"name"`
+	// ForEach groups rows by an occurrence of a loop: rows are collected,
+	// ordered and numbered (ordinal: row) within each one, and loops
+	// outside the row's own ancestry resolve through it. Without ForEach,
+	// rows are collected over the whole transaction set.
+	ForEach string `yaml:"for_each,omitempty"`
+	// Rows are the sources of rows; their rows are merged in file order.
+	// Without Rows the entity has one row per transaction set.
+	Rows    []*Rows   `yaml:"rows,omitempty"`
+	Columns []*Column `yaml:"columns"`
+}
+
+// Rows makes a row of each occurrence of Loop, or of each element group Each
+// selects. Each is an element path whose element range is cut into groups of
+// its step: /PLB/03..14+2 makes a row of every reason/amount pair. Columns
+// read the group with item paths (./+0, ./+1-2, ./01). Groups whose elements
+// are all empty are skipped.
+type Rows struct {
+	Loop  List         `yaml:"loop,omitempty"`
+	Each  string       `yaml:"each,omitempty"`
+	Where []*Condition `yaml:"where,omitempty"`
+}
+
+// Column is one CSV column. The value comes from the first case whose
+// conditions hold, or else from the column's own source.
+//
+// A path selects element values:
+//
+//	[loop/ | /]segment[qualifier]...[qualifier]/element[-component]
+//
+// loop is a loop id of the schema (2300, 2010BA); a leading / is the
+// transaction set level (BPR, PLB, and the envelope: /ISA/13, /ST/02);
+// otherwise the segment is read from the row's own loop. A qualifier picks
+// segments by an element value: [434] means element 01 is 434, [03=20]
+// element 03, [01-1=ABK,BK] component 1 of element 01 is ABK or BK.
+// element and component are 1-based positions, ranges (3..6) or * for all,
+// and element ranges may step (02..19+3).
+//
+// A loop resolves to the row's own occurrence or its ancestor if the id is
+// one of those, else to the occurrences nested in the row's loop, else to a
+// non-repeating loop next to one of its ancestors (2010BA from a claim). The
+// ForEach occurrence and its ancestors count as ancestors too.
+type Column struct {
+	Name   string `yaml:"name"`
+	Source `yaml:",inline"`
+	Cases  []*Case `yaml:"cases,omitempty"`
+}
+
+// Case is an alternative source used when all of Where hold.
+type Case struct {
+	Where  []*Condition `yaml:"where"`
+	Source `yaml:",inline"`
+}
+
+// Condition tests the row: Loop that the row comes from one of the loops,
+// Path that the value (empty if none) is In, or not In (NotIn), a list.
+type Condition struct {
+	Loop  List `yaml:"loop,omitempty"`
+	Path  List `yaml:"path,omitempty"`
+	In    List `yaml:"in,omitempty"`
+	NotIn List `yaml:"not_in,omitempty"`
+}
+
+// Source says where a value comes from; exactly one of Path, Const, Param,
+// Ordinal, Sequence and Func is set.
+type Source struct {
+	// Path lists element paths; the first that selects a non-empty value
+	// is used.
+	Path  List    `yaml:"path,omitempty"`
+	Const *string `yaml:"const,omitempty"`
+	// Param is a value of the conversion: file_id.
+	Param string `yaml:"param,omitempty"`
+	// Ordinal is the 1-based position of the row ("row") within its
+	// ForEach occurrence, or of the enclosing occurrence of a loop among
+	// its siblings.
+	Ordinal string `yaml:"ordinal,omitempty"`
+	// Sequence numbers the occurrences of a loop across the file.
+	Sequence string `yaml:"sequence,omitempty"`
+	// Func names a value computed in Go over the transaction set; see
+	// funcs.go.
+	Func string `yaml:"func,omitempty"`
+
+	// Transform is applied to each value, e.g. date_from(02) or
+	// lookup(provider_role); several are separated by "|". A path that
+	// selects nothing is transformed as "", so bool(Y) yields false.
+	Transform string `yaml:"transform,omitempty"`
+	// Aggregate combines the values a path selects: first (the default),
+	// last, nth(n), min, max, count, sum(places) or join(separator).
+	Aggregate string `yaml:"aggregate,omitempty"`
+}
+
+// ParseSpec reads a spec in YAML or JSON. Unknown keys are errors. The spec
+// is not validated; New and Validate do that.
+func ParseSpec(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if err := strictUnmarshal(data, spec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("mapping: empty spec")
+		}
+		return nil, fmt.Errorf("mapping: %w", err)
+	}
+	return spec, nil
+}
+
+// LoadSpec reads a spec from r.
+func LoadSpec(r io.Reader) (*Spec, error) {
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return ParseSpec(b.Bytes())
+}
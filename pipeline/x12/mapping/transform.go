@@ -0,0 +1,252 @@
+package mapping
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// transformFunc converts one value. seg is the segment the value came from,
+// nil for a missing value. Values that do not fit a transform are kept
+// verbatim, for the silver layer to reject with context.
+type transformFunc func(v string, seg *x12.Segment) string
+
+// parseTransforms parses a "|"-separated chain of transforms:
+//
+//	date           CCYYMMDD as YYYY-MM-DD
+//	date_from(nn)  start of a date or period whose format qualifier (D8,
+//	date_to(nn)    RD8) is element nn of the same segment; without nn an
+//	               RD8 period is told by its "-"
+//	implied(n)     a number with n implied decimal places: 12345 is 123.45
+//	decimal(n)     a number with exactly n decimal places
+//	upper, trim
+//	lookup(table)  the value in a lookup table of the spec, or ""
+//	bool(a,b,...)  true if the value is one of the codes, else false
+func parseTransforms(s string, lookups map[string]map[string]string) ([]transformFunc, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []transformFunc
+	for _, call := range strings.Split(s, "|") {
+		name, arg, hasArg, err := parseCall(strings.TrimSpace(call))
+		if err != nil {
+			return nil, err
+		}
+		t, err := transform(name, arg, hasArg, lookups)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func transform(name, arg string, hasArg bool, lookups map[string]map[string]string) (transformFunc, error) {
+	noArg := func(t transformFunc) (transformFunc, error) {
+		if hasArg {
+			return nil, fmt.Errorf("%s takes no argument", name)
+		}
+		return t, nil
+	}
+	switch name {
+	case "date":
+		return noArg(func(v string, _ *x12.Segment) string {
+			if d, _, ok := x12.DateRange("D8", v); ok {
+				return d
+			}
+			return v
+		})
+	case "date_from", "date_to":
+		elem := 0
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s needs an element position, got %q", name, arg)
+			}
+			elem = n
+		}
+		end := name == "date_to"
+		return func(v string, seg *x12.Segment) string {
+			format := "D8"
+			switch {
+			case elem > 0 && seg != nil:
+				format = seg.Element(elem)
+			case elem == 0 && strings.Contains(v, "-"):
+				format = "RD8"
+			}
+			from, to, ok := x12.DateRange(format, v)
+			switch {
+			case !ok:
+				return v
+			case end:
+				return to
+			}
+			return from
+		}, nil
+	case "implied", "decimal":
+		places, err := strconv.Atoi(arg)
+		if err != nil || places < 0 {
+			return nil, fmt.Errorf("%s needs a number of decimal places, got %q", name, arg)
+		}
+		if name == "implied" {
+			return impliedDecimal(places), nil
+		}
+		return fixedDecimal(places), nil
+	case "upper":
+		return noArg(func(v string, _ *x12.Segment) string { return strings.ToUpper(v) })
+	case "trim":
+		return noArg(func(v string, _ *x12.Segment) string { return strings.TrimSpace(v) })
+	case "lookup":
+		table, ok := lookups[arg]
+		if !ok {
+			return nil, fmt.Errorf("no lookup table %q", arg)
+		}
+		return func(v string, _ *x12.Segment) string { return table[v] }, nil
+	case "bool":
+		if arg == "" {
+			return nil, fmt.Errorf("bool needs the codes that mean true")
+		}
+		codes := strings.Split(arg, ",")
+		return func(v string, _ *x12.Segment) string {
+			return strconv.FormatBool(contains(codes, v))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown transform %q", name)
+}
+
+// impliedDecimal places the decimal point of an unsigned or signed integer.
+func impliedDecimal(places int) transformFunc {
+	return func(v string, _ *x12.Segment) string {
+		digits := strings.TrimPrefix(v, "-")
+		if digits == "" || strings.Trim(digits, "0123456789") != "" {
+			return v
+		}
+		n, _ := new(big.Int).SetString(digits, 10)
+		r := new(big.Rat).SetFrac(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil))
+		if digits != v {
+			r.Neg(r)
+		}
+		return r.FloatString(places)
+	}
+}
+
+// fixedDecimal renders a decimal with exactly places digits after the
+// point; values that would need rounding are kept.
+func fixedDecimal(places int) transformFunc {
+	return func(v string, _ *x12.Segment) string {
+		r, ok := parseDecimal(v)
+		if !ok || !exact(r, places) {
+			return v
+		}
+		return r.FloatString(places)
+	}
+}
+
+// parseDecimal parses an X12 R (decimal) value.
+func parseDecimal(v string) (*big.Rat, bool) {
+	if v == "" || strings.ContainsAny(v, "eE/") {
+		return nil, false
+	}
+	return new(big.Rat).SetString(v)
+}
+
+// exact reports whether r has no more than places decimal digits.
+func exact(r *big.Rat, places int) bool {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)))
+	return scaled.IsInt()
+}
+
+// aggregator combines the values of a path. numeric aggregates (count, sum)
+// have a value even when the path selects nothing.
+type aggregator struct {
+	fn      func([]string) string
+	numeric bool
+}
+
+// parseAggregate parses first, last, nth(n), min, max, count, sum(places)
+// and join(separator).
+func parseAggregate(s string) (aggregator, error) {
+	if s == "" {
+		s = "first"
+	}
+	name, arg, hasArg, err := parseCall(s)
+	if err != nil {
+		return aggregator{}, err
+	}
+	if hasArg {
+		switch name {
+		case "nth", "sum", "join":
+		default:
+			return aggregator{}, fmt.Errorf("%s takes no argument", name)
+		}
+	}
+	switch name {
+	case "first":
+		return aggregator{fn: func(vs []string) string { return vs[0] }}, nil
+	case "last":
+		return aggregator{fn: func(vs []string) string { return vs[len(vs)-1] }}, nil
+	case "nth":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return aggregator{}, fmt.Errorf("nth needs a 1-based position, got %q", arg)
+		}
+		return aggregator{fn: func(vs []string) string {
+			if n > len(vs) {
+				return ""
+			}
+			return vs[n-1]
+		}}, nil
+	case "min", "max":
+		return aggregator{fn: func(vs []string) string {
+			best := vs[0]
+			for _, v := range vs[1:] {
+				if (name == "min") == (v < best) {
+					best = v
+				}
+			}
+			return best
+		}}, nil
+	case "count":
+		return aggregator{numeric: true, fn: func(vs []string) string { return strconv.Itoa(len(vs)) }}, nil
+	case "sum":
+		places, err := strconv.Atoi(arg)
+		if err != nil || places < 0 {
+			return aggregator{}, fmt.Errorf("sum needs a number of decimal places, e.g. sum(2), got %q", arg)
+		}
+		return aggregator{numeric: true, fn: func(vs []string) string {
+			// Amounts that do not parse are left out, as in the
+			// handwritten totals.
+			total := new(big.Rat)
+			for _, v := range vs {
+				if r, ok := parseDecimal(v); ok {
+					total.Add(total, r)
+				}
+			}
+			return total.FloatString(places)
+		}}, nil
+	case "join":
+		sep := ","
+		if hasArg {
+			sep = arg
+		}
+		return aggregator{fn: func(vs []string) string { return strings.Join(vs, sep) }}, nil
+	}
+	return aggregator{}, fmt.Errorf("unknown aggregate %q", name)
+}
+
+// parseCall splits name(arg). The argument is taken verbatim, so join( )
+// joins with a space.
+func parseCall(s string) (name, arg string, hasArg bool, err error) {
+	name, rest, found := strings.Cut(s, "(")
+	if !found {
+		return s, "", false, nil
+	}
+	arg, ok := strings.CutSuffix(rest, ")")
+	if !ok || name == "" {
+		return "", "", false, fmt.Errorf("bad call %q", s)
+	}
+	return name, arg, true, nil
+}
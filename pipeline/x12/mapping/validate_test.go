@@ -0,0 +1,163 @@
+package mapping
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validate parses a one-entity 837P spec with the given columns and returns
+// the SpecErrors as "field: msg" strings.
+func validate(t *testing.T, entity string) []string {
+	t.Helper()
+	spec, err := ParseSpec([]byte("name: test\nschema: 005010X222\nlookups: {roles: {\"82\": rendering}}\nentities:\n" + entity))
+	require.NoError(t, err)
+	var out []string
+	for _, e := range Validate(spec) {
+		out = append(out, e.Field+": "+e.Msg)
+	}
+	return out
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name, entity, want string
+	}{
+		{"unknown loop", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: 2999/CLM/01}]`,
+			`entities[claim].columns[x].path: "2999/CLM/01": unknown loop 2999`},
+		{"segment not in loop", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: 2300/SV1/01}]`,
+			`entities[claim].columns[x].path: "2300/SV1/01": loop 2300 has no SV1 segment`},
+		{"segment not in row loop", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: SV1/01}]`,
+			`entities[claim].columns[x].path: "SV1/01": the row loops [2300] have no SV1 segment`},
+		{"element past the segment", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: CLM/21}]`,
+			`entities[claim].columns[x].path: "CLM/21": CLM has 20 elements`},
+		{"qualifier past the segment", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: "DTP[04=X]/03"}]`,
+			`entities[claim].columns[x].path: "DTP[04=X]/03": qualifier on element 04, but DTP has 3 elements`},
+		{"not a transaction set segment", `
+  - name: claim
+    columns: [{name: x, path: /CLM/01}]`,
+			`entities[claim].columns[x].path: "/CLM/01": the transaction set level has no CLM segment`},
+		{"unknown transform", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: CLM/01, transform: reverse}]`,
+			`entities[claim].columns[x].transform: unknown transform "reverse"`},
+		{"unknown lookup", `
+  - name: claim
+    rows: [{loop: "2310B"}]
+    columns: [{name: x, path: NM1/01, transform: lookup(role)}]`,
+			`entities[claim].columns[x].transform: no lookup table "role"`},
+		{"unknown aggregate", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: CLM/01, aggregate: median}]`,
+			`entities[claim].columns[x].aggregate: unknown aggregate "median"`},
+		{"unknown func", `
+  - name: claim
+    columns: [{name: x, func: x837_balanced}]`,
+			`entities[claim].columns[x].func: unknown func "x837_balanced"`},
+		{"unknown param", `
+  - name: claim
+    columns: [{name: x, param: bucket}]`,
+			`entities[claim].columns[x].param: unknown param "bucket"`},
+		{"no source", `
+  - name: claim
+    columns: [{name: x}]`,
+			`entities[claim].columns[x]: no source: set one of path, const, param, ordinal, sequence and func`},
+		{"two sources", `
+  - name: claim
+    columns: [{name: x, param: file_id, const: a}]`,
+			`entities[claim].columns[x]: set only one of path, const, param, ordinal, sequence and func`},
+		{"item path without each", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, path: ./01}]`,
+			`entities[claim].columns[x].path: "./01" reads an element group, but the rows are not made with each`},
+		{"unknown ordinal loop", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, ordinal: "2500"}]`,
+			`entities[claim].columns[x].ordinal: unknown loop 2500`},
+		{"loop and each", `
+  - name: claim
+    rows: [{loop: "2300", each: HI/*}]
+    columns: [{name: x, const: a}]`,
+			`entities[claim].rows[0]: set one of loop and each`},
+		{"condition without in", `
+  - name: claim
+    rows: [{loop: "2300", where: [{path: CLM/01}]}]
+    columns: [{name: x, const: a}]`,
+			`entities[claim].rows[0].where[0]: set one of in and not_in`},
+		{"case without conditions", `
+  - name: claim
+    rows: [{loop: "2300"}]
+    columns: [{name: x, cases: [{const: a}]}]`,
+			`entities[claim].columns[x].cases[0].where: a case needs conditions`},
+		{"bad names", `
+  - name: Claim
+    columns: [{name: a, const: x}, {name: a, const: y}]`,
+			`entities[Claim].name: "Claim" is not a lower case name`},
+		{"duplicate column", `
+  - name: claim
+    columns: [{name: a, const: x}, {name: a, const: y}]`,
+			`entities[claim].columns[a].name: duplicate column`},
+		{"bad path", `
+  - name: claim
+    columns: [{name: a, path: "/CLM"}]`,
+			`entities[claim].columns[a].path: path "/CLM" is not [loop/]segment/element`},
+	}
+	for _, c := range cases {
+		assert.Contains(t, validate(t, c.entity), c.want, c.name)
+	}
+}
+
+func TestValidateBuiltins(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		data, err := specFiles.ReadFile("specs/" + name + ".yaml")
+		require.NoError(t, err)
+		spec, err := ParseSpec(data)
+		require.NoError(t, err, name)
+		assert.Empty(t, Validate(spec), name)
+	}
+}
+
+func TestNewJoinsErrors(t *testing.T) {
+	spec, err := ParseSpec([]byte(`{"name": "t", "schema": "005010X222", "entities": [{"name": "c", "columns": [{"name": "a"}, {"name": "b", "path": "2999/CLM/01"}]}]}`))
+	require.NoError(t, err, "Specs may be JSON")
+	_, err = New(spec)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "columns[a]: no source")
+	assert.Contains(t, err.Error(), "unknown loop 2999")
+
+	spec.Schema = "005010X999"
+	assert.Equal(t, []*SpecError{{Field: "schema", Msg: `unknown schema "005010X999"`}}, Validate(spec))
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	_, err := ParseSpec([]byte("name: t\nschema: 005010X222\nentities:\n  - name: c\n    colums: []\n"))
+	assert.ErrorContains(t, err, "colums", "Misspelt keys are rejected")
+
+	_, err = ParseSpec(nil)
+	assert.EqualError(t, err, "mapping: empty spec")
+
+	spec, err := LoadSpec(strings.NewReader("name: t\nschema: 005010X222\nentities: [{name: c, columns: [{name: a, path: [CLM/01, CLM/02]}]}]"))
+	require.NoError(t, err)
+	assert.Equal(t, List{"CLM/01", "CLM/02"}, spec.Entities[0].Columns[0].Path, "path takes a list")
+}
@@ -0,0 +1,227 @@
+package x12
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// isaElements is the fixed number of elements in an ISA segment.
+const isaElements = 16
+
+// maxISALength bounds the search for the end of the ISA header. A conforming
+// header is exactly 106 bytes; some senders pad or trim fields, so the header
+// is located by counting element separators rather than by fixed offsets.
+const maxISALength = 256
+
+// ErrNoInterchange is returned when data appears before any ISA segment, so
+// no delimiters are known.
+var ErrNoInterchange = errors.New("x12: data before ISA header")
+
+// SyntaxError reports input that cannot be tokenized at all. Reading stops.
+type SyntaxError struct {
+	Offset int64
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("x12: offset %d: %s", e.Offset, e.Msg)
+}
+
+// Reader tokenizes an X12 stream segment by segment. Delimiters are taken from
+// each ISA header, so a stream may hold several interchanges with different
+// separators. Memory use is bounded by the longest segment.
+//
+// Envelope problems (mismatched control numbers and counts, missing
+// trailers) do not stop reading; they are collected and returned by Errors.
+type Reader struct {
+	br     *bufio.Reader
+	delims Delimiters
+	offset int64
+	index  int
+	err    error
+
+	env  envelope
+	errs []*EnvelopeError
+}
+
+// NewReader returns a Reader over r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReader(r)}
+}
+
+// Next returns the next segment. At the end of the stream it returns io.EOF,
+// after recording errors for any envelope left open.
+func (r *Reader) Next() (*Segment, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	seg, err := r.next()
+	if err != nil {
+		if err == io.EOF {
+			r.env.finish(r)
+		}
+		r.err = err
+		return nil, err
+	}
+	r.env.track(r, seg)
+	return seg, nil
+}
+
+// Delimiters returns the separators of the current interchange.
+func (r *Reader) Delimiters() Delimiters {
+	return r.delims
+}
+
+// Errors returns the envelope errors found so far, in stream order.
+func (r *Reader) Errors() []*EnvelopeError {
+	return r.errs
+}
+
+// Interchange returns the most recently opened interchange header, or nil.
+func (r *Reader) Interchange() *Interchange { return r.env.isa }
+
+// Group returns the most recently opened functional group header, or nil.
+func (r *Reader) Group() *Group { return r.env.gs }
+
+// Transaction returns the most recently opened transaction set header, or nil.
+func (r *Reader) Transaction() *Transaction { return r.env.st }
+
+func (r *Reader) next() (*Segment, error) {
+	if err := r.skipSpace(); err != nil {
+		return nil, err
+	}
+	start := r.offset
+
+	if head, _ := r.br.Peek(3); string(head) == "ISA" {
+		return r.readISA(start)
+	}
+	if r.delims.Segment == 0 {
+		return nil, ErrNoInterchange
+	}
+
+	data, err := r.br.ReadString(r.delims.Segment)
+	r.offset += int64(len(data))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// A missing terminator after the last segment is tolerated; envelope
+	// checks catch truncation.
+	data = strings.TrimSuffix(data, sepString(r.delims.Segment))
+	if r.delims.Segment == '\n' {
+		data = strings.TrimSuffix(data, "\r")
+	}
+	if data == "" {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, &SyntaxError{Offset: start, Msg: "empty segment"}
+	}
+	return r.segment(data, start), nil
+}
+
+// skipSpace discards line breaks between segments.
+func (r *Reader) skipSpace() error {
+	for {
+		b, err := r.br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != '\r' && b != '\n' {
+			return r.br.UnreadByte()
+		}
+		r.offset++
+	}
+}
+
+// readISA reads an ISA header and adopts its delimiters: the element
+// separator follows "ISA", the sub-element separator is ISA16 and the
+// segment terminator is the byte after it.
+func (r *Reader) readISA(start int64) (*Segment, error) {
+	buf := make([]byte, 0, 106)
+	var sep byte
+	seps, last := 0, 0
+	for {
+		if len(buf) == maxISALength {
+			return nil, &SyntaxError{Offset: start, Msg: "ISA header too long"}
+		}
+		b, err := r.br.ReadByte()
+		if err == io.EOF {
+			return nil, &SyntaxError{Offset: start, Msg: "truncated ISA header"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b)
+		switch {
+		case len(buf) == 4:
+			if isAlnum(b) || b == ' ' || b == 0 {
+				return nil, &SyntaxError{Offset: start, Msg: fmt.Sprintf("invalid element separator %q", b)}
+			}
+			sep = b
+			seps = 1
+		case len(buf) > 4 && b == sep:
+			seps++
+			last = len(buf)
+		}
+		// ISA16 is one byte after the last separator, then the terminator.
+		if seps == isaElements && len(buf) == last+2 {
+			break
+		}
+	}
+	r.offset += int64(len(buf))
+
+	d := Delimiters{
+		Element:    sep,
+		SubElement: buf[len(buf)-2],
+		Segment:    buf[len(buf)-1],
+	}
+	if d.SubElement == sep || d.Segment == sep || d.Segment == d.SubElement || d.SubElement == 0 || d.Segment == 0 {
+		return nil, &SyntaxError{Offset: start, Msg: "ISA header declares conflicting delimiters"}
+	}
+
+	elements := strings.Split(string(buf[4:len(buf)-1]), sepString(sep))
+	if len(elements) != isaElements {
+		return nil, &SyntaxError{Offset: start, Msg: fmt.Sprintf("ISA has %d elements, want %d", len(elements), isaElements)}
+	}
+	// ISA11 is the repetition separator from 00501 on and the standards
+	// identifier ("U") before.
+	if rep := elements[10]; len(rep) == 1 && !isAlnum(rep[0]) && rep[0] != 0 && rep[0] != d.SubElement && rep[0] != d.Segment {
+		d.Repetition = rep[0]
+	}
+	r.delims = d
+
+	r.index++
+	return &Segment{ID: "ISA", Elements: elements, Pos: Position{Offset: start, Index: r.index}, delims: d}, nil
+}
+
+func (r *Reader) segment(data string, start int64) *Segment {
+	parts := strings.Split(data, sepString(r.delims.Element))
+	r.index++
+	return &Segment{
+		ID:       parts[0],
+		Elements: parts[1:],
+		Pos:      Position{Offset: start, Index: r.index},
+		delims:   r.delims,
+	}
+}
+
+func isAlnum(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z'
+}
+
+// Validate reads r to the end and returns its envelope errors. The error is
+// non-nil only if r could not be tokenized.
+func Validate(r io.Reader) ([]*EnvelopeError, error) {
+	xr := NewReader(r)
+	for {
+		if _, err := xr.Next(); err != nil {
+			if err == io.EOF {
+				return xr.Errors(), nil
+			}
+			return xr.Errors(), err
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package x12
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t testing.TB, r *Reader) []*Segment {
+	t.Helper()
+	var segs []*Segment
+	for {
+		seg, err := r.Next()
+		if err == io.EOF {
+			return segs
+		}
+		require.NoError(t, err)
+		segs = append(segs, seg)
+	}
+}
+
+func openFixture(t testing.TB, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestFixturesAreValid(t *testing.T) {
+	for _, tc := range []struct {
+		file, set, functional, version string
+	}{
+		{"834.x12", "834", "BE", "005010X220A1"},
+		{"835.x12", "835", "HP", "005010X221A1"},
+		{"835_delims.x12", "835", "HP", "005010X221A1"},
+		{"837p.x12", "837", "HC", "005010X222A1"},
+		{"837i.x12", "837", "HC", "005010X223A3"},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			r := NewReader(openFixture(t, tc.file))
+			segs := readAll(t, r)
+			assert.Empty(t, r.Errors())
+
+			require.NotEmpty(t, segs)
+			assert.Equal(t, "ISA", segs[0].ID)
+			assert.Equal(t, "IEA", segs[len(segs)-1].ID)
+			assert.Equal(t, tc.set, r.Transaction().SetID)
+			assert.Equal(t, tc.functional, r.Group().FunctionalID)
+			assert.Equal(t, tc.version, r.Group().Version)
+
+			for i, seg := range segs {
+				assert.Equal(t, i+1, seg.Pos.Index)
+				if i > 0 {
+					assert.Greater(t, seg.Pos.Offset, segs[i-1].Pos.Offset)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderPositions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "837p.x12"))
+	require.NoError(t, err)
+
+	segs := readAll(t, NewReader(strings.NewReader(string(data))))
+	for _, seg := range segs {
+		assert.True(t, strings.HasPrefix(string(data[seg.Pos.Offset:]), seg.String()), "Offset of segment %d should point at its text", seg.Pos.Index)
+	}
+
+	st, se := segs[2], segs[len(segs)-3]
+	require.Equal(t, "ST", st.ID)
+	require.Equal(t, "SE", se.ID)
+	assert.Equal(t, 1, st.Pos.TxIndex)
+	assert.Equal(t, se.Element(1), strconv.Itoa(se.Pos.TxIndex), "SE's own TxIndex should equal SE01")
+	assert.Zero(t, segs[0].Pos.TxIndex)
+	assert.Zero(t, segs[len(segs)-1].Pos.TxIndex)
+}
+
+func TestReaderDetectsDelimiters(t *testing.T) {
+	r := NewReader(openFixture(t, "835_delims.x12"))
+	segs := readAll(t, r)
+	assert.Equal(t, Delimiters{Element: '|', SubElement: '>', Repetition: '!', Segment: '\n'}, r.Delimiters())
+
+	std := readAll(t, NewReader(openFixture(t, "835.x12")))
+	require.Len(t, segs, len(std))
+	for i := range segs {
+		assert.Equal(t, std[i].ID, segs[i].ID)
+		// Only the envelope control numbers differ between the two files.
+		switch segs[i].ID {
+		case "ISA", "GS", "GE", "IEA":
+		default:
+			assert.Equal(t, std[i].Elements, replaceSub(segs[i].Elements), "Segment %d should tokenize identically", i+1)
+		}
+	}
+
+	svc := segs[16]
+	require.Equal(t, "SVC", svc.ID)
+	assert.Equal(t, "99213", svc.Component(1, 2))
+	assert.Equal(t, []string{"HC", "99213", "25"}, svc.Components(1))
+}
+
+func replaceSub(elements []string) []string {
+	out := make([]string, len(elements))
+	for i, e := range elements {
+		out[i] = strings.ReplaceAll(e, ">", ":")
+	}
+	return out
+}
+
+func TestReaderTypedHeaders(t *testing.T) {
+	r := NewReader(openFixture(t, "837p.x12"))
+	readAll(t, r)
+
+	isa := r.Interchange()
+	require.NotNil(t, isa)
+	assert.Equal(t, "CLEARINGHOUSE", isa.SenderID, "Padding should be trimmed")
+	assert.Equal(t, "ZZ", isa.SenderQualifier)
+	assert.Equal(t, "CLAIMSYS", isa.ReceiverID)
+	assert.Equal(t, "000000101", isa.ControlNumber)
+	assert.Equal(t, "00501", isa.Version)
+	assert.Equal(t, "T", isa.Usage)
+	assert.False(t, isa.AckRequested)
+	assert.Equal(t, DefaultDelimiters, isa.Delimiters)
+
+	assert.Equal(t, "101", r.Group().ControlNumber)
+	assert.Equal(t, "0001", r.Transaction().ControlNumber)
+	assert.Equal(t, "005010X222A1", r.Transaction().ImplementationRef)
+}
+
+func TestSegmentAccessors(t *testing.T) {
+	r := NewReader(strings.NewReader(isaHeader("000000001") + "GS*HC*A*B*20251121*1000*1*X*005010X222A1~ST*837*0001~HI*ABK:J069^ABF:R509*BF:E119~SE*3*0001~GE*1*1~IEA*1*000000001~"))
+	segs := readAll(t, r)
+	hi := segs[3]
+	require.Equal(t, "HI", hi.ID)
+	assert.Equal(t, []string{"ABK:J069", "ABF:R509"}, hi.Repeats(1))
+	assert.Equal(t, "BF", hi.Component(2, 1))
+	assert.Equal(t, "", hi.Component(2, 3))
+	assert.Equal(t, "", hi.Element(9))
+	assert.Nil(t, hi.Components(9))
+	assert.Equal(t, "HI*ABK:J069^ABF:R509*BF:E119", hi.String())
+}
+
+func TestReaderVersion4010HasNoRepetitionSeparator(t *testing.T) {
+	header := strings.Replace(isaHeader("000000001"), "*^*00501*", "*U*00401*", 1)
+	r := NewReader(strings.NewReader(header + "IEA*0*000000001~"))
+	readAll(t, r)
+	assert.Zero(t, r.Delimiters().Repetition)
+	assert.Empty(t, r.Errors())
+}
+
+func TestReaderMultipleInterchanges(t *testing.T) {
+	first := isaHeader("000000001") + "IEA*0*000000001~\r\n"
+	second := strings.NewReplacer("*", "|", "~", "\n").Replace(isaHeader("000000002")+"IEA*0*000000002~") + "\n"
+	r := NewReader(strings.NewReader(first + second))
+	segs := readAll(t, r)
+	require.Len(t, segs, 4)
+	assert.Equal(t, "000000002", segs[3].Element(2))
+	assert.Equal(t, byte('|'), r.Delimiters().Element)
+	assert.Empty(t, r.Errors())
+}
+
+func TestReaderSyntaxErrors(t *testing.T) {
+	_, err := NewReader(strings.NewReader("GS*HC~")).Next()
+	assert.ErrorIs(t, err, ErrNoInterchange)
+
+	_, err = NewReader(strings.NewReader("ISA*00*  ")).Next()
+	var syn *SyntaxError
+	assert.ErrorAs(t, err, &syn)
+
+	_, err = NewReader(strings.NewReader("ISAX00")).Next()
+	assert.ErrorAs(t, err, &syn)
+
+	_, err = NewReader(strings.NewReader("ISA*" + strings.Repeat("x", 300))).Next()
+	assert.ErrorAs(t, err, &syn)
+}
+
+func TestReaderEmptySegment(t *testing.T) {
+	body := isaHeader("000000001") + "GS*HC*S*R*20251121*1000*1*X*005010X222A1~~ST*837*0001~SE*2*0001~GE*1*1~IEA*1*000000001~"
+	r := NewReader(strings.NewReader(body))
+	for _, id := range []string{"ISA", "GS"} {
+		seg, err := r.Next()
+		require.NoError(t, err)
+		assert.Equal(t, id, seg.ID)
+	}
+	_, err := r.Next()
+	var syn *SyntaxError
+	require.ErrorAs(t, err, &syn, "An empty segment is not the end of the input")
+	assert.Equal(t, "x12: offset 147: empty segment", err.Error())
+	assert.Equal(t, int64(strings.Index(body, "~~")+1), syn.Offset)
+	_, err = r.Next()
+	assert.Equal(t, syn, err, "Reading stops")
+
+	// A terminator before the end of the input, then line breaks, is the end.
+	r = NewReader(strings.NewReader(isaHeader("000000001") + "IEA*1*000000001~\r\n"))
+	for {
+		if _, err = r.Next(); err != nil {
+			break
+		}
+	}
+	assert.Equal(t, io.EOF, err)
+}
+
+// isaHeader returns a 106-byte ISA with the default delimiters.
+func isaHeader(control string) string {
+	return "ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       *251121*1000*^*00501*" + control + "*0*T*:~"
+}
+
+func TestDateRange(t *testing.T) {
+	from, to, ok := DateRange("D8", "20251101")
+	assert.True(t, ok)
+	assert.Equal(t, "2025-11-01", from)
+	assert.Equal(t, from, to)
+
+	from, to, ok = DateRange("RD8", "20251101-20251104")
+	assert.True(t, ok)
+	assert.Equal(t, "2025-11-01", from)
+	assert.Equal(t, "2025-11-04", to)
+
+	from, to, ok = DateRange("DT", "202511010830")
+	assert.True(t, ok)
+	assert.Equal(t, "2025-11-01", from)
+	assert.Equal(t, from, to)
+
+	for _, bad := range [][2]string{{"D8", "20251301"}, {"RD8", "20251104-20251101"}, {"RD8", "20251101"}, {"TM", "1000"}, {"DT", "20251101"}, {"DT", "202511012530"}} {
+		_, _, ok = DateRange(bad[0], bad[1])
+		assert.False(t, ok, "%v should not parse", bad)
+	}
+
+	hour, ok := TimeOfDay("1415")
+	assert.True(t, ok)
+	assert.Equal(t, "14:15", hour)
+	for _, bad := range []string{"2400", "930", "14:15"} {
+		_, ok = TimeOfDay(bad)
+		assert.False(t, ok, "%q should not parse", bad)
+	}
+}
@@ -0,0 +1,105 @@
+// Package x12 reads ASC X12 interchanges (the 834, 835 and 837 files that land
+// in the raw bucket) as a stream of segments and checks their envelopes.
+package x12
+
+import (
+	"strings"
+)
+
+// Delimiters are the separators an interchange declares in its ISA header.
+type Delimiters struct {
+	Element    byte
+	SubElement byte
+	// Repetition is zero for interchanges before 00501, where ISA11 is the
+	// standards identifier instead of a separator.
+	Repetition byte
+	Segment    byte
+}
+
+// DefaultDelimiters are the separators most trading partners use.
+var DefaultDelimiters = Delimiters{Element: '*', SubElement: ':', Repetition: '^', Segment: '~'}
+
+// Position locates a segment in its stream.
+type Position struct {
+	// Offset is the byte offset of the segment's first character.
+	Offset int64
+	// Index is the 1-based ordinal of the segment in the stream.
+	Index int
+	// TxIndex is the 1-based ordinal within the enclosing transaction set,
+	// counting ST as 1, as in SE01 and IK302. It is zero outside ST/SE.
+	TxIndex int
+}
+
+// Segment is one X12 segment. Element numbering follows the implementation
+// guides: Element(1) is the first element after the segment id, so CLM01 is
+// seg.Element(1).
+type Segment struct {
+	ID string
+	// Elements holds the raw data elements after the id.
+	Elements []string
+	Pos      Position
+
+	delims Delimiters
+}
+
+// Element returns the n-th element (1-based), or "" if the segment is shorter.
+func (s *Segment) Element(n int) string {
+	if n < 1 || n > len(s.Elements) {
+		return ""
+	}
+	return s.Elements[n-1]
+}
+
+// Component returns component c (1-based) of element n, e.g. the procedure
+// code of SV101 is Component(1, 2).
+func (s *Segment) Component(n, c int) string {
+	parts := s.Components(n)
+	if c < 1 || c > len(parts) {
+		return ""
+	}
+	return parts[c-1]
+}
+
+// Components splits element n on the sub-element separator.
+func (s *Segment) Components(n int) []string {
+	v := s.Element(n)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, sepString(s.delims.SubElement))
+}
+
+// Repeats splits element n on the repetition separator.
+func (s *Segment) Repeats(n int) []string {
+	v := s.Element(n)
+	if v == "" {
+		return nil
+	}
+	if s.delims.Repetition == 0 {
+		return []string{v}
+	}
+	return strings.Split(v, sepString(s.delims.Repetition))
+}
+
+// Delimiters returns the separators in effect when the segment was read.
+func (s *Segment) Delimiters() Delimiters {
+	return s.delims
+}
+
+// String renders the segment with its original separators, without the
+// segment terminator.
+func (s *Segment) String() string {
+	var b strings.Builder
+	b.WriteString(s.ID)
+	for _, e := range s.Elements {
+		b.WriteByte(s.delims.Element)
+		b.WriteString(e)
+	}
+	return b.String()
+}
+
+// sepString converts a separator byte to a one-byte string; string(b) would
+// UTF-8 encode separators above 0x7F.
+func sepString(b byte) string {
+	return string([]byte{b})
+}
@@ -0,0 +1,161 @@
+// Package x834 parses 834 benefit enrollment (005010X220) transactions into
+// member events and writes them as the member and coverage CSVs of the raw
+// layer.
+package x834
+
+// Maintenance is a maintenance type code (INS03, HD01).
+type Maintenance string
+
+const (
+	Change    Maintenance = "001"
+	Add       Maintenance = "021"
+	Terminate Maintenance = "024"
+	Reinstate Maintenance = "025"
+	// Audit (030) restates current enrollment without changing it; audit
+	// files are the full snapshots sponsors send for reconciliation.
+	Audit Maintenance = "030"
+)
+
+// Action names the maintenance code, or returns "" for codes outside the
+// implementation guide.
+func (m Maintenance) Action() string {
+	switch m {
+	case Change:
+		return "change"
+	case Add:
+		return "add"
+	case Terminate:
+		return "terminate"
+	case Reinstate:
+		return "reinstate"
+	case Audit:
+		return "audit"
+	}
+	return ""
+}
+
+// Date is a DTP segment with dates converted to YYYY-MM-DD.
+type Date struct {
+	Qualifier string
+	From      string
+	To        string
+}
+
+// Entity is an N1 segment of loop 1000 (sponsor, payer).
+type Entity struct {
+	Name        string
+	IDQualifier string
+	ID          string
+}
+
+// Address is an N3/N4 pair.
+type Address struct {
+	Line1 string
+	Line2 string
+	City  string
+	State string
+	Zip   string
+}
+
+// Header holds the transaction-level values shared by every member of one
+// ST/SE.
+type Header struct {
+	InterchangeControlNumber string
+	TransactionControlNumber string
+
+	// Purpose is BGN01: 00 original, 15 re-submission, 22 information copy.
+	Purpose   string
+	Reference string
+	Date      string
+	// Action is BGN08: 2 change (update), 4 verify (audit file), RX replace.
+	Action string
+	// MasterPolicy is the REF*38 master policy number.
+	MasterPolicy string
+	// Effective is the DTP*007 file effective date.
+	Effective string
+
+	Sponsor Entity
+	Payer   Entity
+}
+
+// Coverage is one HD loop (2300): a health coverage line of the member.
+type Coverage struct {
+	// Maintenance is HD01, or the member's INS03 when HD01 is empty.
+	Maintenance     Maintenance
+	InsuranceLine   string
+	PlanDescription string
+	// CoverageLevel is HD05, e.g. EMP, FAM, ESP.
+	CoverageLevel string
+	// Start and End are the benefit begin (348) and end (349) dates. A
+	// terminated coverage without a 349 ends on the member's eligibility end.
+	Start string
+	End   string
+
+	Dates      []Date
+	References map[string]string
+}
+
+// Member is one INS loop (2000) with its name, demographics and coverages.
+// Each Member is an enrollment event: the same person appears once per
+// maintenance sent for them.
+type Member struct {
+	Header *Header
+	// Sequence is the 1-based order of the INS within the file; events of
+	// one member are applied in this order.
+	Sequence int
+
+	// Subscriber is INS01 = Y.
+	Subscriber bool
+	// Relationship is INS02; 18 is self.
+	Relationship      string
+	Maintenance       Maintenance
+	MaintenanceReason string
+	BenefitStatus     string
+	EmploymentStatus  string
+
+	// SubscriberID is REF*0F, shared by the subscriber and dependents.
+	SubscriberID string
+	GroupNumber  string
+	// References maps the other 2000 REF qualifiers to values.
+	References map[string]string
+	Dates      []Date
+
+	LastName    string
+	FirstName   string
+	MiddleName  string
+	IDQualifier string
+	// ID is NM109, e.g. the SSN when IDQualifier is 34.
+	ID        string
+	Address   Address
+	BirthDate string
+	Gender    string
+
+	Coverages []*Coverage
+
+	// Segment is the 1-based index of the INS segment in the file.
+	Segment int
+}
+
+// MemberID identifies the member across files: the subscriber number for
+// subscribers and the supplemental identifier (REF*23, 17 or ZZ) for
+// dependents, falling back to the subscriber number.
+func (m *Member) MemberID() string {
+	if !m.Subscriber {
+		for _, q := range []string{"23", "17", "ZZ"} {
+			if v := m.References[q]; v != "" {
+				return v
+			}
+		}
+	}
+	return m.SubscriberID
+}
+
+// Date returns the first member-level date with qualifier q.
+func (m *Member) Date(q string) (Date, bool) {
+	for _, d := range m.Dates {
+		if d.Qualifier == q {
+			return d, true
+		}
+	}
+	return Date{}, false
+}
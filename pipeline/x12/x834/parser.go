@@ -0,0 +1,235 @@
+package x834
+
+import (
+	"io"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Parser streams members from an 834 file. Only one member is held in memory
+// at a time, plus the header of its transaction set.
+type Parser struct {
+	r       *x12.Reader
+	pending *x12.Segment
+	err     error
+
+	interchange string
+	header      *Header
+	sequence    int
+
+	member   *Member
+	coverage *Coverage
+	// named is set after NM1*IL so that N3/N4/DMG of other 2100 loops
+	// (incorrect name, custodial parent, ...) are not applied to the member.
+	named bool
+}
+
+// NewParser returns a Parser reading r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: x12.NewReader(r)}
+}
+
+// Errors returns the envelope errors found so far.
+func (p *Parser) Errors() []*x12.EnvelopeError {
+	return p.r.Errors()
+}
+
+// Next returns the next member, or io.EOF after the last one.
+func (p *Parser) Next() (*Member, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for {
+		seg := p.pending
+		p.pending = nil
+		if seg == nil {
+			var err error
+			seg, err = p.r.Next()
+			if err != nil {
+				p.err = err
+				if err == io.EOF && p.member != nil {
+					return p.finish(), nil
+				}
+				return nil, err
+			}
+		}
+
+		switch seg.ID {
+		case "INS", "SE", "ST", "GE", "IEA", "ISA":
+			if p.member != nil {
+				p.pending = seg
+				return p.finish(), nil
+			}
+		}
+		p.apply(seg)
+	}
+}
+
+func (p *Parser) finish() *Member {
+	m := p.member
+	p.member, p.coverage, p.named = nil, nil, false
+
+	end := ""
+	if d, ok := m.Date("357"); ok {
+		end = d.From
+	}
+	for _, c := range m.Coverages {
+		if c.Maintenance == Terminate && c.End == "" {
+			c.End = end
+		}
+	}
+	return m
+}
+
+func (p *Parser) apply(seg *x12.Segment) {
+	switch seg.ID {
+	case "ISA":
+		p.interchange = seg.Element(13)
+	case "ST":
+		p.header = &Header{
+			InterchangeControlNumber: p.interchange,
+			TransactionControlNumber: seg.Element(2),
+		}
+	case "SE":
+		p.header = nil
+	}
+	if p.header == nil {
+		return
+	}
+	if p.member == nil {
+		p.applyHeader(seg)
+		return
+	}
+
+	m := p.member
+	switch seg.ID {
+	case "REF":
+		switch {
+		case p.coverage != nil:
+			p.coverage.References[seg.Element(1)] = seg.Element(2)
+		case seg.Element(1) == "0F":
+			m.SubscriberID = seg.Element(2)
+		case seg.Element(1) == "1L":
+			m.GroupNumber = seg.Element(2)
+		default:
+			m.References[seg.Element(1)] = seg.Element(2)
+		}
+
+	case "DTP":
+		d := dtp(seg)
+		switch {
+		case p.coverage != nil:
+			p.coverage.Dates = append(p.coverage.Dates, d)
+			switch d.Qualifier {
+			case "348":
+				p.coverage.Start = d.From
+			case "349":
+				p.coverage.End = d.From
+			}
+		case len(m.Coverages) == 0:
+			m.Dates = append(m.Dates, d)
+		}
+
+	case "NM1":
+		p.named = false
+		if seg.Element(1) == "IL" && p.coverage == nil {
+			m.LastName = seg.Element(3)
+			m.FirstName = seg.Element(4)
+			m.MiddleName = seg.Element(5)
+			m.IDQualifier = seg.Element(8)
+			m.ID = seg.Element(9)
+			p.named = true
+		}
+
+	case "N3":
+		if p.named {
+			m.Address.Line1 = seg.Element(1)
+			m.Address.Line2 = seg.Element(2)
+		}
+
+	case "N4":
+		if p.named {
+			m.Address.City = seg.Element(1)
+			m.Address.State = seg.Element(2)
+			m.Address.Zip = seg.Element(3)
+		}
+
+	case "DMG":
+		if p.named {
+			m.BirthDate, _, _ = x12.DateRange(seg.Element(1), seg.Element(2))
+			m.Gender = seg.Element(3)
+		}
+
+	case "HD":
+		p.named = false
+		p.coverage = &Coverage{
+			Maintenance:     Maintenance(seg.Element(1)),
+			InsuranceLine:   seg.Element(3),
+			PlanDescription: seg.Element(4),
+			CoverageLevel:   seg.Element(5),
+			References:      map[string]string{},
+		}
+		if p.coverage.Maintenance == "" {
+			p.coverage.Maintenance = m.Maintenance
+		}
+		m.Coverages = append(m.Coverages, p.coverage)
+
+	case "LX", "COB", "LS":
+		// Provider (2310), coordination of benefits (2320) and reporting
+		// category (2700) loops are not modelled; keep their DTP and REF
+		// off the coverage.
+		p.coverage, p.named = nil, false
+	}
+}
+
+func (p *Parser) applyHeader(seg *x12.Segment) {
+	h := p.header
+	switch seg.ID {
+	case "BGN":
+		h.Purpose = seg.Element(1)
+		h.Reference = seg.Element(2)
+		h.Date, _, _ = x12.DateRange("D8", seg.Element(3))
+		h.Action = seg.Element(8)
+	case "REF":
+		if seg.Element(1) == "38" {
+			h.MasterPolicy = seg.Element(2)
+		}
+	case "DTP":
+		if seg.Element(1) == "007" {
+			h.Effective = dtp(seg).From
+		}
+	case "N1":
+		e := Entity{Name: seg.Element(2), IDQualifier: seg.Element(3), ID: seg.Element(4)}
+		switch seg.Element(1) {
+		case "P5":
+			h.Sponsor = e
+		case "IN":
+			h.Payer = e
+		}
+	case "INS":
+		p.sequence++
+		p.member = &Member{
+			Header:            h,
+			Sequence:          p.sequence,
+			Subscriber:        seg.Element(1) == "Y",
+			Relationship:      seg.Element(2),
+			Maintenance:       Maintenance(seg.Element(3)),
+			MaintenanceReason: seg.Element(4),
+			BenefitStatus:     seg.Element(5),
+			EmploymentStatus:  seg.Element(8),
+			References:        map[string]string{},
+			Segment:           seg.Pos.Index,
+		}
+	}
+}
+
+func dtp(seg *x12.Segment) Date {
+	d := Date{Qualifier: seg.Element(1)}
+	var ok bool
+	d.From, d.To, ok = x12.DateRange(seg.Element(2), seg.Element(3))
+	if !ok {
+		// Keep the raw value; the silver layer rejects it with context.
+		d.From, d.To = seg.Element(3), seg.Element(3)
+	}
+	return d
+}
@@ -0,0 +1,164 @@
+package x834
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFile(t *testing.T, name string) []*Member {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+	return parseAll(t, NewParser(f))
+}
+
+func parseAll(t *testing.T, p *Parser) []*Member {
+	t.Helper()
+	var members []*Member
+	for {
+		m, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		members = append(members, m)
+	}
+	assert.Empty(t, p.Errors())
+	return members
+}
+
+func TestParseEnrollment(t *testing.T) {
+	members := parseFile(t, "834.x12")
+	require.Len(t, members, 4)
+
+	h := members[0].Header
+	require.NotNil(t, h)
+	assert.Equal(t, "000000104", h.InterchangeControlNumber)
+	assert.Equal(t, "0001", h.TransactionControlNumber)
+	assert.Equal(t, "00", h.Purpose)
+	assert.Equal(t, "ENR20251121", h.Reference)
+	assert.Equal(t, "2025-11-21", h.Date)
+	assert.Equal(t, "4", h.Action)
+	assert.Equal(t, "GRP100", h.MasterPolicy)
+	assert.Equal(t, "2025-11-21", h.Effective)
+	assert.Equal(t, Entity{Name: "SPRINGFIELD MANUFACTURING", IDQualifier: "FI", ID: "371111111"}, h.Sponsor)
+	assert.Equal(t, "ACME HEALTH PLAN", h.Payer.Name)
+	for _, m := range members {
+		assert.Same(t, h, m.Header, "Members of one ST share the header")
+	}
+
+	sub := members[0]
+	assert.Equal(t, 1, sub.Sequence)
+	assert.True(t, sub.Subscriber)
+	assert.Equal(t, "18", sub.Relationship)
+	assert.Equal(t, Add, sub.Maintenance)
+	assert.Equal(t, "28", sub.MaintenanceReason)
+	assert.Equal(t, "FT", sub.EmploymentStatus)
+	assert.Equal(t, "MBR0001", sub.SubscriberID)
+	assert.Equal(t, "MBR0001", sub.MemberID())
+	assert.Equal(t, "GRP100", sub.GroupNumber)
+	assert.Equal(t, "DOE", sub.LastName)
+	assert.Equal(t, "A", sub.MiddleName)
+	assert.Equal(t, "34", sub.IDQualifier)
+	assert.Equal(t, "123456789", sub.ID)
+	assert.Equal(t, Address{Line1: "12 OAK AVE", City: "SPRINGFIELD", State: "IL", Zip: "62704"}, sub.Address)
+	assert.Equal(t, "1980-01-15", sub.BirthDate)
+	begin, ok := sub.Date("356")
+	require.True(t, ok)
+	assert.Equal(t, "2025-01-01", begin.From)
+
+	require.Len(t, sub.Coverages, 2)
+	assert.Equal(t, Coverage{
+		Maintenance:     Add,
+		InsuranceLine:   "HLT",
+		PlanDescription: "PPO GOLD",
+		CoverageLevel:   "EMP",
+		Start:           "2025-01-01",
+		Dates:           []Date{{Qualifier: "348", From: "2025-01-01", To: "2025-01-01"}},
+		References:      map[string]string{},
+	}, *sub.Coverages[0])
+	assert.Equal(t, "DEN", sub.Coverages[1].InsuranceLine)
+
+	dep := members[1]
+	assert.False(t, dep.Subscriber)
+	assert.Equal(t, Change, dep.Maintenance)
+	assert.Equal(t, "MBR0001", dep.SubscriberID)
+	assert.Equal(t, "MBR0001-02", dep.MemberID(), "Dependents use their supplemental identifier")
+	assert.Equal(t, "2010-03-04", dep.BirthDate)
+	require.Len(t, dep.Coverages, 1)
+	assert.Equal(t, "FAM", dep.Coverages[0].CoverageLevel)
+
+	term := members[2]
+	assert.Equal(t, Terminate, term.Maintenance)
+	assert.Equal(t, "terminate", term.Maintenance.Action())
+	require.Len(t, term.Coverages, 1)
+	assert.Equal(t, "2024-01-01", term.Coverages[0].Start)
+	assert.Equal(t, "2025-10-31", term.Coverages[0].End)
+	assert.Empty(t, term.Address, "Member without N3/N4")
+
+	audit := members[3]
+	assert.Equal(t, Audit, audit.Maintenance)
+	assert.Equal(t, 4, audit.Sequence)
+	require.Len(t, audit.Coverages, 1)
+	assert.Equal(t, "PPO SILVER", audit.Coverages[0].PlanDescription)
+	assert.Empty(t, audit.Coverages[0].End, "Audit restates open coverage")
+}
+
+func TestParseMaintenanceRules(t *testing.T) {
+	body := strings.Join([]string{
+		"ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       *251121*1000*^*00501*000000001*0*T*:",
+		"GS*BE*S*R*20251121*1000*1*X*005010X220A1",
+		"ST*834*0001*005010X220A1",
+		"BGN*00*REF1*20251121****2",
+		"N1*P5*SPONSOR*FI*1",
+		"N1*IN*PAYER*FI*2",
+		"INS*Y*18*024*07*A",
+		"REF*0F*MBR9",
+		"DTP*357*D8*20251130",
+		"NM1*IL*1*LAST*FIRST",
+		"NM1*31*1",
+		"N3*PO BOX 1",
+		"HD***HLT**EMP",
+		"DTP*348*D8*20250101",
+		"HD*021**VIS**EMP",
+		"DTP*348*D8*20251201",
+		"LX*1",
+		"NM1*P3*1*PCP*DOC",
+		"DTP*348*D8*BADDATE",
+		"INS*Y*18*999",
+		"REF*0F*MBR10",
+		"HD*024**HLT",
+		"DTP*348*RD8*20250101-20250630",
+		"SE*22*0001",
+		"GE*1*1",
+		"IEA*1*000000001",
+	}, "~\n") + "~\n"
+
+	members := parseAll(t, NewParser(strings.NewReader(body)))
+	require.Len(t, members, 2)
+
+	m := members[0]
+	assert.Empty(t, m.Address, "Mailing address loop (NM1*31) is not the residence")
+	require.Len(t, m.Coverages, 2)
+	hlt := m.Coverages[0]
+	assert.Equal(t, Terminate, hlt.Maintenance, "Empty HD01 inherits INS03")
+	assert.Equal(t, "2025-11-30", hlt.End, "Terminations without 349 end on the eligibility end")
+	vis := m.Coverages[1]
+	assert.Equal(t, Add, vis.Maintenance)
+	assert.Empty(t, vis.End, "Only terminated coverages get an end date")
+	assert.Len(t, vis.Dates, 1, "DTP in the provider loop stays off the coverage")
+
+	unknown := members[1]
+	assert.Equal(t, Maintenance("999"), unknown.Maintenance)
+	assert.Empty(t, unknown.Maintenance.Action())
+	require.Len(t, unknown.Coverages, 1)
+	assert.Empty(t, unknown.Coverages[0].End, "Without 349 or 357 the end stays unknown")
+	assert.Equal(t, "2025-01-01", unknown.Coverages[0].Start)
+}
@@ -0,0 +1,133 @@
+package x834
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Column orders of the CSVs. They are part of the raw-layer contract: append
+// new columns at the end and never reorder.
+var (
+	MemberColumns = []string{
+		"file_id", "member_id", "event_sequence", "subscriber_id", "is_subscriber", "relationship",
+		"maintenance_code", "maintenance_action", "maintenance_reason",
+		"benefit_status", "employment_status", "group_number",
+		"last_name", "first_name", "middle_name", "id_qualifier", "identifier",
+		"address_line_1", "address_line_2", "city", "state", "zip",
+		"birth_date", "gender", "eligibility_begin", "eligibility_end", "maintenance_effective",
+		"sponsor_id", "sponsor_name", "payer_id", "payer_name", "master_policy_number",
+		"transaction_purpose", "transaction_action", "transaction_reference", "transaction_date",
+		"interchange_control_number", "transaction_control_number",
+	}
+	CoverageColumns = []string{
+		"file_id", "member_id", "event_sequence", "subscriber_id",
+		"maintenance_code", "maintenance_action", "insurance_line", "plan_description",
+		"coverage_level", "coverage_start", "coverage_end", "maintenance_effective",
+	}
+)
+
+// Writer writes members as the member and coverage CSVs. Rows of one event
+// share file_id and event_sequence.
+type Writer struct {
+	// FileID is the file-metadata file_id of the source X12 file.
+	FileID string
+
+	members   *csv.Writer
+	coverages *csv.Writer
+}
+
+// NewWriter returns a Writer and writes the header row of each CSV.
+func NewWriter(fileID string, members, coverages io.Writer) (*Writer, error) {
+	w := &Writer{
+		FileID:    fileID,
+		members:   csv.NewWriter(members),
+		coverages: csv.NewWriter(coverages),
+	}
+	if err := w.members.Write(MemberColumns); err != nil {
+		return nil, err
+	}
+	if err := w.coverages.Write(CoverageColumns); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes one member row and a row per coverage.
+func (w *Writer) Write(m *Member) error {
+	if err := w.members.Write(w.memberRow(m)); err != nil {
+		return err
+	}
+	for _, c := range m.Coverages {
+		effective := ""
+		for _, d := range c.Dates {
+			if d.Qualifier == "303" {
+				effective = d.From
+			}
+		}
+		row := []string{
+			w.FileID, m.MemberID(), strconv.Itoa(m.Sequence), m.SubscriberID,
+			string(c.Maintenance), c.Maintenance.Action(), c.InsuranceLine, c.PlanDescription,
+			c.CoverageLevel, c.Start, c.End, effective,
+		}
+		if err := w.coverages.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes both CSVs and returns the first error.
+func (w *Writer) Flush() error {
+	for _, cw := range []*csv.Writer{w.members, w.coverages} {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) memberRow(m *Member) []string {
+	begin, _ := m.Date("356")
+	end, _ := m.Date("357")
+	effective, _ := m.Date("303")
+	h := m.Header
+	if h == nil {
+		h = &Header{}
+	}
+	return []string{
+		w.FileID, m.MemberID(), strconv.Itoa(m.Sequence), m.SubscriberID, strconv.FormatBool(m.Subscriber), m.Relationship,
+		string(m.Maintenance), m.Maintenance.Action(), m.MaintenanceReason,
+		m.BenefitStatus, m.EmploymentStatus, m.GroupNumber,
+		m.LastName, m.FirstName, m.MiddleName, m.IDQualifier, m.ID,
+		m.Address.Line1, m.Address.Line2, m.Address.City, m.Address.State, m.Address.Zip,
+		m.BirthDate, m.Gender, begin.From, end.From, effective.From,
+		h.Sponsor.ID, h.Sponsor.Name, h.Payer.ID, h.Payer.Name, h.MasterPolicy,
+		h.Purpose, h.Action, h.Reference, h.Date,
+		h.InterchangeControlNumber, h.TransactionControlNumber,
+	}
+}
+
+// Convert parses an 834 stream and writes every member to w. It returns the
+// number of members and the envelope errors found; err is set only when the
+// input cannot be read or tokenized.
+func Convert(r io.Reader, w *Writer) (members int, envErrs []*x12.EnvelopeError, err error) {
+	p := NewParser(r)
+	for {
+		m, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return members, p.Errors(), err
+		}
+		if err := w.Write(m); err != nil {
+			return members, p.Errors(), err
+		}
+		members++
+	}
+	return members, p.Errors(), w.Flush()
+}
@@ -0,0 +1,71 @@
+package x834
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite golden CSVs in testdata")
+
+func convertFile(t *testing.T, name string) (members, coverages *bytes.Buffer) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", name+".x12"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	members, coverages = &bytes.Buffer{}, &bytes.Buffer{}
+	w, err := NewWriter("f-"+name, members, coverages)
+	require.NoError(t, err)
+
+	n, envErrs, err := Convert(f, w)
+	require.NoError(t, err)
+	require.Empty(t, envErrs)
+	assert.Equal(t, 4, n)
+	return members, coverages
+}
+
+func TestWriterGolden(t *testing.T) {
+	members, coverages := convertFile(t, "834")
+	for name, buf := range map[string]*bytes.Buffer{"member": members, "coverage": coverages} {
+		golden := filepath.Join("testdata", "834", name+".csv")
+		if *update {
+			require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+			require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		require.NoError(t, err, "run go test ./x12/x834 -update to create golden files")
+		assert.Equal(t, string(want), buf.String(), "%s differs from %s", name, golden)
+	}
+}
+
+func TestWriterColumnOrder(t *testing.T) {
+	members, coverages := convertFile(t, "834")
+	for name, c := range map[string]struct {
+		buf  *bytes.Buffer
+		cols []string
+		rows int
+	}{
+		"member":   {members, MemberColumns, 4},
+		"coverage": {coverages, CoverageColumns, 5},
+	} {
+		rows, err := csv.NewReader(bytes.NewReader(c.buf.Bytes())).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, c.cols, rows[0], "%s header", name)
+		assert.Len(t, rows, c.rows+1, "%s rows", name)
+		for i, row := range rows[1:] {
+			assert.Len(t, row, len(c.cols), "%s row %d", name, i+1)
+		}
+	}
+
+	// Appending is the only allowed change; these must not move.
+	assert.Equal(t, []string{"file_id", "member_id", "event_sequence"}, MemberColumns[:3])
+	assert.Equal(t, []string{"file_id", "member_id", "event_sequence"}, CoverageColumns[:3])
+}
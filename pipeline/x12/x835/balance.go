@@ -0,0 +1,190 @@
+package x835
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Balancing levels.
+const (
+	LevelPayment = "payment"
+	LevelClaim   = "claim"
+	LevelLine    = "line"
+)
+
+// BalanceError is a remittance that does not add up.
+type BalanceError struct {
+	Level       string
+	TraceNumber string
+	ClaimID     string
+	Line        int
+	Msg         string
+}
+
+func (e *BalanceError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "x835: payment %s", e.TraceNumber)
+	if e.ClaimID != "" {
+		fmt.Fprintf(&b, " claim %s", e.ClaimID)
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, " line %d", e.Line)
+	}
+	return b.String() + ": " + e.Msg
+}
+
+// Balance checks the balancing rules of the implementation guide:
+//
+//   - payment: BPR02 equals the sum of CLP04 minus the PLB amounts
+//   - claim: CLP03 minus CLP04 equals the claim and service CAS amounts
+//   - line: SVC02 minus SVC03 equals the service CAS amounts
+//
+// Amounts that are not valid decimals are reported as errors too.
+func (p *Payment) Balance() []*BalanceError {
+	b := &balancer{trace: p.TraceNumber}
+
+	var paid int64
+	paidOK := true
+	for _, c := range p.Claims {
+		charge, ok1 := b.amount(LevelClaim, c.ID, 0, "CLP03", c.Charge)
+		cp, ok2 := b.amount(LevelClaim, c.ID, 0, "CLP04", c.Paid)
+		adj, ok3 := b.adjustments(LevelClaim, c.ID, 0, c.Adjustments)
+		paid += cp
+		paidOK = paidOK && ok2
+
+		for _, s := range c.Services {
+			sc, ok4 := b.amount(LevelLine, c.ID, s.Line, "SVC02", s.Charge)
+			sp, ok5 := b.amount(LevelLine, c.ID, s.Line, "SVC03", s.Paid)
+			sa, ok6 := b.adjustments(LevelLine, c.ID, s.Line, s.Adjustments)
+			if ok4 && ok5 && ok6 && sc-sp != sa {
+				b.fail(LevelLine, c.ID, s.Line, "charge %s minus paid %s is %s but adjustments total %s",
+					FormatCents(sc), FormatCents(sp), FormatCents(sc-sp), FormatCents(sa))
+			}
+			adj += sa
+			ok3 = ok3 && ok6
+		}
+		if ok1 && ok2 && ok3 && charge-cp != adj {
+			b.fail(LevelClaim, c.ID, 0, "charge %s minus paid %s is %s but adjustments total %s",
+				FormatCents(charge), FormatCents(cp), FormatCents(charge-cp), FormatCents(adj))
+		}
+	}
+
+	var plb int64
+	plbOK := true
+	for _, a := range p.ProviderAdjustments {
+		v, ok := b.amount(LevelPayment, "", 0, "PLB", a.Amount)
+		plb += v
+		plbOK = plbOK && ok
+	}
+	total, ok := b.amount(LevelPayment, "", 0, "BPR02", p.TotalPaid)
+	if ok && paidOK && plbOK && total != paid-plb {
+		b.fail(LevelPayment, "", 0, "BPR02 %s does not equal claims paid %s minus provider adjustments %s",
+			FormatCents(total), FormatCents(paid), FormatCents(plb))
+	}
+	return b.errs
+}
+
+type balancer struct {
+	trace string
+	errs  []*BalanceError
+}
+
+func (b *balancer) fail(level, claim string, line int, format string, args ...any) {
+	b.errs = append(b.errs, &BalanceError{
+		Level: level, TraceNumber: b.trace, ClaimID: claim, Line: line,
+		Msg: fmt.Sprintf(format, args...),
+	})
+}
+
+func (b *balancer) amount(level, claim string, line int, name, v string) (int64, bool) {
+	n, err := Cents(v)
+	if err != nil {
+		b.fail(level, claim, line, "%s: %v", name, err)
+		return 0, false
+	}
+	return n, true
+}
+
+func (b *balancer) adjustments(level, claim string, line int, adjs []Adjustment) (int64, bool) {
+	var sum int64
+	ok := true
+	for _, a := range adjs {
+		n, valid := b.amount(level, claim, line, "CAS "+a.Group+"-"+a.Reason, a.Amount)
+		sum += n
+		ok = ok && valid
+	}
+	return sum, ok
+}
+
+// ClaimsPaid returns the sum of CLP04, ignoring invalid amounts.
+func (p *Payment) ClaimsPaid() int64 {
+	var n int64
+	for _, c := range p.Claims {
+		v, _ := Cents(c.Paid)
+		n += v
+	}
+	return n
+}
+
+// ProviderAdjustmentTotal returns the sum of the PLB amounts, ignoring
+// invalid amounts.
+func (p *Payment) ProviderAdjustmentTotal() int64 {
+	var n int64
+	for _, a := range p.ProviderAdjustments {
+		v, _ := Cents(a.Amount)
+		n += v
+	}
+	return n
+}
+
+// Cents parses an X12 decimal amount (R data type) into cents. An empty value
+// is zero. Digits beyond the second decimal place must be zero.
+func Cents(v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	s := v
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", v)
+	}
+	if len(frac) > 2 {
+		if strings.Trim(frac[2:], "0") != "" {
+			return 0, fmt.Errorf("amount %q has fractional cents", v)
+		}
+		frac = frac[:2]
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid amount %q", v)
+		}
+	}
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", v)
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// FormatCents renders cents as a decimal with two places.
+func FormatCents(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/100, n%100)
+}
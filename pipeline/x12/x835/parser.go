@@ -0,0 +1,280 @@
+package x835
+
+import (
+	"io"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Parser reads payments from an 835 file. One transaction set is held in
+// memory at a time: balancing needs all of its claims and the provider
+// adjustments that follow them.
+type Parser struct {
+	r   *x12.Reader
+	err error
+
+	interchange string
+	payment     *Payment
+	party       *Party
+	claim       *ClaimPayment
+	service     *ServicePayment
+}
+
+// NewParser returns a Parser reading r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: x12.NewReader(r)}
+}
+
+// Errors returns the envelope errors found so far.
+func (p *Parser) Errors() []*x12.EnvelopeError {
+	return p.r.Errors()
+}
+
+// Next returns the next payment, or io.EOF after the last one. A transaction
+// set missing its SE is returned when the next ST or the end of input is
+// reached; the envelope error is in Errors.
+func (p *Parser) Next() (*Payment, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for {
+		seg, err := p.r.Next()
+		if err != nil {
+			p.err = err
+			if err == io.EOF && p.payment != nil {
+				return p.finish(), nil
+			}
+			return nil, err
+		}
+
+		switch seg.ID {
+		case "ST":
+			prev := p.payment
+			p.start(seg)
+			if prev != nil {
+				return prev, nil
+			}
+			continue
+		case "SE":
+			if p.payment != nil {
+				return p.finish(), nil
+			}
+			continue
+		}
+		p.apply(seg)
+	}
+}
+
+func (p *Parser) start(seg *x12.Segment) {
+	p.payment = &Payment{
+		InterchangeControlNumber: p.interchange,
+		TransactionControlNumber: seg.Element(2),
+	}
+	p.party, p.claim, p.service = nil, nil, nil
+}
+
+func (p *Parser) finish() *Payment {
+	pay := p.payment
+	p.payment, p.party, p.claim, p.service = nil, nil, nil, nil
+	return pay
+}
+
+func (p *Parser) apply(seg *x12.Segment) {
+	if seg.ID == "ISA" {
+		p.interchange = seg.Element(13)
+		return
+	}
+	pay := p.payment
+	if pay == nil {
+		return
+	}
+
+	switch seg.ID {
+	case "BPR":
+		pay.HandlingCode = seg.Element(1)
+		pay.TotalPaid = seg.Element(2)
+		pay.CreditDebit = seg.Element(3)
+		pay.Method = seg.Element(4)
+		pay.PaymentDate = date(seg.Element(16))
+
+	case "TRN":
+		pay.TraceNumber = seg.Element(2)
+		pay.OriginatorID = seg.Element(3)
+
+	case "DTM":
+		v := date(seg.Element(2))
+		switch {
+		case p.service != nil:
+			switch seg.Element(1) {
+			case "472":
+				p.service.ServiceFrom, p.service.ServiceTo = v, v
+			case "150":
+				p.service.ServiceFrom = v
+			case "151":
+				p.service.ServiceTo = v
+			}
+		case p.claim != nil:
+			switch seg.Element(1) {
+			case "232":
+				p.claim.StatementFrom = v
+			case "233":
+				p.claim.StatementTo = v
+			}
+		case seg.Element(1) == "405":
+			pay.ProductionDate = v
+		}
+
+	case "N1":
+		party := Party{Name: seg.Element(2), IDQualifier: seg.Element(3), ID: seg.Element(4), References: map[string]string{}}
+		p.party = nil
+		switch seg.Element(1) {
+		case "PR":
+			pay.Payer = party
+			p.party = &pay.Payer
+		case "PE":
+			pay.Payee = party
+			p.party = &pay.Payee
+		}
+
+	case "N3":
+		if p.party != nil {
+			p.party.Address.Line1 = seg.Element(1)
+			p.party.Address.Line2 = seg.Element(2)
+		}
+
+	case "N4":
+		if p.party != nil {
+			p.party.Address.City = seg.Element(1)
+			p.party.Address.State = seg.Element(2)
+			p.party.Address.Zip = seg.Element(3)
+		}
+
+	case "REF":
+		switch {
+		case p.service != nil:
+			if seg.Element(1) == "6R" {
+				p.service.ControlNumber = seg.Element(2)
+			}
+		case p.claim != nil:
+			p.claim.References[seg.Element(1)] = seg.Element(2)
+		case p.party != nil:
+			p.party.References[seg.Element(1)] = seg.Element(2)
+		}
+
+	case "LX":
+		p.party = nil
+
+	case "CLP":
+		p.party, p.service = nil, nil
+		p.claim = &ClaimPayment{
+			ID:                    seg.Element(1),
+			Status:                seg.Element(2),
+			Charge:                seg.Element(3),
+			Paid:                  seg.Element(4),
+			PatientResponsibility: seg.Element(5),
+			FilingCode:            seg.Element(6),
+			PayerControlNumber:    seg.Element(7),
+			FacilityCode:          seg.Element(8),
+			FrequencyCode:         seg.Element(9),
+			References:            map[string]string{},
+			Segment:               seg.Pos.Index,
+		}
+		pay.Claims = append(pay.Claims, p.claim)
+
+	case "NM1":
+		if p.claim == nil {
+			return
+		}
+		person := Person{LastName: seg.Element(3), FirstName: seg.Element(4), IDQualifier: seg.Element(8), ID: seg.Element(9)}
+		switch seg.Element(1) {
+		case "QC":
+			p.claim.Patient = person
+		case "IL":
+			p.claim.Insured = person
+		}
+
+	case "CAS":
+		adjs := cas(seg)
+		switch {
+		case p.service != nil:
+			p.service.Adjustments = append(p.service.Adjustments, adjs...)
+		case p.claim != nil:
+			p.claim.Adjustments = append(p.claim.Adjustments, adjs...)
+		}
+
+	case "SVC":
+		if p.claim == nil {
+			return
+		}
+		comps := seg.Components(1)
+		p.service = &ServicePayment{
+			Line:        len(p.claim.Services) + 1,
+			Charge:      seg.Element(2),
+			Paid:        seg.Element(3),
+			RevenueCode: seg.Element(4),
+			Units:       seg.Element(5),
+		}
+		if len(comps) > 0 {
+			p.service.ProcedureQualifier = comps[0]
+		}
+		if len(comps) > 1 {
+			p.service.ProcedureCode = comps[1]
+		}
+		for i := 2; i < len(comps) && i < 6; i++ {
+			if comps[i] != "" {
+				p.service.Modifiers = append(p.service.Modifiers, comps[i])
+			}
+		}
+		p.claim.Services = append(p.claim.Services, p.service)
+
+	case "AMT":
+		if p.service != nil && seg.Element(1) == "B6" {
+			p.service.Allowed = seg.Element(2)
+		}
+
+	case "PLB":
+		p.claim, p.service, p.party = nil, nil, nil
+		provider := seg.Element(1)
+		period := date(seg.Element(2))
+		// Up to six reason/amount pairs in PLB03-PLB14.
+		for n := 3; n+1 <= len(seg.Elements) && n <= 13; n += 2 {
+			if seg.Element(n) == "" && seg.Element(n+1) == "" {
+				continue
+			}
+			pay.ProviderAdjustments = append(pay.ProviderAdjustments, ProviderAdjustment{
+				ProviderID:   provider,
+				FiscalPeriod: period,
+				Reason:       seg.Component(n, 1),
+				Reference:    seg.Component(n, 2),
+				Amount:       seg.Element(n + 1),
+			})
+		}
+	}
+}
+
+// cas splits a CAS segment into its reason/amount/quantity triplets
+// (CAS02-CAS19).
+func cas(seg *x12.Segment) []Adjustment {
+	var adjs []Adjustment
+	for n := 2; n <= 17; n += 3 {
+		if seg.Element(n) == "" {
+			continue
+		}
+		adjs = append(adjs, Adjustment{
+			Group:    seg.Element(1),
+			Reason:   seg.Element(n),
+			Amount:   seg.Element(n + 1),
+			Quantity: seg.Element(n + 2),
+		})
+	}
+	return adjs
+}
+
+// date converts a CCYYMMDD element, keeping unparseable values verbatim for
+// the silver layer to reject with context.
+func date(v string) string {
+	if d, _, ok := x12.DateRange("D8", v); ok {
+		return d
+	}
+	return v
+}
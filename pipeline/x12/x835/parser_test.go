@@ -0,0 +1,202 @@
+package x835
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFile(t *testing.T, name string) []*Payment {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+	return parseAll(t, NewParser(f))
+}
+
+func parseAll(t *testing.T, p *Parser) []*Payment {
+	t.Helper()
+	var payments []*Payment
+	for {
+		pay, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		payments = append(payments, pay)
+	}
+	assert.Empty(t, p.Errors())
+	return payments
+}
+
+func TestParseRemittance(t *testing.T) {
+	payments := parseFile(t, "835.x12")
+	require.Len(t, payments, 1)
+
+	p := payments[0]
+	assert.Equal(t, "000000103", p.InterchangeControlNumber)
+	assert.Equal(t, "0001", p.TransactionControlNumber)
+	assert.Equal(t, "I", p.HandlingCode)
+	assert.Equal(t, "190.00", p.TotalPaid)
+	assert.Equal(t, "C", p.CreditDebit)
+	assert.Equal(t, "ACH", p.Method)
+	assert.Equal(t, "2025-11-21", p.PaymentDate)
+	assert.Equal(t, "EFT000123", p.TraceNumber)
+	assert.Equal(t, "1512345678", p.OriginatorID)
+	assert.Equal(t, "2025-11-20", p.ProductionDate)
+
+	assert.Equal(t, "ACME HEALTH PLAN", p.Payer.Name)
+	assert.Equal(t, "HARTFORD", p.Payer.Address.City)
+	assert.Equal(t, "PAYER01", p.Payer.References["2U"])
+	assert.Equal(t, "RIVERSIDE CLINIC", p.Payee.Name)
+	assert.Equal(t, "XX", p.Payee.IDQualifier)
+	assert.Equal(t, "1234567893", p.Payee.ID)
+	assert.Equal(t, "371234567", p.Payee.References["TJ"])
+
+	require.Len(t, p.Claims, 2)
+	c := p.Claims[0]
+	assert.Equal(t, "PCN0001", c.ID)
+	assert.Equal(t, "1", c.Status)
+	assert.False(t, c.Denied())
+	assert.Equal(t, "150.00", c.Charge)
+	assert.Equal(t, "120.00", c.Paid)
+	assert.Equal(t, "30.00", c.PatientResponsibility)
+	assert.Equal(t, "CLMCTL0001", c.PayerControlNumber)
+	assert.Equal(t, "1", c.FrequencyCode)
+	assert.Equal(t, Person{LastName: "DOE", FirstName: "JANE", IDQualifier: "MI", ID: "MBR0001"}, c.Patient)
+	assert.Equal(t, "2025-11-01", c.StatementFrom)
+	assert.Empty(t, c.Adjustments, "Adjustments are at line level in the fixture")
+
+	require.Len(t, c.Services, 2)
+	s := c.Services[0]
+	assert.Equal(t, 1, s.Line)
+	assert.Equal(t, "HC", s.ProcedureQualifier)
+	assert.Equal(t, "99213", s.ProcedureCode)
+	assert.Equal(t, []string{"25"}, s.Modifiers)
+	assert.Equal(t, "100.00", s.Charge)
+	assert.Equal(t, "80.00", s.Paid)
+	assert.Equal(t, "1", s.Units)
+	assert.Equal(t, "2025-11-03", s.ServiceFrom)
+	assert.Equal(t, []Adjustment{{Group: "PR", Reason: "1", Amount: "20.00"}}, s.Adjustments)
+
+	assert.Len(t, p.Claims[1].Services, 1)
+	assert.Empty(t, p.Claims[1].Services[0].ServiceFrom)
+
+	require.Len(t, p.ProviderAdjustments, 1)
+	assert.Equal(t, ProviderAdjustment{
+		ProviderID: "1234567893", FiscalPeriod: "2025-12-31", Reason: "WO", Reference: "PCN0000", Amount: "10.00",
+	}, p.ProviderAdjustments[0])
+
+	assert.Empty(t, p.Balance())
+	assert.Equal(t, int64(20000), p.ClaimsPaid())
+	assert.Equal(t, int64(1000), p.ProviderAdjustmentTotal())
+}
+
+func TestParseDelimiters(t *testing.T) {
+	std := parseFile(t, "835.x12")
+	alt := parseFile(t, "835_delims.x12")
+	require.Len(t, alt, 1)
+
+	// Only the interchange and group control numbers differ.
+	alt[0].InterchangeControlNumber = std[0].InterchangeControlNumber
+	assert.Equal(t, std, alt)
+}
+
+// remit builds a one-transaction 835 around body, with a correct SE count.
+func remit(body ...string) string {
+	segs := append([]string{"ST*835*0001*005010X221A1"}, body...)
+	segs = append(segs, "SE*"+strconv.Itoa(len(segs)+1)+"*0001")
+	all := append([]string{
+		"ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       *251121*1000*^*00501*000000001*0*T*:",
+		"GS*HP*S*R*20251121*1000*1*X*005010X221A1",
+	}, segs...)
+	all = append(all, "GE*1*1", "IEA*1*000000001")
+	return strings.Join(all, "~\n") + "~\n"
+}
+
+func TestBalanceErrors(t *testing.T) {
+	body := remit(
+		"BPR*I*100.00*C*CHK************20251121",
+		"TRN*1*CHK1*1",
+		"N1*PR*PAYER",
+		"N1*PE*PAYEE*XX*1",
+		"LX*1",
+		"CLP*A*1*100.00*60.00**12*X",
+		"CAS*CO*45*30.00**253*5.00",
+		"SVC*HC:99213*100.00*60.00**1",
+		"CAS*CO*45*30.00",
+		"CLP*B*4*50.00*0*50.00*12*Y",
+		"CAS*CO*29*50.00",
+		"PLB*1*20251231*L6:INT*-1.00*FB*5.00",
+	)
+	payments := parseAll(t, NewParser(strings.NewReader(body)))
+	require.Len(t, payments, 1)
+	p := payments[0]
+
+	require.Len(t, p.Claims, 2)
+	assert.Equal(t, []Adjustment{
+		{Group: "CO", Reason: "45", Amount: "30.00"},
+		{Group: "CO", Reason: "253", Amount: "5.00"},
+	}, p.Claims[0].Adjustments, "Each CAS triplet is one adjustment")
+	assert.True(t, p.Claims[1].Denied())
+	require.Len(t, p.ProviderAdjustments, 2)
+	assert.Equal(t, "-1.00", p.ProviderAdjustments[0].Amount)
+	assert.Equal(t, "FB", p.ProviderAdjustments[1].Reason)
+
+	errs := p.Balance()
+	require.Len(t, errs, 3)
+
+	assert.Equal(t, LevelLine, errs[0].Level)
+	assert.Equal(t, "A", errs[0].ClaimID)
+	assert.Equal(t, 1, errs[0].Line)
+	assert.Equal(t, "x835: payment CHK1 claim A line 1: charge 100.00 minus paid 60.00 is 40.00 but adjustments total 30.00", errs[0].Error())
+
+	assert.Equal(t, LevelClaim, errs[1].Level)
+	assert.Contains(t, errs[1].Msg, "is 40.00 but adjustments total 65.00", "Claim CAS plus line CAS")
+
+	assert.Equal(t, LevelPayment, errs[2].Level)
+	assert.Equal(t, "x835: payment CHK1: BPR02 100.00 does not equal claims paid 60.00 minus provider adjustments 4.00", errs[2].Error())
+}
+
+func TestBalanceInvalidAmounts(t *testing.T) {
+	p := &Payment{
+		TraceNumber: "T",
+		TotalPaid:   "1O0.00",
+		Claims:      []*ClaimPayment{{ID: "A", Charge: "10", Paid: "10.001"}},
+	}
+	errs := p.Balance()
+	require.Len(t, errs, 2, "Invalid amounts are reported instead of a mismatch")
+	assert.Equal(t, LevelClaim, errs[0].Level)
+	assert.Contains(t, errs[0].Msg, `CLP04: amount "10.001" has fractional cents`)
+	assert.Equal(t, LevelPayment, errs[1].Level)
+	assert.Contains(t, errs[1].Msg, `BPR02: invalid amount "1O0.00"`)
+}
+
+func TestCents(t *testing.T) {
+	for in, want := range map[string]int64{
+		"":        0,
+		"0":       0,
+		"190.00":  19000,
+		"190":     19000,
+		"1.5":     150,
+		".75":     75,
+		"-10.00":  -1000,
+		"12.3400": 1234,
+	} {
+		got, err := Cents(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"-", ".", "1.005", "1,00", "+1", "1e3", "12.3.4"} {
+		_, err := Cents(in)
+		assert.Error(t, err, in)
+	}
+	assert.Equal(t, "-0.05", FormatCents(-5))
+	assert.Equal(t, "1234.50", FormatCents(123450))
+}
@@ -0,0 +1,132 @@
+// Package x835 parses 835 health care claim payment/advice (005010X221)
+// transactions and writes them as the payment, claim_payment,
+// service_payment and adjustment CSVs of the raw layer.
+package x835
+
+// Party is an N1 (header) entity with the N3/N4 and REF that follow it.
+type Party struct {
+	Name        string
+	IDQualifier string
+	ID          string
+	Address     Address
+	// References maps REF qualifiers (e.g. TJ tax id, 2U payer id) to values.
+	References map[string]string
+}
+
+// Address is an N3/N4 pair.
+type Address struct {
+	Line1 string
+	Line2 string
+	City  string
+	State string
+	Zip   string
+}
+
+// Person is an NM1 of the claim payment loop (patient, insured).
+type Person struct {
+	LastName    string
+	FirstName   string
+	IDQualifier string
+	ID          string
+}
+
+// Adjustment is one group/reason/amount triplet of a CAS segment.
+type Adjustment struct {
+	// Group is CAS01: CO contractual, PR patient responsibility, OA other,
+	// PI payer initiated, CR correction.
+	Group string
+	// Reason is the claim adjustment reason code (CARC), e.g. 1 deductible,
+	// 45 charge exceeds fee schedule.
+	Reason   string
+	Amount   string
+	Quantity string
+}
+
+// ProviderAdjustment is one reason/amount pair of a PLB segment. A positive
+// amount reduces the payment.
+type ProviderAdjustment struct {
+	ProviderID   string
+	FiscalPeriod string
+	// Reason is PLB03-1, e.g. WO overpayment recovery, L6 interest.
+	Reason    string
+	Reference string
+	Amount    string
+}
+
+// ServicePayment is one SVC loop (2110).
+type ServicePayment struct {
+	// Line is the 1-based ordinal of the SVC within its claim.
+	Line               int
+	ProcedureQualifier string
+	ProcedureCode      string
+	Modifiers          []string
+	Charge             string
+	Paid               string
+	RevenueCode        string
+	Units              string
+	// Allowed is AMT*B6.
+	Allowed     string
+	ServiceFrom string
+	ServiceTo   string
+	// ControlNumber is REF*6R, the line item control number of the 837.
+	ControlNumber string
+	Adjustments   []Adjustment
+}
+
+// ClaimPayment is one CLP loop (2100).
+type ClaimPayment struct {
+	ID string
+	// Status is CLP02: 1 processed as primary, 4 denied, 22 reversal, ...
+	Status                string
+	Charge                string
+	Paid                  string
+	PatientResponsibility string
+	FilingCode            string
+	// PayerControlNumber is CLP07, the payer's claim control number.
+	PayerControlNumber string
+	FacilityCode       string
+	FrequencyCode      string
+
+	Patient Person
+	Insured Person
+	// StatementFrom and StatementTo are DTM*232 and DTM*233.
+	StatementFrom string
+	StatementTo   string
+	References    map[string]string
+
+	Adjustments []Adjustment
+	Services    []*ServicePayment
+
+	// Segment is the 1-based index of the CLP segment in the file.
+	Segment int
+}
+
+// Denied reports whether the payer denied the claim (CLP02 = 4).
+func (c *ClaimPayment) Denied() bool {
+	return c.Status == "4"
+}
+
+// Payment is one 835 transaction set: a single check or EFT with the claims it
+// pays and the provider-level adjustments taken from it.
+type Payment struct {
+	InterchangeControlNumber string
+	TransactionControlNumber string
+
+	// HandlingCode is BPR01: I remittance with payment, H notification only.
+	HandlingCode string
+	TotalPaid    string
+	CreditDebit  string
+	// Method is BPR04: ACH, CHK, BOP, FWT or NON.
+	Method      string
+	PaymentDate string
+	// TraceNumber is TRN02, the check or EFT trace number.
+	TraceNumber    string
+	OriginatorID   string
+	ProductionDate string
+
+	Payer Party
+	Payee Party
+
+	Claims              []*ClaimPayment
+	ProviderAdjustments []ProviderAdjustment
+}
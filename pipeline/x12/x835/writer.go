@@ -0,0 +1,183 @@
+package x835
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Column orders of the CSVs. They are part of the raw-layer contract: append
+// new columns at the end and never reorder.
+var (
+	PaymentColumns = []string{
+		"file_id", "trace_number", "handling_code", "payment_method", "credit_debit",
+		"total_paid", "payment_date", "originator_id", "production_date",
+		"payer_id", "payer_name", "payee_id_qualifier", "payee_id", "payee_name", "payee_tax_id",
+		"claim_count", "claims_paid", "provider_adjustment_total", "balanced",
+		"interchange_control_number", "transaction_control_number",
+	}
+	ClaimPaymentColumns = []string{
+		"file_id", "trace_number", "claim_id", "status_code", "denied",
+		"total_charge", "paid", "patient_responsibility", "claim_filing_code",
+		"payer_claim_control_number", "facility_code", "frequency_code",
+		"patient_id", "patient_last_name", "patient_first_name", "insured_id",
+		"statement_from", "statement_to", "service_count",
+	}
+	ServicePaymentColumns = []string{
+		"file_id", "trace_number", "claim_id", "line_number", "line_control_number",
+		"procedure_qualifier", "procedure_code", "modifier_1", "modifier_2", "modifier_3", "modifier_4",
+		"revenue_code", "charge", "paid", "allowed", "units", "service_from", "service_to",
+	}
+	// AdjustmentColumns covers claim (CAS in 2100), line (CAS in 2110) and
+	// provider (PLB) adjustments, told apart by level.
+	AdjustmentColumns = []string{
+		"file_id", "trace_number", "claim_id", "line_number", "level",
+		"group_code", "reason_code", "amount", "quantity", "provider_id", "reference",
+	}
+)
+
+// Writer writes payments as the four 835 CSVs. Rows of one payment share
+// file_id and trace_number; claim rows also share claim_id.
+type Writer struct {
+	// FileID is the file-metadata file_id of the source X12 file.
+	FileID string
+
+	payments    *csv.Writer
+	claims      *csv.Writer
+	services    *csv.Writer
+	adjustments *csv.Writer
+}
+
+// NewWriter returns a Writer and writes the header row of each CSV.
+func NewWriter(fileID string, payments, claims, services, adjustments io.Writer) (*Writer, error) {
+	w := &Writer{
+		FileID:      fileID,
+		payments:    csv.NewWriter(payments),
+		claims:      csv.NewWriter(claims),
+		services:    csv.NewWriter(services),
+		adjustments: csv.NewWriter(adjustments),
+	}
+	for _, h := range []struct {
+		cw   *csv.Writer
+		cols []string
+	}{
+		{w.payments, PaymentColumns},
+		{w.claims, ClaimPaymentColumns},
+		{w.services, ServicePaymentColumns},
+		{w.adjustments, AdjustmentColumns},
+	} {
+		if err := h.cw.Write(h.cols); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Write writes one payment with its claims, services and adjustments, and
+// returns its balancing errors. An unbalanced payment is still written, with
+// balanced=false.
+func (w *Writer) Write(p *Payment) ([]*BalanceError, error) {
+	bal := p.Balance()
+	row := []string{
+		w.FileID, p.TraceNumber, p.HandlingCode, p.Method, p.CreditDebit,
+		p.TotalPaid, p.PaymentDate, p.OriginatorID, p.ProductionDate,
+		p.Payer.References["2U"], p.Payer.Name, p.Payee.IDQualifier, p.Payee.ID, p.Payee.Name, p.Payee.References["TJ"],
+		strconv.Itoa(len(p.Claims)), FormatCents(p.ClaimsPaid()), FormatCents(p.ProviderAdjustmentTotal()), strconv.FormatBool(len(bal) == 0),
+		p.InterchangeControlNumber, p.TransactionControlNumber,
+	}
+	if err := w.payments.Write(row); err != nil {
+		return bal, err
+	}
+
+	for _, c := range p.Claims {
+		row := []string{
+			w.FileID, p.TraceNumber, c.ID, c.Status, strconv.FormatBool(c.Denied()),
+			c.Charge, c.Paid, c.PatientResponsibility, c.FilingCode,
+			c.PayerControlNumber, c.FacilityCode, c.FrequencyCode,
+			c.Patient.ID, c.Patient.LastName, c.Patient.FirstName, c.Insured.ID,
+			c.StatementFrom, c.StatementTo, strconv.Itoa(len(c.Services)),
+		}
+		if err := w.claims.Write(row); err != nil {
+			return bal, err
+		}
+		if err := w.writeAdjustments(p, c.ID, "", LevelClaim, c.Adjustments); err != nil {
+			return bal, err
+		}
+		for _, s := range c.Services {
+			mods := make([]string, 4)
+			copy(mods, s.Modifiers)
+			line := strconv.Itoa(s.Line)
+			row := []string{
+				w.FileID, p.TraceNumber, c.ID, line, s.ControlNumber,
+				s.ProcedureQualifier, s.ProcedureCode, mods[0], mods[1], mods[2], mods[3],
+				s.RevenueCode, s.Charge, s.Paid, s.Allowed, s.Units, s.ServiceFrom, s.ServiceTo,
+			}
+			if err := w.services.Write(row); err != nil {
+				return bal, err
+			}
+			if err := w.writeAdjustments(p, c.ID, line, LevelLine, s.Adjustments); err != nil {
+				return bal, err
+			}
+		}
+	}
+
+	for _, a := range p.ProviderAdjustments {
+		row := []string{
+			w.FileID, p.TraceNumber, "", "", "provider",
+			"", a.Reason, a.Amount, "", a.ProviderID, a.Reference,
+		}
+		if err := w.adjustments.Write(row); err != nil {
+			return bal, err
+		}
+	}
+	return bal, nil
+}
+
+func (w *Writer) writeAdjustments(p *Payment, claim, line, level string, adjs []Adjustment) error {
+	for _, a := range adjs {
+		row := []string{
+			w.FileID, p.TraceNumber, claim, line, level,
+			a.Group, a.Reason, a.Amount, a.Quantity, "", "",
+		}
+		if err := w.adjustments.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes all four CSVs and returns the first error.
+func (w *Writer) Flush() error {
+	for _, cw := range []*csv.Writer{w.payments, w.claims, w.services, w.adjustments} {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert parses an 835 stream and writes every payment to w. It returns the
+// number of payments, the envelope errors and the balancing errors found; err
+// is set only when the input cannot be read or tokenized.
+func Convert(r io.Reader, w *Writer) (payments int, envErrs []*x12.EnvelopeError, balErrs []*BalanceError, err error) {
+	p := NewParser(r)
+	for {
+		pay, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return payments, p.Errors(), balErrs, err
+		}
+		bal, err := w.Write(pay)
+		balErrs = append(balErrs, bal...)
+		if err != nil {
+			return payments, p.Errors(), balErrs, err
+		}
+		payments++
+	}
+	return payments, p.Errors(), balErrs, w.Flush()
+}
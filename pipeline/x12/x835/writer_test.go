@@ -0,0 +1,91 @@
+package x835
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite golden CSVs in testdata")
+
+var outputs = []string{"payment", "claim_payment", "service_payment", "adjustment"}
+
+func convert(t *testing.T, fileID, input string) (map[string]*bytes.Buffer, []*BalanceError) {
+	t.Helper()
+	bufs := map[string]*bytes.Buffer{}
+	for _, o := range outputs {
+		bufs[o] = &bytes.Buffer{}
+	}
+	w, err := NewWriter(fileID, bufs["payment"], bufs["claim_payment"], bufs["service_payment"], bufs["adjustment"])
+	require.NoError(t, err)
+
+	_, envErrs, balErrs, err := Convert(strings.NewReader(input), w)
+	require.NoError(t, err)
+	require.Empty(t, envErrs)
+	return bufs, balErrs
+}
+
+func TestWriterGolden(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("..", "testdata", "835.x12"))
+	require.NoError(t, err)
+	bufs, balErrs := convert(t, "f-835", string(input))
+	assert.Empty(t, balErrs)
+
+	for _, o := range outputs {
+		golden := filepath.Join("testdata", "835", o+".csv")
+		if *update {
+			require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+			require.NoError(t, os.WriteFile(golden, bufs[o].Bytes(), 0o644))
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		require.NoError(t, err, "run go test ./x12/x835 -update to create golden files")
+		assert.Equal(t, string(want), bufs[o].String(), "%s differs from %s", o, golden)
+	}
+}
+
+func TestWriterUnbalancedPayment(t *testing.T) {
+	bufs, balErrs := convert(t, "f1", remit(
+		"BPR*I*99.00*C*CHK",
+		"TRN*1*CHK9*1",
+		"CLP*A*1*100.00*100.00",
+	))
+	require.Len(t, balErrs, 1)
+	assert.Equal(t, LevelPayment, balErrs[0].Level)
+
+	rows, err := csv.NewReader(bytes.NewReader(bufs["payment"].Bytes())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "false", rows[1][18], "Unbalanced payments are written and flagged")
+}
+
+func TestWriterColumnOrder(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("..", "testdata", "835.x12"))
+	require.NoError(t, err)
+	bufs, _ := convert(t, "f-835", string(input))
+	for o, cols := range map[string][]string{
+		"payment":         PaymentColumns,
+		"claim_payment":   ClaimPaymentColumns,
+		"service_payment": ServicePaymentColumns,
+		"adjustment":      AdjustmentColumns,
+	} {
+		rows, err := csv.NewReader(bytes.NewReader(bufs[o].Bytes())).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, cols, rows[0], "%s header", o)
+		for i, row := range rows[1:] {
+			assert.Len(t, row, len(cols), "%s row %d", o, i+1)
+		}
+	}
+	assert.Equal(t, "balanced", PaymentColumns[18])
+
+	// Appending is the only allowed change; these must not move.
+	assert.Equal(t, []string{"file_id", "trace_number"}, PaymentColumns[:2])
+	assert.Equal(t, []string{"file_id", "trace_number", "claim_id"}, ClaimPaymentColumns[:3])
+}
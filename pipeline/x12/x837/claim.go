@@ -0,0 +1,196 @@
+// Package x837 parses 837 professional (005010X222) and institutional
+// (005010X223) claim transactions into claims and writes them as the
+// claim_header, claim_line, diagnosis and provider CSVs of the raw layer.
+package x837
+
+// Kind distinguishes professional from institutional claims.
+type Kind string
+
+const (
+	Professional  Kind = "P"
+	Institutional Kind = "I"
+)
+
+// Address is an N3/N4 pair.
+type Address struct {
+	Line1 string
+	Line2 string
+	City  string
+	State string
+	Zip   string
+}
+
+// Party is an NM1 entity with the N3/N4 that follow it.
+type Party struct {
+	// EntityCode is NM101, e.g. 85 billing provider, IL subscriber.
+	EntityCode string
+	// EntityType is NM102: 1 for a person, 2 for an organization.
+	EntityType string
+	// LastName holds the organization name when EntityType is 2.
+	LastName    string
+	FirstName   string
+	MiddleName  string
+	IDQualifier string
+	ID          string
+	Address     Address
+}
+
+// Demographics is a DMG segment.
+type Demographics struct {
+	BirthDate string
+	Gender    string
+}
+
+// BillingProvider is loop 2000A/2010AA.
+type BillingProvider struct {
+	Party
+	TaxID    string
+	Taxonomy string
+}
+
+// Subscriber is loop 2000B with the subscriber (2010BA) and payer (2010BB).
+type Subscriber struct {
+	Party
+	Demographics
+	// PayerResponsibility is SBR01 (P primary, S secondary, ...).
+	PayerResponsibility string
+	// Relationship is SBR02; 18 means the subscriber is the patient.
+	Relationship    string
+	GroupNumber     string
+	ClaimFilingCode string
+	Payer           Party
+}
+
+// Patient is loop 2000C, present when the patient is not the subscriber.
+type Patient struct {
+	Party
+	Demographics
+	// Relationship is PAT01.
+	Relationship string
+}
+
+// Date is a DTP segment with dates converted to YYYY-MM-DD. From and To are
+// equal for single dates (formats D8 and DT, whose hour is dropped), and a
+// TM hour, e.g. the discharge hour, is HH:MM in both.
+type Date struct {
+	Qualifier string
+	From      string
+	To        string
+}
+
+// Diagnosis is one HI code.
+type Diagnosis struct {
+	// Sequence is the 1-based order across the claim's HI segments, which
+	// is what SV107 diagnosis pointers refer to.
+	Sequence  int
+	Qualifier string
+	Code      string
+	// PresentOnAdmission is the POA indicator (institutional only).
+	PresentOnAdmission string
+}
+
+// Principal reports whether d is the principal diagnosis.
+func (d Diagnosis) Principal() bool {
+	return d.Qualifier == "ABK" || d.Qualifier == "BK"
+}
+
+// Provider is a claim (2310x) or line (2420x) level NM1 provider.
+type Provider struct {
+	Party
+	// Role names the NM101 entity, e.g. rendering, attending.
+	Role     string
+	Taxonomy string
+}
+
+// Line is a service line, loop 2400.
+type Line struct {
+	// Number is LX01.
+	Number             string
+	ProcedureQualifier string
+	ProcedureCode      string
+	Modifiers          []string
+	RevenueCode        string
+	Charge             string
+	UnitBasis          string
+	Units              string
+	PlaceOfService     string
+	DiagnosisPointers  []string
+	ServiceFrom        string
+	ServiceTo          string
+	Dates              []Date
+	Providers          []*Provider
+}
+
+// Claim is one CLM (loop 2300) with its enclosing hierarchy. Amounts are kept
+// as the decimal text of the file; conversion happens in the silver layer.
+type Claim struct {
+	Kind Kind
+	// InterchangeControlNumber and TransactionControlNumber trace the claim
+	// back to its envelope.
+	InterchangeControlNumber string
+	TransactionControlNumber string
+
+	ID                string
+	TotalCharge       string
+	FacilityCode      string
+	FacilityQualifier string
+	// FrequencyCode is CLM05-3: 1 original, 7 replacement, 8 void.
+	FrequencyCode     string
+	ProviderSignature string
+	Assignment        string
+	BenefitsAssigned  string
+	ReleaseOfInfo     string
+
+	AdmissionType   string
+	AdmissionSource string
+	PatientStatus   string
+
+	// References maps REF qualifiers (e.g. F8 payer claim control number) to
+	// values.
+	References map[string]string
+	Dates      []Date
+	Diagnoses  []Diagnosis
+	Providers  []*Provider
+	Lines      []*Line
+
+	BillingProvider *BillingProvider
+	Subscriber      *Subscriber
+	// Patient is nil when the subscriber is the patient.
+	Patient *Patient
+
+	// Segment is the 1-based index of the CLM segment in the file.
+	Segment int
+}
+
+// Date returns the first claim-level date with qualifier q.
+func (c *Claim) Date(q string) (Date, bool) {
+	for _, d := range c.Dates {
+		if d.Qualifier == q {
+			return d, true
+		}
+	}
+	return Date{}, false
+}
+
+// PrincipalDiagnosis returns the principal diagnosis code, if any.
+func (c *Claim) PrincipalDiagnosis() string {
+	for _, d := range c.Diagnoses {
+		if d.Principal() {
+			return d.Code
+		}
+	}
+	return ""
+}
+
+// ServicePeriod returns the earliest and latest line service dates.
+func (c *Claim) ServicePeriod() (from, to string) {
+	for _, l := range c.Lines {
+		if l.ServiceFrom != "" && (from == "" || l.ServiceFrom < from) {
+			from = l.ServiceFrom
+		}
+		if l.ServiceTo != "" && l.ServiceTo > to {
+			to = l.ServiceTo
+		}
+	}
+	return from, to
+}
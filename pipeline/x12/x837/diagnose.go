@@ -0,0 +1,265 @@
+package x837
+
+import (
+	"strings"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Loops opened by an NM1 below the hierarchical levels, by NM101. The claim
+// (2310x), other payer (2330x) and service line (2420x) provider loops are
+// lettered differently in the professional and institutional guides.
+var (
+	levelLoops = map[string]string{
+		"85": "2010AA", "87": "2010AB", "PE": "2010AC",
+		"IL": "2010BA", "PR": "2010BB",
+		"QC": "2010CA",
+	}
+	claimLoops = map[Kind]map[string]string{
+		Professional: {
+			"DN": "2310A", "P3": "2310A", "82": "2310B", "77": "2310C",
+			"DQ": "2310D", "PW": "2310E", "45": "2310F",
+		},
+		Institutional: {
+			"71": "2310A", "72": "2310B", "ZZ": "2310C", "82": "2310D",
+			"77": "2310E", "DN": "2310F",
+		},
+	}
+	otherPayerLoops = map[Kind]map[string]string{
+		Professional: {
+			"IL": "2330A", "PR": "2330B", "DN": "2330C", "P3": "2330C",
+			"82": "2330D", "77": "2330E", "DQ": "2330F", "85": "2330G",
+		},
+		Institutional: {
+			"IL": "2330A", "PR": "2330B", "71": "2330C", "72": "2330D",
+			"ZZ": "2330E", "77": "2330F", "82": "2330G", "DN": "2330H",
+			"85": "2330I",
+		},
+	}
+	lineLoops = map[Kind]map[string]string{
+		Professional: {
+			"82": "2420A", "QB": "2420B", "77": "2420C", "DQ": "2420D",
+			"DK": "2420E", "DN": "2420F", "P3": "2420F", "PW": "2420G",
+			"45": "2420H",
+		},
+		Institutional: {
+			"72": "2420A", "ZZ": "2420B", "82": "2420C", "DN": "2420D",
+		},
+	}
+)
+
+// hlLoops maps HL03 to the hierarchical level loop it opens.
+var hlLoops = map[string]string{"20": "2000A", "22": "2000B", "23": "2000C"}
+
+// dtpFormats lists the DTP02 formats of the qualifiers that allow more than
+// D8 and RD8: an 837I admission date may carry its hour (DT) and an 837I
+// discharge hour is a time (TM), while 837P states a discharge date (D8).
+var dtpFormats = map[string][]string{
+	"435": {"D8", "DT"},
+	"096": {"D8", "TM"},
+}
+
+// contains reports whether list holds v.
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostics returns the segment and element problems found so far, in
+// stream order. They never stop parsing: a claim with a bad element is still
+// returned, with the raw value where one was kept.
+func (p *Parser) Diagnostics() []*x12.Diagnostic {
+	return p.diags
+}
+
+// loop returns the loop path of the segment being applied.
+func (p *Parser) loop() string {
+	parts := append([]string(nil), p.levels...)
+	if p.claim != nil {
+		parts = append(parts, "2300")
+	}
+	if p.line != nil {
+		parts = append(parts, "2400")
+	}
+	if p.sub != "" {
+		parts = append(parts, p.sub)
+	}
+	return strings.Join(parts, "/")
+}
+
+// track moves the loop position to the loop seg opens, if any. It runs
+// before the segment is applied, so the claim and line still in progress
+// are those seg belongs to.
+func (p *Parser) track(seg *x12.Segment) {
+	switch seg.ID {
+	case "ST", "SE":
+		p.levels, p.sub = nil, ""
+	case "HL":
+		p.sub = ""
+		loop, ok := hlLoops[seg.Element(3)]
+		if !ok {
+			return
+		}
+		// A level replaces itself and the levels below it.
+		for i, l := range p.levels {
+			if l >= loop {
+				p.levels = p.levels[:i]
+				break
+			}
+		}
+		p.levels = append(p.levels, loop)
+	case "CLM", "LX":
+		p.sub = ""
+	case "SBR":
+		if p.claim != nil {
+			p.sub = "2320"
+		}
+	case "NM1":
+		code := seg.Element(1)
+		switch {
+		case p.claim == nil:
+			p.sub = levelLoops[code]
+		case p.line != nil:
+			p.sub = lineLoops[p.kind][code]
+		case strings.HasPrefix(p.sub, "2320"):
+			if l := otherPayerLoops[p.kind][code]; l != "" {
+				p.sub = "2320/" + l
+			}
+		default:
+			p.sub = claimLoops[p.kind][code]
+		}
+	}
+}
+
+// check records the problems of seg in an 837 transaction set.
+func (p *Parser) check(seg *x12.Segment) {
+	if p.set != "837" {
+		return
+	}
+	switch seg.ID {
+	case "HL":
+		p.required(seg, 1, 0)
+		if p.required(seg, 3, 0) && hlLoops[seg.Element(3)] == "" {
+			p.element(seg, 3, 0, x12.IK4InvalidCode, "hierarchical level code %q is not 20, 22 or 23", seg.Element(3))
+		}
+
+	case "NM1":
+		p.required(seg, 1, 0)
+		if p.required(seg, 2, 0) && seg.Element(2) != "1" && seg.Element(2) != "2" {
+			p.element(seg, 2, 0, x12.IK4InvalidCode, "entity type %q is not 1 or 2", seg.Element(2))
+		}
+
+	case "DMG":
+		if seg.Element(1) == "D8" && seg.Element(2) != "" {
+			if _, _, ok := x12.DateRange("D8", seg.Element(2)); !ok {
+				p.element(seg, 2, 0, x12.IK4InvalidDate, "birth date %q is not CCYYMMDD", seg.Element(2))
+			}
+		}
+
+	case "CLM":
+		if p.subscriber == nil {
+			p.segment(seg, x12.IK3UnexpectedSegment, "CLM outside a subscriber (2000B) or patient (2000C) loop")
+		}
+		p.required(seg, 1, 0)
+		p.amount(seg, 2, "total charge")
+		p.required(seg, 5, 1)
+
+	case "DTP":
+		p.required(seg, 1, 0)
+		format, formats := seg.Element(2), dtpFormats[seg.Element(1)]
+		if formats == nil {
+			formats = []string{"D8", "RD8"}
+		}
+		if !contains(formats, format) {
+			p.element(seg, 2, 0, x12.IK4InvalidCode, "date format %q is not %s", format, strings.Join(formats, " or "))
+			return
+		}
+		if !p.required(seg, 3, 0) {
+			return
+		}
+		if format == "TM" {
+			if _, ok := x12.TimeOfDay(seg.Element(3)); !ok {
+				p.element(seg, 3, 0, x12.IK4InvalidTime, "%q is not a valid TM (HHMM) time", seg.Element(3))
+			}
+		} else if _, _, ok := x12.DateRange(format, seg.Element(3)); !ok {
+			p.element(seg, 3, 0, x12.IK4InvalidDate, "%q is not a valid %s date", seg.Element(3), format)
+		}
+
+	case "HI":
+		if p.claim == nil {
+			p.segment(seg, x12.IK3UnexpectedSegment, "HI outside a claim (2300)")
+			return
+		}
+		for n := 1; n <= len(seg.Elements); n++ {
+			if seg.Element(n) != "" {
+				p.required(seg, n, 2)
+			}
+		}
+		p.required(seg, 1, 0)
+
+	case "LX":
+		if p.claim == nil {
+			p.segment(seg, x12.IK3UnexpectedSegment, "LX outside a claim (2300)")
+			return
+		}
+		p.required(seg, 1, 0)
+
+	case "SV1":
+		if p.line == nil {
+			p.segment(seg, x12.IK3UnexpectedSegment, "SV1 outside a service line (2400)")
+			return
+		}
+		p.required(seg, 1, 2)
+		p.amount(seg, 2, "line charge")
+		p.quantity(seg, 4)
+
+	case "SV2":
+		if p.line == nil {
+			p.segment(seg, x12.IK3UnexpectedSegment, "SV2 outside a service line (2400)")
+			return
+		}
+		p.required(seg, 1, 0)
+		p.amount(seg, 3, "line charge")
+		p.quantity(seg, 5)
+	}
+}
+
+// required records a missing element (or component, when comp > 0) and
+// reports whether it is present.
+func (p *Parser) required(seg *x12.Segment, n, comp int) bool {
+	v := seg.Element(n)
+	if comp > 0 {
+		v = seg.Component(n, comp)
+	}
+	if v != "" {
+		return true
+	}
+	p.element(seg, n, comp, x12.IK4MissingElement, "required element is empty")
+	return false
+}
+
+// amount checks a required monetary element.
+func (p *Parser) amount(seg *x12.Segment, n int, name string) {
+	if p.required(seg, n, 0) && !x12.ValidDecimal(seg.Element(n)) {
+		p.element(seg, n, 0, x12.IK4InvalidCharacter, "%s %q is not a decimal number", name, seg.Element(n))
+	}
+}
+
+// quantity checks an optional unit count.
+func (p *Parser) quantity(seg *x12.Segment, n int) {
+	if v := seg.Element(n); v != "" && !x12.ValidDecimal(v) {
+		p.element(seg, n, 0, x12.IK4InvalidCharacter, "quantity %q is not a decimal number", v)
+	}
+}
+
+func (p *Parser) segment(seg *x12.Segment, code, format string, args ...interface{}) {
+	p.diags = append(p.diags, p.r.Diagnose(seg, p.loop(), code, format, args...))
+}
+
+func (p *Parser) element(seg *x12.Segment, n, comp int, code, format string, args ...interface{}) {
+	p.diags = append(p.diags, p.r.DiagnoseElement(seg, p.loop(), n, comp, code, format, args...))
+}
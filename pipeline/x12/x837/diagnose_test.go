@@ -0,0 +1,158 @@
+package x837
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"claim-management-system/pipeline/x12"
+)
+
+func TestDiagnosticsCleanFiles(t *testing.T) {
+	for _, name := range []string{"837p.x12", "837i.x12", "835.x12", "834.x12"} {
+		f, err := os.Open(filepath.Join("..", "testdata", name))
+		require.NoError(t, err)
+		p := NewParser(f)
+		parseAll(t, p)
+		f.Close()
+		assert.Empty(t, p.Diagnostics(), name)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "837p.x12"))
+	require.NoError(t, err)
+	body := string(data)
+	for _, r := range [][2]string{
+		{"CLM*PCN0001*150.00*", "CLM*PCN0001*15O.00*"},
+		{"NM1*82*1*SMITH", "NM1*82*3*SMITH"},
+		{"LX*2~\nSV1*HC:87880*50.00*UN*1***1~\nDTP*472*D8*20251103~", "LX*2~\nSV1*HC:87880*50.00*UN*1***1~\nDTP*472*D8*20251131~"},
+		{"LX*1~\nSV1*HC:99214*80.00*UN*1***1~\nDTP*472*RD8*20251105-20251106~\nSE*37*0001~", "SE*34*0001~"},
+	} {
+		require.Contains(t, body, r[0])
+		body = strings.Replace(body, r[0], r[1], 1)
+	}
+
+	p := NewParser(strings.NewReader(body))
+	claims := parseAll(t, p)
+	require.Len(t, claims, 2, "Claims with errors are still returned")
+	assert.Equal(t, "15O.00", claims[0].TotalCharge)
+
+	diags := p.Diagnostics()
+	require.Len(t, diags, 4)
+
+	assert.Equal(t, &x12.Diagnostic{
+		Interchange: "000000101", Group: "101", Transaction: "0001",
+		SegmentID: "CLM", Index: 21, Position: 19, Offset: diags[0].Offset,
+		Loop: "2000A/2000B/2300", SegmentCode: x12.IK3DataElementErrors,
+		Element: 2, ElementCode: x12.IK4InvalidCharacter, Value: "15O.00",
+		Msg:     `total charge "15O.00" is not a decimal number`,
+		Snippet: "CLM*PCN0001*15O.00***11:B:1*Y*A*Y*Y",
+	}, diags[0])
+	assert.Equal(t, "x12: segment 21 (CLM) element 02 in 2000A/2000B/2300: total charge \"15O.00\" is not a decimal number", diags[0].Error())
+	assert.Equal(t, int64(strings.Index(body, "CLM*PCN0001")), diags[0].Offset)
+
+	assert.Equal(t, "2000A/2000B/2300/2310B", diags[1].Loop)
+	assert.Equal(t, "2310B", diags[1].LoopID())
+	assert.Equal(t, x12.IK4InvalidCode, diags[1].ElementCode)
+	assert.Equal(t, "3", diags[1].Value)
+
+	assert.Equal(t, "2000A/2000B/2300/2400", diags[2].Loop)
+	assert.Equal(t, "DTP", diags[2].SegmentID)
+	assert.Equal(t, 3, diags[2].Element)
+	assert.Equal(t, x12.IK4InvalidDate, diags[2].ElementCode)
+
+	assert.Equal(t, "LX", diags[3].SegmentID, "A claim without lines misses its 2400 loop")
+	assert.Equal(t, x12.IK3MissingSegment, diags[3].SegmentCode)
+	assert.Equal(t, "2000A/2000B/2300", diags[3].Loop)
+	assert.Zero(t, diags[3].Element)
+	assert.True(t, strings.HasPrefix(diags[3].Snippet, "CLM*PCN0002"), "It is located at the claim's CLM")
+}
+
+func TestDiagnosticsAdmissionAndDischargeHours(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "837i.x12"))
+	require.NoError(t, err)
+	hours := func(admission, discharge string) string {
+		body := strings.Replace(string(data), "DTP*434*RD8*20251101-20251104~",
+			"DTP*434*RD8*20251101-20251104~\nDTP*435*"+admission+"~\nDTP*096*"+discharge+"~", 1)
+		return strings.Replace(body, "SE*29*0001~", "SE*31*0001~", 1)
+	}
+
+	p := NewParser(strings.NewReader(hours("DT*202511010830", "TM*1415")))
+	claims := parseAll(t, p)
+	assert.Empty(t, p.Diagnostics(), "DT and TM are valid for these qualifiers")
+	require.Len(t, claims, 1)
+	assert.Equal(t, []Date{
+		{Qualifier: "434", From: "2025-11-01", To: "2025-11-04"},
+		{Qualifier: "435", From: "2025-11-01", To: "2025-11-01"},
+		{Qualifier: "096", From: "14:15", To: "14:15"},
+	}, claims[0].Dates)
+
+	p = NewParser(strings.NewReader(hours("D8*20251101", "D8*20251104")))
+	parseAll(t, p)
+	assert.Empty(t, p.Diagnostics(), "Dates without hours are valid too")
+
+	p = NewParser(strings.NewReader(hours("TM*0830", "TM*2515")))
+	parseAll(t, p)
+	diags := p.Diagnostics()
+	require.Len(t, diags, 2)
+	assert.Equal(t, x12.IK4InvalidCode, diags[0].ElementCode)
+	assert.Equal(t, `date format "TM" is not D8 or DT`, diags[0].Msg)
+	assert.Equal(t, x12.IK4InvalidTime, diags[1].ElementCode)
+	assert.Equal(t, 3, diags[1].Element)
+}
+
+func TestDiagnosticsLoops(t *testing.T) {
+	body := strings.Join([]string{
+		"ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       *251121*1000*^*00501*000000001*0*T*:",
+		"GS*HC*S*R*20251121*1000*1*X*005010X223A2",
+		"ST*837*0001*005010X223A2",
+		"HI*ABK:I10",
+		"HL*1**20*1",
+		"NM1*85*2*HOSPITAL*****XX*1111111111",
+		"HL*2*1*22*1",
+		"NM1*IL*1*DOE*JANE****MI*MBR0001",
+		"HL*3*2*23*0",
+		"NM1*QC*1*DOE*JIMMY",
+		"DMG*D8*20101304*M",
+		"CLM*PCN9*50.00***11:A:1",
+		"HI*ABK:",
+		"NM1*72**SURGEON",
+		"SBR*S*01*GRP2",
+		"NM1*PR**OTHER PLAN*****PI*P2",
+		"LX*1",
+		"SV2*0450*HC:99284*x*UN*1.5.1",
+		"NM1*82*1*LINE*DOC****XX*2222222222*",
+		"HL*4*1*24*0",
+		"SE*19*0001",
+		"GE*1*1",
+		"IEA*1*000000001",
+	}, "~\n") + "~\n"
+
+	p := NewParser(strings.NewReader(body))
+	parseAll(t, p)
+
+	type note struct{ seg, loop, code string }
+	var got []note
+	for _, d := range p.Diagnostics() {
+		code := d.SegmentCode
+		if d.Element > 0 {
+			code = d.ElementCode
+		}
+		got = append(got, note{d.SegmentID, d.Loop, code})
+	}
+	assert.Equal(t, []note{
+		{"HI", "", x12.IK3UnexpectedSegment},
+		{"DMG", "2000A/2000B/2000C/2010CA", x12.IK4InvalidDate},
+		{"HI", "2000A/2000B/2000C/2300", x12.IK4MissingElement},
+		{"NM1", "2000A/2000B/2000C/2300/2310B", x12.IK4MissingElement},
+		{"NM1", "2000A/2000B/2000C/2300/2320/2330B", x12.IK4MissingElement},
+		{"SV2", "2000A/2000B/2000C/2300/2400", x12.IK4InvalidCharacter},
+		{"SV2", "2000A/2000B/2000C/2300/2400", x12.IK4InvalidCharacter},
+		{"HL", "2000A/2000B/2000C", x12.IK4InvalidCode},
+	}, got)
+}
@@ -0,0 +1,392 @@
+package x837
+
+import (
+	"io"
+	"strings"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// providerRoles names the NM101 codes of the 2310x and 2420x provider loops.
+var providerRoles = map[string]string{
+	"82": "rendering",
+	"71": "attending",
+	"72": "operating",
+	"ZZ": "other_operating",
+	"DN": "referring",
+	"P3": "primary_care",
+	"DK": "ordering",
+	"DQ": "supervising",
+	"77": "service_facility",
+	"QB": "purchased_service",
+}
+
+// diagnosisQualifiers are the HI code list qualifiers that carry diagnoses;
+// other HI segments (procedures, value and occurrence codes) are skipped.
+var diagnosisQualifiers = map[string]bool{
+	"ABK": true, "BK": true, // principal
+	"ABF": true, "BF": true, // other
+	"ABJ": true, "BJ": true, // admitting
+	"ABN": true, "BN": true, // external cause of injury
+	"APR": true, "PR": true, // patient's reason for visit
+}
+
+// Parser streams claims from an 837 file. Only one claim is held in memory at
+// a time, plus the billing provider and subscriber it belongs to.
+type Parser struct {
+	r       *x12.Reader
+	pending *x12.Segment
+	err     error
+
+	kind        Kind
+	set         string
+	interchange string
+	transaction string
+
+	billing    *BillingProvider
+	subscriber *Subscriber
+	patient    *Patient
+	claim      *Claim
+	line       *Line
+
+	// party and demo receive the N3/N4 and DMG that follow an NM1.
+	party    *Party
+	demo     *Demographics
+	provider *Provider
+
+	// levels are the open hierarchical level loops (2000A-C) and sub the
+	// innermost loop below them, for Diagnostics.
+	levels []string
+	sub    string
+	diags  []*x12.Diagnostic
+	// noLines is reported if the claim in progress ends without a line.
+	noLines *x12.Diagnostic
+}
+
+// NewParser returns a Parser reading r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: x12.NewReader(r)}
+}
+
+// Errors returns the envelope errors found so far.
+func (p *Parser) Errors() []*x12.EnvelopeError {
+	return p.r.Errors()
+}
+
+// Next returns the next claim, or io.EOF after the last one.
+func (p *Parser) Next() (*Claim, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for {
+		seg := p.pending
+		p.pending = nil
+		if seg == nil {
+			var err error
+			seg, err = p.r.Next()
+			if err != nil {
+				p.err = err
+				if err == io.EOF && p.claim != nil {
+					return p.finish(), nil
+				}
+				return nil, err
+			}
+		}
+
+		// These segments end the claim in progress; they are handled again
+		// once it has been returned.
+		switch seg.ID {
+		case "CLM", "HL", "SE", "ST", "GE", "IEA", "ISA":
+			if p.claim != nil {
+				p.pending = seg
+				return p.finish(), nil
+			}
+		}
+		p.track(seg)
+		p.apply(seg)
+		p.check(seg)
+	}
+}
+
+func (p *Parser) finish() *Claim {
+	c := p.claim
+	if len(c.Lines) == 0 && p.noLines != nil {
+		p.diags = append(p.diags, p.noLines)
+	}
+	p.claim, p.line, p.provider, p.noLines = nil, nil, nil, nil
+	if c.Kind == "" {
+		c.Kind = Professional
+		for _, l := range c.Lines {
+			if l.RevenueCode != "" {
+				c.Kind = Institutional
+			}
+		}
+	}
+	return c
+}
+
+func (p *Parser) apply(seg *x12.Segment) {
+	switch seg.ID {
+	case "ISA":
+		p.interchange = seg.Element(13)
+	case "GS":
+		p.kind = kindOf(seg.Element(8))
+	case "ST":
+		p.set = seg.Element(1)
+		p.transaction = seg.Element(2)
+		if k := kindOf(seg.Element(3)); k != "" {
+			p.kind = k
+		}
+		p.billing, p.subscriber, p.patient = nil, nil, nil
+		p.party, p.demo = nil, nil
+
+	case "HL":
+		p.party, p.demo = nil, nil
+		switch seg.Element(3) {
+		case "20":
+			p.billing = &BillingProvider{}
+			p.subscriber, p.patient = nil, nil
+		case "22":
+			p.subscriber = &Subscriber{}
+			p.patient = nil
+		case "23":
+			p.patient = &Patient{}
+		}
+
+	case "PRV":
+		switch {
+		case p.provider != nil:
+			p.provider.Taxonomy = seg.Element(3)
+		case p.claim == nil && p.billing != nil && p.subscriber == nil:
+			p.billing.Taxonomy = seg.Element(3)
+		}
+
+	case "SBR":
+		if p.subscriber != nil {
+			p.subscriber.PayerResponsibility = seg.Element(1)
+			p.subscriber.Relationship = seg.Element(2)
+			p.subscriber.GroupNumber = seg.Element(3)
+			p.subscriber.ClaimFilingCode = seg.Element(9)
+		}
+
+	case "PAT":
+		if p.patient != nil {
+			p.patient.Relationship = seg.Element(1)
+		}
+
+	case "NM1":
+		p.nm1(seg)
+
+	case "N3":
+		if p.party != nil {
+			p.party.Address.Line1 = seg.Element(1)
+			p.party.Address.Line2 = seg.Element(2)
+		}
+
+	case "N4":
+		if p.party != nil {
+			p.party.Address.City = seg.Element(1)
+			p.party.Address.State = seg.Element(2)
+			p.party.Address.Zip = seg.Element(3)
+		}
+
+	case "DMG":
+		if p.demo != nil {
+			p.demo.BirthDate, _, _ = x12.DateRange(seg.Element(1), seg.Element(2))
+			p.demo.Gender = seg.Element(3)
+		}
+
+	case "REF":
+		switch {
+		case p.claim == nil && p.billing != nil && p.subscriber == nil && seg.Element(1) == "EI":
+			p.billing.TaxID = seg.Element(2)
+		case p.claim != nil && p.line == nil && p.provider == nil:
+			p.claim.References[seg.Element(1)] = seg.Element(2)
+		}
+
+	case "CLM":
+		p.clm(seg)
+
+	case "CL1":
+		if p.claim != nil {
+			p.claim.AdmissionType = seg.Element(1)
+			p.claim.AdmissionSource = seg.Element(2)
+			p.claim.PatientStatus = seg.Element(3)
+		}
+
+	case "DTP":
+		d := dtp(seg)
+		switch {
+		case p.line != nil:
+			p.line.Dates = append(p.line.Dates, d)
+			if d.Qualifier == "472" {
+				p.line.ServiceFrom, p.line.ServiceTo = d.From, d.To
+			}
+		case p.claim != nil:
+			p.claim.Dates = append(p.claim.Dates, d)
+		}
+
+	case "HI":
+		if p.claim != nil {
+			p.hi(seg)
+		}
+
+	case "LX":
+		if p.claim != nil {
+			p.line = &Line{Number: seg.Element(1)}
+			p.claim.Lines = append(p.claim.Lines, p.line)
+			p.provider, p.party = nil, nil
+		}
+
+	case "SV1":
+		if p.line != nil {
+			p.procedure(seg, 1)
+			p.line.Charge = seg.Element(2)
+			p.line.UnitBasis = seg.Element(3)
+			p.line.Units = seg.Element(4)
+			p.line.PlaceOfService = seg.Element(5)
+			p.line.DiagnosisPointers = seg.Components(7)
+		}
+
+	case "SV2":
+		if p.line != nil {
+			p.line.RevenueCode = seg.Element(1)
+			p.procedure(seg, 2)
+			p.line.Charge = seg.Element(3)
+			p.line.UnitBasis = seg.Element(4)
+			p.line.Units = seg.Element(5)
+		}
+	}
+}
+
+func (p *Parser) nm1(seg *x12.Segment) {
+	party := Party{
+		EntityCode:  seg.Element(1),
+		EntityType:  seg.Element(2),
+		LastName:    seg.Element(3),
+		FirstName:   seg.Element(4),
+		MiddleName:  seg.Element(5),
+		IDQualifier: seg.Element(8),
+		ID:          seg.Element(9),
+	}
+	p.party, p.demo, p.provider = nil, nil, nil
+
+	if p.claim != nil {
+		role, ok := providerRoles[party.EntityCode]
+		if !ok {
+			// Other payer and subscriber loops (2330x) are not modelled.
+			return
+		}
+		prov := &Provider{Party: party, Role: role}
+		if p.line != nil {
+			p.line.Providers = append(p.line.Providers, prov)
+		} else {
+			p.claim.Providers = append(p.claim.Providers, prov)
+		}
+		p.provider, p.party = prov, &prov.Party
+		return
+	}
+
+	switch {
+	case party.EntityCode == "85" && p.billing != nil:
+		p.billing.Party = party
+		p.party = &p.billing.Party
+	case party.EntityCode == "IL" && p.subscriber != nil:
+		p.subscriber.Party = party
+		p.party, p.demo = &p.subscriber.Party, &p.subscriber.Demographics
+	case party.EntityCode == "PR" && p.subscriber != nil:
+		p.subscriber.Payer = party
+		p.party = &p.subscriber.Payer
+	case party.EntityCode == "QC" && p.patient != nil:
+		p.patient.Party = party
+		p.party, p.demo = &p.patient.Party, &p.patient.Demographics
+	}
+}
+
+func (p *Parser) clm(seg *x12.Segment) {
+	p.claim = &Claim{
+		Kind:                     p.kind,
+		InterchangeControlNumber: p.interchange,
+		TransactionControlNumber: p.transaction,
+		ID:                       seg.Element(1),
+		TotalCharge:              seg.Element(2),
+		FacilityCode:             seg.Component(5, 1),
+		FacilityQualifier:        seg.Component(5, 2),
+		FrequencyCode:            seg.Component(5, 3),
+		ProviderSignature:        seg.Element(6),
+		Assignment:               seg.Element(7),
+		BenefitsAssigned:         seg.Element(8),
+		ReleaseOfInfo:            seg.Element(9),
+		References:               map[string]string{},
+		BillingProvider:          p.billing,
+		Subscriber:               p.subscriber,
+		Patient:                  p.patient,
+		Segment:                  seg.Pos.Index,
+	}
+	p.line, p.provider, p.party, p.demo = nil, nil, nil, nil
+	if p.set == "837" {
+		// Built now, while the reader is still inside this transaction set.
+		p.noLines = p.r.Diagnose(seg, p.loop(), x12.IK3MissingSegment, "claim %q has no service line (2400)", p.claim.ID)
+		p.noLines.SegmentID = "LX"
+	}
+}
+
+func (p *Parser) hi(seg *x12.Segment) {
+	for n := 1; n <= len(seg.Elements); n++ {
+		qual := seg.Component(n, 1)
+		if !diagnosisQualifiers[qual] {
+			continue
+		}
+		p.claim.Diagnoses = append(p.claim.Diagnoses, Diagnosis{
+			Sequence:           len(p.claim.Diagnoses) + 1,
+			Qualifier:          qual,
+			Code:               seg.Component(n, 2),
+			PresentOnAdmission: seg.Component(n, 9),
+		})
+	}
+}
+
+// procedure reads a composite medical procedure identifier (SV101, SV202).
+func (p *Parser) procedure(seg *x12.Segment, n int) {
+	comps := seg.Components(n)
+	if len(comps) == 0 {
+		return
+	}
+	p.line.ProcedureQualifier = comps[0]
+	if len(comps) > 1 {
+		p.line.ProcedureCode = comps[1]
+	}
+	for i := 2; i < len(comps) && i < 6; i++ {
+		if comps[i] != "" {
+			p.line.Modifiers = append(p.line.Modifiers, comps[i])
+		}
+	}
+}
+
+func dtp(seg *x12.Segment) Date {
+	d := Date{Qualifier: seg.Element(1)}
+	if seg.Element(2) == "TM" {
+		if hour, ok := x12.TimeOfDay(seg.Element(3)); ok {
+			d.From, d.To = hour, hour
+			return d
+		}
+	}
+	var ok bool
+	d.From, d.To, ok = x12.DateRange(seg.Element(2), seg.Element(3))
+	if !ok {
+		// Keep the raw value; the silver layer rejects it with context.
+		d.From, d.To = seg.Element(3), seg.Element(3)
+	}
+	return d
+}
+
+// kindOf maps an implementation convention reference to a claim kind.
+func kindOf(version string) Kind {
+	switch {
+	case strings.Contains(version, "X222"):
+		return Professional
+	case strings.Contains(version, "X223"):
+		return Institutional
+	}
+	return ""
+}
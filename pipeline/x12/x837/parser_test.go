@@ -0,0 +1,179 @@
+package x837
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFile(t *testing.T, name string) []*Claim {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+	return parseAll(t, NewParser(f))
+}
+
+func parseAll(t *testing.T, p *Parser) []*Claim {
+	t.Helper()
+	var claims []*Claim
+	for {
+		c, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		claims = append(claims, c)
+	}
+	assert.Empty(t, p.Errors())
+	return claims
+}
+
+func TestParseProfessional(t *testing.T) {
+	claims := parseFile(t, "837p.x12")
+	require.Len(t, claims, 2)
+
+	c := claims[0]
+	assert.Equal(t, Professional, c.Kind)
+	assert.Equal(t, "PCN0001", c.ID)
+	assert.Equal(t, "150.00", c.TotalCharge)
+	assert.Equal(t, "11", c.FacilityCode)
+	assert.Equal(t, "1", c.FrequencyCode)
+	assert.Equal(t, "000000101", c.InterchangeControlNumber)
+	assert.Equal(t, "0001", c.TransactionControlNumber)
+	assert.Equal(t, "CLAIMREF0001", c.References["D9"])
+
+	onset, ok := c.Date("431")
+	require.True(t, ok)
+	assert.Equal(t, "2025-11-01", onset.From)
+
+	require.NotNil(t, c.BillingProvider)
+	assert.Equal(t, "RIVERSIDE CLINIC", c.BillingProvider.LastName)
+	assert.Equal(t, "1234567893", c.BillingProvider.ID)
+	assert.Equal(t, "371234567", c.BillingProvider.TaxID)
+	assert.Equal(t, "207Q00000X", c.BillingProvider.Taxonomy)
+	assert.Equal(t, Address{Line1: "100 MAIN ST", City: "SPRINGFIELD", State: "IL", Zip: "62701"}, c.BillingProvider.Address)
+
+	require.NotNil(t, c.Subscriber)
+	assert.Equal(t, "MBR0001", c.Subscriber.ID)
+	assert.Equal(t, "DOE", c.Subscriber.LastName)
+	assert.Equal(t, "1980-01-15", c.Subscriber.BirthDate)
+	assert.Equal(t, "F", c.Subscriber.Gender)
+	assert.Equal(t, "18", c.Subscriber.Relationship)
+	assert.Equal(t, "GRP100", c.Subscriber.GroupNumber)
+	assert.Equal(t, "CI", c.Subscriber.ClaimFilingCode)
+	assert.Equal(t, "PAYER01", c.Subscriber.Payer.ID)
+	assert.Equal(t, "12 OAK AVE", c.Subscriber.Address.Line1)
+	assert.Empty(t, c.Subscriber.Payer.Address, "Payer has no N3/N4 in the fixture")
+	assert.Nil(t, c.Patient)
+
+	require.Len(t, c.Diagnoses, 2)
+	assert.Equal(t, Diagnosis{Sequence: 1, Qualifier: "ABK", Code: "J069"}, c.Diagnoses[0])
+	assert.Equal(t, "J069", c.PrincipalDiagnosis())
+
+	require.Len(t, c.Providers, 1)
+	assert.Equal(t, "rendering", c.Providers[0].Role)
+	assert.Equal(t, "1987654325", c.Providers[0].ID)
+	assert.Equal(t, "207Q00000X", c.Providers[0].Taxonomy)
+
+	require.Len(t, c.Lines, 2)
+	l := c.Lines[0]
+	assert.Equal(t, "1", l.Number)
+	assert.Equal(t, "HC", l.ProcedureQualifier)
+	assert.Equal(t, "99213", l.ProcedureCode)
+	assert.Equal(t, []string{"25"}, l.Modifiers)
+	assert.Equal(t, "100.00", l.Charge)
+	assert.Equal(t, "UN", l.UnitBasis)
+	assert.Equal(t, "1", l.Units)
+	assert.Equal(t, []string{"1", "2"}, l.DiagnosisPointers)
+	assert.Equal(t, "2025-11-03", l.ServiceFrom)
+
+	// The second claim shares the billing provider and subscriber.
+	c2 := claims[1]
+	assert.Equal(t, "7", c2.FrequencyCode)
+	assert.Equal(t, "CLAIMREF0000", c2.References["F8"])
+	assert.Same(t, c.Subscriber, c2.Subscriber)
+	assert.Empty(t, c2.Providers)
+	from, to := c2.ServicePeriod()
+	assert.Equal(t, "2025-11-05", from)
+	assert.Equal(t, "2025-11-06", to)
+}
+
+func TestParseInstitutional(t *testing.T) {
+	claims := parseFile(t, "837i.x12")
+	require.Len(t, claims, 1)
+
+	c := claims[0]
+	assert.Equal(t, Institutional, c.Kind)
+	assert.Equal(t, "11", c.FacilityCode)
+	assert.Equal(t, "A", c.FacilityQualifier)
+	assert.Equal(t, "1", c.AdmissionType)
+	assert.Equal(t, "7", c.AdmissionSource)
+	assert.Equal(t, "01", c.PatientStatus)
+
+	stmt, ok := c.Date("434")
+	require.True(t, ok)
+	assert.Equal(t, Date{Qualifier: "434", From: "2025-11-01", To: "2025-11-04"}, stmt)
+
+	require.Len(t, c.Diagnoses, 3, "Diagnoses from both HI segments")
+	assert.Equal(t, "I214", c.PrincipalDiagnosis())
+	assert.Equal(t, 3, c.Diagnoses[2].Sequence)
+	assert.Equal(t, "E785", c.Diagnoses[2].Code)
+
+	require.Len(t, c.Providers, 1)
+	assert.Equal(t, "attending", c.Providers[0].Role)
+
+	require.Len(t, c.Lines, 2)
+	assert.Equal(t, "0450", c.Lines[0].RevenueCode)
+	assert.Equal(t, "99284", c.Lines[0].ProcedureCode)
+	assert.Equal(t, "1500.00", c.Lines[0].Charge)
+	assert.Equal(t, "0300", c.Lines[1].RevenueCode)
+	assert.Empty(t, c.Lines[1].ProcedureCode)
+	assert.Equal(t, "4", c.Lines[1].Units)
+}
+
+func TestParseDependentPatientAndLineProviders(t *testing.T) {
+	body := strings.Join([]string{
+		"ISA*00*          *00*          *ZZ*SENDER         *ZZ*RECEIVER       *251121*1000*^*00501*000000001*0*T*:",
+		"GS*HC*S*R*20251121*1000*1*X*005010X222A1",
+		"ST*837*0001*005010X222A1",
+		"HL*1**20*1",
+		"NM1*85*2*CLINIC*****XX*1111111111",
+		"HL*2*1*22*1",
+		"SBR*P**GRP*****CI",
+		"NM1*IL*1*DOE*JANE****MI*MBR0001",
+		"HL*3*2*23*0",
+		"PAT*19",
+		"NM1*QC*1*DOE*JIMMY",
+		"DMG*D8*20100304*M",
+		"CLM*PCN9*50.00***11:B:1*Y*A*Y*Y",
+		"HI*ABK:J029:::::::Y",
+		"LX*1",
+		"SV1*HC:99212*50.00*UN*1***1",
+		"DTP*472*D8*BADDATE",
+		"NM1*82*1*LINE*DOC****XX*2222222222",
+		"SE*17*0001",
+		"GE*1*1",
+		"IEA*1*000000001",
+	}, "~\n") + "~\n"
+
+	claims := parseAll(t, NewParser(strings.NewReader(body)))
+	require.Len(t, claims, 1)
+	c := claims[0]
+
+	require.NotNil(t, c.Patient)
+	assert.Equal(t, "19", c.Patient.Relationship)
+	assert.Equal(t, "JIMMY", c.Patient.FirstName)
+	assert.Equal(t, "2010-03-04", c.Patient.BirthDate)
+	assert.Empty(t, c.Subscriber.BirthDate, "Patient DMG should not land on the subscriber")
+
+	assert.Equal(t, "Y", c.Diagnoses[0].PresentOnAdmission)
+	require.Len(t, c.Lines[0].Providers, 1)
+	assert.Equal(t, "2222222222", c.Lines[0].Providers[0].ID)
+	assert.Equal(t, "BADDATE", c.Lines[0].ServiceFrom, "Unparseable dates are kept verbatim")
+}
@@ -0,0 +1,219 @@
+package x837
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"claim-management-system/pipeline/x12"
+)
+
+// Column orders of the CSVs. They are part of the raw-layer contract: append
+// new columns at the end and never reorder.
+var (
+	ClaimHeaderColumns = []string{
+		"file_id", "claim_id", "claim_type", "frequency_code", "facility_code",
+		"total_charge", "statement_from", "statement_to", "service_from", "service_to",
+		"admission_type", "admission_source", "patient_status",
+		"payer_claim_control_number", "principal_diagnosis", "line_count",
+		"billing_provider_npi", "billing_provider_name", "billing_provider_tax_id",
+		"subscriber_id", "subscriber_last_name", "subscriber_first_name",
+		"subscriber_birth_date", "subscriber_gender", "subscriber_relationship",
+		"patient_last_name", "patient_first_name", "patient_birth_date", "patient_gender",
+		"group_number", "claim_filing_code", "payer_id", "payer_name",
+		"interchange_control_number", "transaction_control_number",
+	}
+	ClaimLineColumns = []string{
+		"file_id", "claim_id", "line_number", "procedure_qualifier", "procedure_code",
+		"modifier_1", "modifier_2", "modifier_3", "modifier_4", "revenue_code",
+		"charge", "unit_basis", "units", "place_of_service", "diagnosis_pointers",
+		"service_from", "service_to", "rendering_provider_npi",
+	}
+	DiagnosisColumns = []string{
+		"file_id", "claim_id", "sequence", "qualifier", "code", "present_on_admission", "is_principal",
+	}
+	ProviderColumns = []string{
+		"file_id", "claim_id", "line_number", "role", "entity_code", "entity_type",
+		"last_or_organization_name", "first_name", "npi", "taxonomy", "tax_id",
+		"address_line_1", "address_line_2", "city", "state", "zip",
+	}
+)
+
+// Writer writes claims as the four 837 CSVs. Rows of one claim share file_id
+// and claim_id.
+type Writer struct {
+	// FileID is the file-metadata file_id of the source X12 file.
+	FileID string
+
+	headers   *csv.Writer
+	lines     *csv.Writer
+	diagnoses *csv.Writer
+	providers *csv.Writer
+}
+
+// NewWriter returns a Writer and writes the header row of each CSV.
+func NewWriter(fileID string, headers, lines, diagnoses, providers io.Writer) (*Writer, error) {
+	w := &Writer{
+		FileID:    fileID,
+		headers:   csv.NewWriter(headers),
+		lines:     csv.NewWriter(lines),
+		diagnoses: csv.NewWriter(diagnoses),
+		providers: csv.NewWriter(providers),
+	}
+	for _, h := range []struct {
+		cw   *csv.Writer
+		cols []string
+	}{
+		{w.headers, ClaimHeaderColumns},
+		{w.lines, ClaimLineColumns},
+		{w.diagnoses, DiagnosisColumns},
+		{w.providers, ProviderColumns},
+	} {
+		if err := h.cw.Write(h.cols); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Write writes one claim: a header row, a row per line, diagnosis and
+// provider. The billing provider is written as a claim-level provider.
+func (w *Writer) Write(c *Claim) error {
+	if err := w.headers.Write(w.headerRow(c)); err != nil {
+		return err
+	}
+	for _, l := range c.Lines {
+		if err := w.lines.Write(w.lineRow(c, l)); err != nil {
+			return err
+		}
+	}
+	for _, d := range c.Diagnoses {
+		row := []string{w.FileID, c.ID, strconv.Itoa(d.Sequence), d.Qualifier, d.Code, d.PresentOnAdmission, strconv.FormatBool(d.Principal())}
+		if err := w.diagnoses.Write(row); err != nil {
+			return err
+		}
+	}
+
+	if b := c.BillingProvider; b != nil {
+		if err := w.providers.Write(w.providerRow(c, "", "billing", b.Party, b.Taxonomy, b.TaxID)); err != nil {
+			return err
+		}
+	}
+	for _, p := range c.Providers {
+		if err := w.providers.Write(w.providerRow(c, "", p.Role, p.Party, p.Taxonomy, "")); err != nil {
+			return err
+		}
+	}
+	for _, l := range c.Lines {
+		for _, p := range l.Providers {
+			if err := w.providers.Write(w.providerRow(c, l.Number, p.Role, p.Party, p.Taxonomy, "")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush flushes all four CSVs and returns the first error.
+func (w *Writer) Flush() error {
+	for _, cw := range []*csv.Writer{w.headers, w.lines, w.diagnoses, w.providers} {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) headerRow(c *Claim) []string {
+	stmt, _ := c.Date("434")
+	from, to := c.ServicePeriod()
+
+	var billing BillingProvider
+	if c.BillingProvider != nil {
+		billing = *c.BillingProvider
+	}
+	var sub Subscriber
+	if c.Subscriber != nil {
+		sub = *c.Subscriber
+	}
+	var pat Patient
+	if c.Patient != nil {
+		pat = *c.Patient
+	}
+
+	return []string{
+		w.FileID, c.ID, string(c.Kind), c.FrequencyCode, c.FacilityCode,
+		c.TotalCharge, stmt.From, stmt.To, from, to,
+		c.AdmissionType, c.AdmissionSource, c.PatientStatus,
+		c.References["F8"], c.PrincipalDiagnosis(), strconv.Itoa(len(c.Lines)),
+		npi(billing.Party), billing.LastName, billing.TaxID,
+		sub.ID, sub.LastName, sub.FirstName,
+		sub.BirthDate, sub.Gender, sub.Relationship,
+		pat.LastName, pat.FirstName, pat.BirthDate, pat.Gender,
+		sub.GroupNumber, sub.ClaimFilingCode, sub.Payer.ID, sub.Payer.LastName,
+		c.InterchangeControlNumber, c.TransactionControlNumber,
+	}
+}
+
+func (w *Writer) lineRow(c *Claim, l *Line) []string {
+	mods := make([]string, 4)
+	copy(mods, l.Modifiers)
+	rendering := ""
+	for _, p := range l.Providers {
+		if p.Role == "rendering" {
+			rendering = npi(p.Party)
+		}
+	}
+	if rendering == "" {
+		for _, p := range c.Providers {
+			if p.Role == "rendering" {
+				rendering = npi(p.Party)
+			}
+		}
+	}
+	return []string{
+		w.FileID, c.ID, l.Number, l.ProcedureQualifier, l.ProcedureCode,
+		mods[0], mods[1], mods[2], mods[3], l.RevenueCode,
+		l.Charge, l.UnitBasis, l.Units, l.PlaceOfService, strings.Join(l.DiagnosisPointers, " "),
+		l.ServiceFrom, l.ServiceTo, rendering,
+	}
+}
+
+func (w *Writer) providerRow(c *Claim, line, role string, p Party, taxonomy, taxID string) []string {
+	return []string{
+		w.FileID, c.ID, line, role, p.EntityCode, p.EntityType,
+		p.LastName, p.FirstName, npi(p), taxonomy, taxID,
+		p.Address.Line1, p.Address.Line2, p.Address.City, p.Address.State, p.Address.Zip,
+	}
+}
+
+// npi returns the party's NPI (NM108 = XX).
+func npi(p Party) string {
+	if p.IDQualifier == "XX" {
+		return p.ID
+	}
+	return ""
+}
+
+// Convert parses an 837 stream and writes every claim to w. It returns the
+// number of claims and the envelope errors found; err is set only when the
+// input cannot be read or tokenized.
+func Convert(r io.Reader, w *Writer) (claims int, envErrs []*x12.EnvelopeError, err error) {
+	p := NewParser(r)
+	for {
+		c, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return claims, p.Errors(), err
+		}
+		if err := w.Write(c); err != nil {
+			return claims, p.Errors(), err
+		}
+		claims++
+	}
+	return claims, p.Errors(), w.Flush()
+}
@@ -0,0 +1,76 @@
+package x837
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite golden CSVs in testdata")
+
+var outputs = []string{"claim_header", "claim_line", "diagnosis", "provider"}
+
+func convertFile(t *testing.T, name string) map[string]*bytes.Buffer {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", name+".x12"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	bufs := map[string]*bytes.Buffer{}
+	for _, o := range outputs {
+		bufs[o] = &bytes.Buffer{}
+	}
+	w, err := NewWriter("f-"+name, bufs["claim_header"], bufs["claim_line"], bufs["diagnosis"], bufs["provider"])
+	require.NoError(t, err)
+
+	_, envErrs, err := Convert(f, w)
+	require.NoError(t, err)
+	require.Empty(t, envErrs)
+	return bufs
+}
+
+func TestWriterGolden(t *testing.T) {
+	for _, name := range []string{"837p", "837i"} {
+		t.Run(name, func(t *testing.T) {
+			bufs := convertFile(t, name)
+			for _, o := range outputs {
+				golden := filepath.Join("testdata", name, o+".csv")
+				if *update {
+					require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+					require.NoError(t, os.WriteFile(golden, bufs[o].Bytes(), 0o644))
+					continue
+				}
+				want, err := os.ReadFile(golden)
+				require.NoError(t, err, "run go test ./x12/x837 -update to create golden files")
+				assert.Equal(t, string(want), bufs[o].String(), "%s differs from %s", o, golden)
+			}
+		})
+	}
+}
+
+func TestWriterColumnOrder(t *testing.T) {
+	bufs := convertFile(t, "837p")
+	for o, cols := range map[string][]string{
+		"claim_header": ClaimHeaderColumns,
+		"claim_line":   ClaimLineColumns,
+		"diagnosis":    DiagnosisColumns,
+		"provider":     ProviderColumns,
+	} {
+		rows, err := csv.NewReader(bytes.NewReader(bufs[o].Bytes())).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, cols, rows[0], "%s header", o)
+		for i, row := range rows[1:] {
+			assert.Len(t, row, len(cols), "%s row %d", o, i+1)
+		}
+	}
+
+	// Appending is the only allowed change; these must not move.
+	assert.Equal(t, []string{"file_id", "claim_id"}, ClaimHeaderColumns[:2])
+	assert.Equal(t, []string{"file_id", "claim_id", "line_number"}, ClaimLineColumns[:3])
+}
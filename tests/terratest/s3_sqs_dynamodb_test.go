@@ -1,6 +1,7 @@
 package terratest
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -200,6 +201,68 @@ func TestS3SQSAndDynamoDB(t *testing.T) {
 		}
 		assert.True(t, foundFileID, "Table should have file_id attribute definition")
 
+		// Verify index key attributes are declared as strings
+		attributeTypes := make(map[string]string)
+		for _, attr := range table.AttributeDefinitions {
+			attributeTypes[*attr.AttributeName] = *attr.AttributeType
+		}
+		for _, name := range []string{"checksum", "file_type", "source_system", "status", "ingest_time", "updated_at"} {
+			assert.Equal(t, "S", attributeTypes[name],
+				fmt.Sprintf("%s attribute should be defined as String type", name))
+		}
+
+		// Verify global secondary indexes, their keys and projections
+		expectedIndexes := map[string]struct {
+			hashKey       string
+			rangeKey      string
+			nonKeyAttribs []string
+		}{
+			"checksum-index": {
+				hashKey: "checksum", rangeKey: "ingest_time",
+				nonKeyAttribs: []string{"file_name", "source_system", "status", "duplicate_of", "checksum_mismatch"},
+			},
+			"file-type-index": {
+				hashKey: "file_type", rangeKey: "ingest_time",
+				nonKeyAttribs: []string{"file_name", "source_system", "status", "updated_at", "bucket", "object_key", "version_id", "checksum", "record_count", "size_bytes"},
+			},
+			"source-system-index": {
+				hashKey: "source_system", rangeKey: "ingest_time",
+				nonKeyAttribs: []string{"file_name", "file_type", "status", "updated_at", "bucket", "object_key", "version_id", "checksum", "record_count", "size_bytes"},
+			},
+			"status-index": {
+				hashKey: "status", rangeKey: "updated_at",
+				nonKeyAttribs: []string{"file_name", "file_type", "source_system", "ingest_time", "bucket", "object_key", "version_id", "checksum", "record_count", "size_bytes", "duplicate_of"},
+			},
+		}
+		require.Len(t, table.GlobalSecondaryIndexes, len(expectedIndexes),
+			"Table should have one GSI per secondary access pattern")
+
+		for _, gsi := range table.GlobalSecondaryIndexes {
+			indexName := *gsi.IndexName
+			expected, ok := expectedIndexes[indexName]
+			if !assert.True(t, ok, fmt.Sprintf("Unexpected GSI %s", indexName)) {
+				continue
+			}
+
+			assert.Equal(t, "ACTIVE", *gsi.IndexStatus,
+				fmt.Sprintf("%s should be ACTIVE", indexName))
+
+			keys := make(map[string]string)
+			for _, k := range gsi.KeySchema {
+				keys[*k.KeyType] = *k.AttributeName
+			}
+			assert.Equal(t, expected.hashKey, keys["HASH"],
+				fmt.Sprintf("%s hash key should be %s", indexName, expected.hashKey))
+			assert.Equal(t, expected.rangeKey, keys["RANGE"],
+				fmt.Sprintf("%s range key should be %s", indexName, expected.rangeKey))
+
+			require.NotNil(t, gsi.Projection, fmt.Sprintf("%s should have a projection", indexName))
+			assert.Equal(t, "INCLUDE", *gsi.Projection.ProjectionType,
+				fmt.Sprintf("%s should use an INCLUDE projection", indexName))
+			assert.ElementsMatch(t, expected.nonKeyAttribs, aws.StringValueSlice(gsi.Projection.NonKeyAttributes),
+				fmt.Sprintf("%s should project the attributes its queries return", indexName))
+		}
+
 		// Verify encryption
 		require.NotNil(t, table.SSEDescription, "Table should have SSE description")
 		assert.Equal(t, "ENABLED", *table.SSEDescription.Status,
@@ -311,5 +374,55 @@ func TestS3SQSAndDynamoDB(t *testing.T) {
 		t.Logf("Queue message attributes retrieved successfully. " +
 			"Note: Event propagation may take a few seconds.")
 	})
-}
 
+	// Step 7: Verify EDI Archive Object Lock Configuration
+	t.Run("VerifyArchiveObjectLock", func(t *testing.T) {
+		s3Svc := s3.New(sess)
+
+		s3BucketNames := terraform.OutputMap(t, tfOptions, "s3_bucket_names")
+		retentionDays := terraform.Output(t, tfOptions, "archive_retention_days")
+
+		archiveBucketName := s3BucketNames["archive"]
+		require.NotEmpty(t, archiveBucketName, "Archive bucket name should not be empty")
+		assert.True(t, strings.HasSuffix(archiveBucketName, "-edi-archive"),
+			"Archive bucket name should end with '-edi-archive'")
+
+		// Object Lock requires versioning
+		versioning, err := s3Svc.GetBucketVersioning(&s3.GetBucketVersioningInput{
+			Bucket: aws.String(archiveBucketName),
+		})
+		require.NoError(t, err, "Should be able to get archive bucket versioning")
+		assert.Equal(t, "Enabled", aws.StringValue(versioning.Status),
+			"Archive bucket should have versioning enabled")
+
+		lockOutput, err := s3Svc.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{
+			Bucket: aws.String(archiveBucketName),
+		})
+		require.NoError(t, err, "Should be able to get archive bucket Object Lock configuration")
+
+		lock := lockOutput.ObjectLockConfiguration
+		require.NotNil(t, lock, "Archive bucket should have an Object Lock configuration")
+		assert.Equal(t, "Enabled", aws.StringValue(lock.ObjectLockEnabled),
+			"Object Lock should be enabled on the archive bucket")
+
+		require.NotNil(t, lock.Rule, "Object Lock should have a rule")
+		require.NotNil(t, lock.Rule.DefaultRetention, "Object Lock rule should set a default retention")
+		assert.Equal(t, "COMPLIANCE", aws.StringValue(lock.Rule.DefaultRetention.Mode),
+			"Default retention should use COMPLIANCE mode")
+		assert.Equal(t, retentionDays, fmt.Sprint(aws.Int64Value(lock.Rule.DefaultRetention.Days)),
+			"Default retention days should match the archive_retention_days output")
+		assert.Nil(t, lock.Rule.DefaultRetention.Years,
+			"Default retention should be set in days only")
+
+		// Archived originals are encrypted like the raw layer
+		encryption, err := s3Svc.GetBucketEncryption(&s3.GetBucketEncryptionInput{
+			Bucket: aws.String(archiveBucketName),
+		})
+		require.NoError(t, err, "Should be able to get archive bucket encryption")
+		require.NotNil(t, encryption.ServerSideEncryptionConfiguration, "Archive bucket should have SSE configured")
+		require.NotEmpty(t, encryption.ServerSideEncryptionConfiguration.Rules, "Archive bucket should have an SSE rule")
+		assert.Equal(t, "aws:kms",
+			aws.StringValue(encryption.ServerSideEncryptionConfiguration.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm),
+			"Archive bucket should use KMS encryption")
+	})
+}